@@ -299,3 +299,26 @@ type CancelLargeFileResponse struct {
 	AccountID string `json:"accountId"` // The identifier for the account.
 	BucketID  string `json:"bucketId"`  // The unique ID of the bucket.
 }
+
+// ListUnfinishedLargeFilesRequest is passed to b2_list_unfinished_large_files
+//
+// The response is a ListFileNamesResponse
+type ListUnfinishedLargeFilesRequest struct {
+	BucketID     string `json:"bucketId"`               // The bucket to look for file names in.
+	NamePrefix   string `json:"namePrefix,omitempty"`   // optional - Only return files whose names match this prefix.
+	StartFileID  string `json:"startFileId,omitempty"`  // optional - The first upload to return.
+	MaxFileCount int    `json:"maxFileCount,omitempty"` // optional - The maximum number of files to return from this call. The default value is 100, and the maximum allowed is 100.
+}
+
+// ListPartsRequest is passed to b2_list_parts
+type ListPartsRequest struct {
+	ID              string `json:"fileId"`                    // The ID returned by b2_start_large_file.
+	StartPartNumber int64  `json:"startPartNumber,omitempty"` // optional - The first part to return.
+	MaxPartCount    int    `json:"maxPartCount,omitempty"`    // optional - The maximum number of parts to return from this call. The default value is 100, and the maximum allowed is 1000.
+}
+
+// ListPartsResponse is the response to b2_list_parts
+type ListPartsResponse struct {
+	Parts          []UploadPartResponse `json:"parts"`          // The parts uploaded so far, in order of part number.
+	NextPartNumber *int64               `json:"nextPartNumber"` // What to pass in to startPartNumber for the next search to continue where this one left off, or null if there are no more parts.
+}
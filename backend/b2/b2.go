@@ -53,8 +53,11 @@ const (
 // Globals
 var (
 	minChunkSize       = fs.SizeSuffix(5E6)
+	maxChunkSize       = fs.SizeSuffix(5E9)
 	chunkSize          = fs.SizeSuffix(96 * 1024 * 1024)
 	uploadCutoff       = fs.SizeSuffix(200E6)
+	maxUploadCutoff    = fs.SizeSuffix(5E9)
+	uploadConcurrency  = flags.IntP("b2-upload-concurrency", "", 4, "Number of chunks of a large file to upload concurrently.")
 	b2TestMode         = flags.StringP("b2-test-mode", "", "", "A flag string for X-Bz-Test-Mode header.")
 	b2Versions         = flags.BoolP("b2-versions", "", false, "Include old versions in directory listings.")
 	b2HardDelete       = flags.BoolP("b2-hard-delete", "", false, "Permanently delete files on remote removal, otherwise hide files.")
@@ -236,9 +239,18 @@ func NewFs(name, root string) (fs.Fs, error) {
 	if uploadCutoff < chunkSize {
 		return nil, errors.Errorf("b2: upload cutoff (%v) must be greater than or equal to chunk size (%v)", uploadCutoff, chunkSize)
 	}
+	if uploadCutoff > maxUploadCutoff {
+		return nil, errors.Errorf("b2: upload cutoff can't be more than %v - was %v", maxUploadCutoff, uploadCutoff)
+	}
 	if chunkSize < minChunkSize {
 		return nil, errors.Errorf("b2: chunk size can't be less than %v - was %v", minChunkSize, chunkSize)
 	}
+	if chunkSize > maxChunkSize {
+		return nil, errors.Errorf("b2: chunk size can't be more than %v - was %v", maxChunkSize, chunkSize)
+	}
+	if *uploadConcurrency < 1 {
+		return nil, errors.Errorf("b2: upload concurrency must be at least 1 - was %d", *uploadConcurrency)
+	}
 	bucket, directory, err := parsePath(root)
 	if err != nil {
 		return nil, err
@@ -253,15 +265,14 @@ func NewFs(name, root string) (fs.Fs, error) {
 	}
 	endpoint := config.FileGet(name, "endpoint", defaultEndpoint)
 	f := &Fs{
-		name:         name,
-		bucket:       bucket,
-		root:         directory,
-		account:      account,
-		key:          key,
-		endpoint:     endpoint,
-		srv:          rest.NewClient(fshttp.NewClient(fs.Config)).SetErrorHandler(errorHandler),
-		pacer:        pacer.New().SetMinSleep(minSleep).SetMaxSleep(maxSleep).SetDecayConstant(decayConstant),
-		bufferTokens: make(chan []byte, fs.Config.Transfers),
+		name:     name,
+		bucket:   bucket,
+		root:     directory,
+		account:  account,
+		key:      key,
+		endpoint: endpoint,
+		srv:      rest.NewClient(fshttp.NewClient(fs.Config)).SetErrorHandler(errorHandler),
+		pacer:    pacer.New().SetMinSleep(minSleep).SetMaxSleep(maxSleep).SetDecayConstant(decayConstant),
 	}
 	f.features = (&fs.Features{
 		ReadMimeType:  true,
@@ -274,8 +285,14 @@ func NewFs(name, root string) (fs.Fs, error) {
 		f.srv.SetHeader(testModeHeader, testMode)
 		fs.Debugf(f, "Setting test header \"%s: %s\"", testModeHeader, testMode)
 	}
-	// Fill up the buffer tokens
-	for i := 0; i < fs.Config.Transfers; i++ {
+	// Fill up the buffer tokens - enough for --transfers uploads or
+	// a single upload with --b2-upload-concurrency chunks in flight
+	bufferTokens := fs.Config.Transfers
+	if *uploadConcurrency > bufferTokens {
+		bufferTokens = *uploadConcurrency
+	}
+	f.bufferTokens = make(chan []byte, bufferTokens)
+	for i := 0; i < bufferTokens; i++ {
 		f.bufferTokens <- nil
 	}
 	err = f.authorizeAccount()
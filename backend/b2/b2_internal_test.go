@@ -1,10 +1,20 @@
 package b2
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/ncw/rclone/backend/b2/api"
 	"github.com/ncw/rclone/fstest"
+	"github.com/ncw/rclone/lib/pacer"
+	"github.com/ncw/rclone/lib/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Test b2 string encoding
@@ -168,3 +178,74 @@ func TestParseTimeString(t *testing.T) {
 	}
 
 }
+
+func TestResumeLargeUpload(t *testing.T) {
+	modTime := fstest.Time("2001-02-03T04:05:10.123000000Z")
+	part1 := []byte("part one")
+	sum1 := sha1.Sum(part1)
+	sha1Part1 := hex.EncodeToString(sum1[:])
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var out interface{}
+		switch r.URL.Path {
+		case "/b2_list_unfinished_large_files":
+			var in api.ListUnfinishedLargeFilesRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+			assert.Equal(t, "bucketID", in.BucketID)
+			assert.Equal(t, "dir/file", in.NamePrefix)
+			out = api.ListFileNamesResponse{Files: []api.File{
+				{ID: "other-name", Name: "dir/file2", Info: map[string]string{timeKey: timeString(modTime)}},
+				{ID: "other-time", Name: "dir/file", Info: map[string]string{timeKey: "0"}},
+				{ID: "old", Name: "dir/file", Info: map[string]string{timeKey: timeString(modTime)}, UploadTimestamp: api.Timestamp(modTime)},
+				{ID: "new", Name: "dir/file", Info: map[string]string{timeKey: timeString(modTime)}, UploadTimestamp: api.Timestamp(modTime.Add(time.Hour))},
+			}}
+		case "/b2_list_parts":
+			var in api.ListPartsRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+			assert.Equal(t, "new", in.ID)
+			if in.StartPartNumber == 0 {
+				next := int64(2)
+				out = api.ListPartsResponse{
+					Parts:          []api.UploadPartResponse{{PartNumber: 1, Size: int64(len(part1)), SHA1: sha1Part1}},
+					NextPartNumber: &next,
+				}
+			} else {
+				out = api.ListPartsResponse{
+					Parts: []api.UploadPartResponse{{PartNumber: 2, Size: 8, SHA1: sha1Part1}},
+				}
+			}
+		default:
+			t.Errorf("unexpected request %q", r.URL.Path)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(out))
+	}))
+	defer ts.Close()
+
+	f := &Fs{
+		srv:       rest.NewClient(http.DefaultClient).SetRoot(ts.URL),
+		pacer:     pacer.New(),
+		_bucketID: "bucketID",
+	}
+
+	// The most recent upload with the same name and time is chosen
+	id, err := f.findUnfinishedLargeFile("dir/file", modTime, "")
+	require.NoError(t, err)
+	assert.Equal(t, "new", id)
+
+	// Not if the SHA1 doesn't match
+	id, err = f.findUnfinishedLargeFile("dir/file", modTime, sha1Part1)
+	require.NoError(t, err)
+	assert.Equal(t, "", id)
+
+	// All the pages of parts are listed
+	parts, err := f.listParts("new")
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(parts))
+
+	// Only parts with the same contents are skipped
+	up := &largeUpload{uploaded: parts, sha1s: make([]string, 3)}
+	assert.True(t, up.alreadyUploaded(1, part1))
+	assert.Equal(t, sha1Part1, up.sha1s[0])
+	assert.False(t, up.alreadyUploaded(2, []byte("part two")))
+	assert.False(t, up.alreadyUploaded(3, part1))
+}
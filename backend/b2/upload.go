@@ -11,8 +11,10 @@ import (
 	"fmt"
 	gohash "hash"
 	"io"
+	"io/ioutil"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ncw/rclone/backend/b2/api"
 	"github.com/ncw/rclone/fs"
@@ -67,16 +69,97 @@ func newHashAppendingReader(in io.Reader, h gohash.Hash) *hashAppendingReader {
 
 // largeUpload is used to control the upload of large files which need chunking
 type largeUpload struct {
-	f        *Fs                             // parent Fs
-	o        *Object                         // object being uploaded
-	in       io.Reader                       // read the data from here
-	wrap     accounting.WrapFn               // account parts being transferred
-	id       string                          // ID of the file being uploaded
-	size     int64                           // total size
-	parts    int64                           // calculated number of parts, if known
-	sha1s    []string                        // slice of SHA1s for each part
-	uploadMu sync.Mutex                      // lock for upload variable
-	uploads  []*api.GetUploadPartURLResponse // result of get upload URL calls
+	f        *Fs                              // parent Fs
+	o        *Object                          // object being uploaded
+	in       io.Reader                        // read the data from here
+	wrap     accounting.WrapFn                // account parts being transferred
+	id       string                           // ID of the file being uploaded
+	size     int64                            // total size
+	parts    int64                            // calculated number of parts, if known
+	sha1s    []string                         // slice of SHA1s for each part
+	uploaded map[int64]api.UploadPartResponse // parts already uploaded if resuming, by part number
+	tokens   chan struct{}                    // limit the chunks in flight to --b2-upload-concurrency
+	uploadMu sync.Mutex                       // lock for upload variable
+	uploads  []*api.GetUploadPartURLResponse  // result of get upload URL calls
+}
+
+// findUnfinishedLargeFile looks for an unfinished large file called
+// name started by a previous upload of the same file, so the upload
+// can be resumed.  The modification time and SHA1 (which may be
+// blank) stored when the upload was started must match.
+//
+// It returns the ID of the most recently started match or "" if none
+// was found.
+func (f *Fs) findUnfinishedLargeFile(name string, modTime time.Time, sha1 string) (id string, err error) {
+	bucketID, err := f.getBucketID()
+	if err != nil {
+		return "", err
+	}
+	opts := rest.Opts{
+		Method: "POST",
+		Path:   "/b2_list_unfinished_large_files",
+	}
+	var request = api.ListUnfinishedLargeFilesRequest{
+		BucketID:     bucketID,
+		NamePrefix:   name,
+		MaxFileCount: 100,
+	}
+	var latest api.Timestamp
+	for {
+		var response api.ListFileNamesResponse
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err := f.srv.CallJSON(&opts, &request, &response)
+			return f.shouldRetry(resp, err)
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "failed to list unfinished large files")
+		}
+		for _, file := range response.Files {
+			if file.Name != name || file.Info[timeKey] != timeString(modTime) || file.Info[sha1Key] != sha1 {
+				continue
+			}
+			if id == "" || time.Time(file.UploadTimestamp).After(time.Time(latest)) {
+				id, latest = file.ID, file.UploadTimestamp
+			}
+		}
+		if response.NextFileID == nil {
+			break
+		}
+		request.StartFileID = *response.NextFileID
+	}
+	return id, nil
+}
+
+// listParts returns the parts uploaded so far to the unfinished large
+// file with id, by part number
+func (f *Fs) listParts(id string) (parts map[int64]api.UploadPartResponse, err error) {
+	opts := rest.Opts{
+		Method: "POST",
+		Path:   "/b2_list_parts",
+	}
+	var request = api.ListPartsRequest{
+		ID:           id,
+		MaxPartCount: 1000,
+	}
+	parts = make(map[int64]api.UploadPartResponse)
+	for {
+		var response api.ListPartsResponse
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err := f.srv.CallJSON(&opts, &request, &response)
+			return f.shouldRetry(resp, err)
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list parts")
+		}
+		for _, part := range response.Parts {
+			parts[part.PartNumber] = part
+		}
+		if response.NextPartNumber == nil {
+			break
+		}
+		request.StartPartNumber = *response.NextPartNumber
+	}
+	return parts, nil
 }
 
 // newLargeUpload starts an upload of object o from in with metadata in src
@@ -119,26 +202,55 @@ func (f *Fs) newLargeUpload(o *Object, in io.Reader, src fs.ObjectInfo) (up *lar
 	if calculatedSha1, err := src.Hash(hash.SHA1); err == nil && calculatedSha1 != "" {
 		request.Info[sha1Key] = calculatedSha1
 	}
-	var response api.StartLargeFileResponse
-	err = f.pacer.Call(func() (bool, error) {
-		resp, err := f.srv.CallJSON(&opts, &request, &response)
-		return f.shouldRetry(resp, err)
-	})
-	if err != nil {
-		return nil, err
-	}
 	// unwrap the accounting from the input, we use wrap to put it
 	// back on after the buffering
 	in, wrap := accounting.UnWrap(in)
 	up = &largeUpload{
-		f:     f,
-		o:     o,
-		in:    in,
-		wrap:  wrap,
-		id:    response.ID,
-		size:  size,
-		parts: parts,
-		sha1s: make([]string, sha1SliceSize),
+		f:      f,
+		o:      o,
+		in:     in,
+		wrap:   wrap,
+		size:   size,
+		parts:  parts,
+		sha1s:  make([]string, sha1SliceSize),
+		tokens: make(chan struct{}, *uploadConcurrency),
+	}
+	// Resume an unfinished upload of this file if there is one -
+	// only possible if the size is known as streams can't be
+	// read again
+	if size >= 0 {
+		up.id, err = f.findUnfinishedLargeFile(request.Name, modTime, request.Info[sha1Key])
+		if err != nil {
+			fs.Debugf(o, "Not resuming large file upload: %v", err)
+			up.id = ""
+		}
+		if up.id != "" {
+			up.uploaded, err = f.listParts(up.id)
+			for part := range up.uploaded {
+				if part > parts {
+					err = errors.Errorf("it has part %d but this upload only needs %d", part, parts)
+					break
+				}
+			}
+			if err != nil {
+				fs.Debugf(o, "Not resuming large file upload: %v", err)
+				up.id = ""
+				up.uploaded = nil
+			} else {
+				fs.Infof(o, "Resuming large file upload (id %q) with %d parts already uploaded", up.id, len(up.uploaded))
+			}
+		}
+	}
+	if up.id == "" {
+		var response api.StartLargeFileResponse
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err := f.srv.CallJSON(&opts, &request, &response)
+			return f.shouldRetry(resp, err)
+		})
+		if err != nil {
+			return nil, err
+		}
+		up.id = response.ID
 	}
 	return up, nil
 }
@@ -298,10 +410,38 @@ func (up *largeUpload) cancel() error {
 	return err
 }
 
+// alreadyUploaded returns true if part was uploaded with the
+// contents buf by the upload being resumed, setting its SHA1 if so.
+func (up *largeUpload) alreadyUploaded(part int64, buf []byte) bool {
+	uploaded, ok := up.uploaded[part]
+	if !ok || uploaded.Size != int64(len(buf)) {
+		return false
+	}
+	sum := sha1.Sum(buf)
+	hexSum := hex.EncodeToString(sum[:])
+	if hexSum != uploaded.SHA1 {
+		fs.Debugf(up.o, "Chunk %d differs from the one already uploaded - sending again", part)
+		return false
+	}
+	up.sha1s[part-1] = hexSum
+	return true
+}
+
+// managedTransferChunk transfers the chunk in the background, waiting
+// first if --b2-upload-concurrency chunks are already in flight
 func (up *largeUpload) managedTransferChunk(wg *sync.WaitGroup, errs chan error, part int64, buf []byte) {
+	if up.alreadyUploaded(part, buf) {
+		fs.Debugf(up.o, "Skipping chunk %d as already uploaded", part)
+		// Account the chunk as if it had been transferred
+		_, _ = io.Copy(ioutil.Discard, up.wrap(bytes.NewReader(buf)))
+		up.f.putUploadBlock(buf)
+		return
+	}
+	up.tokens <- struct{}{}
 	wg.Add(1)
 	go func(part int64, buf []byte) {
 		defer wg.Done()
+		defer func() { <-up.tokens }()
 		defer up.f.putUploadBlock(buf)
 		err := up.transferChunk(part, buf)
 		if err != nil {
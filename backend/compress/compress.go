@@ -0,0 +1,783 @@
+// Package compress provides wrappers for Fs and Object which implement compression
+package compress
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+// Constants
+const (
+	gzipExt     = ".gz" // extension of compressed objects
+	sizeDigits  = 16    // number of hex digits used for the size
+	markerChars = 1 + sizeDigits + len(gzipExt)
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "compress",
+		Description: "Compress/Decompress a remote",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name: "remote",
+			Help: "Remote to compress/decompress.\nNormally should contain a ':' and a path, eg \"myremote:path/to/dir\",\n\"myremote:bucket\" or maybe \"myremote:\" (not recommended).",
+		}, {
+			Name: "level",
+			Help: "GZIP compression level -1 to 9.\nHigher levels compress better but are slower.",
+			Examples: []fs.OptionExample{
+				{
+					Value: "-1",
+					Help:  "Use the default compression level (6).",
+				}, {
+					Value: "1",
+					Help:  "Fastest compression.",
+				}, {
+					Value: "9",
+					Help:  "Best compression.",
+				},
+			},
+			Optional: true,
+		}},
+	})
+}
+
+// compressedName returns the name of the compressed object storing
+// remote which has size bytes when uncompressed
+func compressedName(remote string, size int64) string {
+	return fmt.Sprintf("%s.%0*x%s", remote, sizeDigits, size, gzipExt)
+}
+
+// parseCompressedName checks to see if wrappedRemote is the name of a
+// compressed object, returning the original name and the
+// uncompressed size if so.
+func parseCompressedName(wrappedRemote string) (remote string, size int64, ok bool) {
+	if len(wrappedRemote) <= markerChars || !strings.HasSuffix(wrappedRemote, gzipExt) {
+		return "", 0, false
+	}
+	dot := len(wrappedRemote) - markerChars
+	if wrappedRemote[dot] != '.' {
+		return "", 0, false
+	}
+	size, err := strconv.ParseInt(wrappedRemote[dot+1:dot+1+sizeDigits], 16, 64)
+	if err != nil || size < 0 {
+		return "", 0, false
+	}
+	return wrappedRemote[:dot], size, true
+}
+
+// NewFs contstructs an Fs from the path, container:path
+func NewFs(name, rpath string) (fs.Fs, error) {
+	remote := config.FileGet(name, "remote")
+	if strings.HasPrefix(remote, name+":") {
+		return nil, errors.New("can't point compress remote at itself - check the value of the remote setting")
+	}
+	level, err := strconv.Atoi(config.FileGet(name, "level", "-1"))
+	if err != nil || level < gzip.DefaultCompression || level > gzip.BestCompression {
+		return nil, errors.Errorf("bad compression level %q - must be -1 to 9", config.FileGet(name, "level"))
+	}
+	remotePath := path.Join(remote, rpath)
+	wrappedFs, err := fs.NewFs(remotePath)
+	if err != fs.ErrorIsFile && err != nil {
+		return nil, errors.Wrapf(err, "failed to make remote %q to wrap", remotePath)
+	}
+	f := &Fs{
+		Fs:    wrappedFs,
+		name:  name,
+		root:  rpath,
+		level: level,
+	}
+	// the features here are ones we could support, and they are
+	// ANDed with the ones from wrappedFs
+	f.features = (&fs.Features{
+		DuplicateFiles:          true,
+		ReadMimeType:            false, // MimeTypes not supported with compress
+		WriteMimeType:           false,
+		BucketBased:             true,
+		CanHaveEmptyDirectories: true,
+		SlowHash:                true, // compressed objects are read to hash them
+	}).Fill(f).Mask(wrappedFs).WrapsFs(f, wrappedFs)
+	// PutStream works whatever the wrapped remote supports as
+	// the data is spooled to disk if necessary
+	f.features.PutStream = f.PutStream
+
+	doChangeNotify := wrappedFs.Features().ChangeNotify
+	if doChangeNotify != nil {
+		f.features.ChangeNotify = func(notifyFunc func(string, fs.EntryType), pollInterval time.Duration) chan bool {
+			wrappedNotifyFunc := func(path string, entryType fs.EntryType) {
+				if remote, _, ok := parseCompressedName(path); ok && entryType == fs.EntryObject {
+					path = remote
+				}
+				notifyFunc(path, entryType)
+			}
+			return doChangeNotify(wrappedNotifyFunc, pollInterval)
+		}
+	}
+
+	// rpath may point to a compressed object which the wrapped
+	// remote doesn't know is a file as it has a different name
+	if err == nil && rpath != "" {
+		entries, listErr := wrappedFs.List("")
+		if listErr == fs.ErrorDirNotFound || (listErr == nil && len(entries) == 0) {
+			parent := path.Dir(rpath)
+			if parent == "." {
+				parent = ""
+			}
+			parentFs, parentErr := NewFs(name, parent)
+			if parentErr == nil {
+				if _, findErr := parentFs.NewObject(path.Base(rpath)); findErr == nil {
+					parentFs.(*Fs).root = rpath
+					return parentFs, fs.ErrorIsFile
+				}
+			}
+		}
+	}
+
+	return f, err
+}
+
+// Fs represents a wrapped fs.Fs
+type Fs struct {
+	fs.Fs
+	name     string
+	root     string
+	features *fs.Features // optional features
+	level    int          // gzip compression level
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// String returns a description of the FS
+func (f *Fs) String() string {
+	return fmt.Sprintf("Compressed drive '%s:%s'", f.name, f.root)
+}
+
+// Convert the wrapped objects in entries.  This alters entries
+// returning it as newEntries.
+func (f *Fs) wrapEntries(entries fs.DirEntries) (newEntries fs.DirEntries, err error) {
+	newEntries = entries[:0] // in place filter
+	for _, entry := range entries {
+		switch x := entry.(type) {
+		case fs.Object:
+			newEntries = append(newEntries, f.newObject(x))
+		case fs.Directory:
+			newEntries = append(newEntries, x)
+		default:
+			return nil, errors.Errorf("Unknown object type %T", entry)
+		}
+	}
+	return newEntries, nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(dir string) (entries fs.DirEntries, err error) {
+	entries, err = f.Fs.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrapEntries(entries)
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+//
+// Don't implement this unless you have a more efficient way
+// of listing recursively that doing a directory traversal.
+func (f *Fs) ListR(dir string, callback fs.ListRCallback) (err error) {
+	return f.Fs.Features().ListR(dir, func(entries fs.DirEntries) error {
+		newEntries, err := f.wrapEntries(entries)
+		if err != nil {
+			return err
+		}
+		return callback(newEntries)
+	})
+}
+
+// NewObject finds the Object at remote.
+//
+// As the name of a compressed object contains its size this has to
+// list the directory to find it.
+func (f *Fs) NewObject(remote string) (fs.Object, error) {
+	dir := path.Dir(remote)
+	if dir == "." {
+		dir = ""
+	}
+	entries, err := f.Fs.List(dir)
+	if err == fs.ErrorDirNotFound {
+		return nil, fs.ErrorObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var found *Object
+	for _, entry := range entries {
+		o, ok := entry.(fs.Object)
+		if !ok {
+			continue
+		}
+		obj := f.newObject(o)
+		if obj.Remote() != remote {
+			continue
+		}
+		// Prefer the compressed object if there are both
+		if found == nil || obj.compressed {
+			found = obj
+		}
+	}
+	if found == nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return found, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	in io.Reader
+	n  int64
+}
+
+// Read bytes from the underlying reader counting them
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.in.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// compress compresses in into out
+func (f *Fs) compress(out io.Writer, in io.Reader) error {
+	gz, err := gzip.NewWriterLevel(out, f.level)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(gz, in)
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// spool compresses in into a temporary file returning it rewound
+// ready for reading and the compressed size.
+func (f *Fs) spool(in io.Reader) (spoolFile *os.File, size int64, err error) {
+	spoolFile, err = ioutil.TempFile("", "rclone-compress-")
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to make spool file")
+	}
+	defer func() {
+		if err != nil {
+			_ = spoolFile.Close()
+			_ = os.Remove(spoolFile.Name())
+		}
+	}()
+	err = f.compress(spoolFile, in)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to compress to spool file")
+	}
+	size, err = spoolFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, err = spoolFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, 0, err
+	}
+	return spoolFile, size, nil
+}
+
+// put compresses in and uploads it to the wrapped remote.
+//
+// If old is set then it is the object being updated, which is
+// removed if the new data is stored under a different name.
+//
+// If the uncompressed size is known and the wrapped remote can stream
+// uploads of unknown size then the data is compressed on the fly,
+// otherwise it is compressed to a temporary file first.
+func (f *Fs) put(in io.Reader, src fs.ObjectInfo, options []fs.OpenOption, old *Object) (*Object, error) {
+	// Hash the uncompressed data
+	hasher, err := hash.NewMultiHasherTypes(f.Hashes())
+	if err != nil {
+		return nil, err
+	}
+	counter := &countingReader{in: io.TeeReader(in, hasher)}
+
+	size := src.Size()
+	putStream := f.Fs.Features().PutStream
+	var o fs.Object
+	if size >= 0 && putStream != nil && (old == nil || old.Object.Remote() != compressedName(src.Remote(), size)) {
+		// Compress on the fly
+		pr, pw := io.Pipe()
+		go func() {
+			_ = pw.CloseWithError(f.compress(pw, counter))
+		}()
+		o, err = putStream(pr, f.newObjectInfo(src, compressedName(src.Remote(), size), -1), options...)
+		_ = pr.CloseWithError(errors.New("upload finished"))
+		if err != nil {
+			return nil, err
+		}
+		if counter.n != size {
+			if removeErr := o.Remove(); removeErr != nil {
+				fs.Errorf(o, "Failed to remove corrupted object: %v", removeErr)
+			}
+			return nil, errors.Errorf("corrupted on transfer: read %d bytes but expected %d", counter.n, size)
+		}
+	} else {
+		// Compress to a temporary file
+		spoolFile, compressedSize, err := f.spool(counter)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = spoolFile.Close()
+			_ = os.Remove(spoolFile.Name())
+		}()
+		if size >= 0 && counter.n != size {
+			return nil, errors.Errorf("corrupted on transfer: read %d bytes but expected %d", counter.n, size)
+		}
+		size = counter.n
+		info := f.newObjectInfo(src, compressedName(src.Remote(), size), compressedSize)
+		if old != nil && old.Object.Remote() == info.Remote() {
+			o = old.Object
+			err = o.Update(spoolFile, info, options...)
+		} else {
+			o, err = f.Fs.Put(spoolFile, info, options...)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove the old object if it is stored under a different name
+	if old != nil && old.Object.Remote() != o.Remote() {
+		err = old.Object.Remove()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to remove old version")
+		}
+	}
+
+	newObj := f.newObject(o)
+	newObj.hashes = hasher.Sums()
+	return newObj, nil
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// May create the object even if it returns an error - if so
+// will return the object and the error, otherwise will return
+// nil and the error
+func (f *Fs) Put(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o, err := f.put(in, src, options, nil)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+func (f *Fs) PutStream(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(in, src, options...)
+}
+
+// Hashes returns the supported hash sets.
+//
+// These are the hashes of the uncompressed data.
+func (f *Fs) Hashes() hash.Set {
+	return f.Fs.Hashes()
+}
+
+// Purge all files in the root and the root directory
+//
+// Implement this if you have a way of deleting all the files
+// quicker than just running Remove() on the result of List()
+//
+// Return an error if it doesn't exist
+func (f *Fs) Purge() error {
+	do := f.Fs.Features().Purge
+	if do == nil {
+		return fs.ErrorCantPurge
+	}
+	return do()
+}
+
+// wrappedRemote returns the name o should have in the wrapped remote
+// if it was at remote
+func (o *Object) wrappedRemote(remote string) string {
+	if o.compressed {
+		return compressedName(remote, o.size)
+	}
+	return remote
+}
+
+// Copy src to this remote using server side copy operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().Copy
+	if do == nil {
+		return nil, fs.ErrorCantCopy
+	}
+	o, ok := src.(*Object)
+	if !ok {
+		return nil, fs.ErrorCantCopy
+	}
+	oResult, err := do(o.Object, o.wrappedRemote(remote))
+	if err != nil {
+		return nil, err
+	}
+	return f.newObjectWithHashes(oResult, o), nil
+}
+
+// Move src to this remote using server side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().Move
+	if do == nil {
+		return nil, fs.ErrorCantMove
+	}
+	o, ok := src.(*Object)
+	if !ok {
+		return nil, fs.ErrorCantMove
+	}
+	oResult, err := do(o.Object, o.wrappedRemote(remote))
+	if err != nil {
+		return nil, err
+	}
+	return f.newObjectWithHashes(oResult, o), nil
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(src fs.Fs, srcRemote, dstRemote string) error {
+	do := f.Fs.Features().DirMove
+	if do == nil {
+		return fs.ErrorCantDirMove
+	}
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(srcFs, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	return do(srcFs.Fs, srcRemote, dstRemote)
+}
+
+// CleanUp the trash in the Fs
+//
+// Implement this if you have a way of emptying the trash or
+// otherwise cleaning up old versions of files.
+func (f *Fs) CleanUp() error {
+	do := f.Fs.Features().CleanUp
+	if do == nil {
+		return errors.New("can't CleanUp")
+	}
+	return do()
+}
+
+// About gets quota information from the Fs
+func (f *Fs) About() (*fs.Usage, error) {
+	do := f.Fs.Features().About
+	if do == nil {
+		return nil, errors.New("About not supported")
+	}
+	return do()
+}
+
+// UnWrap returns the Fs that this Fs is wrapping
+func (f *Fs) UnWrap() fs.Fs {
+	return f.Fs
+}
+
+// Object describes a wrapped object for being read from the Fs
+//
+// Objects stored with a compressed name are decompressed when read,
+// others are passed through unchanged.
+type Object struct {
+	fs.Object
+	f          *Fs
+	remote     string // the uncompressed name
+	size       int64  // the uncompressed size
+	compressed bool   // set if the object is compressed
+	mu         sync.Mutex
+	hashes     map[hash.Type]string // hashes of the uncompressed data if known
+}
+
+func (f *Fs) newObject(o fs.Object) *Object {
+	obj := &Object{
+		Object: o,
+		f:      f,
+		remote: o.Remote(),
+		size:   o.Size(),
+	}
+	if remote, size, ok := parseCompressedName(obj.remote); ok {
+		obj.remote = remote
+		obj.size = size
+		obj.compressed = true
+	}
+	return obj
+}
+
+// newObjectWithHashes makes an Object from o copying any hashes
+// already known for src which has the same contents
+func (f *Fs) newObjectWithHashes(o fs.Object, src *Object) *Object {
+	obj := f.newObject(o)
+	src.mu.Lock()
+	obj.hashes = src.hashes
+	src.mu.Unlock()
+	return obj
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.f
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Remote()
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Size returns the size of the file
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// Hash returns the selected checksum of the file
+// If no checksum is available it returns ""
+//
+// For compressed objects this is the hash of the uncompressed data
+// which needs the object to be read unless it has just been uploaded,
+// so the Fs is marked with SlowHash.
+func (o *Object) Hash(ht hash.Type) (string, error) {
+	if !o.compressed {
+		return o.Object.Hash(ht)
+	}
+	if !o.f.Hashes().Contains(ht) {
+		return "", hash.ErrUnsupported
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.hashes == nil {
+		in, err := o.Open()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to open object to hash")
+		}
+		hashes, err := hash.StreamTypes(in, o.f.Hashes())
+		closeErr := in.Close()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to hash object")
+		}
+		if closeErr != nil {
+			return "", errors.Wrap(closeErr, "failed to hash object")
+		}
+		o.hashes = hashes
+	}
+	return o.hashes[ht], nil
+}
+
+// UnWrap returns the wrapped Object
+func (o *Object) UnWrap() fs.Object {
+	return o.Object
+}
+
+// decompressor reads the uncompressed data of an object
+type decompressor struct {
+	io.Reader
+	gz *gzip.Reader
+	rc io.ReadCloser
+}
+
+// Close the decompressor and the underlying object
+func (d *decompressor) Close() error {
+	err := d.gz.Close()
+	closeErr := d.rc.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Open opens the file for read.  Call Close() on the returned io.ReadCloser
+//
+// gzip streams can't be seeked so a ranged read of a compressed
+// object reads and discards the data before the range.
+func (o *Object) Open(options ...fs.OpenOption) (rc io.ReadCloser, err error) {
+	if !o.compressed {
+		return o.Object.Open(options...)
+	}
+	var openOptions []fs.OpenOption
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.Size())
+		default:
+			// pass on Options to underlying open if appropriate
+			openOptions = append(openOptions, option)
+		}
+	}
+	in, err := o.Object.Open(openOptions...)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		_ = in.Close()
+		return nil, errors.Wrap(err, "failed to read compressed object")
+	}
+	d := &decompressor{Reader: gz, gz: gz, rc: in}
+	if offset > 0 {
+		_, err = io.CopyN(ioutil.Discard, gz, offset)
+		if err != nil && err != io.EOF {
+			_ = d.Close()
+			return nil, errors.Wrap(err, "failed to seek compressed object")
+		}
+	}
+	if limit >= 0 {
+		d.Reader = io.LimitReader(gz, limit)
+	}
+	return d, nil
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	newObj, err := o.f.put(in, src, options, o)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	o.Object = newObj.Object
+	o.size = newObj.size
+	o.compressed = newObj.compressed
+	o.hashes = newObj.hashes
+	o.mu.Unlock()
+	return nil
+}
+
+// ObjectInfo describes a wrapped fs.ObjectInfo for being the source
+//
+// This gives the compressed name and size
+type ObjectInfo struct {
+	fs.ObjectInfo
+	f      *Fs
+	remote string
+	size   int64
+}
+
+func (f *Fs) newObjectInfo(src fs.ObjectInfo, remote string, size int64) *ObjectInfo {
+	return &ObjectInfo{
+		ObjectInfo: src,
+		f:          f,
+		remote:     remote,
+		size:       size,
+	}
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *ObjectInfo) Fs() fs.Info {
+	return o.f
+}
+
+// Remote returns the remote path
+func (o *ObjectInfo) Remote() string {
+	return o.remote
+}
+
+// Size returns the size of the compressed file or -1 if not known
+func (o *ObjectInfo) Size() int64 {
+	return o.size
+}
+
+// Hash returns the selected checksum of the file
+// If no checksum is available it returns ""
+func (o *ObjectInfo) Hash(hash hash.Type) (string, error) {
+	return "", nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.Purger          = (*Fs)(nil)
+	_ fs.Copier          = (*Fs)(nil)
+	_ fs.Mover           = (*Fs)(nil)
+	_ fs.DirMover        = (*Fs)(nil)
+	_ fs.PutStreamer     = (*Fs)(nil)
+	_ fs.CleanUpper      = (*Fs)(nil)
+	_ fs.UnWrapper       = (*Fs)(nil)
+	_ fs.ListRer         = (*Fs)(nil)
+	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.ObjectInfo      = (*ObjectInfo)(nil)
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+)
@@ -0,0 +1,107 @@
+package compress
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedName(t *testing.T) {
+	assert.Equal(t, "file.txt.0000000000000064.gz", compressedName("file.txt", 100))
+	for _, test := range []struct {
+		in     string
+		remote string
+		size   int64
+		ok     bool
+	}{
+		{"file.txt.0000000000000064.gz", "file.txt", 100, true},
+		{"dir/a.0000000000000000.gz", "dir/a", 0, true},
+		{".0000000000000064.gz", "", 0, false},
+		{"file.txt", "", 0, false},
+		{"file.txt.gz", "", 0, false},
+		{"logs.2018.gz", "", 0, false},
+		{"file.txt.000000000000006g.gz", "", 0, false},
+		{"file.txt_0000000000000064.gz", "", 0, false},
+		{"file.txt.ffffffffffffffff.gz", "", 0, false},
+	} {
+		remote, size, ok := parseCompressedName(test.in)
+		assert.Equal(t, test.remote, remote, test.in)
+		assert.Equal(t, test.size, size, test.in)
+		assert.Equal(t, test.ok, ok, test.in)
+	}
+}
+
+// Check compressed and uncompressed objects can be read side by side
+func TestMixedObjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-compress-mixed")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	name := "TestCompressMixed"
+	config.FileSet(name, "type", "compress")
+	config.FileSet(name, "remote", dir)
+	f, err := fs.NewFs(name + ":")
+	require.NoError(t, err)
+
+	// An uncompressed file put straight into the wrapped remote
+	plain := []byte("plain contents")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "plain.txt"), plain, 0600))
+
+	// A compressed file put through the compress remote
+	contents := strings.Repeat("compress me ", 100)
+	src := object.NewStaticObjectInfo("packed.txt", time.Now(), -1, true, nil, nil)
+	o, err := f.Put(bytes.NewBufferString(contents), src)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), o.Size())
+	_, err = os.Stat(filepath.Join(dir, compressedName("packed.txt", int64(len(contents)))))
+	require.NoError(t, err)
+
+	entries, err := f.List("")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	sizes := map[string]int64{}
+	for _, entry := range entries {
+		sizes[entry.Remote()] = entry.Size()
+	}
+	assert.Equal(t, map[string]int64{"plain.txt": int64(len(plain)), "packed.txt": int64(len(contents))}, sizes)
+
+	// Hashes are of the uncompressed data
+	o, err = f.NewObject("packed.txt")
+	require.NoError(t, err)
+	md5, err := o.Hash(hash.MD5)
+	require.NoError(t, err)
+	want, err := hash.Stream(strings.NewReader(contents))
+	require.NoError(t, err)
+	assert.Equal(t, want[hash.MD5], md5)
+
+	// Ranged reads are supported
+	in, err := o.Open(&fs.RangeOption{Start: 12, End: 23})
+	require.NoError(t, err)
+	buf, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, "compress me ", string(buf))
+
+	// The uncompressed file reads unchanged
+	o, err = f.NewObject("plain.txt")
+	require.NoError(t, err)
+	in, err = o.Open()
+	require.NoError(t, err)
+	buf, err = ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, plain, buf)
+}
@@ -0,0 +1,169 @@
+// Copy files and folders by their drive ID
+
+package drive
+
+import (
+	"path"
+	"strings"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/fspath"
+	"github.com/ncw/rclone/fs/operations"
+	"github.com/pkg/errors"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// copyIDResult is what the copyid command returns
+type copyIDResult struct {
+	Files int64 // number of files copied
+	Bytes int64 // bytes in the files copied
+}
+
+// getFile reads the drive.File with id.
+//
+// It asks for team drive support whatever the remote is, so files
+// shared from a team drive can be read from any remote.
+func (f *Fs) getFile(id string) (info *drive.File, err error) {
+	err = f.getPacer().Call(func() (bool, error) {
+		info, err = f.svc.Files.Get(id).Fields(googleapi.Field(f.getFields())).SupportsTeamDrives(true).Do()
+		return shouldRetry(err)
+	})
+	return info, err
+}
+
+// copyID copies the file or folder with id to dest.
+//
+// A folder is copied recursively into the directory dest.  A file is
+// copied to the file dest, or into the directory dest if it ends with
+// "/".
+//
+// The path of the file isn't looked up, so this works with files
+// which are only in "Shared with me" or which have several parents.
+func (f *Fs) copyID(id, dest string) (r copyIDResult, err error) {
+	info, err := f.getFile(id)
+	if err != nil {
+		return r, errors.Wrapf(err, "copyid: failed to find %q", id)
+	}
+	if info.MimeType == shortcutMimeType {
+		info, err = f.resolveShortcut(info, "")
+		if err != nil {
+			return r, errors.Wrapf(err, "copyid: failed to read %q", id)
+		}
+	}
+	if info.MimeType == driveFolderType {
+		fdst, err := fs.NewFs(dest)
+		if err != nil {
+			return r, err
+		}
+		err = f.copyIDDir(&r, fdst, info.Id, "", map[string]bool{})
+		return r, err
+	}
+	dstRemote, dstFileName := dest, ""
+	if !strings.HasSuffix(dest, "/") {
+		dstRemote, dstFileName = fspath.Split(dest)
+		if dstRemote == "" {
+			dstRemote = "."
+		}
+	}
+	fdst, err := fs.NewFs(dstRemote)
+	if err != nil && err != fs.ErrorIsFile {
+		return r, err
+	}
+	entry, err := f.itemToDirEntry("", info)
+	if err != nil {
+		return r, err
+	}
+	o, ok := entry.(fs.Object)
+	if !ok {
+		return r, errors.Errorf("copyid: can't copy %q of type %q", info.Name, info.MimeType)
+	}
+	if dstFileName == "" {
+		// Use the name the file has on drive, with the
+		// extension of the export format for documents
+		dstFileName = o.Remote()
+	}
+	err = copyIDObject(&r, fdst, dstFileName, o)
+	return r, err
+}
+
+// copyIDDir copies the contents of the folder dirID recursively into
+// dir in fdst
+//
+// visited holds the IDs of the folders being copied above this one so
+// folder shortcuts which point back to them aren't followed forever.
+func (f *Fs) copyIDDir(r *copyIDResult, fdst fs.Fs, dirID, dir string, visited map[string]bool) error {
+	visited[dirID] = true
+	defer delete(visited, dirID)
+	var items []*drive.File
+	_, err := f.list(dirID, "", false, false, false, func(item *drive.File) bool {
+		items = append(items, item)
+		return false
+	})
+	if err != nil {
+		return errors.Wrapf(err, "copyid: failed to list %q", dir)
+	}
+	for _, item := range items {
+		remote := path.Join(dir, item.Name)
+		if item.MimeType == shortcutMimeType {
+			if *driveSkipShortcuts {
+				continue
+			}
+			target, err := f.resolveShortcut(item, dirID)
+			if err == errDanglingShortcut {
+				fs.Logf(remote, "Ignoring shortcut as its target has been deleted")
+				continue
+			} else if err != nil {
+				return err
+			}
+			if visited[target.Id] {
+				fs.Logf(remote, "Ignoring folder shortcut as it points to a folder above it")
+				continue
+			}
+			item = target
+		}
+		if item.MimeType == driveFolderType {
+			err = f.copyIDDir(r, fdst, item.Id, remote, visited)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// Folders are done above so this won't add the
+		// directory to the dirCache of f
+		entry, err := f.itemToDirEntry(dir, item)
+		if err != nil {
+			return err
+		}
+		if o, ok := entry.(fs.Object); ok {
+			err = copyIDObject(r, fdst, o.Remote(), o)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyIDObject copies o to remote in fdst if it needs copying
+func copyIDObject(r *copyIDResult, fdst fs.Fs, remote string, o fs.Object) error {
+	dst, err := fdst.NewObject(remote)
+	if err == fs.ErrorObjectNotFound {
+		dst = nil
+	} else if err != nil {
+		return err
+	}
+	if !operations.NeedTransfer(dst, o) {
+		return nil
+	}
+	accounting.Stats.Transferring(remote)
+	_, err = operations.Copy(fdst, dst, remote, o)
+	accounting.Stats.DoneTransferring(remote, err == nil)
+	if err != nil {
+		return err
+	}
+	r.Files++
+	r.Bytes += o.Size()
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,9 +26,11 @@ import (
 	"github.com/ncw/rclone/fs/config"
 	"github.com/ncw/rclone/fs/config/flags"
 	"github.com/ncw/rclone/fs/config/obscure"
+	"github.com/ncw/rclone/fs/filter"
 	"github.com/ncw/rclone/fs/fserrors"
 	"github.com/ncw/rclone/fs/fshttp"
 	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/lib/atexit"
 	"github.com/ncw/rclone/lib/dircache"
 	"github.com/ncw/rclone/lib/oauthutil"
 	"github.com/ncw/rclone/lib/pacer"
@@ -59,17 +62,46 @@ var (
 	driveSkipGdocs           = flags.BoolP("drive-skip-gdocs", "", false, "Skip google documents in all listings.")
 	driveSharedWithMe        = flags.BoolP("drive-shared-with-me", "", false, "Only show files that are shared with me")
 	driveTrashedOnly         = flags.BoolP("drive-trashed-only", "", false, "Only show files that are in the trash")
-	driveExtensions          = flags.StringP("drive-formats", "", defaultExtensions, "Comma separated list of preferred formats for downloading Google docs.")
+	driveExtensions          = flags.StringP("drive-formats", "", defaultExtensions, "Deprecated: see --drive-export-formats")
+	driveExportFormats       = flags.StringP("drive-export-formats", "", "", "Comma separated list of preferred formats for downloading Google docs, then ;type=formats for each type.")
 	driveUseCreatedDate      = flags.BoolP("drive-use-created-date", "", false, "Use created date instead of modified date.")
 	driveListChunk           = flags.Int64P("drive-list-chunk", "", 1000, "Size of listing chunk 100-1000. 0 to disable.")
 	driveImpersonate         = flags.StringP("drive-impersonate", "", "", "Impersonate this user when using a service account.")
 	driveAlternateExport     = flags.BoolP("drive-alternate-export", "", false, "Use alternate export URLs for google documents export.")
 	driveAcknowledgeAbuse    = flags.BoolP("drive-acknowledge-abuse", "", false, "Set to allow files which return cannotDownloadAbusiveFile to be downloaded.")
 	driveKeepRevisionForever = flags.BoolP("drive-keep-revision-forever", "", false, "Keep new head revision forever.")
-	// chunkSize is the size of the chunks created during a resumable upload and should be a power of two.
+	driveUploadNoProxy       = flags.BoolP("drive-upload-no-proxy", "", false, "Don't use the proxy from the environment for uploads.")
+	driveFields              = flags.StringP("drive-fields", "", "", "Comma separated list of file fields to request from drive. Leave blank for the default.")
+	driveQuery               = flags.StringP("drive-query", "", "", "Only list files matching this drive search query, eg \"mimeType='application/pdf'\".")
+	driveMaxTransfers        = flags.IntP("drive-max-concurrent-transfers", "", 0, "Max number of concurrent transfers to or from drive. 0 for no limit other than --transfers.")
+	driveExtraRetryReasons   = flags.StringP("drive-extra-retry-reasons", "", "", "Comma separated list of extra error reasons to retry, eg internalError,backendError.")
+	drivePollInterval        = flags.DurationP("drive-poll-interval", "", 0, "Interval to poll drive for changes when mounted. 0 to use --poll-interval.")
+	driveNoResume            = flags.BoolP("drive-no-resume", "", false, "Don't save upload sessions so interrupted uploads can be resumed.")
+	driveUploadMD5           = flags.BoolP("drive-upload-md5", "", false, "Check the MD5 drive stores for uploads against the MD5 of the source before accepting them.")
+	driveDuplicates          = flags.StringP("drive-duplicates", "", "keep", "Which of the files with the same name in a listing to use: keep|newest|oldest|largest|smallest.")
+	driveUploadChunkSize     = flags.StringP("drive-upload-chunk-size", "", "", "Comma separated list of glob=size rules to choose the upload chunk size per file, eg \"*.mkv=256M,*=8M\".")
+	driveDisableHTTP2        = flags.BoolP("drive-disable-http2", "", false, "Disable HTTP/2 for all requests to drive.")
+	driveSkipShortcuts       = flags.BoolP("drive-skip-shortcuts", "", false, "Don't follow shortcuts - leave them out of listings.")
+	driveShortcutTarget      = flags.BoolP("drive-shortcut-target", "", true, "Follow shortcuts to what they point to - set to false to list shortcuts as their own entries.")
+	drivePacerMinSleep       = flags.DurationP("drive-pacer-min-sleep", "", minSleep, "Minimum time to sleep between API calls.")
+	drivePacerBurst          = flags.IntP("drive-pacer-burst", "", 1, "Number of API calls to allow without sleeping.")
+	driveMimeFromContent     = flags.BoolP("drive-mime-from-content", "", false, "Find the MIME type of uploads from the start of their content instead of their extension.")
+	driveNoMimeSniff         = flags.BoolP("drive-no-mime-sniff", "", false, "Don't guess the MIME type of uploads, use --drive-default-mime-type if the source doesn't know it.")
+	driveDefaultMimeType     = flags.StringP("drive-default-mime-type", "", "application/octet-stream", "MIME type for uploads when it can't be found.")
+	driveChunkShrinkRetries  = flags.IntP("drive-chunk-shrink-retries", "", 0, "Halve the upload chunk size after a chunk fails more than this many times. 0 to disable.")
+	// pacerMu protects the pacer flags above and pacerGeneration
+	// which is incremented when they are changed with the set command.
+	pacerMu         sync.Mutex
+	pacerGeneration int
+	// chunkSize is the size of the chunks created during a resumable upload and should be a multiple of 256k.
 	// 1<<18 is the minimum size supported by the Google uploader, and there is no maximum.
+	// It is protected by chunkSizeMu as it can be changed with the set command.
 	chunkSize         = fs.SizeSuffix(8 * 1024 * 1024)
+	chunkSizeMu       sync.Mutex
 	driveUploadCutoff = chunkSize
+	// driveMinChunkSize is the smallest --drive-chunk-shrink-retries
+	// reduces the chunk size to
+	driveMinChunkSize = fs.SizeSuffix(256 * 1024)
 	// Description of how to auth for this app
 	driveConfig = &oauth2.Config{
 		Scopes:       []string{scopePrefix + "drive"},
@@ -104,6 +136,8 @@ var (
 	partialFields       = "id,name,size,md5Checksum,trashed,modifiedTime,createdTime,mimeType"
 	exportFormatsOnce   sync.Once           // make sure we fetch the export formats only once
 	_exportFormats      map[string][]string // allowed export mime-type conversions
+	// mandatoryFields are always requested as rclone can't work without them
+	mandatoryFields = []string{"id", "name", "size", "mimeType"}
 )
 
 // Register with Fs
@@ -166,13 +200,17 @@ func init() {
 		}, {
 			Name: "root_folder_id",
 			Help: "ID of the root folder - leave blank normally.  Fill in to access \"Computers\" folders. (see docs).",
+		}, {
+			Name: "team_drive_name",
+			Help: "Name of the team drive to use if team_drive isn't set - leave blank normally.\nThe ID is looked up when rclone starts.",
 		}, {
 			Name: "service_account_file",
 			Help: "Service Account Credentials JSON file path  - leave blank normally.\nNeeded only if you want use SA instead of interactive login.",
 		}},
 	})
 	flags.VarP(&driveUploadCutoff, "drive-upload-cutoff", "", "Cutoff for switching to chunked upload")
-	flags.VarP(&chunkSize, "drive-chunk-size", "", "Upload chunk size. Must a power of 2 >= 256k.")
+	flags.VarP(&chunkSize, "drive-chunk-size", "", "Upload chunk size. Must a multiple of 256k.")
+	flags.VarP(&driveMinChunkSize, "drive-min-chunk-size", "", "Smallest chunk size --drive-chunk-shrink-retries reduces to. Must a multiple of 256k.")
 
 	// Invert mimeTypeToExtension
 	extensionToMimeType = make(map[string]string, len(mimeTypeToExtension))
@@ -183,17 +221,29 @@ func init() {
 
 // Fs represents a remote drive server
 type Fs struct {
-	name         string             // name of this remote
-	root         string             // the path we are working on
-	features     *fs.Features       // optional features
-	svc          *drive.Service     // the connection to the drive server
-	client       *http.Client       // authorized client
-	rootFolderID string             // the id of the root folder
-	dirCache     *dircache.DirCache // Map of directory path to directory id
-	pacer        *pacer.Pacer       // To pace the API calls
-	extensions   []string           // preferred extensions to download docs
-	teamDriveID  string             // team drive ID, may be ""
-	isTeamDrive  bool               // true if this is a team drive
+	name          string              // name of this remote
+	root          string              // the path we are working on
+	features      *fs.Features        // optional features
+	svc           *drive.Service      // the connection to the drive server
+	client        *http.Client        // authorized client
+	uploadClient  *http.Client        // authorized client for resumable uploads
+	rootFolderID  string              // the id of the root folder
+	dirCache      *dircache.DirCache  // Map of directory path to directory id
+	pacer         *pacer.Pacer        // To pace the API calls - use getPacer
+	pacerGen      int                 // pacerGeneration the pacer was configured with - protected by pacerMu
+	extensions    []string            // preferred extensions to download docs
+	typeExts      map[string][]string // preferred extensions for each type of doc by mime type
+	substitutedMu *sync.Mutex         // protect substituted
+	substituted   map[string]bool     // doc types where the preferred extension wasn't available and this was logged
+	skippedMu     *sync.Mutex         // protect skipped
+	skipped       map[string]string   // doc type of each google doc skipped with --drive-skip-gdocs by ID
+	teamDriveID   string              // team drive ID, may be ""
+	isTeamDrive   bool                // true if this is a team drive
+	uploadedIDs   *idCache            // IDs of files uploaded by this Fs
+	shortcuts     *shortcutCache      // what has been read about shortcuts
+	fields        string              // file fields to request from drive
+	chunkRules    []chunkSizeRule     // rules to choose the upload chunk size
+	duplicates    dedupeFn            // chooses between duplicates, nil to keep all
 }
 
 // Object describes a drive object
@@ -205,8 +255,10 @@ type Object struct {
 	md5sum       string // md5sum of the object
 	bytes        int64  // size of the object
 	modifiedDate string // RFC3339 time it was last modified
+	modifiedByMe string // RFC3339 time it was last modified by the user, may be ""
 	isDocument   bool   // if set this is a Google doc
 	mimeType     string
+	shortcutID   string // Drive Id of the shortcut if this was found through one
 }
 
 // ------------------------------------------------------------
@@ -249,12 +301,87 @@ func shouldRetry(err error) (again bool, errOut error) {
 						again = true
 					}
 				}
+				if !again {
+					for _, item := range gerr.Errors {
+						if isExtraRetryReason(item.Reason) {
+							fs.Debugf(nil, "Retrying error with reason %q from --drive-extra-retry-reasons: %v", item.Reason, err)
+							again = true
+							break
+						}
+					}
+				}
 			}
 		}
 	}
 	return again, err
 }
 
+// isExtraRetryReason returns true if reason is one of the error
+// reasons in --drive-extra-retry-reasons
+func isExtraRetryReason(reason string) bool {
+	if reason == "" {
+		return false
+	}
+	for _, extra := range strings.Split(*driveExtraRetryReasons, ",") {
+		if strings.TrimSpace(extra) == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFields parses the --drive-fields option into the fields to
+// request from drive, making sure the mandatory fields are always
+// included.  It warns if a field needed for a feature in use is
+// missing.
+func parseFields(fields string) string {
+	if strings.TrimSpace(fields) == "" {
+		if fs.Config.ModTimeSource != fs.ModTimeSourceMetadata {
+			return partialFields + ",modifiedByMeTime"
+		}
+		return partialFields
+	}
+	var out []string
+	seen := map[string]bool{}
+	add := func(field string) {
+		if field != "" && !seen[field] {
+			seen[field] = true
+			out = append(out, field)
+		}
+	}
+	for _, field := range mandatoryFields {
+		add(field)
+	}
+	for _, field := range strings.Split(fields, ",") {
+		add(strings.TrimSpace(field))
+	}
+	if fs.Config.ModTimeSource != fs.ModTimeSourceMetadata {
+		add("modifiedByMeTime")
+	}
+	if !seen["md5Checksum"] {
+		fs.Logf(nil, "drive: --drive-fields doesn't include md5Checksum so MD5 hashes won't be read or checked")
+	}
+	if *driveUseCreatedDate {
+		if !seen["createdTime"] {
+			fs.Logf(nil, "drive: --drive-fields doesn't include createdTime which is needed for --drive-use-created-date so modification times won't be read")
+		}
+	} else if !seen["modifiedTime"] {
+		fs.Logf(nil, "drive: --drive-fields doesn't include modifiedTime so modification times won't be read - consider using --size-only")
+	}
+	if !seen["trashed"] {
+		fs.Logf(nil, "drive: --drive-fields doesn't include trashed so trashed files may be treated as present when checking directories are empty")
+	}
+	return strings.Join(out, ",")
+}
+
+// getFields returns the file fields to request from drive
+func (f *Fs) getFields() string {
+	if f.fields == "" {
+		return partialFields
+	}
+	return f.fields
+}
+
 // parseParse parses a drive 'url'
 func parseDrivePath(path string) (root string, err error) {
 	root = strings.Trim(path, "/")
@@ -300,6 +427,18 @@ func (f *Fs) list(dirID string, title string, directoriesOnly bool, filesOnly bo
 	if directoriesOnly {
 		query = append(query, fmt.Sprintf("mimeType='%s'", driveFolderType))
 	}
+	if !includeAll && title == "" && !directoriesOnly && !filter.Active.Opt.DeleteExcluded {
+		// Let drive do --min-age and --max-age so we don't
+		// have to list all the files which don't match
+		if q := modTimeQuery(filter.Active.ModTimeFrom, filter.Active.ModTimeTo); q != "" {
+			query = append(query, q)
+		}
+	}
+	if !includeAll && title == "" && !directoriesOnly && *driveQuery != "" {
+		// Folders are always listed so the files in them can be
+		// found too
+		query = append(query, fmt.Sprintf("(mimeType='%s' or (%s))", driveFolderType, *driveQuery))
+	}
 	if filesOnly {
 		query = append(query, fmt.Sprintf("mimeType!='%s'", driveFolderType))
 	}
@@ -322,7 +461,7 @@ func (f *Fs) list(dirID string, title string, directoriesOnly bool, filesOnly bo
 		list.Spaces("appDataFolder")
 	}
 
-	var fields = partialFields
+	var fields = f.getFields()
 
 	if *driveAuthOwnerOnly {
 		fields += ",owners"
@@ -333,7 +472,7 @@ func (f *Fs) list(dirID string, title string, directoriesOnly bool, filesOnly bo
 OUTER:
 	for {
 		var files *drive.FileList
-		err = f.pacer.Call(func() (bool, error) {
+		err = f.getPacer().Call(func() (bool, error) {
 			files, err = list.Fields(googleapi.Field(fields)).Do()
 			return shouldRetry(err)
 		})
@@ -361,39 +500,330 @@ OUTER:
 	return
 }
 
-// Returns true of x is a power of 2 or zero
-func isPowerOfTwo(x int64) bool {
-	switch {
-	case x == 0:
-		return true
-	case x < 0:
-		return false
-	default:
-		return (x & (x - 1)) == 0
+// checkChunkSize checks cs is a valid chunk size for the resumable
+// uploader which needs multiples of 256k
+func checkChunkSize(cs fs.SizeSuffix) error {
+	const minChunkSize = 256 * 1024
+	if cs < minChunkSize {
+		return errors.Errorf("drive: chunk size can't be less than 256k - was %v", cs)
+	}
+	if cs%minChunkSize != 0 {
+		return errors.Errorf("drive: chunk size %v isn't a multiple of 256k", cs)
+	}
+	return nil
+}
+
+// getChunkSize returns the current chunk size
+func getChunkSize() fs.SizeSuffix {
+	chunkSizeMu.Lock()
+	defer chunkSizeMu.Unlock()
+	return chunkSize
+}
+
+// setChunkSize checks cs and sets the chunk size, returning the old
+// chunk size
+func setChunkSize(cs fs.SizeSuffix) (old fs.SizeSuffix, err error) {
+	err = checkChunkSize(cs)
+	if err != nil {
+		return chunkSize, err
+	}
+	chunkSizeMu.Lock()
+	defer chunkSizeMu.Unlock()
+	old, chunkSize = chunkSize, cs
+	return old, nil
+}
+
+// chunkSizeRule sets the upload chunk size for files matching glob
+type chunkSizeRule struct {
+	glob string
+	size fs.SizeSuffix
+}
+
+// parseChunkSizeRules parses a comma separated list of glob=size
+// rules as used by --drive-upload-chunk-size
+func parseChunkSizeRules(in string) (rules []chunkSizeRule, err error) {
+	for _, item := range strings.Split(in, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		equals := strings.LastIndex(item, "=")
+		if equals < 0 {
+			return nil, errors.Errorf("drive: bad upload chunk size rule %q - must be glob=size", item)
+		}
+		rule := chunkSizeRule{glob: item[:equals]}
+		if _, err = path.Match(rule.glob, ""); err != nil {
+			return nil, errors.Wrapf(err, "drive: bad glob in upload chunk size rule %q", item)
+		}
+		err = rule.size.Set(item[equals+1:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "drive: bad size in upload chunk size rule %q", item)
+		}
+		err = checkChunkSize(rule.size)
+		if err != nil {
+			return nil, errors.Wrapf(err, "upload chunk size rule %q", item)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// uploadChunkSize returns the chunk size to upload remote with.
+//
+// This is the size from the first rule whose glob matches remote, or
+// --drive-chunk-size if none do.  Globs without a "/" are matched
+// against the file name only.
+func (f *Fs) uploadChunkSize(remote string) fs.SizeSuffix {
+	leaf := path.Base(remote)
+	for _, rule := range f.chunkRules {
+		name := leaf
+		if strings.Contains(rule.glob, "/") {
+			name = remote
+		}
+		if ok, _ := path.Match(rule.glob, name); ok {
+			return rule.size
+		}
+	}
+	return getChunkSize()
+}
+
+// dedupeFn returns whether a should be used in preference to b
+type dedupeFn func(a, b *Object) bool
+
+// dedupePolicies are the values --drive-duplicates can take
+var dedupePolicies = map[string]dedupeFn{
+	"keep":     nil,
+	"newest":   func(a, b *Object) bool { return a.ModTime().After(b.ModTime()) },
+	"oldest":   func(a, b *Object) bool { return a.ModTime().Before(b.ModTime()) },
+	"largest":  func(a, b *Object) bool { return a.bytes > b.bytes },
+	"smallest": func(a, b *Object) bool { return a.bytes < b.bytes },
+}
+
+// parseDuplicates parses the --drive-duplicates policy
+func parseDuplicates(policy string) (dedupeFn, error) {
+	better, ok := dedupePolicies[strings.ToLower(policy)]
+	if !ok {
+		return nil, errors.Errorf("drive: unknown --drive-duplicates %q - must be keep, newest, oldest, largest or smallest", policy)
+	}
+	return better, nil
+}
+
+// dedupeListing removes duplicates from the directory listing entries.
+//
+// Drive can return the same ID more than once, eg if it has more than
+// one parent, and these repeats are always removed.  It can also have
+// files with the same name but different IDs in a directory.  These
+// are logged and, unless --drive-duplicates is "keep", all but one
+// chosen by the policy are removed.  Ties go to the smallest ID so the
+// choice is the same every time.
+func (f *Fs) dedupeListing(entries fs.DirEntries) fs.DirEntries {
+	seenIDs := make(map[string]struct{}, len(entries))
+	byName := make(map[string][]*Object)
+	out := entries[:0]
+	for _, entry := range entries {
+		var id string
+		switch x := entry.(type) {
+		case *Object:
+			id = x.id
+		case fs.Directory:
+			id = x.ID()
+		}
+		if id != "" {
+			if _, found := seenIDs[id]; found {
+				fs.Logf(entry, "Duplicate ID in listing: ignoring repeat: id=%s", id)
+				continue
+			}
+			seenIDs[id] = struct{}{}
+		}
+		if o, ok := entry.(*Object); ok {
+			byName[o.remote] = append(byName[o.remote], o)
+		}
+		out = append(out, entry)
+	}
+	drop := make(map[*Object]struct{})
+	for remote, objs := range byName {
+		if len(objs) < 2 {
+			continue
+		}
+		ids := make([]string, len(objs))
+		keep := 0
+		for i, o := range objs {
+			ids[i] = o.id
+			if f.duplicates != nil && (f.duplicates(o, objs[keep]) || (!f.duplicates(objs[keep], o) && o.id < objs[keep].id)) {
+				keep = i
+			}
+		}
+		if f.duplicates == nil {
+			fs.Logf(remote, "Duplicate object in listing: copies=%d ids=%s policy=%s", len(objs), strings.Join(ids, ","), *driveDuplicates)
+			continue
+		}
+		fs.Logf(remote, "Duplicate object in listing: copies=%d ids=%s policy=%s using=%s", len(objs), strings.Join(ids, ","), *driveDuplicates, objs[keep].id)
+		for i, o := range objs {
+			if i != keep {
+				drop[o] = struct{}{}
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return out
+	}
+	kept := out[:0]
+	for _, entry := range out {
+		if o, ok := entry.(*Object); ok {
+			if _, found := drop[o]; found {
+				continue
+			}
+		}
+		kept = append(kept, entry)
 	}
+	return kept
 }
 
 // parseExtensions parses drive export extensions from a string
-func (f *Fs) parseExtensions(extensions string) error {
+func (f *Fs) parseExtensions(extensions string) (err error) {
+	f.extensions, err = appendExtensions(f.extensions, extensions)
+	return err
+}
+
+// appendExtensions parses the comma separated extensions appending
+// the ones which aren't already in existing
+func appendExtensions(existing []string, extensions string) ([]string, error) {
 	for _, extension := range strings.Split(extensions, ",") {
 		extension = strings.ToLower(strings.TrimSpace(extension))
 		if _, found := extensionToMimeType[extension]; !found {
-			return errors.Errorf("couldn't find mime type for extension %q", extension)
+			return existing, errors.Errorf("couldn't find mime type for extension %q", extension)
 		}
 		found := false
-		for _, existingExtension := range f.extensions {
+		for _, existingExtension := range existing {
 			if extension == existingExtension {
 				found = true
 				break
 			}
 		}
 		if !found {
-			f.extensions = append(f.extensions, extension)
+			existing = append(existing, extension)
+		}
+	}
+	return existing, nil
+}
+
+// googleDocPrefix is the start of the mime type of all google docs
+const googleDocPrefix = "application/vnd.google-apps."
+
+// googleDocTypes are the types of google doc which can be given
+// their own export formats
+var googleDocTypes = map[string]bool{
+	"document":     true,
+	"drawing":      true,
+	"form":         true,
+	"jam":          true,
+	"presentation": true,
+	"script":       true,
+	"site":         true,
+	"spreadsheet":  true,
+}
+
+// parseExportFormats parses --drive-export-formats.  This is a comma
+// separated list of extensions for all google docs, with optionally
+// extensions for one type of doc as ;type=extensions, eg
+//
+//	docx,xlsx,pptx,svg;spreadsheet=ods,xlsx
+func (f *Fs) parseExportFormats(formats string) error {
+	for _, group := range strings.Split(formats, ";") {
+		equals := strings.IndexRune(group, '=')
+		if equals < 0 {
+			if strings.TrimSpace(group) == "" {
+				continue
+			}
+			err := f.parseExtensions(group)
+			if err != nil {
+				return err
+			}
+			continue
 		}
+		docType := strings.ToLower(strings.TrimSpace(group[:equals]))
+		if !googleDocTypes[docType] {
+			return errors.Errorf("unknown type of google doc %q in export formats", docType)
+		}
+		mimeType := googleDocPrefix + docType
+		if f.typeExts == nil {
+			f.typeExts = make(map[string][]string)
+		}
+		extensions, err := appendExtensions(f.typeExts[mimeType], group[equals+1:])
+		if err != nil {
+			return err
+		}
+		f.typeExts[mimeType] = extensions
 	}
 	return nil
 }
 
+// teamDrive is a team drive as returned by the drives command
+type teamDrive struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// listTeamDrives lists all the team drives the user can access
+func listTeamDrives(svc *drive.Service, pacer *pacer.Pacer) (drives []teamDrive, err error) {
+	listTeamDrives := svc.Teamdrives.List().PageSize(100)
+	for {
+		var teamDrives *drive.TeamDriveList
+		err = pacer.Call(func() (bool, error) {
+			teamDrives, err = listTeamDrives.Do()
+			return shouldRetry(err)
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "list team drives failed")
+		}
+		for _, drive := range teamDrives.TeamDrives {
+			drives = append(drives, teamDrive{ID: drive.Id, Name: drive.Name})
+		}
+		if teamDrives.NextPageToken == "" {
+			break
+		}
+		listTeamDrives.PageToken(teamDrives.NextPageToken)
+	}
+	return drives, nil
+}
+
+// teamDriveIDs caches the IDs of the team drives found by name for
+// the session, keyed by the remote name and the team drive name
+var (
+	teamDriveIDsMu sync.Mutex
+	teamDriveIDs   = map[string]string{}
+)
+
+// findTeamDrive returns the ID of the team drive called driveName
+func (f *Fs) findTeamDrive(driveName string) (string, error) {
+	key := f.name + ":" + driveName
+	teamDriveIDsMu.Lock()
+	defer teamDriveIDsMu.Unlock()
+	if id, ok := teamDriveIDs[key]; ok {
+		return id, nil
+	}
+	drives, err := listTeamDrives(f.svc, f.getPacer())
+	if err != nil {
+		return "", err
+	}
+	id := ""
+	for _, drive := range drives {
+		if drive.Name != driveName {
+			continue
+		}
+		if id != "" {
+			return "", errors.Errorf("more than one team drive called %q - use team_drive with the ID instead", driveName)
+		}
+		id = drive.ID
+	}
+	if id == "" {
+		return "", errors.Errorf("couldn't find team drive called %q", driveName)
+	}
+	fs.Debugf(f, "Found team drive %q with ID %q", driveName, id)
+	teamDriveIDs[key] = id
+	return id, nil
+}
+
 // Figure out if the user wants to use a team drive
 func configTeamDrive(name string) error {
 	teamDrive := config.FileGet(name, "team_drive")
@@ -405,7 +835,7 @@ func configTeamDrive(name string) error {
 	if !config.ConfirmWithDefault(false) {
 		return nil
 	}
-	client, err := createOAuthClient(name)
+	client, err := createOAuthClient(name, fshttp.NewClient(fs.Config))
 	if err != nil {
 		return errors.Wrap(err, "config team drive failed to create oauth client")
 	}
@@ -414,25 +844,14 @@ func configTeamDrive(name string) error {
 		return errors.Wrap(err, "config team drive failed to make drive client")
 	}
 	fmt.Printf("Fetching team drive list...\n")
+	teamDrives, err := listTeamDrives(svc, newPacer())
+	if err != nil {
+		return err
+	}
 	var driveIDs, driveNames []string
-	listTeamDrives := svc.Teamdrives.List().PageSize(100)
-	for {
-		var teamDrives *drive.TeamDriveList
-		err = newPacer().Call(func() (bool, error) {
-			teamDrives, err = listTeamDrives.Do()
-			return shouldRetry(err)
-		})
-		if err != nil {
-			return errors.Wrap(err, "list team drives failed")
-		}
-		for _, drive := range teamDrives.TeamDrives {
-			driveIDs = append(driveIDs, drive.Id)
-			driveNames = append(driveNames, drive.Name)
-		}
-		if teamDrives.NextPageToken == "" {
-			break
-		}
-		listTeamDrives.PageToken(teamDrives.NextPageToken)
+	for _, drive := range teamDrives {
+		driveIDs = append(driveIDs, drive.ID)
+		driveNames = append(driveNames, drive.Name)
 	}
 	var driveID string
 	if len(driveIDs) == 0 {
@@ -446,10 +865,66 @@ func configTeamDrive(name string) error {
 
 // newPacer makes a pacer configured for drive
 func newPacer() *pacer.Pacer {
-	return pacer.New().SetMinSleep(minSleep).SetPacer(pacer.GoogleDrivePacer)
+	pacerMu.Lock()
+	defer pacerMu.Unlock()
+	return pacer.New().SetMinSleep(*drivePacerMinSleep).SetBurst(*drivePacerBurst).SetPacer(pacer.GoogleDrivePacer)
+}
+
+// checkPacerMinSleep checks the pacer minimum sleep is in range
+func checkPacerMinSleep(t time.Duration) error {
+	if t < 0 || t > time.Minute {
+		return errors.Errorf("drive: pacer min sleep must be between 0 and 1m - was %v", t)
+	}
+	return nil
+}
+
+// checkPacerBurst checks the pacer burst is in range
+func checkPacerBurst(n int) error {
+	if n < 1 || n > 1000 {
+		return errors.Errorf("drive: pacer burst must be between 1 and 1000 - was %d", n)
+	}
+	return nil
 }
 
-func getServiceAccountClient(credentialsData []byte) (*http.Client, error) {
+// setPacerMinSleep checks t and sets the pacer minimum sleep for all
+// drive remotes, returning the old value
+func setPacerMinSleep(t time.Duration) (old time.Duration, err error) {
+	pacerMu.Lock()
+	defer pacerMu.Unlock()
+	if err = checkPacerMinSleep(t); err != nil {
+		return *drivePacerMinSleep, err
+	}
+	old, *drivePacerMinSleep = *drivePacerMinSleep, t
+	pacerGeneration++
+	return old, nil
+}
+
+// setPacerBurst checks n and sets the pacer burst for all drive
+// remotes, returning the old value
+func setPacerBurst(n int) (old int, err error) {
+	pacerMu.Lock()
+	defer pacerMu.Unlock()
+	if err = checkPacerBurst(n); err != nil {
+		return *drivePacerBurst, err
+	}
+	old, *drivePacerBurst = *drivePacerBurst, n
+	pacerGeneration++
+	return old, nil
+}
+
+// getPacer returns the pacer for f having applied any changes made
+// to the pacer settings by the set command since it was last called
+func (f *Fs) getPacer() *pacer.Pacer {
+	pacerMu.Lock()
+	defer pacerMu.Unlock()
+	if f.pacerGen != pacerGeneration {
+		f.pacer.SetMinSleep(*drivePacerMinSleep).SetBurst(*drivePacerBurst)
+		f.pacerGen = pacerGeneration
+	}
+	return f.pacer
+}
+
+func getServiceAccountClient(credentialsData []byte, baseClient *http.Client) (*http.Client, error) {
 	conf, err := google.JWTConfigFromJSON(credentialsData, driveConfig.Scopes...)
 	if err != nil {
 		return nil, errors.Wrap(err, "error processing credentials")
@@ -457,11 +932,13 @@ func getServiceAccountClient(credentialsData []byte) (*http.Client, error) {
 	if *driveImpersonate != "" {
 		conf.Subject = *driveImpersonate
 	}
-	ctxWithSpecialClient := oauthutil.Context(fshttp.NewClient(fs.Config))
+	ctxWithSpecialClient := oauthutil.Context(baseClient)
 	return oauth2.NewClient(ctxWithSpecialClient, conf.TokenSource(ctxWithSpecialClient)), nil
 }
 
-func createOAuthClient(name string) (*http.Client, error) {
+// createOAuthClient makes an authorized client for the remote name
+// which makes its requests with baseClient
+func createOAuthClient(name string, baseClient *http.Client) (*http.Client, error) {
 	var oAuthClient *http.Client
 	var err error
 
@@ -476,12 +953,12 @@ func createOAuthClient(name string) (*http.Client, error) {
 		serviceAccountCreds = loadedCreds
 	}
 	if len(serviceAccountCreds) > 0 {
-		oAuthClient, err = getServiceAccountClient(serviceAccountCreds)
+		oAuthClient, err = getServiceAccountClient(serviceAccountCreds, baseClient)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create oauth client from service account")
 		}
 	} else {
-		oAuthClient, _, err = oauthutil.NewClient(name, driveConfig)
+		oAuthClient, _, err = oauthutil.NewClientWithBaseClient(name, driveConfig, baseClient)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create oauth client")
 		}
@@ -490,32 +967,105 @@ func createOAuthClient(name string) (*http.Client, error) {
 	return oAuthClient, nil
 }
 
+// modTimeQuery returns a query term which only lets through files
+// modified between from and to (inclusive) or "" if neither is set.
+//
+// Directories always pass so they can still be recursed into.
+func modTimeQuery(from, to time.Time) string {
+	field := "modifiedTime"
+	if *driveUseCreatedDate {
+		field = "createdTime"
+	}
+	var terms []string
+	if !from.IsZero() {
+		terms = append(terms, fmt.Sprintf("%s >= '%s'", field, from.UTC().Format(time.RFC3339Nano)))
+	}
+	if !to.IsZero() {
+		terms = append(terms, fmt.Sprintf("%s <= '%s'", field, to.UTC().Format(time.RFC3339Nano)))
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(mimeType='%s' or (%s))", driveFolderType, strings.Join(terms, " and "))
+}
+
 // NewFs contstructs an Fs from the path, container:path
 func NewFs(name, path string) (fs.Fs, error) {
-	if !isPowerOfTwo(int64(chunkSize)) {
-		return nil, errors.Errorf("drive: chunk size %v isn't a power of two", chunkSize)
+	err := checkChunkSize(getChunkSize())
+	if err != nil {
+		return nil, err
 	}
-	if chunkSize < 256*1024 {
-		return nil, errors.Errorf("drive: chunk size can't be less than 256k - was %v", chunkSize)
+	if *driveChunkShrinkRetries > 0 {
+		err = checkChunkSize(driveMinChunkSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad --drive-min-chunk-size")
+		}
 	}
 
-	oAuthClient, err := createOAuthClient(name)
+	baseClient := fshttp.NewClient(fs.Config)
+	if *driveDisableHTTP2 {
+		baseClient = fshttp.NewClientCustom(fs.Config, fshttp.DisableHTTP2)
+	}
+	oAuthClient, err := createOAuthClient(name, baseClient)
 	if err != nil {
 		return nil, errors.Wrap(err, "drive: failed when making oauth client")
 	}
 
+	// Both the initial POST and the Content-Range PUTs of
+	// resumable uploads use the upload client so they go through
+	// the same proxy (or none).
+	uploadClient := oAuthClient
+	if *driveUploadNoProxy {
+		noProxyClient := fshttp.NewClientCustom(fs.Config, func(t *http.Transport) {
+			t.Proxy = nil
+			if *driveDisableHTTP2 {
+				fshttp.DisableHTTP2(t)
+			}
+		})
+		uploadClient, err = createOAuthClient(name, noProxyClient)
+		if err != nil {
+			return nil, errors.Wrap(err, "drive: failed when making upload oauth client")
+		}
+	}
+
 	root, err := parseDrivePath(path)
 	if err != nil {
 		return nil, err
 	}
 
+	chunkRules, err := parseChunkSizeRules(*driveUploadChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkPacerMinSleep(*drivePacerMinSleep); err != nil {
+		return nil, err
+	}
+	if err = checkPacerBurst(*drivePacerBurst); err != nil {
+		return nil, err
+	}
+	if *driveMimeFromContent && *driveNoMimeSniff {
+		return nil, errors.New("drive: can't use --drive-mime-from-content with --drive-no-mime-sniff")
+	}
+	duplicates, err := parseDuplicates(*driveDuplicates)
+	if err != nil {
+		return nil, err
+	}
+
 	f := &Fs{
-		name:  name,
-		root:  root,
-		pacer: newPacer(),
+		name:          name,
+		root:          root,
+		pacer:         newPacer(),
+		pacerGen:      pacerGeneration,
+		uploadedIDs:   newIDCache(),
+		shortcuts:     newShortcutCache(),
+		chunkRules:    chunkRules,
+		duplicates:    duplicates,
+		substitutedMu: new(sync.Mutex),
+		skippedMu:     new(sync.Mutex),
 	}
 	f.teamDriveID = config.FileGet(name, "team_drive")
 	f.isTeamDrive = f.teamDriveID != ""
+	f.fields = parseFields(*driveFields)
 	f.features = (&fs.Features{
 		DuplicateFiles:          true,
 		ReadMimeType:            true,
@@ -525,11 +1075,21 @@ func NewFs(name, path string) (fs.Fs, error) {
 
 	// Create a new authorized Drive client.
 	f.client = oAuthClient
+	f.uploadClient = uploadClient
 	f.svc, err = drive.New(f.client)
 	if err != nil {
 		return nil, errors.Wrap(err, "couldn't create Drive client")
 	}
 
+	// look up the team drive by name if it doesn't have an ID
+	if driveName := config.FileGet(name, "team_drive_name"); driveName != "" && !f.isTeamDrive {
+		f.teamDriveID, err = f.findTeamDrive(driveName)
+		if err != nil {
+			return nil, err
+		}
+		f.isTeamDrive = true
+	}
+
 	// set root folder for a team drive or query the user root folder
 	if f.isTeamDrive {
 		f.rootFolderID = f.teamDriveID
@@ -545,7 +1105,11 @@ func NewFs(name, path string) (fs.Fs, error) {
 	f.dirCache = dircache.New(root, f.rootFolderID, f)
 
 	// Parse extensions
-	err = f.parseExtensions(*driveExtensions)
+	exportFormats := *driveExportFormats
+	if exportFormats == "" {
+		exportFormats = *driveExtensions
+	}
+	err = f.parseExportFormats(exportFormats)
 	if err != nil {
 		return nil, err
 	}
@@ -639,8 +1203,8 @@ func (f *Fs) CreateDir(pathID, leaf string) (newID string, err error) {
 		Parents:     []string{pathID},
 	}
 	var info *drive.File
-	err = f.pacer.Call(func() (bool, error) {
-		info, err = f.svc.Files.Create(createInfo).Fields(googleapi.Field(partialFields)).SupportsTeamDrives(f.isTeamDrive).Do()
+	err = f.getPacer().Call(func() (bool, error) {
+		info, err = f.svc.Files.Create(createInfo).Fields(googleapi.Field(f.getFields())).SupportsTeamDrives(f.isTeamDrive).Do()
 		return shouldRetry(err)
 	})
 	if err != nil {
@@ -667,7 +1231,7 @@ func (f *Fs) exportFormats() map[string][]string {
 	exportFormatsOnce.Do(func() {
 		var about *drive.About
 		var err error
-		err = f.pacer.Call(func() (bool, error) {
+		err = f.getPacer().Call(func() (bool, error) {
 			about, err = f.svc.About.Get().Fields("exportFormats").Do()
 			return shouldRetry(err)
 		})
@@ -682,12 +1246,27 @@ func (f *Fs) exportFormats() map[string][]string {
 }
 
 // findExportFormat works out the optimum extension and mime-type
-// for this item.
+// for this item which is a google doc of docMimeType.
 //
-// Look through the extensions and find the first format that can be
-// converted.  If none found then return "", ""
-func (f *Fs) findExportFormat(filepath string, exportMimeTypes []string) (extension, mimeType string) {
-	for _, extension := range f.extensions {
+// Look through the extensions for the type of doc then the extensions
+// for all docs and find the first format that can be converted.  If
+// none found then return "", ""
+func (f *Fs) findExportFormat(filepath string, docMimeType string, exportMimeTypes []string) (extension, mimeType string) {
+	preferred := f.typeExts[docMimeType]
+	extension, mimeType = matchExportFormat(preferred, exportMimeTypes)
+	if extension == "" {
+		extension, mimeType = matchExportFormat(f.extensions, exportMimeTypes)
+	}
+	if len(preferred) > 0 && extension != preferred[0] {
+		f.logSubstitution(filepath, docMimeType, preferred[0], extension)
+	}
+	return extension, mimeType
+}
+
+// matchExportFormat returns the first of extensions which is one of
+// exportMimeTypes.  If none found then return "", ""
+func matchExportFormat(extensions []string, exportMimeTypes []string) (extension, mimeType string) {
+	for _, extension := range extensions {
 		mimeType := extensionToMimeType[extension]
 		for _, emt := range exportMimeTypes {
 			if emt == mimeType {
@@ -695,97 +1274,250 @@ func (f *Fs) findExportFormat(filepath string, exportMimeTypes []string) (extens
 			}
 		}
 	}
-
-	// else return empty
 	return "", ""
 }
 
-// List the objects and directories in dir into entries.  The
-// entries can be returned in any order but should be for a
-// complete directory.
-//
-// dir should be "" to list the root, and should not have
-// trailing slashes.
-//
-// This should return ErrDirNotFound if the directory isn't
-// found.
-func (f *Fs) List(dir string) (entries fs.DirEntries, err error) {
+// skipDocument records that the google doc item found at remote was
+// skipped because of --drive-skip-gdocs.  A summary of these is logged
+// when rclone exits.
+func (f *Fs) skipDocument(remote string, item *drive.File) {
+	fs.Debugf(remote, "Skipping google document type %q", item.MimeType)
+	f.skippedMu.Lock()
+	defer f.skippedMu.Unlock()
+	if f.skipped == nil {
+		f.skipped = make(map[string]string)
+		atexit.Register(f.logSkippedDocuments)
+	}
+	f.skipped[item.Id] = strings.TrimPrefix(item.MimeType, googleDocPrefix)
+}
+
+// skippedDocuments returns a summary of the google docs skipped with
+// --drive-skip-gdocs, eg "3 google docs (2 document, 1 spreadsheet)",
+// or "" if there weren't any.  Docs found more than once are only
+// counted once.
+func (f *Fs) skippedDocuments() string {
+	f.skippedMu.Lock()
+	defer f.skippedMu.Unlock()
+	if len(f.skipped) == 0 {
+		return ""
+	}
+	counts := make(map[string]int)
+	for _, docType := range f.skipped {
+		counts[docType]++
+	}
+	var docTypes []string
+	for docType := range counts {
+		docTypes = append(docTypes, docType)
+	}
+	sort.Strings(docTypes)
+	var out []string
+	for _, docType := range docTypes {
+		out = append(out, fmt.Sprintf("%d %s", counts[docType], docType))
+	}
+	return fmt.Sprintf("%d google docs (%s)", len(f.skipped), strings.Join(out, ", "))
+}
+
+// logSkippedDocuments logs the summary of the google docs skipped
+func (f *Fs) logSkippedDocuments() {
+	if summary := f.skippedDocuments(); summary != "" {
+		fs.Logf(f, "Skipped %s as --drive-skip-gdocs is set", summary)
+	}
+}
+
+// logSubstitution logs that docs of docMimeType can't be exported as
+// wanted so are exported as got instead.  This is only logged once
+// for each type.
+func (f *Fs) logSubstitution(filepath, docMimeType, wanted, got string) {
+	f.substitutedMu.Lock()
+	defer f.substitutedMu.Unlock()
+	if f.substituted[docMimeType] {
+		return
+	}
+	if f.substituted == nil {
+		f.substituted = make(map[string]bool)
+	}
+	f.substituted[docMimeType] = true
+	docType := strings.TrimPrefix(docMimeType, googleDocPrefix)
+	if got == "" {
+		fs.Logf(filepath, "Google %s can't be exported as %q or any of the other export formats", docType, wanted)
+		return
+	}
+	fs.Infof(filepath, "Google %s can't be exported as %q so exporting as %q instead", docType, wanted, got)
+}
+
+// itemToDirEntry converts a drive.File found in dir into an
+// fs.DirEntry.  It returns nil with no error for items which should
+// be ignored.
+func (f *Fs) itemToDirEntry(dir string, item *drive.File) (fs.DirEntry, error) {
+	remote := path.Join(dir, item.Name)
+	if item.MimeType == shortcutMimeType {
+		if *driveSkipShortcuts {
+			fs.Debugf(remote, "Skipping shortcut")
+			return nil, nil
+		}
+		if !*driveShortcutTarget {
+			return f.newShortcutObject(remote, item), nil
+		}
+		shortcutID := item.Id
+		parentID, _ := f.dirCache.Get(dir)
+		target, err := f.resolveShortcut(item, parentID)
+		if err == errDanglingShortcut {
+			fs.Logf(remote, "Ignoring shortcut as its target has been deleted: id=%s", shortcutID)
+			return nil, nil
+		} else if err != nil {
+			fs.Errorf(remote, "Ignoring shortcut: %v", err)
+			return nil, nil
+		}
+		if target.MimeType == driveFolderType {
+			if parentID == "" {
+				fs.Debugf(remote, "Ignoring folder shortcut as its directory isn't known")
+				return nil, nil
+			}
+			if f.shortcutLoops(dir, target.Id) {
+				fs.Logf(remote, "Ignoring folder shortcut as it points to a directory above it")
+				return nil, nil
+			}
+			// Remember this is a shortcut so removing or
+			// moving the directory acts on the shortcut
+			// rather than the folder it points to
+			f.shortcuts.putDir(parentID, item.Name, shortcutID)
+		}
+		entry, err := f.itemToDirEntry(dir, target)
+		if o, ok := entry.(*Object); ok {
+			o.shortcutID = shortcutID
+		}
+		return entry, err
+	}
+	switch {
+	case item.MimeType == driveFolderType:
+		// cache the directory ID for later lookups
+		f.dirCache.Put(remote, item.Id)
+		when, _ := time.Parse(timeFormatIn, item.ModifiedTime)
+		return fs.NewDir(remote, when).SetID(item.Id), nil
+	case *driveAuthOwnerOnly && !isAuthOwned(item):
+		// ignore object
+	case item.Md5Checksum != "" || item.Size > 0:
+		// If item has MD5 sum or a length it is a file stored on drive
+		return f.newObjectWithInfo(remote, item)
+	case *driveSkipGdocs && strings.HasPrefix(item.MimeType, googleDocPrefix):
+		f.skipDocument(remote, item)
+	default:
+		exportMimeTypes, isDocument := f.exportFormats()[item.MimeType]
+		if !isDocument {
+			fs.Debugf(remote, "Ignoring unknown document type %q", item.MimeType)
+			break
+		}
+		// If item has export links then it is a google doc
+		extension, exportMimeType := f.findExportFormat(remote, item.MimeType, exportMimeTypes)
+		if extension == "" {
+			fs.Debugf(remote, "No export formats found for %q", item.MimeType)
+			break
+		}
+		o, err := f.newObjectWithInfo(remote+"."+extension, item)
+		if err != nil {
+			return nil, err
+		}
+		obj := o.(*Object)
+		obj.url = fmt.Sprintf("%sfiles/%s/export?mimeType=%s", f.svc.BasePath, item.Id, url.QueryEscape(exportMimeType))
+		if *driveAlternateExport {
+			switch item.MimeType {
+			case "application/vnd.google-apps.drawing":
+				obj.url = fmt.Sprintf("https://docs.google.com/drawings/d/%s/export/%s", item.Id, extension)
+			case "application/vnd.google-apps.document":
+				obj.url = fmt.Sprintf("https://docs.google.com/document/d/%s/export?format=%s", item.Id, extension)
+			case "application/vnd.google-apps.spreadsheet":
+				obj.url = fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/export?format=%s", item.Id, extension)
+			case "application/vnd.google-apps.presentation":
+				obj.url = fmt.Sprintf("https://docs.google.com/presentation/d/%s/export/%s", item.Id, extension)
+			}
+		}
+		obj.isDocument = true
+		obj.mimeType = exportMimeType
+		obj.bytes = -1
+		return o, nil
+	}
+	return nil, nil
+}
+
+// listDir lists dir calling callback with the entries.  If chunk is
+// more than 0 then callback is called every chunk entries, otherwise
+// it is called once with all of them.
+func (f *Fs) listDir(dir string, chunk int, callback func(fs.DirEntries) error) (err error) {
 	err = f.dirCache.FindRoot(false)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	directoryID, err := f.dirCache.FindDir(dir, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var iErr error
+	var (
+		iErr    error
+		entries fs.DirEntries
+	)
 	_, err = f.list(directoryID, "", false, false, false, func(item *drive.File) bool {
-		remote := path.Join(dir, item.Name)
-		switch {
-		case item.MimeType == driveFolderType:
-			// cache the directory ID for later lookups
-			f.dirCache.Put(remote, item.Id)
-			when, _ := time.Parse(timeFormatIn, item.ModifiedTime)
-			d := fs.NewDir(remote, when).SetID(item.Id)
-			entries = append(entries, d)
-		case *driveAuthOwnerOnly && !isAuthOwned(item):
-			// ignore object
-		case item.Md5Checksum != "" || item.Size > 0:
-			// If item has MD5 sum or a length it is a file stored on drive
-			o, err := f.newObjectWithInfo(remote, item)
-			if err != nil {
-				iErr = err
-				return true
-			}
-			entries = append(entries, o)
-		case *driveSkipGdocs:
-			fs.Debugf(remote, "Skipping google document type %q", item.MimeType)
-		default:
-			exportMimeTypes, isDocument := f.exportFormats()[item.MimeType]
-			if !isDocument {
-				fs.Debugf(remote, "Ignoring unknown document type %q", item.MimeType)
-				break
-			}
-			// If item has export links then it is a google doc
-			extension, exportMimeType := f.findExportFormat(remote, exportMimeTypes)
-			if extension == "" {
-				fs.Debugf(remote, "No export formats found for %q", item.MimeType)
-				break
-			}
-			o, err := f.newObjectWithInfo(remote+"."+extension, item)
-			if err != nil {
-				iErr = err
-				return true
-			}
-			obj := o.(*Object)
-			obj.url = fmt.Sprintf("%sfiles/%s/export?mimeType=%s", f.svc.BasePath, item.Id, url.QueryEscape(exportMimeType))
-			if *driveAlternateExport {
-				switch item.MimeType {
-				case "application/vnd.google-apps.drawing":
-					obj.url = fmt.Sprintf("https://docs.google.com/drawings/d/%s/export/%s", item.Id, extension)
-				case "application/vnd.google-apps.document":
-					obj.url = fmt.Sprintf("https://docs.google.com/document/d/%s/export?format=%s", item.Id, extension)
-				case "application/vnd.google-apps.spreadsheet":
-					obj.url = fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/export?format=%s", item.Id, extension)
-				case "application/vnd.google-apps.presentation":
-					obj.url = fmt.Sprintf("https://docs.google.com/presentation/d/%s/export/%s", item.Id, extension)
-				}
-			}
-			obj.isDocument = true
-			obj.mimeType = exportMimeType
-			obj.bytes = -1
-			entries = append(entries, o)
+		entry, err := f.itemToDirEntry(dir, item)
+		if err != nil {
+			iErr = err
+			return true
+		}
+		if entry == nil {
+			return false
+		}
+		entries = append(entries, entry)
+		if chunk > 0 && len(entries) >= chunk {
+			iErr = callback(entries)
+			entries = nil
+			return iErr != nil
 		}
 		return false
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if iErr != nil {
-		return nil, iErr
+		return iErr
+	}
+	if chunk > 0 && len(entries) == 0 {
+		return nil
+	}
+	return callback(entries)
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(dir string) (entries fs.DirEntries, err error) {
+	err = f.listDir(dir, 0, func(all fs.DirEntries) error {
+		entries = all
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return entries, nil
+	return f.dedupeListing(entries), nil
+}
+
+// ListP lists the objects and directories in dir calling callback
+// with each page of them as they are read, so it doesn't have to hold
+// the whole directory in memory.
+//
+// Duplicates are only looked for within each page.
+func (f *Fs) ListP(dir string, callback fs.ListRCallback) error {
+	chunk := int(*driveListChunk)
+	if chunk <= 0 {
+		chunk = 1000
+	}
+	return f.listDir(dir, chunk, func(entries fs.DirEntries) error {
+		return callback(f.dedupeListing(entries))
+	})
 }
 
 // Creates a drive.File info from the parameters passed in and a half
@@ -816,6 +1548,33 @@ func (f *Fs) createFileInfo(remote string, modTime time.Time, size int64) (*Obje
 	return o, createInfo, nil
 }
 
+// updateMetadata updates the metadata of the file with id with a PATCH
+// without a media body so no content is transferred.
+//
+// Only the fields set in updateInfo (which may be nil) are sent so the
+// others are left alone - use ForceSendFields to clear a field.  If
+// addParents and removeParents are set then the file is moved from
+// removeParents to addParents.
+//
+// It returns the fields of the updated file asked for.
+func (f *Fs) updateMetadata(id string, updateInfo *drive.File, addParents, removeParents string, fields googleapi.Field) (info *drive.File, err error) {
+	if updateInfo == nil {
+		updateInfo = &drive.File{}
+	}
+	err = f.getPacer().Call(func() (bool, error) {
+		call := f.svc.Files.Update(id, updateInfo).Fields(fields).SupportsTeamDrives(f.isTeamDrive)
+		if addParents != "" {
+			call = call.AddParents(addParents)
+		}
+		if removeParents != "" {
+			call = call.RemoveParents(removeParents)
+		}
+		info, err = call.Do()
+		return shouldRetry(err)
+	})
+	return info, err
+}
+
 // Put the object
 //
 // Copy the reader in to the new object which is returned
@@ -835,6 +1594,9 @@ func (f *Fs) Put(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.
 }
 
 // PutStream uploads to the remote path with the modTime given of indeterminate size
+//
+// Objects of unknown size are always uploaded with the chunked
+// resumable upload.
 func (f *Fs) PutStream(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
 	return f.Put(in, src, options...)
 }
@@ -852,13 +1614,22 @@ func (f *Fs) PutUnchecked(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOpt
 	if err != nil {
 		return nil, err
 	}
+	wantMD5, err := uploadMD5(in, src)
+	if err != nil {
+		return nil, err
+	}
+	createInfo.MimeType, in, err = uploadMimeType(in, remote, sourceMimeType(src))
+	if err != nil {
+		return nil, err
+	}
+	createInfo.Properties = sourceProperties(src)
 
 	var info *drive.File
-	if size == 0 || size < int64(driveUploadCutoff) {
+	if size >= 0 && size < int64(driveUploadCutoff) {
 		// Make the API request to upload metadata and file data.
 		// Don't retry, return a retry error instead
-		err = f.pacer.CallNoRetry(func() (bool, error) {
-			info, err = f.svc.Files.Create(createInfo).Media(in, googleapi.ContentType("")).Fields(googleapi.Field(partialFields)).SupportsTeamDrives(f.isTeamDrive).KeepRevisionForever(*driveKeepRevisionForever).Do()
+		err = f.getPacer().CallNoRetry(func() (bool, error) {
+			info, err = f.svc.Files.Create(createInfo).Media(in, googleapi.ContentType("")).Fields(googleapi.Field(o.fs.getFields())).SupportsTeamDrives(f.isTeamDrive).KeepRevisionForever(*driveKeepRevisionForever).Do()
 			return shouldRetry(err)
 		})
 		if err != nil {
@@ -871,8 +1642,15 @@ func (f *Fs) PutUnchecked(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOpt
 			return o, err
 		}
 	}
-	o.setMetaData(info)
-	return o, nil
+	err = checkUploadMD5(wantMD5, info)
+	if err != nil {
+		// The new file is corrupted so remove it
+		if removeErr := f.delete(info.Id, *driveUseTrash); removeErr != nil {
+			fs.Errorf(remote, "Failed to remove corrupted upload: %v", removeErr)
+		}
+		return nil, err
+	}
+	return o, o.setUploadedMetaData(info)
 }
 
 // MergeDirs merges the contents of all the directories passed
@@ -896,10 +1674,7 @@ func (f *Fs) MergeDirs(dirs []fs.Directory) error {
 		for _, info := range infos {
 			fs.Infof(srcDir, "merging %q", info.Name)
 			// Move the file into the destination
-			err = f.pacer.Call(func() (bool, error) {
-				_, err = f.svc.Files.Update(info.Id, nil).RemoveParents(srcDir.ID()).AddParents(dstDir.ID()).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
-				return shouldRetry(err)
-			})
+			_, err = f.updateMetadata(info.Id, nil, dstDir.ID(), srcDir.ID(), "")
 			if err != nil {
 				return errors.Wrapf(err, "MergDirs move failed on %q in %v", info.Name, srcDir)
 			}
@@ -926,20 +1701,69 @@ func (f *Fs) Mkdir(dir string) error {
 	return err
 }
 
-// Rmdir deletes a directory unconditionally by ID
-func (f *Fs) rmdir(directoryID string, useTrash bool) error {
-	return f.pacer.Call(func() (bool, error) {
+// isPermissionError returns true if err is a googleapi error saying
+// the user doesn't have sufficient permissions on the file
+func isPermissionError(err error) bool {
+	if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusForbidden {
+		for _, item := range gerr.Errors {
+			if item.Reason == "insufficientFilePermissions" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// delete deletes the file or directory with id, either sending it to
+// the trash or deleting it permanently.  Deleting a directory
+// deletes everything in it in one call.
+//
+// Permanently deleting things in a team drive needs the organizer
+// role, so if that isn't allowed then the item is trashed instead.
+func (f *Fs) delete(id string, useTrash bool) error {
+	err := f.getPacer().Call(func() (bool, error) {
 		var err error
 		if useTrash {
 			info := drive.File{
 				Trashed: true,
 			}
-			_, err = f.svc.Files.Update(directoryID, &info).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
+			_, err = f.svc.Files.Update(id, &info).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
 		} else {
-			err = f.svc.Files.Delete(directoryID).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
+			err = f.svc.Files.Delete(id).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
 		}
 		return shouldRetry(err)
 	})
+	if err != nil && !useTrash && f.isTeamDrive && isPermissionError(err) {
+		fs.Logf(f, "Can't delete permanently without the organizer role on the team drive - sending to the trash instead: %v", err)
+		return f.delete(id, true)
+	}
+	return err
+}
+
+// deleteHeadRevision deletes the head revision of the file with id,
+// which makes the revision before it the head again
+func (f *Fs) deleteHeadRevision(id string) error {
+	var info *drive.File
+	err := f.getPacer().Call(func() (bool, error) {
+		var err error
+		info, err = f.svc.Files.Get(id).Fields("headRevisionId").SupportsTeamDrives(f.isTeamDrive).Do()
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to read head revision")
+	}
+	if info.HeadRevisionId == "" {
+		return errors.New("file has no head revision")
+	}
+	return f.getPacer().Call(func() (bool, error) {
+		err := f.svc.Revisions.Delete(id, info.HeadRevisionId).Do()
+		return shouldRetry(err)
+	})
+}
+
+// Rmdir deletes a directory unconditionally by ID
+func (f *Fs) rmdir(directoryID string, useTrash bool) error {
+	return f.delete(directoryID, useTrash)
 }
 
 // Rmdir deletes a directory
@@ -969,6 +1793,18 @@ func (f *Fs) Rmdir(dir string) error {
 		return errors.Errorf("directory not empty")
 	}
 	if root != "" {
+		// Remove a folder shortcut rather than the folder it
+		// points to
+		if dir != "" {
+			leaf, parentID, err := dc.FindPath(dir, false)
+			if err != nil {
+				return err
+			}
+			if shortcutID := f.shortcuts.dir(parentID, leaf); shortcutID != "" {
+				directoryID = shortcutID
+				f.shortcuts.forgetDir(parentID, leaf)
+			}
+		}
 		// trash the directory if it had trashed files
 		// in or the user wants to trash, otherwise
 		// delete it.
@@ -1014,8 +1850,8 @@ func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
 	}
 
 	var info *drive.File
-	err = o.fs.pacer.Call(func() (bool, error) {
-		info, err = o.fs.svc.Files.Copy(srcObj.id, createInfo).Fields(googleapi.Field(partialFields)).SupportsTeamDrives(f.isTeamDrive).KeepRevisionForever(*driveKeepRevisionForever).Do()
+	err = o.fs.getPacer().Call(func() (bool, error) {
+		info, err = o.fs.svc.Files.Copy(srcObj.id, createInfo).Fields(googleapi.Field(f.getFields())).SupportsTeamDrives(f.isTeamDrive).KeepRevisionForever(*driveKeepRevisionForever).Do()
 		return shouldRetry(err)
 	})
 	if err != nil {
@@ -1039,27 +1875,200 @@ func (f *Fs) Purge() error {
 	if err != nil {
 		return err
 	}
-	err = f.pacer.Call(func() (bool, error) {
-		if *driveUseTrash {
-			info := drive.File{
-				Trashed: true,
-			}
-			_, err = f.svc.Files.Update(f.dirCache.RootID(), &info).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
-		} else {
-			err = f.svc.Files.Delete(f.dirCache.RootID()).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
-		}
-		return shouldRetry(err)
-	})
+	// Trash or delete the root directory which removes
+	// everything in it without having to visit each file
+	err = f.delete(f.dirCache.RootID(), *driveUseTrash)
 	f.dirCache.ResetRoot()
+	f.forgetIDs()
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// unTrashResult is returned by the untrash command
+type unTrashResult struct {
+	Untrashed int
+	Errors    int
+}
+
+// unTrash restores the trashed files and directories in dir, whose
+// ID is directoryID, and below if recurse is set.
+func (f *Fs) unTrash(dir string, directoryID string, recurse bool) (r unTrashResult, err error) {
+	var items []*drive.File
+	_, err = f.list(directoryID, "", false, false, true, func(item *drive.File) bool {
+		items = append(items, item)
+		return false
+	})
+	if err != nil {
+		return r, err
+	}
+	for _, item := range items {
+		remote := path.Join(dir, item.Name)
+		if item.Trashed {
+			if fs.Config.DryRun {
+				fs.Logf(remote, "Not untrashing as --dry-run")
+			} else {
+				info := drive.File{
+					Trashed:         false,
+					ForceSendFields: []string{"Trashed"},
+				}
+				err = f.getPacer().Call(func() (bool, error) {
+					_, err = f.svc.Files.Update(item.Id, &info).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
+					return shouldRetry(err)
+				})
+				if err != nil {
+					fs.Errorf(remote, "Failed to untrash: %v", err)
+					r.Errors++
+					continue
+				}
+				fs.Infof(remote, "Untrashed")
+				r.Untrashed++
+			}
+		}
+		if recurse && item.MimeType == driveFolderType {
+			subR, err := f.unTrash(remote, item.Id, recurse)
+			if err != nil {
+				return r, err
+			}
+			r.Untrashed += subR.Untrashed
+			r.Errors += subR.Errors
+		}
+	}
+	return r, nil
+}
+
+// unTrashDir restores the trashed files and directories in dir and
+// below.
+func (f *Fs) unTrashDir(dir string) (r unTrashResult, err error) {
+	directoryID, err := f.dirCache.FindDir(dir, false)
+	if err != nil {
+		return r, err
+	}
+	r, err = f.unTrash(dir, directoryID, true)
+	f.dirCache.FlushDir(dir)
+	return r, err
+}
+
+// set changes options while rclone is running from arguments of the
+// form key=value, returning the old and new values.
+//
+// The options are global so this changes them for all drive remotes.
+func (f *Fs) set(args []string) (out map[string]map[string]string, err error) {
+	out = map[string]map[string]string{
+		"old": {},
+		"new": {},
+	}
+	for _, arg := range args {
+		equals := strings.IndexRune(arg, '=')
+		if equals < 0 {
+			return nil, errors.Errorf("argument %q should be of the form key=value", arg)
+		}
+		key, value := arg[:equals], arg[equals+1:]
+		switch key {
+		case "chunk_size":
+			var cs fs.SizeSuffix
+			err = cs.Set(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "bad %s", key)
+			}
+			old, err := setChunkSize(cs)
+			if err != nil {
+				return nil, err
+			}
+			fs.Infof(f, "Set chunk size to %v (was %v)", cs, old)
+			out["old"][key] = old.String()
+			out["new"][key] = cs.String()
+		case "pacer_min_sleep":
+			t, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "bad %s", key)
+			}
+			old, err := setPacerMinSleep(t)
+			if err != nil {
+				return nil, err
+			}
+			fs.Infof(f, "Set pacer min sleep to %v (was %v)", t, old)
+			out["old"][key] = old.String()
+			out["new"][key] = t.String()
+		case "pacer_burst":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "bad %s", key)
+			}
+			old, err := setPacerBurst(n)
+			if err != nil {
+				return nil, err
+			}
+			fs.Infof(f, "Set pacer burst to %d (was %d)", n, old)
+			out["old"][key] = strconv.Itoa(old)
+			out["new"][key] = strconv.Itoa(n)
+		default:
+			return nil, errors.Errorf("can't set unknown option %q", key)
+		}
+	}
+	return out, nil
+}
+
+// Command the backend to run a named command
+//
+// The command run is name, args may be used to read arguments from.
+// It returns a result which can be marshalled to JSON.
+func (f *Fs) Command(name string, args []string) (interface{}, error) {
+	switch name {
+	case "untrash":
+		dir := ""
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		return f.unTrashDir(dir)
+	case "set":
+		return f.set(args)
+	case "stats":
+		return getUploadStats(), nil
+	case "drives":
+		return listTeamDrives(f.svc, f.getPacer())
+	case "quota":
+		return f.quotas(args), nil
+	case "shortcut":
+		if len(args) != 2 {
+			return nil, errors.New("need source and destination for shortcut")
+		}
+		return f.makeShortcut(args[0], args[1])
+	case "copyid":
+		if len(args) == 0 || len(args)%2 != 0 {
+			return nil, errors.New("need pairs of file ID and destination for copyid")
+		}
+		var results []copyIDResult
+		for i := 0; i < len(args); i += 2 {
+			r, err := f.copyID(args[i], args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, r)
+		}
+		return results, nil
+	case "finalize-upload":
+		if len(args) == 0 {
+			return nil, errors.New("need the path of an upload for finalize-upload")
+		}
+		var results []finalizeUploadResult
+		for _, remote := range args {
+			r, err := f.finalizeUpload(remote)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, r...)
+		}
+		return results, nil
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
 // CleanUp empties the trash
 func (f *Fs) CleanUp() error {
-	err := f.pacer.Call(func() (bool, error) {
+	err := f.getPacer().Call(func() (bool, error) {
 		err := f.svc.Files.EmptyTrash().Do()
 		return shouldRetry(err)
 	})
@@ -1078,7 +2087,7 @@ func (f *Fs) About() (*fs.Usage, error) {
 	}
 	var about *drive.About
 	var err error
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.getPacer().Call(func() (bool, error) {
 		about, err = f.svc.About.Get().Fields("storageQuota").Do()
 		return shouldRetry(err)
 	})
@@ -1098,6 +2107,25 @@ func (f *Fs) About() (*fs.Usage, error) {
 	return usage, nil
 }
 
+// ServerTime returns the time on the server read from the Date header
+// of a cheap API call
+func (f *Fs) ServerTime() (time.Time, error) {
+	var about *drive.About
+	var err error
+	err = f.getPacer().Call(func() (bool, error) {
+		about, err = f.svc.About.Get().Fields("user").Do()
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to read server time")
+	}
+	serverTime, err := http.ParseTime(about.ServerResponse.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to parse server time")
+	}
+	return serverTime, nil
+}
+
 // Move src to this remote using server side move operations.
 //
 // This is stored with the remote path given
@@ -1116,29 +2144,56 @@ func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
 	if srcObj.isDocument {
 		return nil, errors.New("can't move a Google document")
 	}
-	_, srcParentID, err := srcObj.fs.dirCache.FindPath(src.Remote(), false)
+	srcLeaf, srcParentID, err := srcObj.fs.dirCache.FindPath(src.Remote(), false)
 	if err != nil {
 		return nil, err
 	}
-
-	// Temporary Object under construction
-	dstObj, dstInfo, err := f.createFileInfo(remote, srcObj.ModTime(), srcObj.bytes)
+	dstLeaf, dstParentID, err := f.dirCache.FindRootAndPath(remote, true)
 	if err != nil {
 		return nil, err
 	}
-	dstParents := strings.Join(dstInfo.Parents, ",")
-	dstInfo.Parents = nil
+
+	// Temporary Object under construction
+	dstObj := &Object{
+		fs:     f,
+		remote: remote,
+		bytes:  srcObj.bytes,
+	}
+
+	// Only send the changed fields.  The modification time is
+	// sent so it is preserved whatever drive does on a rename.
+	updateInfo := &drive.File{
+		ModifiedTime: srcObj.ModTime().Format(timeFormatOut),
+	}
+	if dstLeaf != srcLeaf {
+		updateInfo.Name = dstLeaf
+	}
+	var addParents, removeParents string
+	if dstParentID != srcParentID {
+		addParents, removeParents = dstParentID, srcParentID
+	}
+
+	// Move a shortcut rather than what it points to
+	if srcObj.shortcutID != "" {
+		updateInfo.ModifiedTime = ""
+		_, err = f.updateMetadata(srcObj.shortcutID, updateInfo, addParents, removeParents, "id")
+		if err != nil {
+			return nil, err
+		}
+		srcObj.fs.forgetID(srcObj.remote)
+		*dstObj = *srcObj
+		dstObj.fs = f
+		dstObj.remote = remote
+		return dstObj, nil
+	}
 
 	// Do the move
-	var info *drive.File
-	err = f.pacer.Call(func() (bool, error) {
-		info, err = f.svc.Files.Update(srcObj.id, dstInfo).RemoveParents(srcParentID).AddParents(dstParents).Fields(googleapi.Field(partialFields)).SupportsTeamDrives(f.isTeamDrive).Do()
-		return shouldRetry(err)
-	})
+	info, err := f.updateMetadata(srcObj.id, updateInfo, addParents, removeParents, googleapi.Field(f.getFields()))
 	if err != nil {
 		return nil, err
 	}
 
+	srcObj.fs.forgetID(srcObj.remote)
 	dstObj.setMetaData(info)
 	return dstObj, nil
 }
@@ -1153,6 +2208,7 @@ func (f *Fs) PublicLink(remote string) (link string, err error) {
 		o := &Object{
 			fs:     f,
 			remote: remote,
+			id:     f.uploadedID(remote), // saves a lookup if we just uploaded it
 		}
 		if err = o.readMetaData(); err != nil {
 			return
@@ -1166,7 +2222,7 @@ func (f *Fs) PublicLink(remote string) (link string, err error) {
 		Type:               "anyone",
 	}
 
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.getPacer().Call(func() (bool, error) {
 		// TODO: On TeamDrives this might fail if lacking permissions to change ACLs.
 		// Need to either check `canShare` attribute on the object or see if a sufficient permission is already present.
 		_, err = f.svc.Permissions.Create(id, permission).Fields(googleapi.Field("id")).SupportsTeamDrives(f.isTeamDrive).Do()
@@ -1259,18 +2315,31 @@ func (f *Fs) DirMove(src fs.Fs, srcRemote, dstRemote string) error {
 		return err
 	}
 
-	// Do the move
-	patch := drive.File{
-		Name: leaf,
+	// Move a folder shortcut rather than the folder it points to
+	srcLeaf := path.Base(srcPath)
+	shortcutID := srcFs.shortcuts.dir(srcDirectoryID, srcLeaf)
+	if shortcutID != "" {
+		srcID = shortcutID
 	}
-	err = f.pacer.Call(func() (bool, error) {
-		_, err = f.svc.Files.Update(srcID, &patch).RemoveParents(srcDirectoryID).AddParents(dstDirectoryID).Fields("").SupportsTeamDrives(f.isTeamDrive).Do()
-		return shouldRetry(err)
-	})
+
+	// Do the move only sending the changed fields
+	patch := drive.File{}
+	if leaf != path.Base(srcPath) {
+		patch.Name = leaf
+	}
+	var addParents, removeParents string
+	if dstDirectoryID != srcDirectoryID {
+		addParents, removeParents = dstDirectoryID, srcDirectoryID
+	}
+	_, err = f.updateMetadata(srcID, &patch, addParents, removeParents, "")
 	if err != nil {
 		return err
 	}
+	if shortcutID != "" {
+		srcFs.shortcuts.forgetDir(srcDirectoryID, srcLeaf)
+	}
 	srcFs.dirCache.FlushDir(srcRemote)
+	srcFs.forgetIDs()
 	return nil
 }
 
@@ -1281,40 +2350,97 @@ func (f *Fs) DirMove(src fs.Fs, srcRemote, dstRemote string) error {
 //
 // Close the returned channel to stop being notified.
 func (f *Fs) ChangeNotify(notifyFunc func(string, fs.EntryType), pollInterval time.Duration) chan bool {
+	if *drivePollInterval > 0 {
+		pollInterval = *drivePollInterval
+	}
 	quit := make(chan bool)
 	go func() {
-		select {
-		case <-quit:
-			return
-		default:
-			for {
-				f.changeNotifyRunner(notifyFunc, pollInterval)
-				fs.Debugf(f, "Notify listener service ran into issues, restarting shortly.")
-				time.Sleep(pollInterval)
+		var (
+			pageToken string
+			err       error
+		)
+		for {
+			pageToken, err = f.changeNotifyRunner(notifyFunc, pageToken)
+			if err != nil {
+				fs.Debugf(f, "Notify listener service ran into issues, retrying shortly: %v", err)
+			}
+			select {
+			case <-quit:
+				return
+			case <-time.After(pollInterval):
 			}
 		}
 	}()
 	return quit
 }
 
-func (f *Fs) changeNotifyRunner(notifyFunc func(string, fs.EntryType), pollInterval time.Duration) {
-	var err error
+// changeStartPageToken gets a page token for changes made from now on
+func (f *Fs) changeStartPageToken() (pageToken string, err error) {
 	var startPageToken *drive.StartPageToken
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.getPacer().Call(func() (bool, error) {
 		startPageToken, err = f.svc.Changes.GetStartPageToken().SupportsTeamDrives(f.isTeamDrive).Do()
 		return shouldRetry(err)
 	})
 	if err != nil {
-		fs.Debugf(f, "Failed to get StartPageToken: %v", err)
-		return
+		return "", errors.Wrap(err, "failed to get StartPageToken")
+	}
+	return startPageToken.StartPageToken, nil
+}
+
+// changeTokenExpired returns true if err shows that the page token
+// passed to Changes.List is no longer valid
+func changeTokenExpired(err error) bool {
+	gerr, ok := err.(*googleapi.Error)
+	if !ok {
+		return false
+	}
+	switch gerr.Code {
+	case http.StatusNotFound, http.StatusGone:
+		return true
+	case http.StatusBadRequest:
+		// the page token is the only parameter which can
+		// become invalid
+		for _, item := range gerr.Errors {
+			if item.Reason == "invalid" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// changeRefresh is called when the changes since pageToken can't be
+// read.  It gets a new page token then tells notifyFunc that
+// everything has changed so the whole directory cache gets flushed.
+func (f *Fs) changeRefresh(notifyFunc func(string, fs.EntryType)) (pageToken string, err error) {
+	pageToken, err = f.changeStartPageToken()
+	if err != nil {
+		return "", err
+	}
+	fs.Debugf(f, "Change token expired - refreshing all directories")
+	notifyFunc("", fs.EntryDirectory)
+	return pageToken, nil
+}
+
+// changeNotifyRunner reads the changes since pageToken and calls
+// notifyFunc with the paths in the directory cache they affect.
+//
+// It returns the page token to use next time.  If pageToken is empty
+// it just returns the page token for changes made from now on.  If
+// there is a transient error the original pageToken is returned so
+// no changes are missed.
+func (f *Fs) changeNotifyRunner(notifyFunc func(string, fs.EntryType), pageToken string) (string, error) {
+	if pageToken == "" {
+		return f.changeStartPageToken()
 	}
-	pageToken := startPageToken.StartPageToken
 
+	visitedPaths := make(map[string]bool)
 	for {
 		fs.Debugf(f, "Checking for changes on remote")
 		var changeList *drive.ChangeList
 
-		err = f.pacer.Call(func() (bool, error) {
+		err := f.getPacer().Call(func() (bool, error) {
+			var err error
 			changesCall := f.svc.Changes.List(pageToken).Fields("nextPageToken,newStartPageToken,changes(fileId,file(name,parents,mimeType))")
 			if *driveListChunk > 0 {
 				changesCall.PageSize(*driveListChunk)
@@ -1327,9 +2453,11 @@ func (f *Fs) changeNotifyRunner(notifyFunc func(string, fs.EntryType), pollInter
 			changeList, err = changesCall.Do()
 			return shouldRetry(err)
 		})
+		if changeTokenExpired(err) {
+			return f.changeRefresh(notifyFunc)
+		}
 		if err != nil {
-			fs.Debugf(f, "Failed to get Changes: %v", err)
-			return
+			return pageToken, errors.Wrap(err, "failed to get Changes")
 		}
 
 		type entryType struct {
@@ -1371,7 +2499,6 @@ func (f *Fs) changeNotifyRunner(notifyFunc func(string, fs.EntryType), pollInter
 			}
 		}
 
-		visitedPaths := make(map[string]bool)
 		for _, entry := range pathsToClear {
 			if _, ok := visitedPaths[entry.path]; ok {
 				continue
@@ -1381,15 +2508,14 @@ func (f *Fs) changeNotifyRunner(notifyFunc func(string, fs.EntryType), pollInter
 		}
 
 		if changeList.NewStartPageToken != "" {
-			pageToken = changeList.NewStartPageToken
 			fs.Debugf(f, "All changes were processed. Waiting for more.")
-			time.Sleep(pollInterval)
+			return changeList.NewStartPageToken, nil
 		} else if changeList.NextPageToken != "" {
 			pageToken = changeList.NextPageToken
 			fs.Debugf(f, "There are more changes pending, checking now.")
 		} else {
 			fs.Debugf(f, "Did not get any page token, something went wrong! %+v", changeList)
-			return
+			return f.changeRefresh(notifyFunc)
 		}
 	}
 }
@@ -1405,6 +2531,12 @@ func (f *Fs) Hashes() hash.Set {
 	return hash.Set(hash.MD5)
 }
 
+// MaxConcurrentTransfers returns the maximum number of transfers to
+// or from drive which may run at once, or 0 for no limit
+func (f *Fs) MaxConcurrentTransfers() int {
+	return *driveMaxTransfers
+}
+
 // ------------------------------------------------------------
 
 // Fs returns the parent Fs
@@ -1438,6 +2570,89 @@ func (o *Object) Size() int64 {
 	return o.bytes
 }
 
+// setUploadedMetaData sets the metadata from the file info returned
+// by an upload and remembers the ID of the new file so it can be used
+// without looking it up again.
+func (o *Object) setUploadedMetaData(info *drive.File) error {
+	if info == nil || info.Id == "" {
+		return errors.New("upload didn't return the ID of the file")
+	}
+	o.setMetaData(info)
+	if info.Size == 0 && info.Md5Checksum == "" && strings.HasPrefix(info.MimeType, "application/vnd.google-apps.") {
+		// Google docs don't have a size
+		o.bytes = -1
+	}
+	o.fs.rememberID(o.remote, o.id)
+	return nil
+}
+
+// maxUploadedIDs is the most IDs of uploaded files an idCache holds
+const maxUploadedIDs = 10000
+
+// idCache remembers the IDs of uploaded files keyed by remote
+//
+// It holds at most maxUploadedIDs so a long running upload doesn't use
+// ever more memory.  The IDs are only used to save lookups so it
+// doesn't matter which are dropped.
+type idCache struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// newIDCache makes a new empty idCache
+func newIDCache() *idCache {
+	return &idCache{
+		ids: make(map[string]string),
+	}
+}
+
+// rememberID remembers the ID of a file uploaded to remote
+func (f *Fs) rememberID(remote, id string) {
+	if f.uploadedIDs == nil {
+		return
+	}
+	f.uploadedIDs.mu.Lock()
+	defer f.uploadedIDs.mu.Unlock()
+	if _, found := f.uploadedIDs.ids[remote]; !found && len(f.uploadedIDs.ids) >= maxUploadedIDs {
+		for oldRemote := range f.uploadedIDs.ids {
+			delete(f.uploadedIDs.ids, oldRemote)
+			break
+		}
+	}
+	f.uploadedIDs.ids[remote] = id
+}
+
+// uploadedID returns the ID of the file uploaded to remote or "" if
+// not known
+func (f *Fs) uploadedID(remote string) string {
+	if f.uploadedIDs == nil {
+		return ""
+	}
+	f.uploadedIDs.mu.Lock()
+	defer f.uploadedIDs.mu.Unlock()
+	return f.uploadedIDs.ids[remote]
+}
+
+// forgetID forgets the ID of the file uploaded to remote
+func (f *Fs) forgetID(remote string) {
+	if f.uploadedIDs == nil {
+		return
+	}
+	f.uploadedIDs.mu.Lock()
+	defer f.uploadedIDs.mu.Unlock()
+	delete(f.uploadedIDs.ids, remote)
+}
+
+// forgetIDs forgets the IDs of all the uploaded files
+func (f *Fs) forgetIDs() {
+	if f.uploadedIDs == nil {
+		return
+	}
+	f.uploadedIDs.mu.Lock()
+	defer f.uploadedIDs.mu.Unlock()
+	f.uploadedIDs.ids = make(map[string]string)
+}
+
 // setMetaData sets the fs data from a drive.File
 func (o *Object) setMetaData(info *drive.File) {
 	o.id = info.Id
@@ -1449,6 +2664,7 @@ func (o *Object) setMetaData(info *drive.File) {
 	} else {
 		o.modifiedDate = info.ModifiedTime
 	}
+	o.modifiedByMe = info.ModifiedByMeTime
 	o.mimeType = info.MimeType
 }
 
@@ -1466,9 +2682,14 @@ func (o *Object) readMetaData() (err error) {
 		return err
 	}
 
+	var shortcut *drive.File
 	found, err := o.fs.list(directoryID, leaf, false, true, false, func(item *drive.File) bool {
 		if item.Name == leaf {
-			o.setMetaData(item)
+			if item.MimeType == shortcutMimeType {
+				shortcut = item
+			} else {
+				o.setMetaData(item)
+			}
 			return true
 		}
 		return false
@@ -1476,6 +2697,28 @@ func (o *Object) readMetaData() (err error) {
 	if err != nil {
 		return err
 	}
+	if shortcut != nil {
+		if *driveSkipShortcuts {
+			return fs.ErrorObjectNotFound
+		}
+		if !*driveShortcutTarget {
+			o.setMetaData(shortcut)
+			o.shortcutID = shortcut.Id
+			return nil
+		}
+		target, err := o.fs.resolveShortcut(shortcut, directoryID)
+		if err == errDanglingShortcut {
+			fs.Logf(o, "Shortcut target has been deleted: id=%s", shortcut.Id)
+			return fs.ErrorObjectNotFound
+		} else if err != nil {
+			return err
+		}
+		if target.MimeType == driveFolderType {
+			return fs.ErrorNotAFile
+		}
+		o.setMetaData(target)
+		o.shortcutID = shortcut.Id
+	}
 	if !found {
 		return fs.ErrorObjectNotFound
 	}
@@ -1493,7 +2736,11 @@ func (o *Object) ModTime() time.Time {
 		fs.Debugf(o, "Failed to read metadata: %v", err)
 		return time.Now()
 	}
-	modTime, err := time.Parse(timeFormatIn, o.modifiedDate)
+	modifiedDate := o.modifiedDate
+	if fs.Config.UseServerModTime && o.modifiedByMe != "" {
+		modifiedDate = o.modifiedByMe
+	}
+	modTime, err := time.Parse(timeFormatIn, modifiedDate)
 	if err != nil {
 		fs.Debugf(o, "Failed to read mtime from object: %v", err)
 		return time.Now()
@@ -1501,22 +2748,39 @@ func (o *Object) ModTime() time.Time {
 	return modTime
 }
 
+// ServerModTime returns the time the user last modified the object on
+// drive, or the modified time if that isn't known
+func (o *Object) ServerModTime() time.Time {
+	err := o.readMetaData()
+	if err != nil {
+		fs.Debugf(o, "Failed to read metadata: %v", err)
+		return time.Now()
+	}
+	modifiedDate := o.modifiedByMe
+	if modifiedDate == "" {
+		modifiedDate = o.modifiedDate
+	}
+	modTime, err := time.Parse(timeFormatIn, modifiedDate)
+	if err != nil {
+		fs.Debugf(o, "Failed to read server mtime from object: %v", err)
+		return time.Now()
+	}
+	return modTime
+}
+
 // SetModTime sets the modification time of the drive fs object
 func (o *Object) SetModTime(modTime time.Time) error {
 	err := o.readMetaData()
 	if err != nil {
 		return err
 	}
-	// New metadata
+	// New metadata - drive v3 sets the modified time if given so
+	// there is no need for the setModifiedDate of v2
 	updateInfo := &drive.File{
 		ModifiedTime: modTime.Format(timeFormatOut),
 	}
 	// Set modified date
-	var info *drive.File
-	err = o.fs.pacer.Call(func() (bool, error) {
-		info, err = o.fs.svc.Files.Update(o.id, updateInfo).Fields(googleapi.Field(partialFields)).SupportsTeamDrives(o.fs.isTeamDrive).Do()
-		return shouldRetry(err)
-	})
+	info, err := o.fs.updateMetadata(o.id, updateInfo, "", "", googleapi.Field(o.fs.getFields()))
 	if err != nil {
 		return err
 	}
@@ -1549,7 +2813,7 @@ func (o *Object) httpResponse(method string, options []fs.OpenOption) (req *http
 		return req, nil, err
 	}
 	fs.OpenOptionAddHTTPHeaders(req.Header, options)
-	err = o.fs.pacer.Call(func() (bool, error) {
+	err = o.fs.getPacer().Call(func() (bool, error) {
 		res, err = o.fs.client.Do(req)
 		if err == nil {
 			err = googleapi.CheckResponse(res)
@@ -1614,6 +2878,9 @@ func isGoogleError(err error, what string) bool {
 
 // Open an object for read
 func (o *Object) Open(options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	if o.mimeType == shortcutMimeType {
+		return nil, errShortcutContents
+	}
 	_, res, err := o.httpResponse("GET", options)
 	if err != nil {
 		if isGoogleError(err, "cannotDownloadAbusiveFile") {
@@ -1655,18 +2922,28 @@ func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOptio
 	if o.isDocument {
 		return errors.New("can't update a google document")
 	}
+	if o.mimeType == shortcutMimeType {
+		return errShortcutContents
+	}
 	updateInfo := &drive.File{
-		MimeType:     fs.MimeType(src),
 		ModifiedTime: modTime.Format(timeFormatOut),
+		Properties:   sourceProperties(src),
+	}
+	wantMD5, err := uploadMD5(in, src)
+	if err != nil {
+		return err
+	}
+	updateInfo.MimeType, in, err = uploadMimeType(in, src.Remote(), sourceMimeType(src))
+	if err != nil {
+		return err
 	}
 
 	// Make the API request to upload metadata and file data.
-	var err error
 	var info *drive.File
-	if size == 0 || size < int64(driveUploadCutoff) {
+	if size >= 0 && size < int64(driveUploadCutoff) {
 		// Don't retry, return a retry error instead
-		err = o.fs.pacer.CallNoRetry(func() (bool, error) {
-			info, err = o.fs.svc.Files.Update(o.id, updateInfo).Media(in, googleapi.ContentType("")).Fields(googleapi.Field(partialFields)).SupportsTeamDrives(o.fs.isTeamDrive).KeepRevisionForever(*driveKeepRevisionForever).Do()
+		err = o.fs.getPacer().CallNoRetry(func() (bool, error) {
+			info, err = o.fs.svc.Files.Update(o.id, updateInfo).Media(in, googleapi.ContentType("")).Fields(googleapi.Field(o.fs.getFields())).SupportsTeamDrives(o.fs.isTeamDrive).KeepRevisionForever(*driveKeepRevisionForever).Do()
 			return shouldRetry(err)
 		})
 		if err != nil {
@@ -1679,8 +2956,16 @@ func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOptio
 			return err
 		}
 	}
-	o.setMetaData(info)
-	return nil
+	err = checkUploadMD5(wantMD5, info)
+	if err != nil {
+		// The new revision is corrupted so remove it to leave the
+		// previous contents in place
+		if removeErr := o.fs.deleteHeadRevision(info.Id); removeErr != nil {
+			fs.Errorf(o, "Failed to remove corrupted revision: %v", removeErr)
+		}
+		return err
+	}
+	return o.setUploadedMetaData(info)
 }
 
 // Remove an object
@@ -1688,19 +2973,12 @@ func (o *Object) Remove() error {
 	if o.isDocument {
 		return errors.New("can't delete a google document")
 	}
-	var err error
-	err = o.fs.pacer.Call(func() (bool, error) {
-		if *driveUseTrash {
-			info := drive.File{
-				Trashed: true,
-			}
-			_, err = o.fs.svc.Files.Update(o.id, &info).Fields("").SupportsTeamDrives(o.fs.isTeamDrive).Do()
-		} else {
-			err = o.fs.svc.Files.Delete(o.id).Fields("").SupportsTeamDrives(o.fs.isTeamDrive).Do()
-		}
-		return shouldRetry(err)
-	})
-	return err
+	o.fs.forgetID(o.remote)
+	if o.shortcutID != "" {
+		// Only remove the shortcut, not what it points to
+		return o.fs.delete(o.shortcutID, *driveUseTrash)
+	}
+	return o.fs.delete(o.id, *driveUseTrash)
 }
 
 // MimeType of an Object if known, "" otherwise
@@ -1723,6 +3001,7 @@ var (
 	_ fs.Fs              = (*Fs)(nil)
 	_ fs.Purger          = (*Fs)(nil)
 	_ fs.CleanUpper      = (*Fs)(nil)
+	_ fs.Commander       = (*Fs)(nil)
 	_ fs.PutStreamer     = (*Fs)(nil)
 	_ fs.Copier          = (*Fs)(nil)
 	_ fs.Mover           = (*Fs)(nil)
@@ -1733,7 +3012,11 @@ var (
 	_ fs.PublicLinker    = (*Fs)(nil)
 	_ fs.MergeDirser     = (*Fs)(nil)
 	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.ListPer         = (*Fs)(nil)
+	_ fs.TransferLimiter = (*Fs)(nil)
+	_ fs.ServerTimer     = (*Fs)(nil)
 	_ fs.Object          = (*Object)(nil)
 	_ fs.MimeTyper       = (*Object)(nil)
 	_ fs.IDer            = (*Object)(nil)
+	_ fs.ServerModTimer  = (*Object)(nil)
 )
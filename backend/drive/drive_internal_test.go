@@ -1,13 +1,38 @@
 package drive
 
 import (
+	"bytes"
+	"crypto/md5"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/fserrors"
+	"github.com/ncw/rclone/fs/fshttp"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/ncw/rclone/lib/dircache"
 
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const exampleExportFormats = `{
@@ -105,8 +130,1231 @@ func TestInternalFindExportFormat(t *testing.T) {
 	} {
 		f := new(Fs)
 		f.extensions = test.extensions
-		gotExtension, gotMimeType := f.findExportFormat("file", exportFormats[item.MimeType])
+		gotExtension, gotMimeType := f.findExportFormat("file", item.MimeType, exportFormats[item.MimeType])
 		assert.Equal(t, test.wantExtension, gotExtension)
 		assert.Equal(t, test.wantMimeType, gotMimeType)
 	}
 }
+
+func TestInternalParseExportFormats(t *testing.T) {
+	f := new(Fs)
+	require.NoError(t, f.parseExportFormats("docx,xlsx;spreadsheet=ods,XLSX; Document = pdf ;"))
+	assert.Equal(t, []string{"docx", "xlsx"}, f.extensions)
+	assert.Equal(t, map[string][]string{
+		"application/vnd.google-apps.spreadsheet": {"ods", "xlsx"},
+		"application/vnd.google-apps.document":    {"pdf"},
+	}, f.typeExts)
+
+	// Only formats for one type
+	f = new(Fs)
+	require.NoError(t, f.parseExportFormats("drawing=png,svg"))
+	assert.Nil(t, f.extensions)
+	assert.Equal(t, []string{"png", "svg"}, f.typeExts["application/vnd.google-apps.drawing"])
+
+	assert.EqualError(t, new(Fs).parseExportFormats("potato=pdf"), `unknown type of google doc "potato" in export formats`)
+	assert.EqualError(t, new(Fs).parseExportFormats("document=potato"), `couldn't find mime type for extension "potato"`)
+}
+
+func TestInternalFindExportFormatForType(t *testing.T) {
+	const document = "application/vnd.google-apps.document"
+	for _, test := range []struct {
+		typeExts      []string
+		wantExtension string
+		wantMimeType  string
+	}{
+		{nil, "docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{[]string{"pdf", "docx"}, "pdf", "application/pdf"},
+		{[]string{"xlsx", "odt", "pdf"}, "odt", "application/vnd.oasis.opendocument.text"},
+		// falls back to the formats for all types
+		{[]string{"xlsx", "csv"}, "docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	} {
+		f := &Fs{substitutedMu: new(sync.Mutex)}
+		f.extensions = []string{"docx", "xlsx"}
+		f.typeExts = map[string][]string{document: test.typeExts}
+		gotExtension, gotMimeType := f.findExportFormat("file", document, exportFormats[document])
+		assert.Equal(t, test.wantExtension, gotExtension, test.typeExts)
+		assert.Equal(t, test.wantMimeType, gotMimeType, test.typeExts)
+		assert.Equal(t, len(test.typeExts) > 0 && test.wantExtension != test.typeExts[0], f.substituted[document], test.typeExts)
+	}
+}
+
+func TestInternalMakeRequest(t *testing.T) {
+	for _, test := range []struct {
+		contentLength int64
+		start         int64
+		reqSize       int64
+		want          string
+	}{
+		{100, 0, 10, "bytes 0-9/100"},
+		{100, 90, 10, "bytes 90-99/100"},
+		{100, 0, 0, "bytes */100"},
+		{-1, 0, 10, "bytes 0-9/*"},
+		{-1, 10, 10, "bytes 10-19/*"},
+		{-1, 0, 0, "bytes */*"},
+	} {
+		rx := &resumableUpload{
+			URI:           "https://example.com/upload",
+			MediaType:     "text/plain",
+			ContentLength: test.contentLength,
+		}
+		req := rx.makeRequest(test.start, nil, test.reqSize)
+		assert.Equal(t, test.want, req.Header.Get("Content-Range"))
+		assert.Equal(t, test.reqSize, req.ContentLength)
+		assert.Equal(t, "text/plain", req.Header.Get("Content-Type"))
+	}
+}
+
+func TestInternalUploadUnknownSize(t *testing.T) {
+	oldChunkSize := chunkSize
+	chunkSize = fs.SizeSuffix(16)
+	defer func() {
+		chunkSize = oldChunkSize
+	}()
+
+	for _, size := range []int{0, 1, 15, 16, 17, 32, 100} {
+		var (
+			received      bytes.Buffer
+			contentRanges []string
+		)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentRange := r.Header.Get("Content-Range")
+			contentRanges = append(contentRanges, contentRange)
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			received.Write(body)
+			if strings.HasSuffix(contentRange, "/*") {
+				w.WriteHeader(statusResumeIncomplete)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprintf(w, `{"id":"ID","size":"%d"}`, received.Len())
+		}))
+
+		in := bytes.Repeat([]byte{'x'}, size)
+		rx := &resumableUpload{
+			f:             &Fs{uploadClient: http.DefaultClient, pacer: newPacer()},
+			remote:        "file.txt",
+			URI:           ts.URL,
+			Media:         bytes.NewReader(in),
+			MediaType:     "text/plain",
+			ContentLength: -1,
+		}
+		info, err := rx.Upload()
+		ts.Close()
+		require.NoError(t, err, size)
+		assert.Equal(t, "ID", info.Id)
+		assert.Equal(t, int64(size), info.Size)
+		assert.Equal(t, string(in), received.String())
+
+		// All but the last chunk should have an unknown total
+		// and the last should have the real total
+		require.True(t, len(contentRanges) > 0)
+		last := contentRanges[len(contentRanges)-1]
+		assert.True(t, strings.HasSuffix(last, fmt.Sprintf("/%d", size)), last)
+		for _, contentRange := range contentRanges[:len(contentRanges)-1] {
+			assert.True(t, strings.HasSuffix(contentRange, "/*"), contentRange)
+		}
+	}
+}
+
+// Check that all the chunks of a resumable upload are sent through
+// an authenticated proxy
+func TestInternalUploadThroughProxy(t *testing.T) {
+	oldChunkSize := chunkSize
+	chunkSize = fs.SizeSuffix(16)
+	defer func() {
+		chunkSize = oldChunkSize
+	}()
+
+	var (
+		received   bytes.Buffer
+		hosts      []string
+		proxyAuths []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.URL.Host)
+		proxyAuths = append(proxyAuths, r.Header.Get("Proxy-Authorization"))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		received.Write(body)
+		if received.Len() < 40 {
+			w.WriteHeader(statusResumeIncomplete)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"id":"ID","size":"%d"}`, received.Len())
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	proxyURL.User = url.UserPassword("user", "pass")
+
+	client := fshttp.NewClientCustom(fs.Config, func(t *http.Transport) {
+		t.Proxy = http.ProxyURL(proxyURL)
+	})
+	in := bytes.Repeat([]byte{'x'}, 40)
+	rx := &resumableUpload{
+		f:             &Fs{uploadClient: client, pacer: newPacer()},
+		remote:        "file.txt",
+		URI:           "http://upload.example.com/upload?upload_id=1",
+		Media:         bytes.NewReader(in),
+		MediaType:     "text/plain",
+		ContentLength: int64(len(in)),
+	}
+	info, err := rx.Upload()
+	require.NoError(t, err)
+	assert.Equal(t, "ID", info.Id)
+	assert.Equal(t, string(in), received.String())
+
+	require.Equal(t, 3, len(hosts))
+	for i := range hosts {
+		assert.Equal(t, "upload.example.com", hosts[i])
+		assert.Equal(t, "Basic dXNlcjpwYXNz", proxyAuths[i])
+	}
+}
+
+// Check that the bytes of a chunk which is sent again aren't counted
+// twice in the stats
+func TestInternalUploadRollback(t *testing.T) {
+	oldChunkSize := chunkSize
+	chunkSize = fs.SizeSuffix(16)
+	defer func() {
+		chunkSize = oldChunkSize
+	}()
+	accounting.Stats.ResetCounters()
+
+	var (
+		received bytes.Buffer
+		requests int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		// Fail the second chunk the first time it is sent
+		if requests == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received.Write(body)
+		if received.Len() < 40 {
+			w.WriteHeader(statusResumeIncomplete)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"id":"ID","size":"%d"}`, received.Len())
+	}))
+	defer ts.Close()
+
+	in := bytes.Repeat([]byte{'x'}, 40)
+	acc := accounting.NewAccountSizeName(ioutil.NopCloser(bytes.NewReader(in)), int64(len(in)), "file.txt")
+	defer func() {
+		_ = acc.Close()
+	}()
+	rx := &resumableUpload{
+		f:             &Fs{uploadClient: http.DefaultClient, pacer: newPacer()},
+		remote:        "file.txt",
+		URI:           ts.URL,
+		Media:         acc,
+		MediaType:     "text/plain",
+		ContentLength: int64(len(in)),
+	}
+	info, err := rx.Upload()
+	require.NoError(t, err)
+	assert.Equal(t, "ID", info.Id)
+	assert.Equal(t, string(in), received.String())
+	assert.Equal(t, 4, requests)
+	assert.Equal(t, int64(len(in)), accounting.Stats.GetBytes())
+}
+
+func TestInternalSetUploadedMetaData(t *testing.T) {
+	f := &Fs{
+		svc:         &drive.Service{BasePath: "https://example.com/"},
+		uploadedIDs: newIDCache(),
+	}
+	o := &Object{fs: f, remote: "file.txt"}
+
+	err := o.setUploadedMetaData(nil)
+	assert.Error(t, err)
+	err = o.setUploadedMetaData(&drive.File{})
+	assert.Error(t, err)
+
+	err = o.setUploadedMetaData(&drive.File{Id: "ID1", Size: 123, Md5Checksum: "ABC", MimeType: "text/plain"})
+	require.NoError(t, err)
+	assert.Equal(t, "ID1", o.ID())
+	assert.Equal(t, int64(123), o.Size())
+	assert.Equal(t, "ID1", f.uploadedID("file.txt"))
+
+	// Google docs have no size
+	o = &Object{fs: f, remote: "doc"}
+	err = o.setUploadedMetaData(&drive.File{Id: "ID2", MimeType: "application/vnd.google-apps.document"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), o.Size())
+	assert.Equal(t, "ID2", f.uploadedID("doc"))
+
+	f.forgetID("doc")
+	assert.Equal(t, "", f.uploadedID("doc"))
+	assert.Equal(t, "ID1", f.uploadedID("file.txt"))
+	f.forgetIDs()
+	assert.Equal(t, "", f.uploadedID("file.txt"))
+
+	// The cache doesn't grow without bound
+	for i := 0; i < maxUploadedIDs+10; i++ {
+		f.rememberID(fmt.Sprintf("file%d", i), "ID")
+	}
+	assert.Equal(t, maxUploadedIDs, len(f.uploadedIDs.ids))
+	f.rememberID("file0", "ID0")
+	assert.Equal(t, maxUploadedIDs, len(f.uploadedIDs.ids))
+}
+
+func TestInternalParseFields(t *testing.T) {
+	assert.Equal(t, partialFields, parseFields(""))
+	assert.Equal(t, partialFields, parseFields("  "))
+	assert.Equal(t, "id,name,size,mimeType", parseFields("name"))
+	assert.Equal(t, "id,name,size,mimeType,md5Checksum,modifiedTime", parseFields("md5Checksum, modifiedTime,,id"))
+
+	f := &Fs{}
+	assert.Equal(t, partialFields, f.getFields())
+	f.fields = parseFields("md5Checksum")
+	assert.Equal(t, "id,name,size,mimeType,md5Checksum", f.getFields())
+}
+
+func TestInternalChangeNotifyRunner(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/changes/startPageToken"):
+			_, _ = fmt.Fprint(w, `{"startPageToken":"start"}`)
+		case r.URL.Query().Get("pageToken") == "expired":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error":{"code":404,"message":"Page token not found"}}`)
+		case r.URL.Query().Get("pageToken") == "page1":
+			_, _ = fmt.Fprintf(w, `{"nextPageToken":"page2","changes":[
+				{"fileId":"dirID","file":{"name":"dir","mimeType":"%s","parents":["rootID"]}},
+				{"fileId":"ID1","file":{"name":"file.txt","mimeType":"text/plain","parents":["dirID"]}}
+			]}`, driveFolderType)
+		case r.URL.Query().Get("pageToken") == "page2":
+			_, _ = fmt.Fprint(w, `{"newStartPageToken":"next","changes":[
+				{"fileId":"ID2","file":{"name":"other.txt","mimeType":"text/plain","parents":["uncachedID"]}},
+				{"fileId":"ID3","file":{"name":"file.txt","mimeType":"text/plain","parents":["dirID"]}}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{svc: svc, pacer: newPacer()}
+	f.dirCache = dircache.New("", "rootID", f)
+	f.dirCache.Put("dir", "dirID")
+
+	var notified []string
+	notifyFunc := func(path string, entryType fs.EntryType) {
+		notified = append(notified, fmt.Sprintf("%s:%v", path, entryType))
+	}
+
+	// No token - just get the start token
+	pageToken, err := f.changeNotifyRunner(notifyFunc, "")
+	require.NoError(t, err)
+	assert.Equal(t, "start", pageToken)
+	assert.Equal(t, []string(nil), notified)
+
+	// Changes over two pages, notifying each cached path once
+	pageToken, err = f.changeNotifyRunner(notifyFunc, "page1")
+	require.NoError(t, err)
+	assert.Equal(t, "next", pageToken)
+	assert.Equal(t, []string{
+		fmt.Sprintf("dir:%v", fs.EntryDirectory),
+		fmt.Sprintf("dir/file.txt:%v", fs.EntryObject),
+	}, notified)
+
+	// Expired token - get a new one and refresh everything
+	notified = nil
+	pageToken, err = f.changeNotifyRunner(notifyFunc, "expired")
+	require.NoError(t, err)
+	assert.Equal(t, "start", pageToken)
+	assert.Equal(t, []string{fmt.Sprintf(":%v", fs.EntryDirectory)}, notified)
+}
+
+func TestInternalShouldRetryExtraReasons(t *testing.T) {
+	oldReasons := *driveExtraRetryReasons
+	defer func() {
+		*driveExtraRetryReasons = oldReasons
+	}()
+	makeErr := func(code int, reasons ...string) error {
+		gerr := &googleapi.Error{Code: code}
+		for _, reason := range reasons {
+			gerr.Errors = append(gerr.Errors, googleapi.ErrorItem{Reason: reason})
+		}
+		return gerr
+	}
+
+	// Defaults are unchanged
+	*driveExtraRetryReasons = ""
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{makeErr(500), true},
+		{makeErr(403, "rateLimitExceeded"), true},
+		{makeErr(403, "userRateLimitExceeded"), true},
+		{makeErr(403, "dailyLimitExceeded"), false},
+		{makeErr(404, "notFound"), false},
+	} {
+		again, err := shouldRetry(test.err)
+		assert.Equal(t, test.want, again, fmt.Sprint(test.err))
+		assert.Equal(t, test.err, err)
+	}
+
+	// Extra reasons are added to them
+	*driveExtraRetryReasons = "dailyLimitExceeded, backendError"
+	again, _ := shouldRetry(makeErr(403, "dailyLimitExceeded"))
+	assert.True(t, again)
+	again, _ = shouldRetry(makeErr(400, "other", "backendError"))
+	assert.True(t, again)
+	again, _ = shouldRetry(makeErr(403, "rateLimitExceeded"))
+	assert.True(t, again)
+	again, _ = shouldRetry(makeErr(404, "notFound"))
+	assert.False(t, again)
+	again, _ = shouldRetry(makeErr(404))
+	assert.False(t, again)
+}
+
+func TestInternalDeleteAndUnTrash(t *testing.T) {
+	var calls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := strings.TrimPrefix(r.URL.Path, "/files/")
+		switch {
+		case r.Method == "DELETE" && id == "noPermissionID":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprint(w, `{"error":{"code":403,"message":"no","errors":[{"reason":"insufficientFilePermissions"}]}}`)
+		case r.Method == "DELETE":
+			calls = append(calls, "delete "+id)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "PATCH":
+			var file drive.File
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&file))
+			calls = append(calls, fmt.Sprintf("trashed=%v %s", file.Trashed, id))
+			_, _ = fmt.Fprint(w, `{}`)
+		case r.URL.Path == "/files" && strings.Contains(r.URL.Query().Get("q"), "'rootID' in parents"):
+			_, _ = fmt.Fprintf(w, `{"files":[
+				{"id":"fileID","name":"file.txt","mimeType":"text/plain","trashed":true},
+				{"id":"dirID","name":"dir","mimeType":"%s","trashed":false}
+			]}`, driveFolderType)
+		case r.URL.Path == "/files" && strings.Contains(r.URL.Query().Get("q"), "'dirID' in parents"):
+			_, _ = fmt.Fprint(w, `{"files":[
+				{"id":"file2ID","name":"file2.txt","mimeType":"text/plain","trashed":true},
+				{"id":"file3ID","name":"file3.txt","mimeType":"text/plain","trashed":false}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{svc: svc, pacer: newPacer()}
+	f.dirCache = dircache.New("", "rootID", f)
+
+	// Permanent delete and trashing
+	require.NoError(t, f.delete("ID1", false))
+	require.NoError(t, f.delete("ID2", true))
+	assert.Equal(t, []string{"delete ID1", "trashed=true ID2"}, calls)
+
+	// Permanent delete without permission is an error...
+	calls = nil
+	err = f.delete("noPermissionID", false)
+	require.Error(t, err)
+	assert.True(t, isPermissionError(err))
+	assert.Equal(t, []string(nil), calls)
+
+	// ...except in a team drive where it falls back to trashing
+	f.isTeamDrive = true
+	require.NoError(t, f.delete("noPermissionID", false))
+	assert.Equal(t, []string{"trashed=true noPermissionID"}, calls)
+	f.isTeamDrive = false
+
+	// Untrash everything recursively
+	calls = nil
+	out, err := f.Command("untrash", nil)
+	require.NoError(t, err)
+	assert.Equal(t, unTrashResult{Untrashed: 2}, out)
+	assert.Equal(t, []string{"trashed=false fileID", "trashed=false file2ID"}, calls)
+
+	_, err = f.Command("potato", nil)
+	assert.Equal(t, fs.ErrorCommandNotFound, err)
+}
+
+func TestInternalCopyID(t *testing.T) {
+	contents := map[string]string{
+		"fileID":  "file contents",
+		"file2ID": "file2",
+		"file3ID": "file3 contents",
+	}
+	file := func(id, name string) string {
+		sum := md5.Sum([]byte(contents[id]))
+		return fmt.Sprintf(`{"id":%q,"name":%q,"mimeType":"text/plain","size":"%d","md5Checksum":"%x","modifiedTime":"2018-10-26T18:50:20.000Z"}`, id, name, len(contents[id]), sum)
+	}
+	folder := func(id, name string) string {
+		return fmt.Sprintf(`{"id":%q,"name":%q,"mimeType":%q}`, id, name, driveFolderType)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/files/")
+		q := r.URL.Query().Get("q")
+		switch {
+		case r.URL.Query().Get("alt") == "media":
+			_, _ = io.WriteString(w, contents[id])
+		case id == "fileID":
+			_, _ = io.WriteString(w, file("fileID", "file.txt"))
+		case id == "dirID":
+			_, _ = io.WriteString(w, folder("dirID", "dir"))
+		case r.URL.Path == "/files" && strings.Contains(q, "'dirID' in parents"):
+			_, _ = fmt.Fprintf(w, `{"files":[%s,%s]}`, file("file2ID", "file2.txt"), folder("subID", "sub"))
+		case r.URL.Path == "/files" && strings.Contains(q, shortcutMimeType):
+			_, _ = io.WriteString(w, `{"files":[{"id":"loopID","shortcutDetails":{"targetId":"dirID"}}]}`)
+		case r.URL.Path == "/files" && strings.Contains(q, "'subID' in parents"):
+			_, _ = fmt.Fprintf(w, `{"files":[%s,{"id":"loopID","name":"loop","mimeType":%q}]}`, file("file3ID", "file3.txt"), shortcutMimeType)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"error":{"code":404,"message":"not found"}}`)
+		}
+	}))
+	defer ts.Close()
+
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{svc: svc, client: http.DefaultClient, pacer: newPacer(), shortcuts: newShortcutCache()}
+	f.dirCache = dircache.New("", "rootID", f)
+
+	dir, err := ioutil.TempDir("", "rclone-drive-copyid")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	readFile := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// A file to a new name
+	out, err := f.Command("copyid", []string{"fileID", filepath.Join(dir, "copy.txt")})
+	require.NoError(t, err)
+	assert.Equal(t, []copyIDResult{{Files: 1, Bytes: 13}}, out)
+	assert.Equal(t, "file contents", readFile("copy.txt"))
+
+	// A file into a directory keeping its name and a folder
+	// recursively, not following the shortcut back to the folder
+	out, err = f.Command("copyid", []string{"fileID", dir + "/", "dirID", filepath.Join(dir, "dir")})
+	require.NoError(t, err)
+	assert.Equal(t, []copyIDResult{{Files: 1, Bytes: 13}, {Files: 2, Bytes: 19}}, out)
+	assert.Equal(t, "file contents", readFile("file.txt"))
+	assert.Equal(t, "file2", readFile("dir/file2.txt"))
+	assert.Equal(t, "file3 contents", readFile("dir/sub/file3.txt"))
+
+	// Nothing is copied again
+	out, err = f.Command("copyid", []string{"dirID", filepath.Join(dir, "dir")})
+	require.NoError(t, err)
+	assert.Equal(t, []copyIDResult{{}}, out)
+
+	// The folders weren't added to the directory cache
+	_, ok := f.dirCache.Get("sub")
+	assert.False(t, ok)
+
+	_, err = f.Command("copyid", []string{"missingID", dir})
+	assert.Error(t, err)
+	_, err = f.Command("copyid", []string{"fileID"})
+	assert.Error(t, err)
+}
+
+func TestInternalUploadMD5(t *testing.T) {
+	oldUploadMD5 := *driveUploadMD5
+	defer func() {
+		*driveUploadMD5 = oldUploadMD5
+	}()
+	const (
+		data    = "hello world"
+		dataMD5 = "5eb63bbbe01eeed093cb22bb8f5acdc3"
+	)
+	noHash := object.NewStaticObjectInfo("file.txt", time.Now(), int64(len(data)), true, nil, nil)
+	withHash := object.NewStaticObjectInfo("file.txt", time.Now(), int64(len(data)), true, map[hash.Type]string{hash.MD5: "0123"}, nil)
+
+	// Off by default
+	*driveUploadMD5 = false
+	md5sum, err := uploadMD5(strings.NewReader(data), noHash)
+	require.NoError(t, err)
+	assert.Equal(t, "", md5sum)
+
+	// Uses the MD5 of the source if it has one
+	*driveUploadMD5 = true
+	md5sum, err = uploadMD5(strings.NewReader(data), withHash)
+	require.NoError(t, err)
+	assert.Equal(t, "0123", md5sum)
+
+	// Otherwise hashes a seekable reader and rewinds it
+	in := strings.NewReader(data)
+	md5sum, err = uploadMD5(in, noHash)
+	require.NoError(t, err)
+	assert.Equal(t, dataMD5, md5sum)
+	got, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	assert.Equal(t, data, string(got))
+
+	// Can't check unseekable readers
+	md5sum, err = uploadMD5(ioutil.NopCloser(strings.NewReader(data)), noHash)
+	require.NoError(t, err)
+	assert.Equal(t, "", md5sum)
+
+	// Check the MD5 drive returns
+	assert.NoError(t, checkUploadMD5("", &drive.File{Md5Checksum: dataMD5}))
+	assert.NoError(t, checkUploadMD5(dataMD5, &drive.File{}))
+	assert.NoError(t, checkUploadMD5(strings.ToUpper(dataMD5), &drive.File{Md5Checksum: dataMD5}))
+	err = checkUploadMD5("0123", &drive.File{Md5Checksum: dataMD5})
+	require.Error(t, err)
+	assert.True(t, fserrors.IsRetryError(err))
+}
+
+func TestInternalUpdateCorruptedRevision(t *testing.T) {
+	oldUploadMD5 := *driveUploadMD5
+	*driveUploadMD5 = true
+	defer func() {
+		*driveUploadMD5 = oldUploadMD5
+	}()
+	var calls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "PATCH" && r.URL.Path == "/files/fileID":
+			calls = append(calls, "update")
+			_, _ = fmt.Fprint(w, `{"id":"fileID","md5Checksum":"5eb63bbbe01eeed093cb22bb8f5acdc3"}`)
+		case r.Method == "GET" && r.URL.Path == "/files/fileID":
+			calls = append(calls, "get "+r.URL.Query().Get("fields"))
+			_, _ = fmt.Fprint(w, `{"headRevisionId":"rev2"}`)
+		case r.Method == "DELETE":
+			calls = append(calls, "delete "+r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{svc: svc, pacer: newPacer(), uploadedIDs: newIDCache()}
+	o := &Object{fs: f, remote: "file.txt", id: "fileID"}
+
+	// An upload which doesn't match its MD5 is removed again
+	src := object.NewStaticObjectInfo("file.txt", time.Now(), 11, true, map[hash.Type]string{hash.MD5: "0123"}, nil)
+	err = o.Update(strings.NewReader("hello world"), src)
+	require.Error(t, err)
+	assert.True(t, fserrors.IsRetryError(err))
+	assert.Equal(t, []string{"update", "get headRevisionId", "delete /files/fileID/revisions/rev2"}, calls)
+	assert.Equal(t, "", f.uploadedID("file.txt"))
+}
+
+func TestInternalUploadMimeType(t *testing.T) {
+	oldMimeFromContent, oldNoMimeSniff, oldDefaultMimeType := *driveMimeFromContent, *driveNoMimeSniff, *driveDefaultMimeType
+	defer func() {
+		*driveMimeFromContent, *driveNoMimeSniff, *driveDefaultMimeType = oldMimeFromContent, oldNoMimeSniff, oldDefaultMimeType
+	}()
+	png := "\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("x", 600)
+	for _, test := range []struct {
+		name          string
+		fromContent   bool
+		noSniff       bool
+		defaultType   string
+		remote        string
+		sourceType    string
+		data          string
+		wantMimeType  string
+		wantSameInput bool
+	}{
+		{"Extension", false, false, "application/octet-stream", "file.txt", "", png, "text/plain; charset=utf-8", true},
+		{"Unknown", false, false, "application/octet-stream", "file", "", png, "application/octet-stream", true},
+		{"Default", false, false, "text/x-rclone", "file", "", png, "text/x-rclone", true},
+		{"Source", true, false, "application/octet-stream", "file.txt", "image/gif", png, "image/gif", true},
+		{"NoSniff", false, true, "text/x-rclone", "file.txt", "", png, "text/x-rclone", true},
+		{"FromContent", true, false, "application/octet-stream", "file.txt", "", png, "image/png", false},
+		{"FromContentShort", true, false, "application/octet-stream", "file.txt", "", "\x89PNG\x0D\x0A\x1A\x0A", "image/png", false},
+		{"FromContentUnknown", true, false, "application/octet-stream", "file.jpg", "", "\x00\x01\x02", "image/jpeg", false},
+		{"FromContentEmpty", true, false, "text/x-rclone", "file", "", "", "text/x-rclone", false},
+	} {
+		*driveMimeFromContent = test.fromContent
+		*driveNoMimeSniff = test.noSniff
+		*driveDefaultMimeType = test.defaultType
+		in := strings.NewReader(test.data)
+		mimeType, out, err := uploadMimeType(in, test.remote, test.sourceType)
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.wantMimeType, mimeType, test.name)
+		assert.Equal(t, test.wantSameInput, out == io.Reader(in), test.name)
+		// The upload must have all the data whether it was
+		// sniffed or not
+		got, err := ioutil.ReadAll(out)
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.data, string(got), test.name)
+	}
+}
+
+// Check a resumable upload whose start was read to find its MIME type
+// sends all the data with the right Content-Range and accounting
+func TestInternalUploadMimeFromContent(t *testing.T) {
+	oldMimeFromContent := *driveMimeFromContent
+	oldChunkSize := chunkSize
+	*driveMimeFromContent = true
+	chunkSize = fs.SizeSuffix(100)
+	defer func() {
+		*driveMimeFromContent = oldMimeFromContent
+		chunkSize = oldChunkSize
+	}()
+	accounting.Stats.ResetCounters()
+
+	var (
+		received      bytes.Buffer
+		contentRanges []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentRanges = append(contentRanges, r.Header.Get("Content-Range"))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		received.Write(body)
+		if received.Len() < 1000 {
+			w.WriteHeader(statusResumeIncomplete)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"id":"ID","size":"%d"}`, received.Len())
+	}))
+	defer ts.Close()
+
+	in := []byte("%PDF-" + strings.Repeat("x", 995))
+	acc := accounting.NewAccountSizeName(ioutil.NopCloser(bytes.NewReader(in)), int64(len(in)), "file.bin")
+	defer func() {
+		_ = acc.Close()
+	}()
+	mimeType, media, err := uploadMimeType(acc, "file.bin", "")
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", mimeType)
+	assert.Equal(t, int64(0), accounting.Stats.GetBytes())
+
+	rx := &resumableUpload{
+		f:             &Fs{uploadClient: http.DefaultClient, pacer: newPacer()},
+		remote:        "file.bin",
+		URI:           ts.URL,
+		Media:         media,
+		MediaType:     mimeType,
+		ContentLength: int64(len(in)),
+	}
+	info, err := rx.Upload()
+	require.NoError(t, err)
+	assert.Equal(t, "ID", info.Id)
+	assert.Equal(t, string(in), received.String())
+	assert.Equal(t, int64(len(in)), accounting.Stats.GetBytes())
+	require.Equal(t, 10, len(contentRanges))
+	for i, contentRange := range contentRanges {
+		assert.Equal(t, fmt.Sprintf("bytes %d-%d/1000", i*100, i*100+99), contentRange)
+	}
+}
+
+func TestInternalSetChunkSize(t *testing.T) {
+	oldChunkSize := chunkSize
+	defer func() {
+		chunkSize = oldChunkSize
+	}()
+	chunkSize = 8 * 1024 * 1024
+	f := &Fs{}
+
+	out, err := f.Command("set", []string{"chunk_size=64M"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"old": {"chunk_size": "8M"},
+		"new": {"chunk_size": "64M"},
+	}, out)
+	assert.Equal(t, fs.SizeSuffix(64*1024*1024), getChunkSize())
+
+	// Multiples of 256k which aren't powers of two are OK
+	_, err = f.Command("set", []string{"chunk_size=768k"})
+	require.NoError(t, err)
+	assert.Equal(t, fs.SizeSuffix(768*1024), getChunkSize())
+
+	for _, args := range [][]string{
+		{"chunk_size=100k"},
+		{"chunk_size=300k"},
+		{"chunk_size=potato"},
+		{"chunk_size"},
+		{"potato=1M"},
+	} {
+		_, err = f.Command("set", args)
+		assert.Error(t, err, args)
+		assert.Equal(t, fs.SizeSuffix(768*1024), getChunkSize(), args)
+	}
+}
+
+func TestInternalSetPacer(t *testing.T) {
+	oldMinSleep, oldBurst := *drivePacerMinSleep, *drivePacerBurst
+	defer func() {
+		*drivePacerMinSleep, *drivePacerBurst = oldMinSleep, oldBurst
+	}()
+	*drivePacerMinSleep, *drivePacerBurst = minSleep, 1
+	f := &Fs{pacer: newPacer(), pacerGen: pacerGeneration}
+
+	out, err := f.Command("set", []string{"pacer_min_sleep=100ms", "pacer_burst=10"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"old": {"pacer_min_sleep": "10ms", "pacer_burst": "1"},
+		"new": {"pacer_min_sleep": "100ms", "pacer_burst": "10"},
+	}, out)
+
+	// The running pacer picks up the new values
+	assert.NotEqual(t, pacerGeneration, f.pacerGen)
+	f.getPacer()
+	assert.Equal(t, pacerGeneration, f.pacerGen)
+	assert.Equal(t, 100*time.Millisecond, f.pacer.GetSleep())
+
+	for _, args := range [][]string{
+		{"pacer_min_sleep=-1s"},
+		{"pacer_min_sleep=2m"},
+		{"pacer_min_sleep=potato"},
+		{"pacer_burst=0"},
+		{"pacer_burst=1001"},
+		{"pacer_burst=potato"},
+	} {
+		_, err = f.Command("set", args)
+		assert.Error(t, err, args)
+	}
+	assert.Equal(t, 100*time.Millisecond, *drivePacerMinSleep)
+	assert.Equal(t, 10, *drivePacerBurst)
+}
+
+func TestInternalUploadChunkSizeRules(t *testing.T) {
+	oldChunkSize := chunkSize
+	defer func() {
+		chunkSize = oldChunkSize
+	}()
+	chunkSize = 8 * 1024 * 1024
+
+	rules, err := parseChunkSizeRules("*.mkv=256M, video/*.mp4=64M,*=1M")
+	require.NoError(t, err)
+	assert.Equal(t, []chunkSizeRule{
+		{glob: "*.mkv", size: 256 * 1024 * 1024},
+		{glob: "video/*.mp4", size: 64 * 1024 * 1024},
+		{glob: "*", size: 1024 * 1024},
+	}, rules)
+
+	f := &Fs{chunkRules: rules}
+	for _, test := range []struct {
+		remote string
+		want   fs.SizeSuffix
+	}{
+		{"film.mkv", 256 * 1024 * 1024},
+		{"dir/film.mkv", 256 * 1024 * 1024},
+		{"video/clip.mp4", 64 * 1024 * 1024},
+		{"dir/video/clip.mp4", 1024 * 1024},
+		{"notes.txt", 1024 * 1024},
+	} {
+		assert.Equal(t, test.want, f.uploadChunkSize(test.remote), test.remote)
+	}
+
+	// No rules uses --drive-chunk-size
+	rules, err = parseChunkSizeRules("")
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+	f = &Fs{chunkRules: rules}
+	assert.Equal(t, fs.SizeSuffix(8*1024*1024), f.uploadChunkSize("film.mkv"))
+
+	for _, in := range []string{
+		"*.mkv",
+		"*.mkv=potato",
+		"*.mkv=100k",
+		"*.mkv=300k",
+		"[=1M",
+	} {
+		_, err = parseChunkSizeRules(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestInternalDedupeListing(t *testing.T) {
+	oldDuplicates := *driveDuplicates
+	defer func() {
+		*driveDuplicates = oldDuplicates
+	}()
+	obj := func(remote, id, modified string, size int64) *Object {
+		return &Object{remote: remote, id: id, modifiedDate: modified, bytes: size}
+	}
+	a1 := obj("a", "id3", "2018-01-01T00:00:00.000Z", 10)
+	a2 := obj("a", "id2", "2018-02-01T00:00:00.000Z", 5)
+	a3 := obj("a", "id1", "2018-02-01T00:00:00.000Z", 5)
+	b := obj("b", "id4", "2018-01-01T00:00:00.000Z", 1)
+	dir := fs.NewDir("dir", time.Time{}).SetID("id5")
+	listing := func() fs.DirEntries {
+		// b and dir are listed twice under different parents
+		return fs.DirEntries{a1, b, dir, a2, b, a3, dir}
+	}
+
+	for _, test := range []struct {
+		policy string
+		want   fs.DirEntries
+	}{
+		{"keep", fs.DirEntries{a1, b, dir, a2, a3}},
+		{"newest", fs.DirEntries{b, dir, a3}},
+		{"oldest", fs.DirEntries{a1, b, dir}},
+		{"largest", fs.DirEntries{a1, b, dir}},
+		{"Smallest", fs.DirEntries{b, dir, a3}},
+	} {
+		*driveDuplicates = test.policy
+		duplicates, err := parseDuplicates(test.policy)
+		require.NoError(t, err)
+		f := &Fs{duplicates: duplicates}
+		assert.Equal(t, test.want, f.dedupeListing(listing()), test.policy)
+	}
+
+	_, err := parseDuplicates("potato")
+	assert.Error(t, err)
+}
+
+func TestInternalUploadStats(t *testing.T) {
+	oldChunkSize := chunkSize
+	chunkSize = fs.SizeSuffix(16)
+	defer func() {
+		chunkSize = oldChunkSize
+	}()
+
+	// Fail the second chunk once
+	failed := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		contentRange := r.Header.Get("Content-Range")
+		switch {
+		case strings.HasPrefix(contentRange, "bytes 16-") && !failed:
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case strings.HasPrefix(contentRange, "bytes 0-"):
+			w.WriteHeader(statusResumeIncomplete)
+		default:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{"id":"ID"}`)
+		}
+	}))
+	defer ts.Close()
+
+	before := getUploadStats()
+	rx := &resumableUpload{
+		f:             &Fs{uploadClient: http.DefaultClient, pacer: newPacer()},
+		remote:        "file.txt",
+		URI:           ts.URL,
+		Media:         bytes.NewReader(bytes.Repeat([]byte{'x'}, 20)),
+		MediaType:     "text/plain",
+		ContentLength: 20,
+	}
+	_, err := rx.Upload()
+	require.NoError(t, err)
+
+	after := getUploadStats()
+	assert.Equal(t, "drive", after.Backend)
+	assert.Equal(t, int64(1), after.Sessions-before.Sessions)
+	assert.Equal(t, int64(0), after.SessionRestarts-before.SessionRestarts)
+	assert.Equal(t, int64(2), after.ChunksSent-before.ChunksSent)
+	assert.Equal(t, int64(1), after.ChunkRetries-before.ChunkRetries)
+	assert.Equal(t, int64(4), after.BytesResent-before.BytesResent)
+
+	out, err := (&Fs{}).Command("stats", nil)
+	require.NoError(t, err)
+	assert.Equal(t, after, out)
+}
+
+func TestInternalModTimeQuery(t *testing.T) {
+	from := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	to := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	folder := "mimeType='" + driveFolderType + "'"
+	assert.Equal(t, "", modTimeQuery(time.Time{}, time.Time{}))
+	assert.Equal(t, "("+folder+" or (modifiedTime >= '2018-01-02T03:04:05Z'))", modTimeQuery(from, time.Time{}))
+	assert.Equal(t, "("+folder+" or (modifiedTime <= '2019-01-02T03:04:05Z'))", modTimeQuery(time.Time{}, to))
+	assert.Equal(t, "("+folder+" or (modifiedTime >= '2018-01-02T03:04:05Z' and modifiedTime <= '2019-01-02T03:04:05Z'))", modTimeQuery(from, to))
+
+	// Fractional seconds aren't truncated so the bounds are exact
+	fromNano := from.Add(123456789)
+	assert.Equal(t, "("+folder+" or (modifiedTime >= '2018-01-02T03:04:05.123456789Z'))", modTimeQuery(fromNano, time.Time{}))
+
+	*driveUseCreatedDate = true
+	defer func() { *driveUseCreatedDate = false }()
+	assert.Equal(t, "("+folder+" or (createdTime >= '2018-01-02T03:04:05Z'))", modTimeQuery(from, time.Time{}))
+}
+
+func TestInternalDriveQuery(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("q"))
+		_, _ = io.WriteString(w, `{"files":[]}`)
+	}))
+	defer ts.Close()
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{svc: svc, pacer: newPacer()}
+
+	*driveQuery = "mimeType='application/pdf'"
+	defer func() { *driveQuery = "" }()
+	list := func(title string, directoriesOnly bool) string {
+		queries = nil
+		_, err := f.list("dirID", title, directoriesOnly, false, false, func(*drive.File) bool { return false })
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+		return queries[0]
+	}
+
+	// Listings have the query with the folders let through
+	assert.Equal(t, "trashed=false and 'dirID' in parents and (mimeType='"+driveFolderType+"' or (mimeType='application/pdf'))", list("", false))
+
+	// Looking up names and directories doesn't use it
+	assert.Equal(t, "trashed=false and 'dirID' in parents and name='file.txt'", list("file.txt", false))
+	assert.Equal(t, "trashed=false and 'dirID' in parents and mimeType='"+driveFolderType+"'", list("", true))
+}
+
+func TestInternalShortcutFileJSON(t *testing.T) {
+	in := shortcutFile{
+		Name:            "link",
+		MimeType:        shortcutMimeType,
+		Parents:         []string{"parent"},
+		ShortcutDetails: &shortcutDetails{TargetID: "target"},
+	}
+	out, err := json.Marshal(&in)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"link","mimeType":"application/vnd.google-apps.shortcut","parents":["parent"],"shortcutDetails":{"targetId":"target"}}`, string(out))
+}
+
+func TestInternalSkipShortcuts(t *testing.T) {
+	*driveSkipShortcuts = true
+	defer func() { *driveSkipShortcuts = false }()
+	f := &Fs{}
+	entry, err := f.itemToDirEntry("dir", &drive.File{Id: "id", Name: "link", MimeType: shortcutMimeType})
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestInternalShortcutEntries(t *testing.T) {
+	*driveShortcutTarget = false
+	defer func() { *driveShortcutTarget = true }()
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	f := &Fs{svc: svc}
+	entry, err := f.itemToDirEntry("dir", &drive.File{Id: "id", Name: "link", MimeType: shortcutMimeType})
+	require.NoError(t, err)
+	o, ok := entry.(*Object)
+	require.True(t, ok)
+	assert.Equal(t, "dir/link", o.Remote())
+	assert.Equal(t, "id", o.shortcutID)
+	assert.Equal(t, int64(0), o.Size())
+	_, err = o.Open()
+	assert.Equal(t, errShortcutContents, err)
+}
+
+func TestInternalShortcuts(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+q)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/files" && strings.Contains(q, shortcutMimeType):
+			_, _ = fmt.Fprint(w, `{"files":[
+				{"id":"sc1","shortcutDetails":{"targetId":"file1"}},
+				{"id":"sc2","shortcutDetails":{"targetId":"folder1","targetMimeType":"`+driveFolderType+`"}},
+				{"id":"sc3","shortcutDetails":{"targetId":"gone"}},
+				{"id":"sc4","shortcutDetails":{"targetId":"root","targetMimeType":"`+driveFolderType+`"}}]}`)
+		case r.URL.Path == "/files" && strings.Contains(q, "'root' in parents"):
+			_, _ = fmt.Fprint(w, `{"files":[
+				{"id":"sc1","name":"link","mimeType":"`+shortcutMimeType+`"},
+				{"id":"sc2","name":"dirlink","mimeType":"`+shortcutMimeType+`"},
+				{"id":"sc3","name":"dangling","mimeType":"`+shortcutMimeType+`"},
+				{"id":"sc4","name":"loop","mimeType":"`+shortcutMimeType+`"}]}`)
+		case r.URL.Path == "/files":
+			_, _ = fmt.Fprint(w, `{"files":[]}`)
+		case r.URL.Path == "/files/file1":
+			_, _ = fmt.Fprint(w, `{"id":"file1","name":"original","size":"5","md5Checksum":"abc","mimeType":"text/plain"}`)
+		case r.URL.Path == "/files/folder1":
+			_, _ = fmt.Fprint(w, `{"id":"folder1","name":"folder","mimeType":"`+driveFolderType+`"}`)
+		case r.URL.Path == "/files/root":
+			_, _ = fmt.Fprint(w, `{"id":"root","name":"root","mimeType":"`+driveFolderType+`"}`)
+		case r.Method == "PATCH":
+			_, _ = fmt.Fprint(w, `{"id":"`+path.Base(r.URL.Path)+`"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error":{"code":404,"message":"not found"}}`)
+		}
+	}))
+	defer ts.Close()
+
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{
+		svc:          svc,
+		client:       http.DefaultClient,
+		pacer:        newPacer(),
+		rootFolderID: "root",
+		uploadedIDs:  newIDCache(),
+		shortcuts:    newShortcutCache(),
+	}
+	f.dirCache = dircache.New("", f.rootFolderID, f)
+
+	// The shortcuts in a directory are read in one go, the
+	// target of each needs reading and dangling ones and ones which
+	// loop back to a directory above are left out
+	entries, err := f.List("")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	o, ok := entries[0].(*Object)
+	require.True(t, ok)
+	assert.Equal(t, "link", o.Remote())
+	assert.Equal(t, "file1", o.id)
+	assert.Equal(t, "sc1", o.shortcutID)
+	assert.Equal(t, int64(5), o.Size())
+	_, ok = entries[1].(fs.Directory)
+	assert.True(t, ok)
+	assert.Equal(t, "dirlink", entries[1].Remote())
+	assert.Len(t, requests, 6)
+
+	// Removing the directory removes the shortcut not the folder
+	requests = nil
+	require.NoError(t, f.Rmdir("dirlink"))
+	assert.Equal(t, []string{
+		"GET /files 'folder1' in parents",
+		"PATCH /files/sc2 ",
+	}, requests)
+
+	// Moving the file moves the shortcut not the file
+	requests = nil
+	moved, err := f.Move(o, "moved")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PATCH /files/sc1 "}, requests)
+	assert.Equal(t, "moved", moved.Remote())
+	assert.Equal(t, "file1", moved.(*Object).id)
+	assert.Equal(t, "sc1", moved.(*Object).shortcutID)
+}
+
+func TestInternalSkipGdocsSummary(t *testing.T) {
+	*driveSkipGdocs = true
+	defer func() { *driveSkipGdocs = false }()
+	f := &Fs{skippedMu: new(sync.Mutex)}
+	assert.Equal(t, "", f.skippedDocuments())
+	for _, item := range []*drive.File{
+		{Id: "doc1", Name: "one", MimeType: "application/vnd.google-apps.document"},
+		{Id: "sheet", Name: "two", MimeType: "application/vnd.google-apps.spreadsheet"},
+		{Id: "doc2", Name: "three", MimeType: "application/vnd.google-apps.document"},
+		{Id: "doc1", Name: "one", MimeType: "application/vnd.google-apps.document"},
+	} {
+		entry, err := f.itemToDirEntry(item.Name, item)
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	}
+	assert.Equal(t, "3 google docs (2 document, 1 spreadsheet)", f.skippedDocuments())
+}
+
+func TestInternalUpdateMetadata(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		query := r.URL.Query()
+		requests = append(requests, fmt.Sprintf("%s %s add=%q remove=%q %s", r.Method, r.URL.Path, query.Get("addParents"), query.Get("removeParents"), strings.TrimSpace(string(body))))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"id":"ID","modifiedTime":"2018-01-02T03:04:05.000Z"}`)
+	}))
+	defer ts.Close()
+
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{svc: svc, pacer: newPacer()}
+
+	// Only the fields set are sent and there is no media
+	info, err := f.updateMetadata("ID", &drive.File{ModifiedTime: "2018-01-02T03:04:05.000Z"}, "", "", "id,modifiedTime")
+	require.NoError(t, err)
+	assert.Equal(t, "2018-01-02T03:04:05.000Z", info.ModifiedTime)
+
+	// Moving between parents
+	_, err = f.updateMetadata("ID", nil, "newParent", "oldParent", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`PATCH /files/ID add="" remove="" {"modifiedTime":"2018-01-02T03:04:05.000Z"}`,
+		`PATCH /files/ID add="newParent" remove="oldParent" {}`,
+	}, requests)
+}
+
+func TestInternalFindTeamDrive(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/teamdrives", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("pageToken") == "" {
+			_, _ = fmt.Fprint(w, `{"teamDrives":[{"id":"ID1","name":"One"},{"id":"ID2","name":"Two"}],"nextPageToken":"page2"}`)
+		} else {
+			_, _ = fmt.Fprint(w, `{"teamDrives":[{"id":"ID3","name":"Three"},{"id":"ID4","name":"Two"}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{name: "TestInternalFindTeamDrive", svc: svc, pacer: newPacer()}
+
+	// All the pages are listed
+	drives, err := listTeamDrives(f.svc, f.pacer)
+	require.NoError(t, err)
+	assert.Equal(t, []teamDrive{{"ID1", "One"}, {"ID2", "Two"}, {"ID3", "Three"}, {"ID4", "Two"}}, drives)
+	assert.Equal(t, 2, requests)
+
+	// Found on the second page then cached
+	id, err := f.findTeamDrive("Three")
+	require.NoError(t, err)
+	assert.Equal(t, "ID3", id)
+	assert.Equal(t, 4, requests)
+	id, err = f.findTeamDrive("Three")
+	require.NoError(t, err)
+	assert.Equal(t, "ID3", id)
+	assert.Equal(t, 4, requests)
+
+	_, err = f.findTeamDrive("Two")
+	assert.Error(t, err)
+	_, err = f.findTeamDrive("Missing")
+	assert.Error(t, err)
+}
+
+func TestInternalQuota(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/about", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"user":{"emailAddress":"user@example.com"},"storageQuota":{"limit":"10240","usage":"4096","usageInDrive":"3072","usageInDriveTrash":"1024"}}`)
+	}))
+	defer ts.Close()
+
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = ts.URL + "/"
+	f := &Fs{name: "TestInternalQuota", svc: svc, pacer: newPacer()}
+
+	qs := f.quotas([]string{"TestInternalQuota:"})
+	assert.Equal(t, quotas{{
+		Remote:  "TestInternalQuota:",
+		User:    "user@example.com",
+		Used:    3072,
+		Trashed: 1024,
+		Other:   1024,
+		Limit:   10240,
+	}}, qs)
+	assert.Equal(t, `Remote              User              Used  Trashed  Other  Limit  Free
+TestInternalQuota:  user@example.com  3k    1k       1k     10k    6k`, qs.CommandText())
+}
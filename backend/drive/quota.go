@@ -0,0 +1,135 @@
+// The quota backend command
+
+package drive
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/pkg/errors"
+	"google.golang.org/api/drive/v3"
+)
+
+// quota is the usage of the account of one drive remote
+type quota struct {
+	Remote    string `json:"remote"`
+	User      string `json:"user,omitempty"`      // email address of the account
+	TeamDrive string `json:"teamDrive,omitempty"` // ID of the team drive if the remote is one
+	Used      int64  `json:"used"`                // bytes used in drive including the trash
+	Trashed   int64  `json:"trashed"`             // bytes used in the trash
+	Other     int64  `json:"other"`               // bytes used by other services eg gmail
+	Limit     int64  `json:"limit"`               // quota in bytes or -1 if unlimited
+	Error     string `json:"error,omitempty"`     // error reading the quota if any
+}
+
+// quotas is the result of the quota command.  It prints as a table
+// and marshals as JSON.
+type quotas []quota
+
+// Check the interface is satisfied
+var _ fs.CommandTexter = quotas(nil)
+
+// CommandText makes a table of the quotas
+func (qs quotas) CommandText() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Remote\tUser\tUsed\tTrashed\tOther\tLimit\tFree\n")
+	for _, q := range qs {
+		if q.Error != "" {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", q.Remote, "error: "+q.Error)
+			continue
+		}
+		user := q.User
+		if q.TeamDrive != "" {
+			user += " (team drive " + q.TeamDrive + ")"
+		}
+		limit, free := "unlimited", "-"
+		if q.Limit >= 0 {
+			limit = fs.SizeSuffix(q.Limit).String()
+			free = fs.SizeSuffix(q.Limit - q.Used - q.Other).String()
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%v\t%v\t%v\t%s\t%s\n", q.Remote, user, fs.SizeSuffix(q.Used), fs.SizeSuffix(q.Trashed), fs.SizeSuffix(q.Other), limit, free)
+	}
+	_ = w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// quota reads the usage of the account f uses
+func (f *Fs) quota() (q quota) {
+	q = quota{
+		Remote: f.name + ":",
+		Limit:  -1,
+	}
+	if f.isTeamDrive {
+		q.TeamDrive = f.teamDriveID
+	}
+	var about *drive.About
+	var err error
+	err = f.getPacer().Call(func() (bool, error) {
+		about, err = f.svc.About.Get().Fields("user(emailAddress),storageQuota").Do()
+		return shouldRetry(err)
+	})
+	if err != nil {
+		q.Error = errors.Wrap(err, "failed to get Drive storageQuota").Error()
+		return q
+	}
+	if about.User != nil {
+		q.User = about.User.EmailAddress
+	}
+	if s := about.StorageQuota; s != nil {
+		q.Used = s.UsageInDrive
+		q.Trashed = s.UsageInDriveTrash
+		q.Other = s.Usage - s.UsageInDrive
+		if s.Limit > 0 {
+			q.Limit = s.Limit
+		}
+	}
+	return q
+}
+
+// quotaRemotes returns the names of the remotes the quota command
+// should read - the remotes given in args or all the drive remotes in
+// the config file.
+func (f *Fs) quotaRemotes(args []string) (remotes []string) {
+	if len(args) > 0 {
+		for _, arg := range args {
+			remotes = append(remotes, strings.TrimSuffix(arg, ":"))
+		}
+		return remotes
+	}
+	remotes = append(remotes, f.name)
+	for _, name := range config.FileSections() {
+		if name != f.name && config.FileGet(name, "type") == "drive" {
+			remotes = append(remotes, name)
+		}
+	}
+	return remotes
+}
+
+// quotas reads the usage of the accounts used by the remotes named in
+// args, or by all the drive remotes if there are none
+func (f *Fs) quotas(args []string) quotas {
+	var qs quotas
+	for _, name := range f.quotaRemotes(args) {
+		if name == f.name {
+			qs = append(qs, f.quota())
+			continue
+		}
+		other, err := fs.NewFs(name + ":")
+		if err != nil {
+			qs = append(qs, quota{Remote: name + ":", Error: err.Error()})
+			continue
+		}
+		otherDrive, ok := other.(*Fs)
+		if !ok {
+			qs = append(qs, quota{Remote: name + ":", Error: "not a drive remote"})
+			continue
+		}
+		qs = append(qs, otherDrive.quota())
+	}
+	return qs
+}
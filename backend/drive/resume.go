@@ -0,0 +1,204 @@
+// Resuming uploads interrupted when rclone stopped
+//
+// The session of each upload of a known size is saved in a database
+// in the cache directory until the upload completes.  If the same
+// file is uploaded to the same place again, eg by running the same
+// copyto again, the upload carries on from where drive got to rather
+// than starting again.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/pkg/errors"
+	"google.golang.org/api/drive/v3"
+)
+
+// resumeMaxAge is how long upload sessions are resumed for - drive
+// expires them after about a week
+const resumeMaxAge = 6 * 24 * time.Hour
+
+// resumeDBBucket is the name of the bucket the sessions are kept in
+var resumeDBBucket = []byte("uploads")
+
+var (
+	resumeDBOnce sync.Once
+	resumeDB     *bolt.DB
+	resumeDBErr  error
+)
+
+// openResumeDB opens the upload session database the first time it
+// is called
+func openResumeDB() (*bolt.DB, error) {
+	resumeDBOnce.Do(func() {
+		dir := filepath.Join(config.CacheDir, "drive")
+		resumeDBErr = os.MkdirAll(dir, 0700)
+		if resumeDBErr != nil {
+			resumeDBErr = errors.Wrap(resumeDBErr, "failed to make upload session database directory")
+			return
+		}
+		dbPath := filepath.Join(dir, "uploads.db")
+		resumeDB, resumeDBErr = bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+		if resumeDBErr != nil {
+			resumeDBErr = errors.Wrapf(resumeDBErr, "failed to open upload session database %q", dbPath)
+			return
+		}
+		fs.Debugf(nil, "Opened drive upload session database %q", dbPath)
+		resumeDBErr = resumeDB.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(resumeDBBucket)
+			return err
+		})
+	})
+	return resumeDB, resumeDBErr
+}
+
+// resumeState is what is saved for each upload session
+type resumeState struct {
+	URI     string    `json:"uri"`     // the upload session
+	Started time.Time `json:"started"` // when the session was started
+}
+
+// resumeKeyPrefix returns the start of the keys the sessions of
+// uploads to remote are saved under
+func (f *Fs) resumeKeyPrefix(remote string) string {
+	return fmt.Sprintf("%s:%s\x00", f.name, path.Join(f.root, remote))
+}
+
+// resumeKey returns the key the session for an upload is saved
+// under.  This changes if the source is a different size or has been
+// modified so a different file is never resumed.
+func (f *Fs) resumeKey(remote string, fileID string, size int64, info *drive.File) string {
+	return fmt.Sprintf("%s%s\x00%d\x00%s", f.resumeKeyPrefix(remote), fileID, size, info.ModifiedTime)
+}
+
+// resumeFind returns the keys of all the sessions saved under keys
+// starting with prefix
+func resumeFind(prefix string) (keys []string, err error) {
+	db, err := openResumeDB()
+	if err != nil {
+		return nil, err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(resumeDBBucket).Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// resumeGet reads the session saved under key returning nil if there
+// isn't one
+func resumeGet(key string) (*resumeState, error) {
+	db, err := openResumeDB()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(resumeDBBucket).Get([]byte(key)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return nil, err
+	}
+	state := new(resumeState)
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode upload session")
+	}
+	return state, nil
+}
+
+// resumePut saves the session uri under key
+func resumePut(key string, uri string) error {
+	db, err := openResumeDB()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&resumeState{
+		URI:     uri,
+		Started: time.Now(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode upload session")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(resumeDBBucket).Put([]byte(key), data)
+	})
+}
+
+// resumeDelete removes the session saved under key
+func resumeDelete(key string) {
+	db, err := openResumeDB()
+	if err == nil {
+		err = db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(resumeDBBucket).Delete([]byte(key))
+		})
+	}
+	if err != nil {
+		fs.Debugf(nil, "Failed to remove upload session: %v", err)
+	}
+}
+
+// resumeUpload returns an upload which carries on from where the
+// session saved under key got to, or nil if there isn't one which
+// can be resumed.
+//
+// If drive has all of the upload then the returned upload has the
+// file drive made in ret already.
+func (f *Fs) resumeUpload(key string, in io.Reader, size int64, contentType string, remote string) *resumableUpload {
+	state, err := resumeGet(key)
+	if err != nil {
+		fs.Debugf(remote, "Not resuming upload: %v", err)
+		return nil
+	}
+	if state == nil {
+		return nil
+	}
+	if time.Since(state.Started) > resumeMaxAge {
+		fs.Debugf(remote, "Not resuming upload: session has expired")
+		resumeDelete(key)
+		return nil
+	}
+	rx := &resumableUpload{
+		f:             f,
+		remote:        remote,
+		URI:           state.URI,
+		Media:         in,
+		MediaType:     contentType,
+		ContentLength: size,
+	}
+	var start int64
+	err = f.getPacer().Call(func() (bool, error) {
+		start, err = rx.transferStatus()
+		return shouldRetry(err)
+	})
+	if err != nil {
+		fs.Debugf(remote, "Not resuming upload: failed to read status of session: %v", withReason(err))
+		resumeDelete(key)
+		return nil
+	}
+	if rx.ret != nil {
+		fs.Infof(remote, "Upload was completed by drive in saved session")
+	} else {
+		fs.Infof(remote, "Resuming upload from %v in saved session", fs.SizeSuffix(start))
+	}
+	rx.start = start
+	return rx
+}
@@ -0,0 +1,287 @@
+// Drive shortcuts
+//
+// The drive library vendored doesn't know about shortcuts yet so the
+// shortcut details are read and written with plain http requests.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/lib/dircache"
+	"github.com/pkg/errors"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// shortcutMimeType is the mime type drive gives shortcuts
+const shortcutMimeType = "application/vnd.google-apps.shortcut"
+
+// errDanglingShortcut is returned when the target of a shortcut has
+// been deleted
+var errDanglingShortcut = errors.New("shortcut target not found")
+
+// errShortcutContents is returned when reading or writing the contents
+// of a shortcut listed as its own entry
+var errShortcutContents = errors.New("shortcuts have no contents - use --drive-shortcut-target to read what they point to")
+
+// shortcutCache remembers what has been read about shortcuts
+type shortcutCache struct {
+	mu      sync.Mutex
+	details map[string]*shortcutDetails // details by shortcut ID - these can't be changed once made
+	dirs    map[string]string           // ID of each folder shortcut listed as a directory by parent ID and name
+}
+
+// newShortcutCache makes an empty shortcutCache
+func newShortcutCache() *shortcutCache {
+	return &shortcutCache{
+		details: make(map[string]*shortcutDetails),
+		dirs:    make(map[string]string),
+	}
+}
+
+// getDetails returns the details of the shortcut with id if known
+func (c *shortcutCache) getDetails(id string) (details *shortcutDetails, ok bool) {
+	c.mu.Lock()
+	details, ok = c.details[id]
+	c.mu.Unlock()
+	return details, ok
+}
+
+// putDetails remembers the details of the shortcut with id, which may
+// be nil if it doesn't have any
+func (c *shortcutCache) putDetails(id string, details *shortcutDetails) {
+	c.mu.Lock()
+	c.details[id] = details
+	c.mu.Unlock()
+}
+
+// putDir remembers that leaf in the directory parentID is the folder
+// shortcut with id
+func (c *shortcutCache) putDir(parentID, leaf, id string) {
+	c.mu.Lock()
+	c.dirs[parentID+"/"+leaf] = id
+	c.mu.Unlock()
+}
+
+// dir returns the ID of the folder shortcut leaf in the directory
+// parentID or "" if it isn't one
+func (c *shortcutCache) dir(parentID, leaf string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirs[parentID+"/"+leaf]
+}
+
+// forgetDir forgets the folder shortcut leaf in the directory parentID
+func (c *shortcutCache) forgetDir(parentID, leaf string) {
+	c.mu.Lock()
+	delete(c.dirs, parentID+"/"+leaf)
+	c.mu.Unlock()
+}
+
+// shortcutDetails are the extra fields drive stores on a shortcut
+type shortcutDetails struct {
+	TargetID       string `json:"targetId,omitempty"`
+	TargetMimeType string `json:"targetMimeType,omitempty"`
+}
+
+// shortcutFile is the part of a drive file needed for shortcuts
+type shortcutFile struct {
+	ID              string           `json:"id,omitempty"`
+	Name            string           `json:"name,omitempty"`
+	MimeType        string           `json:"mimeType,omitempty"`
+	Parents         []string         `json:"parents,omitempty"`
+	ShortcutDetails *shortcutDetails `json:"shortcutDetails,omitempty"`
+}
+
+// shortcutCall does an http request to the files endpoint of drive
+// with the body in (if not nil) decoding the response into out
+func (f *Fs) shortcutCall(method, path string, params url.Values, in, out interface{}) error {
+	if f.isTeamDrive {
+		params.Set("supportsTeamDrives", "true")
+	}
+	u := f.svc.BasePath + "files" + path + "?" + params.Encode()
+	return f.getPacer().Call(func() (bool, error) {
+		var body bytes.Buffer
+		if in != nil {
+			err := json.NewEncoder(&body).Encode(in)
+			if err != nil {
+				return false, err
+			}
+		}
+		req, err := http.NewRequest(method, u, &body)
+		if err != nil {
+			return false, err
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return shouldRetry(err)
+		}
+		defer googleapi.CloseBody(resp)
+		err = googleapi.CheckResponse(resp)
+		if err != nil {
+			return shouldRetry(err)
+		}
+		return false, json.NewDecoder(resp.Body).Decode(out)
+	})
+}
+
+// readDirShortcuts reads the details of all the shortcuts in the
+// directory parentID with one listing
+func (f *Fs) readDirShortcuts(parentID string) error {
+	params := url.Values{
+		"q":      {fmt.Sprintf("'%s' in parents and mimeType='%s'", parentID, shortcutMimeType)},
+		"fields": {"files(id,shortcutDetails),nextPageToken"},
+	}
+	if f.isTeamDrive {
+		params.Set("teamDriveId", f.teamDriveID)
+		params.Set("includeTeamDriveItems", "true")
+		params.Set("corpora", "teamDrive")
+	}
+	for {
+		var list struct {
+			Files         []shortcutFile `json:"files"`
+			NextPageToken string         `json:"nextPageToken"`
+		}
+		err := f.shortcutCall("GET", "", params, nil, &list)
+		if err != nil {
+			return errors.Wrap(err, "failed to list shortcuts")
+		}
+		for _, file := range list.Files {
+			f.shortcuts.putDetails(file.ID, file.ShortcutDetails)
+		}
+		if list.NextPageToken == "" {
+			return nil
+		}
+		params.Set("pageToken", list.NextPageToken)
+	}
+}
+
+// readShortcutDetails returns the details of the shortcut item, which
+// is in the directory parentID if that isn't "".
+//
+// The details of all the shortcuts in parentID are read at once the
+// first time one of them is needed so listing a directory doesn't
+// need an extra call for each shortcut to find what it points to.
+func (f *Fs) readShortcutDetails(item *drive.File, parentID string) (*shortcutDetails, error) {
+	if details, ok := f.shortcuts.getDetails(item.Id); ok {
+		return details, nil
+	}
+	if parentID != "" {
+		err := f.readDirShortcuts(parentID)
+		if err != nil {
+			return nil, err
+		}
+		if details, ok := f.shortcuts.getDetails(item.Id); ok {
+			return details, nil
+		}
+	}
+	var shortcut shortcutFile
+	err := f.shortcutCall("GET", "/"+item.Id, url.Values{"fields": {"shortcutDetails"}}, nil, &shortcut)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read shortcut")
+	}
+	f.shortcuts.putDetails(item.Id, shortcut.ShortcutDetails)
+	return shortcut.ShortcutDetails, nil
+}
+
+// resolveShortcut returns the file the shortcut item, which is in the
+// directory parentID if that isn't "", points to with the name of the
+// shortcut.  It returns errDanglingShortcut if the target doesn't
+// exist any more.
+func (f *Fs) resolveShortcut(item *drive.File, parentID string) (*drive.File, error) {
+	details, err := f.readShortcutDetails(item, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if details == nil || details.TargetID == "" {
+		return nil, errDanglingShortcut
+	}
+	var target *drive.File
+	err = f.getPacer().Call(func() (bool, error) {
+		target, err = f.svc.Files.Get(details.TargetID).Fields(googleapi.Field(f.getFields())).SupportsTeamDrives(f.isTeamDrive).Do()
+		return shouldRetry(err)
+	})
+	if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusNotFound {
+		return nil, errDanglingShortcut
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read shortcut target")
+	}
+	if target.Trashed && !*driveTrashedOnly {
+		return nil, errDanglingShortcut
+	}
+	target.Name = item.Name
+	return target, nil
+}
+
+// shortcutLoops returns true if following a folder shortcut in dir to
+// the folder with id would lead back to dir or one of the directories
+// above it, so listing it recursively would never end
+func (f *Fs) shortcutLoops(dir, id string) bool {
+	for {
+		if dirID, ok := f.dirCache.Get(dir); ok && dirID == id {
+			return true
+		}
+		if dir == "" {
+			return false
+		}
+		dir, _ = dircache.SplitPath(dir)
+	}
+}
+
+// newShortcutObject makes an Object at remote for the shortcut item
+// itself rather than what it points to
+func (f *Fs) newShortcutObject(remote string, item *drive.File) *Object {
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	o.setMetaData(item)
+	o.shortcutID = item.Id
+	return o
+}
+
+// makeShortcut makes a shortcut at dst pointing to src where both are
+// paths relative to the root.  src may be a file or a directory.
+func (f *Fs) makeShortcut(src, dst string) (interface{}, error) {
+	err := f.dirCache.FindRoot(false)
+	if err != nil {
+		return nil, err
+	}
+	var srcID string
+	if o, err := f.NewObject(src); err == nil {
+		srcID = o.(*Object).id
+	} else if err != fs.ErrorObjectNotFound && err != fs.ErrorNotAFile {
+		return nil, errors.Wrap(err, "shortcut: failed to find source")
+	} else if srcID, err = f.dirCache.FindDir(src, false); err != nil {
+		return nil, errors.Wrap(err, "shortcut: failed to find source")
+	}
+	if _, err := f.NewObject(dst); err == nil {
+		return nil, errors.New("shortcut: destination already exists")
+	}
+	leaf, directoryID, err := f.dirCache.FindRootAndPath(dst, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "shortcut: failed to make destination directory")
+	}
+	createInfo := shortcutFile{
+		Name:            leaf,
+		MimeType:        shortcutMimeType,
+		Parents:         []string{directoryID},
+		ShortcutDetails: &shortcutDetails{TargetID: srcID},
+	}
+	var info shortcutFile
+	err = f.shortcutCall("POST", "", url.Values{"fields": {"id,name,mimeType,shortcutDetails"}}, &createInfo, &info)
+	if err != nil {
+		return nil, errors.Wrap(err, "shortcut: failed to create")
+	}
+	return info, nil
+}
@@ -11,16 +11,26 @@
 package drive
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
 	"github.com/ncw/rclone/fs/fserrors"
+	"github.com/ncw/rclone/fs/fshttp"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/lib/pool"
 	"github.com/ncw/rclone/lib/readers"
 	"github.com/pkg/errors"
 	"google.golang.org/api/drive/v3"
@@ -32,6 +42,43 @@ const (
 	statusResumeIncomplete = 308
 )
 
+// uploadURL is where resumable upload sessions are started - it is a
+// variable so the tests can point it at a mock server
+var uploadURL = "https://www.googleapis.com/upload/drive/v3/files"
+
+// uploadStats counts what the resumable uploader has done since
+// rclone started for all drive remotes.  The counters are updated
+// atomically and read with the stats command.
+var uploadStats struct {
+	sessions        int64 // upload sessions started
+	sessionRestarts int64 // sessions which didn't complete and need restarting
+	chunksSent      int64 // chunks sent successfully
+	chunkRetries    int64 // chunks sent again after an error
+	bytesResent     int64 // bytes sent again after an error
+}
+
+// uploadStatsResult is a snapshot of uploadStats
+type uploadStatsResult struct {
+	Backend         string
+	Sessions        int64
+	SessionRestarts int64
+	ChunksSent      int64
+	ChunkRetries    int64
+	BytesResent     int64
+}
+
+// getUploadStats returns a snapshot of the upload counters
+func getUploadStats() uploadStatsResult {
+	return uploadStatsResult{
+		Backend:         "drive",
+		Sessions:        atomic.LoadInt64(&uploadStats.sessions),
+		SessionRestarts: atomic.LoadInt64(&uploadStats.sessionRestarts),
+		ChunksSent:      atomic.LoadInt64(&uploadStats.chunksSent),
+		ChunkRetries:    atomic.LoadInt64(&uploadStats.chunkRetries),
+		BytesResent:     atomic.LoadInt64(&uploadStats.bytesResent),
+	}
+}
+
 // resumableUpload is used by the generated APIs to provide resumable uploads.
 // It is not used by developers directly.
 type resumableUpload struct {
@@ -43,8 +90,11 @@ type resumableUpload struct {
 	Media io.Reader
 	// MediaType defines the media type, e.g. "image/jpeg".
 	MediaType string
-	// ContentLength is the full size of the object being uploaded.
+	// ContentLength is the full size of the object being uploaded or
+	// -1 if it isn't known.
 	ContentLength int64
+	// start is the offset to upload from when resuming a session
+	start int64
 	// Return value
 	ret *drive.File
 }
@@ -54,14 +104,14 @@ func (f *Fs) Upload(in io.Reader, size int64, contentType string, fileID string,
 	params := make(url.Values)
 	params.Set("alt", "json")
 	params.Set("uploadType", "resumable")
-	params.Set("fields", partialFields)
+	params.Set("fields", f.getFields())
 	if f.isTeamDrive {
 		params.Set("supportsTeamDrives", "true")
 	}
 	if *driveKeepRevisionForever {
 		params.Set("keepRevisionForever", "true")
 	}
-	urls := "https://www.googleapis.com/upload/drive/v3/files"
+	urls := uploadURL
 	method := "POST"
 	if fileID != "" {
 		params.Set("setModifiedDate", "true")
@@ -69,9 +119,17 @@ func (f *Fs) Upload(in io.Reader, size int64, contentType string, fileID string,
 		method = "PATCH"
 	}
 	urls += "?" + params.Encode()
+	// Uploads of a known size can be resumed if rclone is stopped
+	resumeKey := ""
+	if size >= 0 && !*driveNoResume {
+		resumeKey = f.resumeKey(remote, fileID, size, info)
+		if rx := f.resumeUpload(resumeKey, in, size, contentType, remote); rx != nil {
+			return rx.uploadResumable(resumeKey)
+		}
+	}
 	var res *http.Response
 	var err error
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.getPacer().Call(func() (bool, error) {
 		var body io.Reader
 		body, err = googleapi.WithoutDataWrapper.JSONReader(info)
 		if err != nil {
@@ -87,8 +145,10 @@ func (f *Fs) Upload(in io.Reader, size int64, contentType string, fileID string,
 		})
 		req.Header.Set("Content-Type", "application/json; charset=UTF-8")
 		req.Header.Set("X-Upload-Content-Type", contentType)
-		req.Header.Set("X-Upload-Content-Length", fmt.Sprintf("%v", size))
-		res, err = f.client.Do(req)
+		if size >= 0 {
+			req.Header.Set("X-Upload-Content-Length", fmt.Sprintf("%v", size))
+		}
+		res, err = f.uploadClient.Do(req)
 		if err == nil {
 			defer googleapi.CloseBody(res)
 			err = googleapi.CheckResponse(res)
@@ -96,9 +156,10 @@ func (f *Fs) Upload(in io.Reader, size int64, contentType string, fileID string,
 		return shouldRetry(err)
 	})
 	if err != nil {
-		return nil, err
+		return nil, withReason(err)
 	}
 	loc := res.Header.Get("Location")
+	fs.Debugf(remote, "Started upload session")
 	rx := &resumableUpload{
 		f:             f,
 		remote:        remote,
@@ -107,17 +168,156 @@ func (f *Fs) Upload(in io.Reader, size int64, contentType string, fileID string,
 		MediaType:     contentType,
 		ContentLength: size,
 	}
-	return rx.Upload()
+	if resumeKey != "" {
+		if err = resumePut(resumeKey, loc); err != nil {
+			fs.Debugf(remote, "Failed to save upload session: %v", err)
+		}
+	}
+	return rx.uploadResumable(resumeKey)
+}
+
+// uploadResumable does the upload and forgets the session saved under
+// resumeKey, if set, once it has completed.  If it doesn't complete
+// the session is kept so the upload can be resumed.
+func (rx *resumableUpload) uploadResumable(resumeKey string) (*drive.File, error) {
+	ret, err := rx.Upload()
+	if err == nil && resumeKey != "" {
+		resumeDelete(resumeKey)
+	}
+	return ret, err
+}
+
+// uploadMD5 returns the MD5 that the data uploaded from in should
+// have if --drive-upload-md5 is set, or "" if it isn't known.
+//
+// Drive won't accept an MD5 in the metadata of an upload so it can't
+// check the data itself.  Instead the MD5 is read from src if it has
+// one, otherwise in must be seekable so it can be hashed and rewound
+// before the upload starts.  Streams which can't be rewound aren't
+// checked.
+func uploadMD5(in io.Reader, src fs.ObjectInfo) (string, error) {
+	if !*driveUploadMD5 {
+		return "", nil
+	}
+	md5sum, err := src.Hash(hash.MD5)
+	if err == nil && md5sum != "" {
+		return md5sum, nil
+	}
+	seeker, ok := in.(io.ReadSeeker)
+	if !ok {
+		fs.Debugf(src, "Can't check MD5 of upload as source isn't seekable")
+		return "", nil
+	}
+	pos, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find position for MD5 check")
+	}
+	hasher := md5.New()
+	_, err = io.Copy(hasher, seeker)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read source for MD5 check")
+	}
+	_, err = seeker.Seek(pos, io.SeekStart)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to rewind source after MD5 check")
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// mimeSniffLen is the number of bytes http.DetectContentType looks
+// at to find the MIME type
+const mimeSniffLen = 512
+
+// sourceMimeType returns the MIME type src has, or "" if it doesn't
+// know it
+func sourceMimeType(src fs.ObjectInfo) string {
+	if do, ok := src.(fs.MimeTyper); ok {
+		return do.MimeType()
+	}
+	return ""
+}
+
+// sourceProperties returns the custom properties src has to set on
+// the uploaded file, or nil if it doesn't have any
+func sourceProperties(src fs.ObjectInfo) map[string]string {
+	if do, ok := src.(fs.Metadataer); ok {
+		return do.Metadata()
+	}
+	return nil
+}
+
+// uploadMimeType returns the MIME type to upload the file called
+// remote with.  mimeType is the type the source has, if any, which is
+// used if set.
+//
+// Otherwise with --drive-mime-from-content the start of in is read to
+// sniff the type, falling back to the extension of remote unless
+// --drive-no-mime-sniff is set, then to --drive-default-mime-type.
+//
+// It returns the reader to upload from instead of in.  If the start of
+// in was read then it is put back on the front of this so the upload,
+// and the Content-Range of each chunk, is the same as if it hadn't been.
+func uploadMimeType(in io.Reader, remote, mimeType string) (string, io.Reader, error) {
+	if mimeType != "" {
+		return mimeType, in, nil
+	}
+	if *driveMimeFromContent {
+		// Read the start with the accounting off so it is
+		// counted when it is uploaded
+		unwrapped, wrap := accounting.UnWrap(in)
+		head := make([]byte, mimeSniffLen)
+		n, err := readers.ReadFill(unwrapped, head)
+		if err != nil && err != io.EOF {
+			return "", nil, errors.Wrap(err, "failed to read start of upload to find its MIME type")
+		}
+		head = head[:n]
+		in = wrap(io.MultiReader(bytes.NewReader(head), unwrapped))
+		if n > 0 {
+			mimeType = http.DetectContentType(head)
+			if mimeType != "application/octet-stream" {
+				return mimeType, in, nil
+			}
+		}
+	}
+	if !*driveNoMimeSniff {
+		mimeType = fs.MimeTypeFromName(remote)
+		if mimeType != "application/octet-stream" {
+			return mimeType, in, nil
+		}
+	}
+	return *driveDefaultMimeType, in, nil
+}
+
+// checkUploadMD5 checks the MD5 drive computed for the upload in info
+// is wantMD5, returning a retry error if not
+func checkUploadMD5(wantMD5 string, info *drive.File) error {
+	if wantMD5 == "" || info.Md5Checksum == "" {
+		return nil
+	}
+	if !strings.EqualFold(wantMD5, info.Md5Checksum) {
+		return fserrors.RetryErrorf("corrupted on upload: MD5 differ %q vs %q", wantMD5, info.Md5Checksum)
+	}
+	return nil
+}
+
+// totalSize returns the total size of the upload for use in the
+// Content-Range header - this is "*" if it isn't known yet
+func (rx *resumableUpload) totalSize() string {
+	if rx.ContentLength < 0 {
+		return "*"
+	}
+	return strconv.FormatInt(rx.ContentLength, 10)
 }
 
 // Make an http.Request for the range passed in
-func (rx *resumableUpload) makeRequest(start int64, body io.ReadSeeker, reqSize int64) *http.Request {
+func (rx *resumableUpload) makeRequest(start int64, body io.Reader, reqSize int64) *http.Request {
 	req, _ := http.NewRequest("POST", rx.URI, body)
 	req.ContentLength = reqSize
+	total := rx.totalSize()
 	if reqSize != 0 {
-		req.Header.Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", start, start+reqSize-1, rx.ContentLength))
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", start, start+reqSize-1, total))
 	} else {
-		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%v", rx.ContentLength))
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%v", total))
 	}
 	req.Header.Set("Content-Type", rx.MediaType)
 	return req
@@ -125,19 +325,29 @@ func (rx *resumableUpload) makeRequest(start int64, body io.ReadSeeker, reqSize
 
 // rangeRE matches the transfer status response from the server. $1 is
 // the last byte index uploaded.
-var rangeRE = regexp.MustCompile(`^0\-(\d+)$`)
+var rangeRE = regexp.MustCompile(`^(?:bytes=)?0\-(\d+)$`)
 
 // Query drive for the amount transferred so far
 //
-// If error is nil, then start should be valid
+// If error is nil, then start should be valid - it is the offset of
+// the first byte drive hasn't received.  Drive doesn't send a Range
+// if it hasn't received anything yet.  If the upload is complete then
+// rx.ret is set to the file drive made.
 func (rx *resumableUpload) transferStatus() (start int64, err error) {
 	req := rx.makeRequest(0, nil, 0)
-	res, err := rx.f.client.Do(req)
+	res, err := rx.f.uploadClient.Do(req)
 	if err != nil {
 		return 0, err
 	}
 	defer googleapi.CloseBody(res)
 	if res.StatusCode == http.StatusCreated || res.StatusCode == http.StatusOK {
+		// The upload is complete so read the file drive made
+		if err = json.NewDecoder(res.Body).Decode(&rx.ret); err != nil {
+			return 0, errors.Wrap(err, "failed to read completed upload")
+		}
+		if rx.ContentLength < 0 {
+			return rx.ret.Size, nil
+		}
 		return rx.ContentLength, nil
 	}
 	if res.StatusCode != statusResumeIncomplete {
@@ -148,20 +358,107 @@ func (rx *resumableUpload) transferStatus() (start int64, err error) {
 		return 0, errors.Errorf("unexpected http return code %v", res.StatusCode)
 	}
 	Range := res.Header.Get("Range")
+	if Range == "" {
+		return 0, nil
+	}
 	if m := rangeRE.FindStringSubmatch(Range); len(m) == 2 {
 		start, err = strconv.ParseInt(m[1], 10, 64)
 		if err == nil {
-			return start, nil
+			return start + 1, nil
 		}
 	}
 	return 0, errors.Errorf("unable to parse range %q", Range)
 }
 
+// finalizeUploadResult is what the finalize-upload command returns
+type finalizeUploadResult struct {
+	Remote   string // where the file was being uploaded to
+	Complete bool   // set if drive has all of the upload
+	Received int64  // bytes drive has received
+	ID       string // ID of the file made if complete
+	Name     string // name of the file made if complete
+	MD5      string // MD5 of the file made if complete
+}
+
+// finalizeUpload finds out whether the uploads to remote saved in the
+// upload session database were completed.
+//
+// If rclone stops after the last chunk is sent but before the reply is
+// read the file is made on drive without rclone knowing.  If so this
+// reads the file drive made so it doesn't need uploading again and
+// forgets the session.  Incomplete sessions are kept so the next
+// upload of the file can resume them.
+func (f *Fs) finalizeUpload(remote string) (results []finalizeUploadResult, err error) {
+	keys, err := resumeFind(f.resumeKeyPrefix(remote))
+	if err != nil {
+		return nil, errors.Wrap(err, "finalize-upload: failed to read upload sessions")
+	}
+	if len(keys) == 0 {
+		return nil, errors.Errorf("finalize-upload: no upload session saved for %q", remote)
+	}
+	for _, key := range keys {
+		state, err := resumeGet(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "finalize-upload: failed to read upload session")
+		}
+		if state == nil {
+			continue
+		}
+		r, err := f.finalizeSession(remote, key, state.URI)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// finalizeSession finds out whether the upload to remote in the
+// session at uri saved under key was completed
+func (f *Fs) finalizeSession(remote, key, uri string) (r finalizeUploadResult, err error) {
+	r.Remote = remote
+	rx := &resumableUpload{
+		f:             f,
+		remote:        remote,
+		URI:           uri,
+		ContentLength: -1,
+	}
+	err = f.getPacer().Call(func() (bool, error) {
+		r.Received, err = rx.transferStatus()
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return r, errors.Wrap(withReason(err), "finalize-upload: failed to read upload status")
+	}
+	if rx.ret == nil {
+		fs.Logf(remote, "finalize-upload: upload is incomplete with %d bytes received - upload the file again to resume it", r.Received)
+		return r, nil
+	}
+	info, err := f.getFile(rx.ret.Id)
+	if err != nil {
+		return r, errors.Wrapf(err, "finalize-upload: failed to read uploaded file %q", rx.ret.Id)
+	}
+	resumeDelete(key)
+	r.Complete = true
+	r.Received = info.Size
+	r.ID = info.Id
+	r.Name = info.Name
+	r.MD5 = info.Md5Checksum
+	fs.Infof(remote, "finalize-upload: upload completed as %q with ID %q", info.Name, info.Id)
+	return r, nil
+}
+
 // Transfer a chunk - caller must call googleapi.CloseBody(res) if err == nil || res != nil
-func (rx *resumableUpload) transferChunk(start int64, chunk io.ReadSeeker, chunkSize int64) (int, error) {
-	_, _ = chunk.Seek(0, io.SeekStart)
+//
+// The chunk is aborted if none of it is sent for --read-idle-timeout
+// so it is retried rather than waiting for a stalled connection.
+func (rx *resumableUpload) transferChunk(start int64, chunk io.Reader, chunkSize int64) (status int, err error) {
 	req := rx.makeRequest(start, chunk, chunkSize)
-	res, err := rx.f.client.Do(req)
+	req, done := fshttp.WithIdleTimeout(req, fs.Config.ReadIdleTimeout)
+	defer func() {
+		err = done(err)
+	}()
+	res, err := rx.f.uploadClient.Do(req)
 	if err != nil {
 		return 599, err
 	}
@@ -191,34 +488,160 @@ func (rx *resumableUpload) transferChunk(start int64, chunk io.ReadSeeker, chunk
 	return res.StatusCode, nil
 }
 
+// shrinkChunkSize returns the chunk size to use after a chunk of
+// chunkSize has failed too many times - this is half of it rounded
+// down to a multiple of minSize, but not less than minSize.
+//
+// Drive needs every chunk except the last to be a multiple of 256k
+// which minSize is, so this is too.
+func shrinkChunkSize(chunkSize, minSize int64) int64 {
+	newSize := chunkSize / 2 / minSize * minSize
+	if newSize < minSize {
+		newSize = minSize
+	}
+	return newSize
+}
+
 // Upload uploads the chunks from the input
 // It retries each chunk using the pacer and --low-level-retries
+//
+// If rx.ContentLength is -1 then the size isn't known, so each chunk
+// is read into the buffer first.  A short read means the end of the
+// input has been reached, at which point the total size is known and
+// is sent with the final chunk.
+//
+// The accounting is taken off the input and put on each attempt to
+// send a chunk instead, so the bytes of an attempt which fails are
+// rolled back and only the bytes drive has received are counted.
+//
+// If rx.start is set the upload carries on from there in a resumed
+// session, skipping the data drive has already.
+//
+// With --drive-chunk-shrink-retries the chunk size is halved, down to
+// --drive-min-chunk-size, each time a chunk fails more than that many
+// times.  The chunk which failed is read into the buffer and split, and
+// the rest of it is sent from the buffer before any more of the input
+// is read.
+//
+// The buffer comes from the upload pool so this waits if the buffers
+// of the other uploads use --upload-buffer-pool-max already.
 func (rx *resumableUpload) Upload() (*drive.File, error) {
-	start := int64(0)
+	in, wrap := accounting.UnWrap(rx.Media)
+	start := rx.start
 	var StatusCode int
 	var err error
-	buf := make([]byte, int(chunkSize))
-	for start < rx.ContentLength {
-		reqSize := rx.ContentLength - start
-		if reqSize >= int64(chunkSize) {
-			reqSize = int64(chunkSize)
+	if start > 0 && rx.ret == nil {
+		// Skip the data drive has already, without accounting it
+		// as it isn't sent
+		_, err = io.CopyN(ioutil.Discard, in, start)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to skip data uploaded already")
+		}
+	}
+	chunkSize := int64(rx.f.uploadChunkSize(rx.remote))
+	buf := pool.Upload().Get(int(chunkSize))
+	defer pool.Upload().Put(buf)
+	// buf[bufPos:bufEnd] is data read from in which hasn't been sent
+	// yet because its chunk was split
+	var bufPos, bufEnd int64
+	atomic.AddInt64(&uploadStats.sessions, 1)
+	for finished := false; !finished || bufPos < bufEnd; {
+		var reqSize int64
+		var chunk io.ReadSeeker
+		var chunkPos int64 // offset of the chunk in buf
+		if bufPos < bufEnd {
+			// Send the rest of a split chunk from the buffer
+			reqSize = bufEnd - bufPos
+			if reqSize >= chunkSize {
+				reqSize = chunkSize
+			}
+			chunkPos = bufPos
+			chunk = bytes.NewReader(buf[chunkPos : chunkPos+reqSize])
+			bufPos += reqSize
+		} else if rx.ContentLength >= 0 {
+			// If size known use repeatable reader for smoother bwlimit
+			if start >= rx.ContentLength {
+				break
+			}
+			reqSize = rx.ContentLength - start
+			if reqSize >= chunkSize {
+				reqSize = chunkSize
+			}
+			chunk = readers.NewRepeatableLimitReaderBuffer(in, buf, reqSize)
+		} else {
+			// If size unknown read into buffer
+			var n int
+			n, err = readers.ReadFill(in, buf)
+			if err == io.EOF {
+				// Send the last chunk with the correct ContentLength
+				// otherwise Google doesn't know we've finished
+				rx.ContentLength = start + int64(n)
+				finished = true
+			} else if err != nil {
+				return nil, err
+			}
+			reqSize = int64(n)
+			chunk = bytes.NewReader(buf[:reqSize])
 		}
-		chunk := readers.NewRepeatableLimitReaderBuffer(rx.Media, buf, reqSize)
 
 		// Transfer the chunk
-		err = rx.f.pacer.Call(func() (bool, error) {
+		tries := 0
+		failures := 0
+		err = rx.f.getPacer().Call(func() (bool, error) {
+			if tries > 0 {
+				atomic.AddInt64(&uploadStats.chunkRetries, 1)
+				atomic.AddInt64(&uploadStats.bytesResent, reqSize)
+			}
+			tries++
 			fs.Debugf(rx.remote, "Sending chunk %d length %d", start, reqSize)
-			StatusCode, err = rx.transferChunk(start, chunk, reqSize)
-			again, err := shouldRetry(err)
+			_, _ = chunk.Seek(0, io.SeekStart)
+			sent := readers.NewCountingReader(chunk)
+			body := wrap(sent)
+			StatusCode, err = rx.transferChunk(start, body, reqSize)
 			if StatusCode == statusResumeIncomplete || StatusCode == http.StatusCreated || StatusCode == http.StatusOK {
-				again = false
-				err = nil
+				return false, nil
+			}
+			// The chunk will be sent again or the upload fails so
+			// don't count the bytes sent
+			if rb, ok := body.(accounting.Rollbacker); ok {
+				rb.Rollback(int64(sent.BytesRead()))
 			}
-			return again, err
+			if fserrors.IsFatalError(err) {
+				// Don't retry errors such as --max-transfer being reached
+				return false, err
+			}
+			retry, err := shouldRetry(err)
+			failures++
+			minSize := int64(driveMinChunkSize)
+			if !retry || *driveChunkShrinkRetries <= 0 || failures <= *driveChunkShrinkRetries || chunkSize <= minSize {
+				return retry, err
+			}
+			newSize := shrinkChunkSize(chunkSize, minSize)
+			fs.Infof(rx.remote, "Reducing upload chunk size from %v to %v after %d failures", fs.SizeSuffix(chunkSize), fs.SizeSuffix(newSize), failures)
+			chunkSize = newSize
+			failures = 0
+			if reqSize > chunkSize {
+				// Read all of the chunk into the buffer and send
+				// only the start of it next
+				if _, seekErr := chunk.Seek(0, io.SeekEnd); seekErr != nil {
+					return false, seekErr
+				}
+				if _, readErr := io.Copy(ioutil.Discard, chunk); readErr != nil {
+					return false, readErr
+				}
+				if chunkPos+reqSize > bufEnd {
+					bufEnd = chunkPos + reqSize
+				}
+				bufPos = chunkPos + chunkSize
+				reqSize = chunkSize
+				chunk = bytes.NewReader(buf[chunkPos : chunkPos+reqSize])
+			}
+			return retry, err
 		})
 		if err != nil {
-			return nil, err
+			return nil, withReason(err)
 		}
+		atomic.AddInt64(&uploadStats.chunksSent, 1)
 
 		start += reqSize
 	}
@@ -243,7 +666,38 @@ func (rx *resumableUpload) Upload() (*drive.File, error) {
 	// Handle 404 Not Found errors when doing resumable uploads by starting
 	// the entire upload over from the beginning.
 	if rx.ret == nil {
+		atomic.AddInt64(&uploadStats.sessionRestarts, 1)
 		return nil, fserrors.RetryErrorf("Incomplete upload - retry, last error %d", StatusCode)
 	}
 	return rx.ret, nil
 }
+
+// reasonError wraps a googleapi error so the reason given by Google
+// can be read with fserrors.Reason
+type reasonError struct {
+	error
+	reason string
+}
+
+// Reason returns the reason Google gave for the error
+func (err reasonError) Reason() string {
+	return err.reason
+}
+
+// Cause returns the underlying error
+func (err reasonError) Cause() error {
+	return err.error
+}
+
+// Check interface
+var _ fserrors.Reasoner = reasonError{}
+
+// withReason wraps err with the reason from the googleapi error if
+// there is one, otherwise it returns err unchanged
+func withReason(err error) error {
+	gerr, ok := err.(*googleapi.Error)
+	if !ok || len(gerr.Errors) == 0 || gerr.Errors[0].Reason == "" {
+		return err
+	}
+	return reasonError{error: err, reason: gerr.Errors[0].Reason}
+}
@@ -0,0 +1,702 @@
+package drive
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/lib/pacer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// mockUpload is a mock server for the Google resumable upload
+// protocol.
+//
+// A POST or PATCH to /upload starts a session and returns its URI in
+// the Location header.  Chunks are sent to the session with a
+// Content-Range and answered with 308 and the Range received so far
+// until the last one, which is answered with 201 (or 200 for an
+// update) and the JSON of the file.  Status queries with a
+// Content-Range of "bytes */total" are answered the same way.
+//
+// Failures are injected with startErrors and chunkErrors which are
+// the status codes to answer the session starts and the chunks with
+// in turn - 0 accepts the request.  A 404 expires the session as
+// Google does.
+type mockUpload struct {
+	t           *testing.T
+	srv         *httptest.Server
+	mu          sync.Mutex
+	startErrors []int        // status codes to fail session starts with
+	chunkErrors []int        // status codes to fail chunks with
+	sessions    int          // number of sessions started
+	expired     bool         // set if the current session has expired
+	update      bool         // set if the session is updating a file
+	size        int64        // size of the upload or -1 if not known
+	received    bytes.Buffer // data received in the current session
+	complete    bool         // set when all the data has been received
+	info        drive.File   // metadata sent to start the session
+	log         []string     // requests received
+}
+
+// newMockUpload starts a mockUpload and points uploadURL at it,
+// returning a function to undo this.
+//
+// Upload sessions aren't saved unless the test turns this back on.
+func newMockUpload(t *testing.T) (m *mockUpload, cleanup func()) {
+	m = &mockUpload{t: t, size: -1}
+	m.srv = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	oldUploadURL := uploadURL
+	uploadURL = m.srv.URL + "/upload"
+	oldNoResume := *driveNoResume
+	*driveNoResume = true
+	return m, func() {
+		uploadURL = oldUploadURL
+		*driveNoResume = oldNoResume
+		m.srv.Close()
+	}
+}
+
+// newFs makes an Fs which uploads to the mock in chunks of chunkSize
+// and doesn't wait long between retries
+func (m *mockUpload) newFs(chunkSize fs.SizeSuffix) *Fs {
+	return &Fs{
+		uploadClient: http.DefaultClient,
+		pacer:        pacer.New().SetMinSleep(time.Millisecond).SetMaxSleep(10 * time.Millisecond).SetRetries(3),
+		pacerGen:     pacerGeneration,
+		chunkRules:   []chunkSizeRule{{glob: "*", size: chunkSize}},
+	}
+}
+
+// sessionURI returns the URI of the current session
+func (m *mockUpload) sessionURI() string {
+	return fmt.Sprintf("%s/session?upload_id=%d", m.srv.URL, m.sessions)
+}
+
+// nextError returns the next status code from errs, or 0 if there
+// aren't any left
+func nextError(errs *[]int) int {
+	if len(*errs) == 0 {
+		return 0
+	}
+	status := (*errs)[0]
+	*errs = (*errs)[1:]
+	return status
+}
+
+func (m *mockUpload) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(m.t, err)
+	if strings.HasPrefix(r.URL.Path, "/upload") {
+		m.start(w, r, body)
+		return
+	}
+	contentRange := r.Header.Get("Content-Range")
+	if r.URL.Query().Get("upload_id") != strconv.Itoa(m.sessions) || m.expired {
+		m.log = append(m.log, "expired "+contentRange)
+		m.fail(w, http.StatusNotFound)
+		return
+	}
+	if strings.HasPrefix(contentRange, "bytes */") {
+		m.log = append(m.log, "status "+contentRange)
+		m.checkComplete(strings.TrimPrefix(contentRange, "bytes */"))
+		m.reply(w, r)
+		return
+	}
+	m.log = append(m.log, contentRange)
+	if status := nextError(&m.chunkErrors); status != 0 {
+		if status == http.StatusNotFound {
+			m.expired = true
+		}
+		m.fail(w, status)
+		return
+	}
+	var first, last int64
+	var total string
+	_, err = fmt.Sscanf(contentRange, "bytes %d-%d/%s", &first, &last, &total)
+	if err != nil || first != int64(m.received.Len()) || last-first+1 != int64(len(body)) {
+		m.fail(w, http.StatusBadRequest)
+		return
+	}
+	m.received.Write(body)
+	m.checkComplete(total)
+	m.reply(w, r)
+}
+
+// start a new upload session
+func (m *mockUpload) start(w http.ResponseWriter, r *http.Request, body []byte) {
+	m.log = append(m.log, r.Method+" start")
+	if status := nextError(&m.startErrors); status != 0 {
+		m.fail(w, status)
+		return
+	}
+	m.info = drive.File{}
+	require.NoError(m.t, json.Unmarshal(body, &m.info))
+	m.size = -1
+	if size := r.Header.Get("X-Upload-Content-Length"); size != "" {
+		m.size, _ = strconv.ParseInt(size, 10, 64)
+	}
+	m.sessions++
+	m.expired = false
+	m.update = r.Method == "PATCH"
+	m.received.Reset()
+	m.complete = false
+	w.Header().Set("Location", m.sessionURI())
+	w.WriteHeader(http.StatusOK)
+}
+
+// checkComplete marks the upload complete if total is known and has
+// been received
+func (m *mockUpload) checkComplete(total string) {
+	if total != "*" {
+		size, err := strconv.ParseInt(total, 10, 64)
+		require.NoError(m.t, err)
+		m.size = size
+	}
+	if m.size >= 0 && int64(m.received.Len()) == m.size {
+		m.complete = true
+	}
+}
+
+// reply with the status of the upload
+func (m *mockUpload) reply(w http.ResponseWriter, r *http.Request) {
+	if !m.complete {
+		if m.received.Len() > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", m.received.Len()-1))
+		}
+		w.WriteHeader(statusResumeIncomplete)
+		return
+	}
+	md5sum := md5.Sum(m.received.Bytes())
+	info := drive.File{
+		Id:          "ID",
+		Name:        m.info.Name,
+		MimeType:    r.Header.Get("Content-Type"),
+		Size:        int64(m.received.Len()),
+		Md5Checksum: hex.EncodeToString(md5sum[:]),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if m.update {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	require.NoError(m.t, json.NewEncoder(w).Encode(&info))
+}
+
+// fail the request with status
+func (m *mockUpload) fail(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `{"error":{"code":%d,"message":"mock error %d","errors":[{"reason":"mockError"}]}}`, status, status)
+}
+
+// errorCode returns the HTTP status code of the googleapi error in
+// err or 0 if there isn't one.  This reads the message as the pacer
+// wraps the error when it runs out of retries.
+func errorCode(err error) int {
+	code := 0
+	_, _ = fmt.Sscanf(err.Error(), "googleapi: Error %d", &code)
+	return code
+}
+
+func TestInternalResumableUpload(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		size        int64
+		fileID      string
+		startErrors []int
+		chunkErrors []int
+		wantLog     []string
+		wantErr     int
+	}{
+		{
+			name:    "OK",
+			size:    40,
+			wantLog: []string{"POST start", "bytes 0-15/40", "bytes 16-31/40", "bytes 32-39/40"},
+		},
+		{
+			name:    "UnknownSize",
+			size:    -1,
+			wantLog: []string{"POST start", "bytes 0-15/*", "bytes 16-31/*", "bytes 32-39/40"},
+		},
+		{
+			name:    "ExactChunks",
+			size:    32,
+			wantLog: []string{"POST start", "bytes 0-15/32", "bytes 16-31/32"},
+		},
+		{
+			name:    "Update",
+			size:    20,
+			fileID:  "ID",
+			wantLog: []string{"PATCH start", "bytes 0-15/20", "bytes 16-19/20"},
+		},
+		{
+			name:        "RetryStart",
+			size:        20,
+			startErrors: []int{503},
+			wantLog:     []string{"POST start", "POST start", "bytes 0-15/20", "bytes 16-19/20"},
+		},
+		{
+			name:        "RetryChunk",
+			size:        40,
+			chunkErrors: []int{0, 500, 502},
+			wantLog:     []string{"POST start", "bytes 0-15/40", "bytes 16-31/40", "bytes 16-31/40", "bytes 16-31/40", "bytes 32-39/40"},
+		},
+		{
+			name:        "RetryLastChunkUnknownSize",
+			size:        -1,
+			chunkErrors: []int{0, 0, 503},
+			wantLog:     []string{"POST start", "bytes 0-15/*", "bytes 16-31/*", "bytes 32-39/40", "bytes 32-39/40"},
+		},
+		{
+			name:        "TooManyRetries",
+			size:        40,
+			chunkErrors: []int{0, 503, 503, 503},
+			wantLog:     []string{"POST start", "bytes 0-15/40", "bytes 16-31/40", "bytes 16-31/40", "bytes 16-31/40"},
+			wantErr:     503,
+		},
+		{
+			name:        "SessionExpired",
+			size:        40,
+			chunkErrors: []int{0, 404},
+			wantLog:     []string{"POST start", "bytes 0-15/40", "bytes 16-31/40"},
+			wantErr:     404,
+		},
+		{
+			name:        "NotRetried",
+			size:        40,
+			startErrors: []int{403},
+			wantLog:     []string{"POST start"},
+			wantErr:     403,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m, cleanup := newMockUpload(t)
+			defer cleanup()
+			m.startErrors = test.startErrors
+			m.chunkErrors = test.chunkErrors
+			f := m.newFs(16)
+
+			in := []byte(strings.Repeat("0123456789", 4))
+			if test.size >= 0 {
+				in = in[:test.size]
+			}
+			info, err := f.Upload(bytes.NewReader(in), test.size, "text/plain", test.fileID, &drive.File{Name: "file.txt"}, "file.txt")
+			assert.Equal(t, test.wantLog, m.log)
+			if test.wantErr != 0 {
+				require.Error(t, err)
+				assert.Equal(t, test.wantErr, errorCode(err), err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "ID", info.Id)
+			assert.Equal(t, "file.txt", m.info.Name)
+			assert.Equal(t, "text/plain", info.MimeType)
+			assert.Equal(t, int64(len(in)), info.Size)
+			md5sum := md5.Sum(in)
+			assert.Equal(t, hex.EncodeToString(md5sum[:]), info.Md5Checksum)
+			assert.Equal(t, string(in), m.received.String())
+			assert.Equal(t, 1, m.sessions)
+		})
+	}
+}
+
+func TestInternalShrinkChunkSize(t *testing.T) {
+	const k = 256 * 1024
+	assert.Equal(t, int64(4*1024*k), shrinkChunkSize(8*1024*k, k))
+	assert.Equal(t, int64(k), shrinkChunkSize(3*k, k))
+	assert.Equal(t, int64(k), shrinkChunkSize(k, k))
+	assert.Equal(t, int64(4*k), shrinkChunkSize(10*k, 4*k))
+	assert.Equal(t, int64(4*k), shrinkChunkSize(6*k, 4*k))
+}
+
+func TestInternalResumableUploadShrink(t *testing.T) {
+	oldRetries, oldMinChunkSize := *driveChunkShrinkRetries, driveMinChunkSize
+	defer func() {
+		*driveChunkShrinkRetries, driveMinChunkSize = oldRetries, oldMinChunkSize
+	}()
+	*driveChunkShrinkRetries = 1
+	for _, test := range []struct {
+		name         string
+		size         int64
+		minChunkSize fs.SizeSuffix
+		chunkErrors  []int
+		wantLog      []string
+		wantErr      int
+	}{
+		{
+			name:         "Shrink",
+			size:         40,
+			minChunkSize: 4,
+			chunkErrors:  []int{0, 500, 502},
+			wantLog:      []string{"POST start", "bytes 0-15/40", "bytes 16-31/40", "bytes 16-31/40", "bytes 16-23/40", "bytes 24-31/40", "bytes 32-39/40"},
+		},
+		{
+			name:         "ShrinkUnknownSize",
+			size:         -1,
+			minChunkSize: 4,
+			chunkErrors:  []int{0, 500, 502},
+			wantLog:      []string{"POST start", "bytes 0-15/*", "bytes 16-31/*", "bytes 16-31/*", "bytes 16-23/*", "bytes 24-31/*", "bytes 32-39/40"},
+		},
+		{
+			name:         "ShrinkTwice",
+			size:         24,
+			minChunkSize: 4,
+			chunkErrors:  []int{500, 500, 0, 500, 500},
+			wantLog:      []string{"POST start", "bytes 0-15/24", "bytes 0-15/24", "bytes 0-7/24", "bytes 8-15/24", "bytes 8-15/24", "bytes 8-11/24", "bytes 12-15/24", "bytes 16-19/24", "bytes 20-23/24"},
+		},
+		{
+			name:         "Floor",
+			size:         24,
+			minChunkSize: 8,
+			chunkErrors:  []int{500, 500, 0, 500, 500},
+			wantLog:      []string{"POST start", "bytes 0-15/24", "bytes 0-15/24", "bytes 0-7/24", "bytes 8-15/24", "bytes 8-15/24", "bytes 8-15/24", "bytes 16-23/24"},
+		},
+		{
+			name:         "TooManyRetries",
+			size:         40,
+			minChunkSize: 4,
+			chunkErrors:  []int{500, 500, 500},
+			wantLog:      []string{"POST start", "bytes 0-15/40", "bytes 0-15/40", "bytes 0-7/40"},
+			wantErr:      500,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			driveMinChunkSize = test.minChunkSize
+			m, cleanup := newMockUpload(t)
+			defer cleanup()
+			m.chunkErrors = test.chunkErrors
+			f := m.newFs(16)
+
+			in := []byte(strings.Repeat("0123456789", 4))
+			if test.size >= 0 {
+				in = in[:test.size]
+			}
+			info, err := f.Upload(bytes.NewReader(in), test.size, "text/plain", "", &drive.File{Name: "file.txt"}, "file.txt")
+			assert.Equal(t, test.wantLog, m.log)
+			if test.wantErr != 0 {
+				require.Error(t, err)
+				assert.Equal(t, test.wantErr, errorCode(err), err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(in)), info.Size)
+			assert.Equal(t, string(in), m.received.String())
+		})
+	}
+}
+
+func TestInternalTransferChunk(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		received   int
+		start      int64
+		chunk      string
+		chunkError int
+		wantStatus int
+		wantErr    bool
+		wantID     string
+	}{
+		{name: "First", received: 0, start: 0, chunk: "0123456789", wantStatus: statusResumeIncomplete},
+		{name: "Middle", received: 10, start: 10, chunk: "0123456789", wantStatus: statusResumeIncomplete},
+		{name: "Last", received: 30, start: 30, chunk: "0123456789", wantStatus: http.StatusCreated, wantID: "ID"},
+		{name: "ServerError", received: 10, start: 10, chunk: "0123456789", chunkError: 503, wantStatus: 503, wantErr: true},
+		{name: "Expired", received: 10, start: 10, chunk: "0123456789", chunkError: 404, wantStatus: 404, wantErr: true},
+		{name: "BadRange", received: 10, start: 20, chunk: "0123456789", wantStatus: 400, wantErr: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m, cleanup := newMockUpload(t)
+			defer cleanup()
+			m.sessions = 1
+			m.received.WriteString(strings.Repeat("x", test.received))
+			if test.chunkError != 0 {
+				m.chunkErrors = []int{test.chunkError}
+			}
+			rx := &resumableUpload{
+				f:             m.newFs(16),
+				remote:        "file.txt",
+				URI:           m.sessionURI(),
+				MediaType:     "text/plain",
+				ContentLength: 40,
+			}
+			status, err := rx.transferChunk(test.start, strings.NewReader(test.chunk), int64(len(test.chunk)))
+			assert.Equal(t, test.wantStatus, status)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, test.wantStatus, errorCode(err))
+			} else {
+				require.NoError(t, err)
+			}
+			if test.wantID != "" {
+				require.NotNil(t, rx.ret)
+				assert.Equal(t, test.wantID, rx.ret.Id)
+				assert.Equal(t, int64(40), rx.ret.Size)
+			} else {
+				assert.Nil(t, rx.ret)
+			}
+		})
+	}
+}
+
+func TestInternalTransferStatus(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		received      int
+		contentLength int64
+		expired       bool
+		want          int64
+		wantErr       int
+	}{
+		{name: "Nothing", received: 0, contentLength: 40, want: 0},
+		{name: "Partial", received: 23, contentLength: 40, want: 23},
+		{name: "PartialUnknownSize", received: 23, contentLength: -1, want: 23},
+		{name: "Complete", received: 40, contentLength: 40, want: 40},
+		{name: "Expired", received: 23, contentLength: 40, expired: true, wantErr: 404},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m, cleanup := newMockUpload(t)
+			defer cleanup()
+			m.sessions = 1
+			m.expired = test.expired
+			m.received.WriteString(strings.Repeat("x", test.received))
+			rx := &resumableUpload{
+				f:             m.newFs(16),
+				remote:        "file.txt",
+				URI:           m.sessionURI(),
+				MediaType:     "text/plain",
+				ContentLength: test.contentLength,
+			}
+			start, err := rx.transferStatus()
+			if test.wantErr != 0 {
+				require.Error(t, err)
+				assert.Equal(t, test.wantErr, errorCode(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, start)
+		})
+	}
+}
+
+// Check an upload interrupted part way through a chunk can be resumed
+// from where drive says it got to
+func TestInternalTransferResume(t *testing.T) {
+	m, cleanup := newMockUpload(t)
+	defer cleanup()
+	m.chunkErrors = []int{0, 503}
+	f := m.newFs(16)
+
+	in := strings.Repeat("0123456789", 4)
+	rx := &resumableUpload{
+		f:             f,
+		remote:        "file.txt",
+		MediaType:     "text/plain",
+		ContentLength: int64(len(in)),
+	}
+	// Start the session by hand
+	req, err := http.NewRequest("POST", uploadURL, strings.NewReader(`{"name":"file.txt"}`))
+	require.NoError(t, err)
+	req.Header.Set("X-Upload-Content-Length", strconv.Itoa(len(in)))
+	res, err := f.uploadClient.Do(req)
+	require.NoError(t, err)
+	googleapi.CloseBody(res)
+	rx.URI = res.Header.Get("Location")
+
+	// First chunk OK, second interrupted
+	status, err := rx.transferChunk(0, strings.NewReader(in[:16]), 16)
+	require.NoError(t, err)
+	assert.Equal(t, statusResumeIncomplete, status)
+	status, err = rx.transferChunk(16, strings.NewReader(in[16:32]), 16)
+	require.Error(t, err)
+	assert.Equal(t, 503, status)
+
+	// Find where to resume from and send the rest
+	start, err := rx.transferStatus()
+	require.NoError(t, err)
+	assert.Equal(t, int64(16), start)
+	status, err = rx.transferChunk(start, strings.NewReader(in[start:]), int64(len(in))-start)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status)
+	require.NotNil(t, rx.ret)
+	assert.Equal(t, int64(len(in)), rx.ret.Size)
+	assert.Equal(t, in, m.received.String())
+
+	// Once complete the status is the whole file
+	start, err = rx.transferStatus()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(in)), start)
+
+	assert.Equal(t, []string{"POST start", "bytes 0-15/40", "bytes 16-31/40", "status bytes */40", "bytes 16-39/40", "status bytes */40"}, m.log)
+}
+
+// useTempResumeDB makes the upload session database in a temporary
+// directory, returning a function to remove it again
+func useTempResumeDB(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "rclone-drive-resume")
+	require.NoError(t, err)
+	oldCacheDir := config.CacheDir
+	config.CacheDir = dir
+	resumeDBOnce = sync.Once{}
+	return func() {
+		if resumeDB != nil {
+			require.NoError(t, resumeDB.Close())
+		}
+		resumeDB, resumeDBErr = nil, nil
+		resumeDBOnce = sync.Once{}
+		config.CacheDir = oldCacheDir
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+// Check an upload which drive completed without rclone reading the
+// reply can be recovered from its saved session
+func TestInternalFinalizeUpload(t *testing.T) {
+	m, cleanup := newMockUpload(t)
+	defer cleanup()
+	defer useTempResumeDB(t)()
+	f := m.newFs(16)
+	f.name, f.root = "remote", "root"
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/files/ID" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, `{"id":"ID","name":"file.txt","size":"40","md5Checksum":"md5"}`)
+	}))
+	defer files.Close()
+	svc, err := drive.New(http.DefaultClient)
+	require.NoError(t, err)
+	svc.BasePath = files.URL + "/"
+	f.svc = svc
+
+	in := strings.Repeat("0123456789", 4)
+	req, err := http.NewRequest("POST", uploadURL, strings.NewReader(`{"name":"file.txt"}`))
+	require.NoError(t, err)
+	req.Header.Set("X-Upload-Content-Length", strconv.Itoa(len(in)))
+	res, err := f.uploadClient.Do(req)
+	require.NoError(t, err)
+	googleapi.CloseBody(res)
+	uri := res.Header.Get("Location")
+	key := f.resumeKey("file.txt", "", int64(len(in)), &drive.File{})
+	require.NoError(t, resumePut(key, uri))
+	rx := &resumableUpload{
+		f:             f,
+		remote:        "file.txt",
+		URI:           uri,
+		MediaType:     "text/plain",
+		ContentLength: int64(len(in)),
+	}
+
+	// No session saved for the path
+	_, err = f.Command("finalize-upload", []string{"other.txt"})
+	assert.Error(t, err)
+
+	// Part of the upload sent keeps the session to resume
+	_, err = rx.transferChunk(0, strings.NewReader(in[:16]), 16)
+	require.NoError(t, err)
+	out, err := f.Command("finalize-upload", []string{"file.txt"})
+	require.NoError(t, err)
+	assert.Equal(t, []finalizeUploadResult{{Remote: "file.txt", Received: 16}}, out)
+	state, err := resumeGet(key)
+	require.NoError(t, err)
+	require.NotNil(t, state)
+
+	// Expired sessions are an error
+	m.expired = true
+	_, err = f.Command("finalize-upload", []string{"file.txt"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "googleapi: Error 404")
+	m.expired = false
+
+	// All of it sent but the reply lost forgets the session
+	_, err = rx.transferChunk(16, strings.NewReader(in[16:]), 24)
+	require.NoError(t, err)
+	out, err = f.Command("finalize-upload", []string{"file.txt"})
+	require.NoError(t, err)
+	assert.Equal(t, []finalizeUploadResult{{Remote: "file.txt", Complete: true, Received: 40, ID: "ID", Name: "file.txt", MD5: "md5"}}, out)
+	state, err = resumeGet(key)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+	_, err = f.Command("finalize-upload", []string{"file.txt"})
+	assert.Error(t, err)
+
+	_, err = f.Command("finalize-upload", nil)
+	assert.Error(t, err)
+}
+
+// Check an upload interrupted by rclone stopping is resumed from
+// where drive got to by the next upload of the same file
+func TestInternalResumeUpload(t *testing.T) {
+	m, cleanup := newMockUpload(t)
+	defer cleanup()
+	defer useTempResumeDB(t)()
+	*driveNoResume = false
+	f := m.newFs(16)
+
+	in := []byte(strings.Repeat("0123456789", 4))
+	upload := func(modTime string) (*drive.File, error) {
+		info := &drive.File{Name: "file.txt", ModifiedTime: modTime}
+		return f.Upload(bytes.NewReader(in), int64(len(in)), "text/plain", "", info, "file.txt")
+	}
+
+	// Interrupted after the first chunk
+	m.chunkErrors = []int{0, 503, 503, 503}
+	_, err := upload("2018-01-02T03:04:05Z")
+	require.Error(t, err)
+	assert.Equal(t, []string{"POST start", "bytes 0-15/40", "bytes 16-31/40", "bytes 16-31/40", "bytes 16-31/40"}, m.log)
+
+	// Carries on in the same session
+	m.log = nil
+	info, err := upload("2018-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"status bytes */40", "bytes 16-31/40", "bytes 32-39/40"}, m.log)
+	assert.Equal(t, 1, m.sessions)
+	assert.Equal(t, string(in), m.received.String())
+	md5sum := md5.Sum(in)
+	assert.Equal(t, hex.EncodeToString(md5sum[:]), info.Md5Checksum)
+
+	// The session is forgotten once complete
+	m.log = nil
+	_, err = upload("2018-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"POST start", "bytes 0-15/40", "bytes 16-31/40", "bytes 32-39/40"}, m.log)
+
+	// A modified file isn't resumed
+	m.chunkErrors = []int{0, 503, 503, 503}
+	_, err = upload("2018-01-02T03:04:05Z")
+	require.Error(t, err)
+	m.log = nil
+	_, err = upload("2019-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"POST start", "bytes 0-15/40", "bytes 16-31/40", "bytes 32-39/40"}, m.log)
+
+	// Expired sessions are started again
+	m.chunkErrors = []int{0, 503, 503, 503}
+	_, err = upload("2020-01-02T03:04:05Z")
+	require.Error(t, err)
+	m.expired = true
+	m.log = nil
+	_, err = upload("2020-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"expired bytes */40", "POST start", "bytes 0-15/40", "bytes 16-31/40", "bytes 32-39/40"}, m.log)
+}
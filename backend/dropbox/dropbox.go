@@ -31,6 +31,7 @@ import (
 	"time"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/async"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/common"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
@@ -57,6 +58,11 @@ const (
 	decayConstant               = 2 // bigger for slower decay, exponential
 )
 
+const (
+	maxDeleteBatch = 1000                   // max number of entries in a delete_batch
+	minBatchPoll   = 100 * time.Millisecond // initial time between polls of batch jobs
+)
+
 var (
 	// Description of how to auth for this app
 	dropboxConfig = &oauth2.Config{
@@ -1049,6 +1055,123 @@ func (o *Object) Remove() (err error) {
 	return err
 }
 
+// deleteErrorString describes a failure from a delete batch
+func deleteErrorString(e *files.DeleteError) string {
+	if e == nil {
+		return "unknown error"
+	}
+	switch {
+	case e.PathLookup != nil:
+		return e.Tag + "/" + e.PathLookup.Tag
+	case e.PathWrite != nil:
+		return e.Tag + "/" + e.PathWrite.Tag
+	}
+	return e.Tag
+}
+
+// deleteBatch deletes the paths passed in, which must be no more
+// than maxDeleteBatch, with a single delete_batch job and waits for
+// it to complete.
+//
+// It returns the error for each path or an error if the whole batch
+// failed.
+func (f *Fs) deleteBatch(paths []string) (errs []error, err error) {
+	arg := &files.DeleteBatchArg{
+		Entries: make([]*files.DeleteArg, len(paths)),
+	}
+	for i, p := range paths {
+		arg.Entries[i] = &files.DeleteArg{Path: p}
+	}
+	var launch *files.DeleteBatchLaunch
+	err = f.pacer.Call(func() (bool, error) {
+		launch, err = f.srv.DeleteBatch(arg)
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "delete batch failed")
+	}
+	result := launch.Complete
+	if launch.Tag == files.DeleteBatchLaunchAsyncJobId {
+		// Poll the job until it is finished
+		poll := async.PollArg{AsyncJobId: launch.AsyncJobId}
+		for sleep := minBatchPoll; ; {
+			var status *files.DeleteBatchJobStatus
+			err = f.pacer.Call(func() (bool, error) {
+				status, err = f.srv.DeleteBatchCheck(&poll)
+				return shouldRetry(err)
+			})
+			if err != nil {
+				return nil, errors.Wrap(err, "delete batch check failed")
+			}
+			if status.Tag == files.DeleteBatchJobStatusComplete {
+				result = status.Complete
+				break
+			}
+			if status.Tag == files.DeleteBatchJobStatusFailed {
+				reason := "unknown error"
+				if status.Failed != nil {
+					reason = status.Failed.Tag
+				}
+				return nil, errors.Errorf("delete batch failed: %s", reason)
+			}
+			time.Sleep(sleep)
+			if sleep *= 2; sleep > maxSleep {
+				sleep = maxSleep
+			}
+		}
+	}
+	if result == nil || len(result.Entries) != len(paths) {
+		return nil, errors.New("delete batch returned the wrong number of results")
+	}
+	errs = make([]error, len(paths))
+	for i, entry := range result.Entries {
+		if entry.Tag == files.DeleteBatchResultEntryFailure {
+			errs[i] = errors.Errorf("delete batch failed for %q: %s", paths[i], deleteErrorString(entry.Failure))
+		}
+	}
+	return errs, nil
+}
+
+// RemoveBatch removes the objects passed in using delete_batch jobs
+// of up to maxDeleteBatch objects each.
+//
+// It returns the error for each object, nil if it was removed.
+//
+// There is no batched Move to go with this as the SDK only has
+// move_batch (not move_batch_v2) which fails the whole batch if one
+// entry fails without saying which, and DirMove already moves whole
+// directories in one call.
+func (f *Fs) RemoveBatch(objs []fs.Object) []error {
+	errs := make([]error, len(objs))
+	for start := 0; start < len(objs); start += maxDeleteBatch {
+		end := start + maxDeleteBatch
+		if end > len(objs) {
+			end = len(objs)
+		}
+		paths := make([]string, 0, end-start)
+		for _, obj := range objs[start:end] {
+			o, ok := obj.(*Object)
+			if !ok {
+				// Shouldn't happen - paths must be in step with objs
+				o = &Object{fs: f, remote: obj.Remote()}
+			}
+			paths = append(paths, o.remotePath())
+		}
+		batchErrs, err := f.deleteBatch(paths)
+		for i := range paths {
+			if err != nil {
+				errs[start+i] = err
+			} else {
+				errs[start+i] = batchErrs[i]
+			}
+		}
+		if err != nil {
+			fs.Errorf(f, "Failed to delete batch of %d files: %v", len(paths), err)
+		}
+	}
+	return errs
+}
+
 // Check the interfaces are satisfied
 var (
 	_ fs.Fs           = (*Fs)(nil)
@@ -1059,5 +1182,6 @@ var (
 	_ fs.PublicLinker = (*Fs)(nil)
 	_ fs.DirMover     = (*Fs)(nil)
 	_ fs.Abouter      = (*Fs)(nil)
+	_ fs.BatchRemover = (*Fs)(nil)
 	_ fs.Object       = (*Object)(nil)
 )
@@ -57,6 +57,7 @@ const (
 var (
 	gcsLocation     = flags.StringP("gcs-location", "", "", "Default location for buckets (us|eu|asia|us-central1|us-east1|us-east4|us-west1|asia-east1|asia-noetheast1|asia-southeast1|australia-southeast1|europe-west1|europe-west2).")
 	gcsStorageClass = flags.StringP("gcs-storage-class", "", "", "Default storage class for buckets (MULTI_REGIONAL|REGIONAL|STANDARD|NEARLINE|COLDLINE|DURABLE_REDUCED_AVAILABILITY).")
+	gcsObjectACL    = flags.StringP("gcs-object-acl", "", "", "Predefined ACL for new objects (authenticatedRead|bucketOwnerFullControl|bucketOwnerRead|private|projectPrivate|publicRead).")
 	// Description of how to auth for this app
 	storageConfig = &oauth2.Config{
 		Scopes:       []string{storage.DevstorageFullControlScope},
@@ -362,9 +363,16 @@ func NewFs(name, root string) (fs.Fs, error) {
 		WriteMimeType: true,
 		BucketBased:   true,
 	}).Fill(f)
+	if *gcsObjectACL != "" {
+		f.objectACL = *gcsObjectACL
+	}
 	if f.objectACL == "" {
 		f.objectACL = "private"
 	}
+	err = checkObjectACL(f.objectACL)
+	if err != nil {
+		return nil, err
+	}
 	if f.bucketACL == "" {
 		f.bucketACL = "private"
 	}
@@ -748,7 +756,7 @@ func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
 	dstObject := f.root + remote
 	var newObject *storage.Object
 	err = f.pacer.Call(func() (bool, error) {
-		newObject, err = f.svc.Objects.Copy(srcBucket, srcObject, dstBucket, dstObject, nil).Do()
+		newObject, err = f.svc.Objects.Copy(srcBucket, srcObject, dstBucket, dstObject, nil).DestinationPredefinedAcl(f.objectACL).Do()
 		return shouldRetry(err)
 	})
 	if err != nil {
@@ -759,6 +767,93 @@ func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
 	return dstObj, nil
 }
 
+// objectACLs are the predefined ACLs which can be set on objects
+var objectACLs = map[string]bool{
+	"authenticatedRead":      true,
+	"bucketOwnerFullControl": true,
+	"bucketOwnerRead":        true,
+	"private":                true,
+	"projectPrivate":         true,
+	"publicRead":             true,
+}
+
+// checkObjectACL returns an error if acl isn't a predefined ACL for
+// objects
+func checkObjectACL(acl string) error {
+	if !objectACLs[acl] {
+		return errors.Errorf("google cloud storage: unknown object ACL %q", acl)
+	}
+	return nil
+}
+
+// setACLResult is returned by the setacl command
+type setACLResult struct {
+	ACL     string // the predefined ACL set
+	Objects int    // the number of objects it was set on
+}
+
+// setACL sets the predefined ACL of all the objects in f which pass
+// the filters to acl
+func (f *Fs) setACL(acl string) (*setACLResult, error) {
+	err := checkObjectACL(acl)
+	if err != nil {
+		return nil, err
+	}
+	if f.bucket == "" {
+		return nil, errors.New("need a bucket to set the ACL of its objects")
+	}
+	result := &setACLResult{ACL: acl}
+	errorCount := 0
+	err = walk.Walk(f, "", false, -1, func(dir string, entries fs.DirEntries, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			o, ok := entry.(*Object)
+			if !ok {
+				continue
+			}
+			if fs.Config.DryRun {
+				fs.Logf(o, "Not setting ACL to %q as --dry-run", acl)
+				continue
+			}
+			err = f.pacer.Call(func() (bool, error) {
+				_, err = f.svc.Objects.Patch(f.bucket, f.root+o.remote, &storage.Object{}).PredefinedAcl(acl).Do()
+				return shouldRetry(err)
+			})
+			if err != nil {
+				fs.CountError(err)
+				fs.Errorf(o, "Failed to set ACL: %v", err)
+				errorCount++
+				continue
+			}
+			fs.Infof(o, "Set ACL to %q", acl)
+			result.Objects++
+		}
+		return nil
+	})
+	if err == nil && errorCount > 0 {
+		err = errors.Errorf("failed to set ACL of %d objects", errorCount)
+	}
+	return result, err
+}
+
+// Command the backend to run a named command
+//
+// The command run is name, args may be used to read arguments from.
+// It returns a result which can be marshalled to JSON.
+func (f *Fs) Command(name string, args []string) (interface{}, error) {
+	switch name {
+	case "setacl":
+		if len(args) != 1 {
+			return nil, errors.New("need the predefined ACL to set, eg publicRead")
+		}
+		return f.setACL(args[0])
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
 // Hashes returns the supported hash sets.
 func (f *Fs) Hashes() hash.Set {
 	return hash.Set(hash.MD5)
@@ -979,6 +1074,7 @@ var (
 	_ fs.Copier      = &Fs{}
 	_ fs.PutStreamer = &Fs{}
 	_ fs.ListRer     = &Fs{}
+	_ fs.Commander   = &Fs{}
 	_ fs.Object      = &Object{}
 	_ fs.MimeTyper   = &Object{}
 )
@@ -5,6 +5,7 @@
 package http
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/url"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/config/flags"
 	"github.com/ncw/rclone/fs/fshttp"
 	"github.com/ncw/rclone/fs/hash"
 	"github.com/ncw/rclone/lib/rest"
@@ -25,6 +27,12 @@ import (
 var (
 	errorReadOnly = errors.New("http remotes are read only")
 	timeUnset     = time.Unix(0, 0)
+
+	// Flags
+	httpCACert     = flags.StringP("http-ca-cert", "", "", "Path to a PEM file of CA certificates to verify the server with.")
+	httpClientCert = flags.StringP("http-client-cert", "", "", "Path to a PEM client certificate for mutual TLS.")
+	httpClientKey  = flags.StringP("http-client-key", "", "", "Path to the PEM key for --http-client-cert.")
+	httpIndexFile  = flags.StringP("http-index-file", "", "", "Name of a JSON index file to list each directory from instead of its HTML.")
 )
 
 func init() {
@@ -40,6 +48,22 @@ func init() {
 				Value: "https://example.com",
 				Help:  "Connect to example.com",
 			}},
+		}, {
+			Name:     "ca_cert",
+			Help:     "Path to a PEM file of CA certificates to verify the server with instead of the system ones.",
+			Optional: true,
+		}, {
+			Name:     "client_cert",
+			Help:     "Path to a PEM client certificate for mutual TLS.",
+			Optional: true,
+		}, {
+			Name:     "client_key",
+			Help:     "Path to the PEM key for the client certificate.",
+			Optional: true,
+		}, {
+			Name:     "index_file",
+			Help:     "Name of a JSON index file in each directory to list it from instead of scraping its HTML, eg .rclone-index.json.",
+			Optional: true,
 		}},
 	}
 	fs.Register(fsi)
@@ -53,6 +77,7 @@ type Fs struct {
 	endpoint    *url.URL
 	endpointURL string // endpoint as a string
 	httpClient  *http.Client
+	indexFile   string // name of the JSON index file to list directories with if set
 }
 
 // Object is a remote object that has been stat'd (so it exists, but is not necessarily open for reading)
@@ -94,7 +119,14 @@ func NewFs(name, root string) (fs.Fs, error) {
 		return nil, err
 	}
 
-	client := fshttp.NewClient(fs.Config)
+	client, err := fshttp.NewClientTLS(fs.Config, config.FileGetTLSOptions(name, fshttp.TLSOptions{
+		CACert:     *httpCACert,
+		ClientCert: *httpClientCert,
+		ClientKey:  *httpClientKey,
+	}))
+	if err != nil {
+		return nil, errors.Wrap(err, "http")
+	}
 
 	var isFile = false
 	if !strings.HasSuffix(u.String(), "/") {
@@ -133,6 +165,10 @@ func NewFs(name, root string) (fs.Fs, error) {
 		httpClient:  client,
 		endpoint:    u,
 		endpointURL: u.String(),
+		indexFile:   config.FileGet(name, "index_file"),
+	}
+	if *httpIndexFile != "" {
+		f.indexFile = *httpIndexFile
 	}
 	f.features = (&fs.Features{
 		CanHaveEmptyDirectories: true,
@@ -307,6 +343,67 @@ func (f *Fs) readDir(dir string) (names []string, err error) {
 	return names, nil
 }
 
+// indexEntry is an entry in a JSON index file
+type indexEntry struct {
+	Name    string    `json:"name"`    // leaf name, ending in / for a directory
+	Size    *int64    `json:"size"`    // size in bytes if known
+	ModTime time.Time `json:"modtime"` // modification time in RFC3339 format if known
+	IsDir   bool      `json:"dir"`     // set if this is a directory
+}
+
+// readIndex reads the JSON index file for dir and turns it into
+// entries.
+//
+// The index file is a JSON array of indexEntry.  It returns
+// fs.ErrorObjectNotFound if the index file doesn't exist so the
+// caller can read the HTML instead.
+func (f *Fs) readIndex(dir string) (entries fs.DirEntries, err error) {
+	URL := f.url(dir) + rest.URLPathEscape(f.indexFile)
+	res, err := f.httpClient.Get(URL)
+	if err == nil && res.StatusCode == http.StatusNotFound {
+		_ = res.Body.Close()
+		return nil, fs.ErrorObjectNotFound
+	}
+	err = statusError(res, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read index")
+	}
+	defer fs.CheckClose(res.Body, &err)
+	var index []indexEntry
+	err = json.NewDecoder(res.Body).Decode(&index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse index %q", URL)
+	}
+	for _, item := range index {
+		isDir := item.IsDir || strings.HasSuffix(item.Name, "/")
+		name := strings.TrimSuffix(item.Name, "/")
+		if name == "" || name == "." || name == ".." || strings.Contains(name, "/") || name == f.indexFile {
+			fs.Debugf(dir, "skipping %q from index", item.Name)
+			continue
+		}
+		remote := path.Join(dir, name)
+		modTime := item.ModTime
+		if modTime.IsZero() {
+			modTime = timeUnset
+		}
+		if isDir {
+			entries = append(entries, fs.NewDir(remote, modTime))
+			continue
+		}
+		o := &Object{
+			fs:      f,
+			remote:  remote,
+			size:    -1,
+			modTime: modTime,
+		}
+		if item.Size != nil {
+			o.size = *item.Size
+		}
+		entries = append(entries, o)
+	}
+	return entries, nil
+}
+
 // List the objects and directories in dir into entries.  The
 // entries can be returned in any order but should be for a
 // complete directory.
@@ -320,6 +417,16 @@ func (f *Fs) List(dir string) (entries fs.DirEntries, err error) {
 	if !strings.HasSuffix(dir, "/") && dir != "" {
 		dir += "/"
 	}
+	if f.indexFile != "" {
+		entries, err = f.readIndex(dir)
+		if err != fs.ErrorObjectNotFound {
+			if err != nil {
+				return nil, errors.Wrapf(err, "error listing %q", dir)
+			}
+			return entries, nil
+		}
+		fs.Debugf(dir, "no index file %q - reading the HTML instead", f.indexFile)
+	}
 	names, err := f.readDir(dir)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error listing %q", dir)
@@ -207,6 +207,75 @@ func TestIsAFileSubDir(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func TestListIndexFile(t *testing.T) {
+	fileServer := http.FileServer(http.Dir(filesPath))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.rclone-index.json":
+			_, _ = fmt.Fprint(w, `[
+	{"name": "file.txt", "size": 5, "modtime": "2019-01-02T03:04:05Z"},
+	{"name": "nosize.txt"},
+	{"name": "dir/", "modtime": "2019-01-02T03:04:05Z"},
+	{"name": "dir2", "dir": true},
+	{"name": "../escape"},
+	{"name": ".rclone-index.json"}
+]`)
+		case "/four/.rclone-index.json":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			fileServer.ServeHTTP(w, r)
+		}
+	}))
+	defer ts.Close()
+	config.LoadConfig()
+	config.FileSet(remoteName, "type", "http")
+	config.FileSet(remoteName, "url", ts.URL)
+	config.FileSet(remoteName, "index_file", ".rclone-index.json")
+	defer config.FileSet(remoteName, "index_file", "")
+
+	f, err := NewFs(remoteName, "")
+	require.NoError(t, err)
+
+	// The root is listed from the index
+	entries, err := f.List("")
+	require.NoError(t, err)
+	sort.Sort(entries)
+	require.Equal(t, 4, len(entries))
+	modTime := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	_, ok := entries[0].(fs.Directory)
+	assert.True(t, ok)
+	assert.Equal(t, "dir", entries[0].Remote())
+	assert.True(t, modTime.Equal(entries[0].ModTime()))
+
+	_, ok = entries[1].(fs.Directory)
+	assert.True(t, ok)
+	assert.Equal(t, "dir2", entries[1].Remote())
+	assert.Equal(t, timeUnset, entries[1].ModTime())
+
+	_, ok = entries[2].(*Object)
+	assert.True(t, ok)
+	assert.Equal(t, "file.txt", entries[2].Remote())
+	assert.Equal(t, int64(5), entries[2].Size())
+	assert.True(t, modTime.Equal(entries[2].ModTime()))
+
+	assert.Equal(t, "nosize.txt", entries[3].Remote())
+	assert.Equal(t, int64(-1), entries[3].Size())
+	assert.Equal(t, timeUnset, entries[3].ModTime())
+
+	// Directories without an index are read from the HTML
+	entries, err = f.List("three")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	assert.Equal(t, "three/underthree.txt", entries[0].Remote())
+	assert.Equal(t, int64(9), entries[0].Size())
+
+	// Errors reading the index are returned
+	_, err = f.List("four")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read index")
+}
+
 func TestParseName(t *testing.T) {
 	for i, test := range []struct {
 		base    string
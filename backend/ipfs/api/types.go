@@ -0,0 +1,80 @@
+// Package api has type definitions for the IPFS HTTP API
+//
+// Docs: https://docs.ipfs.io/reference/api/http/
+package api
+
+import "strings"
+
+// Types of entries returned by files/ls
+const (
+	TypeFile      = 0
+	TypeDirectory = 1
+)
+
+// Error is returned from the API when things go wrong
+type Error struct {
+	Message string `json:"Message"`
+	Code    int    `json:"Code"`
+	Type    string `json:"Type"`
+}
+
+// Error returns a string for the error and satisfies the error interface
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NotFound returns true if the error says the path doesn't exist
+func (e *Error) NotFound() bool {
+	return strings.Contains(e.Message, "does not exist") || strings.Contains(e.Message, "no link named")
+}
+
+// Check Error satisfies the error interface
+var _ error = (*Error)(nil)
+
+// Entry is a file or directory returned by files/ls
+type Entry struct {
+	Name string `json:"Name"`
+	Type int    `json:"Type"` // TypeFile or TypeDirectory
+	Size int64  `json:"Size"`
+	Hash string `json:"Hash"` // the CID of the entry
+}
+
+// ListResponse is returned by files/ls
+type ListResponse struct {
+	Entries []Entry `json:"Entries"`
+}
+
+// Stat is returned by files/stat
+type Stat struct {
+	Hash           string `json:"Hash"` // the CID of the file or directory
+	Size           int64  `json:"Size"`
+	CumulativeSize int64  `json:"CumulativeSize"`
+	Blocks         int    `json:"Blocks"`
+	Type           string `json:"Type"`  // "file" or "directory"
+	Mtime          int64  `json:"Mtime"` // modification time in unix seconds if the daemon keeps it
+}
+
+// IsDir returns true if the Stat is of a directory
+func (s *Stat) IsDir() bool {
+	return s.Type == "directory"
+}
+
+// AddResponse is returned by add
+type AddResponse struct {
+	Name string `json:"Name"`
+	Hash string `json:"Hash"` // the CID of the content added
+	Size string `json:"Size"` // size of the DAG made, not of the content
+}
+
+// RepoStat is returned by repo/stat
+type RepoStat struct {
+	RepoSize   int64 `json:"RepoSize"`
+	StorageMax int64 `json:"StorageMax"`
+	NumObjects int64 `json:"NumObjects"`
+}
+
+// PublishResponse is returned by name/publish
+type PublishResponse struct {
+	Name  string `json:"Name"`  // the IPNS name published to
+	Value string `json:"Value"` // the path published
+}
@@ -0,0 +1,783 @@
+// Package ipfs provides an interface to IPFS through the HTTP API of
+// an IPFS daemon.
+//
+// The directory tree is kept in the daemon's mutable file system
+// (MFS).  Files are added to IPFS and then linked into the tree by
+// their CID, which is used as the ID of each object.
+package ipfs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/backend/ipfs/api"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/config/flags"
+	"github.com/ncw/rclone/fs/fserrors"
+	"github.com/ncw/rclone/fs/fshttp"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/lib/pacer"
+	"github.com/ncw/rclone/lib/rest"
+	"github.com/pkg/errors"
+)
+
+const (
+	minSleep      = 10 * time.Millisecond
+	maxSleep      = 2 * time.Second
+	decayConstant = 2 // bigger for slower decay, exponential
+	defaultAPIURL = "http://127.0.0.1:5001"
+)
+
+// Globals
+var (
+	timeUnset = time.Unix(0, 0)
+
+	// Flags
+	ipfsAPIURL     = flags.StringP("ipfs-api-url", "", "", "URL of the HTTP API of the IPFS daemon, eg "+defaultAPIURL+".")
+	ipfsGatewayURL = flags.StringP("ipfs-gateway-url", "", "", "URL of an IPFS gateway to read files from if the daemon can't, eg https://ipfs.io.")
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "ipfs",
+		Description: "IPFS",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name:     "api_url",
+			Help:     "URL of the HTTP API of the IPFS daemon - leave blank for the local daemon.",
+			Optional: true,
+			Examples: []fs.OptionExample{{
+				Value: defaultAPIURL,
+				Help:  "Connect to the daemon on this machine",
+			}},
+		}, {
+			Name:     "gateway_url",
+			Help:     "URL of an IPFS gateway to read files from if the daemon can't - leave blank for none.",
+			Optional: true,
+			Examples: []fs.OptionExample{{
+				Value: "https://ipfs.io",
+				Help:  "Read from the public gateway run by Protocol Labs",
+			}},
+		}},
+	})
+}
+
+// Fs represents a directory tree in the MFS of an IPFS daemon
+type Fs struct {
+	name     string       // name of this remote
+	root     string       // the path we are working on
+	features *fs.Features // optional features
+	apiURL   string       // URL of the daemon's API
+	srv      *rest.Client // the connection to the daemon
+	gateway  *rest.Client // the connection to the gateway or nil if not set
+	pacer    *pacer.Pacer // pacer for API calls
+}
+
+// Object describes a file in IPFS
+//
+// As files are immutable, changing one links a new CID into the tree
+// in place of the old one.
+type Object struct {
+	fs      *Fs       // what this object is part of
+	remote  string    // The remote path
+	size    int64     // size of the object
+	modTime time.Time // modification time of the object if known
+	cid     string    // CID of the content
+}
+
+// ------------------------------------------------------------
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("IPFS root '%s'", f.root)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// retryErrorCodes is a slice of error codes that we will retry
+//
+// The daemon returns 500 for every error, including paths which don't
+// exist, so that isn't retried.
+var retryErrorCodes = []int{
+	429, // Too Many Requests.
+	502, // Bad Gateway
+	503, // Service Unavailable
+	504, // Gateway Timeout
+}
+
+// shouldRetry returns a boolean as to whether this resp and err
+// deserve to be retried.  It returns the err as a convenience
+func shouldRetry(resp *http.Response, err error) (bool, error) {
+	return fserrors.ShouldRetry(err) || fserrors.ShouldRetryHTTP(resp, retryErrorCodes), err
+}
+
+// errorHandler parses a non 2xx error response into an error
+func errorHandler(resp *http.Response) error {
+	// Decode error response
+	errResponse := new(api.Error)
+	err := rest.DecodeJSON(resp, &errResponse)
+	if err != nil {
+		fs.Debugf(nil, "Couldn't decode error response: %v", err)
+	}
+	if errResponse.Message == "" {
+		errResponse.Message = resp.Status
+	}
+	return errResponse
+}
+
+// isNotFound returns true if err says the path doesn't exist
+func isNotFound(err error) bool {
+	apiErr, ok := errors.Cause(err).(*api.Error)
+	return ok && apiErr.NotFound()
+}
+
+// mfsPath returns the path in the MFS of remote
+func (f *Fs) mfsPath(remote string) string {
+	return "/" + path.Join(f.root, remote)
+}
+
+// call makes a call to the API, decoding the result into response if
+// it isn't nil
+//
+// All calls to the API are POSTs with the arguments as parameters.
+func (f *Fs) call(endpoint string, params url.Values, response interface{}) error {
+	opts := rest.Opts{
+		Method:     "POST",
+		Path:       endpoint,
+		Parameters: params,
+		NoResponse: response == nil,
+	}
+	return f.pacer.Call(func() (bool, error) {
+		resp, err := f.srv.CallJSON(&opts, nil, response)
+		return shouldRetry(resp, err)
+	})
+}
+
+// stat reads the info about remote
+func (f *Fs) stat(remote string) (info *api.Stat, err error) {
+	info = new(api.Stat)
+	err = f.call("/files/stat", url.Values{"arg": {f.mfsPath(remote)}}, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// NewFs constructs an Fs from the path
+func NewFs(name, root string) (fs.Fs, error) {
+	apiURL := config.FileGet(name, "api_url", defaultAPIURL)
+	if *ipfsAPIURL != "" {
+		apiURL = *ipfsAPIURL
+	}
+	gatewayURL := config.FileGet(name, "gateway_url")
+	if *ipfsGatewayURL != "" {
+		gatewayURL = *ipfsGatewayURL
+	}
+	apiURL = strings.TrimRight(apiURL, "/")
+	client := fshttp.NewClient(fs.Config)
+	f := &Fs{
+		name:   name,
+		root:   strings.Trim(root, "/"),
+		apiURL: apiURL,
+		srv:    rest.NewClient(client).SetRoot(apiURL + "/api/v0"),
+		pacer:  pacer.New().SetMinSleep(minSleep).SetMaxSleep(maxSleep).SetDecayConstant(decayConstant),
+	}
+	f.srv.SetErrorHandler(errorHandler)
+	if gatewayURL != "" {
+		f.gateway = rest.NewClient(client).SetRoot(strings.TrimRight(gatewayURL, "/"))
+	}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(f)
+
+	if f.root != "" {
+		// Check to see if the root actually an existing file
+		info, err := f.stat("")
+		if err == nil && !info.IsDir() {
+			f.root = path.Dir(f.root)
+			if f.root == "." {
+				f.root = ""
+			}
+			// return an error with an fs which points to the parent
+			return f, fs.ErrorIsFile
+		}
+		if err != nil && !isNotFound(err) {
+			return nil, errors.Wrap(err, "failed to read root")
+		}
+	}
+	return f, nil
+}
+
+// newObjectWithInfo makes an Object from remote and info
+func (f *Fs) newObjectWithInfo(remote string, info *api.Stat) *Object {
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	o.setMetaData(info)
+	return o
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(remote string) (fs.Object, error) {
+	info, err := f.stat(remote)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fs.ErrorObjectNotFound
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fs.ErrorNotAFile
+	}
+	return f.newObjectWithInfo(remote, info), nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(dir string) (entries fs.DirEntries, err error) {
+	var result api.ListResponse
+	err = f.call("/files/ls", url.Values{
+		"arg":  {f.mfsPath(dir)},
+		"long": {"true"},
+	}, &result)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fs.ErrorDirNotFound
+		}
+		return nil, errors.Wrap(err, "couldn't list directory")
+	}
+	for _, entry := range result.Entries {
+		remote := path.Join(dir, entry.Name)
+		if entry.Type == api.TypeDirectory {
+			d := fs.NewDir(remote, timeUnset).SetID(entry.Hash)
+			entries = append(entries, d)
+		} else {
+			o := &Object{
+				fs:      f,
+				remote:  remote,
+				size:    entry.Size,
+				modTime: timeUnset,
+				cid:     entry.Hash,
+			}
+			entries = append(entries, o)
+		}
+	}
+	return entries, nil
+}
+
+// Put the object into the remote
+//
+// Copy the reader in to the new object which is returned
+//
+// The new object may have been created if an error is returned
+func (f *Fs) Put(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o := &Object{
+		fs:     f,
+		remote: src.Remote(),
+	}
+	return o, o.Update(in, src, options...)
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+//
+// IPFS only knows the size of the content once it has been added, so
+// this is the same as Put.
+func (f *Fs) PutStream(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(in, src, options...)
+}
+
+// mkdir makes the directory at mfsPath and any parents it needs
+func (f *Fs) mkdir(mfsPath string) error {
+	if mfsPath == "/" {
+		return nil
+	}
+	return f.call("/files/mkdir", url.Values{
+		"arg":     {mfsPath},
+		"parents": {"true"},
+	}, nil)
+}
+
+// mkParentDir makes the directory which remote is in
+func (f *Fs) mkParentDir(remote string) error {
+	return f.mkdir(path.Dir(f.mfsPath(remote)))
+}
+
+// remove removes mfsPath, and everything in it if recursive is set
+func (f *Fs) remove(mfsPath string, recursive bool) error {
+	return f.call("/files/rm", url.Values{
+		"arg":       {mfsPath},
+		"recursive": {strconv.FormatBool(recursive)},
+	}, nil)
+}
+
+// replace puts new content at mfsPath in place of whatever is there
+// already
+//
+// Content can't be changed so any old version must be removed to make
+// room for the new one.  So that a failure doesn't lose the old
+// version, put is called to put the new content at a temporary path
+// first, then the old version is removed and the new one is moved into
+// place.  If the old version can't be removed undo is called to take
+// the new content away from the temporary path again.
+func (f *Fs) replace(mfsPath string, put, undo func(tmpPath string) error) error {
+	var random [4]byte
+	_, err := rand.Read(random[:])
+	if err != nil {
+		return errors.Wrap(err, "failed to make temporary name")
+	}
+	tmpPath := mfsPath + ".rclone-" + hex.EncodeToString(random[:]) + ".partial"
+	err = put(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = f.remove(mfsPath, false)
+	if err != nil && !isNotFound(err) {
+		if undoErr := undo(tmpPath); undoErr != nil {
+			fs.Errorf(f, "Failed to tidy up %q: %v", tmpPath, undoErr)
+		}
+		return errors.Wrap(err, "failed to remove old version")
+	}
+	err = f.call("/files/mv", url.Values{"arg": {tmpPath, mfsPath}}, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to move new version into place - it is in %q", tmpPath)
+	}
+	return nil
+}
+
+// link puts the content with cid at remote replacing whatever is
+// there already
+func (f *Fs) link(cid string, remote string) error {
+	err := f.mkParentDir(remote)
+	if err != nil {
+		return errors.Wrap(err, "failed to make parent directory")
+	}
+	return f.replace(f.mfsPath(remote), func(tmpPath string) error {
+		return f.call("/files/cp", url.Values{"arg": {"/ipfs/" + cid, tmpPath}}, nil)
+	}, func(tmpPath string) error {
+		return f.remove(tmpPath, false)
+	})
+}
+
+// Mkdir creates the directory if it doesn't exist
+func (f *Fs) Mkdir(dir string) error {
+	return f.mkdir(f.mfsPath(dir))
+}
+
+// Rmdir deletes the directory
+//
+// Returns an error if it isn't empty
+func (f *Fs) Rmdir(dir string) error {
+	entries, err := f.List(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 0 {
+		return fs.ErrorDirectoryNotEmpty
+	}
+	mfsPath := f.mfsPath(dir)
+	if mfsPath == "/" {
+		// The root of the MFS always exists
+		return nil
+	}
+	return f.remove(mfsPath, true)
+}
+
+// Precision of the ModTimes in this Fs
+func (f *Fs) Precision() time.Duration {
+	return fs.ModTimeNotSupported
+}
+
+// Copy src to this remote using server side copy operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok || srcObj.fs.apiURL != f.apiURL {
+		fs.Debugf(src, "Can't copy - not same remote type")
+		return nil, fs.ErrorCantCopy
+	}
+	// The content is already in IPFS so only needs linking
+	err := f.link(srcObj.cid, remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "copy failed")
+	}
+	return f.NewObject(remote)
+}
+
+// Move src to this remote using server side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok || srcObj.fs.apiURL != f.apiURL {
+		fs.Debugf(src, "Can't move - not same remote type")
+		return nil, fs.ErrorCantMove
+	}
+	err := f.mkParentDir(remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make parent directory")
+	}
+	srcPath := srcObj.fs.mfsPath(srcObj.remote)
+	err = f.replace(f.mfsPath(remote), func(tmpPath string) error {
+		return f.call("/files/mv", url.Values{"arg": {srcPath, tmpPath}}, nil)
+	}, func(tmpPath string) error {
+		return f.call("/files/mv", url.Values{"arg": {tmpPath, srcPath}}, nil)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "move failed")
+	}
+	return f.NewObject(remote)
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(src fs.Fs, srcRemote, dstRemote string) error {
+	srcFs, ok := src.(*Fs)
+	if !ok || srcFs.apiURL != f.apiURL {
+		fs.Debugf(srcFs, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	dstPath := f.mfsPath(dstRemote)
+	if dstPath == "/" {
+		return fs.ErrorDirExists
+	}
+	_, err := f.stat(dstRemote)
+	if err == nil {
+		return fs.ErrorDirExists
+	} else if !isNotFound(err) {
+		return err
+	}
+	err = f.mkParentDir(dstRemote)
+	if err != nil {
+		return errors.Wrap(err, "failed to make parent directory")
+	}
+	err = f.call("/files/mv", url.Values{"arg": {srcFs.mfsPath(srcRemote), dstPath}}, nil)
+	if err != nil {
+		return errors.Wrap(err, "move directory failed")
+	}
+	return nil
+}
+
+// Purge deletes all the files and the container
+//
+// Optional interface: Only implement this if you have a way of
+// deleting all the files quicker than just running Remove() on the
+// result of List()
+func (f *Fs) Purge() error {
+	mfsPath := f.mfsPath("")
+	if mfsPath != "/" {
+		return f.remove(mfsPath, true)
+	}
+	// The root of the MFS can't be removed so remove what is in it
+	entries, err := f.List("")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err = f.remove(f.mfsPath(entry.Remote()), true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// About gets quota information
+func (f *Fs) About() (usage *fs.Usage, err error) {
+	var q api.RepoStat
+	err = f.call("/repo/stat", nil, &q)
+	if err != nil {
+		return nil, errors.Wrap(err, "about failed")
+	}
+	usage = &fs.Usage{
+		Used:    fs.NewUsageValue(q.RepoSize),   // bytes in use
+		Objects: fs.NewUsageValue(q.NumObjects), // objects in the repo
+	}
+	if q.StorageMax > 0 {
+		usage.Total = fs.NewUsageValue(q.StorageMax)             // quota of bytes that can be used
+		usage.Free = fs.NewUsageValue(q.StorageMax - q.RepoSize) // bytes which can be uploaded before reaching the quota
+	}
+	return usage, nil
+}
+
+// publish publishes the CID of the root to IPNS under key, or the
+// daemon's own key if key is empty
+func (f *Fs) publish(key string) (*api.PublishResponse, error) {
+	info, err := f.stat("")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read root")
+	}
+	params := url.Values{"arg": {"/ipfs/" + info.Hash}}
+	if key != "" {
+		params.Set("key", key)
+	}
+	result := new(api.PublishResponse)
+	err = f.call("/name/publish", params, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "publish failed")
+	}
+	return result, nil
+}
+
+// Command the backend to run a named command
+//
+// The command run is name, args may be used to read arguments from.
+// It returns a result which can be marshalled to JSON.
+func (f *Fs) Command(name string, args []string) (interface{}, error) {
+	switch name {
+	case "publish":
+		if len(args) > 1 {
+			return nil, errors.New("need at most one argument, the name of the key to publish with")
+		}
+		key := ""
+		if len(args) == 1 {
+			key = args[0]
+		}
+		return f.publish(key)
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(hash.None)
+}
+
+// ------------------------------------------------------------
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Hash returns the hash of an object returning a lowercase hex string
+//
+// IPFS identifies content by CID rather than by any of the supported
+// hashes - use ID to read it.
+func (o *Object) Hash(t hash.Type) (string, error) {
+	return "", hash.ErrUnsupported
+}
+
+// ID returns the CID of the Object
+func (o *Object) ID() string {
+	return o.cid
+}
+
+// Size returns the size of an object in bytes
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// setMetaData sets the metadata from info
+func (o *Object) setMetaData(info *api.Stat) {
+	o.size = info.Size
+	o.cid = info.Hash
+	o.modTime = timeUnset
+	if info.Mtime > 0 {
+		o.modTime = time.Unix(info.Mtime, 0)
+	}
+}
+
+// ModTime returns the modification time of the object
+//
+// The daemon only keeps modification times of files added with them,
+// so this is usually unset.
+func (o *Object) ModTime() time.Time {
+	return o.modTime
+}
+
+// SetModTime sets the modification time of the local fs object
+func (o *Object) SetModTime(modTime time.Time) error {
+	return fs.ErrorCantSetModTime
+}
+
+// Storable returns a boolean showing whether this object storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Open an object for read
+//
+// The content is read by CID from the daemon, or from the gateway if
+// the daemon can't read it and one is configured.
+func (o *Object) Open(options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	fs.FixRangeOption(options, o.size)
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.size)
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	params := url.Values{"arg": {o.cid}}
+	if offset > 0 {
+		params.Set("offset", strconv.FormatInt(offset, 10))
+	}
+	if limit >= 0 {
+		params.Set("length", strconv.FormatInt(limit, 10))
+	}
+	opts := rest.Opts{
+		Method:     "POST",
+		Path:       "/cat",
+		Parameters: params,
+	}
+	var resp *http.Response
+	err = o.fs.pacer.Call(func() (bool, error) {
+		resp, err = o.fs.srv.Call(&opts)
+		return shouldRetry(resp, err)
+	})
+	if err != nil && o.fs.gateway != nil {
+		fs.Debugf(o, "Reading from gateway as the daemon failed: %v", err)
+		return o.openGateway(options)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file")
+	}
+	return resp.Body, nil
+}
+
+// openGateway opens the object for read from the gateway
+func (o *Object) openGateway(options []fs.OpenOption) (in io.ReadCloser, err error) {
+	opts := rest.Opts{
+		Method:  "GET",
+		Path:    "/ipfs/" + o.cid,
+		Options: options,
+	}
+	var resp *http.Response
+	err = o.fs.pacer.Call(func() (bool, error) {
+		resp, err = o.fs.gateway.Call(&opts)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file from gateway")
+	}
+	return resp.Body, nil
+}
+
+// Update the object with the contents of the io.Reader, modTime and size
+//
+// The content is added to IPFS which gives it a new CID, then that is
+// linked into the tree in place of the old content.  The size is only
+// known once the content has been added.
+func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+	var added api.AddResponse
+	opts := rest.Opts{
+		Method:               "POST",
+		Path:                 "/add",
+		Body:                 in,
+		MultipartParams:      url.Values{},
+		MultipartContentName: "file",
+		MultipartFileName:    path.Base(o.remote),
+		Parameters: url.Values{
+			"pin":     {"false"},
+			"quieter": {"true"},
+		},
+	}
+	var resp *http.Response
+	err = o.fs.pacer.CallNoRetry(func() (bool, error) {
+		resp, err = o.fs.srv.CallJSON(&opts, nil, &added)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to add file")
+	}
+	err = o.fs.link(added.Hash, o.remote)
+	if err != nil {
+		return errors.Wrap(err, "failed to link file")
+	}
+	info, err := o.fs.stat(o.remote)
+	if err != nil {
+		return errors.Wrap(err, "failed to read file after upload")
+	}
+	o.setMetaData(info)
+	return nil
+}
+
+// Remove an object
+func (o *Object) Remove() error {
+	return o.fs.remove(o.fs.mfsPath(o.remote), false)
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs          = (*Fs)(nil)
+	_ fs.Purger      = (*Fs)(nil)
+	_ fs.PutStreamer = (*Fs)(nil)
+	_ fs.Copier      = (*Fs)(nil)
+	_ fs.Mover       = (*Fs)(nil)
+	_ fs.DirMover    = (*Fs)(nil)
+	_ fs.Abouter     = (*Fs)(nil)
+	_ fs.Commander   = (*Fs)(nil)
+	_ fs.Object      = (*Object)(nil)
+	_ fs.IDer        = (*Object)(nil)
+)
@@ -0,0 +1,389 @@
+package ipfs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/backend/ipfs/api"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockDaemon is enough of the API of an IPFS daemon to test with
+type mockDaemon struct {
+	mu       sync.Mutex
+	dirs     map[string]bool   // directories in the MFS
+	files    map[string]string // files in the MFS to their CIDs
+	blocks   map[string][]byte // content by CID
+	catFails bool              // set to make cat fail
+	rmFails  bool              // set to make rm fail
+}
+
+func newMockDaemon() *mockDaemon {
+	return &mockDaemon{
+		dirs:   map[string]bool{"/": true},
+		files:  map[string]string{},
+		blocks: map[string][]byte{},
+	}
+}
+
+// fail returns an error in the same way the daemon does
+func fail(w http.ResponseWriter, message string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(api.Error{Message: message, Type: "error"})
+}
+
+func (d *mockDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	args := r.URL.Query()["arg"]
+	var result interface{}
+	switch strings.TrimPrefix(r.URL.Path, "/api/v0") {
+	case "/files/stat":
+		if d.dirs[args[0]] {
+			result = api.Stat{Hash: "dir" + args[0], Type: "directory"}
+		} else if cid, ok := d.files[args[0]]; ok {
+			result = api.Stat{Hash: cid, Size: int64(len(d.blocks[cid])), Type: "file"}
+		} else {
+			fail(w, "file does not exist")
+			return
+		}
+	case "/files/ls":
+		if !d.dirs[args[0]] {
+			fail(w, "file does not exist")
+			return
+		}
+		var list api.ListResponse
+		for p := range d.dirs {
+			if p != args[0] && path.Dir(p) == args[0] {
+				list.Entries = append(list.Entries, api.Entry{Name: path.Base(p), Type: api.TypeDirectory, Hash: "dir" + p})
+			}
+		}
+		for p, cid := range d.files {
+			if path.Dir(p) == args[0] {
+				list.Entries = append(list.Entries, api.Entry{Name: path.Base(p), Size: int64(len(d.blocks[cid])), Hash: cid})
+			}
+		}
+		result = list
+	case "/files/mkdir":
+		for p := args[0]; p != "/"; p = path.Dir(p) {
+			d.dirs[p] = true
+		}
+	case "/files/rm":
+		p := args[0]
+		switch {
+		case d.rmFails:
+			fail(w, "failed to remove")
+			return
+		case d.files[p] != "":
+			delete(d.files, p)
+		case d.dirs[p] && r.URL.Query().Get("recursive") == "true":
+			for q := range d.dirs {
+				if q == p || strings.HasPrefix(q, p+"/") {
+					delete(d.dirs, q)
+				}
+			}
+			for q := range d.files {
+				if strings.HasPrefix(q, p+"/") {
+					delete(d.files, q)
+				}
+			}
+		case d.dirs[p]:
+			fail(w, p+" is a directory, use -r to remove directories")
+			return
+		default:
+			fail(w, "file does not exist")
+			return
+		}
+	case "/files/cp":
+		cid := strings.TrimPrefix(args[0], "/ipfs/")
+		if d.blocks[cid] == nil || !d.dirs[path.Dir(args[1])] {
+			fail(w, "file does not exist")
+			return
+		}
+		if d.dirs[args[1]] || d.files[args[1]] != "" {
+			fail(w, "directory already has entry by that name")
+			return
+		}
+		d.files[args[1]] = cid
+	case "/files/mv":
+		src, dst := args[0], args[1]
+		if d.dirs[dst] || d.files[dst] != "" {
+			fail(w, "directory already has entry by that name")
+			return
+		}
+		if cid, ok := d.files[src]; ok {
+			delete(d.files, src)
+			d.files[dst] = cid
+		} else if d.dirs[src] {
+			for q := range d.dirs {
+				if q == src || strings.HasPrefix(q, src+"/") {
+					delete(d.dirs, q)
+					d.dirs[dst+strings.TrimPrefix(q, src)] = true
+				}
+			}
+			for q, cid := range d.files {
+				if strings.HasPrefix(q, src+"/") {
+					delete(d.files, q)
+					d.files[dst+strings.TrimPrefix(q, src)] = cid
+				}
+			}
+		} else {
+			fail(w, "file does not exist")
+			return
+		}
+	case "/add":
+		in, header, err := r.FormFile("file")
+		if err != nil {
+			fail(w, err.Error())
+			return
+		}
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			fail(w, err.Error())
+			return
+		}
+		cid := fmt.Sprintf("Qm%x", md5.Sum(data))
+		d.blocks[cid] = data
+		result = api.AddResponse{Name: header.Filename, Hash: cid, Size: strconv.Itoa(len(data) + 10)}
+	case "/cat":
+		data, ok := d.blocks[args[0]]
+		if d.catFails || !ok {
+			fail(w, "failed to fetch block")
+			return
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		data = data[offset:]
+		if length := r.URL.Query().Get("length"); length != "" {
+			n, _ := strconv.Atoi(length)
+			data = data[:n]
+		}
+		_, _ = w.Write(data)
+		return
+	case "/repo/stat":
+		var stat api.RepoStat
+		for _, data := range d.blocks {
+			stat.RepoSize += int64(len(data))
+			stat.NumObjects++
+		}
+		result = stat
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if result != nil {
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
+
+// prepare makes a daemon and a gateway to test with and returns the
+// daemon and a function to tidy up afterwards
+func prepare(t *testing.T, name string) (*mockDaemon, func()) {
+	d := newMockDaemon()
+	ts := httptest.NewServer(d)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		data, ok := d.blocks[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		d.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	config.FileSet(name, "type", "ipfs")
+	config.FileSet(name, "api_url", ts.URL)
+	config.FileSet(name, "gateway_url", gateway.URL)
+	return d, func() {
+		ts.Close()
+		gateway.Close()
+	}
+}
+
+// readObject reads the contents of o with the options given
+func readObject(t *testing.T, o fs.Object, options ...fs.OpenOption) string {
+	in, err := o.Open(options...)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	return string(data)
+}
+
+func TestPutListOpen(t *testing.T) {
+	_, tidy := prepare(t, "TestIPFSInternal")
+	defer tidy()
+	f, err := NewFs("TestIPFSInternal", "root")
+	require.NoError(t, err)
+
+	contents := "hello, content addressed world"
+	src := object.NewStaticObjectInfo("dir/file.txt", time.Now(), -1, true, nil, nil)
+	o, err := f.Put(strings.NewReader(contents), src)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), o.Size())
+	cid := o.(fs.IDer).ID()
+	assert.Equal(t, fmt.Sprintf("Qm%x", md5.Sum([]byte(contents))), cid)
+	assert.Equal(t, timeUnset, o.ModTime())
+
+	entries, err := f.List("")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	_, ok := entries[0].(fs.Directory)
+	assert.True(t, ok)
+	assert.Equal(t, "dir", entries[0].Remote())
+
+	entries, err = f.List("dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "dir/file.txt", entries[0].Remote())
+	assert.Equal(t, int64(len(contents)), entries[0].Size())
+	assert.Equal(t, cid, entries[0].(*Object).ID())
+
+	_, err = f.List("notfound")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+
+	assert.Equal(t, contents, readObject(t, o))
+	assert.Equal(t, "content", readObject(t, o, &fs.RangeOption{Start: 7, End: 13}))
+	assert.Equal(t, "world", readObject(t, o, &fs.RangeOption{Start: -1, End: 5}))
+
+	// Updating the file links in the new CID
+	updated := "new contents"
+	err = o.Update(strings.NewReader(updated), src)
+	require.NoError(t, err)
+	assert.NotEqual(t, cid, o.(fs.IDer).ID())
+	assert.Equal(t, int64(len(updated)), o.Size())
+	o, err = f.NewObject("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, updated, readObject(t, o))
+
+	// A root pointing at the file is its parent
+	f2, err := NewFs("TestIPFSInternal", "root/dir/file.txt")
+	assert.Equal(t, fs.ErrorIsFile, err)
+	assert.Equal(t, "root/dir", f2.Root())
+
+	_, err = f.NewObject("dir")
+	assert.Equal(t, fs.ErrorNotAFile, err)
+	_, err = f.NewObject("notfound")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+
+	assert.Equal(t, fs.ErrorDirectoryNotEmpty, f.Rmdir("dir"))
+	require.NoError(t, o.Remove())
+	require.NoError(t, f.Rmdir("dir"))
+	entries, err = f.List("")
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestOpenGateway(t *testing.T) {
+	d, tidy := prepare(t, "TestIPFSGateway")
+	defer tidy()
+	f, err := NewFs("TestIPFSGateway", "")
+	require.NoError(t, err)
+
+	contents := "read from the gateway"
+	src := object.NewStaticObjectInfo("file.txt", time.Now(), -1, true, nil, nil)
+	o, err := f.Put(strings.NewReader(contents), src)
+	require.NoError(t, err)
+
+	d.mu.Lock()
+	d.catFails = true
+	d.mu.Unlock()
+	assert.Equal(t, contents, readObject(t, o))
+	assert.Equal(t, "gateway", readObject(t, o, &fs.RangeOption{Start: 14, End: -1}))
+
+	// Without a gateway reading fails
+	f.(*Fs).gateway = nil
+	_, err = o.Open()
+	assert.Error(t, err)
+}
+
+func TestCopyMove(t *testing.T) {
+	d, tidy := prepare(t, "TestIPFSCopyMove")
+	defer tidy()
+	f, err := NewFs("TestIPFSCopyMove", "")
+	require.NoError(t, err)
+
+	src := object.NewStaticObjectInfo("file.txt", time.Now(), -1, true, nil, nil)
+	o, err := f.Put(strings.NewReader("contents"), src)
+	require.NoError(t, err)
+	cid := o.(fs.IDer).ID()
+
+	usage, err := f.(fs.Abouter).About()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("contents")), *usage.Used)
+	assert.Nil(t, usage.Total)
+
+	// A copy shares the content of the original
+	dst, err := f.(fs.Copier).Copy(o, "a/copy.txt")
+	require.NoError(t, err)
+	assert.Equal(t, cid, dst.(fs.IDer).ID())
+
+	dst, err = f.(fs.Mover).Move(o, "b/moved.txt")
+	require.NoError(t, err)
+	assert.Equal(t, cid, dst.(fs.IDer).ID())
+
+	// A failed copy leaves the old version in place
+	missing := &Object{fs: f.(*Fs), remote: "missing.txt", cid: "QmMissing"}
+	_, err = f.(fs.Copier).Copy(missing, "b/moved.txt")
+	assert.Error(t, err)
+	dst, err = f.NewObject("b/moved.txt")
+	require.NoError(t, err)
+	assert.Equal(t, cid, dst.(fs.IDer).ID())
+
+	// As does a move which can't remove the old version, which
+	// leaves the source where it was
+	other, err := f.Put(strings.NewReader("other"), object.NewStaticObjectInfo("other.txt", time.Now(), -1, true, nil, nil))
+	require.NoError(t, err)
+	d.mu.Lock()
+	d.rmFails = true
+	d.mu.Unlock()
+	_, err = f.(fs.Mover).Move(other, "b/moved.txt")
+	assert.Error(t, err)
+	d.mu.Lock()
+	d.rmFails = false
+	d.mu.Unlock()
+	dst, err = f.NewObject("b/moved.txt")
+	require.NoError(t, err)
+	assert.Equal(t, cid, dst.(fs.IDer).ID())
+	_, err = f.NewObject("other.txt")
+	require.NoError(t, err)
+	require.NoError(t, other.Remove())
+
+	require.NoError(t, f.(fs.DirMover).DirMove(f, "a", "c"))
+	assert.Equal(t, fs.ErrorDirExists, f.(fs.DirMover).DirMove(f, "b", "c"))
+
+	entries, err := f.List("")
+	require.NoError(t, err)
+	sort.Sort(entries)
+	var remotes []string
+	for _, entry := range entries {
+		remotes = append(remotes, entry.Remote())
+	}
+	assert.Equal(t, []string{"b", "c"}, remotes)
+	_, err = f.NewObject("c/copy.txt")
+	require.NoError(t, err)
+
+	require.NoError(t, f.(fs.Purger).Purge())
+	entries, err = f.List("")
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
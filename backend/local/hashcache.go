@@ -0,0 +1,121 @@
+// Persistent caching of computed hashes
+
+package local
+
+import (
+	"encoding/json"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/flags"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+var (
+	cacheHashes = flags.BoolP("local-cache-hashes", "", false, "Cache computed hashes in an xattr or a database so unchanged files aren't hashed again.")
+)
+
+// hashXattr is the name of the extended attribute the hashes are
+// stored in
+const hashXattr = "user.rclone.hashes"
+
+// errXattrNotSupported is returned if extended attributes can't be
+// used on this OS or filesystem
+var errXattrNotSupported = errors.New("extended attributes not supported")
+
+// cachedHashes is the hashes of a file along with the size and
+// modification time of the file when they were computed
+type cachedHashes struct {
+	Size    int64             `json:"size"`
+	ModTime int64             `json:"modtime"` // UnixNano
+	Hashes  map[string]string `json:"hashes"`  // keyed by hash name
+}
+
+// getCachedHashes returns the cached hashes for the object if they
+// are still valid and include hash type r, or nil otherwise.
+//
+// The hashes are considered valid only if the size and modification
+// time match exactly what they were when the hashes were computed,
+// otherwise the cached entry is removed.
+func (o *Object) getCachedHashes(r hash.Type) map[hash.Type]string {
+	if !*cacheHashes {
+		return nil
+	}
+	data, err := getXattr(o.path, hashXattr)
+	if err != nil && err != errXattrNotSupported {
+		fs.Debugf(o, "Failed to read hash xattr: %v", err)
+	}
+	if data == nil {
+		data, err = hashDBGet(o.path)
+		if err != nil {
+			fs.Debugf(o, "Failed to read hash from database: %v", err)
+		}
+	}
+	if data == nil {
+		return nil
+	}
+	var cached cachedHashes
+	err = json.Unmarshal(data, &cached)
+	if err != nil || cached.Size != o.size || cached.ModTime != o.modTime.UnixNano() {
+		fs.Debugf(o, "Removing stale cached hashes")
+		o.removeCachedHashes()
+		return nil
+	}
+	hashes := make(map[hash.Type]string, len(cached.Hashes))
+	for name, sum := range cached.Hashes {
+		var ht hash.Type
+		if ht.Set(name) == nil {
+			hashes[ht] = sum
+		}
+	}
+	if _, ok := hashes[r]; !ok {
+		return nil
+	}
+	return hashes
+}
+
+// putCachedHashes stores the hashes for the object which must have
+// been computed at the object's current size and modification time.
+//
+// They are stored in an xattr if possible, otherwise in the database.
+func (o *Object) putCachedHashes(hashes map[hash.Type]string) {
+	if !*cacheHashes {
+		return
+	}
+	cached := cachedHashes{
+		Size:    o.size,
+		ModTime: o.modTime.UnixNano(),
+		Hashes:  make(map[string]string, len(hashes)),
+	}
+	for ht, sum := range hashes {
+		cached.Hashes[ht.String()] = sum
+	}
+	data, err := json.Marshal(&cached)
+	if err != nil {
+		fs.Debugf(o, "Failed to encode hashes: %v", err)
+		return
+	}
+	err = setXattr(o.path, hashXattr, data)
+	if err == nil {
+		return
+	}
+	if err != errXattrNotSupported {
+		fs.Debugf(o, "Failed to write hash xattr: %v", err)
+	}
+	err = hashDBPut(o.path, data)
+	if err != nil {
+		fs.Debugf(o, "Failed to write hash to database: %v", err)
+	}
+}
+
+// removeCachedHashes removes any cached hashes for the object
+func (o *Object) removeCachedHashes() {
+	if !*cacheHashes {
+		return
+	}
+	_ = removeXattr(o.path, hashXattr)
+	err := hashDBRemove(o.path)
+	if err != nil {
+		fs.Debugf(o, "Failed to remove hash from database: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Database for hash caching where xattrs can't be used
+
+// +build !plan9
+
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/pkg/errors"
+)
+
+// hashDBBucket is the name of the bucket the hashes are kept in
+var hashDBBucket = []byte("hashes")
+
+var (
+	hashDBOnce sync.Once
+	hashDB     *bolt.DB
+	hashDBErr  error
+)
+
+// openHashDB opens the hash database the first time it is called
+func openHashDB() (*bolt.DB, error) {
+	hashDBOnce.Do(func() {
+		dir := filepath.Join(config.CacheDir, "local")
+		hashDBErr = os.MkdirAll(dir, 0700)
+		if hashDBErr != nil {
+			hashDBErr = errors.Wrap(hashDBErr, "failed to make hash database directory")
+			return
+		}
+		dbPath := filepath.Join(dir, "hashes.db")
+		hashDB, hashDBErr = bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+		if hashDBErr != nil {
+			hashDBErr = errors.Wrapf(hashDBErr, "failed to open hash database %q", dbPath)
+			return
+		}
+		fs.Debugf(nil, "Opened local hash database %q", dbPath)
+		hashDBErr = hashDB.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(hashDBBucket)
+			return err
+		})
+	})
+	return hashDB, hashDBErr
+}
+
+// hashDBGet reads the entry for path from the database returning nil
+// if there isn't one
+func hashDBGet(path string) (data []byte, err error) {
+	db, err := openHashDB()
+	if err != nil {
+		return nil, err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(hashDBBucket).Get([]byte(path)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return data, err
+}
+
+// hashDBPut stores data as the entry for path in the database
+func hashDBPut(path string, data []byte) error {
+	db, err := openHashDB()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(hashDBBucket).Put([]byte(path), data)
+	})
+}
+
+// hashDBRemove removes the entry for path from the database
+func hashDBRemove(path string) error {
+	db, err := openHashDB()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(hashDBBucket).Delete([]byte(path))
+	})
+}
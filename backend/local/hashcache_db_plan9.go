@@ -0,0 +1,22 @@
+// Database for hash caching where xattrs can't be used
+
+package local
+
+import "github.com/pkg/errors"
+
+var errHashDBNotSupported = errors.New("hash database not supported on plan9")
+
+// hashDBGet isn't supported on plan9
+func hashDBGet(path string) ([]byte, error) {
+	return nil, errHashDBNotSupported
+}
+
+// hashDBPut isn't supported on plan9
+func hashDBPut(path string, data []byte) error {
+	return errHashDBNotSupported
+}
+
+// hashDBRemove isn't supported on plan9
+func hashDBRemove(path string) error {
+	return errHashDBNotSupported
+}
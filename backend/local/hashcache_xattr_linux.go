@@ -0,0 +1,59 @@
+// Extended attribute functions for hash caching
+
+package local
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// xattrNotSupported returns true if err means that xattrs aren't
+// supported by the filesystem
+func xattrNotSupported(err error) bool {
+	return err == unix.ENOTSUP || err == unix.EOPNOTSUPP
+}
+
+// getXattr reads the extended attribute name of path returning nil
+// data if it isn't set
+func getXattr(path, name string) ([]byte, error) {
+	size, err := unix.Getxattr(path, name, nil)
+	for err == nil {
+		buf := make([]byte, size)
+		var n int
+		n, err = unix.Getxattr(path, name, buf)
+		if err == nil {
+			return buf[:n], nil
+		}
+		// Attribute grew since we read the size, so try again
+		if err == unix.ERANGE {
+			size, err = unix.Getxattr(path, name, nil)
+		}
+	}
+	if err == unix.ENODATA {
+		return nil, nil
+	}
+	if xattrNotSupported(err) {
+		return nil, errXattrNotSupported
+	}
+	return nil, err
+}
+
+// setXattr sets the extended attribute name of path to data
+func setXattr(path, name string, data []byte) error {
+	err := unix.Setxattr(path, name, data, 0)
+	if xattrNotSupported(err) {
+		return errXattrNotSupported
+	}
+	return err
+}
+
+// removeXattr removes the extended attribute name from path
+func removeXattr(path, name string) error {
+	err := unix.Removexattr(path, name)
+	if err == unix.ENODATA {
+		return nil
+	}
+	if xattrNotSupported(err) {
+		return errXattrNotSupported
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+// Extended attribute functions for hash caching
+
+// +build !linux
+
+package local
+
+// getXattr isn't supported on this OS
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errXattrNotSupported
+}
+
+// setXattr isn't supported on this OS
+func setXattr(path, name string, data []byte) error {
+	return errXattrNotSupported
+}
+
+// removeXattr isn't supported on this OS
+func removeXattr(path, name string) error {
+	return errXattrNotSupported
+}
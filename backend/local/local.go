@@ -104,6 +104,8 @@ func NewFs(name, root string) (fs.Fs, error) {
 	f.features = (&fs.Features{
 		CaseInsensitive:         f.caseInsensitive(),
 		CanHaveEmptyDirectories: true,
+		SlowHash:                true,
+		AtomicMove:              true,
 	}).Fill(f)
 	if *followSymlinks {
 		f.lstat = os.Stat
@@ -344,6 +346,44 @@ func (f *Fs) PutStream(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption
 	return f.Put(in, src, options...)
 }
 
+// OpenWriterAt opens with a handle for random access writes
+//
+// Pass in the remote desired and the size if known.
+//
+// It truncates any existing object
+func (f *Fs) OpenWriterAt(remote string, size int64) (fs.WriterAtCloser, error) {
+	// Temporary Object under construction
+	o := f.newObject(remote, "")
+
+	err := o.mkdirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// The contents are about to change so forget any cached hashes
+	o.removeCachedHashes()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if size < 0 {
+		flags |= os.O_TRUNC
+	}
+	out, err := os.OpenFile(o.path, flags, 0666)
+	if err != nil {
+		return nil, err
+	}
+	// Set the file to the final size so the parts can be written
+	// in any order.  This keeps any existing data before size so
+	// partial downloads can be resumed.
+	if size >= 0 {
+		err = out.Truncate(size)
+		if err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
 // Mkdir creates the directory if it doesn't exist
 func (f *Fs) Mkdir(dir string) error {
 	// FIXME: https://github.com/syncthing/syncthing/blob/master/lib/osutil/mkdirall_windows.go
@@ -482,6 +522,10 @@ func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
 		return nil, err
 	}
 
+	// Forget cached hashes which are about to be invalid
+	dstObj.removeCachedHashes()
+	srcObj.removeCachedHashes()
+
 	// Do the move
 	err = os.Rename(srcObj.path, dstObj.path)
 	if os.IsNotExist(err) {
@@ -593,17 +637,21 @@ func (o *Object) Hash(r hash.Type) (string, error) {
 	o.fs.objectHashesMu.Unlock()
 
 	if !o.modTime.Equal(oldtime) || oldsize != o.size || hashes == nil {
-		in, err := os.Open(o.path)
-		if err != nil {
-			return "", errors.Wrap(err, "hash: failed to open")
-		}
-		hashes, err = hash.Stream(in)
-		closeErr := in.Close()
-		if err != nil {
-			return "", errors.Wrap(err, "hash: failed to read")
-		}
-		if closeErr != nil {
-			return "", errors.Wrap(closeErr, "hash: failed to close")
+		hashes = o.getCachedHashes(r)
+		if hashes == nil {
+			in, err := os.Open(o.path)
+			if err != nil {
+				return "", errors.Wrap(err, "hash: failed to open")
+			}
+			hashes, err = hash.Stream(in)
+			closeErr := in.Close()
+			if err != nil {
+				return "", errors.Wrap(err, "hash: failed to read")
+			}
+			if closeErr != nil {
+				return "", errors.Wrap(closeErr, "hash: failed to close")
+			}
+			o.putCachedHashes(hashes)
 		}
 		o.fs.objectHashesMu.Lock()
 		o.hashes = hashes
@@ -762,12 +810,16 @@ func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOptio
 			hashes = x.Hashes
 		}
 	}
+	allHashes := hashes == hash.Supported
 
 	err := o.mkdirAll()
 	if err != nil {
 		return err
 	}
 
+	// The contents are about to change so forget any cached hashes
+	o.removeCachedHashes()
+
 	out, err := os.OpenFile(o.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
@@ -805,7 +857,16 @@ func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOptio
 	}
 
 	// ReRead info now that we have finished
-	return o.lstat()
+	err = o.lstat()
+	if err != nil {
+		return err
+	}
+
+	// Cache the hashes if we have all of them
+	if allHashes {
+		o.putCachedHashes(hash.Sums())
+	}
+	return nil
 }
 
 // setMetadata sets the file info from the os.FileInfo passed in
@@ -834,6 +895,7 @@ func (o *Object) lstat() error {
 
 // Remove an object
 func (o *Object) Remove() error {
+	o.removeCachedHashes()
 	return remove(o.path)
 }
 
@@ -960,10 +1022,11 @@ func cleanWindowsName(f *Fs, name string) string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Purger      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.Mover       = &Fs{}
-	_ fs.DirMover    = &Fs{}
-	_ fs.Object      = &Object{}
+	_ fs.Fs             = &Fs{}
+	_ fs.Purger         = &Fs{}
+	_ fs.PutStreamer    = &Fs{}
+	_ fs.Mover          = &Fs{}
+	_ fs.DirMover       = &Fs{}
+	_ fs.OpenWriterAter = &Fs{}
+	_ fs.Object         = &Object{}
 )
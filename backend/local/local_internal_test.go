@@ -1,11 +1,14 @@
 package local
 
 import (
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ncw/rclone/fs/config"
 	"github.com/ncw/rclone/fs/hash"
 	"github.com/ncw/rclone/fstest"
 	"github.com/ncw/rclone/lib/readers"
@@ -76,3 +79,71 @@ func TestUpdatingCheck(t *testing.T) {
 	require.NoError(t, err)
 
 }
+
+// setupHashCache turns on --local-cache-hashes with the database in
+// a temporary directory
+func setupHashCache(t *testing.T) func() {
+	cacheDir, err := ioutil.TempDir("", "rclone-hashcache")
+	require.NoError(t, err)
+	oldCacheDir := config.CacheDir
+	config.CacheDir = cacheDir
+	*cacheHashes = true
+	return func() {
+		*cacheHashes = false
+		config.CacheDir = oldCacheDir
+		_ = os.RemoveAll(cacheDir)
+	}
+}
+
+func md5sum(t *testing.T, s string) string {
+	sums, err := hash.Stream(strings.NewReader(s))
+	require.NoError(t, err)
+	return sums[hash.MD5]
+}
+
+// Test the hashes are cached until the size or modtime changes
+func TestCacheHashes(t *testing.T) {
+	defer setupHashCache(t)()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	when := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	r.WriteFile("file", "content1", when)
+	localPath := path.Join(r.LocalName, "file")
+
+	hashOf := func() string {
+		o, err := r.Flocal.NewObject("file")
+		require.NoError(t, err)
+		sum, err := o.Hash(hash.MD5)
+		require.NoError(t, err)
+		return sum
+	}
+	assert.Equal(t, md5sum(t, "content1"), hashOf())
+
+	// Change the contents but not the size or modtime so the
+	// cached value is returned
+	require.NoError(t, ioutil.WriteFile(localPath, []byte("content2"), 0600))
+	require.NoError(t, os.Chtimes(localPath, when, when))
+	assert.Equal(t, md5sum(t, "content1"), hashOf())
+
+	// Change the modtime so the hash is recomputed
+	when = when.Add(time.Second)
+	require.NoError(t, os.Chtimes(localPath, when, when))
+	assert.Equal(t, md5sum(t, "content2"), hashOf())
+}
+
+func TestHashDB(t *testing.T) {
+	defer setupHashCache(t)()
+	data, err := hashDBGet("/potato")
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	require.NoError(t, hashDBPut("/potato", []byte("hashes")))
+	data, err = hashDBGet("/potato")
+	require.NoError(t, err)
+	assert.Equal(t, "hashes", string(data))
+
+	require.NoError(t, hashDBRemove("/potato"))
+	data, err = hashDBGet("/potato")
+	require.NoError(t, err)
+	assert.Nil(t, data)
+}
@@ -0,0 +1,336 @@
+// Recursive listing with the delta API
+//
+// OneDrive can return all the changes to a folder and everything in
+// it since a token.  ListR keeps a copy of the tree along with the
+// token in a database in the cache directory so repeated listings of
+// a large drive only need to fetch the items which have changed.
+
+package onedrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/ncw/rclone/backend/onedrive/api"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/walk"
+	"github.com/ncw/rclone/lib/rest"
+	"github.com/pkg/errors"
+)
+
+// deltaDBBucket is the name of the bucket the delta states are kept in
+var deltaDBBucket = []byte("delta")
+
+var (
+	deltaDBOnce sync.Once
+	deltaDB     *bolt.DB
+	deltaDBErr  error
+)
+
+// openDeltaDB opens the delta database the first time it is called
+func openDeltaDB() (*bolt.DB, error) {
+	deltaDBOnce.Do(func() {
+		dir := filepath.Join(config.CacheDir, "onedrive")
+		deltaDBErr = os.MkdirAll(dir, 0700)
+		if deltaDBErr != nil {
+			deltaDBErr = errors.Wrap(deltaDBErr, "failed to make delta database directory")
+			return
+		}
+		dbPath := filepath.Join(dir, "delta.db")
+		deltaDB, deltaDBErr = bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+		if deltaDBErr != nil {
+			deltaDBErr = errors.Wrapf(deltaDBErr, "failed to open delta database %q", dbPath)
+			return
+		}
+		fs.Debugf(nil, "Opened onedrive delta database %q", dbPath)
+		deltaDBErr = deltaDB.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(deltaDBBucket)
+			return err
+		})
+	})
+	return deltaDB, deltaDBErr
+}
+
+// deltaState is what is kept in the database for each folder listed
+type deltaState struct {
+	DeltaLink string               `json:"deltaLink"` // URL to read the next changes from
+	Items     map[string]*api.Item `json:"items"`     // the items in the tree by ID
+}
+
+// newDeltaState makes an empty deltaState
+func newDeltaState() *deltaState {
+	return &deltaState{
+		Items: make(map[string]*api.Item),
+	}
+}
+
+// deltaGet reads the state stored under key returning nil if there
+// isn't one
+func deltaGet(key string) (*deltaState, error) {
+	db, err := openDeltaDB()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(deltaDBBucket).Get([]byte(key)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return nil, err
+	}
+	state := newDeltaState()
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode delta state")
+	}
+	return state, nil
+}
+
+// deltaPut stores state under key
+func deltaPut(key string, state *deltaState) error {
+	db, err := openDeltaDB()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode delta state")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(deltaDBBucket).Put([]byte(key), data)
+	})
+}
+
+// apply updates the state with a page of changed items
+func (s *deltaState) apply(items []api.Item) {
+	for i := range items {
+		item := &items[i]
+		if item.Deleted != nil {
+			delete(s.Items, item.ID)
+			continue
+		}
+		// These aren't needed for listing so don't store them
+		item.CreatedBy = api.IdentitySet{}
+		item.LastModifiedBy = api.IdentitySet{}
+		s.Items[item.ID] = item
+	}
+}
+
+// paths works out the path of each item in the state relative to the
+// folder with ID rootID.  Items which aren't under rootID are left
+// out.
+func (s *deltaState) paths(rootID string) map[string]string {
+	paths := make(map[string]string, len(s.Items))
+	missing := make(map[string]struct{})
+	var find func(id string, depth int) (string, bool)
+	find = func(id string, depth int) (string, bool) {
+		if p, ok := paths[id]; ok {
+			return p, true
+		}
+		if _, ok := missing[id]; ok {
+			return "", false
+		}
+		item, ok := s.Items[id]
+		// the depth check stops a loop in the parents recursing forever
+		if !ok || item.ParentReference == nil || depth > len(s.Items) {
+			missing[id] = struct{}{}
+			return "", false
+		}
+		p := restoreReservedChars(item.GetName())
+		if parentID := item.ParentReference.ID; parentID != rootID {
+			parent, ok := find(parentID, depth+1)
+			if !ok {
+				missing[id] = struct{}{}
+				return "", false
+			}
+			p = parent + "/" + p
+		}
+		paths[id] = p
+		return p, true
+	}
+	for id := range s.Items {
+		if id != rootID {
+			find(id, 0)
+		}
+	}
+	return paths
+}
+
+// deltaKey returns the key the delta state for dir is kept under
+func (f *Fs) deltaKey(dir string) string {
+	if f.isBusiness {
+		// OneDrive for Business only does delta on the root so
+		// there is one state for the whole drive
+		return f.name + ":"
+	}
+	return f.name + ":" + path.Join(f.root, dir)
+}
+
+// deltaOpts returns the call to start a full delta listing of the
+// folder with ID directoryID
+func (f *Fs) deltaOpts(directoryID string) rest.Opts {
+	if f.isBusiness {
+		return rest.Opts{
+			Method: "GET",
+			Path:   "/root/delta",
+		}
+	}
+	return newOptsCall(directoryID, "GET", "/delta")
+}
+
+// listDelta brings the saved state for dir up to date with the
+// changes made since it was last listed and returns it.
+//
+// If there is no saved state or the server says the token is no
+// longer valid then everything is listed again.
+func (f *Fs) listDelta(dir string, directoryID string) (state *deltaState, err error) {
+	key := f.deltaKey(dir)
+	state, err = deltaGet(key)
+	if err != nil {
+		fs.Debugf(f, "Ignoring saved delta state: %v", err)
+		state = nil
+	}
+	var opts rest.Opts
+	full := state == nil || state.DeltaLink == ""
+	if full {
+		fs.Debugf(f, "Listing %q in full with delta", key)
+		state = newDeltaState()
+		opts = f.deltaOpts(directoryID)
+	} else {
+		fs.Debugf(f, "Listing changes to %q with delta", key)
+		opts = rest.Opts{
+			Method:  "GET",
+			RootURL: state.DeltaLink,
+		}
+	}
+	for {
+		var result api.ViewDeltaResponse
+		var resp *http.Response
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err = f.srv.CallJSON(&opts, nil, &result)
+			return shouldRetry(resp, err)
+		})
+		if err != nil {
+			if !full && resp != nil && resp.StatusCode == http.StatusGone {
+				fs.Infof(f, "Delta token for %q is no longer valid so listing in full: %v", key, err)
+				full = true
+				state = newDeltaState()
+				opts = f.deltaOpts(directoryID)
+				continue
+			}
+			return nil, errors.Wrap(err, "couldn't list changes")
+		}
+		state.apply(result.Value)
+		if result.NextLink == "" {
+			state.DeltaLink = result.DeltaLink
+			break
+		}
+		opts = rest.Opts{
+			Method:  "GET",
+			RootURL: result.NextLink,
+		}
+	}
+	err = deltaPut(key, state)
+	if err != nil {
+		fs.Errorf(f, "Failed to save delta state: %v", err)
+	}
+	return state, nil
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+//
+// Don't implement this unless you have a more efficient way
+// of listing recursively that doing a directory traversal.
+func (f *Fs) ListR(dir string, callback fs.ListRCallback) (err error) {
+	err = f.dirCache.FindRoot(false)
+	if err != nil {
+		return err
+	}
+	directoryID, err := f.dirCache.FindDir(dir, false)
+	if err != nil {
+		return err
+	}
+	state, err := f.listDelta(dir, directoryID)
+	if err != nil {
+		return err
+	}
+	rootID, _, _ := parseDirID(directoryID)
+	list := walk.NewListRHelper(callback)
+	for id, p := range state.paths(rootID) {
+		info := state.Items[id]
+		remote := path.Join(dir, p)
+		folder := info.GetFolder()
+		if folder != nil {
+			// cache the directory ID for later lookups
+			id := info.GetID()
+			f.dirCache.Put(remote, id)
+			d := fs.NewDir(remote, time.Time(info.GetLastModifiedDateTime())).SetID(id)
+			d.SetItems(folder.ChildCount)
+			err = list.Add(d)
+			if err != nil {
+				return err
+			}
+			// The contents of shared folders aren't in the delta
+			if info.IsRemote() {
+				err = f.listRecursive(remote, list)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			o, err := f.newObjectWithInfo(remote, info)
+			if err != nil {
+				return err
+			}
+			err = list.Add(o)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return list.Flush()
+}
+
+// listRecursive lists everything under dir into list a directory at
+// a time
+func (f *Fs) listRecursive(dir string, list *walk.ListRHelper) error {
+	entries, err := f.List(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err = list.Add(entry)
+		if err != nil {
+			return err
+		}
+		if _, ok := entry.(fs.Directory); ok {
+			err = f.listRecursive(entry.Remote(), list)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
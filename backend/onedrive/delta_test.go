@@ -0,0 +1,97 @@
+package onedrive
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/backend/onedrive/api"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deltaItem makes an item for the delta tests
+func deltaItem(id, parentID, name string, folder bool) api.Item {
+	item := api.Item{
+		ID:              id,
+		Name:            name,
+		ParentReference: &api.ItemReference{ID: parentID},
+	}
+	if folder {
+		item.Folder = &api.FolderFacet{}
+	}
+	return item
+}
+
+func TestDeltaStatePaths(t *testing.T) {
+	s := newDeltaState()
+	s.apply([]api.Item{
+		{ID: "root", Name: "root", Folder: &api.FolderFacet{}},
+		deltaItem("dir", "root", "dir", true),
+		deltaItem("file1", "root", "file1", false),
+		deltaItem("file2", "dir", "file2", false),
+		deltaItem("sub", "dir", "sub", true),
+		deltaItem("file3", "sub", "file？3", false),
+		deltaItem("orphan", "unknown", "orphan", false),
+		deltaItem("loop1", "loop2", "loop1", true),
+		deltaItem("loop2", "loop1", "loop2", true),
+	})
+	assert.Equal(t, map[string]string{
+		"dir":   "dir",
+		"file1": "file1",
+		"file2": "dir/file2",
+		"sub":   "dir/sub",
+		"file3": "dir/sub/file?3",
+	}, s.paths("root"))
+	assert.Equal(t, map[string]string{
+		"file2": "file2",
+		"sub":   "sub",
+		"file3": "sub/file?3",
+	}, s.paths("dir"))
+
+	// Apply some changes
+	s.apply([]api.Item{
+		{ID: "sub", Deleted: &api.DeletedFacet{}},
+		deltaItem("file1", "dir", "file1renamed", false),
+	})
+	assert.Equal(t, map[string]string{
+		"dir":   "dir",
+		"file1": "dir/file1renamed",
+		"file2": "dir/file2",
+	}, s.paths("root"))
+}
+
+func TestDeltaStateSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-onedrive-delta")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	oldCacheDir := config.CacheDir
+	config.CacheDir = dir
+	defer func() {
+		config.CacheDir = oldCacheDir
+	}()
+
+	got, err := deltaGet("remote:path")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	modTime := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+	s := newDeltaState()
+	s.DeltaLink = "https://example.com/delta?token=123"
+	item := deltaItem("file", "root", "file", false)
+	item.LastModifiedDateTime = api.Timestamp(modTime)
+	s.apply([]api.Item{item})
+	require.NoError(t, deltaPut("remote:path", s))
+
+	got, err = deltaGet("remote:path")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, s.DeltaLink, got.DeltaLink)
+	require.Contains(t, got.Items, "file")
+	assert.Equal(t, "file", got.Items["file"].Name)
+	assert.True(t, modTime.Equal(time.Time(got.Items["file"].LastModifiedDateTime)))
+}
@@ -75,6 +75,7 @@ var (
 	oauthBusinessResource = oauth2.SetAuthURLParam("resource", discoveryServiceURL)
 
 	chunkSize = fs.SizeSuffix(10 * 1024 * 1024)
+	useDelta  = flags.BoolP("onedrive-delta", "", false, "Use the delta API to only fetch changes for recursive listings.")
 	sharedURL = "https://api.onedrive.com/v1.0/drives" // root URL for remote shared resources
 )
 
@@ -385,6 +386,9 @@ func NewFs(name, root string) (fs.Fs, error) {
 		ReadMimeType:            !f.isBusiness,
 		CanHaveEmptyDirectories: true,
 	}).Fill(f)
+	if !*useDelta {
+		f.features.ListR = nil
+	}
 	f.srv.SetErrorHandler(errorHandler)
 
 	// Renew the token in the background
@@ -1383,6 +1387,7 @@ var (
 	// _ fs.DirMover = (*Fs)(nil)
 	_ fs.DirCacheFlusher = (*Fs)(nil)
 	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.ListRer         = (*Fs)(nil)
 	_ fs.Object          = (*Object)(nil)
 	_ fs.MimeTyper       = &Object{}
 	_ fs.IDer            = &Object{}
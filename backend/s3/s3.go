@@ -14,6 +14,7 @@ What happens if you CTRL-C a multipart upload
 */
 
 import (
+	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -30,12 +31,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws/corehandlers"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/defaults"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/config"
 	"github.com/ncw/rclone/fs/config/flags"
@@ -497,7 +500,20 @@ func init() {
 			}, {
 				Value: "AES256",
 				Help:  "AES256",
+			}, {
+				Value: "aws:kms",
+				Help:  "aws:kms",
 			}},
+		}, {
+			Name:     "sse_kms_key_id",
+			Help:     "The ID of the KMS key to encrypt objects with if using aws:kms server-side encryption.",
+			Provider: "AWS",
+			Optional: true,
+		}, {
+			Name:     "sse_customer_key",
+			Help:     "The base64 encoded 256 bit key to encrypt and decrypt objects with using server-side encryption with customer provided keys (SSE-C).",
+			Provider: "AWS",
+			Optional: true,
 		}, {
 			Name:     "storage_class",
 			Help:     "The storage class to use when storing objects in S3.",
@@ -518,6 +534,18 @@ func init() {
 				Value: "ONEZONE_IA",
 				Help:  "One Zone Infrequent Access storage class",
 			}},
+		}, {
+			Name:     "ca_cert",
+			Help:     "Path to a PEM file of CA certificates to verify the server with instead of the system ones.",
+			Optional: true,
+		}, {
+			Name:     "client_cert",
+			Help:     "Path to a PEM client certificate for mutual TLS.",
+			Optional: true,
+		}, {
+			Name:     "client_key",
+			Help:     "Path to the PEM key for the client certificate.",
+			Optional: true,
 		},
 		},
 	})
@@ -532,6 +560,8 @@ const (
 	maxRetries     = 10                            // number of retries to make of operations
 	maxSizeForCopy = 5 * 1024 * 1024 * 1024        // The maximum size of object we can COPY
 	maxFileSize    = 5 * 1024 * 1024 * 1024 * 1024 // largest possible upload file size
+	// assumed role credentials are refreshed this long before they expire
+	assumeRoleExpiryWindow = 5 * time.Minute
 )
 
 // Globals
@@ -542,6 +572,13 @@ var (
 	s3ChunkSize         = fs.SizeSuffix(s3manager.MinUploadPartSize)
 	s3DisableChecksum   = flags.BoolP("s3-disable-checksum", "", false, "Don't store MD5 checksum with object metadata")
 	s3UploadConcurrency = flags.IntP("s3-upload-concurrency", "", 2, "Concurrency for multipart uploads")
+	s3AssumeRoleARN     = flags.StringArrayP("s3-assume-role-arn", "", nil, "ARN of an IAM role to assume. Repeat to assume a chain of roles.")
+	s3AssumeRoleExtID   = flags.StringArrayP("s3-assume-role-external-id", "", nil, "External ID for assuming the role of the matching --s3-assume-role-arn.")
+	s3CACert            = flags.StringP("s3-ca-cert", "", "", "Path to a PEM file of CA certificates to verify the server with.")
+	s3ClientCert        = flags.StringP("s3-client-cert", "", "", "Path to a PEM client certificate for mutual TLS.")
+	s3ClientKey         = flags.StringP("s3-client-key", "", "", "Path to the PEM key for --s3-client-cert.")
+	s3SSEKMSKeyID       = flags.StringP("s3-sse-kms-key-id", "", "", "ID of the KMS key to encrypt uploads with using aws:kms server-side encryption.")
+	s3SSECustomerKey    = flags.StringP("s3-sse-customer-key", "", "", "Base64 encoded 256 bit key to use for server-side encryption with customer provided keys (SSE-C).")
 )
 
 // Fs represents a remote s3 server
@@ -558,6 +595,9 @@ type Fs struct {
 	acl                string           // ACL for new buckets / objects
 	locationConstraint string           // location constraint of new buckets
 	sse                string           // the type of server-side encryption
+	sseKMSKeyID        string           // the KMS key for aws:kms server-side encryption
+	sseCustomerKey     string           // the decoded key for SSE-C or "" if not in use
+	sseCustomerKeyMD5  string           // base64 encoded MD5 of sseCustomerKey
 	storageClass       string           // storage class
 }
 
@@ -619,6 +659,73 @@ func s3ParsePath(path string) (bucket, directory string, err error) {
 	return
 }
 
+// sseCustomerAlgorithm is the only algorithm S3 supports for SSE-C
+const sseCustomerAlgorithm = "AES256"
+
+// parseSSECustomerKey decodes the base64 encoded SSE-C key returning
+// the raw key and the base64 encoded MD5 of it to send with it
+func parseSSECustomerKey(encoded string) (key, keyMD5 string, err error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", errors.Wrap(err, "sse_customer_key must be base64 encoded")
+	}
+	if len(raw) != 32 {
+		return "", "", errors.Errorf("sse_customer_key must be a 256 bit key but it is %d bits", len(raw)*8)
+	}
+	sum := md5.Sum(raw)
+	return string(raw), base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// setEncryption reads the server-side encryption settings for the
+// remote name from the config, overridden by the flags if set, and
+// checks they are consistent
+func (f *Fs) setEncryption(name string) (err error) {
+	f.sse = config.FileGet(name, "server_side_encryption")
+	f.sseKMSKeyID = config.FileGet(name, "sse_kms_key_id")
+	customerKey := config.FileGet(name, "sse_customer_key")
+	if *s3SSEKMSKeyID != "" {
+		f.sseKMSKeyID = *s3SSEKMSKeyID
+	}
+	if *s3SSECustomerKey != "" {
+		customerKey = *s3SSECustomerKey
+	}
+	if f.sseKMSKeyID != "" {
+		if f.sse == "" {
+			f.sse = s3.ServerSideEncryptionAwsKms
+		} else if f.sse != s3.ServerSideEncryptionAwsKms {
+			return errors.Errorf("sse_kms_key_id needs server_side_encryption %q not %q", s3.ServerSideEncryptionAwsKms, f.sse)
+		}
+	}
+	if customerKey != "" {
+		if f.sse != "" {
+			return errors.New("can't use sse_customer_key with server_side_encryption")
+		}
+		f.sseCustomerKey, f.sseCustomerKeyMD5, err = parseSSECustomerKey(customerKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sseCustomer returns the values for the SSECustomerAlgorithm,
+// SSECustomerKey and SSECustomerKeyMD5 fields of a request, or nils if
+// SSE-C isn't in use.  These must be sent on every request which reads
+// or writes the object data or metadata.
+func (f *Fs) sseCustomer() (algorithm, key, keyMD5 *string) {
+	if f.sseCustomerKey == "" {
+		return nil, nil, nil
+	}
+	return aws.String(sseCustomerAlgorithm), aws.String(f.sseCustomerKey), aws.String(f.sseCustomerKeyMD5)
+}
+
+// etagIsMD5 returns whether the ETag of objects uploaded by f is the
+// MD5 of the contents, which isn't the case if they are encrypted with
+// KMS or customer keys
+func (f *Fs) etagIsMD5() bool {
+	return f.sseCustomerKey == "" && f.sse != s3.ServerSideEncryptionAwsKms
+}
+
 // s3Connection makes a connection to s3
 func s3Connection(name string) (*s3.S3, *session.Session, error) {
 	// Make the auth
@@ -679,12 +786,34 @@ func s3Connection(name string) (*s3.S3, *session.Session, error) {
 	if region == "" {
 		region = "us-east-1"
 	}
+	if len(*s3AssumeRoleARN) > 0 {
+		if cred == credentials.AnonymousCredentials {
+			return nil, nil, errors.New("can't assume a role without credentials")
+		}
+		if region == "other-v2-signature" {
+			return nil, nil, errors.New("can't assume a role with v2 auth")
+		}
+		var err error
+		cred, err = assumeRoleChain(cred, *s3AssumeRoleARN, *s3AssumeRoleExtID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	// Only the connections to the endpoint use the custom certificates
+	client, err := fshttp.NewClientTLS(fs.Config, config.FileGetTLSOptions(name, fshttp.TLSOptions{
+		CACert:     *s3CACert,
+		ClientCert: *s3ClientCert,
+		ClientKey:  *s3ClientKey,
+	}))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "s3")
+	}
 	awsConfig := aws.NewConfig().
 		WithRegion(region).
 		WithMaxRetries(maxRetries).
 		WithCredentials(cred).
 		WithEndpoint(endpoint).
-		WithHTTPClient(fshttp.NewClient(fs.Config)).
+		WithHTTPClient(client).
 		WithS3ForcePathStyle(true)
 	// awsConfig.WithLogLevel(aws.LogDebugWithSigning)
 	ses := session.New()
@@ -705,6 +834,42 @@ func s3Connection(name string) (*s3.S3, *session.Session, error) {
 	return c, ses, nil
 }
 
+// assumeRoleChain returns credentials for the last of roleARNs,
+// assuming each role in turn with the credentials of the one before,
+// starting with cred.
+//
+// externalIDs are the external IDs to use for each role - they may
+// be empty or missing for roles which don't need one.
+//
+// Each set of credentials is refreshed by assuming the role again
+// when it gets close to expiring, so these can be used for as long as
+// necessary.
+func assumeRoleChain(cred *credentials.Credentials, roleARNs, externalIDs []string) (*credentials.Credentials, error) {
+	if len(externalIDs) > len(roleARNs) {
+		return nil, errors.New("more --s3-assume-role-external-id than --s3-assume-role-arn")
+	}
+	for i, roleARN := range roleARNs {
+		externalID := ""
+		if i < len(externalIDs) {
+			externalID = externalIDs[i]
+		}
+		// STS is global so use its default endpoint, not the S3 one
+		stsConfig := aws.NewConfig().
+			WithRegion("us-east-1").
+			WithMaxRetries(maxRetries).
+			WithCredentials(cred).
+			WithHTTPClient(fshttp.NewClient(fs.Config))
+		svc := sts.New(session.New(), stsConfig)
+		cred = stscreds.NewCredentialsWithClient(svc, roleARN, func(p *stscreds.AssumeRoleProvider) {
+			if externalID != "" {
+				p.ExternalID = aws.String(externalID)
+			}
+			p.ExpiryWindow = assumeRoleExpiryWindow
+		})
+	}
+	return cred, nil
+}
+
 // NewFs constructs an Fs from the path, bucket:path
 func NewFs(name, root string) (fs.Fs, error) {
 	bucket, directory, err := s3ParsePath(root)
@@ -723,9 +888,12 @@ func NewFs(name, root string) (fs.Fs, error) {
 		acl:                config.FileGet(name, "acl"),
 		root:               directory,
 		locationConstraint: config.FileGet(name, "location_constraint"),
-		sse:                config.FileGet(name, "server_side_encryption"),
 		storageClass:       config.FileGet(name, "storage_class"),
 	}
+	err = f.setEncryption(name)
+	if err != nil {
+		return nil, err
+	}
 	f.features = (&fs.Features{
 		ReadMimeType:  true,
 		WriteMimeType: true,
@@ -734,6 +902,10 @@ func NewFs(name, root string) (fs.Fs, error) {
 	if *s3ACL != "" {
 		f.acl = *s3ACL
 	}
+	err = checkCannedACL(f.acl)
+	if err != nil {
+		return nil, err
+	}
 	if *s3StorageClass != "" {
 		f.storageClass = *s3StorageClass
 	}
@@ -747,6 +919,7 @@ func NewFs(name, root string) (fs.Fs, error) {
 			Bucket: &f.bucket,
 			Key:    &directory,
 		}
+		req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = f.sseCustomer()
 		_, err = f.c.HeadObject(&req)
 		if err == nil {
 			f.root = path.Dir(directory)
@@ -1139,10 +1312,12 @@ func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
 	source := pathEscape(srcFs.bucket + "/" + srcFs.root + srcObj.remote)
 	req := s3.CopyObjectInput{
 		Bucket:            &f.bucket,
+		ACL:               &f.acl,
 		Key:               &key,
 		CopySource:        &source,
 		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
 	}
+	f.setCopyEncryption(&req, srcFs)
 	_, err = f.c.CopyObject(&req)
 	if err != nil {
 		return nil, err
@@ -1150,6 +1325,113 @@ func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
 	return f.NewObject(remote)
 }
 
+// cannedACLs are the canned ACLs S3 accepts for buckets and objects
+var cannedACLs = map[string]bool{
+	s3.ObjectCannedACLPrivate:                true,
+	s3.ObjectCannedACLPublicRead:             true,
+	s3.ObjectCannedACLPublicReadWrite:        true,
+	s3.ObjectCannedACLAuthenticatedRead:      true,
+	s3.ObjectCannedACLAwsExecRead:            true,
+	s3.ObjectCannedACLBucketOwnerRead:        true,
+	s3.ObjectCannedACLBucketOwnerFullControl: true,
+	"log-delivery-write":                     true, // buckets only
+}
+
+// checkCannedACL returns an error if acl isn't a canned ACL S3
+// accepts.  An empty acl is OK as the provider's default is used.
+func checkCannedACL(acl string) error {
+	if acl != "" && !cannedACLs[acl] {
+		return errors.Errorf("s3: unknown canned ACL %q", acl)
+	}
+	return nil
+}
+
+// setACLResult is returned by the setacl command
+type setACLResult struct {
+	ACL     string // the canned ACL set
+	Objects int    // the number of objects it was set on
+}
+
+// setACL sets the canned ACL of all the objects in f which pass the
+// filters to acl
+func (f *Fs) setACL(acl string) (*setACLResult, error) {
+	if acl == "" {
+		return nil, errors.New("need the canned ACL to set, eg public-read")
+	}
+	err := checkCannedACL(acl)
+	if err != nil {
+		return nil, err
+	}
+	if f.bucket == "" {
+		return nil, errors.New("need a bucket to set the ACL of its objects")
+	}
+	result := &setACLResult{ACL: acl}
+	errorCount := 0
+	err = walk.Walk(f, "", false, -1, func(dir string, entries fs.DirEntries, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			o, ok := entry.(*Object)
+			if !ok {
+				continue
+			}
+			if fs.Config.DryRun {
+				fs.Logf(o, "Not setting ACL to %q as --dry-run", acl)
+				continue
+			}
+			key := f.root + o.remote
+			_, err := f.c.PutObjectAcl(&s3.PutObjectAclInput{
+				Bucket: &f.bucket,
+				Key:    &key,
+				ACL:    &acl,
+			})
+			if err != nil {
+				fs.CountError(err)
+				fs.Errorf(o, "Failed to set ACL: %v", err)
+				errorCount++
+				continue
+			}
+			fs.Infof(o, "Set ACL to %q", acl)
+			result.Objects++
+		}
+		return nil
+	})
+	if err == nil && errorCount > 0 {
+		err = errors.Errorf("failed to set ACL of %d objects", errorCount)
+	}
+	return result, err
+}
+
+// Command the backend to run a named command
+//
+// The command run is name, args may be used to read arguments from.
+// It returns a result which can be marshalled to JSON.
+func (f *Fs) Command(name string, args []string) (interface{}, error) {
+	switch name {
+	case "setacl":
+		if len(args) != 1 {
+			return nil, errors.New("need the canned ACL to set, eg public-read")
+		}
+		return f.setACL(args[0])
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
+// setCopyEncryption sets the server-side encryption of req to copy an
+// object from srcFs to f
+func (f *Fs) setCopyEncryption(req *s3.CopyObjectInput, srcFs *Fs) {
+	if f.sse != "" {
+		req.ServerSideEncryption = &f.sse
+	}
+	if f.sseKMSKeyID != "" {
+		req.SSEKMSKeyId = &f.sseKMSKeyID
+	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = f.sseCustomer()
+	req.CopySourceSSECustomerAlgorithm, req.CopySourceSSECustomerKey, req.CopySourceSSECustomerKeyMD5 = srcFs.sseCustomer()
+}
+
 // Hashes returns the supported hash sets.
 func (f *Fs) Hashes() hash.Set {
 	return hash.Set(hash.MD5)
@@ -1184,7 +1466,7 @@ func (o *Object) Hash(t hash.Type) (string, error) {
 	}
 	hash := strings.Trim(strings.ToLower(o.etag), `"`)
 	// Check the etag is a valid md5sum
-	if !matchMd5.MatchString(hash) {
+	if !matchMd5.MatchString(hash) || !o.fs.etagIsMD5() {
 		err := o.readMetaData()
 		if err != nil {
 			return "", err
@@ -1220,6 +1502,7 @@ func (o *Object) readMetaData() (err error) {
 		Bucket: &o.fs.bucket,
 		Key:    &key,
 	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = o.fs.sseCustomer()
 	resp, err := o.fs.c.HeadObject(&req)
 	if err != nil {
 		if awsErr, ok := err.(awserr.RequestFailure); ok {
@@ -1275,6 +1558,18 @@ func (o *Object) ModTime() time.Time {
 	return modTime
 }
 
+// ServerModTime returns the LastModified time of the object on the
+// server
+func (o *Object) ServerModTime() time.Time {
+	if o.lastModified.IsZero() {
+		err := o.readMetaData()
+		if err != nil {
+			fs.Logf(o, "Failed to read metadata: %v", err)
+		}
+	}
+	return o.lastModified
+}
+
 // SetModTime sets the modification time of the local fs object
 func (o *Object) SetModTime(modTime time.Time) error {
 	err := o.readMetaData()
@@ -1304,6 +1599,7 @@ func (o *Object) SetModTime(modTime time.Time) error {
 		Metadata:          o.meta,
 		MetadataDirective: &directive,
 	}
+	o.fs.setCopyEncryption(&req, o.fs)
 	_, err = o.fs.c.CopyObject(&req)
 	return err
 }
@@ -1320,6 +1616,7 @@ func (o *Object) Open(options ...fs.OpenOption) (in io.ReadCloser, err error) {
 		Bucket: &o.fs.bucket,
 		Key:    &key,
 	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = o.fs.sseCustomer()
 	for _, option := range options {
 		switch option.(type) {
 		case *fs.RangeOption, *fs.SeekOption:
@@ -1376,7 +1673,9 @@ func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOptio
 		metaMtime: aws.String(swift.TimeToFloatString(modTime)),
 	}
 
-	if !*s3DisableChecksum && size > uploader.PartSize {
+	// The ETag isn't the MD5 of multipart or encrypted uploads so
+	// store that in the metadata
+	if !*s3DisableChecksum && (size > uploader.PartSize || !o.fs.etagIsMD5()) {
 		hash, err := src.Hash(hash.MD5)
 
 		if err == nil && matchMd5.MatchString(hash) {
@@ -1404,6 +1703,10 @@ func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOptio
 	if o.fs.sse != "" {
 		req.ServerSideEncryption = &o.fs.sse
 	}
+	if o.fs.sseKMSKeyID != "" {
+		req.SSEKMSKeyId = &o.fs.sseKMSKeyID
+	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = o.fs.sseCustomer()
 	if o.fs.storageClass != "" {
 		req.StorageClass = &o.fs.storageClass
 	}
@@ -1441,10 +1744,12 @@ func (o *Object) MimeType() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
+	_ fs.Fs             = &Fs{}
+	_ fs.Copier         = &Fs{}
+	_ fs.PutStreamer    = &Fs{}
+	_ fs.ListRer        = &Fs{}
+	_ fs.Commander      = &Fs{}
+	_ fs.Object         = &Object{}
+	_ fs.MimeTyper      = &Object{}
+	_ fs.ServerModTimer = &Object{}
 )
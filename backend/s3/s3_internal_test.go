@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalParseSSECustomerKey(t *testing.T) {
+	raw := strings.Repeat("k", 32)
+	sum := md5.Sum([]byte(raw))
+
+	key, keyMD5, err := parseSSECustomerKey(base64.StdEncoding.EncodeToString([]byte(raw)))
+	require.NoError(t, err)
+	assert.Equal(t, raw, key)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(sum[:]), keyMD5)
+
+	// Not base64
+	_, _, err = parseSSECustomerKey("not base64!")
+	assert.Error(t, err)
+
+	// Wrong length
+	_, _, err = parseSSECustomerKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
+
+func TestInternalSetEncryption(t *testing.T) {
+	oldKMS, oldCustomer := *s3SSEKMSKeyID, *s3SSECustomerKey
+	defer func() { *s3SSEKMSKeyID, *s3SSECustomerKey = oldKMS, oldCustomer }()
+
+	// A KMS key implies aws:kms
+	*s3SSEKMSKeyID, *s3SSECustomerKey = "arn:aws:kms:key", ""
+	f := &Fs{}
+	require.NoError(t, f.setEncryption("TestS3InternalNotConfigured"))
+	assert.Equal(t, "aws:kms", f.sse)
+	assert.Equal(t, "arn:aws:kms:key", f.sseKMSKeyID)
+	assert.False(t, f.etagIsMD5())
+	algorithm, _, _ := f.sseCustomer()
+	assert.Nil(t, algorithm)
+
+	// SSE-C sets the headers
+	*s3SSEKMSKeyID = ""
+	*s3SSECustomerKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	f = &Fs{}
+	require.NoError(t, f.setEncryption("TestS3InternalNotConfigured"))
+	algorithm, key, keyMD5 := f.sseCustomer()
+	assert.Equal(t, "AES256", *algorithm)
+	assert.Equal(t, strings.Repeat("k", 32), *key)
+	assert.NotEqual(t, "", *keyMD5)
+	assert.False(t, f.etagIsMD5())
+
+	// Can't use both
+	*s3SSEKMSKeyID = "arn:aws:kms:key"
+	f = &Fs{}
+	assert.Error(t, f.setEncryption("TestS3InternalNotConfigured"))
+}
+
+func TestInternalCheckCannedACL(t *testing.T) {
+	for _, acl := range []string{"", "private", "public-read", "bucket-owner-full-control", "log-delivery-write"} {
+		assert.NoError(t, checkCannedACL(acl), acl)
+	}
+	for _, acl := range []string{"public", "publicRead", "PRIVATE"} {
+		assert.Error(t, checkCannedACL(acl), acl)
+	}
+	_, err := (&Fs{bucket: "bucket"}).Command("setacl", []string{"potato"})
+	assert.Error(t, err)
+	_, err = (&Fs{bucket: "bucket"}).Command("setacl", nil)
+	assert.Error(t, err)
+}
@@ -7,6 +7,8 @@ package sftp
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -40,8 +42,9 @@ var (
 	currentUser = readCurrentUser()
 
 	// Flags
-	sftpAskPassword = flags.BoolP("sftp-ask-password", "", false, "Allow asking for SFTP password when needed.")
-	sshPathOverride = flags.StringP("ssh-path-override", "", "", "Override path used by SSH connection.")
+	sftpAskPassword    = flags.BoolP("sftp-ask-password", "", false, "Allow asking for SFTP password when needed.")
+	sshPathOverride    = flags.StringP("ssh-path-override", "", "", "Override path used by SSH connection.")
+	sftpCopyIsHardlink = flags.BoolP("sftp-copy-is-hardlink", "", false, "Do server side copies by making hardlinks with ln over SSH.")
 )
 
 func init() {
@@ -285,6 +288,8 @@ func NewFs(name, root string) (fs.Fs, error) {
 	if port == "" {
 		port = "22"
 	}
+	// The Go SSH library only negotiates the "none" compression
+	// method so there is no way of turning compression on here
 	sshConfig := &ssh.ClientConfig{
 		User:            user,
 		Auth:            []ssh.AuthMethod{},
@@ -353,12 +358,17 @@ func NewFs(name, root string) (fs.Fs, error) {
 	}
 	f.features = (&fs.Features{
 		CanHaveEmptyDirectories: true,
+		SlowHash:                true,
 	}).Fill(f)
 	// Make a connection and pool it to return errors early
 	c, err := f.getSftpConnection()
 	if err != nil {
 		return nil, errors.Wrap(err, "NewFs")
 	}
+	// Server side copies are only possible with hardlinks
+	if !*sftpCopyIsHardlink {
+		f.features.Copy = nil
+	}
 	f.putSftpConnection(&c, nil)
 	if root != "" {
 		// Check to see if the root actually an existing file
@@ -523,6 +533,67 @@ func (f *Fs) PutStream(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption
 	return f.Put(in, src, options...)
 }
 
+// writerAt is a remote sftp file open for random access writes
+type writerAt struct {
+	mu       sync.Mutex // serialises the Seek and Write in WriteAt
+	sftpFile *sftp.File
+}
+
+// WriteAt writes len(p) bytes from p at offset off in the file
+func (w *writerAt) WriteAt(p []byte, off int64) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.sftpFile.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	return w.sftpFile.Write(p)
+}
+
+// Close the file
+func (w *writerAt) Close() error {
+	return w.sftpFile.Close()
+}
+
+// OpenWriterAt opens with a handle for random access writes
+//
+// Pass in the remote desired and the size if known.
+//
+// If the size is known the file is set to that size keeping any
+// existing data before it, otherwise it is truncated.
+func (f *Fs) OpenWriterAt(remote string, size int64) (fs.WriterAtCloser, error) {
+	err := f.mkParentDir(remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "OpenWriterAt mkParentDir failed")
+	}
+	// Temporary object under construction
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	c, err := f.getSftpConnection()
+	if err != nil {
+		return nil, errors.Wrap(err, "OpenWriterAt")
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	if size < 0 {
+		flags |= os.O_TRUNC
+	}
+	sftpFile, err := c.sftpClient.OpenFile(o.path(), flags)
+	f.putSftpConnection(&c, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "OpenWriterAt failed")
+	}
+	if size >= 0 {
+		err = sftpFile.Truncate(size)
+		if err != nil {
+			_ = sftpFile.Close()
+			return nil, errors.Wrap(err, "OpenWriterAt Truncate failed")
+		}
+	}
+	return &writerAt{sftpFile: sftpFile}, nil
+}
+
 // mkParentDir makes the parent of remote if necessary and any
 // directories above that
 func (f *Fs) mkParentDir(remote string) error {
@@ -579,6 +650,57 @@ func (f *Fs) Rmdir(dir string) error {
 	return err
 }
 
+// Copy src to this remote using server side copy operations.
+//
+// This makes a hardlink by running ln over SSH so is only enabled
+// with --sftp-copy-is-hardlink.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't copy - not same remote type")
+		return nil, fs.ErrorCantCopy
+	}
+	err := f.mkParentDir(remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "Copy mkParentDir failed")
+	}
+	// A hardlink can't be made over an existing file, so make it
+	// with a temporary name and rename it over the destination so
+	// the existing file is kept if this fails
+	var random [4]byte
+	_, err = rand.Read(random[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "Copy temporary name failed")
+	}
+	tmpRemote := remote + ".rclone-" + hex.EncodeToString(random[:]) + ".link"
+	err = f.run("ln -- " + srcObj.fs.shellPath(srcObj.remote) + " " + f.shellPath(tmpRemote))
+	if err != nil {
+		// eg the source and destination are on different devices
+		fs.Debugf(src, "Can't copy - hardlink failed: %v", err)
+		return nil, fs.ErrorCantCopy
+	}
+	err = f.run("mv -f -- " + f.shellPath(tmpRemote) + " " + f.shellPath(remote))
+	if err != nil {
+		if rmErr := f.run("rm -f -- " + f.shellPath(tmpRemote)); rmErr != nil {
+			fs.Errorf(src, "Failed to remove temporary hardlink: %v", rmErr)
+		}
+		return nil, errors.Wrap(err, "Copy rename failed")
+	}
+	dstObj, err := f.NewObject(remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "Copy NewObject failed")
+	}
+	return dstObj, nil
+}
+
 // Move renames a remote sftp file object
 func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
 	srcObj, ok := src.(*Object)
@@ -594,10 +716,7 @@ func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "Move")
 	}
-	err = c.sftpClient.Rename(
-		srcObj.path(),
-		path.Join(f.root, remote),
-	)
+	err = f.rename(c, srcObj.path(), path.Join(f.root, remote))
 	f.putSftpConnection(&c, err)
 	if err != nil {
 		return nil, errors.Wrap(err, "Move Rename failed")
@@ -609,6 +728,32 @@ func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
 	return dstObj, nil
 }
 
+// rename srcPath to dstPath replacing dstPath if it exists
+//
+// A plain SFTP rename fails if dstPath exists on most servers
+// (eg OpenSSH) so if that happens dstPath is removed and the rename
+// is tried again.
+func (f *Fs) rename(c *conn, srcPath, dstPath string) error {
+	err := c.sftpClient.Rename(srcPath, dstPath)
+	if err == nil {
+		return nil
+	}
+	if _, statErr := c.sftpClient.Lstat(dstPath); statErr != nil {
+		// The destination wasn't in the way
+		return err
+	}
+	if _, statErr := c.sftpClient.Lstat(srcPath); statErr != nil {
+		// Don't remove the destination if the source is missing
+		return err
+	}
+	fs.Debugf(f, "Removing %q so %q can be renamed over it", dstPath, srcPath)
+	err = c.sftpClient.Remove(dstPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to remove existing destination")
+	}
+	return c.sftpClient.Rename(srcPath, dstPath)
+}
+
 // DirMove moves src, srcRemote to this remote at dstRemote
 // using server side move operations.
 //
@@ -787,6 +932,37 @@ func shellEscape(str string) string {
 	return strings.Replace(safe, "\n", "'\n'", -1)
 }
 
+// shellPath returns the path of remote escaped for running commands
+// on the server over SSH
+func (f *Fs) shellPath(remote string) string {
+	if *sshPathOverride != "" {
+		return shellEscape(path.Join(*sshPathOverride, remote))
+	}
+	return shellEscape(path.Join(f.root, remote))
+}
+
+// run runs cmd on the server over SSH, returning an error with
+// anything it wrote to stderr if it fails
+func (f *Fs) run(cmd string) error {
+	c, err := f.getSftpConnection()
+	if err != nil {
+		return errors.Wrap(err, "run get SFTP connection")
+	}
+	session, err := c.sshClient.NewSession()
+	f.putSftpConnection(&c, err)
+	if err != nil {
+		return errors.Wrap(err, "run new session")
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	err = session.Run(cmd)
+	_ = session.Close()
+	if err != nil {
+		return errors.Errorf("%s: %v (%s)", cmd, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
 // Converts a byte array from the SSH session returned by
 // an invocation of md5sum/sha1sum to a hash string
 // as expected by the rest of this application
@@ -1010,9 +1186,11 @@ func (o *Object) Remove() error {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.Mover       = &Fs{}
-	_ fs.DirMover    = &Fs{}
-	_ fs.Object      = &Object{}
+	_ fs.Fs             = &Fs{}
+	_ fs.PutStreamer    = &Fs{}
+	_ fs.Mover          = &Fs{}
+	_ fs.Copier         = &Fs{}
+	_ fs.DirMover       = &Fs{}
+	_ fs.OpenWriterAter = &Fs{}
+	_ fs.Object         = &Object{}
 )
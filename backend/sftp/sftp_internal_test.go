@@ -3,10 +3,26 @@
 package sftp
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"testing"
+	"time"
 
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/config/obscure"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/ncw/rclone/fs/operations"
+	pkgsftp "github.com/pkg/sftp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestShellEscape(t *testing.T) {
@@ -35,3 +51,140 @@ func TestParseHash(t *testing.T) {
 		assert.Equal(t, test.checksum, got, fmt.Sprintf("Test %d sshOutput = %q", i, test.sshOutput))
 	}
 }
+
+// startServer starts an SSH server on localhost with an in memory
+// SFTP subsystem which, like OpenSSH, won't rename over an existing
+// file.  It returns the port it is listening on.
+func startServer(t *testing.T) (port string, stop func()) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "user" && string(pass) == "pass" {
+				return nil, nil
+			}
+			return nil, errors.New("bad password")
+		},
+	}
+	config.AddHostKey(signer)
+	handlers := pkgsftp.InMemHandler()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(nConn, config, handlers)
+		}
+	}()
+	_, port, err = net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	return port, func() { _ = listener.Close() }
+}
+
+// serveConn serves the SFTP subsystem on a single SSH connection
+func serveConn(nConn net.Conn, config *ssh.ServerConfig, handlers pkgsftp.Handlers) {
+	_, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				// Only the sftp subsystem is supported, so
+				// hash commands fail
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if ok {
+					go func() {
+						_ = pkgsftp.NewRequestServer(channel, handlers).Serve()
+						_ = channel.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// newTestFs makes an Fs connected to the server from startServer
+func newTestFs(t *testing.T, port string) *Fs {
+	const name = "TestSftpInternal"
+	config.FileSet(name, "type", "sftp")
+	config.FileSet(name, "host", "127.0.0.1")
+	config.FileSet(name, "port", port)
+	config.FileSet(name, "user", "user")
+	config.FileSet(name, "pass", obscure.MustObscure("pass"))
+	f, err := NewFs(name, "/test")
+	require.NoError(t, err)
+	return f.(*Fs)
+}
+
+func put(t *testing.T, f *Fs, remote, contents string) fs.Object {
+	src := object.NewStaticObjectInfo(remote, time.Now(), int64(len(contents)), true, nil, nil)
+	o, err := f.Put(bytes.NewBufferString(contents), src)
+	require.NoError(t, err)
+	return o
+}
+
+func readAll(t *testing.T, o fs.Object) string {
+	in, err := o.Open()
+	require.NoError(t, err)
+	defer func() { _ = in.Close() }()
+	data, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestInternalMoveReplacesExisting(t *testing.T) {
+	port, stop := startServer(t)
+	defer stop()
+	f := newTestFs(t, port)
+	require.NoError(t, f.Mkdir(""))
+
+	put(t, f, "file.txt", "old contents")
+	src := put(t, f, "file.txt.partial", "new contents")
+
+	dst, err := f.Move(src, "file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "file.txt", dst.Remote())
+	assert.Equal(t, "new contents", readAll(t, dst))
+
+	_, err = f.NewObject("file.txt.partial")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+
+	// A missing source mustn't remove the destination
+	_, err = f.Move(src, "file.txt")
+	assert.Error(t, err)
+	dst, err = f.NewObject("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "new contents", readAll(t, dst))
+}
+
+func TestInternalUpdateExisting(t *testing.T) {
+	port, stop := startServer(t)
+	defer stop()
+	f := newTestFs(t, port)
+	require.NoError(t, f.Mkdir(""))
+
+	dst := put(t, f, "file.txt", "old contents")
+	src := put(t, f, "new.txt", "new contents, longer")
+
+	// This copies via a partial file which is renamed over dst
+	newDst, err := operations.Copy(f, dst, "file.txt", src)
+	require.NoError(t, err)
+	assert.Equal(t, "new contents, longer", readAll(t, newDst))
+}
@@ -754,6 +754,12 @@ func (o *Object) ModTime() time.Time {
 	return modTime
 }
 
+// ServerModTime returns the LastModified time of the object on the
+// server
+func (o *Object) ServerModTime() time.Time {
+	return o.info.LastModified
+}
+
 // SetModTime sets the modification time of the local fs object
 func (o *Object) SetModTime(modTime time.Time) error {
 	err := o.readMetaData()
@@ -975,11 +981,12 @@ func (o *Object) MimeType() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Purger      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
+	_ fs.Fs             = &Fs{}
+	_ fs.Purger         = &Fs{}
+	_ fs.PutStreamer    = &Fs{}
+	_ fs.Copier         = &Fs{}
+	_ fs.ListRer        = &Fs{}
+	_ fs.Object         = &Object{}
+	_ fs.MimeTyper      = &Object{}
+	_ fs.ServerModTimer = &Object{}
 )
@@ -32,6 +32,7 @@ import (
 	"github.com/ncw/rclone/backend/webdav/odrvcookie"
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/config/flags"
 	"github.com/ncw/rclone/fs/config/obscure"
 	"github.com/ncw/rclone/fs/fserrors"
 	"github.com/ncw/rclone/fs/fshttp"
@@ -91,10 +92,30 @@ func init() {
 			Name:     "bearer_token",
 			Help:     "Bearer token instead of user/pass (eg a Macaroon)",
 			Optional: true,
+		}, {
+			Name:     "ca_cert",
+			Help:     "Path to a PEM file of CA certificates to verify the server with instead of the system ones.",
+			Optional: true,
+		}, {
+			Name:     "client_cert",
+			Help:     "Path to a PEM client certificate for mutual TLS.",
+			Optional: true,
+		}, {
+			Name:     "client_key",
+			Help:     "Path to the PEM key for the client certificate.",
+			Optional: true,
 		}},
 	})
 }
 
+// Globals
+var (
+	// Flags
+	webdavCACert     = flags.StringP("webdav-ca-cert", "", "", "Path to a PEM file of CA certificates to verify the server with.")
+	webdavClientCert = flags.StringP("webdav-client-cert", "", "", "Path to a PEM client certificate for mutual TLS.")
+	webdavClientKey  = flags.StringP("webdav-client-key", "", "", "Path to the PEM key for --webdav-client-cert.")
+)
+
 // Fs represents a remote webdav
 type Fs struct {
 	name        string        // name of this remote
@@ -289,13 +310,21 @@ func NewFs(name, root string) (fs.Fs, error) {
 	if err != nil {
 		return nil, err
 	}
+	client, err := fshttp.NewClientTLS(fs.Config, config.FileGetTLSOptions(name, fshttp.TLSOptions{
+		CACert:     *webdavCACert,
+		ClientCert: *webdavClientCert,
+		ClientKey:  *webdavClientKey,
+	}))
+	if err != nil {
+		return nil, errors.Wrap(err, "webdav")
+	}
 
 	f := &Fs{
 		name:        name,
 		root:        root,
 		endpoint:    u,
 		endpointURL: u.String(),
-		srv:         rest.NewClient(fshttp.NewClient(fs.Config)).SetRoot(u.String()),
+		srv:         rest.NewClient(client).SetRoot(u.String()),
 		pacer:       pacer.New().SetMinSleep(minSleep).SetMaxSleep(maxSleep).SetDecayConstant(decayConstant),
 		user:        user,
 		pass:        pass,
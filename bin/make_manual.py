@@ -28,6 +28,7 @@ docs = [
     "b2.md",
     "box.md",
     "cache.md",
+    "compress.md",
     "crypt.md",
     "dropbox.md",
     "ftp.md",
@@ -35,6 +36,7 @@ docs = [
     "drive.md",
     "http.md",
     "hubic.md",
+    "ipfs.md",
     "mega.md",
     "azureblob.md",
     "onedrive.md",
@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/operations"
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var jsonOutput bool
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	commandDefinition.Flags().BoolVarP(&jsonOutput, "json", "", false, "Always print the result as JSON.")
+	rc.Add(rc.Call{
+		Path:  "backend/command",
+		Fn:    rcCommand,
+		Title: "Runs a backend command.",
+		Help: `
+This takes the following parameters
+
+- command - a string with the command name
+- fs - a remote name string eg "drive:"
+- arg - a string with an argument for the command (optional)
+
+Eg
+
+    rclone rc backend/command command=set fs=drive: arg=chunk_size=64M
+
+This runs the command in the running rclone, so it can be used to
+change settings in "rclone mount" or "rclone serve" run with --rc.
+
+The result of the command is returned in "result".
+
+The "features" command is supported by all remotes, eg
+
+    rclone rc backend/command command=features fs=drive:
+`,
+	})
+}
+
+// runCommand runs the backend command name on f with args
+func runCommand(f fs.Fs, name string, args []string) (interface{}, error) {
+	// "features" is available for all backends
+	if name == "features" {
+		return operations.GetFsInfo(f), nil
+	}
+	doCommand := f.Features().Command
+	if doCommand == nil {
+		return nil, errors.Errorf("%v doesn't support backend commands", f)
+	}
+	out, err := doCommand(name, args)
+	if err == fs.ErrorCommandNotFound {
+		return nil, errors.Errorf("%v doesn't support backend command %q", f, name)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "command %q failed", name)
+	}
+	return out, nil
+}
+
+// rcCommand runs a backend command given the rc parameters
+func rcCommand(in rc.Params) (out rc.Params, err error) {
+	name, ok := in["command"].(string)
+	if !ok {
+		return nil, errors.New("command is needed")
+	}
+	fsName, ok := in["fs"].(string)
+	if !ok {
+		return nil, errors.New("fs is needed")
+	}
+	var args []string
+	if arg, ok := in["arg"].(string); ok {
+		args = append(args, arg)
+	}
+	f, err := fs.NewFs(fsName)
+	if err != nil {
+		return nil, err
+	}
+	result, err := runCommand(f, name, args)
+	if err != nil {
+		return nil, err
+	}
+	out = make(rc.Params)
+	out["result"] = result
+	return out, nil
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "backend <command> remote:path [args...]",
+	Short: `Run a backend specific command.`,
+	Long: `
+This runs a backend specific command.  The commands themselves are
+defined by the backends and you should see the backend docs for
+definitions.  For example
+
+    rclone backend untrash drive:path
+
+Will run the "untrash" command on a drive remote.  Not all remotes
+support backend commands.
+
+The "features" command is supported by all remotes.  It shows what
+the remote can do, as configured, so scripts don't have to know about
+each backend
+
+    rclone backend features remote:
+
+This prints JSON with the name, root and description of the remote,
+the precision of its modification times in nanoseconds, the hash
+types it supports and a map of its optional features, eg "Copy" for
+server side copy or "PublicLink", showing whether each is available.
+
+The result of the command is printed as JSON, unless the command
+makes a table or text for its result in which case use --json to see
+the JSON.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 1e6, command, args)
+		name, remote := args[0], args[1]
+		f := cmd.NewFsSrc([]string{remote})
+		cmd.Run(false, false, command, func() error {
+			out, err := runCommand(f, name, args[2:])
+			if err != nil {
+				return err
+			}
+			if out == nil {
+				return nil
+			}
+			if texter, ok := out.(fs.CommandTexter); ok && !jsonOutput {
+				fmt.Println(texter.CommandText())
+				return nil
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			return enc.Encode(out)
+		})
+	},
+}
@@ -1,8 +1,13 @@
 package check
 
 import (
+	"bufio"
+	"io"
+	"os"
+
 	"github.com/ncw/rclone/cmd"
 	"github.com/ncw/rclone/fs/operations"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -10,12 +15,14 @@ import (
 var (
 	download = false
 	oneway   = false
+	combined = ""
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefintion)
 	commandDefintion.Flags().BoolVarP(&download, "download", "", download, "Check by downloading rather than with hash.")
 	commandDefintion.Flags().BoolVarP(&oneway, "one-way", "", oneway, "Check one way only, source files must exist on remote")
+	commandDefintion.Flags().StringVarP(&combined, "combined", "", combined, "Write a report of the status of every file checked to this file, - for stdout")
 }
 
 var commandDefintion = &cobra.Command{
@@ -37,15 +44,60 @@ to check all the data.
 If you supply the --one-way flag, it will only check that files in source
 match the files in destination, not the other way around. Meaning extra files in
 destination that are not in the source will not trigger an error.
+
+If you supply the --combined flag with a file name (or "-" for
+standard output), a line is written to it for every file checked, as
+it is checked.  Each line is the path of the file prefixed by a status
+character and a space
+
+    = path means path was found in source and destination and was identical
+    * path means path was found in source and destination but was different
+    + path means path was missing on the source, so only in the destination
+    - path means path was missing on the destination, so only in the source
+    ! path means there was an error reading or hashing the source or dest
+
+The lines are in the order the files were checked, so sort the file
+if you want to compare two reports.
 `,
-	Run: func(command *cobra.Command, args []string) {
+	RunE: func(command *cobra.Command, args []string) error {
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, fdst := cmd.NewFsSrcDst(args)
+		var (
+			out     io.Writer
+			buf     *bufio.Writer
+			outFile = os.Stdout
+		)
+		if combined != "" {
+			if combined != "-" {
+				var err error
+				outFile, err = os.Create(combined)
+				if err != nil {
+					return errors.Errorf("failed to create combined report: %v", err)
+				}
+			}
+			buf = bufio.NewWriter(outFile)
+			out = buf
+		}
 		cmd.Run(false, false, command, func() error {
+			var err error
 			if download {
-				return operations.CheckDownload(fdst, fsrc, oneway)
+				err = operations.CheckDownload(fdst, fsrc, oneway, out)
+			} else {
+				err = operations.Check(fdst, fsrc, oneway, out)
+			}
+			if buf != nil {
+				// cmd.Run exits on error so finish the report here
+				if flushErr := buf.Flush(); flushErr != nil && err == nil {
+					err = errors.Wrap(flushErr, "failed to write combined report")
+				}
+				if outFile != os.Stdout {
+					if closeErr := outFile.Close(); closeErr != nil && err == nil {
+						err = errors.Wrap(closeErr, "failed to close combined report")
+					}
+				}
 			}
-			return operations.Check(fdst, fsrc, oneway)
+			return err
 		})
+		return nil
 	},
 }
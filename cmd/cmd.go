@@ -30,7 +30,9 @@ import (
 	"github.com/ncw/rclone/fs/filter/filterflags"
 	"github.com/ncw/rclone/fs/fserrors"
 	"github.com/ncw/rclone/fs/fspath"
+	"github.com/ncw/rclone/fs/listcache"
 	fslog "github.com/ncw/rclone/fs/log"
+	"github.com/ncw/rclone/fs/operations"
 	"github.com/ncw/rclone/fs/rc"
 	"github.com/ncw/rclone/fs/rc/rcflags"
 	"github.com/ncw/rclone/lib/atexit"
@@ -63,6 +65,7 @@ const (
 	exitCodeNoRetryError
 	exitCodeFatalError
 	exitCodeTransferExceeded
+	exitCodeDeleteExceeded
 )
 
 // Root is the main rclone command
@@ -159,9 +162,9 @@ func NewFsFile(remote string) (fs.Fs, string) {
 	f, err := fsInfo.NewFs(configName, fsPath)
 	switch err {
 	case fs.ErrorIsFile:
-		return f, path.Base(fsPath)
+		return listcache.Wrap(f), path.Base(fsPath)
 	case nil:
-		return f, ""
+		return listcache.Wrap(f), ""
 	default:
 		fs.CountError(err)
 		log.Fatalf("Failed to create file system for %q: %v", remote, err)
@@ -209,7 +212,7 @@ func newFsDir(remote string) fs.Fs {
 		fs.CountError(err)
 		log.Fatalf("Failed to create file system for %q: %v", remote, err)
 	}
-	return f
+	return listcache.Wrap(f)
 }
 
 // NewFsDir creates a new Fs from the arguments
@@ -263,6 +266,7 @@ func NewFsSrcDstFiles(args []string) (fsrc fs.Fs, srcFileName string, fdst fs.Fs
 		fs.CountError(err)
 		log.Fatalf("Failed to create file system for destination %q: %v", dstRemote, err)
 	}
+	fdst = listcache.Wrap(fdst)
 	return
 }
 
@@ -330,6 +334,11 @@ func Run(Retry bool, showStats bool, cmd *cobra.Command, f func() error) {
 	if showStats {
 		close(stopStats)
 	}
+	lastErr := err
+	if lastErr == nil {
+		lastErr = accounting.Stats.GetLastError()
+	}
+	fslog.ErrorSummary(lastErr, accounting.Stats.GetErrors(), accounting.Stats.GetChecks(), accounting.Stats.GetTransfers(), accounting.Stats.GetBytes())
 	if err != nil {
 		log.Printf("Failed to %s: %v", cmd.Name(), err)
 		resolveExitCode(err)
@@ -486,6 +495,8 @@ func resolveExitCode(err error) {
 		os.Exit(exitCodeUncategorizedError)
 	case unwrapped == accounting.ErrorMaxTransferLimitReached:
 		os.Exit(exitCodeTransferExceeded)
+	case unwrapped == operations.ErrorMaxDeleteLimitReached:
+		os.Exit(exitCodeDeleteExceeded)
 	case fserrors.ShouldRetry(err):
 		os.Exit(exitCodeRetryError)
 	case fserrors.IsNoRetryError(err):
@@ -325,6 +325,9 @@ func (fsys *FS) Create(filePath string, flags int, mode uint32) (errc int, fh ui
 // Truncate truncates a file to size
 func (fsys *FS) Truncate(path string, size int64, fh uint64) (errc int) {
 	defer log.Trace(path, "size=%d, fh=0x%X", size, fh)("errc=%d", &errc)
+	if fsys.VFS.Opt.ReadOnly {
+		return -fuse.EROFS
+	}
 	node, handle, errc := fsys.getNode(path, fh)
 	if errc != 0 {
 		return errc
@@ -447,6 +450,9 @@ func (fsys *FS) Utimens(path string, tmsp []fuse.Timespec) (errc int) {
 // Mknod creates a file node.
 func (fsys *FS) Mknod(path string, mode uint32, dev uint64) (errc int) {
 	defer log.Trace(path, "mode=0x%X, dev=0x%X", mode, dev)("errc=%d", &errc)
+	if fsys.VFS.Opt.ReadOnly {
+		return -fuse.EROFS
+	}
 	return -fuse.ENOSYS
 }
 
@@ -460,12 +466,18 @@ func (fsys *FS) Fsync(path string, datasync bool, fh uint64) (errc int) {
 // Link creates a hard link to a file.
 func (fsys *FS) Link(oldpath string, newpath string) (errc int) {
 	defer log.Trace(oldpath, "newpath=%q", newpath)("errc=%d", &errc)
+	if fsys.VFS.Opt.ReadOnly {
+		return -fuse.EROFS
+	}
 	return -fuse.ENOSYS
 }
 
 // Symlink creates a symbolic link.
 func (fsys *FS) Symlink(target string, newpath string) (errc int) {
 	defer log.Trace(target, "newpath=%q", newpath)("errc=%d", &errc)
+	if fsys.VFS.Opt.ReadOnly {
+		return -fuse.EROFS
+	}
 	return -fuse.ENOSYS
 }
 
@@ -478,6 +490,9 @@ func (fsys *FS) Readlink(path string) (errc int, linkPath string) {
 // Chmod changes the permission bits of a file.
 func (fsys *FS) Chmod(path string, mode uint32) (errc int) {
 	defer log.Trace(path, "mode=0%o", mode)("errc=%d", &errc)
+	if fsys.VFS.Opt.ReadOnly {
+		return -fuse.EROFS
+	}
 	// This is a no-op for rclone
 	return 0
 }
@@ -485,6 +500,9 @@ func (fsys *FS) Chmod(path string, mode uint32) (errc int) {
 // Chown changes the owner and group of a file.
 func (fsys *FS) Chown(path string, uid uint32, gid uint32) (errc int) {
 	defer log.Trace(path, "uid=%d, gid=%d", uid, gid)("errc=%d", &errc)
+	if fsys.VFS.Opt.ReadOnly {
+		return -fuse.EROFS
+	}
 	// This is a no-op for rclone
 	return 0
 }
@@ -510,7 +528,20 @@ func (fsys *FS) Setxattr(path string, name string, value []byte, flags int) (err
 
 // Getxattr gets extended attributes.
 func (fsys *FS) Getxattr(path string, name string) (errc int, value []byte) {
-	return -fuse.ENOSYS, nil
+	if !fsys.VFS.Opt.XattrHashes {
+		return -fuse.ENOSYS, nil
+	}
+	defer log.Trace(path, "name=%q", name)("errc=%d, value=%q", &errc, &value)
+	node, errc := fsys.lookupNode(path)
+	if errc != 0 {
+		return errc, nil
+	}
+	file, ok := node.(*vfs.File)
+	if !ok {
+		return -fuse.ENOATTR, nil
+	}
+	value, err := file.Getxattr(name)
+	return translateError(err), value
 }
 
 // Removexattr removes extended attributes.
@@ -520,7 +551,22 @@ func (fsys *FS) Removexattr(path string, name string) (errc int) {
 
 // Listxattr lists extended attributes.
 func (fsys *FS) Listxattr(path string, fill func(name string) bool) (errc int) {
-	return -fuse.ENOSYS
+	if !fsys.VFS.Opt.XattrHashes {
+		return -fuse.ENOSYS
+	}
+	defer log.Trace(path, "")("errc=%d", &errc)
+	node, errc := fsys.lookupNode(path)
+	if errc != 0 {
+		return errc
+	}
+	if file, ok := node.(*vfs.File); ok {
+		for _, name := range file.Listxattr() {
+			if !fill(name) {
+				return -fuse.ERANGE
+			}
+		}
+	}
+	return 0
 }
 
 // Translate errors from mountlib
@@ -549,6 +595,8 @@ func translateError(err error) (errc int) {
 		return -fuse.EROFS
 	case vfs.ENOSYS:
 		return -fuse.ENOSYS
+	case vfs.ENOATTR:
+		return -fuse.ENOATTR
 	case vfs.EINVAL:
 		return -fuse.EINVAL
 	}
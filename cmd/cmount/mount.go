@@ -214,11 +214,14 @@ func Mount(f fs.Fs, mountpoint string) error {
 		return errors.Wrap(err, "failed to notify systemd")
 	}
 
+	var drainErr error
 waitloop:
 	for {
 		select {
 		// umount triggered outside the app
 		case err = <-errChan:
+			// wait for any uploads still in progress
+			drainErr = mountlib.Drain(FS)
 			break waitloop
 		// user sent SIGHUP to clear the cache
 		case <-sigHup:
@@ -236,5 +239,5 @@ waitloop:
 		return errors.Wrap(err, "failed to umount FUSE fs")
 	}
 
-	return nil
+	return drainErr
 }
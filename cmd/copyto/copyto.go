@@ -40,6 +40,15 @@ This will:
 This doesn't transfer unchanged files, testing by size and
 modification time or MD5SUM.  It doesn't delete files from the
 destination.
+
+The copy is checked once it is made, by size and by hash if the
+source and destination have one in common (eg MD5 for a local file
+copied to drive).  If it doesn't match it is removed and rclone exits
+with a non-zero status.  Only the one file is looked up on the source
+and destination so there is no need for --no-traverse.
+
+Some remotes, eg drive, can resume a large upload which was
+interrupted from where it got to when the same command is run again.
 `,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(2, 2, command, args)
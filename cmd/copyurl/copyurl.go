@@ -0,0 +1,65 @@
+package copyurl
+
+import (
+	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+// Globals
+var (
+	checksumHeader = ""
+	expectHash     = ""
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	flags := commandDefinition.Flags()
+	flags.StringVarP(&checksumHeader, "checksum-header", "", checksumHeader, "Check the download against the MD5 or SHA-1 in this response header, eg Content-MD5 or Digest.")
+	flags.StringVarP(&expectHash, "expect-hash", "", expectHash, "Check the download has this MD5 or SHA-1 checksum.")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "copyurl https://example.com dest:path/to/file",
+	Short: `Copy url content to dest.`,
+	Long: `
+Download urls content and copy it to destination without saving it in
+temporary storage.
+
+    rclone copyurl https://example.com/file.iso remote:path/to/file.iso
+
+If the connection fails part way through the download, rclone asks the
+server for the rest of the file with an HTTP Range request (if the
+server supports them) and carries on, so the upload to the
+destination isn't interrupted.  This is tried up to
+` + "`--low-level-retries`" + ` times.
+
+If the destination supports writing to the middle of files (only the
+local disk at the moment), the file is downloaded to a partial file
+with ` + "`--partial-suffix`" + ` on the end of its name, which is
+renamed when the download is complete.  If an interrupted copyurl
+leaves a partial file, running it again downloads only the rest of the
+file, as long as the end of the partial file matches the data being
+downloaded.  Otherwise the whole file is downloaded again.  An
+existing destination file is never resumed, only replaced.
+
+If the size of the download is not known then the data is streamed to
+the destination in the same way as ` + "`rclone rcat`" + `.
+
+The download can be checked against an MD5 or SHA-1 checksum, either
+from a header in the response with ` + "`--checksum-header`" + `, eg
+` + "`Content-MD5`" + ` or ` + "`Digest`" + `, or given with
+` + "`--expect-hash`" + ` in hex or base64.  If the check fails the
+destination file is deleted and an error returned.
+
+    rclone copyurl --expect-hash 5d41402abc4b2a76b9719d911017c592 https://example.com/file.iso remote:file.iso
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fdst, dstFileName := cmd.NewFsDstFile(args[1:])
+		cmd.Run(true, true, command, func() error {
+			_, err := operations.CopyURL(fdst, dstFileName, args[0], checksumHeader, expectHash)
+			return err
+		})
+	},
+}
@@ -110,5 +110,5 @@ func cryptCheck(fdst, fsrc fs.Fs) error {
 		return false, false
 	}
 
-	return operations.CheckFn(fcrypt, fsrc, checkIdentical, oneway)
+	return operations.CheckFn(fcrypt, fsrc, checkIdentical, oneway, nil)
 }
@@ -0,0 +1,97 @@
+package genpresign
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/cmd/serve/httplib"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/operations"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Globals
+var (
+	method     = "GET"
+	expire     = time.Hour
+	serverURL  = ""
+	presignKey = ""
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	flags := commandDefinition.Flags()
+	flags.StringVarP(&method, "method", "", method, "HTTP method the URL is for, GET or PUT.")
+	flags.DurationVarP(&expire, "expire", "", expire, "How long the URL is valid for.")
+	flags.StringVarP(&serverURL, "url", "", serverURL, "URL of the rclone serve http server to presign for.")
+	flags.StringVarP(&presignKey, "presign-key", "", presignKey, "Key to sign the URL with - must match the server.")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "genpresign remote:path",
+	Short: `Generate a temporary URL to download or upload a file.`,
+	Long: `
+rclone genpresign prints a URL which can be given to a client to
+download (GET) or upload (PUT) a single file directly without needing
+rclone or any credentials.
+
+If --url isn't set then rclone will use the remote's own public link
+facility, as used by "rclone link".  This only works for downloads and
+only on remotes which support public links.  The expiry of the link is
+controlled by the remote, so --expire is ignored in this case.
+
+    rclone genpresign remote:path/to/file
+
+If --url is set then rclone will make a URL for the "rclone serve
+http" server at that URL, signed with --presign-key, which will stop
+working after --expire.  This works with any remote, including the
+local disk.  The server must be started with the same --presign-key
+and it must serve the root of the remote, so the path after the
+"remote:" is the path on the server.
+
+    rclone serve http remote: --addr :8080 --presign-key KEY
+    rclone genpresign remote:path/to/file --url http://example.com:8080/ --presign-key KEY --expire 10m
+    rclone genpresign remote:path/to/upload --method PUT --url http://example.com:8080/ --presign-key KEY
+
+The server checks the signature, the method and the expiry before
+serving the request, even if it is using authentication, so the rclone
+process only has to proxy the data.
+
+If successful, the last line of the output will contain the URL.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		method = strings.ToUpper(method)
+		if serverURL == "" {
+			fsrc, remote := cmd.NewFsFile(args[0])
+			cmd.Run(false, false, command, func() error {
+				if method != "GET" {
+					return errors.Errorf("can only make %s URLs with --url", method)
+				}
+				fs.Logf(nil, "Using the remote's public link: --expire is ignored")
+				link, err := operations.PublicLink(fsrc, remote)
+				if err != nil {
+					return err
+				}
+				fmt.Println(link)
+				return nil
+			})
+			return
+		}
+		cmd.Run(false, false, command, func() error {
+			_, _, fsPath, err := fs.ParseRemote(args[0])
+			if err != nil {
+				return err
+			}
+			link, err := httplib.PresignURL(serverURL, presignKey, method, fsPath, time.Now().Add(expire))
+			if err != nil {
+				return err
+			}
+			fmt.Println(link)
+			return nil
+		})
+	},
+}
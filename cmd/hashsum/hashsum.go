@@ -6,13 +6,21 @@ import (
 	"os"
 
 	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/flags"
 	"github.com/ncw/rclone/fs/hash"
 	"github.com/ncw/rclone/fs/operations"
 	"github.com/spf13/cobra"
 )
 
+// Globals
+var (
+	checkFile = ""
+)
+
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
+	flags.StringVarP(commandDefinition.Flags(), &checkFile, "checkfile", "", checkFile, "Check the files listed in this sum file instead of producing a sum file.")
 }
 
 var commandDefinition = &cobra.Command{
@@ -35,6 +43,23 @@ Run without a hash to see the list of supported hashes, eg
 Then
 
     $ rclone hashsum MD5 remote:path
+
+Use ` + "`--checkfile sums.txt`" + ` to check the files listed in a sum file in
+the same format (eg as made by md5sum or this command) against the
+remote instead.  This prints one of these for each line of the file
+
+  * ` + "`MATCH`" + ` - the file's hash is the same as the one in the sum file
+  * ` + "`MISS`" + ` - the file wasn't found on the remote
+  * ` + "`HASH_DIFF`" + ` - the file's hash is different
+  * ` + "`ERROR`" + ` - the hash of the file couldn't be read
+
+followed by the file name, and exits with an error if any of the
+files didn't match.  The sum file is read as it is checked so it can
+be as big as you like, and the files are checked ` + "`--checkers`" + ` at
+a time.  The hash is read from the remote if it supports it,
+otherwise the file is downloaded to work it out.
+
+    $ rclone hashsum MD5 remote:path --checkfile sums.txt
 `,
 	RunE: func(command *cobra.Command, args []string) error {
 		cmd.CheckArgs(0, 2, command, args)
@@ -54,8 +79,21 @@ Then
 		}
 		fsrc := cmd.NewFsSrc(args[1:])
 		cmd.Run(false, false, command, func() error {
+			if checkFile != "" {
+				return checkSums(ht, fsrc)
+			}
 			return operations.HashLister(ht, fsrc, os.Stdout)
 		})
 		return nil
 	},
 }
+
+// checkSums checks the files in the --checkfile against f
+func checkSums(ht hash.Type, f fs.Fs) (err error) {
+	in, err := os.Open(checkFile)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	return operations.CheckFile(ht, f, in, os.Stdout)
+}
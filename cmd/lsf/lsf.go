@@ -9,6 +9,7 @@ import (
 	"github.com/ncw/rclone/cmd/ls/lshelp"
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/hash"
+	fslist "github.com/ncw/rclone/fs/list"
 	"github.com/ncw/rclone/fs/operations"
 	"github.com/ncw/rclone/fs/walk"
 	"github.com/pkg/errors"
@@ -59,6 +60,12 @@ Eg
     ferejej3gux/
     fubuwic
 
+If the remote can list a page at a time (eg Google Drive) and
+--recursive isn't used then the entries are printed as each page is
+read, so directories with millions of entries can be listed without
+using lots of memory.  In this case the entries are only sorted
+within each page.
+
 Use the --format option to control what gets listed.  By default this
 is just the path, but you can use these parameters to control the
 output:
@@ -181,12 +188,7 @@ func Lsf(fsrc fs.Fs, out io.Writer) error {
 		}
 	}
 
-	return walk.Walk(fsrc, "", false, operations.ConfigMaxDepth(recurse), func(path string, entries fs.DirEntries, err error) error {
-		if err != nil {
-			fs.CountError(err)
-			fs.Errorf(path, "error listing: %v", err)
-			return nil
-		}
+	printEntries := func(entries fs.DirEntries) error {
 		for _, entry := range entries {
 			_, isDir := entry.(fs.Directory)
 			if isDir {
@@ -201,5 +203,25 @@ func Lsf(fsrc fs.Fs, out io.Writer) error {
 			_, _ = fmt.Fprintln(out, list.Format(entry))
 		}
 		return nil
+	}
+
+	maxDepth := operations.ConfigMaxDepth(recurse)
+	if maxDepth == 1 {
+		// Print a page at a time so huge directories don't
+		// have to be read into memory first
+		err := fslist.DirPaged(fsrc, false, "", printEntries)
+		if err != nil {
+			fs.CountError(err)
+			fs.Errorf(nil, "error listing: %v", err)
+		}
+		return nil
+	}
+	return walk.Walk(fsrc, "", false, maxDepth, func(path string, entries fs.DirEntries, err error) error {
+		if err != nil {
+			fs.CountError(err)
+			fs.Errorf(path, "error listing: %v", err)
+			return nil
+		}
+		return printEntries(entries)
 	})
 }
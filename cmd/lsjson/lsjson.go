@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path"
+	"sort"
 	"time"
 
 	"github.com/ncw/rclone/backend/crypt"
@@ -46,6 +47,43 @@ type lsJSON struct {
 	ID        string            `json:",omitempty"`
 }
 
+// lessFn compares two entries returning true if a should be first
+type lessFn func(a, b fs.DirEntry) bool
+
+// parseOrderBy turns --order-by into a function to sort the entries
+// of each directory with, returning nil if they shouldn't be sorted
+func parseOrderBy(config string) (lessFn, error) {
+	o, err := fs.ParseOrderBy(config)
+	if err != nil || o == nil {
+		return nil, err
+	}
+	if o.Mixed {
+		return nil, errors.Errorf("--order-by %q: mixed isn't supported by lsjson", config)
+	}
+	return o.Less, nil
+}
+
+// entrySorter sorts entries with less
+type entrySorter struct {
+	entries fs.DirEntries
+	less    lessFn
+}
+
+// Len is part of sort.Interface.
+func (es entrySorter) Len() int { return len(es.entries) }
+
+// Swap is part of sort.Interface.
+func (es entrySorter) Swap(i, j int) { es.entries[i], es.entries[j] = es.entries[j], es.entries[i] }
+
+// Less is part of sort.Interface.
+func (es entrySorter) Less(i, j int) bool { return es.less(es.entries[i], es.entries[j]) }
+
+// sortEntries sorts entries in place with less keeping the listing
+// order of entries which are equal
+func sortEntries(entries fs.DirEntries, less lessFn) {
+	sort.Stable(entrySorter{entries: entries, less: less})
+}
+
 // Timestamp a time in RFC3339 format with Nanosecond precision secongs
 type Timestamp time.Time
 
@@ -96,6 +134,22 @@ The time is in RFC3339 format with nanosecond precision.
 
 The whole output can be processed as a JSON blob, or alternatively it
 can be processed line by line as each item is written one to a line.
+
+Use --order-by to sort the entries of each directory, eg
+"--order-by size,descending" or "--order-by modtime".  The key can be
+size, path or modtime optionally followed by ,ascending or
+,descending.  Normally they are in the order the backend lists them.
+When listing recursively each directory is sorted on its own.
+
+Filters are applied by rclone after listing, except where the backend
+can do them itself so the files which don't match aren't listed at
+all.  Google drive does --min-age and --max-age this way, and can be
+given a search query of its own with --drive-query, eg
+
+    rclone lsjson drive:docs --drive-query "mimeType='application/pdf'"
+
+to list only the PDFs.  All the other filters and backends are done
+by rclone.
 ` + lshelp.Help,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
@@ -114,6 +168,10 @@ can be processed line by line as each item is written one to a line.
 				log.Fatalf(err.Error())
 			}
 		}
+		less, err := parseOrderBy(fs.Config.OrderBy)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
 		cmd.Run(false, false, command, func() error {
 			fmt.Println("[")
 			first := true
@@ -123,6 +181,9 @@ can be processed line by line as each item is written one to a line.
 					fs.Errorf(dirPath, "error listing: %v", err)
 					return nil
 				}
+				if less != nil {
+					sortEntries(entries, less)
+				}
 				for _, entry := range entries {
 					item := lsJSON{
 						Path:     entry.Remote(),
@@ -0,0 +1,48 @@
+package lsjson
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := fs.DirEntries{
+		fs.NewDir("b", t0.Add(time.Hour)).SetSize(1),
+		fs.NewDir("c", t0).SetSize(3),
+		fs.NewDir("a", t0.Add(2*time.Hour)).SetSize(2),
+	}
+	names := func(less lessFn) (out []string) {
+		sorted := append(fs.DirEntries(nil), entries...)
+		if less != nil {
+			sortEntries(sorted, less)
+		}
+		for _, entry := range sorted {
+			out = append(out, entry.Remote())
+		}
+		return out
+	}
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{"b", "c", "a"}},
+		{"name", []string{"a", "b", "c"}},
+		{"path,descending", []string{"c", "b", "a"}},
+		{"size", []string{"b", "a", "c"}},
+		{"Size,Desc", []string{"c", "a", "b"}},
+		{"modtime,ascending", []string{"c", "b", "a"}},
+	} {
+		less, err := parseOrderBy(test.in)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, names(less), test.in)
+	}
+	for _, in := range []string{"potato", "size,mixed", "size,asc,desc"} {
+		_, err := parseOrderBy(in)
+		assert.Error(t, err, in)
+	}
+}
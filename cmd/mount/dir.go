@@ -46,6 +46,9 @@ var _ fusefs.NodeSetattrer = (*Dir)(nil)
 // Setattr handles attribute changes from FUSE. Currently supports ModTime only.
 func (d *Dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) (err error) {
 	defer log.Trace(d, "stat=%+v", req)("err=%v", &err)
+	if d.VFS().Opt.ReadOnly {
+		return translateError(vfs.EROFS)
+	}
 	if d.VFS().Opt.NoModTime {
 		return nil
 	}
@@ -191,5 +194,8 @@ var _ fusefs.NodeLinker = (*Dir)(nil)
 // existing Node. Receiver must be a directory.
 func (d *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fusefs.Node) (new fusefs.Node, err error) {
 	defer log.Trace(d, "req=%v, old=%v", req, old)("new=%v, err=%v", &new, &err)
+	if d.VFS().Opt.ReadOnly {
+		return nil, translateError(vfs.EROFS)
+	}
 	return nil, fuse.ENOSYS
 }
@@ -47,6 +47,9 @@ var _ fusefs.NodeSetattrer = (*File)(nil)
 // Setattr handles attribute changes from FUSE. Currently supports ModTime and Size only
 func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) (err error) {
 	defer log.Trace(f, "a=%+v", req)("err=%v", &err)
+	if f.VFS().Opt.ReadOnly {
+		return translateError(vfs.EROFS)
+	}
 	if !f.VFS().Opt.NoModTime {
 		if req.Valid.Mtime() {
 			err = f.File.SetModTime(req.Mtime)
@@ -97,15 +100,24 @@ func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) (err error) {
 // node.
 //
 // If there is no xattr by that name, returns fuse.ErrNoXattr.
-func (f *File) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
-	return fuse.ENOSYS // we never implement this
+func (f *File) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) (err error) {
+	if !f.VFS().Opt.XattrHashes {
+		return fuse.ENOSYS // only implemented with --vfs-xattr-hashes
+	}
+	defer log.Trace(f, "name=%q", req.Name)("value=%q, err=%v", &resp.Xattr, &err)
+	resp.Xattr, err = f.File.Getxattr(req.Name)
+	return translateError(err)
 }
 
 var _ fusefs.NodeGetxattrer = (*File)(nil)
 
 // Listxattr lists the extended attributes recorded for the node.
 func (f *File) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
-	return fuse.ENOSYS // we never implement this
+	if !f.VFS().Opt.XattrHashes {
+		return fuse.ENOSYS // only implemented with --vfs-xattr-hashes
+	}
+	resp.Append(f.File.Listxattr()...)
+	return nil
 }
 
 var _ fusefs.NodeListxattrer = (*File)(nil)
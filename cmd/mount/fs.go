@@ -101,6 +101,8 @@ func translateError(err error) error {
 		return fuse.Errno(syscall.EROFS)
 	case vfs.ENOSYS:
 		return fuse.ENOSYS
+	case vfs.ENOATTR:
+		return fuse.ErrNoXattr
 	case vfs.EINVAL:
 		return fuse.Errno(syscall.EINVAL)
 	}
@@ -140,14 +140,18 @@ func Mount(f fs.Fs, mountpoint string) error {
 		return errors.Wrap(err, "failed to notify systemd")
 	}
 
+	var drainErr error
 waitloop:
 	for {
 		select {
 		// umount triggered outside the app
 		case err = <-errChan:
+			// wait for any uploads still in progress
+			drainErr = mountlib.Drain(FS)
 			break waitloop
-		// Program abort: umount
+		// Program abort: flush the writes then umount
 		case <-sigInt:
+			drainErr = mountlib.Drain(FS)
 			err = unmount()
 			break waitloop
 		// user sent SIGHUP to clear the cache
@@ -166,5 +170,5 @@ waitloop:
 		return errors.Wrap(err, "failed to umount FUSE fs")
 	}
 
-	return nil
+	return drainErr
 }
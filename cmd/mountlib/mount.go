@@ -60,6 +60,21 @@ func checkMountEmpty(mountpoint string) error {
 	return nil
 }
 
+// Drain stops the VFS accepting new writes and waits for up to
+// --vfs-shutdown-timeout for the writes and uploads in progress to
+// finish before the mount is stopped.
+func Drain(VFS *vfs.VFS) error {
+	timeout := VFS.Opt.ShutdownTimeout
+	fs.Infof(nil, "Waiting up to %v for writes and uploads to finish", timeout)
+	flushed, err := VFS.Drain(timeout)
+	if err != nil {
+		fs.Errorf(nil, "Flushed %d files from the VFS cache but %v", flushed, err)
+		return errors.Wrap(err, "failed to flush VFS cache")
+	}
+	fs.Infof(nil, "Flushed %d files from the VFS cache", flushed)
+	return nil
+}
+
 // NewMountCommand makes a mount command with the given name and Mount function
 func NewMountCommand(commandName string, Mount func(f fs.Fs, mountpoint string) error) *cobra.Command {
 	var commandDefintion = &cobra.Command{
@@ -85,6 +100,12 @@ Or on Windows like this where X: is an unused drive letter
 When the program ends, either via Ctrl+C or receiving a SIGINT or SIGTERM signal,
 the mount is automatically stopped.
 
+Before the mount is stopped rclone stops accepting new writes and
+waits for files which are open for write to be closed and for any
+files in the VFS cache to be uploaded.  It will wait for up to
+--vfs-shutdown-timeout for this and then exit with an error if there
+was still data that hadn't been uploaded.
+
 The umount operation can fail, for example when the mountpoint is busy.
 When that happens, it is the user's responsibility to stop the mount manually with
 
@@ -212,6 +233,19 @@ When --vfs-read-chunk-size-limit 500M is specified, the result would be
 
 Chunked reading will only work with --vfs-cache-mode < full, as the file will always
 be copied to the vfs cache before opening with --vfs-cache-mode full.
+
+### Extended attributes
+
+If --vfs-xattr-hashes is set then the hashes the remote stores for
+each file are exposed as read only extended attributes named after the
+hash, eg ` + "`user.rclone.md5`" + ` or ` + "`user.rclone.sha1`" + `.  These can
+be used to verify files without reading them, eg
+
+    getfattr -n user.rclone.md5 /path/to/mount/file
+
+Only hashes the remote actually supports are listed, so remotes
+without hashes, such as crypt, won't have any.  This is off by default
+as some applications probe extended attributes on every file.
 ` + vfs.Help,
 		Run: func(command *cobra.Command, args []string) {
 			cmd.CheckArgs(2, 2, command, args)
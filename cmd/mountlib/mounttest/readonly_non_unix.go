@@ -0,0 +1,13 @@
+// +build !linux,!darwin,!freebsd
+
+package mounttest
+
+import (
+	"runtime"
+	"testing"
+)
+
+// TestReadOnly tests a read only mount refuses changes immediately
+func TestReadOnly(t *testing.T) {
+	t.Skip("not supported on " + runtime.GOOS)
+}
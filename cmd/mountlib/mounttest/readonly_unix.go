@@ -0,0 +1,70 @@
+// +build linux darwin freebsd
+
+package mounttest
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// errno returns the syscall error underlying err if any
+func errno(err error) error {
+	switch e := err.(type) {
+	case *os.PathError:
+		return e.Err
+	case *os.LinkError:
+		return e.Err
+	case *os.SyscallError:
+		return e.Err
+	}
+	return err
+}
+
+// TestReadOnly tests a read only mount refuses changes immediately
+func TestReadOnly(t *testing.T) {
+	run.skipIfNoFUSE(t)
+
+	run.mkdir(t, "dir")
+	run.createFile(t, "dir/file", "hello")
+	run.checkDir(t, "dir/|dir/file 5")
+
+	run.vfs.Opt.ReadOnly = true
+
+	// touch a new file
+	_, err := osCreate(run.path("dir/new"))
+	assert.Equal(t, syscall.EROFS, errno(err), "create")
+
+	// touch an existing file
+	mtime := time.Date(2012, 11, 18, 17, 32, 31, 0, time.UTC)
+	err = os.Chtimes(run.path("dir/file"), mtime, mtime)
+	assert.Equal(t, syscall.EROFS, errno(err), "chtimes")
+
+	// open an existing file for write
+	_, err = os.OpenFile(run.path("dir/file"), os.O_WRONLY, 0)
+	assert.Equal(t, syscall.EROFS, errno(err), "open for write")
+
+	// truncate
+	err = os.Truncate(run.path("dir/file"), 0)
+	assert.Equal(t, syscall.EROFS, errno(err), "truncate")
+
+	// rm
+	err = os.Remove(run.path("dir/file"))
+	assert.Equal(t, syscall.EROFS, errno(err), "rm")
+
+	// mv
+	err = os.Rename(run.path("dir/file"), run.path("dir/file2"))
+	assert.Equal(t, syscall.EROFS, errno(err), "mv")
+
+	// mkdir
+	err = os.Mkdir(run.path("dir/subdir"), 0777)
+	assert.Equal(t, syscall.EROFS, errno(err), "mkdir")
+
+	run.vfs.Opt.ReadOnly = false
+	run.checkDir(t, "dir/|dir/file 5")
+	run.rm(t, "dir/file")
+	run.rmdir(t, "dir")
+}
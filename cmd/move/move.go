@@ -36,6 +36,15 @@ into ` + "`dest:path`" + ` then delete the original (if no errors on copy) in
 ` + "`source:path`" + `.
 
 If you want to delete empty source directories after move, use the --delete-empty-src-dirs flag.
+When the files are moved one by one this removes the source
+directories which are empty once the move has finished, deepest
+first.  Directories which still contain anything (eg files excluded
+by the filters) are left, as is ` + "`source:path`" + ` itself.  The backend
+checks each directory is empty before removing it.
+
+With --create-empty-src-dirs-marker the placeholders in a source
+which can't have empty directories are deleted too so their
+directories can be removed.
 
 **Important**: Since this can cause data loss, test first with the
 --dry-run flag.
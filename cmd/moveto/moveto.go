@@ -41,6 +41,15 @@ This doesn't transfer unchanged files, testing by size and
 modification time or MD5SUM.  src will be deleted on successful
 transfer.
 
+Before src is deleted the copy is checked by size and by hash if the
+source and destination have one in common (eg MD5 for a local file
+copied to drive).  If it doesn't match the copy is removed, src is
+kept and rclone exits with a non-zero status.  Only the one file is looked up on the source
+and destination so there is no need for --no-traverse.
+
+Some remotes, eg drive, can resume a large upload which was
+interrupted from where it got to when the same command is run again.
+
 **Important**: Since this can cause data loss, test first with the
 --dry-run flag.
 `,
@@ -0,0 +1,783 @@
+// FTP control connections
+
+package ftp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/vfs"
+	"github.com/pkg/errors"
+)
+
+// dataTimeout is how long to wait for the client to open a data
+// connection
+const dataTimeout = 30 * time.Second
+
+// maxLineLength is the longest command line accepted from a client
+const maxLineLength = 4096
+
+// errLineTooLong is returned by readLine for command lines longer
+// than maxLineLength
+var errLineTooLong = errors.New("command line too long")
+
+// conn is a control connection from a client
+type conn struct {
+	s          *Server
+	remoteAddr string
+	in         *bufio.Reader // reads the control connection
+	mu         sync.Mutex    // protects ctrl and passive
+	ctrl       net.Conn      // control connection, wrapped in TLS after AUTH TLS
+	passive    net.Listener  // listener for the next data connection, if any
+	tls        bool          // set if the control connection is TLS
+	pbsz       bool          // set if PBSZ has been sent
+	protect    bool          // set if the data connections should be TLS
+	user       string        // user name given with USER
+	loggedIn   bool          // set if the user has logged in
+	cwd        string        // current directory, always starting with "/"
+	restart    int64         // offset for the next transfer set by REST
+	renameFrom string        // path given by RNFR
+	quit       bool          // set to close the connection
+}
+
+// command is an FTP command the server understands
+type command struct {
+	fn      func(c *conn, arg string)
+	noLogin bool // set if the command can be used before logging in
+}
+
+// commands are the FTP commands the server understands
+var commands = map[string]command{
+	"USER": {fn: (*conn).cmdUser, noLogin: true},
+	"PASS": {fn: (*conn).cmdPass, noLogin: true},
+	"AUTH": {fn: (*conn).cmdAuth, noLogin: true},
+	"PBSZ": {fn: (*conn).cmdPbsz, noLogin: true},
+	"PROT": {fn: (*conn).cmdProt, noLogin: true},
+	"FEAT": {fn: (*conn).cmdFeat, noLogin: true},
+	"SYST": {fn: (*conn).cmdSyst, noLogin: true},
+	"NOOP": {fn: (*conn).cmdNoop, noLogin: true},
+	"QUIT": {fn: (*conn).cmdQuit, noLogin: true},
+	"OPTS": {fn: (*conn).cmdOpts, noLogin: true},
+	"TYPE": {fn: (*conn).cmdType},
+	"MODE": {fn: (*conn).cmdMode},
+	"STRU": {fn: (*conn).cmdStru},
+	"PWD":  {fn: (*conn).cmdPwd},
+	"XPWD": {fn: (*conn).cmdPwd},
+	"CWD":  {fn: (*conn).cmdCwd},
+	"XCWD": {fn: (*conn).cmdCwd},
+	"CDUP": {fn: (*conn).cmdCdup},
+	"XCUP": {fn: (*conn).cmdCdup},
+	"PASV": {fn: (*conn).cmdPasv},
+	"EPSV": {fn: (*conn).cmdEpsv},
+	"PORT": {fn: (*conn).cmdPort},
+	"EPRT": {fn: (*conn).cmdPort},
+	"LIST": {fn: (*conn).cmdList},
+	"NLST": {fn: (*conn).cmdNlst},
+	"RETR": {fn: (*conn).cmdRetr},
+	"STOR": {fn: (*conn).cmdStor},
+	"APPE": {fn: (*conn).cmdAppe},
+	"REST": {fn: (*conn).cmdRest},
+	"SIZE": {fn: (*conn).cmdSize},
+	"MDTM": {fn: (*conn).cmdMdtm},
+	"DELE": {fn: (*conn).cmdDele},
+	"MKD":  {fn: (*conn).cmdMkd},
+	"XMKD": {fn: (*conn).cmdMkd},
+	"RMD":  {fn: (*conn).cmdRmd},
+	"XRMD": {fn: (*conn).cmdRmd},
+	"RNFR": {fn: (*conn).cmdRnfr},
+	"RNTO": {fn: (*conn).cmdRnto},
+}
+
+// newConn makes a new conn for the control connection ctrl
+func newConn(s *Server, ctrl net.Conn) *conn {
+	return &conn{
+		s:          s,
+		remoteAddr: ctrl.RemoteAddr().String(),
+		ctrl:       ctrl,
+		in:         bufio.NewReaderSize(ctrl, maxLineLength),
+		cwd:        "/",
+	}
+}
+
+// serve reads and runs commands until the client quits
+func (c *conn) serve() {
+	defer c.close()
+	fs.Debugf(nil, "FTP connection from %s", c.remoteAddr)
+	c.reply(220, "rclone FTP server ready")
+	for !c.quit {
+		line, err := c.readLine()
+		if err == errLineTooLong {
+			c.reply(500, "Command line too long")
+			return
+		}
+		if err != nil {
+			if err != io.EOF {
+				fs.Debugf(nil, "FTP connection from %s: read failed: %v", c.remoteAddr, err)
+			}
+			return
+		}
+		name, arg := line, ""
+		if space := strings.IndexRune(line, ' '); space >= 0 {
+			name, arg = line[:space], line[space+1:]
+		}
+		name = strings.ToUpper(name)
+		if name == "PASS" {
+			fs.Debugf(nil, "FTP connection from %s: PASS ****", c.remoteAddr)
+		} else {
+			fs.Debugf(nil, "FTP connection from %s: %s", c.remoteAddr, line)
+		}
+		cmd, ok := commands[name]
+		switch {
+		case !ok:
+			c.reply(502, "Command %q not implemented", name)
+		case !cmd.noLogin && !c.loggedIn:
+			c.reply(530, "Not logged in")
+		default:
+			cmd.fn(c, arg)
+		}
+	}
+}
+
+// readLine reads a command line from the client without the line
+// ending
+func (c *conn) readLine() (string, error) {
+	line, isPrefix, err := c.in.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if isPrefix {
+		return "", errLineTooLong
+	}
+	return string(line), nil
+}
+
+// close closes the control connection and any passive listener
+func (c *conn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.ctrl.Close()
+	if c.passive != nil {
+		_ = c.passive.Close()
+		c.passive = nil
+	}
+}
+
+// reply sends a reply to the client
+func (c *conn) reply(code int, format string, a ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := fmt.Fprintf(c.ctrl, "%d %s\r\n", code, fmt.Sprintf(format, a...))
+	if err != nil {
+		fs.Debugf(nil, "FTP connection from %s: write failed: %v", c.remoteAddr, err)
+	}
+}
+
+// replyLines sends a multi line reply to the client
+func (c *conn) replyLines(code int, first string, lines []string, last string) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d-%s\r\n", code, first)
+	for _, line := range lines {
+		fmt.Fprintf(&b, " %s\r\n", line)
+	}
+	fmt.Fprintf(&b, "%d %s\r\n", code, last)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := io.WriteString(c.ctrl, b.String())
+	if err != nil {
+		fs.Debugf(nil, "FTP connection from %s: write failed: %v", c.remoteAddr, err)
+	}
+}
+
+// replyError sends an error reply for err
+func (c *conn) replyError(err error) {
+	switch {
+	case err == vfs.ENOENT || os.IsNotExist(err):
+		c.reply(550, "No such file or directory")
+	case err == vfs.EROFS:
+		c.reply(550, "Read only file system")
+	default:
+		c.reply(550, "%v", err)
+	}
+}
+
+// path returns the absolute path of arg in the current directory
+func (c *conn) path(arg string) string {
+	if !strings.HasPrefix(arg, "/") {
+		arg = path.Join(c.cwd, arg)
+	}
+	return path.Clean("/" + arg)
+}
+
+func (c *conn) cmdUser(arg string) {
+	if c.s.tlsConfig != nil && !c.tls {
+		c.reply(530, "Use AUTH TLS before logging in")
+		return
+	}
+	c.user = arg
+	c.loggedIn = false
+	c.reply(331, "Password required for %s", arg)
+}
+
+func (c *conn) cmdPass(arg string) {
+	if c.s.tlsConfig != nil && !c.tls {
+		c.reply(530, "Use AUTH TLS before logging in")
+		return
+	}
+	if !c.s.checkLogin(c.user, arg) {
+		fs.Logf(nil, "FTP connection from %s: login failed for user %q", c.remoteAddr, c.user)
+		c.reply(530, "Login incorrect")
+		return
+	}
+	c.loggedIn = true
+	c.reply(230, "User %s logged in", c.user)
+}
+
+func (c *conn) cmdAuth(arg string) {
+	if c.s.tlsConfig == nil {
+		c.reply(502, "TLS isn't configured")
+		return
+	}
+	if c.tls {
+		c.reply(503, "Already using TLS")
+		return
+	}
+	if t := strings.ToUpper(arg); t != "TLS" && t != "TLS-C" && t != "SSL" {
+		c.reply(504, "AUTH %s not supported", arg)
+		return
+	}
+	c.reply(234, "AUTH TLS successful")
+	c.mu.Lock()
+	tlsConn := tls.Server(c.ctrl, c.s.tlsConfig)
+	c.ctrl = tlsConn
+	c.mu.Unlock()
+	err := tlsConn.Handshake()
+	if err != nil {
+		fs.Debugf(nil, "FTP connection from %s: TLS handshake failed: %v", c.remoteAddr, err)
+		c.quit = true
+		return
+	}
+	c.in = bufio.NewReaderSize(tlsConn, maxLineLength)
+	c.tls = true
+	// The user must log in again over TLS
+	c.user = ""
+	c.loggedIn = false
+}
+
+func (c *conn) cmdPbsz(arg string) {
+	if !c.tls {
+		c.reply(503, "Use AUTH TLS first")
+		return
+	}
+	c.pbsz = true
+	c.reply(200, "PBSZ=0")
+}
+
+func (c *conn) cmdProt(arg string) {
+	if !c.tls || !c.pbsz {
+		c.reply(503, "Use AUTH TLS and PBSZ first")
+		return
+	}
+	switch strings.ToUpper(arg) {
+	case "P":
+		c.protect = true
+		c.reply(200, "Data connections will be protected")
+	case "C":
+		// TLS is always required when it is configured
+		c.reply(534, "Data connections must be protected - use PROT P")
+	default:
+		c.reply(504, "PROT %s not supported", arg)
+	}
+}
+
+func (c *conn) cmdFeat(arg string) {
+	var features []string
+	if c.s.tlsConfig != nil {
+		features = append(features, "AUTH TLS", "PBSZ", "PROT")
+	}
+	features = append(features, "EPSV", "PASV", "SIZE", "MDTM", "REST STREAM", "UTF8")
+	c.replyLines(211, "Features:", features, "End")
+}
+
+func (c *conn) cmdSyst(arg string) {
+	c.reply(215, "UNIX Type: L8")
+}
+
+func (c *conn) cmdNoop(arg string) {
+	c.reply(200, "OK")
+}
+
+func (c *conn) cmdQuit(arg string) {
+	c.reply(221, "Goodbye")
+	c.quit = true
+}
+
+func (c *conn) cmdOpts(arg string) {
+	if strings.ToUpper(arg) == "UTF8 ON" {
+		c.reply(200, "UTF8 is always on")
+		return
+	}
+	c.reply(501, "Option %q not supported", arg)
+}
+
+func (c *conn) cmdType(arg string) {
+	// All transfers are binary, but ASCII is accepted for the
+	// clients which insist on it
+	switch strings.ToUpper(arg) {
+	case "I", "L 8", "A", "A N":
+		c.reply(200, "Type set to %s", arg)
+	default:
+		c.reply(504, "Type %s not supported", arg)
+	}
+}
+
+func (c *conn) cmdMode(arg string) {
+	if strings.ToUpper(arg) != "S" {
+		c.reply(504, "Only stream mode is supported")
+		return
+	}
+	c.reply(200, "Mode set to S")
+}
+
+func (c *conn) cmdStru(arg string) {
+	if strings.ToUpper(arg) != "F" {
+		c.reply(504, "Only file structure is supported")
+		return
+	}
+	c.reply(200, "Structure set to F")
+}
+
+func (c *conn) cmdPwd(arg string) {
+	c.reply(257, "%q is the current directory", c.cwd)
+}
+
+func (c *conn) cmdCwd(arg string) {
+	p := c.path(arg)
+	node, err := c.s.vfs.Stat(p)
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	if !node.IsDir() {
+		c.reply(550, "Not a directory")
+		return
+	}
+	c.cwd = p
+	c.reply(250, "Directory changed to %s", p)
+}
+
+func (c *conn) cmdCdup(arg string) {
+	c.cmdCwd("..")
+}
+
+// listen starts listening for a passive data connection returning
+// the IP address the client should connect to and the port
+func (c *conn) listen() (ip net.IP, port int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.passive != nil {
+		_ = c.passive.Close()
+		c.passive = nil
+	}
+	localIP := c.ctrl.LocalAddr().(*net.TCPAddr).IP
+	ln, err := c.s.listenPassive(localIP)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.passive = ln
+	ip = localIP
+	if c.s.opt.PublicIP != "" {
+		ip = net.ParseIP(c.s.opt.PublicIP)
+	}
+	return ip, ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+func (c *conn) cmdPasv(arg string) {
+	ip, port, err := c.listen()
+	if err != nil {
+		c.reply(425, "Can't open data connection: %v", err)
+		return
+	}
+	ip4 := ip.To4()
+	if ip4 == nil {
+		c.reply(425, "Can't use PASV over IPv6 - use EPSV")
+		return
+	}
+	c.reply(227, "Entering Passive Mode (%d,%d,%d,%d,%d,%d)", ip4[0], ip4[1], ip4[2], ip4[3], port>>8, port&0xFF)
+}
+
+func (c *conn) cmdEpsv(arg string) {
+	if strings.ToUpper(arg) == "ALL" {
+		c.reply(200, "EPSV ALL OK")
+		return
+	}
+	_, port, err := c.listen()
+	if err != nil {
+		c.reply(425, "Can't open data connection: %v", err)
+		return
+	}
+	c.reply(229, "Entering Extended Passive Mode (|||%d|)", port)
+}
+
+func (c *conn) cmdPort(arg string) {
+	c.reply(502, "Active mode isn't supported - use PASV")
+}
+
+// checkData checks a data connection can be opened, sending an error
+// reply and returning false if not
+func (c *conn) checkData() bool {
+	c.mu.Lock()
+	passive := c.passive
+	c.mu.Unlock()
+	if passive == nil {
+		c.reply(425, "Use PASV or EPSV first")
+		return false
+	}
+	if c.s.tlsConfig != nil && !c.protect {
+		c.reply(521, "Data connections must be protected - use PROT P")
+		return false
+	}
+	return true
+}
+
+// openData accepts the data connection from the client
+func (c *conn) openData() (data net.Conn, err error) {
+	c.mu.Lock()
+	ln := c.passive
+	c.passive = nil
+	c.mu.Unlock()
+	if ln == nil {
+		return nil, errors.New("no passive listener")
+	}
+	defer func() {
+		_ = ln.Close()
+	}()
+	if tcpLn, ok := ln.(*net.TCPListener); ok {
+		_ = tcpLn.SetDeadline(time.Now().Add(dataTimeout))
+	}
+	data, err = ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// Only accept the data connection from the client, so no one
+	// else can steal the data
+	ctrlIP := c.ctrl.RemoteAddr().(*net.TCPAddr).IP
+	dataIP := data.RemoteAddr().(*net.TCPAddr).IP
+	if !ctrlIP.Equal(dataIP) {
+		_ = data.Close()
+		return nil, errors.Errorf("data connection from %v doesn't match control connection from %v", dataIP, ctrlIP)
+	}
+	if c.protect {
+		tlsConn := tls.Server(data, c.s.tlsConfig)
+		err = tlsConn.Handshake()
+		if err != nil {
+			_ = data.Close()
+			return nil, errors.Wrap(err, "TLS handshake failed")
+		}
+		data = tlsConn
+	}
+	return data, nil
+}
+
+// transfer opens the data connection and calls fn with it, replying
+// with the result
+func (c *conn) transfer(fn func(data net.Conn) error) {
+	c.reply(150, "Opening data connection")
+	data, err := c.openData()
+	if err != nil {
+		c.reply(425, "Can't open data connection: %v", err)
+		return
+	}
+	err = fn(data)
+	closeErr := data.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.reply(426, "Transfer failed: %v", err)
+		return
+	}
+	c.reply(226, "Transfer complete")
+}
+
+// listArg returns the path to list from the argument to LIST, which
+// may start with options like "-la" which are ignored
+func (c *conn) listArg(arg string) string {
+	for strings.HasPrefix(arg, "-") {
+		space := strings.IndexRune(arg, ' ')
+		if space < 0 {
+			arg = ""
+			break
+		}
+		arg = strings.TrimLeft(arg[space:], " ")
+	}
+	return c.path(arg)
+}
+
+// listNodes returns the nodes to list for p - the contents of the
+// directory or the file itself
+func (c *conn) listNodes(p string) (vfs.Nodes, error) {
+	node, err := c.s.vfs.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := node.(*vfs.Dir)
+	if !ok {
+		return vfs.Nodes{node}, nil
+	}
+	return dir.ReadDirAll()
+}
+
+// listLine returns node as a line of "ls -l" output
+func listLine(node vfs.Node, now time.Time) string {
+	modTime := node.ModTime()
+	var when string
+	if modTime.After(now.AddDate(0, -6, 0)) && modTime.Before(now.Add(time.Hour)) {
+		when = modTime.Format("Jan _2 15:04")
+	} else {
+		when = modTime.Format("Jan _2  2006")
+	}
+	return fmt.Sprintf("%s 1 ftp ftp %12d %s %s\r\n", node.Mode().String(), node.Size(), when, node.Name())
+}
+
+func (c *conn) cmdList(arg string) {
+	nodes, err := c.listNodes(c.listArg(arg))
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	if !c.checkData() {
+		return
+	}
+	now := time.Now()
+	c.transfer(func(data net.Conn) error {
+		w := bufio.NewWriter(data)
+		for _, node := range nodes {
+			_, _ = w.WriteString(listLine(node, now))
+		}
+		return w.Flush()
+	})
+}
+
+func (c *conn) cmdNlst(arg string) {
+	nodes, err := c.listNodes(c.listArg(arg))
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	if !c.checkData() {
+		return
+	}
+	c.transfer(func(data net.Conn) error {
+		w := bufio.NewWriter(data)
+		for _, node := range nodes {
+			_, _ = w.WriteString(node.Name() + "\r\n")
+		}
+		return w.Flush()
+	})
+}
+
+// takeRestart returns the offset set by REST for this transfer
+func (c *conn) takeRestart() int64 {
+	offset := c.restart
+	c.restart = 0
+	return offset
+}
+
+func (c *conn) cmdRetr(arg string) {
+	offset := c.takeRestart()
+	p := c.path(arg)
+	if c.statFile(p) == nil || !c.checkData() {
+		return
+	}
+	handle, err := c.s.vfs.OpenFile(p, os.O_RDONLY, 0)
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	defer func() {
+		_ = handle.Close()
+	}()
+	if offset > 0 {
+		_, err = handle.Seek(offset, io.SeekStart)
+		if err != nil {
+			c.replyError(err)
+			return
+		}
+	}
+	c.transfer(func(data net.Conn) error {
+		// Hide any ReadFrom or WriteTo methods promoted from the
+		// handle's cache file as they bypass the VFS
+		_, err := io.Copy(data, struct{ io.Reader }{handle})
+		return err
+	})
+}
+
+// upload reads the data connection into the file at arg opened with
+// flags
+func (c *conn) upload(arg string, flags int, offset int64) {
+	if !c.checkData() {
+		return
+	}
+	p := c.path(arg)
+	handle, err := c.s.vfs.OpenFile(p, flags, 0666)
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	if offset > 0 {
+		_, err = handle.Seek(offset, io.SeekStart)
+		if err != nil {
+			_ = handle.Close()
+			c.replyError(err)
+			return
+		}
+	}
+	c.transfer(func(data net.Conn) error {
+		_, err := io.Copy(struct{ io.Writer }{handle}, data)
+		// Closing the file uploads it so report any error
+		closeErr := handle.Close()
+		if err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
+
+func (c *conn) cmdStor(arg string) {
+	offset := c.takeRestart()
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	c.upload(arg, flags, offset)
+}
+
+func (c *conn) cmdAppe(arg string) {
+	c.takeRestart()
+	c.upload(arg, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0)
+}
+
+func (c *conn) cmdRest(arg string) {
+	offset, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || offset < 0 {
+		c.reply(501, "Bad offset %q", arg)
+		return
+	}
+	c.restart = offset
+	c.reply(350, "Restarting at %d", offset)
+}
+
+// statFile finds the file at arg sending an error reply and returning
+// nil if it isn't a file
+func (c *conn) statFile(arg string) vfs.Node {
+	node, err := c.s.vfs.Stat(c.path(arg))
+	if err != nil {
+		c.replyError(err)
+		return nil
+	}
+	if node.IsDir() {
+		c.reply(550, "Not a file")
+		return nil
+	}
+	return node
+}
+
+func (c *conn) cmdSize(arg string) {
+	if node := c.statFile(arg); node != nil {
+		c.reply(213, "%d", node.Size())
+	}
+}
+
+func (c *conn) cmdMdtm(arg string) {
+	if node := c.statFile(arg); node != nil {
+		c.reply(213, "%s", node.ModTime().UTC().Format("20060102150405"))
+	}
+}
+
+func (c *conn) cmdDele(arg string) {
+	node := c.statFile(arg)
+	if node == nil {
+		return
+	}
+	err := node.Remove()
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	c.reply(250, "Deleted %s", node.Path())
+}
+
+func (c *conn) cmdMkd(arg string) {
+	p := c.path(arg)
+	dir, leaf, err := c.s.vfs.StatParent(p)
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	_, err = dir.Mkdir(leaf)
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	c.reply(257, "%q created", p)
+}
+
+func (c *conn) cmdRmd(arg string) {
+	p := c.path(arg)
+	if p == "/" {
+		c.reply(550, "Can't remove the root")
+		return
+	}
+	node, err := c.s.vfs.Stat(p)
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	if !node.IsDir() {
+		c.reply(550, "Not a directory")
+		return
+	}
+	err = node.Remove()
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	c.reply(250, "Removed %s", p)
+}
+
+func (c *conn) cmdRnfr(arg string) {
+	p := c.path(arg)
+	_, err := c.s.vfs.Stat(p)
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	c.renameFrom = p
+	c.reply(350, "Ready for RNTO")
+}
+
+func (c *conn) cmdRnto(arg string) {
+	from := c.renameFrom
+	c.renameFrom = ""
+	if from == "" {
+		c.reply(503, "Use RNFR first")
+		return
+	}
+	err := c.s.vfs.Rename(from, c.path(arg))
+	if err != nil {
+		c.replyError(err)
+		return
+	}
+	c.reply(250, "Renamed %s to %s", from, c.path(arg))
+}
@@ -0,0 +1,249 @@
+// Package ftp implements a server to serve a VFS remote over FTP
+package ftp
+
+import (
+	"crypto/tls"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/flags"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Options required for ftp server
+type Options struct {
+	ListenAddr   string // Port to listen on
+	PublicIP     string // Public IP address to give in passive replies
+	PassivePorts string // Range of ports for passive data connections, eg "50000-50100"
+	BasicUser    string // User name for authentication
+	BasicPass    string // Password for authentication
+	TLSCert      string // TLS PEM certificate for FTPS
+	TLSKey       string // TLS PEM private key for FTPS
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ListenAddr: "localhost:2121",
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+func init() {
+	flagSet := Command.Flags()
+	flags.StringVarP(flagSet, &Opt.ListenAddr, "addr", "", Opt.ListenAddr, "IPaddress:Port or :Port to bind server to.")
+	flags.StringVarP(flagSet, &Opt.PublicIP, "public-ip", "", Opt.PublicIP, "Public IP address to give the clients for passive connections.")
+	flags.StringVarP(flagSet, &Opt.PassivePorts, "ftp-passive-port-range", "", Opt.PassivePorts, "Range of ports for passive data connections, eg 50000-50100.")
+	flags.StringVarP(flagSet, &Opt.BasicUser, "user", "", Opt.BasicUser, "User name for authentication.")
+	flags.StringVarP(flagSet, &Opt.BasicPass, "pass", "", Opt.BasicPass, "Password for authentication.")
+	flags.StringVarP(flagSet, &Opt.TLSCert, "ftp-tls-cert", "", Opt.TLSCert, "TLS PEM certificate to enable explicit FTPS with.")
+	flags.StringVarP(flagSet, &Opt.TLSKey, "ftp-tls-key", "", Opt.TLSKey, "TLS PEM private key to enable explicit FTPS with.")
+	vfsflags.AddFlags(flagSet)
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "ftp remote:path",
+	Short: `Serve the remote over FTP.`,
+	Long: `rclone serve ftp implements a basic FTP server to serve the
+remote over FTP.  This can be used with any FTP client or you can make
+a remote of type ftp to read and write it.
+
+Use --addr to specify which IP address and port the server should
+listen on, eg --addr 1.2.3.4:2121 or --addr :2121 to listen to all
+IPs.  By default it only listens on localhost.
+
+Only passive mode data connections are supported.  These are made to
+a random port unless --ftp-passive-port-range is used to choose the
+range of ports, eg --ftp-passive-port-range 50000-50100, so they can
+be let through a firewall.  Behind NAT use --public-ip to give the
+address the clients should connect to for them, otherwise it is the
+address the client connected to.
+
+Use --user and --pass to set the user name and password the clients
+must log in with.  If --user isn't set any user name and password is
+accepted.
+
+Use --ftp-tls-cert and --ftp-tls-key to enable explicit FTPS.  The
+clients must then upgrade the connection with AUTH TLS before they log
+in and protect the data connections with PROT P - plaintext logins and
+data connections are refused.
+` + vfs.Help,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, true, command, func() error {
+			s, err := newServer(f, &Opt)
+			if err != nil {
+				return err
+			}
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			s.Wait()
+			return nil
+		})
+	},
+}
+
+// Server is an FTP server serving a VFS
+type Server struct {
+	f         fs.Fs
+	opt       Options
+	vfs       *vfs.VFS
+	tlsConfig *tls.Config // set if FTPS is enabled
+	minPort   int         // lowest passive port or 0 for any
+	maxPort   int         // highest passive port
+	ln        net.Listener
+	done      chan error
+
+	mu    sync.Mutex
+	conns map[*conn]struct{}
+}
+
+// parsePortRange parses a range of ports like "50000-50100"
+func parsePortRange(portRange string) (min, max int, err error) {
+	if portRange == "" {
+		return 0, 0, nil
+	}
+	dash := strings.IndexRune(portRange, '-')
+	if dash < 0 {
+		return 0, 0, errors.Errorf("bad port range %q - use eg 50000-50100", portRange)
+	}
+	min, err = strconv.Atoi(strings.TrimSpace(portRange[:dash]))
+	if err == nil {
+		max, err = strconv.Atoi(strings.TrimSpace(portRange[dash+1:]))
+	}
+	if err != nil || min <= 0 || max > 65535 || min > max {
+		return 0, 0, errors.Errorf("bad port range %q - use eg 50000-50100", portRange)
+	}
+	return min, max, nil
+}
+
+// newServer makes a new FTP server for f
+func newServer(f fs.Fs, opt *Options) (*Server, error) {
+	s := &Server{
+		f:     f,
+		opt:   *opt,
+		vfs:   vfs.New(f, &vfsflags.Opt),
+		conns: make(map[*conn]struct{}),
+	}
+	var err error
+	s.minPort, s.maxPort, err = parsePortRange(opt.PassivePorts)
+	if err != nil {
+		return nil, err
+	}
+	if opt.PublicIP != "" && net.ParseIP(opt.PublicIP).To4() == nil {
+		return nil, errors.Errorf("public IP %q must be an IPv4 address", opt.PublicIP)
+	}
+	if opt.TLSCert != "" || opt.TLSKey != "" {
+		if opt.TLSCert == "" || opt.TLSKey == "" {
+			return nil, errors.New("need both --ftp-tls-cert and --ftp-tls-key to use FTPS")
+		}
+		cert, err := tls.LoadX509KeyPair(opt.TLSCert, opt.TLSKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load TLS certificate")
+		}
+		s.tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	return s, nil
+}
+
+// Serve starts the server listening in the background
+func (s *Server) Serve() (err error) {
+	s.ln, err = net.Listen("tcp", s.opt.ListenAddr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for FTP")
+	}
+	if s.tlsConfig != nil {
+		fs.Logf(s.f, "FTP server started on %s with explicit FTPS", s.Addr())
+	} else {
+		fs.Logf(s.f, "FTP server started on %s", s.Addr())
+	}
+	s.done = make(chan error, 1)
+	go func() {
+		s.done <- s.serve()
+	}()
+	return nil
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve() error {
+	for {
+		ctrl, err := s.ln.Accept()
+		if err != nil {
+			return err
+		}
+		c := newConn(s, ctrl)
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		go func() {
+			c.serve()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Wait blocks until the server is closed
+func (s *Server) Wait() {
+	<-s.done
+}
+
+// Close stops the server and closes all the connections
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.close()
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// checkLogin returns whether user and pass may log in
+func (s *Server) checkLogin(user, pass string) bool {
+	if s.opt.BasicUser == "" {
+		return true
+	}
+	return user == s.opt.BasicUser && pass == s.opt.BasicPass
+}
+
+// listenPassive listens for a passive data connection on ip, using a
+// port in the passive port range if there is one
+func (s *Server) listenPassive(ip net.IP) (net.Listener, error) {
+	if s.minPort == 0 {
+		return net.Listen("tcp", net.JoinHostPort(ip.String(), "0"))
+	}
+	// Start at a random port in the range so concurrent
+	// connections don't all fight over the first ones
+	n := s.maxPort - s.minPort + 1
+	start := rand.Intn(n)
+	for i := 0; i < n; i++ {
+		port := s.minPort + (start+i)%n
+		ln, err := net.Listen("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+		if err == nil {
+			return ln, nil
+		}
+	}
+	return nil, errors.Errorf("no free ports in passive port range %d-%d", s.minPort, s.maxPort)
+}
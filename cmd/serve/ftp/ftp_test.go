@@ -0,0 +1,321 @@
+package ftp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient talks FTP to the server under test
+type testClient struct {
+	t         *testing.T
+	conn      net.Conn
+	text      *textproto.Conn
+	tlsConfig *tls.Config // set to make the data connections TLS
+}
+
+// newTestClient connects to s and reads the greeting
+func newTestClient(t *testing.T, s *Server) *testClient {
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	c := &testClient{t: t, conn: conn, text: textproto.NewConn(conn)}
+	_, _, err = c.text.ReadResponse(220)
+	require.NoError(t, err)
+	return c
+}
+
+// cmd sends a command checking the reply has code and returns the
+// message
+func (c *testClient) cmd(code int, format string, a ...interface{}) string {
+	_, err := c.text.Cmd(format, a...)
+	require.NoError(c.t, err)
+	gotCode, msg, err := c.text.ReadResponse(0)
+	require.NoError(c.t, err)
+	require.Equal(c.t, code, gotCode, "%s: %s", fmt.Sprintf(format, a...), msg)
+	return msg
+}
+
+// startTLS upgrades the control connection with AUTH TLS
+func (c *testClient) startTLS() {
+	c.cmd(234, "AUTH TLS")
+	tlsConn := tls.Client(c.conn, &tls.Config{InsecureSkipVerify: true})
+	require.NoError(c.t, tlsConn.Handshake())
+	c.conn = tlsConn
+	c.text = textproto.NewConn(tlsConn)
+}
+
+var epsvRe = regexp.MustCompile(`\(\|\|\|(\d+)\|\)`)
+
+// data runs a command which uses a data connection sending in and
+// returning what was received
+func (c *testClient) data(in string, format string, a ...interface{}) string {
+	msg := c.cmd(229, "EPSV")
+	match := epsvRe.FindStringSubmatch(msg)
+	require.NotNil(c.t, match, msg)
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	require.NoError(c.t, err)
+	data, err := net.Dial("tcp", net.JoinHostPort(host, match[1]))
+	require.NoError(c.t, err)
+	c.cmd(150, format, a...)
+	if c.tlsConfig != nil {
+		tlsData := tls.Client(data, c.tlsConfig)
+		require.NoError(c.t, tlsData.Handshake())
+		data = tlsData
+	}
+	if in != "" {
+		_, err = data.Write([]byte(in))
+		require.NoError(c.t, err)
+	}
+	require.NoError(c.t, data.(interface{ CloseWrite() error }).CloseWrite())
+	out, err := ioutil.ReadAll(data)
+	require.NoError(c.t, err)
+	require.NoError(c.t, data.Close())
+	_, _, err = c.text.ReadResponse(226)
+	require.NoError(c.t, err)
+	return string(out)
+}
+
+// newTestServer starts a server on the local directory dir
+func newTestServer(t *testing.T, dir string, opt Options) *Server {
+	f, err := fs.NewFs(dir)
+	require.NoError(t, err)
+	opt.ListenAddr = "localhost:0"
+	s, err := newServer(f, &opt)
+	require.NoError(t, err)
+	require.NoError(t, s.Serve())
+	return s
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-ftp")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	remoteDir := filepath.Join(dir, "remote")
+	require.NoError(t, os.Mkdir(remoteDir, 0777))
+
+	// Use the cache so files can be appended to
+	oldCacheDir, oldCacheMode := config.CacheDir, vfsflags.Opt.CacheMode
+	config.CacheDir, vfsflags.Opt.CacheMode = filepath.Join(dir, "cache"), vfs.CacheModeWrites
+	defer func() { config.CacheDir, vfsflags.Opt.CacheMode = oldCacheDir, oldCacheMode }()
+
+	opt := DefaultOpt
+	opt.BasicUser = "user"
+	opt.BasicPass = "pass"
+	s := newTestServer(t, remoteDir, opt)
+	defer func() { _ = s.Close() }()
+	c := newTestClient(t, s)
+
+	c.cmd(530, "LIST")
+	c.cmd(331, "USER user")
+	c.cmd(530, "PASS wrong")
+	c.cmd(331, "USER user")
+	c.cmd(230, "PASS pass")
+	c.cmd(502, "AUTH TLS")
+	c.cmd(502, "PORT 127,0,0,1,4,1")
+	c.cmd(425, "LIST")
+	assert.Equal(t, `"/" is the current directory`, c.cmd(257, "PWD"))
+
+	c.cmd(257, "MKD dir")
+	c.cmd(250, "CWD dir")
+	assert.Equal(t, `"/dir" is the current directory`, c.cmd(257, "PWD"))
+	c.cmd(550, "CWD missing")
+
+	assert.Equal(t, "", c.data("hello", "STOR file.txt"))
+	assert.Equal(t, "5", c.cmd(213, "SIZE file.txt"))
+	assert.Equal(t, "5", c.cmd(213, "SIZE /dir/file.txt"))
+	assert.Regexp(t, `^\d{14}$`, c.cmd(213, "MDTM file.txt"))
+	c.cmd(550, "SIZE missing.txt")
+	c.cmd(550, "RETR missing.txt")
+
+	assert.Equal(t, "hello", c.data("", "RETR file.txt"))
+	c.cmd(350, "REST 2")
+	assert.Equal(t, "llo", c.data("", "RETR file.txt"))
+	assert.Equal(t, "file.txt\r\n", c.data("", "NLST"))
+	assert.Regexp(t, `^-rw-r--r-- 1 ftp ftp +5 \w{3} [ \d]\d +[\d:]+ file.txt\r\n$`, c.data("", "LIST -la"))
+	assert.Equal(t, "", c.data(" world", "APPE file.txt"))
+	assert.Equal(t, "hello world", c.data("", "RETR file.txt"))
+
+	c.cmd(503, "RNTO new.txt")
+	c.cmd(350, "RNFR file.txt")
+	c.cmd(250, "RNTO new.txt")
+	c.cmd(550, "SIZE file.txt")
+	contents, err := ioutil.ReadFile(filepath.Join(remoteDir, "dir", "new.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(contents))
+
+	c.cmd(250, "CDUP")
+	assert.Regexp(t, `^d\S+ 1 ftp ftp .* dir\r\n$`, c.data("", "LIST"))
+	c.cmd(550, "DELE dir")
+	c.cmd(250, "DELE dir/new.txt")
+	c.cmd(250, "RMD dir")
+	_, err = os.Stat(filepath.Join(remoteDir, "dir"))
+	assert.True(t, os.IsNotExist(err))
+
+	c.cmd(221, "QUIT")
+}
+
+// writeTestCert writes a self signed certificate and its key to dir
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestServerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-ftp")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	remoteDir := filepath.Join(dir, "remote")
+	require.NoError(t, os.Mkdir(remoteDir, 0777))
+
+	opt := DefaultOpt
+	opt.TLSCert, opt.TLSKey = writeTestCert(t, dir)
+	s := newTestServer(t, remoteDir, opt)
+	defer func() { _ = s.Close() }()
+	c := newTestClient(t, s)
+
+	assert.Contains(t, c.cmd(211, "FEAT"), "AUTH TLS")
+	c.cmd(530, "USER anonymous")
+	c.cmd(503, "PBSZ 0")
+	c.startTLS()
+	c.cmd(503, "PROT P")
+	c.cmd(331, "USER anonymous")
+	c.cmd(230, "PASS anything")
+	c.cmd(503, "AUTH TLS")
+	c.cmd(200, "PBSZ 0")
+	c.cmd(534, "PROT C")
+
+	// Plaintext data connections are refused
+	c.cmd(229, "EPSV")
+	c.cmd(521, "LIST")
+
+	c.cmd(200, "PROT P")
+	c.tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	assert.Equal(t, "", c.data("secret", "STOR file.txt"))
+	assert.Equal(t, "secret", c.data("", "RETR file.txt"))
+	assert.Equal(t, "file.txt\r\n", c.data("", "NLST"))
+	c.cmd(221, "QUIT")
+
+	// Can't make the server with only half the TLS config
+	f, err := fs.NewFs(remoteDir)
+	require.NoError(t, err)
+	opt.TLSKey = ""
+	_, err = newServer(f, &opt)
+	assert.Error(t, err)
+}
+
+func TestServerLineTooLong(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-ftp")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	s := newTestServer(t, dir, DefaultOpt)
+	defer func() { _ = s.Close() }()
+	c := newTestClient(t, s)
+
+	c.cmd(200, "NOOP")
+	c.cmd(500, "NOOP %s", strings.Repeat("A", maxLineLength))
+
+	// The server hangs up after a line which is too long
+	_, _, err = c.text.ReadResponse(0)
+	assert.Error(t, err)
+}
+
+func TestParsePortRange(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		min     int
+		max     int
+		wantErr bool
+	}{
+		{"", 0, 0, false},
+		{"50000-50100", 50000, 50100, false},
+		{"50000 - 50000", 50000, 50000, false},
+		{"50000", 0, 0, true},
+		{"50100-50000", 0, 0, true},
+		{"0-10", 0, 0, true},
+		{"1-65536", 0, 0, true},
+		{"a-b", 0, 0, true},
+	} {
+		min, max, err := parsePortRange(test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+		assert.Equal(t, test.min, min, test.in)
+		assert.Equal(t, test.max, max, test.in)
+	}
+}
+
+func TestPassivePorts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-ftp")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// Find a free port to use as the range
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+
+	opt := DefaultOpt
+	opt.PassivePorts = fmt.Sprintf("%d-%d", port, port)
+	opt.PublicIP = "1.2.3.4"
+	s := newTestServer(t, dir, opt)
+	defer func() { _ = s.Close() }()
+	c := newTestClient(t, s)
+	c.cmd(331, "USER anonymous")
+	c.cmd(230, "PASS anything")
+
+	msg := c.cmd(227, "PASV")
+	assert.Equal(t, fmt.Sprintf("Entering Passive Mode (1,2,3,4,%d,%d)", port>>8, port&0xFF), msg)
+	// PASV again closes the first listener so can reuse the port
+	msg = c.cmd(229, "EPSV")
+	assert.Equal(t, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", port), msg)
+
+	// A second client can't get a port as the range is full
+	c2 := newTestClient(t, s)
+	c2.cmd(331, "USER anonymous")
+	c2.cmd(230, "PASS anything")
+	assert.Contains(t, c2.cmd(425, "EPSV"), "no free ports in passive port range "+strconv.Itoa(port))
+
+	assert.Equal(t, "", c.data("", "NLST"))
+}
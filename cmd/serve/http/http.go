@@ -3,6 +3,7 @@ package http
 import (
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -82,7 +83,9 @@ func (s *server) serve() {
 
 // handler reads incoming requests and dispatches them
 func (s *server) handler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" && r.Method != "HEAD" {
+	// Only allow uploads with a presigned URL
+	isPut := r.Method == "PUT" && httplib.Presigned(r)
+	if r.Method != "GET" && r.Method != "HEAD" && !isPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -92,7 +95,9 @@ func (s *server) handler(w http.ResponseWriter, r *http.Request) {
 	urlPath := r.URL.Path
 	isDir := strings.HasSuffix(urlPath, "/")
 	remote := strings.Trim(urlPath, "/")
-	if isDir {
+	if isPut {
+		s.putFile(w, r, remote)
+	} else if isDir {
 		s.serveDir(w, r, remote)
 	} else {
 		s.serveFile(w, r, remote)
@@ -197,6 +202,30 @@ func (s *server) serveDir(w http.ResponseWriter, r *http.Request, dirRemote stri
 	}
 }
 
+// putFile uploads the body of the request to remote
+func (s *server) putFile(w http.ResponseWriter, r *http.Request, remote string) {
+	if remote == "" || strings.HasSuffix(r.URL.Path, "/") {
+		http.Error(w, "Can't upload to a directory", http.StatusBadRequest)
+		return
+	}
+	fs.Infof(remote, "%s: Uploading file", r.RemoteAddr)
+	out, err := s.vfs.OpenFile(remote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		internalError(remote, w, "Failed to create file", err)
+		return
+	}
+	_, err = io.Copy(out, r.Body)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		internalError(remote, w, "Failed to upload file", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
 // serveFile serves a file object at remote
 func (s *server) serveFile(w http.ResponseWriter, r *http.Request, remote string) {
 	node, err := s.vfs.Stat(remote)
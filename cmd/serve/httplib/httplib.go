@@ -70,7 +70,7 @@ supply --client-ca also.
 of that with the CA certificate.  --key should be the PEM encoded
 private key and --client-ca should be the PEM encoded client
 certificate authority certificate.
-`
+` + PresignHelp
 
 // Options contains options for the http Server
 type Options struct {
@@ -85,6 +85,7 @@ type Options struct {
 	Realm              string        // realm for authentication
 	BasicUser          string        // single username for basic auth if not using Htpasswd
 	BasicPass          string        // password for BasicUser
+	PresignKey         string        // key to check presigned URLs with - if not provided they aren't accepted
 }
 
 // DefaultOpt is the default values used for Options
@@ -130,6 +131,7 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 	}
 
 	// Use htpasswd if required on everything
+	rawHandler := handler
 	if s.Opt.HtPasswd != "" || s.Opt.BasicUser != "" {
 		var secretProvider auth.SecretProvider
 		if s.Opt.HtPasswd != "" {
@@ -144,6 +146,12 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 		handler = auth.JustCheck(authenticator, handler.ServeHTTP)
 	}
 
+	// Let presigned URLs through without authentication
+	if s.Opt.PresignKey != "" {
+		fs.Infof(nil, "Accepting URLs presigned with --presign-key")
+		handler = presignHandler(s.Opt.PresignKey, rawHandler, handler)
+	}
+
 	s.useSSL = s.Opt.SslKey != ""
 	if (s.Opt.SslCert != "") != s.useSSL {
 		log.Fatalf("Need both -cert and -key to use SSL")
@@ -0,0 +1,132 @@
+package httplib
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/lib/rest"
+	"github.com/pkg/errors"
+)
+
+// Query parameters used in presigned URLs
+const (
+	presignExpires   = "rclone-expires"
+	presignSignature = "rclone-signature"
+)
+
+// PresignHelp contains text describing presigned URLs to add to the
+// command help.
+var PresignHelp = `
+#### Presigned URLs
+
+If --presign-key is set then the server will accept URLs signed with
+that key made by "rclone genpresign".  These allow access to a single
+file with a single method (GET or PUT) until they expire.  Presigned
+URLs are accepted even if authentication is in use so they can be
+handed out to clients who don't have a login.
+`
+
+// presignKey is the type of the context key for presigned requests
+type presignKey struct{}
+
+// presignPayload returns the string to be signed
+func presignPayload(method, urlPath string, expires int64) string {
+	return method + "\n" + urlPath + "\n" + strconv.FormatInt(expires, 10)
+}
+
+// presignHMAC returns the hex encoded signature of payload with key
+func presignHMAC(key, payload string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	_, _ = mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PresignURL returns a URL for remote on the server at baseURL which
+// is valid for method until expires when signed with key.
+func PresignURL(baseURL, key, method, remote string, expires time.Time) (string, error) {
+	if key == "" {
+		return "", errors.New("presign key must be set")
+	}
+	method = strings.ToUpper(method)
+	if method != "GET" && method != "PUT" {
+		return "", errors.Errorf("can't presign method %q - must be GET or PUT", method)
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse base URL")
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.Errorf("base URL %q must be of the form http://host:port/", baseURL)
+	}
+	if strings.Trim(u.Path, "/") != "" {
+		return "", errors.Errorf("base URL %q must be the root of the server", baseURL)
+	}
+	urlPath := "/" + strings.Trim(remote, "/")
+	unix := expires.Unix()
+	q := url.Values{}
+	q.Set(presignExpires, strconv.FormatInt(unix, 10))
+	q.Set(presignSignature, presignHMAC(key, presignPayload(method, urlPath, unix)))
+	return u.Scheme + "://" + u.Host + rest.URLPathEscape(urlPath) + "?" + q.Encode(), nil
+}
+
+// checkPresigned checks the signature on r and returns whether it
+// was presigned.  It returns an error if the signature is present but
+// is invalid or has expired.
+func checkPresigned(key string, r *http.Request, now time.Time) (presigned bool, err error) {
+	q := r.URL.Query()
+	signature := q.Get(presignSignature)
+	if signature == "" {
+		return false, nil
+	}
+	expires, err := strconv.ParseInt(q.Get(presignExpires), 10, 64)
+	if err != nil {
+		return true, errors.New("bad expiry in presigned URL")
+	}
+	method := r.Method
+	if method == "HEAD" {
+		method = "GET"
+	}
+	want := presignHMAC(key, presignPayload(method, r.URL.Path, expires))
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return true, errors.New("bad signature in presigned URL")
+	}
+	if now.Unix() > expires {
+		return true, errors.New("presigned URL has expired")
+	}
+	return true, nil
+}
+
+// Presigned returns whether the request was allowed because it had a
+// valid presigned URL.
+func Presigned(r *http.Request) bool {
+	presigned, _ := r.Context().Value(presignKey{}).(bool)
+	return presigned
+}
+
+// presignHandler returns a handler which passes requests with a valid
+// presigned URL straight to handler, marking them as presigned, and
+// everything else to authHandler.
+func presignHandler(key string, handler, authHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presigned, err := checkPresigned(key, r, time.Now())
+		if err != nil {
+			fs.Infof(r.URL.Path, "%s: %v", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if !presigned {
+			authHandler.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), presignKey{}, true))
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,100 @@
+package httplib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresignURL(t *testing.T) {
+	expires := time.Unix(1500000000, 0)
+	u, err := PresignURL("http://localhost:8080/", "key", "get", "dir/file name.txt", expires)
+	require.NoError(t, err)
+	parsed, err := url.Parse(u)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:8080", parsed.Host)
+	assert.Equal(t, "/dir/file name.txt", parsed.Path)
+	assert.Equal(t, "1500000000", parsed.Query().Get(presignExpires))
+	assert.NotEqual(t, "", parsed.Query().Get(presignSignature))
+
+	for _, bad := range []struct {
+		baseURL, key, method string
+	}{
+		{"http://localhost:8080/", "", "GET"},
+		{"http://localhost:8080/", "key", "DELETE"},
+		{"localhost:8080", "key", "GET"},
+		{"http://localhost:8080/path/", "key", "GET"},
+	} {
+		_, err := PresignURL(bad.baseURL, bad.key, bad.method, "file", expires)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCheckPresigned(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	get, err := PresignURL("http://localhost:8080/", "key", "GET", "dir/file.txt", now.Add(time.Minute))
+	require.NoError(t, err)
+	put, err := PresignURL("http://localhost:8080/", "key", "PUT", "dir/file.txt", now.Add(time.Minute))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		method        string
+		url           string
+		key           string
+		now           time.Time
+		wantPresigned bool
+		wantErr       bool
+	}{
+		{"GET", "http://localhost:8080/dir/file.txt", "key", now, false, false},
+		{"GET", get, "key", now, true, false},
+		{"HEAD", get, "key", now, true, false},
+		{"PUT", get, "key", now, true, true},
+		{"PUT", put, "key", now, true, false},
+		{"GET", get, "otherkey", now, true, true},
+		{"GET", get, "key", now.Add(2 * time.Minute), true, true},
+		{"GET", "http://localhost:8080/dir/other.txt?" + mustParse(t, get).RawQuery, "key", now, true, true},
+	} {
+		r := httptest.NewRequest(test.method, test.url, nil)
+		presigned, err := checkPresigned(test.key, r, test.now)
+		assert.Equal(t, test.wantPresigned, presigned, "%+v", test)
+		assert.Equal(t, test.wantErr, err != nil, "%+v: %v", test, err)
+	}
+}
+
+func mustParse(t *testing.T, in string) *url.URL {
+	u, err := url.Parse(in)
+	require.NoError(t, err)
+	return u
+}
+
+func TestPresignHandler(t *testing.T) {
+	var gotPresigned bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPresigned = Presigned(r)
+	})
+	authHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "auth", http.StatusUnauthorized)
+	})
+	h := presignHandler("key", handler, authHandler)
+
+	get, err := PresignURL("http://localhost:8080/", "key", "GET", "file.txt", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", get, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, gotPresigned)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/file.txt", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", get, nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,201 @@
+// Open files for NFS reads and writes
+
+package nfs
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/vfs"
+)
+
+// openFile is a VFS file kept open between NFS calls
+type openFile struct {
+	handle  vfs.Handle
+	write   bool      // set if open for write
+	canRead bool      // set if reads can use handle
+	users   int       // number of calls using handle
+	used    time.Time // when handle was last used
+}
+
+// openFiles keeps the VFS files used by the NFS calls open
+//
+// NFS has no open and close so the files opened to read or write are
+// kept open until the client commits the writes, or until they haven't
+// been used for the timeout.
+type openFiles struct {
+	vfs     *vfs.VFS
+	timeout time.Duration
+	mu      sync.Mutex
+	unused  *sync.Cond // signalled on mu when a file stops being used
+	files   map[string]*openFile
+	stop    chan struct{}
+}
+
+// newOpenFiles makes a new openFiles closing files unused for timeout
+func newOpenFiles(VFS *vfs.VFS, timeout time.Duration) *openFiles {
+	o := &openFiles{
+		vfs:     VFS,
+		timeout: timeout,
+		files:   make(map[string]*openFile),
+		stop:    make(chan struct{}),
+	}
+	o.unused = sync.NewCond(&o.mu)
+	go o.closeIdle()
+	return o
+}
+
+// writeFlags returns the flags to open a file for write with
+func (o *openFiles) writeFlags() int {
+	// Use O_RDWR if the cache allows reads on the same handle
+	if o.vfs.Opt.CacheMode >= vfs.CacheModeMinimal {
+		return os.O_RDWR
+	}
+	return os.O_WRONLY
+}
+
+// use calls fn with a handle for path open for write if write is set
+// or for read otherwise.
+//
+// If the file isn't open in a suitable way then it is opened with
+// extraFlags added to the flags.
+func (o *openFiles) use(path string, write bool, extraFlags int, fn func(vfs.Handle) error) error {
+	o.mu.Lock()
+	file, ok := o.files[path]
+	for ok && (write && !file.write || !write && !file.canRead) {
+		// Open in the wrong mode so close it first
+		file = o.remove(path)
+		o.mu.Unlock()
+		if file != nil {
+			_ = o.closeFile(path, file)
+		}
+		o.mu.Lock()
+		file, ok = o.files[path]
+	}
+	if ok && extraFlags&os.O_TRUNC != 0 {
+		// Truncate the already open file
+		err := file.handle.Truncate(0)
+		if err != nil {
+			o.mu.Unlock()
+			return err
+		}
+	}
+	if !ok {
+		flags := os.O_RDONLY
+		if write {
+			flags = o.writeFlags()
+		}
+		handle, err := o.vfs.OpenFile(path, flags|extraFlags, 0777)
+		if err != nil {
+			o.mu.Unlock()
+			return err
+		}
+		file = &openFile{
+			handle:  handle,
+			write:   write,
+			canRead: !write || flags == os.O_RDWR,
+		}
+		o.files[path] = file
+	}
+	file.users++
+	file.used = time.Now()
+	o.mu.Unlock()
+
+	err := fn(file.handle)
+
+	o.mu.Lock()
+	file.users--
+	file.used = time.Now()
+	if file.users == 0 {
+		o.unused.Broadcast()
+	}
+	o.mu.Unlock()
+	return err
+}
+
+// remove waits until no calls are using path then removes it from the
+// open files and returns it, or nil if it isn't open
+//
+// Call with mu held
+func (o *openFiles) remove(path string) *openFile {
+	for {
+		file, ok := o.files[path]
+		if !ok {
+			return nil
+		}
+		if file.users == 0 {
+			delete(o.files, path)
+			return file
+		}
+		o.unused.Wait()
+	}
+}
+
+// isOpen returns whether path is open for write
+func (o *openFiles) isOpen(path string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	file, ok := o.files[path]
+	return ok && file.write
+}
+
+// close closes path if it is open returning any error from finishing
+// the writes.  It waits for any calls using path to finish first.
+func (o *openFiles) close(path string) error {
+	o.mu.Lock()
+	file := o.remove(path)
+	o.mu.Unlock()
+	if file == nil {
+		return nil
+	}
+	return o.closeFile(path, file)
+}
+
+// closeFile closes file logging any errors
+func (o *openFiles) closeFile(path string, file *openFile) error {
+	err := file.handle.Close()
+	if err != nil {
+		fs.Errorf(path, "NFS: failed to close file: %v", err)
+	}
+	return err
+}
+
+// closeAll closes all the open files and stops closing idle files
+func (o *openFiles) closeAll() {
+	close(o.stop)
+	o.mu.Lock()
+	files := o.files
+	o.files = make(map[string]*openFile)
+	o.mu.Unlock()
+	for path, file := range files {
+		_ = o.closeFile(path, file)
+	}
+}
+
+// closeIdle closes files which haven't been used for the timeout
+func (o *openFiles) closeIdle() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case now := <-ticker.C:
+			idle := make(map[string]*openFile)
+			o.mu.Lock()
+			for path, file := range o.files {
+				if file.users == 0 && now.Sub(file.used) >= o.timeout {
+					idle[path] = file
+					delete(o.files, path)
+				}
+			}
+			o.mu.Unlock()
+			for path, file := range idle {
+				fs.Debugf(path, "NFS: closing idle file")
+				_ = o.closeFile(path, file)
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// NFS file handles
+
+package nfs
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// handleSize is the size of the file handles we make - NFSv3 allows
+// up to 64 bytes
+const handleSize = 16
+
+// handles maps NFS file handles to paths in the VFS
+//
+// A handle is made from the hash of the path so it is the same every
+// time the server is run.  The handles are saved in a file so they can
+// be turned back into paths after a restart, which means clients don't
+// get stale file handle errors.
+//
+// When a path is renamed its handles are kept and point to the new
+// path, so clients can carry on using them.  The handles of removed
+// paths are forgotten so the map only holds paths which exist.
+type handles struct {
+	mu      sync.Mutex
+	paths   map[string]string // handle to path
+	handles map[string]string // path to handle
+	file    string            // file the handles are saved in
+	out     *os.File          // file to save new handles to or nil
+	lines   int               // number of lines in out
+}
+
+// newHandles makes a new handles loading and saving them to file if
+// it isn't empty
+func newHandles(file string) (*handles, error) {
+	h := &handles{
+		paths:   make(map[string]string),
+		handles: make(map[string]string),
+		file:    file,
+	}
+	if file == "" {
+		return h, nil
+	}
+	err := os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make directory for NFS handles")
+	}
+	h.out, err = os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open NFS handles")
+	}
+	// Each line is a handle and its path, or just a handle if it
+	// has been forgotten
+	scanner := bufio.NewScanner(h.out)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		h.lines++
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		handle, err := hex.DecodeString(fields[0])
+		if err != nil {
+			continue
+		}
+		if len(fields) == 1 {
+			h.remove(string(handle))
+			continue
+		}
+		path, err := strconv.Unquote(fields[1])
+		if err != nil {
+			continue
+		}
+		h.set(string(handle), path)
+	}
+	if err := scanner.Err(); err != nil {
+		_ = h.out.Close()
+		return nil, errors.Wrap(err, "failed to read NFS handles")
+	}
+	fs.Debugf(nil, "Loaded %d NFS handles from %q", len(h.paths), file)
+	if h.lines > 2*len(h.paths)+compactLines {
+		err = h.compact()
+		if err != nil {
+			_ = h.out.Close()
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// compactLines is how many more lines than needed the handles file
+// can have before it is rewritten when it is loaded
+const compactLines = 1000
+
+// compact rewrites the handles file with only the current handles in
+func (h *handles) compact() error {
+	tmp := h.file + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to compact NFS handles")
+	}
+	w := bufio.NewWriter(out)
+	for handle, path := range h.paths {
+		_, _ = fmt.Fprintf(w, "%x %s\n", handle, strconv.Quote(path))
+	}
+	err = w.Flush()
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, h.file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(err, "failed to compact NFS handles")
+	}
+	_ = h.out.Close()
+	h.out, err = os.OpenFile(h.file, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open NFS handles")
+	}
+	fs.Debugf(nil, "Compacted NFS handles from %d to %d lines", h.lines, len(h.paths))
+	h.lines = len(h.paths)
+	return nil
+}
+
+// set makes handle point to path
+//
+// Call with mu held
+func (h *handles) set(handle, path string) {
+	h.remove(handle)
+	h.paths[handle] = path
+	h.handles[path] = handle
+}
+
+// remove forgets handle
+//
+// Call with mu held
+func (h *handles) remove(handle string) {
+	if path, ok := h.paths[handle]; ok {
+		delete(h.paths, handle)
+		if h.handles[path] == handle {
+			delete(h.handles, path)
+		}
+	}
+}
+
+// save appends line to the handles file if there is one
+//
+// Call with mu held
+func (h *handles) save(format string, a ...interface{}) {
+	if h.out == nil {
+		return
+	}
+	_, err := fmt.Fprintf(h.out, format, a...)
+	if err != nil {
+		fs.Errorf(nil, "Failed to save NFS handle: %v", err)
+		return
+	}
+	h.lines++
+}
+
+// toHandle returns the handle for path
+//
+// This is the hash of the path unless that is in use by another path,
+// eg one which was renamed from path, in which case the hash of the
+// path with a counter added is used.
+func (h *handles) toHandle(path string) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if handle, ok := h.handles[path]; ok {
+		return []byte(handle)
+	}
+	for n := 0; ; n++ {
+		in := path
+		if n > 0 {
+			in = fmt.Sprintf("%s\x00%d", path, n)
+		}
+		sum := sha256.Sum256([]byte(in))
+		handle := string(sum[:handleSize])
+		if _, ok := h.paths[handle]; !ok {
+			h.set(handle, path)
+			h.save("%x %s\n", handle, strconv.Quote(path))
+			return []byte(handle)
+		}
+	}
+}
+
+// toPath returns the path for handle and whether it was found
+func (h *handles) toPath(handle []byte) (path string, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	path, ok = h.paths[string(handle)]
+	return path, ok
+}
+
+// isWithin returns whether path is dir or in dir
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// rename makes the handles for from and anything in it point to the
+// same paths in to, forgetting the handles of anything at to first
+func (h *handles) rename(from, to string) {
+	h.forget(to)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	renamed := make(map[string]string)
+	for handle, path := range h.paths {
+		if isWithin(path, from) {
+			renamed[handle] = to + path[len(from):]
+		}
+	}
+	for handle, newPath := range renamed {
+		h.set(handle, newPath)
+		h.save("%x %s\n", handle, strconv.Quote(newPath))
+	}
+}
+
+// forget forgets the handles for path and anything in it
+func (h *handles) forget(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for handle, p := range h.paths {
+		if isWithin(p, path) {
+			h.remove(handle)
+			h.save("%x\n", handle)
+		}
+	}
+}
+
+// close closes the file the handles are saved to
+func (h *handles) close() error {
+	if h.out == nil {
+		return nil
+	}
+	return h.out.Close()
+}
+
+// fileID returns the NFS fileid for handle which is stable like the
+// handle
+func fileID(handle []byte) uint64 {
+	return binary.BigEndian.Uint64(handle)
+}
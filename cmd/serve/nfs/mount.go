@@ -0,0 +1,93 @@
+// The MOUNT protocol version 3 as described in RFC 1813 appendix I
+
+package nfs
+
+import (
+	"strings"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// MOUNT constants
+const (
+	mountProgram = 100005
+	mountVersion = 3
+
+	mountProcNull    = 0
+	mountProcMnt     = 1
+	mountProcDump    = 2
+	mountProcUmnt    = 3
+	mountProcUmntAll = 4
+	mountProcExport  = 5
+
+	mountOK        = 0
+	mountErrNoEnt  = 2
+	mountErrNotDir = 20
+
+	maxPathLen = 1024
+)
+
+// registerMount adds the MOUNT program to the RPC server
+func (s *Server) registerMount() {
+	s.rpc.register(mountProgram, mountVersion, map[uint32]rpcProc{
+		mountProcNull:    nullProc,
+		mountProcMnt:     s.mountMnt,
+		mountProcDump:    s.mountDump,
+		mountProcUmnt:    s.mountUmnt,
+		mountProcUmntAll: nullProc,
+		mountProcExport:  s.mountExport,
+	})
+}
+
+// mountMnt returns the handle of the directory to mount.  This can be
+// the root or any directory in it.
+func (s *Server) mountMnt(args *xdrReader, res *xdrWriter) error {
+	dirPath := args.string(maxPathLen)
+	if args.err != nil {
+		return args.err
+	}
+	dirPath = strings.Trim(dirPath, "/")
+	fs.Infof(nil, "NFS mount of %q", "/"+dirPath)
+	node, err := s.vfs.Stat(dirPath)
+	if err != nil {
+		res.uint32(mountErrNoEnt)
+		return nil
+	}
+	if !node.IsDir() {
+		res.uint32(mountErrNotDir)
+		return nil
+	}
+	res.uint32(mountOK)
+	res.opaque(s.handles.toHandle(node.Path()))
+	// auth flavors allowed
+	res.uint32(2)
+	res.uint32(authUnix)
+	res.uint32(authNone)
+	return nil
+}
+
+// mountDump returns the list of mounts which we don't keep
+func (s *Server) mountDump(args *xdrReader, res *xdrWriter) error {
+	res.bool(false)
+	return nil
+}
+
+// mountUmnt unmounts a directory which needs nothing doing
+func (s *Server) mountUmnt(args *xdrReader, res *xdrWriter) error {
+	dirPath := args.string(maxPathLen)
+	if args.err != nil {
+		return args.err
+	}
+	fs.Infof(nil, "NFS unmount of %q", dirPath)
+	return nil
+}
+
+// mountExport returns the list of exports which is just the root
+// available to everyone
+func (s *Server) mountExport(args *xdrReader, res *xdrWriter) error {
+	res.bool(true)
+	res.string("/")
+	res.bool(false) // no groups
+	res.bool(false) // no more exports
+	return nil
+}
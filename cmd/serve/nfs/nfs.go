@@ -0,0 +1,178 @@
+// Package nfs implements a server to serve a VFS remote over NFSv3
+package nfs
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/config/flags"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Options required for nfs server
+type Options struct {
+	ListenAddr      string        // Port to listen on
+	FileIdleTimeout time.Duration // close files not used for this long
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ListenAddr:      "localhost:2049",
+	FileIdleTimeout: 5 * time.Second,
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+func init() {
+	flagSet := Command.Flags()
+	flags.StringVarP(flagSet, &Opt.ListenAddr, "addr", "", Opt.ListenAddr, "IPaddress:Port or :Port to bind server to.")
+	vfsflags.AddFlags(flagSet)
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "nfs remote:path",
+	Short: `Serve the remote as an NFS export.`,
+	Long: `rclone serve nfs implements an NFS version 3 server to serve any
+rclone remote.  This lets machines on the network mount the remote
+with their own NFS client without needing FUSE or rclone installed.
+
+Use --addr to specify which IP address and port the server should
+listen on, eg --addr 1.2.3.4:2049 or --addr :2049 to listen to all
+IPs.  By default it only listens on localhost.
+
+There is no portmapper, and the MOUNT protocol is served on the same
+port as NFS, so the client needs to be told the ports and to use TCP,
+eg on Linux
+
+    mount -t nfs -o port=2049,mountport=2049,tcp,mountproto=tcp,vers=3,nolock localhost:/ /mnt/remote
+
+Any directory in the remote can be mounted by using its path in place
+of "/".  File locking isn't supported, hence the "nolock" option.
+
+There is no authentication - all clients which can connect can read
+and write the files, which are owned by the --uid and --gid given.
+Use --read-only to serve the remote read only.
+
+The file handles given to the clients are saved in the cache directory
+so they stay the same when the server is restarted, or when files are
+renamed, which means the clients don't see "Stale file handle" errors.
+
+NFS has no open and close so the server keeps files open until the
+client commits its writes or until they haven't been used for a few
+seconds, when they are uploaded.  Writes the client asks to be stable
+are synced to the VFS cache, so without one they are only stable once
+uploaded.  Without a VFS cache files must be written sequentially, so
+--vfs-cache-mode writes is recommended to serve a remote read write.
+` + vfs.Help,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, true, command, func() error {
+			s, err := newServer(f, &Opt)
+			if err != nil {
+				return err
+			}
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			s.Wait()
+			return nil
+		})
+	},
+}
+
+// Server is an NFS server serving a VFS
+type Server struct {
+	f        fs.Fs
+	opt      Options
+	vfs      *vfs.VFS
+	rpc      *rpcServer
+	handles  *handles
+	files    *openFiles
+	verifier [8]byte // write verifier which changes on every restart
+	fsid     uint64  // identifies the file system to the client
+	ln       net.Listener
+	done     chan error
+}
+
+// remoteHash returns a hash identifying the remote f
+func remoteHash(f fs.Fs) [md5.Size]byte {
+	return md5.Sum([]byte(fmt.Sprintf("%s:%s", f.Name(), f.Root())))
+}
+
+// handlesFile returns the file to save the handles for f in
+func handlesFile(f fs.Fs) string {
+	return filepath.Join(config.CacheDir, "serve-nfs", fmt.Sprintf("%x.handles", remoteHash(f)))
+}
+
+// newServer makes a new NFS server for f
+func newServer(f fs.Fs, opt *Options) (*Server, error) {
+	h, err := newHandles(handlesFile(f))
+	if err != nil {
+		return nil, err
+	}
+	VFS := vfs.New(f, &vfsflags.Opt)
+	s := &Server{
+		f:       f,
+		opt:     *opt,
+		vfs:     VFS,
+		rpc:     newRPCServer(),
+		handles: h,
+		files:   newOpenFiles(VFS, opt.FileIdleTimeout),
+	}
+	binary.BigEndian.PutUint64(s.verifier[:], uint64(time.Now().UnixNano()))
+	sum := remoteHash(f)
+	s.fsid = binary.BigEndian.Uint64(sum[:])
+	s.registerMount()
+	s.registerNFS()
+	return s, nil
+}
+
+// Serve starts the server listening in the background
+func (s *Server) Serve() (err error) {
+	s.ln, err = net.Listen("tcp", s.opt.ListenAddr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for NFS")
+	}
+	fs.Logf(s.f, "NFS server started on %s", s.Addr())
+	s.done = make(chan error, 1)
+	go func() {
+		s.done <- s.rpc.serve(s.ln)
+	}()
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Wait blocks until the server is closed
+func (s *Server) Wait() {
+	<-s.done
+}
+
+// Close stops the server, closing the open files which uploads any
+// writes to them
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.rpc.closeConns()
+	s.files.closeAll()
+	if closeErr := s.handles.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
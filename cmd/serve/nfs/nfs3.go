@@ -0,0 +1,934 @@
+// The NFS protocol version 3 as described in RFC 1813
+
+package nfs
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/vfs"
+	"github.com/pkg/errors"
+)
+
+// NFS constants
+const (
+	nfsProgram = 100003
+	nfsVersion = 3
+
+	// procedures
+	nfsProcNull        = 0
+	nfsProcGetAttr     = 1
+	nfsProcSetAttr     = 2
+	nfsProcLookup      = 3
+	nfsProcAccess      = 4
+	nfsProcReadLink    = 5
+	nfsProcRead        = 6
+	nfsProcWrite       = 7
+	nfsProcCreate      = 8
+	nfsProcMkdir       = 9
+	nfsProcSymlink     = 10
+	nfsProcMknod       = 11
+	nfsProcRemove      = 12
+	nfsProcRmdir       = 13
+	nfsProcRename      = 14
+	nfsProcLink        = 15
+	nfsProcReadDir     = 16
+	nfsProcReadDirPlus = 17
+	nfsProcFSStat      = 18
+	nfsProcFSInfo      = 19
+	nfsProcPathConf    = 20
+	nfsProcCommit      = 21
+
+	// nfsstat3
+	nfsOK             = 0
+	nfsErrPerm        = 1
+	nfsErrNoEnt       = 2
+	nfsErrIO          = 5
+	nfsErrExist       = 17
+	nfsErrNotDir      = 20
+	nfsErrIsDir       = 21
+	nfsErrInval       = 22
+	nfsErrROFS        = 30
+	nfsErrNameTooLong = 63
+	nfsErrNotEmpty    = 66
+	nfsErrStale       = 70
+	nfsErrBadHandle   = 10001
+	nfsErrNotSupp     = 10004
+	nfsErrTooSmall    = 10005
+
+	// ftype3
+	nfsTypeReg = 1
+	nfsTypeDir = 2
+
+	// time_how
+	nfsDontChange      = 0
+	nfsSetToServerTime = 1
+	nfsSetToClientTime = 2
+
+	// stable_how
+	nfsUnstable = 0
+	nfsFileSync = 2
+
+	// createmode3
+	nfsCreateUnchecked = 0
+	nfsCreateGuarded   = 1
+	nfsCreateExclusive = 2
+
+	// ACCESS bits
+	nfsAccessRead    = 0x01
+	nfsAccessLookup  = 0x02
+	nfsAccessModify  = 0x04
+	nfsAccessExtend  = 0x08
+	nfsAccessDelete  = 0x10
+	nfsAccessExecute = 0x20
+
+	// FSINFO properties
+	nfsFSFHomogeneous = 0x08
+	nfsFSFCanSetTime  = 0x10
+
+	maxHandleSize = 64      // maximum size of an NFSv3 handle
+	maxNameLen    = 255     // maximum length of a file name
+	maxIOSize     = 1 << 20 // maximum read and write size
+	ioMultiple    = 4096    // suggested multiple for read and write sizes
+	attrSize      = 84      // size of encoded fattr3
+)
+
+// registerNFS adds the NFS program to the RPC server
+func (s *Server) registerNFS() {
+	s.rpc.register(nfsProgram, nfsVersion, map[uint32]rpcProc{
+		nfsProcNull:        nullProc,
+		nfsProcGetAttr:     s.nfsGetAttr,
+		nfsProcSetAttr:     s.nfsSetAttr,
+		nfsProcLookup:      s.nfsLookup,
+		nfsProcAccess:      s.nfsAccess,
+		nfsProcReadLink:    s.nfsReadLink,
+		nfsProcRead:        s.nfsRead,
+		nfsProcWrite:       s.nfsWrite,
+		nfsProcCreate:      s.nfsCreate,
+		nfsProcMkdir:       s.nfsMkdir,
+		nfsProcSymlink:     s.nfsNotSuppWcc,
+		nfsProcMknod:       s.nfsNotSuppWcc,
+		nfsProcRemove:      s.nfsRemove,
+		nfsProcRmdir:       s.nfsRmdir,
+		nfsProcRename:      s.nfsRename,
+		nfsProcLink:        s.nfsLink,
+		nfsProcReadDir:     s.nfsReadDir,
+		nfsProcReadDirPlus: s.nfsReadDirPlus,
+		nfsProcFSStat:      s.nfsFSStat,
+		nfsProcFSInfo:      s.nfsFSInfo,
+		nfsProcPathConf:    s.nfsPathConf,
+		nfsProcCommit:      s.nfsCommit,
+	})
+}
+
+// nfsStatus converts a VFS error into an NFS status
+func nfsStatus(err error) uint32 {
+	switch errors.Cause(err) {
+	case nil:
+		return nfsOK
+	case vfs.ENOENT, fs.ErrorObjectNotFound, fs.ErrorDirNotFound:
+		return nfsErrNoEnt
+	case vfs.EEXIST, fs.ErrorDirExists:
+		return nfsErrExist
+	case vfs.EPERM:
+		return nfsErrPerm
+	case vfs.EINVAL:
+		return nfsErrInval
+	case vfs.ENOTEMPTY, fs.ErrorDirectoryNotEmpty:
+		return nfsErrNotEmpty
+	case vfs.EROFS:
+		return nfsErrROFS
+	case vfs.ENOSYS:
+		return nfsErrNotSupp
+	}
+	return nfsErrIO
+}
+
+// writeTime writes an nfstime3
+func writeTime(res *xdrWriter, t time.Time) {
+	if t.Unix() < 0 {
+		t = time.Unix(0, 0)
+	}
+	res.uint32(uint32(t.Unix()))
+	res.uint32(uint32(t.Nanosecond()))
+}
+
+// readTime reads an nfstime3
+func readTime(args *xdrReader) time.Time {
+	seconds := args.uint32()
+	nanoseconds := args.uint32()
+	return time.Unix(int64(seconds), int64(nanoseconds))
+}
+
+// writeAttr writes the fattr3 of node
+func (s *Server) writeAttr(res *xdrWriter, node vfs.Node) {
+	handle := s.handles.toHandle(node.Path())
+	size := uint64(0)
+	if node.Size() > 0 {
+		size = uint64(node.Size())
+	}
+	if node.IsDir() {
+		res.uint32(nfsTypeDir)
+		res.uint32(uint32(node.Mode() & os.ModePerm))
+		res.uint32(2) // nlink
+	} else {
+		res.uint32(nfsTypeReg)
+		res.uint32(uint32(node.Mode() & os.ModePerm))
+		res.uint32(1) // nlink
+	}
+	res.uint32(s.vfs.Opt.UID)
+	res.uint32(s.vfs.Opt.GID)
+	res.uint64(size)
+	res.uint64(size) // used
+	res.uint32(0)    // rdev
+	res.uint32(0)
+	res.uint64(s.fsid)
+	res.uint64(fileID(handle))
+	modTime := node.ModTime()
+	writeTime(res, modTime) // atime
+	writeTime(res, modTime) // mtime
+	writeTime(res, modTime) // ctime
+}
+
+// writePostOpAttr writes the post_op_attr of node which may be nil
+func (s *Server) writePostOpAttr(res *xdrWriter, node vfs.Node) {
+	if node == nil {
+		res.bool(false)
+		return
+	}
+	res.bool(true)
+	s.writeAttr(res, node)
+}
+
+// writeWcc writes the wcc_data for node which may be nil.  The
+// attributes before the operation aren't sent.
+func (s *Server) writeWcc(res *xdrWriter, node vfs.Node) {
+	res.bool(false)
+	s.writePostOpAttr(res, node)
+}
+
+// writePostOpHandle writes the post_op_fh3 for node
+func (s *Server) writePostOpHandle(res *xdrWriter, node vfs.Node) {
+	res.bool(true)
+	res.opaque(s.handles.toHandle(node.Path()))
+}
+
+// node returns the node and its path for handle
+func (s *Server) node(handle []byte) (node vfs.Node, status uint32) {
+	if len(handle) != handleSize {
+		return nil, nfsErrBadHandle
+	}
+	nodePath, ok := s.handles.toPath(handle)
+	if !ok {
+		return nil, nfsErrStale
+	}
+	node, err := s.vfs.Stat(nodePath)
+	if err == vfs.ENOENT {
+		return nil, nfsErrStale
+	} else if err != nil {
+		return nil, nfsStatus(err)
+	}
+	return node, nfsOK
+}
+
+// dirNode returns the directory for handle
+func (s *Server) dirNode(handle []byte) (dir *vfs.Dir, status uint32) {
+	node, status := s.node(handle)
+	if status != nfsOK {
+		return nil, status
+	}
+	dir, ok := node.(*vfs.Dir)
+	if !ok {
+		return nil, nfsErrNotDir
+	}
+	return dir, nfsOK
+}
+
+// fileNode returns the file for handle
+func (s *Server) fileNode(handle []byte) (file *vfs.File, status uint32) {
+	node, status := s.node(handle)
+	if status != nfsOK {
+		return nil, status
+	}
+	file, ok := node.(*vfs.File)
+	if !ok {
+		return nil, nfsErrIsDir
+	}
+	return file, nfsOK
+}
+
+// checkName checks name is a valid name for a file in a directory
+func checkName(name string) uint32 {
+	switch {
+	case len(name) > maxNameLen:
+		return nfsErrNameTooLong
+	case name == "" || name == "." || name == ".." || strings.Contains(name, "/"):
+		return nfsErrInval
+	}
+	return nfsOK
+}
+
+// statOrNil returns the node for nodePath or nil if not found
+func (s *Server) statOrNil(nodePath string) vfs.Node {
+	node, err := s.vfs.Stat(nodePath)
+	if err != nil {
+		return nil
+	}
+	return node
+}
+
+// sattr is the decoded sattr3
+type sattr struct {
+	setSize  bool
+	size     uint64
+	mtimeHow uint32
+	mtime    time.Time
+}
+
+// readSattr reads an sattr3 - the mode, uid, gid and atime are
+// ignored as the VFS can't set them
+func readSattr(args *xdrReader) (attr sattr) {
+	if args.bool() {
+		_ = args.uint32() // mode
+	}
+	if args.bool() {
+		_ = args.uint32() // uid
+	}
+	if args.bool() {
+		_ = args.uint32() // gid
+	}
+	attr.setSize = args.bool()
+	if attr.setSize {
+		attr.size = args.uint64()
+	}
+	if args.uint32() == nfsSetToClientTime {
+		_ = readTime(args) // atime
+	}
+	attr.mtimeHow = args.uint32()
+	if attr.mtimeHow == nfsSetToClientTime {
+		attr.mtime = readTime(args)
+	}
+	return attr
+}
+
+// setAttr applies attr to node
+func (s *Server) setAttr(node vfs.Node, attr sattr) error {
+	if attr.setSize {
+		file, ok := node.(*vfs.File)
+		if !ok {
+			return vfs.EINVAL
+		}
+		err := s.truncate(file, int64(attr.size))
+		if err != nil {
+			return err
+		}
+	}
+	switch attr.mtimeHow {
+	case nfsSetToServerTime:
+		return node.SetModTime(time.Now())
+	case nfsSetToClientTime:
+		return node.SetModTime(attr.mtime)
+	}
+	return nil
+}
+
+// truncate sets the size of file
+//
+// If the file is being written it is truncated through the open
+// handle.  A truncate to 0 opens the file for write so the writes
+// which usually follow can use it.
+func (s *Server) truncate(file *vfs.File, size int64) error {
+	if size == 0 || s.files.isOpen(file.Path()) {
+		return s.files.use(file.Path(), true, 0, func(handle vfs.Handle) error {
+			return handle.Truncate(size)
+		})
+	}
+	return file.Truncate(size)
+}
+
+// nfsGetAttr returns the attributes of a file or directory
+func (s *Server) nfsGetAttr(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.node(handle)
+	res.uint32(status)
+	if status == nfsOK {
+		s.writeAttr(res, node)
+	}
+	return nil
+}
+
+// nfsSetAttr sets the size and modification time of a file or
+// directory
+func (s *Server) nfsSetAttr(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	attr := readSattr(args)
+	if args.bool() {
+		_ = readTime(args) // guard ctime which isn't checked
+	}
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.node(handle)
+	if status == nfsOK {
+		status = nfsStatus(s.setAttr(node, attr))
+	}
+	res.uint32(status)
+	s.writeWcc(res, node)
+	return nil
+}
+
+// nfsLookup looks up a name in a directory
+func (s *Server) nfsLookup(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	name := args.string(maxPathLen)
+	if args.err != nil {
+		return args.err
+	}
+	dir, status := s.dirNode(handle)
+	var node vfs.Node
+	if status == nfsOK {
+		switch name {
+		case ".":
+			node = dir
+		case "..":
+			node, status = s.statOrNil(path.Dir(dir.Path())), nfsOK
+			if dir.Path() == "" {
+				node = dir
+			}
+		default:
+			if status = checkName(name); status == nfsOK {
+				var err error
+				node, err = dir.Stat(name)
+				status = nfsStatus(err)
+			}
+		}
+		if node == nil && status == nfsOK {
+			status = nfsErrNoEnt
+		}
+	}
+	res.uint32(status)
+	if status == nfsOK {
+		res.opaque(s.handles.toHandle(node.Path()))
+		s.writePostOpAttr(res, node)
+	}
+	s.writePostOpAttr(res, vfsNode(dir))
+	return nil
+}
+
+// vfsNode returns dir as a vfs.Node which is nil if dir is nil
+func vfsNode(dir *vfs.Dir) vfs.Node {
+	if dir == nil {
+		return nil
+	}
+	return dir
+}
+
+// nfsAccess returns which access is allowed which is everything
+// unless the VFS is read only
+func (s *Server) nfsAccess(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	access := args.uint32()
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.node(handle)
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	if status == nfsOK {
+		if s.vfs.Opt.ReadOnly {
+			access &= nfsAccessRead | nfsAccessLookup | nfsAccessExecute
+		}
+		res.uint32(access)
+	}
+	return nil
+}
+
+// nfsReadLink reads a symlink which the VFS doesn't have
+func (s *Server) nfsReadLink(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.node(handle)
+	if status == nfsOK {
+		status = nfsErrInval
+	}
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	return nil
+}
+
+// nfsRead reads data from a file
+func (s *Server) nfsRead(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	offset := args.uint64()
+	count := args.uint32()
+	if args.err != nil {
+		return args.err
+	}
+	if count > maxIOSize {
+		count = maxIOSize
+	}
+	file, status := s.fileNode(handle)
+	var (
+		buf = make([]byte, count)
+		n   int
+		eof bool
+	)
+	if status == nfsOK {
+		err := s.files.use(file.Path(), false, 0, func(h vfs.Handle) (err error) {
+			n, err = h.ReadAt(buf, int64(offset))
+			return err
+		})
+		if err == io.EOF {
+			eof, err = true, nil
+		}
+		if err != nil {
+			fs.Errorf(file, "NFS: read failed: %v", err)
+		}
+		status = nfsStatus(err)
+		if size := file.Size(); size >= 0 && int64(offset)+int64(n) >= size {
+			eof = true
+		}
+	}
+	res.uint32(status)
+	s.writePostOpAttr(res, vfsFile(file))
+	if status == nfsOK {
+		res.uint32(uint32(n))
+		res.bool(eof)
+		res.opaque(buf[:n])
+	}
+	return nil
+}
+
+// vfsFile returns file as a vfs.Node which is nil if file is nil
+func vfsFile(file *vfs.File) vfs.Node {
+	if file == nil {
+		return nil
+	}
+	return file
+}
+
+// nfsWrite writes data to a file
+//
+// Writes are left in the open file until a COMMIT or until it is
+// closed for being idle, which uploads it.  Stable writes are synced
+// to the file but it isn't closed as the client may carry on writing
+// to it.
+func (s *Server) nfsWrite(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	offset := args.uint64()
+	_ = args.uint32() // count which is the same as len(data)
+	stable := args.uint32()
+	data := args.opaque(maxIOSize)
+	if args.err != nil {
+		return args.err
+	}
+	file, status := s.fileNode(handle)
+	var n int
+	if status == nfsOK {
+		err := s.files.use(file.Path(), true, 0, func(h vfs.Handle) (err error) {
+			n, err = h.WriteAt(data, int64(offset))
+			if err == nil && stable != nfsUnstable {
+				err = h.Sync()
+			}
+			return err
+		})
+		if err != nil {
+			fs.Errorf(file, "NFS: write failed: %v", err)
+		}
+		status = nfsStatus(err)
+	}
+	res.uint32(status)
+	s.writeWcc(res, vfsFile(file))
+	if status == nfsOK {
+		res.uint32(uint32(n))
+		if stable == nfsUnstable {
+			res.uint32(nfsUnstable)
+		} else {
+			res.uint32(nfsFileSync)
+		}
+		res.fixedOpaque(s.verifier[:])
+	}
+	return nil
+}
+
+// nfsCreate creates a file
+func (s *Server) nfsCreate(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	name := args.string(maxPathLen)
+	how := args.uint32()
+	var attr sattr
+	if how == nfsCreateExclusive {
+		_ = args.fixedOpaque(8) // verifier which isn't stored
+	} else {
+		attr = readSattr(args)
+	}
+	if args.err != nil {
+		return args.err
+	}
+	dir, status := s.dirNode(handle)
+	if status == nfsOK {
+		status = checkName(name)
+	}
+	var node vfs.Node
+	if status == nfsOK {
+		filePath := path.Join(dir.Path(), name)
+		existing, err := dir.Stat(name)
+		switch {
+		case err == nil && how != nfsCreateUnchecked:
+			status = nfsErrExist
+		case err == nil && existing.IsDir():
+			status = nfsErrIsDir
+		case err == nil:
+			// An unchecked create of an existing file just sets
+			// the attributes
+			node = existing
+			status = nfsStatus(s.setAttr(node, attr))
+		case err == vfs.ENOENT:
+			// Create the file leaving it open for the writes.  The
+			// empty write makes the file appear in the directory.
+			err = s.files.use(filePath, true, os.O_CREATE|os.O_TRUNC, func(h vfs.Handle) error {
+				node = h.Node()
+				_, err := h.WriteAt(nil, 0)
+				return err
+			})
+			if err == nil && attr.mtimeHow != nfsDontChange {
+				err = s.setAttr(node, sattr{mtimeHow: attr.mtimeHow, mtime: attr.mtime})
+			}
+			status = nfsStatus(err)
+		default:
+			status = nfsStatus(err)
+		}
+	}
+	res.uint32(status)
+	if status == nfsOK {
+		s.writePostOpHandle(res, node)
+		s.writePostOpAttr(res, node)
+	}
+	s.writeWcc(res, vfsNode(dir))
+	return nil
+}
+
+// nfsMkdir makes a directory
+func (s *Server) nfsMkdir(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	name := args.string(maxPathLen)
+	_ = readSattr(args)
+	if args.err != nil {
+		return args.err
+	}
+	dir, status := s.dirNode(handle)
+	if status == nfsOK {
+		status = checkName(name)
+	}
+	var node vfs.Node
+	if status == nfsOK {
+		if _, err := dir.Stat(name); err == nil {
+			status = nfsErrExist
+		} else {
+			var newDir *vfs.Dir
+			newDir, err = dir.Mkdir(name)
+			status = nfsStatus(err)
+			node = vfsNode(newDir)
+		}
+	}
+	res.uint32(status)
+	if status == nfsOK {
+		s.writePostOpHandle(res, node)
+		s.writePostOpAttr(res, node)
+	}
+	s.writeWcc(res, vfsNode(dir))
+	return nil
+}
+
+// nfsNotSuppWcc is for the procedures which aren't supported and
+// return wcc_data on failure (SYMLINK and MKNOD)
+func (s *Server) nfsNotSuppWcc(args *xdrReader, res *xdrWriter) error {
+	res.uint32(nfsErrNotSupp)
+	s.writeWcc(res, nil)
+	return nil
+}
+
+// nfsLink makes hard links which aren't supported
+func (s *Server) nfsLink(args *xdrReader, res *xdrWriter) error {
+	res.uint32(nfsErrNotSupp)
+	s.writePostOpAttr(res, nil)
+	s.writeWcc(res, nil)
+	return nil
+}
+
+// remove removes name from the directory with handle which must be a
+// directory if isDir is set or a file otherwise
+func (s *Server) remove(args *xdrReader, res *xdrWriter, isDir bool) error {
+	handle := args.opaque(maxHandleSize)
+	name := args.string(maxPathLen)
+	if args.err != nil {
+		return args.err
+	}
+	dir, status := s.dirNode(handle)
+	if status == nfsOK {
+		status = checkName(name)
+	}
+	if status == nfsOK {
+		node, err := dir.Stat(name)
+		switch {
+		case err != nil:
+			status = nfsStatus(err)
+		case isDir && !node.IsDir():
+			status = nfsErrNotDir
+		case !isDir && node.IsDir():
+			status = nfsErrIsDir
+		default:
+			_ = s.files.close(node.Path())
+			status = nfsStatus(node.Remove())
+			if status == nfsOK {
+				s.handles.forget(node.Path())
+			}
+		}
+	}
+	res.uint32(status)
+	s.writeWcc(res, vfsNode(dir))
+	return nil
+}
+
+// nfsRemove removes a file
+func (s *Server) nfsRemove(args *xdrReader, res *xdrWriter) error {
+	return s.remove(args, res, false)
+}
+
+// nfsRmdir removes an empty directory
+func (s *Server) nfsRmdir(args *xdrReader, res *xdrWriter) error {
+	return s.remove(args, res, true)
+}
+
+// nfsRename renames a file or directory
+func (s *Server) nfsRename(args *xdrReader, res *xdrWriter) error {
+	fromHandle := args.opaque(maxHandleSize)
+	fromName := args.string(maxPathLen)
+	toHandle := args.opaque(maxHandleSize)
+	toName := args.string(maxPathLen)
+	if args.err != nil {
+		return args.err
+	}
+	fromDir, status := s.dirNode(fromHandle)
+	toDir, toStatus := s.dirNode(toHandle)
+	if status == nfsOK {
+		status = toStatus
+	}
+	if status == nfsOK {
+		status = checkName(fromName)
+	}
+	if status == nfsOK {
+		status = checkName(toName)
+	}
+	if status == nfsOK {
+		fromPath := path.Join(fromDir.Path(), fromName)
+		toPath := path.Join(toDir.Path(), toName)
+		// Finish any writes before renaming
+		_ = s.files.close(fromPath)
+		_ = s.files.close(toPath)
+		status = nfsStatus(s.vfs.Rename(fromPath, toPath))
+		if status == nfsOK {
+			s.handles.rename(fromPath, toPath)
+		}
+	}
+	res.uint32(status)
+	s.writeWcc(res, vfsNode(fromDir))
+	s.writeWcc(res, vfsNode(toDir))
+	return nil
+}
+
+// readDir reads the directory for READDIR and READDIRPLUS calling fn
+// for each entry after cookie while it returns true.  It returns
+// whether the end of the directory was reached.
+func (s *Server) readDir(dir *vfs.Dir, cookie uint64, fn func(node vfs.Node, cookie uint64) bool) (eof bool, err error) {
+	nodes, err := dir.ReadDirAll()
+	if err != nil {
+		return false, err
+	}
+	for i := cookie; i < uint64(len(nodes)); i++ {
+		if !fn(nodes[i], i+1) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// readDir lists a directory for READDIR and READDIRPLUS adding the
+// attributes and handles of the entries if plus is set
+func (s *Server) listDir(args *xdrReader, res *xdrWriter, plus bool) error {
+	handle := args.opaque(maxHandleSize)
+	cookie := args.uint64()
+	_ = args.fixedOpaque(8) // cookie verifier which isn't checked
+	count := args.uint32()
+	if plus {
+		// use maxcount rather than dircount as the limit
+		count = args.uint32()
+	}
+	if args.err != nil {
+		return args.err
+	}
+	dir, status := s.dirNode(handle)
+	var (
+		entries = &xdrWriter{}
+		eof     bool
+		n       int
+	)
+	if status == nfsOK {
+		// size of the reply without the entries
+		size := 4 + 4 + attrSize + 8 + 4 + 4
+		var err error
+		eof, err = s.readDir(dir, cookie, func(node vfs.Node, cookie uint64) bool {
+			name := node.Name()
+			entrySize := 4 + 8 + 4 + len(name) + pad(len(name)) + 8
+			if plus {
+				entrySize += 4 + attrSize + 4 + 4 + handleSize
+			}
+			if size+entrySize > int(count) {
+				return false
+			}
+			size += entrySize
+			n++
+			entries.bool(true)
+			entries.uint64(fileID(s.handles.toHandle(node.Path())))
+			entries.string(name)
+			entries.uint64(cookie)
+			if plus {
+				s.writePostOpAttr(entries, node)
+				s.writePostOpHandle(entries, node)
+			}
+			return true
+		})
+		status = nfsStatus(err)
+		if status == nfsOK && n == 0 && !eof {
+			status = nfsErrTooSmall
+		}
+	}
+	res.uint32(status)
+	s.writePostOpAttr(res, vfsNode(dir))
+	if status == nfsOK {
+		res.fixedOpaque(make([]byte, 8)) // cookie verifier
+		res.buf = append(res.buf, entries.buf...)
+		res.bool(false) // no more entries
+		res.bool(eof)
+	}
+	return nil
+}
+
+// nfsReadDir lists a directory
+func (s *Server) nfsReadDir(args *xdrReader, res *xdrWriter) error {
+	return s.listDir(args, res, false)
+}
+
+// nfsReadDirPlus lists a directory with the attributes and handles of
+// the entries
+func (s *Server) nfsReadDirPlus(args *xdrReader, res *xdrWriter) error {
+	return s.listDir(args, res, true)
+}
+
+// nfsFSStat returns the usage of the remote if known
+func (s *Server) nfsFSStat(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.node(handle)
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	if status == nfsOK {
+		const unknown = 1 << 50
+		total, used, free := s.vfs.Statfs()
+		if total < 0 {
+			total = unknown
+		}
+		if free < 0 {
+			if used >= 0 && used <= total {
+				free = total - used
+			} else {
+				free = unknown
+			}
+		}
+		res.uint64(uint64(total)) // tbytes
+		res.uint64(uint64(free))  // fbytes
+		res.uint64(uint64(free))  // abytes
+		res.uint64(1e9)           // tfiles
+		res.uint64(1e9)           // ffiles
+		res.uint64(1e9)           // afiles
+		res.uint32(0)             // invarsec
+	}
+	return nil
+}
+
+// nfsFSInfo returns the properties of the file system
+func (s *Server) nfsFSInfo(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.node(handle)
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	if status == nfsOK {
+		res.uint32(maxIOSize)  // rtmax
+		res.uint32(maxIOSize)  // rtpref
+		res.uint32(ioMultiple) // rtmult
+		res.uint32(maxIOSize)  // wtmax
+		res.uint32(maxIOSize)  // wtpref
+		res.uint32(ioMultiple) // wtmult
+		res.uint32(64 * 1024)  // dtpref
+		res.uint64(1 << 62)    // maxfilesize
+		res.uint32(0)          // time_delta - 1ms
+		res.uint32(1000000)
+		res.uint32(nfsFSFHomogeneous | nfsFSFCanSetTime)
+	}
+	return nil
+}
+
+// nfsPathConf returns the POSIX properties of the file system
+func (s *Server) nfsPathConf(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.node(handle)
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	if status == nfsOK {
+		res.uint32(1)          // linkmax
+		res.uint32(maxNameLen) // name_max
+		res.bool(true)         // no_trunc
+		res.bool(true)         // chown_restricted
+		res.bool(false)        // case_insensitive
+		res.bool(true)         // case_preserving
+	}
+	return nil
+}
+
+// nfsCommit finishes the writes to a file by closing it which uploads
+// it
+func (s *Server) nfsCommit(args *xdrReader, res *xdrWriter) error {
+	handle := args.opaque(maxHandleSize)
+	_ = args.uint64() // offset
+	_ = args.uint32() // count
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.node(handle)
+	if status == nfsOK {
+		status = nfsStatus(s.files.close(node.Path()))
+	}
+	res.uint32(status)
+	s.writeWcc(res, node)
+	if status == nfsOK {
+		res.fixedOpaque(s.verifier[:])
+	}
+	return nil
+}
@@ -0,0 +1,496 @@
+package nfs
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient makes RPC calls to the server under test
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	xid  uint32
+}
+
+// call calls procedure proc of program prog with args returning a
+// reader for the results
+func (c *testClient) call(prog, vers, proc uint32, args func(w *xdrWriter)) *xdrReader {
+	c.xid++
+	w := &xdrWriter{}
+	w.uint32(c.xid)
+	w.uint32(rpcCall)
+	w.uint32(rpcVersion)
+	w.uint32(prog)
+	w.uint32(vers)
+	w.uint32(proc)
+	w.uint32(authNone)
+	w.opaque(nil)
+	w.uint32(authNone)
+	w.opaque(nil)
+	if args != nil {
+		args(w)
+	}
+	require.NoError(c.t, writeRecord(c.conn, w.buf))
+	record, err := readRecord(c.conn)
+	require.NoError(c.t, err)
+	r := newXDRReader(record)
+	assert.Equal(c.t, c.xid, r.uint32())
+	assert.Equal(c.t, uint32(rpcReply), r.uint32())
+	assert.Equal(c.t, uint32(rpcMsgAccepted), r.uint32())
+	_ = r.uint32()
+	_ = r.opaque(maxAuthSize)
+	require.Equal(c.t, uint32(rpcSuccess), r.uint32())
+	return r
+}
+
+// nfs calls NFS procedure proc checking it returns status
+func (c *testClient) nfs(proc uint32, status uint32, args func(w *xdrWriter)) *xdrReader {
+	r := c.call(nfsProgram, nfsVersion, proc, args)
+	require.Equal(c.t, status, r.uint32(), "nfs procedure %d", proc)
+	return r
+}
+
+// readAttr reads a fattr3 returning the type and the size
+func readAttr(r *xdrReader) (ftype uint32, size uint64) {
+	ftype = r.uint32()
+	_ = r.fixedOpaque(4 * 4) // mode, nlink, uid, gid
+	size = r.uint64()
+	_ = r.fixedOpaque(attrSize - 4 - 4*4 - 8)
+	return ftype, size
+}
+
+// skipPostOpAttr skips a post_op_attr
+func skipPostOpAttr(r *xdrReader) {
+	if r.bool() {
+		_ = r.fixedOpaque(attrSize)
+	}
+}
+
+// skipWcc skips a wcc_data
+func skipWcc(r *xdrReader) {
+	if r.bool() {
+		_ = r.fixedOpaque(8 + 8 + 8)
+	}
+	skipPostOpAttr(r)
+}
+
+// newTestServer starts a server on a temporary local remote.  The
+// handles are saved in cacheDir.
+func newTestServer(t *testing.T, dir, cacheDir string) (*Server, *testClient) {
+	oldCacheDir := config.CacheDir
+	config.CacheDir = cacheDir
+	defer func() { config.CacheDir = oldCacheDir }()
+	f, err := fs.NewFs(dir)
+	require.NoError(t, err)
+	opt := DefaultOpt
+	opt.ListenAddr = "localhost:0"
+	s, err := newServer(f, &opt)
+	require.NoError(t, err)
+	require.NoError(t, s.Serve())
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	return s, &testClient{t: t, conn: conn}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-nfs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	remoteDir := filepath.Join(dir, "remote")
+	cacheDir := filepath.Join(dir, "cache")
+	require.NoError(t, os.Mkdir(remoteDir, 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(remoteDir, "existing.txt"), []byte("hello"), 0666))
+
+	s, c := newTestServer(t, remoteDir, cacheDir)
+
+	// NULL
+	c.call(nfsProgram, nfsVersion, nfsProcNull, nil)
+
+	// MNT the root
+	r := c.call(mountProgram, mountVersion, mountProcMnt, func(w *xdrWriter) {
+		w.string("/")
+	})
+	require.Equal(t, uint32(mountOK), r.uint32())
+	root := r.opaque(maxHandleSize)
+	require.NoError(t, r.err)
+	assert.Equal(t, handleSize, len(root))
+
+	// MNT of a file fails
+	r = c.call(mountProgram, mountVersion, mountProcMnt, func(w *xdrWriter) {
+		w.string("/existing.txt")
+	})
+	assert.Equal(t, uint32(mountErrNotDir), r.uint32())
+
+	// GETATTR of the root
+	r = c.nfs(nfsProcGetAttr, nfsOK, func(w *xdrWriter) {
+		w.opaque(root)
+	})
+	ftype, _ := readAttr(r)
+	assert.Equal(t, uint32(nfsTypeDir), ftype)
+
+	// GETATTR of a bad handle
+	c.nfs(nfsProcGetAttr, nfsErrBadHandle, func(w *xdrWriter) {
+		w.opaque([]byte("bad"))
+	})
+	c.nfs(nfsProcGetAttr, nfsErrStale, func(w *xdrWriter) {
+		w.opaque(make([]byte, handleSize))
+	})
+
+	// LOOKUP the existing file
+	lookup := func(name string, status uint32) []byte {
+		r := c.nfs(nfsProcLookup, status, func(w *xdrWriter) {
+			w.opaque(root)
+			w.string(name)
+		})
+		if status != nfsOK {
+			return nil
+		}
+		handle := r.opaque(maxHandleSize)
+		require.NoError(t, r.err)
+		return handle
+	}
+	existing := lookup("existing.txt", nfsOK)
+	lookup("missing.txt", nfsErrNoEnt)
+	assert.Equal(t, root, lookup(".", nfsOK))
+	assert.Equal(t, root, lookup("..", nfsOK))
+
+	// READ the existing file
+	read := func(handle []byte) string {
+		r := c.nfs(nfsProcRead, nfsOK, func(w *xdrWriter) {
+			w.opaque(handle)
+			w.uint64(0)
+			w.uint32(1024)
+		})
+		skipPostOpAttr(r)
+		n := r.uint32()
+		assert.True(t, r.bool(), "eof")
+		data := r.opaque(maxIOSize)
+		require.NoError(t, r.err)
+		assert.Equal(t, int(n), len(data))
+		return string(data)
+	}
+	assert.Equal(t, "hello", read(existing))
+
+	// CREATE a new file
+	r = c.nfs(nfsProcCreate, nfsOK, func(w *xdrWriter) {
+		w.opaque(root)
+		w.string("new.txt")
+		w.uint32(nfsCreateGuarded)
+		w.fixedOpaque(make([]byte, 6*4)) // empty sattr3
+	})
+	require.True(t, r.bool())
+	newFile := r.opaque(maxHandleSize)
+	require.NoError(t, r.err)
+	assert.Equal(t, newFile, lookup("new.txt", nfsOK))
+
+	// GUARDED CREATE of an existing file fails
+	c.nfs(nfsProcCreate, nfsErrExist, func(w *xdrWriter) {
+		w.opaque(root)
+		w.string("existing.txt")
+		w.uint32(nfsCreateGuarded)
+		w.fixedOpaque(make([]byte, 6*4))
+	})
+
+	// WRITE to it unstably then COMMIT
+	write := func(offset uint64, data string) {
+		r := c.nfs(nfsProcWrite, nfsOK, func(w *xdrWriter) {
+			w.opaque(newFile)
+			w.uint64(offset)
+			w.uint32(uint32(len(data)))
+			w.uint32(nfsUnstable)
+			w.opaque([]byte(data))
+		})
+		skipWcc(r)
+		assert.Equal(t, uint32(len(data)), r.uint32())
+		assert.Equal(t, uint32(nfsUnstable), r.uint32())
+		assert.Equal(t, s.verifier[:], r.fixedOpaque(8))
+	}
+	write(0, "potato ")
+	write(7, "sausage")
+	r = c.nfs(nfsProcCommit, nfsOK, func(w *xdrWriter) {
+		w.opaque(newFile)
+		w.uint64(0)
+		w.uint32(0)
+	})
+	skipWcc(r)
+	assert.Equal(t, s.verifier[:], r.fixedOpaque(8))
+	data, err := ioutil.ReadFile(filepath.Join(remoteDir, "new.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "potato sausage", string(data))
+	assert.Equal(t, "potato sausage", read(newFile))
+
+	// GETATTR of the new file
+	r = c.nfs(nfsProcGetAttr, nfsOK, func(w *xdrWriter) {
+		w.opaque(newFile)
+	})
+	ftype, size := readAttr(r)
+	assert.Equal(t, uint32(nfsTypeReg), ftype)
+	assert.Equal(t, uint64(14), size)
+
+	// MKDIR
+	r = c.nfs(nfsProcMkdir, nfsOK, func(w *xdrWriter) {
+		w.opaque(root)
+		w.string("dir")
+		w.fixedOpaque(make([]byte, 6*4))
+	})
+	require.True(t, r.bool())
+	subDir := r.opaque(maxHandleSize)
+	require.NoError(t, r.err)
+
+	// RENAME the new file into it
+	c.nfs(nfsProcRename, nfsOK, func(w *xdrWriter) {
+		w.opaque(root)
+		w.string("new.txt")
+		w.opaque(subDir)
+		w.string("renamed.txt")
+	})
+	_, err = os.Stat(filepath.Join(remoteDir, "dir", "renamed.txt"))
+	require.NoError(t, err)
+
+	// The handle still works after the rename
+	assert.Equal(t, "potato sausage", read(newFile))
+
+	// READDIR of the root
+	r = c.nfs(nfsProcReadDir, nfsOK, func(w *xdrWriter) {
+		w.opaque(root)
+		w.uint64(0)
+		w.fixedOpaque(make([]byte, 8))
+		w.uint32(4096)
+	})
+	skipPostOpAttr(r)
+	_ = r.fixedOpaque(8)
+	var names []string
+	for r.bool() {
+		_ = r.uint64()
+		names = append(names, r.string(maxNameLen))
+		_ = r.uint64()
+	}
+	assert.True(t, r.bool(), "eof")
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"dir", "existing.txt"}, names)
+
+	// REMOVE the existing file
+	r = c.nfs(nfsProcRemove, nfsOK, func(w *xdrWriter) {
+		w.opaque(root)
+		w.string("existing.txt")
+	})
+	_, err = os.Stat(filepath.Join(remoteDir, "existing.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// RMDIR of a non empty directory fails
+	c.nfs(nfsProcRmdir, nfsErrNotEmpty, func(w *xdrWriter) {
+		w.opaque(root)
+		w.string("dir")
+	})
+
+	// Restart the server and check the handles still work
+	require.NoError(t, c.conn.Close())
+	require.NoError(t, s.Close())
+	s, c = newTestServer(t, remoteDir, cacheDir)
+	defer func() {
+		_ = c.conn.Close()
+		_ = s.Close()
+	}()
+	r = c.nfs(nfsProcGetAttr, nfsOK, func(w *xdrWriter) {
+		w.opaque(subDir)
+	})
+	ftype, _ = readAttr(r)
+	assert.Equal(t, uint32(nfsTypeDir), ftype)
+}
+
+func TestServerReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-nfs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	vfsflags.Opt.ReadOnly = true
+	defer func() { vfsflags.Opt.ReadOnly = false }()
+	s, c := newTestServer(t, dir, filepath.Join(dir, "cache"))
+	defer func() {
+		_ = c.conn.Close()
+		_ = s.Close()
+	}()
+	root := s.handles.toHandle("")
+
+	// ACCESS doesn't allow modifications
+	r := c.nfs(nfsProcAccess, nfsOK, func(w *xdrWriter) {
+		w.opaque(root)
+		w.uint32(nfsAccessRead | nfsAccessModify | nfsAccessLookup)
+	})
+	skipPostOpAttr(r)
+	assert.Equal(t, uint32(nfsAccessRead|nfsAccessLookup), r.uint32())
+
+	// MKDIR fails
+	c.nfs(nfsProcMkdir, nfsErrROFS, func(w *xdrWriter) {
+		w.opaque(root)
+		w.string("dir")
+		w.fixedOpaque(make([]byte, 6*4))
+	})
+}
+
+func TestHandles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-nfs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "sub", "test.handles")
+
+	h, err := newHandles(file)
+	require.NoError(t, err)
+	handle := h.toHandle("dir/file with \"quotes\"\n.txt")
+	assert.Equal(t, handleSize, len(handle))
+	assert.Equal(t, handle, h.toHandle("dir/file with \"quotes\"\n.txt"))
+	assert.NotEqual(t, handle, h.toHandle("dir"))
+	require.NoError(t, h.close())
+
+	h, err = newHandles(file)
+	require.NoError(t, err)
+	defer func() { _ = h.close() }()
+	path, ok := h.toPath(handle)
+	assert.True(t, ok)
+	assert.Equal(t, "dir/file with \"quotes\"\n.txt", path)
+	_, ok = h.toPath(make([]byte, handleSize))
+	assert.False(t, ok)
+
+	// Renaming keeps the handles of the directory and its contents
+	dirHandle := h.toHandle("dir")
+	h.rename("dir", "moved")
+	path, ok = h.toPath(handle)
+	assert.True(t, ok)
+	assert.Equal(t, "moved/file with \"quotes\"\n.txt", path)
+	assert.Equal(t, dirHandle, h.toHandle("moved"))
+
+	// A new file with the old name gets a different handle
+	newDirHandle := h.toHandle("dir")
+	assert.NotEqual(t, dirHandle, newDirHandle)
+	path, ok = h.toPath(newDirHandle)
+	assert.True(t, ok)
+	assert.Equal(t, "dir", path)
+
+	// Removing forgets the handles
+	h.forget("moved")
+	_, ok = h.toPath(handle)
+	assert.False(t, ok)
+	_, ok = h.toPath(dirHandle)
+	assert.False(t, ok)
+	require.NoError(t, h.close())
+
+	// Which is remembered when reloaded
+	h, err = newHandles(file)
+	require.NoError(t, err)
+	_, ok = h.toPath(dirHandle)
+	assert.False(t, ok)
+	path, ok = h.toPath(newDirHandle)
+	assert.True(t, ok)
+	assert.Equal(t, "dir", path)
+	assert.Equal(t, newDirHandle, h.toHandle("dir"))
+}
+
+func TestHandlesCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-nfs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "test.handles")
+
+	h, err := newHandles(file)
+	require.NoError(t, err)
+	keep := h.toHandle("keep")
+	for i := 0; i < 2*compactLines; i++ {
+		h.toHandle("file")
+		h.forget("file")
+	}
+	require.NoError(t, h.close())
+
+	h, err = newHandles(file)
+	require.NoError(t, err)
+	defer func() { _ = h.close() }()
+	assert.Equal(t, 1, h.lines)
+	path, ok := h.toPath(keep)
+	assert.True(t, ok)
+	assert.Equal(t, "keep", path)
+
+	// New handles are added after compacting
+	other := h.toHandle("other")
+	require.NoError(t, h.close())
+	h, err = newHandles(file)
+	require.NoError(t, err)
+	path, ok = h.toPath(other)
+	assert.True(t, ok)
+	assert.Equal(t, "other", path)
+}
+
+func TestOpenFilesCloseWaits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-nfs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	f, err := fs.NewFs(dir)
+	require.NoError(t, err)
+	o := newOpenFiles(vfs.New(f, nil), time.Hour)
+	defer o.closeAll()
+
+	using := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- o.use("file.txt", true, os.O_CREATE, func(h vfs.Handle) error {
+			close(using)
+			<-finish
+			_, err := h.WriteAt([]byte("hello"), 0)
+			return err
+		})
+	}()
+	<-using
+	closed := make(chan error)
+	go func() {
+		closed <- o.close("file.txt")
+	}()
+	select {
+	case <-closed:
+		t.Fatal("closed while in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(finish)
+	require.NoError(t, <-done)
+	require.NoError(t, <-closed)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestXDR(t *testing.T) {
+	w := &xdrWriter{}
+	w.uint32(1)
+	w.uint64(2)
+	w.bool(true)
+	w.string("hello")
+	w.opaque([]byte{1, 2, 3, 4})
+	assert.Equal(t, 4+8+4+4+8+4+4, len(w.buf))
+
+	r := newXDRReader(w.buf)
+	assert.Equal(t, uint32(1), r.uint32())
+	assert.Equal(t, uint64(2), r.uint64())
+	assert.Equal(t, true, r.bool())
+	assert.Equal(t, "hello", r.string(10))
+	assert.Equal(t, []byte{1, 2, 3, 4}, r.opaque(10))
+	require.NoError(t, r.err)
+
+	// reading past the end sets the error
+	assert.Equal(t, uint32(0), r.uint32())
+	assert.Equal(t, errXDRShort, r.err)
+
+	// strings longer than the maximum are an error
+	r = newXDRReader(w.buf[4+8+4:])
+	assert.Equal(t, "", r.string(4))
+	assert.Error(t, r.err)
+}
@@ -0,0 +1,231 @@
+// ONC RPC over TCP as described in RFC 5531
+
+package nfs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// RPC constants
+const (
+	rpcVersion = 2
+
+	// msg_type
+	rpcCall  = 0
+	rpcReply = 1
+
+	// reply_stat
+	rpcMsgAccepted = 0
+	rpcMsgDenied   = 1
+
+	// accept_stat
+	rpcSuccess      = 0
+	rpcProgUnavail  = 1
+	rpcProgMismatch = 2
+	rpcProcUnavail  = 3
+	rpcGarbageArgs  = 4
+
+	// reject_stat
+	rpcMismatch = 0
+
+	// auth_flavor
+	authNone = 0
+	authUnix = 1
+
+	maxAuthSize   = 400             // maximum size of the auth body
+	maxRecordSize = 4 * 1024 * 1024 // maximum size of a call we'll accept
+)
+
+// rpcProc is the implementation of an RPC procedure.  It decodes its
+// arguments from args and encodes its results into res.  It should
+// return an error without writing anything if the arguments can't be
+// decoded.
+type rpcProc func(args *xdrReader, res *xdrWriter) error
+
+// rpcProgram is an RPC program with a single version
+type rpcProgram struct {
+	version uint32
+	procs   map[uint32]rpcProc
+}
+
+// rpcServer serves RPC programs over TCP
+type rpcServer struct {
+	programs map[uint32]*rpcProgram
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// newRPCServer makes a new rpcServer
+func newRPCServer() *rpcServer {
+	return &rpcServer{
+		programs: make(map[uint32]*rpcProgram),
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// register adds the program prog with version to the server
+func (s *rpcServer) register(prog, version uint32, procs map[uint32]rpcProc) {
+	s.programs[prog] = &rpcProgram{version: version, procs: procs}
+}
+
+// serve accepts connections on ln until it is closed
+func (s *rpcServer) serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// closeConns closes all the open connections
+func (s *rpcServer) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// serveConn reads calls from conn and writes the replies.  The calls
+// are run one at a time so the writes to a file arrive in the order
+// the client sent them.
+func (s *rpcServer) serveConn(conn net.Conn) {
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	fs.Debugf(nil, "NFS connection from %v", conn.RemoteAddr())
+	in := bufio.NewReader(conn)
+	for {
+		record, err := readRecord(in)
+		if err != nil {
+			if err != io.EOF {
+				fs.Debugf(nil, "NFS connection from %v: read failed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		reply := s.call(record)
+		if reply == nil {
+			continue
+		}
+		err = writeRecord(conn, reply)
+		if err != nil {
+			fs.Debugf(nil, "NFS connection from %v: write failed: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// readRecord reads a record made of one or more fragments each
+// starting with a 4 byte header of the length with the top bit set on
+// the last fragment.
+func readRecord(in io.Reader) (record []byte, err error) {
+	for {
+		var header [4]byte
+		_, err = io.ReadFull(in, header[:])
+		if err != nil {
+			return nil, err
+		}
+		h := binary.BigEndian.Uint32(header[:])
+		n := int(h &^ (1 << 31))
+		if len(record)+n > maxRecordSize {
+			return nil, errors.Errorf("record too big (%d bytes)", len(record)+n)
+		}
+		start := len(record)
+		record = append(record, make([]byte, n)...)
+		_, err = io.ReadFull(in, record[start:])
+		if err != nil {
+			return nil, err
+		}
+		if h&(1<<31) != 0 {
+			return record, nil
+		}
+	}
+}
+
+// writeRecord writes record as a single fragment
+func writeRecord(out io.Writer, record []byte) error {
+	buf := make([]byte, 4, 4+len(record))
+	binary.BigEndian.PutUint32(buf, 1<<31|uint32(len(record)))
+	_, err := out.Write(append(buf, record...))
+	return err
+}
+
+// call runs the RPC call in record returning the reply, or nil if the
+// record couldn't be decoded
+func (s *rpcServer) call(record []byte) []byte {
+	args := newXDRReader(record)
+	xid := args.uint32()
+	msgType := args.uint32()
+	rpcvers := args.uint32()
+	prog := args.uint32()
+	vers := args.uint32()
+	proc := args.uint32()
+	// The credentials and verifier are ignored
+	_ = args.uint32()
+	_ = args.opaque(maxAuthSize)
+	_ = args.uint32()
+	_ = args.opaque(maxAuthSize)
+	if args.err != nil || msgType != rpcCall {
+		fs.Debugf(nil, "NFS: ignoring bad RPC call")
+		return nil
+	}
+	res := &xdrWriter{}
+	res.uint32(xid)
+	res.uint32(rpcReply)
+	if rpcvers != rpcVersion {
+		res.uint32(rpcMsgDenied)
+		res.uint32(rpcMismatch)
+		res.uint32(rpcVersion)
+		res.uint32(rpcVersion)
+		return res.buf
+	}
+	res.uint32(rpcMsgAccepted)
+	res.uint32(authNone)
+	res.opaque(nil)
+	program := s.programs[prog]
+	if program == nil {
+		res.uint32(rpcProgUnavail)
+		return res.buf
+	}
+	if vers != program.version {
+		res.uint32(rpcProgMismatch)
+		res.uint32(program.version)
+		res.uint32(program.version)
+		return res.buf
+	}
+	fn := program.procs[proc]
+	if fn == nil {
+		res.uint32(rpcProcUnavail)
+		return res.buf
+	}
+	start := len(res.buf)
+	res.uint32(rpcSuccess)
+	err := fn(args, res)
+	if err != nil {
+		fs.Debugf(nil, "NFS: bad arguments to program %d procedure %d: %v", prog, proc, err)
+		res.buf = res.buf[:start]
+		res.uint32(rpcGarbageArgs)
+	}
+	return res.buf
+}
+
+// nullProc is the NULL procedure every program has
+func nullProc(args *xdrReader, res *xdrWriter) error {
+	return nil
+}
@@ -0,0 +1,135 @@
+// XDR encoding and decoding as described in RFC 4506
+
+package nfs
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// errXDRShort is returned when decoding runs out of data
+var errXDRShort = errors.New("xdr: not enough data")
+
+// xdrReader decodes XDR from a buffer
+//
+// The first error is remembered and all reads after it return zero
+// values so the caller only needs to check err once at the end.
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+// newXDRReader makes a reader for buf
+func newXDRReader(buf []byte) *xdrReader {
+	return &xdrReader{buf: buf}
+}
+
+// next returns the next n bytes or nil if there aren't enough
+func (r *xdrReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = errXDRShort
+		return nil
+	}
+	p := r.buf[:n]
+	r.buf = r.buf[n:]
+	return p
+}
+
+// uint32 reads an unsigned int
+func (r *xdrReader) uint32() uint32 {
+	p := r.next(4)
+	if p == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(p)
+}
+
+// uint64 reads an unsigned hyper
+func (r *xdrReader) uint64() uint64 {
+	p := r.next(8)
+	if p == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(p)
+}
+
+// bool reads a bool
+func (r *xdrReader) bool() bool {
+	return r.uint32() != 0
+}
+
+// fixedOpaque reads fixed length opaque data of n bytes
+func (r *xdrReader) fixedOpaque(n int) []byte {
+	p := r.next(n)
+	r.next(pad(n))
+	return p
+}
+
+// opaque reads variable length opaque data of at most max bytes
+func (r *xdrReader) opaque(max int) []byte {
+	n := r.uint32()
+	if r.err == nil && n > uint32(max) {
+		r.err = errors.Errorf("xdr: opaque length %d more than %d", n, max)
+	}
+	return r.fixedOpaque(int(n))
+}
+
+// string reads a string of at most max bytes
+func (r *xdrReader) string(max int) string {
+	return string(r.opaque(max))
+}
+
+// xdrWriter encodes XDR into a buffer
+type xdrWriter struct {
+	buf []byte
+}
+
+// uint32 writes an unsigned int
+func (w *xdrWriter) uint32(x uint32) {
+	var p [4]byte
+	binary.BigEndian.PutUint32(p[:], x)
+	w.buf = append(w.buf, p[:]...)
+}
+
+// uint64 writes an unsigned hyper
+func (w *xdrWriter) uint64(x uint64) {
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], x)
+	w.buf = append(w.buf, p[:]...)
+}
+
+// bool writes a bool
+func (w *xdrWriter) bool(x bool) {
+	if x {
+		w.uint32(1)
+	} else {
+		w.uint32(0)
+	}
+}
+
+// fixedOpaque writes fixed length opaque data
+func (w *xdrWriter) fixedOpaque(p []byte) {
+	w.buf = append(w.buf, p...)
+	w.buf = append(w.buf, make([]byte, pad(len(p)))...)
+}
+
+// opaque writes variable length opaque data
+func (w *xdrWriter) opaque(p []byte) {
+	w.uint32(uint32(len(p)))
+	w.fixedOpaque(p)
+}
+
+// string writes a string
+func (w *xdrWriter) string(s string) {
+	w.opaque([]byte(s))
+}
+
+// pad returns the number of bytes needed to pad n bytes to a multiple
+// of 4
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
@@ -4,6 +4,7 @@ package accounting
 import (
 	"fmt"
 	"io"
+	"path"
 	"sync"
 	"time"
 
@@ -14,6 +15,10 @@ import (
 	"github.com/pkg/errors"
 )
 
+// statsNameWidth is the width of the column file names are shown in
+// by the stats
+const statsNameWidth = 45
+
 // ErrorMaxTransferLimitReached is returned from Read when the max
 // transfer limit is reached.
 var ErrorMaxTransferLimitReached = fserrors.FatalError(errors.New("Max transfer limit reached as set by --max-transfer"))
@@ -31,6 +36,7 @@ type Account struct {
 	close   io.Closer
 	size    int64
 	name    string
+	path    string             // remote and full path of the file if known
 	statmu  sync.Mutex         // Separate mutex for stat values.
 	bytes   int64              // Total number of bytes read
 	max     int64              // if >=0 the max number of bytes to transfer
@@ -41,6 +47,7 @@ type Account struct {
 	closed  bool               // set if the file is closed
 	exit    chan struct{}      // channel that will be closed when transfer is finished
 	withBuf bool               // is using a buffered in
+	bwTag   int64              // virtual time the bandwidth limiter has served this up to
 }
 
 // NewAccountSizeName makes a Account reader for an io.ReadCloser of
@@ -64,7 +71,11 @@ func NewAccountSizeName(in io.ReadCloser, size int64, name string) *Account {
 
 // NewAccount makes a Account reader for an object
 func NewAccount(in io.ReadCloser, obj fs.Object) *Account {
-	return NewAccountSizeName(in, obj.Size(), obj.Remote())
+	acc := NewAccountSizeName(in, obj.Size(), obj.Remote())
+	if f := obj.Fs(); f != nil {
+		acc.path = f.Name() + ":" + path.Join(f.Root(), obj.Remote())
+	}
+	return acc
 }
 
 // WithBuffer - If the file is above a certain size it adds an Async reader
@@ -137,21 +148,24 @@ func (acc *Account) averageLoop() {
 	}
 }
 
-// read bytes from the io.Reader passed in and account them
-func (acc *Account) read(in io.Reader, p []byte) (n int, err error) {
+// checkRead checks the transfer limits before a read and sets the
+// start time
+func (acc *Account) checkRead() error {
 	acc.statmu.Lock()
-	if acc.max >= 0 && Stats.GetBytes() >= acc.max {
-		acc.statmu.Unlock()
-		return 0, ErrorMaxTransferLimitReached
+	defer acc.statmu.Unlock()
+	if acc.max >= 0 && fs.Config.CutoffMode == fs.CutoffModeHard && Stats.GetBytes() >= acc.max {
+		return ErrorMaxTransferLimitReached
 	}
 	// Set start time.
 	if acc.start.IsZero() {
 		acc.start = time.Now()
 	}
-	acc.statmu.Unlock()
-
-	n, err = in.Read(p)
+	return nil
+}
 
+// accountRead updates the stats and limits the bandwidth for n bytes
+// read
+func (acc *Account) accountRead(n int) {
 	// Update Stats
 	acc.statmu.Lock()
 	acc.lpBytes += n
@@ -160,10 +174,54 @@ func (acc *Account) read(in io.Reader, p []byte) (n int, err error) {
 
 	Stats.Bytes(int64(n))
 
-	limitBandwidth(n)
+	limitBandwidth(acc, n)
+}
+
+// read bytes from the io.Reader passed in and account them
+func (acc *Account) read(in io.Reader, p []byte) (n int, err error) {
+	err = acc.checkRead()
+	if err != nil {
+		return 0, err
+	}
+	n, err = in.Read(p)
+	acc.accountRead(n)
 	return
 }
 
+// AccountRead accounts for n bytes having been read by something
+// other than Read, for example by several readers in parallel.
+//
+// It obeys the transfer limits and bandwidth limit in the same way
+// as Read does.
+func (acc *Account) AccountRead(n int) error {
+	err := acc.checkRead()
+	if err != nil {
+		return err
+	}
+	acc.accountRead(n)
+	return nil
+}
+
+// Rollback removes n bytes which have been accounted from the stats,
+// for example because they were sent in a request which failed and
+// will be sent again.
+//
+// The bandwidth limit isn't given back as the bytes were sent.
+func (acc *Account) Rollback(n int64) {
+	acc.statmu.Lock()
+	if n > acc.bytes {
+		n = acc.bytes
+	}
+	acc.bytes -= n
+	acc.lpBytes -= int(n)
+	if acc.lpBytes < 0 {
+		acc.lpBytes = 0
+	}
+	acc.statmu.Unlock()
+
+	Stats.Bytes(-n)
+}
+
 // Read bytes from the object - see io.Reader
 func (acc *Account) Read(p []byte) (n int, err error) {
 	acc.mu.Lock()
@@ -184,9 +242,9 @@ func (acc *Account) Close() error {
 	return acc.close.Close()
 }
 
-// progress returns bytes read as well as the size.
+// Progress returns bytes read as well as the size.
 // Size can be <= 0 if the size is unknown.
-func (acc *Account) progress() (bytes, size int64) {
+func (acc *Account) Progress() (bytes, size int64) {
 	if acc == nil {
 		return 0, 0
 	}
@@ -242,7 +300,7 @@ func (acc *Account) eta() (eta time.Duration, ok bool) {
 
 // String produces stats for this file
 func (acc *Account) String() string {
-	a, b := acc.progress()
+	a, b := acc.Progress()
 	_, cur := acc.speed()
 	eta, etaok := acc.eta()
 	etas := "-"
@@ -254,6 +312,9 @@ func (acc *Account) String() string {
 		}
 	}
 	name := []rune(acc.name)
+	if fs.Config.StatsFullPath && acc.path != "" {
+		name = []rune(acc.path)
+	}
 	if fs.Config.StatsFileNameLength > 0 {
 		if len(name) > fs.Config.StatsFileNameLength {
 			where := len(name) - fs.Config.StatsFileNameLength
@@ -272,12 +333,17 @@ func (acc *Account) String() string {
 
 	done := fmt.Sprintf("%2d%% /%s", percentageDone, fs.SizeSuffix(b))
 
-	return fmt.Sprintf("%45s: %s, %s/s, %s",
-		string(name),
+	stats := fmt.Sprintf("%s, %s/s, %s",
 		done,
 		fs.SizeSuffix(cur),
 		etas,
 	)
+	// Put names too long for the column on a line of their own so
+	// the stats of all the files still line up
+	if len(name) > statsNameWidth {
+		return fmt.Sprintf("%s:\n%*s  %s", string(name), statsNameWidth, "", stats)
+	}
+	return fmt.Sprintf("%*s: %s", statsNameWidth, string(name), stats)
 }
 
 // OldStream returns the top io.Reader
@@ -329,6 +395,17 @@ func (a *accountStream) Read(p []byte) (n int, err error) {
 	return a.acc.read(a.in, p)
 }
 
+// Rollback removes n bytes from the accounting of the parent
+func (a *accountStream) Rollback(n int64) {
+	a.acc.Rollback(n)
+}
+
+// Rollbacker is an optional interface for an Accounter which can
+// remove bytes from the accounting again
+type Rollbacker interface {
+	Rollback(n int64)
+}
+
 // Accounter accounts a stream allowing the accounting to be removed and re-added
 type Accounter interface {
 	io.Reader
@@ -21,6 +21,8 @@ var (
 	_ io.Reader     = &accountStream{}
 	_ Accounter     = &Account{}
 	_ Accounter     = &accountStream{}
+	_ Rollbacker    = &Account{}
+	_ Rollbacker    = &accountStream{}
 )
 
 func TestNewAccountSizeName(t *testing.T) {
@@ -108,6 +110,34 @@ func TestAccountRead(t *testing.T) {
 	assert.NoError(t, acc.Close())
 }
 
+func TestAccountRollback(t *testing.T) {
+	Stats.ResetCounters()
+	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
+	acc := NewAccountSizeName(in, 3, "test")
+
+	var buf = make([]byte, 3)
+	n, err := acc.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, int64(3), acc.bytes)
+	assert.Equal(t, int64(3), Stats.bytes)
+
+	// Rollback on the accountStream rolls back the parent
+	r := acc.WrapStream(in)
+	r.(Rollbacker).Rollback(2)
+	assert.Equal(t, int64(1), acc.bytes)
+	assert.Equal(t, 1, acc.lpBytes)
+	assert.Equal(t, int64(1), Stats.bytes)
+
+	// Can't roll back more than has been read
+	acc.Rollback(2)
+	assert.Equal(t, int64(0), acc.bytes)
+	assert.Equal(t, 0, acc.lpBytes)
+	assert.Equal(t, int64(0), Stats.bytes)
+
+	assert.NoError(t, acc.Close())
+}
+
 func TestAccountString(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
 	acc := NewAccountSizeName(in, 3, "test")
@@ -126,6 +156,30 @@ func TestAccountString(t *testing.T) {
 	assert.NoError(t, acc.Close())
 }
 
+func TestAccountStringNames(t *testing.T) {
+	oldLength, oldFullPath := fs.Config.StatsFileNameLength, fs.Config.StatsFullPath
+	defer func() {
+		fs.Config.StatsFileNameLength, fs.Config.StatsFullPath = oldLength, oldFullPath
+	}()
+	long := strings.Repeat("dir/", 12) + "file"
+	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
+	acc := NewAccountSizeName(in, 3, long)
+	acc.path = "remote:root/" + long
+
+	fs.Config.StatsFileNameLength = 40
+	fs.Config.StatsFullPath = false
+	assert.Equal(t, "..."+long[12:]+":  0% /3, 0/s, -", strings.TrimSpace(acc.String()))
+
+	// Names too long for the column go on their own line
+	fs.Config.StatsFileNameLength = 0
+	assert.Equal(t, long+":\n"+strings.Repeat(" ", 47)+" 0% /3, 0/s, -", acc.String())
+
+	fs.Config.StatsFullPath = true
+	assert.Equal(t, "remote:root/"+long+":\n"+strings.Repeat(" ", 47)+" 0% /3, 0/s, -", acc.String())
+
+	assert.NoError(t, acc.Close())
+}
+
 // Test the Accounter interface methods on Account and accountStream
 func TestAccountAccounter(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
@@ -208,3 +262,27 @@ func TestAccountMaxTransfer(t *testing.T) {
 	assert.Equal(t, ErrorMaxTransferLimitReached, err)
 	assert.True(t, fserrors.IsFatalError(err))
 }
+
+func TestAccountMaxTransferSoft(t *testing.T) {
+	old := fs.Config.MaxTransfer
+	oldMode := fs.Config.CutoffMode
+	fs.Config.MaxTransfer = 15
+	fs.Config.CutoffMode = fs.CutoffModeSoft
+	defer func() {
+		fs.Config.MaxTransfer = old
+		fs.Config.CutoffMode = oldMode
+	}()
+	Stats.ResetCounters()
+
+	in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 100)))
+	acc := NewAccountSizeName(in, 1, "test")
+
+	var b = make([]byte, 10)
+
+	for i := 0; i < 3; i++ {
+		n, err := acc.Read(b)
+		assert.Equal(t, 10, n)
+		assert.NoError(t, err)
+	}
+	assert.True(t, Stats.MaxTransferReached())
+}
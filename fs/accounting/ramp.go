@@ -0,0 +1,169 @@
+package accounting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// Ramp limits the number of concurrent transfers for
+// --transfers-ramp.  It starts at 1 transfer and doubles the limit
+// every --transfers-ramp up to --transfers, halving it again whenever
+// the pacer reports sustained retries.
+type Ramp struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	max      int           // ceiling - the --transfers
+	limit    int           // current number of transfers allowed
+	active   int           // current number of transfers running
+	interval time.Duration // how often to double limit
+	changed  time.Time     // when limit was last changed
+	stop     chan struct{} // close to stop the ticker
+}
+
+// Globals
+var (
+	rampMu      sync.Mutex // protects currentRamp
+	currentRamp *Ramp      // the Ramp in use or nil
+)
+
+// NewRamp makes a new Ramp for up to max concurrent transfers if
+// --transfers-ramp is set, otherwise it returns nil.
+//
+// The Ramp is used for the stats and pacer back off until Stop is
+// called.
+func NewRamp(max int) *Ramp {
+	if fs.Config.TransfersRamp <= 0 || max <= 1 {
+		return nil
+	}
+	r := &Ramp{
+		max:      max,
+		limit:    1,
+		interval: fs.Config.TransfersRamp,
+		changed:  time.Now(),
+		stop:     make(chan struct{}),
+	}
+	r.cond = sync.NewCond(&r.mu)
+	go r.ticker()
+	rampMu.Lock()
+	currentRamp = r
+	rampMu.Unlock()
+	fs.Infof(nil, "Starting transfers at 1 doubling every %v up to %d", r.interval, r.max)
+	return r
+}
+
+// ticker doubles the limit every interval until stopped
+func (r *Ramp) ticker() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.grow(now)
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// grow doubles the limit if it hasn't been changed for interval
+func (r *Ramp) grow(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limit >= r.max || now.Sub(r.changed) < r.interval {
+		return
+	}
+	r.limit *= 2
+	if r.limit > r.max {
+		r.limit = r.max
+	}
+	r.changed = now
+	fs.Debugf(nil, "Increasing concurrent transfers to %d", r.limit)
+	r.cond.Broadcast()
+}
+
+// Backoff halves the limit.  It is called when the pacer sees
+// sustained retries.
+func (r *Ramp) Backoff() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limit <= 1 {
+		return
+	}
+	r.limit /= 2
+	r.changed = time.Now()
+	fs.Debugf(nil, "Decreasing concurrent transfers to %d after retries", r.limit)
+}
+
+// Wait waits until a transfer is allowed to start.  Done must be
+// called when the transfer has finished if it returns no error.
+func (r *Ramp) Wait(ctx context.Context) error {
+	// wake up the waiters if ctx is cancelled
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.mu.Lock()
+			r.cond.Broadcast()
+			r.mu.Unlock()
+		case <-stop:
+		}
+	}()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.active >= r.limit {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		r.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	r.active++
+	return nil
+}
+
+// Done marks a transfer started with Wait as finished
+func (r *Ramp) Done() {
+	r.mu.Lock()
+	r.active--
+	r.cond.Broadcast()
+	r.mu.Unlock()
+}
+
+// Concurrency returns the number of transfers currently allowed and
+// the maximum it will go up to
+func (r *Ramp) Concurrency() (limit, max int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.limit, r.max
+}
+
+// Stop stops the ramp
+func (r *Ramp) Stop() {
+	close(r.stop)
+	rampMu.Lock()
+	if currentRamp == r {
+		currentRamp = nil
+	}
+	rampMu.Unlock()
+}
+
+// getRamp returns the Ramp in use or nil
+func getRamp() *Ramp {
+	rampMu.Lock()
+	defer rampMu.Unlock()
+	return currentRamp
+}
+
+// RampBackoff halves the number of concurrent transfers if
+// --transfers-ramp is in use.
+func RampBackoff() {
+	if r := getRamp(); r != nil {
+		r.Backoff()
+	}
+}
@@ -0,0 +1,69 @@
+package accounting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRamp(t *testing.T) {
+	oldTransfersRamp := fs.Config.TransfersRamp
+	defer func() {
+		fs.Config.TransfersRamp = oldTransfersRamp
+	}()
+
+	fs.Config.TransfersRamp = 0
+	assert.Nil(t, NewRamp(8))
+
+	fs.Config.TransfersRamp = time.Hour
+	r := NewRamp(8)
+	require.NotNil(t, r)
+	assert.Equal(t, r, getRamp())
+	limit, max := r.Concurrency()
+	assert.Equal(t, 1, limit)
+	assert.Equal(t, 8, max)
+
+	// Only one transfer is allowed to start with
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	require.NoError(t, r.Wait(ctx))
+	assert.Equal(t, context.DeadlineExceeded, r.Wait(ctx))
+	cancel()
+
+	// Doubles every interval up to the max
+	now := r.changed
+	r.grow(now.Add(time.Minute))
+	limit, _ = r.Concurrency()
+	assert.Equal(t, 1, limit)
+	for _, want := range []int{2, 4, 8, 8} {
+		now = now.Add(time.Hour)
+		r.grow(now)
+		limit, _ = r.Concurrency()
+		assert.Equal(t, want, limit)
+	}
+
+	// Waiting transfers start when a transfer finishes
+	for i := 1; i < 8; i++ {
+		require.NoError(t, r.Wait(context.Background()))
+	}
+	done := make(chan error)
+	go func() {
+		done <- r.Wait(context.Background())
+	}()
+	r.Done()
+	require.NoError(t, <-done)
+
+	// Backs off when the pacer says
+	RampBackoff()
+	limit, _ = r.Concurrency()
+	assert.Equal(t, 4, limit)
+
+	r.Stop()
+	assert.Nil(t, getRamp())
+	RampBackoff()
+	limit, _ = r.Concurrency()
+	assert.Equal(t, 4, limit)
+}
@@ -31,8 +31,14 @@ type StatsInfo struct {
 	transfers    int64
 	transferring *stringSet
 	deletes      int64
+	deletedBytes int64
 	start        time.Time
 	inProgress   *inProgress
+	queued       int64     // bytes queued for transfer but not started
+	sampleTime   time.Time // time of the last speed sample for the ETA
+	sampleBytes  int64     // bytes at sampleTime
+	etaSpeed     float64   // moving average of the speed for the ETA
+	etaSamples   int       // number of speed samples taken
 }
 
 // NewStats cretates an initialised StatsInfo
@@ -45,11 +51,131 @@ func NewStats() *StatsInfo {
 	}
 }
 
+// sampleSpeed takes a sample of the transfer speed at now and returns
+// the speed to use for the ETA and whether it is known.
+//
+// If --stats-eta-smoothing is set this is an exponential moving
+// average of the speed between samples, otherwise it is the average
+// speed since the start.  A stalled transfer gives samples of 0 so
+// the speed decays and the ETA grows.  Samples less than a second
+// apart are ignored as they are too noisy.
+func (s *StatsInfo) sampleSpeed(now time.Time) (speed float64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alpha := fs.Config.StatsETASmoothing
+	if alpha <= 0 || alpha > 1 {
+		dt := now.Sub(s.start)
+		if dt <= 0 || s.bytes == 0 {
+			return 0, false
+		}
+		return float64(s.bytes) / dt.Seconds(), true
+	}
+	if s.sampleTime.IsZero() {
+		s.sampleTime = s.start
+	}
+	dt := now.Sub(s.sampleTime)
+	if dt >= time.Second {
+		sample := float64(s.bytes-s.sampleBytes) / dt.Seconds()
+		if sample < 0 {
+			// bytes were rolled back
+			sample = 0
+		}
+		if s.etaSamples == 0 {
+			s.etaSpeed = sample
+		} else {
+			s.etaSpeed = alpha*sample + (1-alpha)*s.etaSpeed
+		}
+		s.etaSamples++
+		s.sampleTime = now
+		s.sampleBytes = s.bytes
+	}
+	if s.etaSamples == 0 || s.bytes == 0 {
+		return 0, false
+	}
+	return s.etaSpeed, true
+}
+
+// eta returns the estimated time to finish the transfers in progress
+// and those queued at speed bytes/s and whether it is known
+func (s *StatsInfo) eta(speed float64) (eta time.Duration, ok bool) {
+	if speed <= 0 {
+		return 0, false
+	}
+	s.mu.RLock()
+	remaining := s.queued
+	s.mu.RUnlock()
+	s.inProgress.mu.Lock()
+	defer s.inProgress.mu.Unlock()
+	for _, acc := range s.inProgress.m {
+		bytes, size := acc.Progress()
+		if size < 0 {
+			return 0, false
+		}
+		if size > bytes {
+			remaining += size - bytes
+		}
+	}
+	eta = time.Duration(float64(remaining)/speed) * time.Second
+	return eta - eta%time.Second, true
+}
+
+// etaString returns the ETA at speed bytes/s as a string, or "--" if
+// it isn't known
+func (s *StatsInfo) etaString(speed float64, ok bool) string {
+	if !ok {
+		return "--"
+	}
+	eta, ok := s.eta(speed)
+	if !ok {
+		return "--"
+	}
+	return eta.String()
+}
+
+// oneLineDate returns the stats at now as a single line for parsing.
+//
+// This is the date then key=value fields.  The fields are always
+// printed in this order and any new ones will be added on the end.
+func (s *StatsInfo) oneLineDate(now time.Time, speed float64, etaString string) string {
+	s.mu.RLock()
+	dt := now.Sub(s.start)
+	bytes, errors, checks, transfers := s.bytes, s.errors, s.checks, s.transfers
+	s.mu.RUnlock()
+	if fs.Config.DataRateUnit == "bits" {
+		speed = speed * 8
+	}
+	return fmt.Sprintf("%s transferred=%v speed=%v eta=%s errors=%d checks=%d transfers=%d elapsed=%v",
+		now.UTC().Format(time.RFC3339),
+		fs.SizeSuffix(bytes),
+		fs.SizeSuffix(speed),
+		etaString,
+		errors,
+		checks,
+		transfers,
+		dt-dt%time.Second)
+}
+
+// speed returns the average transfer speed in bytes/s at now
+func (s *StatsInfo) speed(now time.Time) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	dt := now.Sub(s.start)
+	if dt <= 0 {
+		return 0
+	}
+	return float64(s.bytes) / dt.Seconds()
+}
+
 // String convert the StatsInfo to a string for printing
 func (s *StatsInfo) String() string {
+	now := time.Now()
+	etaString := s.etaString(s.sampleSpeed(now))
+	if fs.Config.StatsOneLineDate {
+		return s.oneLineDate(now, s.speed(now), etaString)
+	}
 	s.mu.RLock()
 
-	dt := time.Now().Sub(s.start)
+	dt := now.Sub(s.start)
 	dtSeconds := dt.Seconds()
 	speed := 0.0
 	if dt > 0 {
@@ -62,23 +188,40 @@ func (s *StatsInfo) String() string {
 		speed = speed * 8
 	}
 
+	if fs.Config.StatsOneLine {
+		defer s.mu.RUnlock()
+		return fmt.Sprintf("Transferred: %s (%s), Errors: %d, Checks: %d, Transferred: %d, Elapsed time: %v, ETA: %s",
+			fs.SizeSuffix(s.bytes).Unit("Bytes"), fs.SizeSuffix(speed).Unit(strings.Title(fs.Config.DataRateUnit)+"/s"),
+			s.errors,
+			s.checks,
+			s.transfers,
+			dtRounded,
+			etaString)
+	}
+
 	_, _ = fmt.Fprintf(buf, `
 Transferred:   %10s (%s)
 Errors:        %10d
 Checks:        %10d
 Transferred:   %10d
 Elapsed time:  %10v
+ETA:           %10s
 `,
 		fs.SizeSuffix(s.bytes).Unit("Bytes"), fs.SizeSuffix(speed).Unit(strings.Title(fs.Config.DataRateUnit)+"/s"),
 		s.errors,
 		s.checks,
 		s.transfers,
-		dtRounded)
+		dtRounded,
+		etaString)
 
 	// checking and transferring have their own locking so unlock
 	// here to prevent deadlock on GetBytes
 	s.mu.RUnlock()
 
+	if r := getRamp(); r != nil {
+		limit, max := r.Concurrency()
+		_, _ = fmt.Fprintf(buf, "Concurrency:   %10s\n", fmt.Sprintf("%d/%d", limit, max))
+	}
 	if !s.checking.empty() {
 		_, _ = fmt.Fprintf(buf, "Checking:\n%s\n", s.checking)
 	}
@@ -107,6 +250,12 @@ func (s *StatsInfo) GetBytes() int64 {
 	return s.bytes
 }
 
+// MaxTransferReached returns true if --max-transfer is set and the
+// number of bytes transferred so far has reached it
+func (s *StatsInfo) MaxTransferReached() bool {
+	return fs.Config.MaxTransfer >= 0 && s.GetBytes() >= int64(fs.Config.MaxTransfer)
+}
+
 // Errors updates the stats for errors
 func (s *StatsInfo) Errors(errors int64) {
 	s.mu.Lock()
@@ -121,6 +270,13 @@ func (s *StatsInfo) GetErrors() int64 {
 	return s.errors
 }
 
+// GetChecks returns the number of checks done so far
+func (s *StatsInfo) GetChecks() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checks
+}
+
 // GetLastError returns the lastError
 func (s *StatsInfo) GetLastError() error {
 	s.mu.RLock()
@@ -136,6 +292,14 @@ func (s *StatsInfo) Deletes(deletes int64) int64 {
 	return s.deletes
 }
 
+// DeletedBytes updates the stats for the size of deleted files
+func (s *StatsInfo) DeletedBytes(bytes int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deletedBytes += bytes
+	return s.deletedBytes
+}
+
 // ResetCounters sets the counters (bytes, checks, errors, transfers) to 0
 func (s *StatsInfo) ResetCounters() {
 	s.mu.RLock()
@@ -145,6 +309,12 @@ func (s *StatsInfo) ResetCounters() {
 	s.checks = 0
 	s.transfers = 0
 	s.deletes = 0
+	s.deletedBytes = 0
+	s.queued = 0
+	s.sampleTime = time.Time{}
+	s.sampleBytes = 0
+	s.etaSpeed = 0
+	s.etaSamples = 0
 }
 
 // ResetErrors sets the errors count to 0
@@ -189,6 +359,14 @@ func (s *StatsInfo) GetTransfers() int64 {
 	return s.transfers
 }
 
+// Queued adds size bytes to the bytes waiting to be transferred for
+// the ETA.  Call it with a negative size when the transfer starts.
+func (s *StatsInfo) Queued(size int64) {
+	s.mu.Lock()
+	s.queued += size
+	s.mu.Unlock()
+}
+
 // Transferring adds a transfer into the stats
 func (s *StatsInfo) Transferring(remote string) {
 	s.transferring.add(remote)
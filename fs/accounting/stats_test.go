@@ -0,0 +1,91 @@
+package accounting
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsOneLineDate(t *testing.T) {
+	s := NewStats()
+	s.start = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := s.start.Add(90 * time.Second)
+	s.Bytes(300 * 1024 * 1024)
+	s.Errors(2)
+	s.DoneChecking("a")
+	s.DoneTransferring("a", true)
+
+	// Nothing in progress so there is nothing left to transfer
+	assert.Equal(t, "2018-01-01T00:01:30Z transferred=300M speed=10M eta=0s errors=2 checks=1 transfers=1 elapsed=1m30s", s.oneLineDate(now, 10*1024*1024, s.etaString(10*1024*1024, true)))
+
+	// A transfer in progress gives an ETA
+	acc := NewAccountSizeName(ioutil.NopCloser(strings.NewReader("")), 100*1024*1024, "file")
+	s.inProgress.set("file", acc)
+	assert.Equal(t, "2018-01-01T00:01:30Z transferred=300M speed=10M eta=10s errors=2 checks=1 transfers=1 elapsed=1m30s", s.oneLineDate(now, 10*1024*1024, s.etaString(10*1024*1024, true)))
+
+	// No speed means no ETA
+	assert.Equal(t, "2018-01-01T00:01:30Z transferred=300M speed=0 eta=-- errors=2 checks=1 transfers=1 elapsed=1m30s", s.oneLineDate(now, 0, s.etaString(0, true)))
+	s.inProgress.clear("file")
+
+	fs.Config.StatsOneLineDate = true
+	defer func() { fs.Config.StatsOneLineDate = false }()
+	assert.NotContains(t, s.String(), "\n")
+}
+
+func TestStatsOneLine(t *testing.T) {
+	s := NewStats()
+	s.Bytes(1024)
+	fs.Config.StatsOneLine = true
+	defer func() { fs.Config.StatsOneLine = false }()
+	out := s.String()
+	assert.NotContains(t, out, "\n")
+	assert.Contains(t, out, "Transferred: 1 kBytes (")
+}
+
+func TestStatsETASmoothing(t *testing.T) {
+	oldSmoothing := fs.Config.StatsETASmoothing
+	fs.Config.StatsETASmoothing = 0.5
+	defer func() { fs.Config.StatsETASmoothing = oldSmoothing }()
+	s := NewStats()
+	start := s.start
+
+	// No samples yet
+	_, ok := s.sampleSpeed(start)
+	assert.False(t, ok)
+	assert.Equal(t, "--", s.etaString(s.sampleSpeed(start)))
+
+	// First sample sets the speed
+	s.Bytes(1000)
+	speed, ok := s.sampleSpeed(start.Add(time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 1000.0, speed)
+
+	// Samples less than a second apart are ignored
+	s.Bytes(2000)
+	speed, _ = s.sampleSpeed(start.Add(1500 * time.Millisecond))
+	assert.Equal(t, 1000.0, speed)
+
+	// Next sample is averaged in
+	speed, _ = s.sampleSpeed(start.Add(2 * time.Second))
+	assert.Equal(t, 1500.0, speed)
+
+	// The queued bytes count towards the ETA
+	s.Queued(3000)
+	assert.Equal(t, "2s", s.etaString(speed, true))
+
+	// A stall makes the speed decay so the ETA grows
+	speed, _ = s.sampleSpeed(start.Add(3 * time.Second))
+	assert.Equal(t, 750.0, speed)
+	assert.Equal(t, "4s", s.etaString(speed, true))
+	s.Queued(-3000)
+
+	// No smoothing uses the average speed
+	fs.Config.StatsETASmoothing = 0
+	speed, ok = s.sampleSpeed(start.Add(4 * time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 750.0, speed)
+}
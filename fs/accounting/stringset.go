@@ -44,7 +44,7 @@ func (ss *stringSet) empty() bool {
 func (ss *stringSet) Strings() []string {
 	ss.mu.RLock()
 	defer ss.mu.RUnlock()
-	strings := make([]string, 0, len(ss.items))
+	lines := make([]string, 0, len(ss.items))
 	for name := range ss.items {
 		var out string
 		if acc := Stats.inProgress.get(name); acc != nil {
@@ -52,9 +52,11 @@ func (ss *stringSet) Strings() []string {
 		} else {
 			out = name
 		}
-		strings = append(strings, " * "+out)
+		// indent any continuation lines to match the " * "
+		out = strings.Replace(out, "\n", "\n   ", -1)
+		lines = append(lines, " * "+out)
 	}
-	sorted := sort.StringSlice(strings)
+	sorted := sort.StringSlice(lines)
 	sorted.Sort()
 	return sorted
 }
@@ -1,6 +1,7 @@
 package accounting
 
 import (
+	"container/heap"
 	"context"
 	"sync"
 	"time"
@@ -99,20 +100,121 @@ func StartTokenTicker() {
 	}()
 }
 
-// limitBandwith sleeps for the correct amount of time for the passage
-// of n bytes according to the current bandwidth limit
-func limitBandwidth(n int) {
-	tokenBucketMu.Lock()
+// bandwidthQuantum is the most bytes a transfer can take from the
+// token bucket in one turn
+const bandwidthQuantum = 64 * 1024
 
-	// Limit the transfer speed if required
-	if tokenBucket != nil {
-		err := tokenBucket.WaitN(context.Background(), n)
-		if err != nil {
-			fs.Errorf(nil, "Token bucket error: %v", err)
-		}
+// bandwidthWaiter is a read waiting for its turn at the token bucket
+type bandwidthWaiter struct {
+	tag   int64         // virtual time of the start of the read
+	seq   int64         // order the read arrived in to break ties
+	ready chan struct{} // closed when it is this read's turn
+}
+
+// bandwidthWaiters is a priority queue of waiters with the lowest
+// tag first
+type bandwidthWaiters []*bandwidthWaiter
+
+func (ws bandwidthWaiters) Len() int { return len(ws) }
+func (ws bandwidthWaiters) Less(i, j int) bool {
+	if ws[i].tag != ws[j].tag {
+		return ws[i].tag < ws[j].tag
 	}
+	return ws[i].seq < ws[j].seq
+}
+func (ws bandwidthWaiters) Swap(i, j int)       { ws[i], ws[j] = ws[j], ws[i] }
+func (ws *bandwidthWaiters) Push(x interface{}) { *ws = append(*ws, x.(*bandwidthWaiter)) }
+func (ws *bandwidthWaiters) Pop() interface{} {
+	old := *ws
+	w := old[len(old)-1]
+	*ws = old[:len(old)-1]
+	return w
+}
 
+// fairQueue shares the token bucket fairly between the transfers
+// using it.
+//
+// Reads take turns at the token bucket in the order of the number of
+// bytes their transfer has been given (start time fair queuing), so
+// each transfer waiting gets the same share of the bandwidth however
+// big its reads are and however long the others have been running.
+type fairQueue struct {
+	mu      sync.Mutex
+	waiting bandwidthWaiters // reads waiting for their turn
+	busy    bool             // set if a read has the turn
+	virtual int64            // tag of the read with the turn
+	seq     int64            // number of reads queued
+}
+
+// bandwidthQueue is the fair queue for the token bucket
+var bandwidthQueue fairQueue
+
+// wait blocks until it is the turn of a read of n bytes by acc
+func (q *fairQueue) wait(acc *Account, n int) {
+	q.mu.Lock()
+	// A transfer which has been idle starts from now rather
+	// than using up the share it didn't take
+	tag := acc.bwTag
+	if tag < q.virtual {
+		tag = q.virtual
+	}
+	acc.bwTag = tag + int64(n)
+	if !q.busy {
+		q.busy = true
+		q.virtual = tag
+		q.mu.Unlock()
+		return
+	}
+	q.seq++
+	w := &bandwidthWaiter{tag: tag, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiting, w)
+	q.mu.Unlock()
+	<-w.ready
+}
+
+// done passes the turn to the next read waiting
+func (q *fairQueue) done() {
+	q.mu.Lock()
+	if len(q.waiting) == 0 {
+		q.busy = false
+	} else {
+		w := heap.Pop(&q.waiting).(*bandwidthWaiter)
+		q.virtual = w.tag
+		close(w.ready)
+	}
+	q.mu.Unlock()
+}
+
+// limitBandwith sleeps for the correct amount of time for the passage
+// of n bytes read by acc according to the current bandwidth limit.
+//
+// Reads of more than bandwidthQuantum bytes are split up so the reads
+// of other transfers are interleaved with them.
+func limitBandwidth(acc *Account, n int) {
+	tokenBucketMu.Lock()
+	limited := tokenBucket != nil
 	tokenBucketMu.Unlock()
+	if !limited {
+		return
+	}
+	for n > 0 {
+		chunk := n
+		if chunk > bandwidthQuantum {
+			chunk = bandwidthQuantum
+		}
+		n -= chunk
+		bandwidthQueue.wait(acc, chunk)
+		tokenBucketMu.Lock()
+		// Limit the transfer speed if required
+		if tokenBucket != nil {
+			err := tokenBucket.WaitN(context.Background(), chunk)
+			if err != nil {
+				fs.Errorf(nil, "Token bucket error: %v", err)
+			}
+		}
+		tokenBucketMu.Unlock()
+		bandwidthQueue.done()
+	}
 }
 
 // SetBwLimit sets the current bandwidth limit
@@ -0,0 +1,120 @@
+package accounting
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFairQueue(t *testing.T) {
+	var q fairQueue
+	greedy, a, b := &Account{}, &Account{}, &Account{}
+
+	// greedy takes the turn with a big read
+	q.wait(greedy, 1024*1024)
+	assert.True(t, q.busy)
+	assert.Equal(t, int64(1024*1024), greedy.bwTag)
+
+	// Queue up another read from greedy and reads from the
+	// transfers which haven't had any bandwidth yet
+	order := make(chan string, 3)
+	var wg sync.WaitGroup
+	for _, test := range []struct {
+		name string
+		acc  *Account
+	}{
+		{"greedy", greedy},
+		{"a", a},
+		{"b", b},
+	} {
+		wg.Add(1)
+		go func(name string, acc *Account) {
+			defer wg.Done()
+			q.wait(acc, 1024)
+			order <- name
+			q.done()
+		}(test.name, test.acc)
+	}
+	for {
+		q.mu.Lock()
+		n := len(q.waiting)
+		q.mu.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	q.done()
+	wg.Wait()
+	close(order)
+	var got []string
+	for name := range order {
+		got = append(got, name)
+	}
+	assert.ElementsMatch(t, []string{"a", "b"}, got[:2])
+	assert.Equal(t, "greedy", got[2])
+	assert.False(t, q.busy)
+
+	// A transfer which has been idle doesn't get a burst
+	idle := &Account{}
+	q.wait(idle, 1024)
+	assert.Equal(t, q.virtual+1024, idle.bwTag)
+	q.done()
+}
+
+// Check that many transfers sharing a tight bandwidth limit all make
+// progress even if one of them reads much more at a time
+func TestLimitBandwidthFair(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs for a second")
+	}
+	SetBwLimit(4 * 1024 * 1024)
+	defer SetBwLimit(0)
+
+	const transfers = 16
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		bytes = make([]int, transfers)
+		stop  = make(chan struct{})
+	)
+	for i := 0; i < transfers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			acc := &Account{}
+			readSize := 4 * 1024
+			if i == 0 {
+				readSize = 1024 * 1024
+			}
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				limitBandwidth(acc, readSize)
+				mu.Lock()
+				bytes[i] += readSize
+				mu.Unlock()
+			}
+		}(i)
+	}
+	time.Sleep(time.Second)
+	close(stop)
+	wg.Wait()
+
+	total := 0
+	for _, n := range bytes {
+		total += n
+	}
+	average := total / transfers
+	for i, n := range bytes {
+		what := fmt.Sprintf("transfer %d: %v", i, bytes)
+		assert.True(t, n >= average/4, what)
+		assert.True(t, n <= 2*average+1024*1024, what)
+	}
+}
@@ -32,8 +32,10 @@ type ConfigInfo struct {
 	LogLevel              LogLevel
 	StatsLogLevel         LogLevel
 	DryRun                bool
+	Interactive           bool
 	CheckSum              bool
 	SizeOnly              bool
+	SizeAndFreeHash       bool
 	IgnoreTimes           bool
 	IgnoreExisting        bool
 	IgnoreErrors          bool
@@ -42,11 +44,18 @@ type ConfigInfo struct {
 	Transfers             int
 	ConnectTimeout        time.Duration // Connect timeout
 	Timeout               time.Duration // Data channel timeout
+	ExpectContinueTimeout time.Duration // Time to wait for a 100-continue response, 0 to use ConnectTimeout
+	ReadIdleTimeout       time.Duration // Abort uploads which send no data for this long, 0 for off
 	Dump                  DumpFlags
-	InsecureSkipVerify    bool // Skip server certificate verification
+	Headers               []*HTTPOption // headers to add to all HTTP requests
+	UploadHeaders         []*HTTPOption // headers to add to HTTP requests which upload
+	DownloadHeaders       []*HTTPOption // headers to add to HTTP requests which download
+	InsecureSkipVerify    bool          // Skip server certificate verification
 	DeleteMode            DeleteMode
 	MaxDelete             int64
-	TrackRenames          bool // Track file renames.
+	MaxDeleteSize         SizeSuffix
+	TrackRenames          bool   // Track file renames.
+	TrackRenamesStrategy  string // Comma separated list of strategies used to track renames
 	LowLevelRetries       int
 	UpdateOlder           bool // Skip files that are newer on the destination
 	NoGzip                bool // Disable compression
@@ -68,10 +77,49 @@ type ConfigInfo struct {
 	Immutable             bool
 	AutoConfirm           bool
 	StreamingUploadCutoff SizeSuffix
+	UploadBufferPoolMax   SizeSuffix // max memory for the buffers of all the uploads, 0 for no limit
 	StatsFileNameLength   int
+	StatsFullPath         bool // show the remote and full path of transfers in the stats
 	AskPassword           bool
 	UseServerModTime      bool
 	MaxTransfer           SizeSuffix
+	CutoffMode            CutoffMode
+	BreakerThreshold      int           // consecutive failures to open the circuit breaker, 0 to disable
+	BreakerCooldown       time.Duration // how long the circuit breaker stays open
+	SuffixKeepExtension   bool          // add --suffix before the file extension
+	CompoundExtensions    []string      // extensions to treat as one with --suffix-keep-extension
+	MultiThreadCutoff     SizeSuffix    // use multi-thread downloads for files above this size
+	MultiThreadStreams    int           // number of streams to use for multi-thread downloads
+	Atomic                bool          // upload to a temporary name then rename
+	DeleteExcludedDryRun  bool          // list files --delete-excluded would delete and confirm first
+	MinFreeSpace          SizeSuffix    // free space to leave on the destination, -1 for no limit
+	ListCacheTime         time.Duration // how long to cache directory listings for, 0 to disable
+	Refresh               bool          // ignore cached listings and read them afresh
+	PartialSuffix         string        // suffix for resumable partial downloads
+	NoPartial             bool          // don't use resumable partial downloads
+	StatsOneLine          bool          // make the stats fit on one line
+	StatsOneLineDate      bool          // one line stats with the date and key=value fields
+	DisableHTTP2          bool          // don't negotiate HTTP/2 with servers
+	TransfersRamp         time.Duration // double the concurrent transfers this often starting from 1
+	StatsETASmoothing     float64       // weight of the newest speed sample in the ETA, 0 for the average speed
+	FastListThreshold     int           // use ListR if the first level has more entries than this, 0 to disable
+	NoListR               bool          // never use ListR
+	OrderBy               string        // order the transfers by this, eg "size,descending"
+	OrderByLookahead      int           // number of transfers to look at to order them
+	VerifyExisting        bool          // skip existing files only if their hashes match
+	ModTimeSource         ModTimeSource // which modification time to compare
+	CreateEmptySrcDirs    bool          // create empty source directories on the destination
+	EmptyDirMarker        string        // leaf name of a placeholder for empty directories on remotes which can't have them
+	RefreshTimes          bool          // set the modification time of files found equal by --size-only or --checksum
+	Inplace               bool          // update existing destination objects rather than replacing them
+	ClockSkew             time.Duration // how far the clocks of the servers are ahead of the local clock
+	ClockSkewAuto         bool          // measure the clock skew of each remote instead of using ClockSkew
+	MetadataMapper        string        // program to transform the metadata of each object uploaded
+	MetadataMapperTimeout time.Duration // how long the metadata mapper may take for each object
+	Delta                 bool          // update existing files by writing only the blocks which changed
+	DeltaBlockSize        SizeSuffix    // size of the blocks compared by --delta
+	FixCase               bool          // rename destination files whose names only differ in case from the source
+	DedupeOnUpload        bool          // server side copy files with the same hash as ones already uploaded
 }
 
 // NewConfig creates a new config with everything set to the default
@@ -90,7 +138,12 @@ func NewConfig() *ConfigInfo {
 	c.Timeout = 5 * 60 * time.Second
 	c.DeleteMode = DeleteModeDefault
 	c.MaxDelete = -1
+	c.MaxDeleteSize = -1
 	c.LowLevelRetries = 10
+	c.BreakerCooldown = 30 * time.Second
+	c.CompoundExtensions = []string{".tar.gz", ".tar.bz2", ".tar.xz"}
+	c.MultiThreadCutoff = SizeSuffix(250 * 1024 * 1024)
+	c.MultiThreadStreams = 0
 	c.MaxDepth = -1
 	c.DataRateUnit = "bytes"
 	c.BufferSize = SizeSuffix(16 << 20)
@@ -100,6 +153,15 @@ func NewConfig() *ConfigInfo {
 	c.AskPassword = true
 	c.TPSLimitBurst = 1
 	c.MaxTransfer = -1
+	c.CutoffMode = CutoffModeDefault
+	c.TrackRenamesStrategy = "hash"
+	c.MinFreeSpace = -1
+	c.PartialSuffix = ".rclonepartial"
+	c.StatsETASmoothing = 0.3
+	c.OrderByLookahead = 10000
+	c.ModTimeSource = ModTimeSourceDefault
+	c.MetadataMapperTimeout = time.Minute
+	c.DeltaBlockSize = SizeSuffix(64 * 1024)
 
 	return c
 }
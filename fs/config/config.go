@@ -21,6 +21,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -33,6 +34,7 @@ import (
 	"github.com/ncw/rclone/fs/fspath"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/text/unicode/norm"
 )
 
@@ -57,6 +59,10 @@ const (
 
 	// ConfigAutomatic indicates that we want non-interactive configuration
 	ConfigAutomatic = "config_automatic"
+
+	// ConfigAutomaticSave indicates that non-interactive configuration
+	// should save the token in the config file rather than print it
+	ConfigAutomaticSave = "config_automatic_save"
 )
 
 // Global
@@ -462,10 +468,16 @@ func SaveConfig() {
 	return
 }
 
+// setValueAndSaveMu stops concurrent calls to SetValueAndSave
+// clobbering each other's values
+var setValueAndSaveMu sync.Mutex
+
 // SetValueAndSave sets the key to the value and saves just that
 // value in the config file.  It loads the old config file in from
 // disk first and overwrites the given value only.
 func SetValueAndSave(name, key, value string) (err error) {
+	setValueAndSaveMu.Lock()
+	defer setValueAndSaveMu.Unlock()
 	// Set the value in config in case we fail to reload it
 	getConfigData().SetValue(name, key, value)
 	// Reload the config file
@@ -521,6 +533,12 @@ var ReadLine = func() string {
 	return strings.TrimSpace(line)
 }
 
+// StdinIsTerminal returns whether rclone is being run interactively
+// so can ask the user questions
+var StdinIsTerminal = func() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
 // Command - choose one
 func Command(commands []string) byte {
 	opts := []string{}
@@ -1069,15 +1087,20 @@ func SetPassword() {
 //
 //   rclone authorize "fs name"
 //   rclone authorize "fs name" "client id" "client secret"
-func Authorize(args []string) {
+//
+// If outputRemote is set then the token is saved in the config of
+// that remote, which is made if it doesn't exist, rather than being
+// printed.  If the remote already has a token which can be refreshed
+// then it is refreshed without any interaction.
+func Authorize(args []string, outputRemote string) {
 	switch len(args) {
 	case 1, 3:
 	default:
 		log.Fatalf("Invalid number of arguments: %d", len(args))
 	}
 	newType := args[0]
-	fs := fs.MustFind(newType)
-	if fs.Config == nil {
+	ri := fs.MustFind(newType)
+	if ri.Config == nil {
 		log.Fatalf("Can't authorize fs %q", newType)
 	}
 	// Name used for temporary fs
@@ -1086,13 +1109,47 @@ func Authorize(args []string) {
 	// Make sure we delete it
 	defer DeleteRemote(name)
 
+	outputRemote = strings.TrimSuffix(outputRemote, ":")
+	if outputRemote != "" {
+		switch remoteType := FileGet(outputRemote, "type"); remoteType {
+		case "", newType:
+		default:
+			log.Fatalf("Can't authorize remote %q of type %q as %q", outputRemote, remoteType, newType)
+		}
+		// Start from the config of the remote so its
+		// credentials and any token are used
+		for _, key := range getConfigData().GetKeyList(outputRemote) {
+			value, _ := getConfigData().GetValue(outputRemote, key)
+			getConfigData().SetValue(name, key, value)
+		}
+		// Save the token rather than printing it
+		getConfigData().SetValue(name, ConfigAutomaticSave, "yes")
+		// Suppress Confirm
+		fs.Config.AutoConfirm = true
+	}
+
 	// Indicate that we want fully automatic configuration.
 	getConfigData().SetValue(name, ConfigAutomatic, "yes")
 	if len(args) == 3 {
 		getConfigData().SetValue(name, ConfigClientID, args[1])
 		getConfigData().SetValue(name, ConfigClientSecret, args[2])
 	}
-	fs.Config(name)
+	ri.Config(name)
+
+	if outputRemote != "" {
+		token := FileGet(name, ConfigToken)
+		if token == "" {
+			log.Fatalf("Didn't get a token for remote %q", outputRemote)
+		}
+		getConfigData().SetValue(outputRemote, "type", newType)
+		if len(args) == 3 {
+			getConfigData().SetValue(outputRemote, ConfigClientID, args[1])
+			getConfigData().SetValue(outputRemote, ConfigClientSecret, args[2])
+		}
+		getConfigData().SetValue(outputRemote, ConfigToken, token)
+		SaveConfig()
+		fmt.Printf("Saved token in config for remote %q\n", outputRemote)
+	}
 }
 
 // configToEnv converts an config section and name, eg ("myremote",
@@ -1151,6 +1208,27 @@ func FileGetInt(section, key string, defaultVal ...int) int {
 	return getConfigData().MustInt(section, key, defaultVal...)
 }
 
+// FileGetTLSOptions reads the certificates for the remote in section
+// from its ca_cert, client_cert and client_key config keys.  Any
+// fields set in flags override these.
+func FileGetTLSOptions(section string, flags fshttp.TLSOptions) fshttp.TLSOptions {
+	opt := fshttp.TLSOptions{
+		CACert:     FileGet(section, "ca_cert"),
+		ClientCert: FileGet(section, "client_cert"),
+		ClientKey:  FileGet(section, "client_key"),
+	}
+	if flags.CACert != "" {
+		opt.CACert = flags.CACert
+	}
+	if flags.ClientCert != "" {
+		opt.ClientCert = flags.ClientCert
+	}
+	if flags.ClientKey != "" {
+		opt.ClientKey = flags.ClientKey
+	}
+	return opt
+}
+
 // FileSet sets the key in section to value.  It doesn't save
 // the config file.
 func FileSet(section, key, value string) {
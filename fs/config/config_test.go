@@ -8,6 +8,7 @@ import (
 
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/config/obscure"
+	"github.com/ncw/rclone/fs/fshttp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -227,3 +228,17 @@ func TestMatchProvider(t *testing.T) {
 		assert.Equal(t, test.want, got, what)
 	}
 }
+
+func TestFileGetTLSOptions(t *testing.T) {
+	const section = "config_test_tls"
+	FileSet(section, "ca_cert", "ca.pem")
+	FileSet(section, "client_cert", "cert.pem")
+	defer getConfigData().DeleteSection(section)
+
+	opt := FileGetTLSOptions(section, fshttp.TLSOptions{})
+	assert.Equal(t, fshttp.TLSOptions{CACert: "ca.pem", ClientCert: "cert.pem"}, opt)
+
+	// The flags override the config
+	opt = FileGetTLSOptions(section, fshttp.TLSOptions{ClientCert: "flag-cert.pem", ClientKey: "flag-key.pem"})
+	assert.Equal(t, fshttp.TLSOptions{CACert: "ca.pem", ClientCert: "flag-cert.pem", ClientKey: "flag-key.pem"}, opt)
+}
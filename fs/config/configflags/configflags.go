@@ -8,6 +8,7 @@ import (
 	"net"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/config"
@@ -26,7 +27,12 @@ var (
 	deleteAfter     bool
 	bindAddr        string
 	disableFeatures string
+	compoundExts    string
 	noTraverse      bool
+	clockSkew       string
+	headers         []string
+	uploadHeaders   []string
+	downloadHeaders []string
 )
 
 // AddFlags adds the non filing system specific flags to the command
@@ -41,12 +47,17 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.StringVarP(flagSet, &config.CacheDir, "cache-dir", "", config.CacheDir, "Directory rclone will use for caching.")
 	flags.BoolVarP(flagSet, &fs.Config.CheckSum, "checksum", "c", fs.Config.CheckSum, "Skip based on checksum & size, not mod-time & size")
 	flags.BoolVarP(flagSet, &fs.Config.SizeOnly, "size-only", "", fs.Config.SizeOnly, "Skip based on size only, not mod-time or checksum")
+	flags.BoolVarP(flagSet, &fs.Config.SizeAndFreeHash, "size-and-free-hash", "", fs.Config.SizeAndFreeHash, "Skip based on size and any checksum which can be read without computing it, not mod-time")
 	flags.BoolVarP(flagSet, &fs.Config.IgnoreTimes, "ignore-times", "I", fs.Config.IgnoreTimes, "Don't skip files that match size and time - transfer all files")
 	flags.BoolVarP(flagSet, &fs.Config.IgnoreExisting, "ignore-existing", "", fs.Config.IgnoreExisting, "Skip all files that exist on destination")
+	flags.BoolVarP(flagSet, &fs.Config.VerifyExisting, "verify-existing", "", fs.Config.VerifyExisting, "Skip files that exist on destination only if their hashes match")
 	flags.BoolVarP(flagSet, &fs.Config.IgnoreErrors, "ignore-errors", "", fs.Config.IgnoreErrors, "delete even if there are I/O errors")
 	flags.BoolVarP(flagSet, &fs.Config.DryRun, "dry-run", "n", fs.Config.DryRun, "Do a trial run with no permanent changes")
+	flags.BoolVarP(flagSet, &fs.Config.Interactive, "interactive", "", fs.Config.Interactive, "Ask before each transfer, overwrite or delete")
 	flags.DurationVarP(flagSet, &fs.Config.ConnectTimeout, "contimeout", "", fs.Config.ConnectTimeout, "Connect timeout")
 	flags.DurationVarP(flagSet, &fs.Config.Timeout, "timeout", "", fs.Config.Timeout, "IO idle timeout")
+	flags.DurationVarP(flagSet, &fs.Config.ExpectContinueTimeout, "expect-continue-timeout", "", fs.Config.ExpectContinueTimeout, "Timeout when using expect / 100-continue in HTTP. 0 to use --contimeout.")
+	flags.DurationVarP(flagSet, &fs.Config.ReadIdleTimeout, "read-idle-timeout", "", fs.Config.ReadIdleTimeout, "Abort and retry uploads which send no data for this long. 0 for off.")
 	flags.BoolVarP(flagSet, &dumpHeaders, "dump-headers", "", false, "Dump HTTP bodies - may contain sensitive info")
 	flags.BoolVarP(flagSet, &dumpBodies, "dump-bodies", "", false, "Dump HTTP headers and bodies - may contain sensitive info")
 	flags.BoolVarP(flagSet, &fs.Config.InsecureSkipVerify, "no-check-certificate", "", fs.Config.InsecureSkipVerify, "Do not verify the server SSL certificate. Insecure.")
@@ -55,19 +66,31 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &deleteDuring, "delete-during", "", false, "When synchronizing, delete files during transfer (default)")
 	flags.BoolVarP(flagSet, &deleteAfter, "delete-after", "", false, "When synchronizing, delete files on destination after transfering")
 	flags.IntVar64P(flagSet, &fs.Config.MaxDelete, "max-delete", "", -1, "When synchronizing, limit the number of deletes")
+	flags.FVarP(flagSet, &fs.Config.MaxDeleteSize, "max-delete-size", "", "When synchronizing, limit the total size of deletes")
 	flags.BoolVarP(flagSet, &fs.Config.TrackRenames, "track-renames", "", fs.Config.TrackRenames, "When synchronizing, track file renames and do a server side move if possible")
+	flags.StringVarP(flagSet, &fs.Config.TrackRenamesStrategy, "track-renames-strategy", "", fs.Config.TrackRenamesStrategy, "Strategies to use when synchronizing using track-renames hash|modtime|leaf")
 	flags.IntVarP(flagSet, &fs.Config.LowLevelRetries, "low-level-retries", "", fs.Config.LowLevelRetries, "Number of low level retries to do.")
+	flags.IntVarP(flagSet, &fs.Config.BreakerThreshold, "backend-circuit-breaker-threshold", "", fs.Config.BreakerThreshold, "Fail calls to a backend fast after this many consecutive failures. 0 to disable.")
+	flags.DurationVarP(flagSet, &fs.Config.BreakerCooldown, "backend-circuit-breaker-cooldown", "", fs.Config.BreakerCooldown, "Time to fail calls fast for before trying the backend again.")
 	flags.BoolVarP(flagSet, &fs.Config.UpdateOlder, "update", "u", fs.Config.UpdateOlder, "Skip files that are newer on the destination.")
 	flags.BoolVarP(flagSet, &fs.Config.UseServerModTime, "use-server-modtime", "", fs.Config.UseServerModTime, "Use server modified time instead of object metadata")
+	flags.BoolVarP(flagSet, &fs.Config.DisableHTTP2, "disable-http2", "", fs.Config.DisableHTTP2, "Disable HTTP/2 in the global transport.")
 	flags.BoolVarP(flagSet, &fs.Config.NoGzip, "no-gzip-encoding", "", fs.Config.NoGzip, "Don't set Accept-Encoding: gzip.")
 	flags.IntVarP(flagSet, &fs.Config.MaxDepth, "max-depth", "", fs.Config.MaxDepth, "If set limits the recursion depth to this.")
 	flags.BoolVarP(flagSet, &fs.Config.IgnoreSize, "ignore-size", "", false, "Ignore size when skipping use mod-time or checksum.")
 	flags.BoolVarP(flagSet, &fs.Config.IgnoreChecksum, "ignore-checksum", "", fs.Config.IgnoreChecksum, "Skip post copy check of checksums.")
 	flags.BoolVarP(flagSet, &noTraverse, "no-traverse", "", noTraverse, "Obsolete - does nothing.")
 	flags.BoolVarP(flagSet, &fs.Config.NoUpdateModTime, "no-update-modtime", "", fs.Config.NoUpdateModTime, "Don't update destination mod-time if files identical.")
+	flags.BoolVarP(flagSet, &fs.Config.RefreshTimes, "refresh-times", "", fs.Config.RefreshTimes, "Set the mod-time of files found identical by --size-only or --checksum instead of ignoring it.")
 	flags.StringVarP(flagSet, &fs.Config.BackupDir, "backup-dir", "", fs.Config.BackupDir, "Make backups into hierarchy based in DIR.")
 	flags.StringVarP(flagSet, &fs.Config.Suffix, "suffix", "", fs.Config.Suffix, "Suffix for use with --backup-dir.")
+	flags.BoolVarP(flagSet, &fs.Config.SuffixKeepExtension, "suffix-keep-extension", "", fs.Config.SuffixKeepExtension, "Preserve the extension when using --suffix.")
+	flags.StringVarP(flagSet, &compoundExts, "suffix-compound-extensions", "", strings.Join(fs.Config.CompoundExtensions, ","), "Comma separated list of extensions kept whole by --suffix-keep-extension.")
 	flags.BoolVarP(flagSet, &fs.Config.UseListR, "fast-list", "", fs.Config.UseListR, "Use recursive list if available. Uses more memory but fewer transactions.")
+	flags.IntVarP(flagSet, &fs.Config.FastListThreshold, "fast-list-threshold", "", fs.Config.FastListThreshold, "Use recursive list if the top directory has more entries than this. 0 to disable.")
+	flags.BoolVarP(flagSet, &fs.Config.NoListR, "no-fast-list", "", fs.Config.NoListR, "Never use recursive list, overriding --fast-list-threshold.")
+	flags.StringVarP(flagSet, &fs.Config.OrderBy, "order-by", "", fs.Config.OrderBy, "Order the transfers by size|path|modtime optionally with ,ascending|descending|mixed")
+	flags.IntVarP(flagSet, &fs.Config.OrderByLookahead, "order-by-lookahead", "", fs.Config.OrderByLookahead, "Number of pending transfers to order with --order-by.")
 	flags.Float64VarP(flagSet, &fs.Config.TPSLimit, "tpslimit", "", fs.Config.TPSLimit, "Limit HTTP transactions per second to this.")
 	flags.IntVarP(flagSet, &fs.Config.TPSLimitBurst, "tpslimit-burst", "", fs.Config.TPSLimitBurst, "Max burst of transactions for --tpslimit.")
 	flags.StringVarP(flagSet, &bindAddr, "bind", "", "", "Local address to bind to for outgoing connections, IPv4, IPv6 or name.")
@@ -76,13 +99,43 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &fs.Config.Immutable, "immutable", "", fs.Config.Immutable, "Do not modify files. Fail if existing files have been modified.")
 	flags.BoolVarP(flagSet, &fs.Config.AutoConfirm, "auto-confirm", "", fs.Config.AutoConfirm, "If enabled, do not request console confirmation.")
 	flags.IntVarP(flagSet, &fs.Config.StatsFileNameLength, "stats-file-name-length", "", fs.Config.StatsFileNameLength, "Max file name length in stats. 0 for no limit")
+	flags.BoolVarP(flagSet, &fs.Config.StatsFullPath, "stats-full-path", "", fs.Config.StatsFullPath, "Show the remote and full path of files being transferred in stats.")
 	flags.FVarP(flagSet, &fs.Config.LogLevel, "log-level", "", "Log level DEBUG|INFO|NOTICE|ERROR")
+	flags.BoolVarP(flagSet, &fs.Config.StatsOneLine, "stats-one-line", "", fs.Config.StatsOneLine, "Make the stats fit on one line.")
+	flags.BoolVarP(flagSet, &fs.Config.StatsOneLineDate, "stats-one-line-date", "", fs.Config.StatsOneLineDate, "Make the stats one line starting with the date for log parsing.")
+	flags.Float64VarP(flagSet, &fs.Config.StatsETASmoothing, "stats-eta-smoothing", "", fs.Config.StatsETASmoothing, "Weight of the newest speed sample in the ETA moving average, 0 to use the average speed.")
 	flags.FVarP(flagSet, &fs.Config.StatsLogLevel, "stats-log-level", "", "Log level to show --stats output DEBUG|INFO|NOTICE|ERROR")
+	flags.FVarP(flagSet, &fs.Config.MultiThreadCutoff, "multi-thread-cutoff", "", "Use multi-thread downloads for files above this size.")
+	flags.IntVarP(flagSet, &fs.Config.MultiThreadStreams, "multi-thread-streams", "", fs.Config.MultiThreadStreams, "Number of streams to use for multi-thread downloads, 2 or more to enable them.")
+	flags.BoolVarP(flagSet, &fs.Config.Atomic, "atomic", "", fs.Config.Atomic, "Upload to a temporary name then rename to the final name if the remote can.")
+	flags.BoolVarP(flagSet, &fs.Config.Inplace, "inplace", "", fs.Config.Inplace, "Overwrite existing destination files in place rather than replacing them.")
+	flags.BoolVarP(flagSet, &fs.Config.Delta, "delta", "", fs.Config.Delta, "Update existing files by writing only the blocks which have changed if the remote can.")
+	flags.FVarP(flagSet, &fs.Config.DeltaBlockSize, "delta-block-size", "", "Size of the blocks compared with --delta.")
+	flags.BoolVarP(flagSet, &fs.Config.FixCase, "fix-case", "", fs.Config.FixCase, "Rename destination files whose names only differ in case to match the source.")
+	flags.BoolVarP(flagSet, &fs.Config.DedupeOnUpload, "dedupe-on-upload", "", fs.Config.DedupeOnUpload, "Server side copy files with the same contents as ones already uploaded instead of uploading them again.")
+	flags.BoolVarP(flagSet, &fs.Config.DeleteExcludedDryRun, "delete-excluded-dry-run-first", "", fs.Config.DeleteExcludedDryRun, "List the files --delete-excluded would delete and confirm before deleting them.")
+	flags.DurationVarP(flagSet, &fs.Config.ListCacheTime, "list-cache-time", "", fs.Config.ListCacheTime, "Time to cache directory listings for, 0 to disable.")
+	flags.BoolVarP(flagSet, &fs.Config.Refresh, "refresh", "", fs.Config.Refresh, "Ignore any cached directory listings and read them afresh.")
+	flags.StringVarP(flagSet, &fs.Config.PartialSuffix, "partial-suffix", "", fs.Config.PartialSuffix, "Suffix for partial downloads which can be resumed.")
+	flags.BoolVarP(flagSet, &fs.Config.NoPartial, "no-partial", "", fs.Config.NoPartial, "Don't download to partial files which can be resumed.")
+	flags.DurationVarP(flagSet, &fs.Config.TransfersRamp, "transfers-ramp", "", fs.Config.TransfersRamp, "Start with 1 transfer and double the number running this often up to --transfers.")
 	flags.FVarP(flagSet, &fs.Config.BwLimit, "bwlimit", "", "Bandwidth limit in kBytes/s, or use suffix b|k|M|G or a full timetable.")
 	flags.FVarP(flagSet, &fs.Config.BufferSize, "buffer-size", "", "Buffer size when copying files.")
 	flags.FVarP(flagSet, &fs.Config.StreamingUploadCutoff, "streaming-upload-cutoff", "", "Cutoff for switching to chunked upload if file size is unknown. Upload starts after reaching cutoff or when file ends.")
+	flags.FVarP(flagSet, &fs.Config.UploadBufferPoolMax, "upload-buffer-pool-max", "", "Max memory for the chunk buffers of all the uploads in progress, 0 for no limit.")
 	flags.FVarP(flagSet, &fs.Config.Dump, "dump", "", "List of items to dump from: "+fs.DumpFlagsList)
+	flags.StringArrayVarP(flagSet, &headers, "header", "", nil, "Set HTTP header for all transactions, eg \"X-Key: value\" (can be repeated)")
+	flags.StringArrayVarP(flagSet, &uploadHeaders, "header-upload", "", nil, "Set HTTP header for upload transactions (can be repeated)")
+	flags.StringArrayVarP(flagSet, &downloadHeaders, "header-download", "", nil, "Set HTTP header for download transactions (can be repeated)")
 	flags.FVarP(flagSet, &fs.Config.MaxTransfer, "max-transfer", "", "Maximum size of data to transfer.")
+	flags.FVarP(flagSet, &fs.Config.CutoffMode, "cutoff-mode", "", "Mode to stop transfers when reaching the max transfer limit HARD|SOFT")
+	flags.FVarP(flagSet, &fs.Config.ModTimeSource, "modtime-source", "", "Modification times to compare metadata|server|source")
+	flags.StringVarP(flagSet, &clockSkew, "clock-skew", "", "", "How far the servers' clocks are ahead of the local one when comparing their times, or \"auto\" to measure it")
+	flags.StringVarP(flagSet, &fs.Config.MetadataMapper, "metadata-mapper", "", fs.Config.MetadataMapper, "Program to transform the metadata of each file uploaded, reading and writing it as JSON.")
+	flags.DurationVarP(flagSet, &fs.Config.MetadataMapperTimeout, "metadata-mapper-timeout", "", fs.Config.MetadataMapperTimeout, "Time the --metadata-mapper may take for each file.")
+	flags.BoolVarP(flagSet, &fs.Config.CreateEmptySrcDirs, "create-empty-src-dirs", "", fs.Config.CreateEmptySrcDirs, "Create empty source dirs on remotes which can't have them using --create-empty-src-dirs-marker")
+	flags.StringVarP(flagSet, &fs.Config.EmptyDirMarker, "create-empty-src-dirs-marker", "", fs.Config.EmptyDirMarker, "Name of a placeholder file to create empty dirs on remotes which can't have them")
+	flags.FVarP(flagSet, &fs.Config.MinFreeSpace, "min-free-space", "", "Stop transferring if the free space on the destination would go below this.")
 }
 
 // SetFlags converts any flags into config which weren't straight foward
@@ -135,14 +188,77 @@ func SetFlags() {
 		fs.Config.DeleteMode = fs.DeleteModeDefault
 	}
 
+	switch {
+	case fs.Config.ModTimeSource == fs.ModTimeSourceServer:
+		fs.Config.UseServerModTime = true
+	case fs.Config.UseServerModTime && fs.Config.ModTimeSource == fs.ModTimeSourceSource:
+		log.Fatalf(`Can't use --use-server-modtime with --modtime-source source.`)
+	case fs.Config.UseServerModTime:
+		fs.Config.ModTimeSource = fs.ModTimeSourceServer
+	}
+
+	if fs.Config.EmptyDirMarker != "" {
+		if !fs.Config.CreateEmptySrcDirs {
+			log.Fatalf(`Can only use --create-empty-src-dirs-marker with --create-empty-src-dirs.`)
+		}
+		if strings.ContainsRune(fs.Config.EmptyDirMarker, '/') {
+			log.Fatalf(`--create-empty-src-dirs-marker must be a file name not a path.`)
+		}
+	}
+
 	if fs.Config.IgnoreSize && fs.Config.SizeOnly {
 		log.Fatalf(`Can't use --size-only and --ignore-size together.`)
 	}
 
+	if fs.Config.SizeAndFreeHash && (fs.Config.SizeOnly || fs.Config.CheckSum || fs.Config.IgnoreSize) {
+		log.Fatalf(`Can't use --size-and-free-hash with --size-only, --checksum or --ignore-size.`)
+	}
+
+	if fs.Config.RefreshTimes {
+		if !fs.Config.SizeOnly && !fs.Config.CheckSum {
+			log.Fatalf(`Can only use --refresh-times with --size-only or --checksum.`)
+		}
+		if fs.Config.NoUpdateModTime {
+			log.Fatalf(`Can't use --refresh-times and --no-update-modtime together.`)
+		}
+	}
+
+	switch clockSkew {
+	case "":
+	case "auto":
+		fs.Config.ClockSkewAuto = true
+	default:
+		skew, err := time.ParseDuration(clockSkew)
+		if err != nil {
+			log.Fatalf(`Failed to parse --clock-skew: %v`, err)
+		}
+		fs.Config.ClockSkew = skew
+	}
+
 	if fs.Config.Suffix != "" && fs.Config.BackupDir == "" {
 		log.Fatalf(`Can only use --suffix with --backup-dir.`)
 	}
 
+	if fs.Config.SuffixKeepExtension && fs.Config.Suffix == "" {
+		log.Fatalf(`Can only use --suffix-keep-extension with --suffix.`)
+	}
+
+	fs.Config.CompoundExtensions = nil
+	for _, ext := range strings.Split(compoundExts, ",") {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		fs.Config.CompoundExtensions = append(fs.Config.CompoundExtensions, ext)
+	}
+
+	fs.Config.Headers = parseHeaders("header", headers)
+	fs.Config.UploadHeaders = parseHeaders("header-upload", uploadHeaders)
+	fs.Config.DownloadHeaders = parseHeaders("header-download", downloadHeaders)
+
 	if bindAddr != "" {
 		addrs, err := net.LookupIP(bindAddr)
 		if err != nil {
@@ -167,3 +283,20 @@ func SetFlags() {
 		config.ConfigPath = configPath
 	}
 }
+
+// parseHeaders parses the "Key: value" strings passed to the flag
+// called name into HTTPOptions
+func parseHeaders(name string, headers []string) (opts []*fs.HTTPOption) {
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			log.Fatalf("--%s: Failed to parse %q as an HTTP header - expecting \"Key: value\"", name, header)
+		}
+		opts = append(opts, &fs.HTTPOption{
+			Key:   key,
+			Value: strings.TrimSpace(parts[1]),
+		})
+	}
+	return opts
+}
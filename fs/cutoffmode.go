@@ -0,0 +1,48 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CutoffMode describes the possible actions to take when the
+// --max-transfer limit is reached
+type CutoffMode byte
+
+// CutoffMode constants
+const (
+	CutoffModeHard CutoffMode = iota
+	CutoffModeSoft
+	CutoffModeDefault = CutoffModeHard
+)
+
+var cutoffModeToString = []string{
+	CutoffModeHard: "HARD",
+	CutoffModeSoft: "SOFT",
+}
+
+// String turns a CutoffMode into a string
+func (m CutoffMode) String() string {
+	if m >= CutoffMode(len(cutoffModeToString)) {
+		return fmt.Sprintf("CutoffMode(%d)", m)
+	}
+	return cutoffModeToString[m]
+}
+
+// Set a CutoffMode
+func (m *CutoffMode) Set(s string) error {
+	for n, name := range cutoffModeToString {
+		if s != "" && name == strings.ToUpper(s) {
+			*m = CutoffMode(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown cutoff mode %q", s)
+}
+
+// Type of the value
+func (m *CutoffMode) Type() string {
+	return "string"
+}
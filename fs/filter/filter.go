@@ -418,10 +418,11 @@ func (f *Filter) DirContainsExcludeFile(fremote fs.Fs, remote string) (bool, err
 // Include returns whether this object should be included into the
 // sync or not
 func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
-	// filesFrom takes precedence
+	// filesFrom takes precedence over the rules but not the ages
 	if f.files != nil {
-		_, include := f.files[remote]
-		return include
+		if _, include := f.files[remote]; !include {
+			return false
+		}
 	}
 	if !f.ModTimeFrom.IsZero() && modTime.Before(f.ModTimeFrom) {
 		return false
@@ -429,6 +430,9 @@ func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
 	if !f.ModTimeTo.IsZero() && modTime.After(f.ModTimeTo) {
 		return false
 	}
+	if f.files != nil {
+		return true
+	}
 	if f.Opt.MinSize >= 0 && size < int64(f.Opt.MinSize) {
 		return false
 	}
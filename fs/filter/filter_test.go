@@ -250,6 +250,20 @@ func TestNewFilterMaxAge(t *testing.T) {
 	assert.False(t, f.InActive())
 }
 
+func TestNewFilterFilesFromMaxAge(t *testing.T) {
+	f, err := NewFilter(nil)
+	require.NoError(t, err)
+	require.NoError(t, f.AddFile("file1.jpg"))
+	require.NoError(t, f.AddFile("file3.jpg"))
+	f.ModTimeFrom = time.Unix(1440000002, 0)
+	testInclude(t, f, []includeTest{
+		{"file1.jpg", 100, 1440000000, false},
+		{"file2.jpg", 101, 1440000003, false},
+		{"file3.jpg", 102, 1440000002, true},
+	})
+	assert.False(t, f.InActive())
+}
+
 func TestNewFilterMatches(t *testing.T) {
 	f, err := NewFilter(nil)
 	require.NoError(t, err)
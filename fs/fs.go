@@ -60,6 +60,7 @@ var (
 	ErrorDirectoryNotEmpty           = errors.New("directory not empty")
 	ErrorImmutableModified           = errors.New("immutable file modified")
 	ErrorPermissionDenied            = errors.New("permission denied")
+	ErrorCommandNotFound             = errors.New("command not found")
 )
 
 // RegInfo provides information about a filesystem
@@ -245,6 +246,20 @@ type MimeTyper interface {
 	MimeType() string
 }
 
+// ServerModTimer is an optional interface for Object
+type ServerModTimer interface {
+	// ServerModTime returns the time the Object was last
+	// written on the server which may differ from ModTime
+	ServerModTime() time.Time
+}
+
+// Metadataer is an optional interface for Object
+type Metadataer interface {
+	// Metadata returns the custom properties of the Object, or
+	// nil if it doesn't have any
+	Metadata() map[string]string
+}
+
 // IDer is an optional interface for Object
 type IDer interface {
 	// ID returns the ID of the Object if known, or "" if not
@@ -295,6 +310,8 @@ type Features struct {
 	WriteMimeType           bool // can set the mime type of objects
 	CanHaveEmptyDirectories bool // can have empty directories
 	BucketBased             bool // is bucket based (like s3, swift etc)
+	SlowHash                bool // Hash() reads the data or asks the server to compute it
+	AtomicMove              bool // Move replaces an existing object atomically
 
 	// Purge all files in the root and the root directory
 	//
@@ -402,8 +419,51 @@ type Features struct {
 	// of listing recursively that doing a directory traversal.
 	ListR ListRFn
 
+	// ListP lists the objects and directories in dir calling
+	// callback with each page of them as they are read.
+	//
+	// dir should be "" to list the root, and should not have
+	// trailing slashes.
+	//
+	// This should return ErrDirNotFound if the directory isn't
+	// found.
+	//
+	// The entries need not be in any particular order.  If
+	// callback returns an error then the listing will stop
+	// immediately.
+	//
+	// Implement this if the listings are paginated so very big
+	// directories can be listed without holding them in memory.
+	ListP ListRFn
+
 	// About gets quota information from the Fs
 	About func() (*Usage, error)
+
+	// RemoveBatch removes all the objects passed in which must
+	// be from this Fs.  It returns a slice the same length as
+	// objs with the error for each object, nil if it was removed
+	// successfully.
+	RemoveBatch func(objs []Object) []error
+
+	// OpenWriterAt opens with a handle for random access writes
+	//
+	// Pass in the remote desired and the size if known.
+	//
+	// If the size is known the object is set to that size keeping
+	// any existing data up to it, otherwise it truncates any
+	// existing object
+	OpenWriterAt func(remote string, size int64) (WriterAtCloser, error)
+
+	// Command the backend to run a named command
+	//
+	// The command run is name, args may be used to read arguments
+	// from.  It returns a result which can be marshalled to JSON
+	// and an error.  If the command isn't known then it should
+	// return fs.ErrorCommandNotFound.
+	Command func(name string, args []string) (interface{}, error)
+
+	// ServerTime returns the time now according to the server
+	ServerTime func() (time.Time, error)
 }
 
 // Disable nil's out the named feature.  If it isn't found then it
@@ -437,6 +497,27 @@ func (ft *Features) List() (out []string) {
 	return out
 }
 
+// Enabled returns a map of features with keys showing whether they
+// are enabled or not.  Boolean features are enabled if they are true
+// and optional functions if they are set.
+func (ft *Features) Enabled() (features map[string]bool) {
+	v := reflect.ValueOf(ft).Elem()
+	vType := v.Type()
+	features = make(map[string]bool, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		vName := vType.Field(i).Name
+		field := v.Field(i)
+		if field.Kind() == reflect.Func {
+			// Can't compare functions
+			features[vName] = !field.IsNil()
+		} else {
+			zero := reflect.Zero(field.Type())
+			features[vName] = field.Interface() != zero.Interface()
+		}
+	}
+	return features
+}
+
 // DisableList nil's out the comma separated list of named features.
 // If it isn't found then it will log a message.
 func (ft *Features) DisableList(list []string) *Features {
@@ -493,9 +574,24 @@ func (ft *Features) Fill(f Fs) *Features {
 	if do, ok := f.(ListRer); ok {
 		ft.ListR = do.ListR
 	}
+	if do, ok := f.(ListPer); ok {
+		ft.ListP = do.ListP
+	}
 	if do, ok := f.(Abouter); ok {
 		ft.About = do.About
 	}
+	if do, ok := f.(BatchRemover); ok {
+		ft.RemoveBatch = do.RemoveBatch
+	}
+	if do, ok := f.(OpenWriterAter); ok {
+		ft.OpenWriterAt = do.OpenWriterAt
+	}
+	if do, ok := f.(Commander); ok {
+		ft.Command = do.Command
+	}
+	if do, ok := f.(ServerTimer); ok {
+		ft.ServerTime = do.ServerTime
+	}
 	return ft.DisableList(Config.DisableFeatures)
 }
 
@@ -513,6 +609,9 @@ func (ft *Features) Mask(f Fs) *Features {
 	ft.WriteMimeType = ft.WriteMimeType && mask.WriteMimeType
 	ft.CanHaveEmptyDirectories = ft.CanHaveEmptyDirectories && mask.CanHaveEmptyDirectories
 	ft.BucketBased = ft.BucketBased && mask.BucketBased
+	// A wrapped Fs with slow hashes makes the wrapper's slow too
+	ft.SlowHash = ft.SlowHash || mask.SlowHash
+	ft.AtomicMove = ft.AtomicMove && mask.AtomicMove
 	if mask.Purge == nil {
 		ft.Purge = nil
 	}
@@ -549,9 +648,24 @@ func (ft *Features) Mask(f Fs) *Features {
 	if mask.ListR == nil {
 		ft.ListR = nil
 	}
+	if mask.ListP == nil {
+		ft.ListP = nil
+	}
 	if mask.About == nil {
 		ft.About = nil
 	}
+	if mask.RemoveBatch == nil {
+		ft.RemoveBatch = nil
+	}
+	if mask.OpenWriterAt == nil {
+		ft.OpenWriterAt = nil
+	}
+	if mask.Command == nil {
+		ft.Command = nil
+	}
+	if mask.ServerTime == nil {
+		ft.ServerTime = nil
+	}
 	return ft.DisableList(Config.DisableFeatures)
 }
 
@@ -726,6 +840,26 @@ type ListRer interface {
 	ListR(dir string, callback ListRCallback) error
 }
 
+// ListPer is an optional interfaces for Fs
+type ListPer interface {
+	// ListP lists the objects and directories in dir calling
+	// callback with each page of them as they are read.
+	//
+	// dir should be "" to list the root, and should not have
+	// trailing slashes.
+	//
+	// This should return ErrDirNotFound if the directory isn't
+	// found.
+	//
+	// The entries need not be in any particular order.  If
+	// callback returns an error then the listing will stop
+	// immediately.
+	//
+	// Implement this if the listings are paginated so very big
+	// directories can be listed without holding them in memory.
+	ListP(dir string, callback ListRCallback) error
+}
+
 // RangeSeeker is the interface that wraps the RangeSeek method.
 //
 // Some of the returns from Object.Open() may optionally implement
@@ -745,6 +879,68 @@ type Abouter interface {
 	About() (*Usage, error)
 }
 
+// BatchRemover is an optional interface for Fs
+type BatchRemover interface {
+	// RemoveBatch removes all the objects passed in which must
+	// be from this Fs.  It returns a slice the same length as
+	// objs with the error for each object, nil if it was removed
+	// successfully.
+	//
+	// Implement this if you have a way of removing lots of
+	// objects quicker than calling Remove() on each of them.
+	RemoveBatch(objs []Object) []error
+}
+
+// OpenWriterAter is an optional interface for Fs
+type OpenWriterAter interface {
+	// OpenWriterAt opens with a handle for random access writes
+	//
+	// Pass in the remote desired and the size if known.
+	//
+	// If the size is known the object is set to that size keeping
+	// any existing data up to it, otherwise it truncates any
+	// existing object
+	OpenWriterAt(remote string, size int64) (WriterAtCloser, error)
+}
+
+// Commander is an optional interface for Fs
+type Commander interface {
+	// Command the backend to run a named command
+	//
+	// The command run is name, args may be used to read arguments
+	// from.  It returns a result which can be marshalled to JSON
+	// and an error.  If the command isn't known then it should
+	// return fs.ErrorCommandNotFound.
+	Command(name string, args []string) (interface{}, error)
+}
+
+// CommandTexter is an optional interface for the results of Command
+// which print as text, eg a table, rather than as JSON
+type CommandTexter interface {
+	// CommandText returns the result as text to print
+	CommandText() string
+}
+
+// ServerTimer is an optional interface for Fs
+type ServerTimer interface {
+	// ServerTime returns the time now according to the server
+	ServerTime() (time.Time, error)
+}
+
+// WriterAtCloser wraps io.WriterAt and io.Closer
+type WriterAtCloser interface {
+	io.WriterAt
+	io.Closer
+}
+
+// TransferLimiter is an optional interface for Fs
+type TransferLimiter interface {
+	// MaxConcurrentTransfers returns the maximum number of
+	// transfers to or from this Fs which may run at once, or 0
+	// for no limit other than --transfers
+	MaxConcurrentTransfers() int
+}
+
 // ObjectsChan is a channel of Objects
 type ObjectsChan chan Object
 
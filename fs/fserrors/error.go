@@ -166,6 +166,32 @@ func IsNoRetryError(err error) bool {
 	return false
 }
 
+// Reasoner is an optional interface for error to supply a short
+// machine readable reason for the error as reported by the backend,
+// eg "userRateLimitExceeded".
+type Reasoner interface {
+	error
+	Reason() string
+}
+
+// Reason returns the backend reason for err if any error in its
+// chain of causes implements the Reasoner interface, or "" otherwise.
+func Reason(err error) string {
+	for err != nil {
+		if r, ok := err.(Reasoner); ok {
+			return r.Reason()
+		}
+		cause, ok := err.(interface {
+			Cause() error
+		})
+		if !ok {
+			break
+		}
+		err = cause.Cause()
+	}
+	return ""
+}
+
 // Cause is a souped up errors.Cause which can unwrap some standard
 // library errors too.  It returns true if any of the intermediate
 // errors had a Timeout() or Temporary() method which returned true.
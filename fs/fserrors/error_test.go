@@ -107,6 +107,31 @@ func TestCause(t *testing.T) {
 	}
 }
 
+type reasonError struct {
+	error
+}
+
+func (e reasonError) Reason() string { return "potatoReason" }
+
+func TestReason(t *testing.T) {
+	errPotato := errors.New("potato")
+	errReason := reasonError{errPotato}
+	for i, test := range []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{errPotato, ""},
+		{errReason, "potatoReason"},
+		{errors.Wrap(errReason, "wrapped"), "potatoReason"},
+		{&errorCause{errReason}, "potatoReason"},
+		{&errorCause{nil}, ""},
+	} {
+		got := Reason(test.err)
+		assert.Equal(t, test.want, got, fmt.Sprintf("test #%d: %v", i, test.err))
+	}
+}
+
 func TestShouldRetry(t *testing.T) {
 	for i, test := range []struct {
 		err  error
@@ -1,11 +1,20 @@
 package fshttp
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/fserrors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // returns the "%p" reprentation of the thing passed in
@@ -76,3 +85,183 @@ func TestCleanAuths(t *testing.T) {
 		assert.Equal(t, test.want, got, test.in)
 	}
 }
+
+func TestDisableHTTP2(t *testing.T) {
+	ci := *fs.Config
+	tr := NewTransportCustom(&ci, nil).(*Transport)
+	assert.Nil(t, tr.TLSNextProto)
+
+	tr = NewTransportCustom(&ci, DisableHTTP2).(*Transport)
+	assert.NotNil(t, tr.TLSNextProto)
+	assert.Len(t, tr.TLSNextProto, 0)
+
+	ci.DisableHTTP2 = true
+	tr = NewTransportCustom(&ci, nil).(*Transport)
+	assert.NotNil(t, tr.TLSNextProto)
+}
+
+func TestExpectContinueTimeout(t *testing.T) {
+	ci := *fs.Config
+	ci.ConnectTimeout = 10 * time.Second
+	tr := NewTransportCustom(&ci, nil).(*Transport)
+	assert.Equal(t, 10*time.Second, tr.ExpectContinueTimeout)
+
+	ci.ExpectContinueTimeout = time.Second
+	tr = NewTransportCustom(&ci, nil).(*Transport)
+	assert.Equal(t, time.Second, tr.ExpectContinueTimeout)
+}
+
+func TestWithIdleTimeout(t *testing.T) {
+	stall := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stall" {
+			// Don't read the body so the upload stalls
+			<-stall
+			return
+		}
+		_, _ = io.Copy(ioutil.Discard, r.Body)
+		if r.URL.Path == "/slow" {
+			// Take a while to respond once the body is read
+			time.Sleep(300 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+	defer close(stall)
+	do := func(path string, size int, timeout time.Duration) error {
+		req, err := http.NewRequest("PUT", ts.URL+path, bytes.NewReader(make([]byte, size)))
+		require.NoError(t, err)
+		req, done := WithIdleTimeout(req, timeout)
+		res, err := http.DefaultClient.Do(req)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+		return done(err)
+	}
+
+	// Bodies which are sent are fine with or without the timeout
+	require.NoError(t, do("/", 1024, 0))
+	require.NoError(t, do("/", 1024, time.Second))
+
+	// A slow response isn't aborted once the body has been sent
+	require.NoError(t, do("/slow", 1024, 100*time.Millisecond))
+
+	// A successful request isn't turned into an error
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	require.NoError(t, err)
+	_, done := WithIdleTimeout(req, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, done(nil))
+
+	// A stalled upload is aborted with a retry error
+	err = do("/stall", 256*1024*1024, 100*time.Millisecond)
+	assert.Equal(t, ErrorIdleTimeout, err)
+	assert.True(t, fserrors.ShouldRetry(err))
+}
+
+func TestTransportHeaders(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer ts.Close()
+
+	ci := *fs.Config
+	ci.Headers = []*fs.HTTPOption{
+		{Key: "X-Key", Value: "key"},
+		{Key: "x-multi", Value: "one"},
+		{Key: "X-Multi", Value: "two"},
+		{Key: "Authorization", Value: "potato"},
+	}
+	ci.UploadHeaders = []*fs.HTTPOption{{Key: "X-Upload", Value: "up"}}
+	ci.DownloadHeaders = []*fs.HTTPOption{{Key: "X-Download", Value: "down"}}
+	client := &http.Client{Transport: NewTransportCustom(&ci, nil)}
+
+	do := func(method string) {
+		got = nil
+		req, err := http.NewRequest(method, ts.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer token")
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+
+	do("GET")
+	assert.Equal(t, "key", got.Get("X-Key"))
+	assert.Equal(t, []string{"one", "two"}, got["X-Multi"])
+	assert.Equal(t, "Bearer token", got.Get("Authorization"))
+	assert.Equal(t, "down", got.Get("X-Download"))
+	assert.Equal(t, "", got.Get("X-Upload"))
+
+	do("PUT")
+	assert.Equal(t, "key", got.Get("X-Key"))
+	assert.Equal(t, "up", got.Get("X-Upload"))
+	assert.Equal(t, "", got.Get("X-Download"))
+
+	do("DELETE")
+	assert.Equal(t, "key", got.Get("X-Key"))
+	assert.Equal(t, "", got.Get("X-Upload"))
+	assert.Equal(t, "", got.Get("X-Download"))
+}
+
+func TestTransportDump(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(ioutil.Discard, r.Body)
+		w.Header().Set("Range", "bytes=0-1023")
+		w.Header().Set("Location", "http://example.com/session")
+		w.WriteHeader(308)
+	}))
+	defer ts.Close()
+
+	var logged bytes.Buffer
+	oldLogPrint, oldLogLevel := fs.LogPrint, fs.Config.LogLevel
+	fs.LogPrint = func(level fs.LogLevel, text string) {
+		logged.WriteString(text + "\n")
+	}
+	fs.Config.LogLevel = fs.LogLevelDebug
+	defer func() {
+		fs.LogPrint, fs.Config.LogLevel = oldLogPrint, oldLogLevel
+	}()
+
+	do := func(dump fs.DumpFlags, size int) string {
+		logged.Reset()
+		ci := *fs.Config
+		ci.Dump = dump
+		client := &http.Client{
+			Transport: NewTransportCustom(&ci, nil),
+			// Read the 308 rather than following its Location
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		body := bytes.Repeat([]byte("x"), size)
+		req, err := http.NewRequest("PUT", ts.URL, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes 0-%d/*", size-1))
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return logged.String()
+	}
+
+	// Headers of the request and response without the body
+	out := do(fs.DumpHeaders, 1024)
+	assert.Contains(t, out, "Content-Range: bytes 0-1023/*")
+	assert.Contains(t, out, "HTTP/1.1 308")
+	assert.Contains(t, out, "Range: bytes=0-1023")
+	assert.Contains(t, out, "Location: http://example.com/session")
+	assert.Contains(t, out, "Authorization: XXXX")
+	assert.NotContains(t, out, "secret-token")
+	assert.NotContains(t, out, "xxxx")
+
+	// Small bodies are dumped
+	out = do(fs.DumpBodies, 1024)
+	assert.Contains(t, out, strings.Repeat("x", 1024))
+
+	// Big bodies aren't
+	out = do(fs.DumpBodies, maxDumpBody+1)
+	assert.Contains(t, out, "Content-Range: bytes 0-65536/*")
+	assert.Contains(t, out, fmt.Sprintf("[body of %d bytes not dumped]", maxDumpBody+1))
+	assert.NotContains(t, out, "xxxx")
+}
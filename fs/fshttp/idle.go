@@ -0,0 +1,90 @@
+package fshttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// idleTimeoutError is a timeout error like those from net so it is
+// retried by fserrors.ShouldRetry
+type idleTimeoutError struct{}
+
+// Error interface
+func (idleTimeoutError) Error() string {
+	return "request aborted as no data was sent for --read-idle-timeout"
+}
+
+// Timeout returns true as this is a timeout
+func (idleTimeoutError) Timeout() bool {
+	return true
+}
+
+// ErrorIdleTimeout is returned for requests made with WithIdleTimeout
+// which were aborted as no data was sent for the timeout.  It is a
+// timeout error so the request is retried by the pacer.
+var ErrorIdleTimeout error = idleTimeoutError{}
+
+// idleReader wraps the body of a request nudging the idle timer each
+// time some of it is read
+type idleReader struct {
+	in      io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+// Read bytes from the body and nudge the timer on, stopping it once
+// all the body has been read
+func (r *idleReader) Read(p []byte) (n int, err error) {
+	n, err = r.in.Read(p)
+	if err == io.EOF {
+		r.timer.Stop()
+	} else if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// Close the body
+func (r *idleReader) Close() error {
+	return r.in.Close()
+}
+
+// WithIdleTimeout returns req changed so it is aborted if none of its
+// body is sent for timeout.  A stalled connection is found this way
+// much sooner than with --timeout.
+//
+// The timer stops once all the body has been sent, so the server can
+// take as long as it likes to respond.
+//
+// done must be called with the error from the request once the
+// response has been read.  It stops the timer and returns
+// ErrorIdleTimeout if the request failed because it was aborted,
+// otherwise err.
+//
+// If timeout is 0 then req is returned unchanged.
+func WithIdleTimeout(req *http.Request, timeout time.Duration) (newReq *http.Request, done func(err error) error) {
+	if timeout <= 0 {
+		return req, func(err error) error { return err }
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	var fired int32
+	timer := time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&fired, 1)
+		cancel()
+	})
+	newReq = req.WithContext(ctx)
+	if req.Body != nil {
+		newReq.Body = &idleReader{in: req.Body, timer: timer, timeout: timeout}
+	}
+	return newReq, func(err error) error {
+		timer.Stop()
+		cancel()
+		if err != nil && atomic.LoadInt32(&fired) != 0 {
+			return ErrorIdleTimeout
+		}
+		return err
+	}
+}
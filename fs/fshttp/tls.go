@@ -0,0 +1,70 @@
+package fshttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// TLSOptions are the certificates a backend can use for its own
+// connections in place of the system ones.  All the fields are paths
+// to PEM files and may be empty.
+type TLSOptions struct {
+	CACert     string // CA certificates to verify the server with, may be a bundle
+	ClientCert string // client certificate for mutual TLS
+	ClientKey  string // key for ClientCert
+}
+
+// IsSet returns whether any of the options are set
+func (opt *TLSOptions) IsSet() bool {
+	return opt.CACert != "" || opt.ClientCert != "" || opt.ClientKey != ""
+}
+
+// tlsConfig makes the tls.Config for the options
+func (opt *TLSOptions) tlsConfig(ci *fs.ConfigInfo) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: ci.InsecureSkipVerify}
+	if opt.CACert != "" {
+		pem, err := ioutil.ReadFile(opt.CACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA certificate")
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in CA certificate file %q", opt.CACert)
+		}
+	}
+	if (opt.ClientCert == "") != (opt.ClientKey == "") {
+		return nil, errors.New("need both client certificate and client key for mutual TLS")
+	}
+	if opt.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(opt.ClientCert, opt.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// NewClientTLS returns an http.Client which uses the certificates in
+// opt.  This has its own transport so the certificates only affect
+// the backend using it.
+//
+// If opt has nothing set then it returns the shared client from
+// NewClient.
+func NewClientTLS(ci *fs.ConfigInfo, opt TLSOptions) (*http.Client, error) {
+	if !opt.IsSet() {
+		return NewClient(ci), nil
+	}
+	config, err := opt.tlsConfig(ci)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientCustom(ci, func(t *http.Transport) {
+		t.TLSClientConfig = config
+	}), nil
+}
@@ -0,0 +1,63 @@
+package fshttp
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClientTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "rclone-fshttp")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	caCert := filepath.Join(dir, "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(caCert, certPEM, 0600))
+
+	ci := *fs.Config
+	ci.InsecureSkipVerify = false
+
+	// Nothing set gives the shared client
+	client, err := NewClientTLS(&ci, TLSOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, NewTransport(&ci), client.Transport)
+
+	// The self signed server isn't trusted without the CA
+	client = NewClientCustom(&ci, nil)
+	_, err = client.Get(ts.URL)
+	assert.Error(t, err)
+
+	client, err = NewClientTLS(&ci, TLSOptions{CACert: caCert})
+	require.NoError(t, err)
+	res, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, "hello", string(body))
+
+	// Errors
+	_, err = NewClientTLS(&ci, TLSOptions{CACert: filepath.Join(dir, "notfound.pem")})
+	assert.Error(t, err)
+	notPEM := filepath.Join(dir, "not.pem")
+	require.NoError(t, ioutil.WriteFile(notPEM, []byte("potato"), 0600))
+	_, err = NewClientTLS(&ci, TLSOptions{CACert: notPEM})
+	assert.Error(t, err)
+	_, err = NewClientTLS(&ci, TLSOptions{ClientCert: caCert})
+	assert.Error(t, err)
+}
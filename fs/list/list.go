@@ -34,6 +34,42 @@ func DirSorted(f fs.Fs, includeAll bool, dir string) (entries fs.DirEntries, err
 	return filterAndSortDir(entries, includeAll, dir, filter.Active.IncludeObject, filter.Active.IncludeDirectory(f))
 }
 
+// DirPaged calls callback with the Object and *Dir entries of dir in
+// f passing the filter, a page at a time.
+//
+// If f supports ListP then the pages are passed on as they are read
+// so the directory doesn't have to be held in memory, and the entries
+// are only sorted within each page.  Otherwise the whole directory is
+// read with List and passed to callback as one sorted page.
+func DirPaged(f fs.Fs, includeAll bool, dir string, callback fs.ListRCallback) error {
+	listP := f.Features().ListP
+	if listP == nil {
+		entries, err := DirSorted(f, includeAll, dir)
+		if err != nil {
+			return err
+		}
+		return callback(entries)
+	}
+	if !includeAll {
+		excluded, err := filter.Active.DirContainsExcludeFile(f, dir)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			fs.Debugf(dir, "Excluded from sync (and deletion)")
+			return nil
+		}
+	}
+	includeDirectory := filter.Active.IncludeDirectory(f)
+	return listP(dir, func(entries fs.DirEntries) error {
+		entries, err := filterAndSortDir(entries, includeAll, dir, filter.Active.IncludeObject, includeDirectory)
+		if err != nil {
+			return err
+		}
+		return callback(entries)
+	})
+}
+
 // filter (if required) and check the entries, then sort them
 func filterAndSortDir(entries fs.DirEntries, includeAll bool, dir string,
 	IncludeObject func(o fs.Object) bool,
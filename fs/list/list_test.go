@@ -102,3 +102,56 @@ func TestFilterAndSortUnknown(t *testing.T) {
 	assert.Error(t, err, "error")
 	assert.Nil(t, newEntries)
 }
+
+// pagedFs is a minimal fs.Fs which lists a page at a time
+type pagedFs struct {
+	fs.Fs
+	pages []fs.DirEntries
+	paged bool
+}
+
+func (f *pagedFs) Features() *fs.Features {
+	ft := (&fs.Features{}).Fill(f)
+	if !f.paged {
+		ft.ListP = nil
+	}
+	return ft
+}
+
+func (f *pagedFs) List(dir string) (entries fs.DirEntries, err error) {
+	for _, page := range f.pages {
+		entries = append(entries, page...)
+	}
+	return entries, nil
+}
+
+func (f *pagedFs) ListP(dir string, callback fs.ListRCallback) error {
+	for _, page := range f.pages {
+		err := callback(append(fs.DirEntries(nil), page...))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDirPaged(t *testing.T) {
+	oA := mockobject.Object("A")
+	oB := mockobject.Object("B")
+	oC := mockobject.Object("C")
+	da := mockdir.New("a")
+	f := &pagedFs{pages: []fs.DirEntries{{oC, da}, {oB, oA}}}
+
+	var got []fs.DirEntries
+	callback := func(entries fs.DirEntries) error {
+		got = append(got, entries)
+		return nil
+	}
+	require.NoError(t, DirPaged(f, true, "", callback))
+	assert.Equal(t, []fs.DirEntries{{oA, oB, oC, da}}, got)
+
+	f.paged = true
+	got = nil
+	require.NoError(t, DirPaged(f, true, "", callback))
+	assert.Equal(t, []fs.DirEntries{{oC, da}, {oA, oB}}, got)
+}
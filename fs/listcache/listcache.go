@@ -0,0 +1,650 @@
+// Package listcache implements a persistent cache of directory
+// listings which can wrap any Fs.
+//
+// Listings are stored in a bolt database in the cache directory keyed
+// by the name of the remote and the full path of the directory.  They
+// are used until they are older than --list-cache-time, or ignored if
+// --refresh is set.  Anything written through the wrapped Fs
+// invalidates the listings of the directories it touches.
+package listcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+// Buckets used in the database
+const (
+	listBucket  = "list"  // listings made with List
+	listRBucket = "listr" // listings made with ListR
+)
+
+// Globals
+var (
+	dbPath = filepath.Join(config.CacheDir, "list-cache.db")
+	dbMu   sync.Mutex
+	db     *bolt.DB
+)
+
+// openDB opens the database if it isn't already open
+func openDB() (*bolt.DB, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	if db != nil {
+		return db, nil
+	}
+	err := os.MkdirAll(filepath.Dir(dbPath), 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make listing cache directory")
+	}
+	newDB, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open listing cache %q", dbPath)
+	}
+	err = newDB.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{listBucket, listRBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = newDB.Close()
+		return nil, errors.Wrap(err, "failed to initialise listing cache")
+	}
+	db = newDB
+	return db, nil
+}
+
+// closeDB closes the database if it is open
+func closeDB() error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	if db == nil {
+		return nil
+	}
+	err := db.Close()
+	db = nil
+	return err
+}
+
+// Wrap returns f wrapped in a listing cache if --list-cache-time is
+// set, otherwise f unchanged.
+//
+// If the cache can't be opened then it logs an error and returns f.
+func Wrap(f fs.Fs) fs.Fs {
+	if fs.Config.ListCacheTime <= 0 || f == nil {
+		return f
+	}
+	db, err := openDB()
+	if err != nil {
+		fs.Errorf(f, "Not caching listings: %v", err)
+		return f
+	}
+	return newFs(f, db)
+}
+
+// Fs represents a wrapped fs.Fs with its listings cached
+type Fs struct {
+	fs.Fs
+	db       *bolt.DB
+	features *fs.Features // optional features
+}
+
+// newFs makes a new listing cache wrapping wrappedFs
+func newFs(wrappedFs fs.Fs, db *bolt.DB) *Fs {
+	f := &Fs{
+		Fs: wrappedFs,
+		db: db,
+	}
+	// Pass through the features of the wrapped Fs, overriding
+	// those which list or write so the cache is kept up to date
+	features := *wrappedFs.Features()
+	ft := &features
+	if ft.Purge != nil {
+		ft.Purge = f.Purge
+	}
+	if ft.Copy != nil {
+		ft.Copy = f.Copy
+	}
+	if ft.Move != nil {
+		ft.Move = f.Move
+	}
+	if ft.DirMove != nil {
+		ft.DirMove = f.DirMove
+	}
+	if ft.PutUnchecked != nil {
+		ft.PutUnchecked = f.PutUnchecked
+	}
+	if ft.PutStream != nil {
+		ft.PutStream = f.PutStream
+	}
+	if ft.MergeDirs != nil {
+		ft.MergeDirs = f.MergeDirs
+	}
+	if ft.ListR != nil {
+		ft.ListR = f.ListR
+	}
+	// Paged listings aren't cached so use List instead
+	ft.ListP = nil
+	if ft.RemoveBatch != nil {
+		ft.RemoveBatch = f.RemoveBatch
+	}
+	if ft.OpenWriterAt != nil {
+		ft.OpenWriterAt = f.OpenWriterAt
+	}
+	ft.UnWrap = f.UnWrap
+	f.features = ft.WrapsFs(f, wrappedFs)
+	return f
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// UnWrap returns the Fs that this Fs is wrapping
+func (f *Fs) UnWrap() fs.Fs {
+	return f.Fs
+}
+
+// cacheItem is a single directory entry as stored in the cache
+type cacheItem struct {
+	Remote  string
+	Dir     bool      `json:",omitempty"`
+	Size    int64     // size or -1 if unknown
+	Items   int64     `json:",omitempty"` // number of items in a directory or -1 if unknown
+	ModTime time.Time // modification time
+	ID      string    `json:",omitempty"` // directory ID if known
+}
+
+// cacheEntry is a directory listing as stored in the cache
+type cacheEntry struct {
+	Time    time.Time // when the listing was read
+	Entries []cacheItem
+}
+
+// key returns the database key for dir
+func (f *Fs) key(dir string) string {
+	return f.Name() + ":" + path.Join("/", f.Root(), dir)
+}
+
+// get returns the cached listing of dir from bucket if it is present
+// and fresh enough.
+func (f *Fs) get(bucket, dir string) (entries fs.DirEntries, ok bool) {
+	if fs.Config.Refresh {
+		return nil, false
+	}
+	var entry cacheEntry
+	err := f.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(bucket)).Get([]byte(f.key(dir)))
+		if data == nil {
+			return errors.New("not found")
+		}
+		return json.Unmarshal(data, &entry)
+	})
+	if err != nil || time.Since(entry.Time) > fs.Config.ListCacheTime {
+		return nil, false
+	}
+	entries = make(fs.DirEntries, 0, len(entry.Entries))
+	for _, item := range entry.Entries {
+		if item.Dir {
+			d := fs.NewDir(item.Remote, item.ModTime).SetSize(item.Size).SetItems(item.Items).SetID(item.ID)
+			entries = append(entries, d)
+		} else {
+			entries = append(entries, &Object{
+				f:       f,
+				remote:  item.Remote,
+				size:    item.Size,
+				modTime: item.ModTime,
+			})
+		}
+	}
+	fs.Debugf(f, "Using cached listing of %q from %v", dir, entry.Time)
+	return entries, true
+}
+
+// put stores the listing of dir in bucket
+func (f *Fs) put(bucket, dir string, entries fs.DirEntries) {
+	entry := cacheEntry{
+		Time:    time.Now(),
+		Entries: make([]cacheItem, 0, len(entries)),
+	}
+	for _, e := range entries {
+		item := cacheItem{
+			Remote:  e.Remote(),
+			Size:    e.Size(),
+			ModTime: e.ModTime(),
+		}
+		if d, isDir := e.(fs.Directory); isDir {
+			item.Dir = true
+			item.Items = d.Items()
+			item.ID = d.ID()
+		}
+		entry.Entries = append(entry.Entries, item)
+	}
+	data, err := json.Marshal(&entry)
+	if err == nil {
+		err = f.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte(bucket)).Put([]byte(f.key(dir)), data)
+		})
+	}
+	if err != nil {
+		fs.Errorf(f, "Failed to cache listing of %q: %v", dir, err)
+	}
+}
+
+// invalidate removes the cached listings of dir and all its parents.
+//
+// If recurse is set then the cached listings of everything below dir
+// are removed too.
+func (f *Fs) invalidate(dir string, recurse bool) {
+	key := f.key(dir)
+	err := f.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{listBucket, listRBucket} {
+			b := tx.Bucket([]byte(bucket))
+			if recurse {
+				prefix := []byte(strings.TrimSuffix(key, "/") + "/")
+				var keys [][]byte
+				c := b.Cursor()
+				for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+					keys = append(keys, append([]byte(nil), k...))
+				}
+				for _, k := range keys {
+					if err := b.Delete(k); err != nil {
+						return err
+					}
+				}
+			}
+			for parent := key; ; {
+				if err := b.Delete([]byte(parent)); err != nil {
+					return err
+				}
+				i := strings.LastIndex(parent, "/")
+				if i < 0 || parent[i:] == "/" {
+					break
+				}
+				parent = parent[:i]
+				if strings.HasSuffix(parent, ":") {
+					parent += "/"
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fs.Errorf(f, "Failed to invalidate cached listing of %q: %v", dir, err)
+	}
+}
+
+// invalidateParent invalidates the directory containing remote
+func (f *Fs) invalidateParent(remote string) {
+	dir := path.Dir(remote)
+	if dir == "." {
+		dir = ""
+	}
+	f.invalidate(dir, false)
+}
+
+// wrapEntries wraps the objects in entries so writes to them
+// invalidate the cache
+func (f *Fs) wrapEntries(entries fs.DirEntries) fs.DirEntries {
+	for i, entry := range entries {
+		if o, ok := entry.(fs.Object); ok {
+			entries[i] = f.newObject(o)
+		}
+	}
+	return entries
+}
+
+// List the objects and directories in dir into entries, using the
+// cached listing if it is fresh enough.
+func (f *Fs) List(dir string) (entries fs.DirEntries, err error) {
+	if entries, ok := f.get(listBucket, dir); ok {
+		return entries, nil
+	}
+	entries, err = f.Fs.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	f.put(listBucket, dir, entries)
+	return f.wrapEntries(entries), nil
+}
+
+// ListR lists the objects and directories of the Fs starting from
+// dir recursively into out, using the cached listing if it is fresh
+// enough.
+func (f *Fs) ListR(dir string, callback fs.ListRCallback) error {
+	if entries, ok := f.get(listRBucket, dir); ok {
+		return callback(entries)
+	}
+	var all fs.DirEntries
+	err := f.Fs.Features().ListR(dir, func(entries fs.DirEntries) error {
+		all = append(all, entries...)
+		return callback(f.wrapEntries(entries))
+	})
+	if err != nil {
+		return err
+	}
+	f.put(listRBucket, dir, all)
+	return nil
+}
+
+// NewObject finds the Object at remote.
+func (f *Fs) NewObject(remote string) (fs.Object, error) {
+	o, err := f.Fs.NewObject(remote)
+	if err != nil {
+		return nil, err
+	}
+	return f.newObject(o), nil
+}
+
+// Put in to the remote path with the modTime given of the given size
+func (f *Fs) Put(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	defer f.invalidateParent(src.Remote())
+	o, err := f.Fs.Put(in, src, options...)
+	if o != nil {
+		o = f.newObject(o)
+	}
+	return o, err
+}
+
+// PutUnchecked uploads to the remote path with the modTime given of
+// the given size without checking for duplicates
+func (f *Fs) PutUnchecked(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	defer f.invalidateParent(src.Remote())
+	o, err := f.Fs.Features().PutUnchecked(in, src, options...)
+	if o != nil {
+		o = f.newObject(o)
+	}
+	return o, err
+}
+
+// PutStream uploads to the remote path with the modTime given of
+// indeterminate size
+func (f *Fs) PutStream(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	defer f.invalidateParent(src.Remote())
+	o, err := f.Fs.Features().PutStream(in, src, options...)
+	if o != nil {
+		o = f.newObject(o)
+	}
+	return o, err
+}
+
+// Mkdir makes the directory (container, bucket)
+func (f *Fs) Mkdir(dir string) error {
+	defer f.invalidate(dir, false)
+	return f.Fs.Mkdir(dir)
+}
+
+// Rmdir removes the directory (container, bucket) if empty
+func (f *Fs) Rmdir(dir string) error {
+	defer f.invalidate(dir, true)
+	return f.Fs.Rmdir(dir)
+}
+
+// Purge all files in the root and the root directory
+func (f *Fs) Purge() error {
+	defer f.invalidate("", true)
+	return f.Fs.Features().Purge()
+}
+
+// unwrapObject returns the underlying object if o is from a listing
+// cache
+func unwrapObject(o fs.Object) (fs.Object, error) {
+	if do, ok := o.(*Object); ok {
+		return do.getObject()
+	}
+	return o, nil
+}
+
+// Copy src to this remote using server side copy operations.
+func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
+	srcObj, err := unwrapObject(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.invalidateParent(remote)
+	o, err := f.Fs.Features().Copy(srcObj, remote)
+	if err != nil {
+		return nil, err
+	}
+	return f.newObject(o), nil
+}
+
+// Move src to this remote using server side move operations.
+func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
+	srcObj, err := unwrapObject(src)
+	if err != nil {
+		return nil, err
+	}
+	if srcFs, ok := src.Fs().(*Fs); ok {
+		defer srcFs.invalidateParent(src.Remote())
+	}
+	defer f.invalidateParent(remote)
+	o, err := f.Fs.Features().Move(srcObj, remote)
+	if err != nil {
+		return nil, err
+	}
+	return f.newObject(o), nil
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote using
+// server side move operations.
+func (f *Fs) DirMove(src fs.Fs, srcRemote, dstRemote string) error {
+	if srcFs, ok := src.(*Fs); ok {
+		src = srcFs.Fs
+		defer srcFs.invalidate(srcRemote, true)
+	}
+	defer f.invalidate(dstRemote, true)
+	return f.Fs.Features().DirMove(src, srcRemote, dstRemote)
+}
+
+// MergeDirs merges the contents of all the directories passed in into
+// the first one and rmdirs the other directories.
+func (f *Fs) MergeDirs(dirs []fs.Directory) error {
+	for _, dir := range dirs {
+		defer f.invalidate(dir.Remote(), true)
+	}
+	return f.Fs.Features().MergeDirs(dirs)
+}
+
+// RemoveBatch removes all the objects passed in
+func (f *Fs) RemoveBatch(objs []fs.Object) []error {
+	unwrapped := make([]fs.Object, len(objs))
+	for i, o := range objs {
+		defer f.invalidateParent(o.Remote())
+		srcObj, err := unwrapObject(o)
+		if err != nil {
+			srcObj = o
+		}
+		unwrapped[i] = srcObj
+	}
+	return f.Fs.Features().RemoveBatch(unwrapped)
+}
+
+// OpenWriterAt opens with a handle for random access writes
+func (f *Fs) OpenWriterAt(remote string, size int64) (fs.WriterAtCloser, error) {
+	defer f.invalidateParent(remote)
+	return f.Fs.Features().OpenWriterAt(remote, size)
+}
+
+// Object describes an object read through the listing cache.
+//
+// Objects read from the cache only know their metadata, so the
+// underlying object is only found when it is needed.
+type Object struct {
+	f       *Fs
+	remote  string
+	size    int64
+	modTime time.Time
+	mu      sync.Mutex
+	o       fs.Object // the wrapped object, nil if not found yet
+}
+
+// newObject wraps o
+func (f *Fs) newObject(o fs.Object) *Object {
+	return &Object{
+		f:       f,
+		remote:  o.Remote(),
+		size:    o.Size(),
+		modTime: o.ModTime(),
+		o:       o,
+	}
+}
+
+// getObject returns the wrapped object, finding it if necessary
+func (o *Object) getObject() (fs.Object, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.o == nil {
+		obj, err := o.f.Fs.NewObject(o.remote)
+		if err != nil {
+			return nil, err
+		}
+		o.o = obj
+	}
+	return o.o, nil
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.f
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Size returns the size of the file
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// ModTime returns the modification time of the object
+func (o *Object) ModTime() time.Time {
+	return o.modTime
+}
+
+// Storable returns whether this object is storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Hash returns the selected checksum of the file
+func (o *Object) Hash(ht hash.Type) (string, error) {
+	obj, err := o.getObject()
+	if err != nil {
+		return "", err
+	}
+	return obj.Hash(ht)
+}
+
+// SetModTime sets the modification time of the object
+func (o *Object) SetModTime(modTime time.Time) error {
+	obj, err := o.getObject()
+	if err != nil {
+		return err
+	}
+	defer o.f.invalidateParent(o.remote)
+	err = obj.SetModTime(modTime)
+	if err == nil {
+		o.modTime = modTime
+	}
+	return err
+}
+
+// Open opens the file for read.  Call Close() on the returned io.ReadCloser
+func (o *Object) Open(options ...fs.OpenOption) (io.ReadCloser, error) {
+	obj, err := o.getObject()
+	if err != nil {
+		return nil, err
+	}
+	return obj.Open(options...)
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Object) Update(in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	obj, err := o.getObject()
+	if err != nil {
+		return err
+	}
+	defer o.f.invalidateParent(o.remote)
+	err = obj.Update(in, src, options...)
+	o.size = obj.Size()
+	o.modTime = obj.ModTime()
+	return err
+}
+
+// Remove an object
+func (o *Object) Remove() error {
+	obj, err := o.getObject()
+	if err != nil {
+		return err
+	}
+	defer o.f.invalidateParent(o.remote)
+	return obj.Remove()
+}
+
+// MimeType returns the content type of the Object if known
+func (o *Object) MimeType() string {
+	obj, err := o.getObject()
+	if err != nil {
+		return ""
+	}
+	if do, ok := obj.(fs.MimeTyper); ok {
+		return do.MimeType()
+	}
+	return ""
+}
+
+// UnWrap returns the wrapped Object
+func (o *Object) UnWrap() fs.Object {
+	obj, err := o.getObject()
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.Purger          = (*Fs)(nil)
+	_ fs.Copier          = (*Fs)(nil)
+	_ fs.Mover           = (*Fs)(nil)
+	_ fs.DirMover        = (*Fs)(nil)
+	_ fs.PutUncheckeder  = (*Fs)(nil)
+	_ fs.PutStreamer     = (*Fs)(nil)
+	_ fs.MergeDirser     = (*Fs)(nil)
+	_ fs.ListRer         = (*Fs)(nil)
+	_ fs.BatchRemover    = (*Fs)(nil)
+	_ fs.OpenWriterAter  = (*Fs)(nil)
+	_ fs.UnWrapper       = (*Fs)(nil)
+	_ fs.Object          = (*Object)(nil)
+	_ fs.MimeTyper       = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+)
@@ -0,0 +1,110 @@
+package listcache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listNames returns the names of the entries in dir of f
+func listNames(t *testing.T, f fs.Fs, dir string) (names []string) {
+	entries, err := f.List(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		names = append(names, entry.Remote())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestListCache(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "rclone-listcache")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+	oldDBPath, oldListCacheTime, oldRefresh := dbPath, fs.Config.ListCacheTime, fs.Config.Refresh
+	defer func() {
+		require.NoError(t, closeDB())
+		dbPath, fs.Config.ListCacheTime, fs.Config.Refresh = oldDBPath, oldListCacheTime, oldRefresh
+	}()
+	dbPath = filepath.Join(tmp, "cache", "list-cache.db")
+	root := filepath.Join(tmp, "root")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "dir", "a"), []byte("a"), 0666))
+
+	wrappedFs, err := fs.NewFs(root)
+	require.NoError(t, err)
+
+	// Not wrapped without --list-cache-time
+	fs.Config.ListCacheTime = 0
+	assert.Equal(t, wrappedFs, Wrap(wrappedFs))
+
+	fs.Config.ListCacheTime = time.Hour
+	f := Wrap(wrappedFs)
+	require.IsType(t, &Fs{}, f)
+	assert.Equal(t, wrappedFs, f.Features().UnWrap())
+
+	assert.Equal(t, []string{"dir/a"}, listNames(t, f, "dir"))
+
+	// Changes made behind our back aren't seen
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "dir", "b"), []byte("b"), 0666))
+	assert.Equal(t, []string{"dir/a"}, listNames(t, f, "dir"))
+
+	// Objects from the cache can still be read
+	entries, err := f.List("dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	o := entries[0].(fs.Object)
+	assert.Equal(t, int64(1), o.Size())
+	in, err := o.Open()
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, "a", string(data))
+
+	// Unless --refresh is set
+	fs.Config.Refresh = true
+	assert.Equal(t, []string{"dir/a", "dir/b"}, listNames(t, f, "dir"))
+	fs.Config.Refresh = false
+
+	// Or the listing has expired
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "dir", "c"), []byte("c"), 0666))
+	assert.Equal(t, []string{"dir/a", "dir/b"}, listNames(t, f, "dir"))
+	fs.Config.ListCacheTime = time.Nanosecond
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c"}, listNames(t, f, "dir"))
+	fs.Config.ListCacheTime = time.Hour
+
+	// Another Fs with the same root shares the cache
+	require.NoError(t, os.Remove(filepath.Join(root, "dir", "c")))
+	f2 := Wrap(wrappedFs)
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c"}, listNames(t, f2, "dir"))
+
+	// Uploading invalidates the directory and its parents
+	_ = listNames(t, f, "")
+	src := object.NewStaticObjectInfo("dir/d", time.Now(), 1, true, nil, nil)
+	_, err = f.Put(bytes.NewBufferString("d"), src)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/d"}, listNames(t, f, "dir"))
+
+	// As does deleting
+	o, err = f.NewObject("dir/a")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove())
+	assert.Equal(t, []string{"dir/b", "dir/d"}, listNames(t, f, "dir"))
+
+	// And making directories
+	require.NoError(t, f.Mkdir("dir2"))
+	assert.Equal(t, []string{"dir", "dir2"}, listNames(t, f, ""))
+}
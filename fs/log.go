@@ -73,8 +73,21 @@ var LogPrint = func(level LogLevel, text string) {
 	log.Print(text)
 }
 
+// LogErrorf is called with the unformatted arguments of each log
+// message at ERROR level or above.  If it returns true then the
+// message has been dealt with and isn't passed on to LogPrint.
+//
+// This is a function pointer so structured error output can be
+// plugged in by the log package.
+var LogErrorf = func(level LogLevel, o interface{}, text string, args ...interface{}) bool {
+	return false
+}
+
 // LogPrintf produces a log string from the arguments passed in
 func LogPrintf(level LogLevel, o interface{}, text string, args ...interface{}) {
+	if level <= LogLevelError && LogErrorf(level, o, text, args...) {
+		return
+	}
 	out := fmt.Sprintf(text, args...)
 	if o != nil {
 		out = fmt.Sprintf("%v: %s", o, out)
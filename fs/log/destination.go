@@ -0,0 +1,127 @@
+// Log destinations for --log-destination
+
+package log
+
+import (
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// logDestination is a parsed --log-destination
+type logDestination struct {
+	kind string // syslog, journald or file
+
+	// syslog
+	network  string // network to reach the syslog server on, "" for local
+	raddr    string // address of the syslog server, "" for local
+	facility string // syslog facility
+
+	// file
+	path       string        // name of the log file
+	maxSize    int64         // rotate when the file would be bigger than this, 0 for no limit
+	maxAge     time.Duration // rotate when the file has been written for this long, 0 for no limit
+	maxBackups int           // number of old files to keep, 0 to keep them all
+}
+
+// windowsDriveRE matches the path of a file URL with a drive letter
+var windowsDriveRE = regexp.MustCompile(`^/[A-Za-z]:`)
+
+// parseLogDestination parses dest which is one of
+//
+//	syslog
+//	syslog://host:port?facility=NAME
+//	syslog+tcp://host:port?facility=NAME
+//	journald
+//	file:///path?maxsize=SIZE&maxbackups=N&maxage=TIME
+func parseLogDestination(dest string) (d logDestination, err error) {
+	if !strings.Contains(dest, "://") {
+		dest += "://"
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return d, errors.Wrap(err, "bad --log-destination")
+	}
+	query := u.Query()
+	switch u.Scheme {
+	case "syslog", "syslog+udp", "syslog+tcp":
+		d.kind = "syslog"
+		d.facility = *syslogFacility
+		if u.Host != "" {
+			d.network = "udp"
+			if u.Scheme == "syslog+tcp" {
+				d.network = "tcp"
+			}
+			d.raddr = u.Host
+		}
+		for key, values := range query {
+			switch key {
+			case "facility":
+				d.facility = strings.ToUpper(values[0])
+			default:
+				return d, errors.Errorf("unknown --log-destination syslog parameter %q", key)
+			}
+		}
+	case "journald":
+		d.kind = "journald"
+		if len(query) > 0 || u.Host != "" || u.Path != "" {
+			return d, errors.New("--log-destination journald doesn't take any parameters")
+		}
+	case "file":
+		d.kind = "file"
+		d.path = u.Host + u.Path
+		if windowsDriveRE.MatchString(d.path) {
+			d.path = d.path[1:]
+		}
+		if d.path == "" {
+			return d, errors.New("--log-destination file needs a path, eg file:///var/log/rclone.log")
+		}
+		for key, values := range query {
+			value := values[0]
+			switch key {
+			case "maxsize":
+				var size fs.SizeSuffix
+				err = size.Set(value)
+				d.maxSize = int64(size)
+			case "maxage":
+				d.maxAge, err = fs.ParseDuration(value)
+			case "maxbackups":
+				d.maxBackups, err = strconv.Atoi(value)
+			default:
+				return d, errors.Errorf("unknown --log-destination file parameter %q", key)
+			}
+			if err != nil {
+				return d, errors.Wrapf(err, "bad --log-destination file parameter %q", key)
+			}
+		}
+	default:
+		return d, errors.Errorf("unknown --log-destination %q - use syslog, journald or file", u.Scheme)
+	}
+	return d, nil
+}
+
+// startLogDestination starts logging to the --log-destination
+func startLogDestination() {
+	d, err := parseLogDestination(*logDest)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	switch d.kind {
+	case "syslog":
+		startSysLog(d.network, d.raddr, d.facility)
+	case "journald":
+		startJournald()
+	case "file":
+		r, err := newRotatingFile(d.path, d.maxSize, d.maxAge, d.maxBackups)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		log.SetOutput(r)
+	}
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogDestination(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want logDestination
+		err  bool
+	}{
+		{"syslog", logDestination{kind: "syslog", facility: "DAEMON"}, false},
+		{"syslog://", logDestination{kind: "syslog", facility: "DAEMON"}, false},
+		{"syslog://logs.example.com:514?facility=user", logDestination{kind: "syslog", network: "udp", raddr: "logs.example.com:514", facility: "USER"}, false},
+		{"syslog+tcp://logs.example.com:601", logDestination{kind: "syslog", network: "tcp", raddr: "logs.example.com:601", facility: "DAEMON"}, false},
+		{"syslog://?potato=1", logDestination{}, true},
+		{"journald", logDestination{kind: "journald"}, false},
+		{"journald://?facility=user", logDestination{}, true},
+		{"file:///var/log/rclone.log", logDestination{kind: "file", path: "/var/log/rclone.log"}, false},
+		{"file:///C:/logs/rclone.log", logDestination{kind: "file", path: "C:/logs/rclone.log"}, false},
+		{"file://rclone.log", logDestination{kind: "file", path: "rclone.log"}, false},
+		{"file:///var/log/rclone.log?maxsize=10M&maxbackups=5&maxage=1d", logDestination{kind: "file", path: "/var/log/rclone.log", maxSize: 10 << 20, maxBackups: 5, maxAge: 24 * time.Hour}, false},
+		{"file:///var/log/rclone.log?maxsize=potato", logDestination{}, true},
+		{"file:///var/log/rclone.log?potato=1", logDestination{}, true},
+		{"file://", logDestination{}, true},
+		{"potato://", logDestination{}, true},
+	} {
+		got, err := parseLogDestination(test.in)
+		if test.err {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		assert.NoError(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestJournaldMessage(t *testing.T) {
+	assert.Equal(t, "PRIORITY=3\nSYSLOG_IDENTIFIER=rclone\nMESSAGE=hello\n", string(journaldMessage(fs.LogLevelError, "rclone", "hello")))
+	assert.Equal(t, "PRIORITY=7\nSYSLOG_IDENTIFIER=rclone\nMESSAGE\n\x0b\x00\x00\x00\x00\x00\x00\x00hello\nworld\n", string(journaldMessage(fs.LogLevelDebug, "rclone", "hello\nworld")))
+}
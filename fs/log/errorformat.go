@@ -0,0 +1,153 @@
+// Structured error output
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/flags"
+	"github.com/ncw/rclone/fs/fserrors"
+)
+
+// Flags
+var (
+	errorFormat = flags.StringP("error-format", "", "text", "Format to write errors in text|json")
+	errorFile   = flags.StringP("error-file", "", "", "Write json errors to this file instead of stderr")
+)
+
+var (
+	errorOutMu sync.Mutex
+	errorOut   io.Writer // where json errors are written if set
+)
+
+// jsonError is the output of one error with --error-format json
+type jsonError struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Object    string `json:"object,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	Message   string `json:"message"`
+	Error     string `json:"error,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Retryable bool   `json:"retryable"`
+	Fatal     bool   `json:"fatal"`
+}
+
+// jsonSummary is the final summary with --error-format json
+type jsonSummary struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Summary   bool   `json:"summary"`
+	Errors    int64  `json:"errors"`
+	Checks    int64  `json:"checks"`
+	Transfers int64  `json:"transfers"`
+	Bytes     int64  `json:"bytes"`
+	Error     string `json:"error,omitempty"`
+}
+
+// startErrorFormat sets up the --error-format output
+func startErrorFormat() {
+	switch *errorFormat {
+	case "text":
+		if *errorFile != "" {
+			log.Fatalf("Can only use --error-file with --error-format json")
+		}
+		return
+	case "json":
+	default:
+		log.Fatalf("Unknown --error-format %q - use text or json", *errorFormat)
+	}
+	out := io.Writer(os.Stderr)
+	if *errorFile != "" {
+		f, err := os.OpenFile(*errorFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			log.Fatalf("Failed to open error file: %v", err)
+		}
+		out = f
+	}
+	setErrorOutput(out)
+}
+
+// setErrorOutput makes all errors be written to out as JSON
+func setErrorOutput(out io.Writer) {
+	errorOutMu.Lock()
+	errorOut = out
+	errorOutMu.Unlock()
+	fs.LogErrorf = logJSONError
+}
+
+// writeJSON writes v as a single line of JSON to the error output
+func writeJSON(v interface{}) {
+	errorOutMu.Lock()
+	defer errorOutMu.Unlock()
+	if errorOut == nil {
+		return
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Failed to encode error as JSON: %v\n", err)
+		return
+	}
+	buf = append(buf, '\n')
+	_, _ = errorOut.Write(buf)
+}
+
+// operationFromText makes a short description of the operation from
+// the log format text, eg "Failed to copy: %v" gives "Failed to copy"
+func operationFromText(text string) string {
+	if i := strings.IndexRune(text, '%'); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimRight(text, " :")
+}
+
+// logJSONError writes an error log message as JSON
+func logJSONError(level fs.LogLevel, o interface{}, text string, args ...interface{}) bool {
+	out := jsonError{
+		Time:      time.Now().Format(time.RFC3339Nano),
+		Level:     strings.ToLower(level.String()),
+		Operation: operationFromText(text),
+		Message:   fmt.Sprintf(text, args...),
+	}
+	if o != nil {
+		out.Object = fmt.Sprintf("%v", o)
+	}
+	// Find the first error in the args
+	for _, arg := range args {
+		if err, ok := arg.(error); ok && err != nil {
+			out.Error = err.Error()
+			out.Reason = fserrors.Reason(err)
+			out.Retryable = fserrors.IsRetryError(err) || fserrors.ShouldRetry(err)
+			out.Fatal = fserrors.IsFatalError(err)
+			break
+		}
+	}
+	writeJSON(out)
+	return true
+}
+
+// ErrorSummary writes the final summary of the run as JSON if
+// --error-format json is in use.  err is the final error if any.
+func ErrorSummary(err error, errors, checks, transfers, bytes int64) {
+	out := jsonSummary{
+		Time:      time.Now().Format(time.RFC3339Nano),
+		Level:     "summary",
+		Summary:   true,
+		Errors:    errors,
+		Checks:    checks,
+		Transfers: transfers,
+		Bytes:     bytes,
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	writeJSON(out)
+}
@@ -0,0 +1,166 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/fserrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reasonError is an error with a backend reason
+type reasonError struct {
+	error
+	reason string
+}
+
+func (e reasonError) Reason() string {
+	return e.reason
+}
+
+// captureErrors makes the JSON errors be written to the buffer
+// returned until the function returned is called
+func captureErrors() (*bytes.Buffer, func()) {
+	oldLogErrorf := fs.LogErrorf
+	buf := new(bytes.Buffer)
+	setErrorOutput(buf)
+	return buf, func() {
+		errorOutMu.Lock()
+		errorOut = nil
+		errorOutMu.Unlock()
+		fs.LogErrorf = oldLogErrorf
+	}
+}
+
+// decodeLine decodes the single line of JSON in buf into v and
+// empties buf
+func decodeLine(t *testing.T, buf *bytes.Buffer, v interface{}) {
+	line := buf.String()
+	buf.Reset()
+	require.True(t, strings.HasSuffix(line, "\n"), line)
+	assert.Equal(t, 1, strings.Count(line, "\n"), line)
+	require.NoError(t, json.Unmarshal([]byte(line), v))
+}
+
+func TestOperationFromText(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"Failed to copy: %v", "Failed to copy"},
+		{"Failed to set modification time: %v", "Failed to set modification time"},
+		{"Couldn't delete %q: %v", "Couldn't delete"},
+		{"%v", ""},
+		{"Not deleting as dry run", "Not deleting as dry run"},
+		{"Corrupted on transfer : ", "Corrupted on transfer"},
+		{"", ""},
+	} {
+		assert.Equal(t, test.want, operationFromText(test.in), test.in)
+	}
+}
+
+func TestLogJSONError(t *testing.T) {
+	buf, done := captureErrors()
+	defer done()
+
+	for _, test := range []struct {
+		what  string
+		level fs.LogLevel
+		o     interface{}
+		text  string
+		args  []interface{}
+		want  jsonError
+	}{
+		{
+			what:  "plain error",
+			level: fs.LogLevelError,
+			o:     "file.txt",
+			text:  "Failed to copy: %v",
+			args:  []interface{}{errors.New("boom")},
+			want:  jsonError{Level: "error", Object: "file.txt", Operation: "Failed to copy", Message: "Failed to copy: boom", Error: "boom"},
+		},
+		{
+			what:  "no object or error",
+			level: fs.LogLevelError,
+			text:  "Attempt %d/%d failed",
+			args:  []interface{}{1, 3},
+			want:  jsonError{Level: "error", Operation: "Attempt", Message: "Attempt 1/3 failed"},
+		},
+		{
+			what:  "retry error",
+			level: fs.LogLevelError,
+			o:     "dir/file.txt",
+			text:  "Failed to upload: %v",
+			args:  []interface{}{fserrors.RetryErrorf("try again")},
+			want:  jsonError{Level: "error", Object: "dir/file.txt", Operation: "Failed to upload", Message: "Failed to upload: try again", Error: "try again", Retryable: true},
+		},
+		{
+			what:  "fatal error",
+			level: fs.LogLevelCritical,
+			text:  "Stopping: %v",
+			args:  []interface{}{fserrors.FatalError(errors.New("out of quota"))},
+			want:  jsonError{Level: "critical", Operation: "Stopping", Message: "Stopping: out of quota", Error: "out of quota", Fatal: true},
+		},
+		{
+			what:  "first error with a reason",
+			level: fs.LogLevelError,
+			o:     "file.txt",
+			text:  "Failed %q: %v %v",
+			args:  []interface{}{"copy", error(nil), reasonError{errors.New("limited"), "userRateLimitExceeded"}},
+			want:  jsonError{Level: "error", Object: "file.txt", Operation: "Failed", Message: `Failed "copy": <nil> limited`, Error: "limited", Reason: "userRateLimitExceeded"},
+		},
+	} {
+		assert.True(t, logJSONError(test.level, test.o, test.text, test.args...), test.what)
+		var got jsonError
+		decodeLine(t, buf, &got)
+		assert.NotEqual(t, "", got.Time, test.what)
+		got.Time = ""
+		assert.Equal(t, test.want, got, test.what)
+	}
+}
+
+func TestLogJSONErrorNoOutput(t *testing.T) {
+	// Nothing is written without an error output
+	assert.True(t, logJSONError(fs.LogLevelError, nil, "Failed: %v", errors.New("boom")))
+}
+
+func TestErrorSummary(t *testing.T) {
+	buf, done := captureErrors()
+	defer done()
+
+	for _, test := range []struct {
+		what                             string
+		err                              error
+		errors, checks, transfers, bytes int64
+		want                             jsonSummary
+	}{
+		{
+			what:      "success",
+			checks:    10,
+			transfers: 3,
+			bytes:     1024,
+			want:      jsonSummary{Level: "summary", Summary: true, Checks: 10, Transfers: 3, Bytes: 1024},
+		},
+		{
+			what:      "failure",
+			err:       errors.New("not everything was copied"),
+			errors:    2,
+			checks:    5,
+			transfers: 1,
+			bytes:     1,
+			want:      jsonSummary{Level: "summary", Summary: true, Errors: 2, Checks: 5, Transfers: 1, Bytes: 1, Error: "not everything was copied"},
+		},
+	} {
+		ErrorSummary(test.err, test.errors, test.checks, test.transfers, test.bytes)
+		var got jsonSummary
+		decodeLine(t, buf, &got)
+		assert.NotEqual(t, "", got.Time, test.what)
+		got.Time = ""
+		assert.Equal(t, test.want, got, test.what)
+	}
+}
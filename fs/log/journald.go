@@ -0,0 +1,66 @@
+// Logging to the systemd journal
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// journaldSocket is where journald receives log messages
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldMessage formats text at level in journald's native protocol
+func journaldMessage(level fs.LogLevel, identifier, text string) []byte {
+	var buf bytes.Buffer
+	// fs.LogLevel is the same as the syslog priority
+	_, _ = buf.WriteString("PRIORITY=" + strconv.Itoa(int(level)) + "\n")
+	_, _ = buf.WriteString("SYSLOG_IDENTIFIER=" + identifier + "\n")
+	if !strings.Contains(text, "\n") {
+		_, _ = buf.WriteString("MESSAGE=" + text + "\n")
+		return buf.Bytes()
+	}
+	// Values with newlines in are sent with their length
+	_, _ = buf.WriteString("MESSAGE\n")
+	_ = binary.Write(&buf, binary.LittleEndian, uint64(len(text)))
+	_, _ = buf.WriteString(text + "\n")
+	return buf.Bytes()
+}
+
+// journaldWriter writes the output of the standard logger to the
+// journal at NOTICE level
+type journaldWriter struct {
+	conn       net.Conn
+	identifier string
+}
+
+// Write p to the journal
+func (w journaldWriter) Write(p []byte) (int, error) {
+	_, err := w.conn.Write(journaldMessage(fs.LogLevelNotice, w.identifier, strings.TrimSuffix(string(p), "\n")))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Starts logging to journald
+func startJournald() {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		log.Fatalf("Failed to connect to journald: %v", err)
+	}
+	Me := path.Base(os.Args[0])
+	log.SetFlags(0)
+	log.SetOutput(journaldWriter{conn: conn, identifier: Me})
+	fs.LogPrint = func(level fs.LogLevel, text string) {
+		_, _ = conn.Write(journaldMessage(level, Me, text))
+	}
+}
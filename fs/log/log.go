@@ -18,6 +18,7 @@ var (
 	logFile        = flags.StringP("log-file", "", "", "Log everything to this file")
 	useSyslog      = flags.BoolP("syslog", "", false, "Use Syslog for logging")
 	syslogFacility = flags.StringP("syslog-facility", "", "DAEMON", "Facility for syslog, eg KERN,USER,...")
+	logDest        = flags.StringP("log-destination", "", "", "Send the logs to syslog://[host:port], journald or file:///path?maxsize=SIZE&maxbackups=N&maxage=TIME")
 )
 
 // fnName returns the name of the calling +2 function
@@ -85,6 +86,17 @@ func InitLogging() {
 		if *logFile != "" {
 			log.Fatalf("Can't use --syslog and --log-file together")
 		}
-		startSysLog()
+		startSysLog("", "", *syslogFacility)
 	}
+
+	// Pluggable log destination
+	if *logDest != "" {
+		if *logFile != "" || *useSyslog {
+			log.Fatalf("Can't use --log-destination with --log-file or --syslog")
+		}
+		startLogDestination()
+	}
+
+	// Structured error output
+	startErrorFormat()
 }
@@ -0,0 +1,123 @@
+// Log file rotation
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// rotatingFile is an io.Writer which writes to a log file, rotating it
+// when it gets too big or too old.
+//
+// When the file is rotated it is renamed to path.1, the old path.1 to
+// path.2 and so on, and a new file is started.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string        // name of the log file
+	maxSize    int64         // rotate when the file would be bigger than this, 0 for no limit
+	maxAge     time.Duration // rotate when the file has been written for this long, 0 for no limit
+	maxBackups int           // number of old files to keep, 0 to keep them all
+	f          *os.File      // open log file
+	size       int64         // size of the log file
+	started    time.Time     // when we started writing to the file
+	now        func() time.Time
+}
+
+// newRotatingFile opens path for appending to and returns a
+// rotatingFile writing to it
+func newRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open the log file for appending
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f = f
+	r.size = fi.Size()
+	r.started = r.now()
+	return nil
+}
+
+// backup returns the name of the nth old log file
+func (r *rotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// rotate renames the log file and its backups and starts a new one,
+// removing the oldest backups if there are too many
+//
+// The log file is always reopened so if this returns an error the
+// logs carry on being written to it.
+func (r *rotatingFile) rotate() error {
+	err := r.f.Close()
+	if err == nil {
+		err = r.renameBackups()
+	}
+	if openErr := r.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// renameBackups moves the log file to the first backup, moving the
+// existing backups up one
+func (r *rotatingFile) renameBackups() error {
+	n := 0
+	for {
+		if _, err := os.Stat(r.backup(n + 1)); err != nil {
+			break
+		}
+		n++
+	}
+	if r.maxBackups > 0 {
+		for ; n >= r.maxBackups; n-- {
+			_ = os.Remove(r.backup(n))
+		}
+	}
+	for i := n; i >= 1; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(r.path, r.backup(1))
+}
+
+// Write p to the log file, rotating it first if necessary
+func (r *rotatingFile) Write(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 {
+		tooBig := r.maxSize > 0 && r.size+int64(len(p)) > r.maxSize
+		tooOld := r.maxAge > 0 && r.now().Sub(r.started) >= r.maxAge
+		if tooBig || tooOld {
+			if err = r.rotate(); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Failed to rotate log file: %v\n", err)
+			}
+		}
+	}
+	n, err = r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
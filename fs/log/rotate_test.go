@@ -0,0 +1,82 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readLog reads the log file name, returning "" if it doesn't exist
+func readLog(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-log-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	name := filepath.Join(dir, "rclone.log")
+
+	r, err := newRotatingFile(name, 10, 0, 2)
+	require.NoError(t, err)
+	for i := 1; i <= 4; i++ {
+		_, err = fmt.Fprintf(r, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.f.Close())
+
+	assert.Equal(t, "line 4\n", readLog(t, name))
+	assert.Equal(t, "line 3\n", readLog(t, name+".1"))
+	assert.Equal(t, "line 2\n", readLog(t, name+".2"))
+	assert.Equal(t, "", readLog(t, name+".3"), "too many backups kept")
+
+	// Check the size of an existing file is counted
+	r, err = newRotatingFile(name, 10, 0, 0)
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(r, "line 5\n")
+	require.NoError(t, err)
+	require.NoError(t, r.f.Close())
+	assert.Equal(t, "line 5\n", readLog(t, name))
+	assert.Equal(t, "line 4\n", readLog(t, name+".1"))
+	assert.Equal(t, "line 2\n", readLog(t, name+".3"))
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-log-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	name := filepath.Join(dir, "rclone.log")
+
+	r, err := newRotatingFile(name, 0, time.Hour, 0)
+	require.NoError(t, err)
+	now := r.started
+	r.now = func() time.Time { return now }
+
+	_, err = fmt.Fprintf(r, "first\n")
+	require.NoError(t, err)
+	now = now.Add(59 * time.Minute)
+	_, err = fmt.Fprintf(r, "second\n")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = fmt.Fprintf(r, "third\n")
+	require.NoError(t, err)
+	require.NoError(t, r.f.Close())
+
+	assert.Equal(t, "third\n", readLog(t, name))
+	assert.Equal(t, "first\nsecond\n", readLog(t, name+".1"))
+}
@@ -10,7 +10,7 @@ import (
 )
 
 // Starts syslog if configured, returns true if it was started
-func startSysLog() bool {
+func startSysLog(network, raddr, facilityName string) bool {
 	log.Fatalf("--syslog not supported on %s platform", runtime.GOOS)
 	return false
 }
@@ -31,13 +31,16 @@ var (
 )
 
 // Starts syslog
-func startSysLog() bool {
-	facility, ok := syslogFacilityMap[*syslogFacility]
+//
+// If network and raddr are empty it logs to the local syslog,
+// otherwise to the syslog server at raddr
+func startSysLog(network, raddr, facilityName string) bool {
+	facility, ok := syslogFacilityMap[facilityName]
 	if !ok {
-		log.Fatalf("Unknown syslog facility %q - man syslog for list", *syslogFacility)
+		log.Fatalf("Unknown syslog facility %q - man syslog for list", facilityName)
 	}
 	Me := path.Base(os.Args[0])
-	w, err := syslog.New(syslog.LOG_NOTICE|facility, Me)
+	w, err := syslog.Dial(network, raddr, syslog.LOG_NOTICE|facility, Me)
 	if err != nil {
 		log.Fatalf("Failed to start syslog: %v", err)
 	}
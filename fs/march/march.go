@@ -28,6 +28,7 @@ type March struct {
 	srcListDir listDirFn // function to call to list a directory in the src
 	dstListDir listDirFn // function to call to list a directory in the dst
 	transforms []matchTransformFn
+	fixCase    bool // match up files whose names only differ in case
 }
 
 // Marcher is called on each match
@@ -62,6 +63,10 @@ func New(ctx context.Context, fdst, fsrc fs.Fs, dir string, callback Marcher) *M
 	//                  | Yes | Yes | Yes                |
 	if fdst.Features().CaseInsensitive {
 		m.transforms = append(m.transforms, strings.ToLower)
+	} else if fs.Config.FixCase {
+		// ..otherwise with --fix-case match up the files left
+		// over whose names only differ in case to be renamed
+		m.fixCase = true
 	}
 	return m
 }
@@ -71,8 +76,18 @@ type listDirFn func(dir string) (entries fs.DirEntries, err error)
 
 // makeListDir makes a listing function for the given fs and includeAll flags
 func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
-	if !fs.Config.UseListR || f.Features().ListR == nil {
+	useListR, probed := walk.UseListR(f, m.dir, includeAll, fs.Config.MaxDepth)
+	if !useListR {
+		var mu sync.Mutex
 		return func(dir string) (entries fs.DirEntries, err error) {
+			// use the listing UseListR made if any
+			mu.Lock()
+			if dir == m.dir && probed != nil {
+				entries, probed = probed, nil
+				mu.Unlock()
+				return entries, nil
+			}
+			mu.Unlock()
 			return list.DirSorted(f, includeAll, dir)
 		}
 	}
@@ -86,7 +101,7 @@ func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
 		mu.Lock()
 		defer mu.Unlock()
 		if !started {
-			dirs, dirsErr = walk.NewDirTree(f, m.dir, includeAll, fs.Config.MaxDepth)
+			dirs, dirsErr = walk.NewDirTreeListR(f, m.dir, includeAll, fs.Config.MaxDepth)
 			started = true
 		}
 		if dirsErr != nil {
@@ -328,6 +343,57 @@ func matchListings(srcListEntries, dstListEntries fs.DirEntries, transforms []ma
 	return
 }
 
+// matchCase matches up the files left in srcOnly and dstOnly by
+// matchListings whose names only differ in case, adding them to
+// matches.
+//
+// Files are only matched if there is exactly one of each with the
+// name, so names in the source which only differ in case, eg Foo and
+// foo, are never both matched with the same destination.
+func matchCase(srcOnly, dstOnly fs.DirEntries, matches []matchPair) (fs.DirEntries, fs.DirEntries, []matchPair) {
+	if len(srcOnly) == 0 || len(dstOnly) == 0 {
+		return srcOnly, dstOnly, matches
+	}
+	foldedName := func(entry fs.DirEntry) string {
+		return strings.ToLower(norm.NFC.String(path.Base(entry.Remote())))
+	}
+	countObjects := func(entries fs.DirEntries) map[string]int {
+		counts := make(map[string]int)
+		for _, entry := range entries {
+			if _, ok := entry.(fs.Object); ok {
+				counts[foldedName(entry)]++
+			}
+		}
+		return counts
+	}
+	srcCounts, dstCounts := countObjects(srcOnly), countObjects(dstOnly)
+	matchable := func(entry fs.DirEntry) (name string, ok bool) {
+		if _, ok = entry.(fs.Object); !ok {
+			return "", false
+		}
+		name = foldedName(entry)
+		return name, srcCounts[name] == 1 && dstCounts[name] == 1
+	}
+	dstByName := make(map[string]fs.DirEntry)
+	var newDstOnly fs.DirEntries
+	for _, dst := range dstOnly {
+		if name, ok := matchable(dst); ok {
+			dstByName[name] = dst
+		} else {
+			newDstOnly = append(newDstOnly, dst)
+		}
+	}
+	var newSrcOnly fs.DirEntries
+	for _, src := range srcOnly {
+		if name, ok := matchable(src); ok {
+			matches = append(matches, matchPair{src: src, dst: dstByName[name]})
+		} else {
+			newSrcOnly = append(newSrcOnly, src)
+		}
+	}
+	return newSrcOnly, newDstOnly, matches
+}
+
 // processJob processes a listDirJob listing the source and
 // destination directories, comparing them and returning a slice of
 // more jobs
@@ -373,6 +439,9 @@ func (m *March) processJob(job listDirJob) (jobs []listDirJob) {
 
 	// Work out what to do and do it
 	srcOnly, dstOnly, matches := matchListings(srcList, dstList, m.transforms)
+	if m.fixCase {
+		srcOnly, dstOnly, matches = matchCase(srcOnly, dstOnly, matches)
+	}
 	for _, src := range srcOnly {
 		if m.aborting() {
 			return nil
@@ -5,6 +5,7 @@ package march
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fstest/mockobject"
@@ -169,3 +170,64 @@ func TestMatchListings(t *testing.T) {
 		assert.Equal(t, test.matches, matches, test.what)
 	}
 }
+
+func TestMatchCase(t *testing.T) {
+	var (
+		foo   = mockobject.Object("dir/foo")
+		Foo   = mockobject.Object("dir/Foo")
+		FOO   = mockobject.Object("dir/FOO")
+		bar   = mockobject.Object("dir/bar")
+		BAR   = mockobject.Object("dir/BAR")
+		baz   = mockobject.Object("dir/baz")
+		sub   = fs.NewDir("dir/sub", time.Now())
+		SUB   = fs.NewDir("dir/SUB", time.Now())
+		other = mockobject.Object("dir/other")
+	)
+
+	for _, test := range []struct {
+		what             string
+		srcOnly, dstOnly fs.DirEntries
+		wantSrc, wantDst fs.DirEntries
+		wantMatches      []matchPair
+	}{
+		{
+			what:        "only differ in case",
+			srcOnly:     fs.DirEntries{Foo, bar, baz},
+			dstOnly:     fs.DirEntries{BAR, FOO, other},
+			wantSrc:     fs.DirEntries{baz},
+			wantDst:     fs.DirEntries{other},
+			wantMatches: []matchPair{{Foo, FOO}, {bar, BAR}},
+		},
+		{
+			what:    "distinct names in the source aren't collapsed",
+			srcOnly: fs.DirEntries{Foo, foo},
+			dstOnly: fs.DirEntries{FOO},
+			wantSrc: fs.DirEntries{Foo, foo},
+			wantDst: fs.DirEntries{FOO},
+		},
+		{
+			what:    "nor in the destination",
+			srcOnly: fs.DirEntries{FOO},
+			dstOnly: fs.DirEntries{Foo, foo},
+			wantSrc: fs.DirEntries{FOO},
+			wantDst: fs.DirEntries{Foo, foo},
+		},
+		{
+			what:    "directories aren't matched",
+			srcOnly: fs.DirEntries{sub},
+			dstOnly: fs.DirEntries{SUB},
+			wantSrc: fs.DirEntries{sub},
+			wantDst: fs.DirEntries{SUB},
+		},
+		{
+			what:    "nothing in the destination",
+			srcOnly: fs.DirEntries{foo},
+			wantSrc: fs.DirEntries{foo},
+		},
+	} {
+		srcOnly, dstOnly, matches := matchCase(test.srcOnly, test.dstOnly, nil)
+		assert.Equal(t, test.wantSrc, srcOnly, test.what)
+		assert.Equal(t, test.wantDst, dstOnly, test.what)
+		assert.Equal(t, test.wantMatches, matches, test.what)
+	}
+}
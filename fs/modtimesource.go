@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ModTimeSource describes which modification time is used when
+// comparing files
+type ModTimeSource byte
+
+// ModTimeSource constants
+const (
+	ModTimeSourceMetadata ModTimeSource = iota // the modification time stored with the object
+	ModTimeSourceServer                        // the server's own timestamp
+	ModTimeSourceSource                        // the source's stored time against the destination's server time
+	ModTimeSourceDefault  = ModTimeSourceMetadata
+)
+
+var modTimeSourceToString = []string{
+	ModTimeSourceMetadata: "metadata",
+	ModTimeSourceServer:   "server",
+	ModTimeSourceSource:   "source",
+}
+
+// String turns a ModTimeSource into a string
+func (m ModTimeSource) String() string {
+	if m >= ModTimeSource(len(modTimeSourceToString)) {
+		return fmt.Sprintf("ModTimeSource(%d)", m)
+	}
+	return modTimeSourceToString[m]
+}
+
+// Set a ModTimeSource
+func (m *ModTimeSource) Set(s string) error {
+	for n, name := range modTimeSourceToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = ModTimeSource(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown modtime source %q", s)
+}
+
+// Type of the value
+func (m *ModTimeSource) Type() string {
+	return "string"
+}
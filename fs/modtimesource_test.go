@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*ModTimeSource)(nil)
+
+func TestModTimeSourceString(t *testing.T) {
+	for _, test := range []struct {
+		in   ModTimeSource
+		want string
+	}{
+		{ModTimeSourceMetadata, "metadata"},
+		{ModTimeSourceServer, "server"},
+		{ModTimeSourceSource, "source"},
+		{99, "ModTimeSource(99)"},
+	} {
+		assert.Equal(t, test.want, test.in.String())
+	}
+}
+
+func TestModTimeSourceSet(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want ModTimeSource
+		err  bool
+	}{
+		{"metadata", ModTimeSourceMetadata, false},
+		{"SERVER", ModTimeSourceServer, false},
+		{"source", ModTimeSourceSource, false},
+		{"potato", ModTimeSourceMetadata, true},
+		{"", ModTimeSourceMetadata, true},
+	} {
+		m := ModTimeSourceMetadata
+		err := m.Set(test.in)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.want, m, test.in)
+	}
+}
@@ -0,0 +1,153 @@
+package operations
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+// Results of checking an entry with CheckFile
+const (
+	checkFileMatch    = "MATCH"
+	checkFileMiss     = "MISS"
+	checkFileHashDiff = "HASH_DIFF"
+	checkFileError    = "ERROR"
+)
+
+// checkFileLine matches a line of md5sum/sha1sum output, which is the
+// hash then a space then a space or a "*" for binary mode then the
+// file name
+var checkFileLine = regexp.MustCompile(`^(\S+) [ *](.+)$`)
+
+// checkFileEntry is a parsed line of a checksum file
+type checkFileEntry struct {
+	sum    string
+	remote string
+}
+
+// parseCheckFileLine parses a line of a checksum file
+func parseCheckFileLine(line string) (entry checkFileEntry, err error) {
+	match := checkFileLine.FindStringSubmatch(line)
+	if match == nil {
+		return entry, errors.Errorf("can't parse checksum line %q", line)
+	}
+	return checkFileEntry{sum: match[1], remote: match[2]}, nil
+}
+
+// objectHash returns the hash of type ht of o.  If the backend doesn't
+// support ht then the object is downloaded to work it out.
+//
+// If download isn't nil it is called with the Account of the download
+// if there is one so its progress can be read.
+func objectHash(ht hash.Type, o fs.Object, download func(acc *accounting.Account)) (sum string, err error) {
+	sum, err = o.Hash(ht)
+	if err != hash.ErrUnsupported {
+		return sum, err
+	}
+	in0, err := o.Open()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open object to hash")
+	}
+	in := accounting.NewAccount(in0, o) // account the download
+	defer fs.CheckClose(in, &err)
+	if download != nil {
+		download(in)
+	}
+	sums, err := hash.StreamTypes(in, hash.NewHashSet(ht))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to hash object")
+	}
+	return sums[ht], nil
+}
+
+// checkFileEntryResult checks a single entry of a checksum file
+// against f returning one of the checkFile* results and an error if
+// it wasn't a match.
+func checkFileEntryResult(ht hash.Type, f fs.Fs, entry checkFileEntry) (result string, err error) {
+	accounting.Stats.Checking(entry.remote)
+	defer accounting.Stats.DoneChecking(entry.remote)
+	o, err := f.NewObject(entry.remote)
+	if err == fs.ErrorObjectNotFound {
+		fs.Errorf(entry.remote, "File not found")
+		return checkFileMiss, err
+	} else if err != nil {
+		fs.Errorf(entry.remote, "Failed to find file: %v", err)
+		return checkFileError, err
+	}
+	sum, err := objectHash(ht, o, nil)
+	if err != nil {
+		fs.Errorf(o, "Failed to read %v: %v", ht, err)
+		return checkFileError, err
+	}
+	if !strings.EqualFold(sum, entry.sum) {
+		err = errors.Errorf("%v differ", ht)
+		fs.Errorf(o, "%v", err)
+		return checkFileHashDiff, err
+	}
+	fs.Debugf(o, "%v match", ht)
+	return checkFileMatch, nil
+}
+
+// CheckFile reads a checksum file in the format of md5sum/sha1sum from
+// in and checks each file in it against f using the hash ht.
+//
+// It writes MATCH, MISS, HASH_DIFF or ERROR and the file name to w for
+// each line.  The checks are done using --checkers in parallel so
+// these may be out of order.
+//
+// The checksum file is streamed so it can be arbitrarily big.  It
+// returns an error if any of the files didn't match.
+func CheckFile(ht hash.Type, f fs.Fs, in io.Reader, w io.Writer) error {
+	var (
+		wg          sync.WaitGroup
+		entries     = make(chan checkFileEntry, fs.Config.Checkers)
+		differences int32
+	)
+	wg.Add(fs.Config.Checkers)
+	for i := 0; i < fs.Config.Checkers; i++ {
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				result, err := checkFileEntryResult(ht, f, entry)
+				if err != nil {
+					atomic.AddInt32(&differences, 1)
+					accounting.Stats.Error(err)
+				}
+				syncFprintf(w, "%-9s %s\n", result, entry.remote)
+			}
+		}()
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		entry, parseErr := parseCheckFileLine(line)
+		if parseErr != nil {
+			fs.Errorf(nil, "%v", parseErr)
+			atomic.AddInt32(&differences, 1)
+			accounting.Stats.Error(parseErr)
+			continue
+		}
+		entries <- entry
+	}
+	close(entries)
+	wg.Wait()
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "failed to read checksum file")
+	}
+	if differences > 0 {
+		return errors.Errorf("%d differences found", differences)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package operations
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+)
+
+var (
+	// clockSkewMu protects clockSkews and makes sure each remote
+	// is only measured once
+	clockSkewMu sync.Mutex
+	// clockSkews has the skew measured for each remote by name
+	clockSkews = map[string]time.Duration{}
+)
+
+// ClockSkew returns how far the clock of the server for f is ahead of
+// the local clock.
+//
+// This is --clock-skew unless it is "auto", in which case the skew of
+// each remote is measured the first time it is needed and logged.
+// Remotes which can't tell the time on the server are assumed to have
+// no skew.
+func ClockSkew(f fs.Info) time.Duration {
+	if !fs.Config.ClockSkewAuto {
+		return fs.Config.ClockSkew
+	}
+	clockSkewMu.Lock()
+	defer clockSkewMu.Unlock()
+	skew, found := clockSkews[f.Name()]
+	if !found {
+		skew = measureClockSkew(f)
+		clockSkews[f.Name()] = skew
+	}
+	return skew
+}
+
+// measureClockSkew measures how far the clock of the server for f is
+// ahead of the local clock
+func measureClockSkew(f fs.Info) time.Duration {
+	serverTime := f.Features().ServerTime
+	if serverTime == nil {
+		fs.Debugf(f, "Can't measure clock skew - assuming there isn't any")
+		return 0
+	}
+	start := time.Now()
+	now, err := serverTime()
+	if err != nil {
+		fs.Errorf(f, "Failed to measure clock skew - assuming there isn't any: %v", err)
+		return 0
+	}
+	end := time.Now()
+	// The server's time is normally only accurate to the second so
+	// compare it with the middle of the request and ignore skews
+	// smaller than that
+	skew := now.Sub(start.Add(end.Sub(start) / 2))
+	if skew > -time.Second && skew < time.Second {
+		fs.Debugf(f, "No clock skew found")
+		return 0
+	}
+	fs.Logf(f, "Clock on the server is %v ahead of the local clock - correcting modification times from the server", skew)
+	return skew
+}
+
+// localModTime returns the modification time of o, corrected for the
+// clock skew of its server if it is a time the server set, that is an
+// object which has a ServerModTime with --use-server-modtime.
+func localModTime(o fs.ObjectInfo) time.Time {
+	modTime := o.ModTime()
+	if !fs.Config.UseServerModTime {
+		return modTime
+	}
+	if _, ok := o.(fs.ServerModTimer); !ok {
+		return modTime
+	}
+	return modTime.Add(-ClockSkew(o.Fs()))
+}
@@ -0,0 +1,449 @@
+package operations
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/fshttp"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/pkg/errors"
+)
+
+// errCantResume is returned by urlReader.open if the server won't
+// send the data from the offset asked for
+var errCantResume = errors.New("server can't resume download")
+
+// urlReader reads the body of a URL, asking for the rest of it with
+// a Range request if the connection fails part way through.
+type urlReader struct {
+	client    *http.Client
+	url       string
+	body      io.ReadCloser
+	offset    int64  // offset of the next byte to read
+	size      int64  // total size of the data or -1 if not known
+	validator string // ETag or Last-Modified to check the data hasn't changed on resume
+	canRange  bool   // set if the server accepts Range requests
+	retries   int    // number of resumes done
+}
+
+// contentRangeRe parses the Content-Range header of a 206 response
+var contentRangeRe = regexp.MustCompile(`^bytes (\d+)-\d+/(\d+|\*)$`)
+
+// open requests the data from offset onwards returning the response
+//
+// It returns errCantResume if offset > 0 and the server replied with
+// anything other than the data from offset.
+func (r *urlReader) open(offset int64) (resp *http.Response, err error) {
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if r.validator != "" {
+			req.Header.Set("If-Range", r.validator)
+		}
+	}
+	resp, err = r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case offset == 0 && resp.StatusCode == http.StatusOK:
+		r.size = resp.ContentLength
+		r.canRange = resp.Header.Get("Accept-Ranges") == "bytes"
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		match := contentRangeRe.FindStringSubmatch(resp.Header.Get("Content-Range"))
+		if match == nil || match[1] != strconv.FormatInt(offset, 10) {
+			_ = resp.Body.Close()
+			return nil, errCantResume
+		}
+		r.size = -1
+		if match[2] != "*" {
+			r.size, _ = strconv.ParseInt(match[2], 10, 64)
+		}
+		r.canRange = true
+	case offset > 0 && (resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable):
+		_ = resp.Body.Close()
+		return resp, errCantResume
+	default:
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("failed to fetch %q: %s", r.url, resp.Status)
+	}
+	if r.validator == "" {
+		r.validator = resp.Header.Get("ETag")
+		if r.validator == "" {
+			r.validator = resp.Header.Get("Last-Modified")
+		}
+	}
+	r.body = resp.Body
+	r.offset = offset
+	return resp, nil
+}
+
+// Read the data, resuming the download if it fails part way through
+func (r *urlReader) Read(p []byte) (n int, err error) {
+	for {
+		n, err = r.body.Read(p)
+		r.offset += int64(n)
+		if err == io.EOF && r.size >= 0 && r.offset < r.size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if !r.canRange || r.retries >= fs.Config.LowLevelRetries {
+			return n, err
+		}
+		r.retries++
+		fs.Debugf(r.url, "Resuming download at offset %d after error (%d/%d): %v", r.offset, r.retries, fs.Config.LowLevelRetries, err)
+		_ = r.body.Close()
+		_, openErr := r.open(r.offset)
+		if openErr != nil {
+			r.body = eofReader{}
+			return n, errors.Wrapf(err, "failed to resume download: %v", openErr)
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close the body
+func (r *urlReader) Close() error {
+	return r.body.Close()
+}
+
+// eofReader is an empty body used when a resume has failed
+type eofReader struct{}
+
+func (eofReader) Read(p []byte) (int, error) { return 0, io.EOF }
+func (eofReader) Close() error               { return nil }
+
+// offsetWriter writes sequentially to an io.WriterAt from an offset
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+// Write p at the current offset
+func (o *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = o.w.WriteAt(p, o.off)
+	o.off += int64(n)
+	return n, err
+}
+
+// parseChecksum parses a checksum which can be hex or base64 encoded
+// returning its type and the hex encoded value.
+//
+// The type is worked out from the length so only MD5 and SHA-1 are
+// recognised.
+func parseChecksum(s string) (ht hash.Type, sum string, err error) {
+	s = strings.TrimSpace(s)
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		raw, err = base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return hash.None, "", errors.Errorf("checksum %q isn't hex or base64", s)
+		}
+	}
+	switch len(raw) {
+	case 16:
+		ht = hash.MD5
+	case 20:
+		ht = hash.SHA1
+	default:
+		return hash.None, "", errors.Errorf("checksum %q isn't an MD5 or SHA-1", s)
+	}
+	return ht, hex.EncodeToString(raw), nil
+}
+
+// headerChecksum reads a checksum from the header called name
+//
+// This can be a single checksum, as in Content-MD5, or a list like
+// the Digest header, eg "MD5=base64,SHA=base64".
+func headerChecksum(header http.Header, name string) (ht hash.Type, sum string, err error) {
+	value := header.Get(name)
+	if value == "" {
+		return hash.None, "", errors.Errorf("no %q header in response", name)
+	}
+	// An "=" which isn't base64 padding means it is a list
+	if i := strings.Index(value, "="); i < 0 || strings.TrimRight(value, "=") == value[:i] {
+		return parseChecksum(value)
+	}
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToLower(kv[0]) {
+		case "md5", "sha":
+			return parseChecksum(kv[1])
+		}
+	}
+	return hash.None, "", errors.Errorf("no MD5 or SHA-1 checksum in %q header %q", name, value)
+}
+
+// CopyURL copies the data from url to dstFileName on fdst.
+//
+// If the connection fails part way through the download is resumed
+// with a Range request.  If fdst supports OpenWriterAt and Move the
+// data is downloaded to a file with --partial-suffix on the end of its
+// name which is renamed to dstFileName when complete.  If that is
+// left by an interrupted download then only the rest of the data is
+// downloaded, as long as the end of the data in it matches the data
+// at the url.
+//
+// If checksumHeader is set the checksum in the response header of
+// that name is checked and if expectHash is set the data is checked
+// against that.  If the checksum doesn't match the destination is
+// removed.
+func CopyURL(fdst fs.Fs, dstFileName, url, checksumHeader, expectHash string) (dst fs.Object, err error) {
+	var (
+		ht       = hash.None
+		wantHash string
+	)
+	if expectHash != "" {
+		ht, wantHash, err = parseChecksum(expectHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := fdst.NewObject(dstFileName)
+	if err == fs.ErrorObjectNotFound {
+		existing, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Look for a partial download to resume
+	features := fdst.Features()
+	usePartial := !fs.Config.NoPartial && fs.Config.PartialSuffix != "" && features.OpenWriterAt != nil && features.Move != nil
+	partialRemote := dstFileName + fs.Config.PartialSuffix
+	var (
+		partial     fs.Object
+		offset      int64
+		sampleStart int64
+	)
+	if usePartial {
+		partial, err = fdst.NewObject(partialRemote)
+		if err == nil && partial.Size() > 0 {
+			offset = partial.Size()
+			sampleStart = offset - partialSampleSize
+			if sampleStart < 0 {
+				sampleStart = 0
+			}
+		}
+		err = nil
+	}
+
+	r := &urlReader{
+		client: fshttp.NewClient(fs.Config),
+		url:    url,
+	}
+	resp, err := r.open(sampleStart)
+	if err == errCantResume {
+		// The server won't send the range or the partial
+		// download is longer than the data
+		fs.Debugf(partial, "Can't resume download - downloading from the start")
+		offset = 0
+		resp, err = r.open(0)
+	} else if err == nil && offset > 0 && !copyURLSampleMatches(r, partial, sampleStart, offset) {
+		fs.Debugf(partial, "Not resuming download as the data has changed - downloading from the start")
+		_ = r.body.Close()
+		offset = 0
+		resp, err = r.open(0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(r, &err)
+
+	if checksumHeader != "" {
+		header := resp.Header
+		if offset > 0 && sampleStart > 0 {
+			// The header on a partial response is for the part
+			// so read the one for the whole file
+			headResp, err := r.client.Head(url)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read checksum header")
+			}
+			_ = headResp.Body.Close()
+			header = headResp.Header
+		}
+		headerType, headerHash, err := headerChecksum(header, checksumHeader)
+		if err != nil {
+			return nil, err
+		}
+		if wantHash != "" && (headerType != ht || headerHash != wantHash) {
+			return nil, errors.Errorf("%s header %s doesn't match expected checksum %s", checksumHeader, headerHash, wantHash)
+		}
+		ht, wantHash = headerType, headerHash
+	}
+
+	modTime := time.Now()
+	if lastModified, parseErr := http.ParseTime(resp.Header.Get("Last-Modified")); parseErr == nil {
+		modTime = lastModified
+	}
+
+	if fs.Config.DryRun {
+		fs.Logf(dstFileName, "Not copying from %q as --dry-run", url)
+		return nil, nil
+	}
+
+	var hasher *hash.MultiHasher
+	var in io.ReadCloser = r
+	if ht != hash.None {
+		hasher, err = hash.NewMultiHasherTypes(hash.NewHashSet(ht))
+		if err != nil {
+			return nil, err
+		}
+		in = &readCloser{Reader: io.TeeReader(r, hasher), Closer: r}
+	}
+
+	if usePartial {
+		dst, err = copyURLPartial(fdst, partialRemote, partial, in, hasher, offset, r.size, modTime)
+	} else if r.size < 0 {
+		dst, err = Rcat(fdst, dstFileName, in, modTime)
+	} else {
+		dst, err = copyURLSized(fdst, existing, dstFileName, in, r.size, modTime)
+	}
+	if err != nil {
+		return dst, err
+	}
+
+	if hasher != nil {
+		gotHash := hasher.Sums()[ht]
+		if gotHash != wantHash {
+			err = errors.Errorf("corrupted on transfer: %v hash differ %q vs %q", ht, gotHash, wantHash)
+			fs.Errorf(dst, "%v", err)
+			removeFailedCopy(dst)
+			return nil, err
+		}
+		fs.Debugf(dst, "%v hash OK", ht)
+	}
+
+	if usePartial {
+		dst, err = features.Move(dst, dstFileName)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to rename partial download to final name")
+		}
+	}
+	return dst, nil
+}
+
+// copyURLSampleMatches reads the data from sampleStart to offset from
+// r and returns whether it is the same as the data there in partial.
+func copyURLSampleMatches(r *urlReader, partial fs.Object, sampleStart, offset int64) bool {
+	if r.size >= 0 && r.size < offset {
+		return false
+	}
+	sample := make([]byte, offset-sampleStart)
+	_, err := io.ReadFull(r, sample)
+	if err != nil {
+		fs.Debugf(partial, "Failed to read data to compare with partial download: %v", err)
+		return false
+	}
+	partialSample, err := readSample(partial, sampleStart, offset)
+	if err != nil {
+		fs.Debugf(partial, "Failed to read partial download: %v", err)
+		return false
+	}
+	return bytes.Equal(sample, partialSample)
+}
+
+// copyURLSized uploads in which is size bytes long
+func copyURLSized(fdst fs.Fs, existing fs.Object, dstFileName string, in io.ReadCloser, size int64, modTime time.Time) (dst fs.Object, err error) {
+	accounting.Stats.Transferring(dstFileName)
+	acc := accounting.NewAccountSizeName(in, size, dstFileName).WithBuffer()
+	defer func() {
+		accounting.Stats.DoneTransferring(dstFileName, err == nil)
+		if closeErr := acc.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	src := object.NewStaticObjectInfo(dstFileName, modTime, size, true, nil, fdst)
+	if existing != nil {
+		err = existing.Update(acc, src)
+		return existing, err
+	}
+	return fdst.Put(acc, src)
+}
+
+// copyURLPartial writes in to the partial download partialRemote
+// from offset, where the whole file is size bytes long or -1 if not
+// known.  partial is the data already downloaded if offset > 0.
+//
+// If hasher is set the data already in partial is read into it first.
+//
+// If the download fails the partial download is left so it can be
+// resumed.
+func copyURLPartial(fdst fs.Fs, partialRemote string, partial fs.Object, in io.ReadCloser, hasher *hash.MultiHasher, offset, size int64, modTime time.Time) (dst fs.Object, err error) {
+	if offset > 0 {
+		fs.Infof(partial, "Resuming download at offset %d", offset)
+		if hasher != nil {
+			rc, err := partial.Open()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to open partial download to hash it")
+			}
+			_, err = io.CopyN(hasher, rc, offset)
+			closeErr := rc.Close()
+			if err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to hash partial download")
+			}
+		}
+	}
+
+	accSize := int64(-1)
+	if size >= 0 {
+		accSize = size - offset
+	}
+	accounting.Stats.Transferring(partialRemote)
+	acc := accounting.NewAccountSizeName(in, accSize, partialRemote).WithBuffer()
+	defer func() {
+		accounting.Stats.DoneTransferring(partialRemote, err == nil)
+		if closeErr := acc.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	// Truncating to offset keeps the data we are resuming from
+	wc, err := fdst.Features().OpenWriterAt(partialRemote, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open partial download")
+	}
+	_, err = io.Copy(&offsetWriter{w: wc, off: offset}, acc)
+	closeErr := wc.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download")
+	}
+	dst, err = fdst.NewObject(partialRemote)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find object after download")
+	}
+	err = dst.SetModTime(modTime)
+	switch err {
+	case nil, fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
+	default:
+		return dst, errors.Wrap(err, "failed to set modification time")
+	}
+	return dst, nil
+}
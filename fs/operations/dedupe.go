@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log"
 	"path"
-	"sort"
 	"strings"
 
 	"github.com/ncw/rclone/fs"
@@ -100,14 +99,41 @@ func dedupeInteractive(ht hash.Type, remote string, objs []fs.Object) {
 	}
 }
 
-type objectsSortedByModTime []fs.Object
+// objectID returns the ID of o if it has one or "" otherwise
+func objectID(o fs.Object) string {
+	if do, ok := o.(fs.IDer); ok {
+		return do.ID()
+	}
+	return ""
+}
 
-func (objs objectsSortedByModTime) Len() int      { return len(objs) }
-func (objs objectsSortedByModTime) Swap(i, j int) { objs[i], objs[j] = objs[j], objs[i] }
-func (objs objectsSortedByModTime) Less(i, j int) bool {
-	return objs[i].ModTime().Before(objs[j].ModTime())
+// dedupeChoose returns the index of the object to keep in objs.
+//
+// better should return true if a should be kept in preference to b
+// and same should return true if a and b are equal by the chosen
+// criterion.  Ties are broken by keeping the object with the
+// lexicographically smallest ID so the choice is deterministic.
+func dedupeChoose(objs []fs.Object, better, same func(a, b fs.Object) bool) int {
+	keep := 0
+	for i := 1; i < len(objs); i++ {
+		a, b := objs[i], objs[keep]
+		if better(a, b) || (same(a, b) && objectID(a) < objectID(b)) {
+			keep = i
+		}
+	}
+	return keep
 }
 
+// Criteria for dedupeChoose
+var (
+	dedupeNewer    = func(a, b fs.Object) bool { return a.ModTime().After(b.ModTime()) }
+	dedupeOlder    = func(a, b fs.Object) bool { return a.ModTime().Before(b.ModTime()) }
+	dedupeSameTime = func(a, b fs.Object) bool { return a.ModTime().Equal(b.ModTime()) }
+	dedupeLarger   = func(a, b fs.Object) bool { return a.Size() > b.Size() }
+	dedupeSmaller  = func(a, b fs.Object) bool { return a.Size() < b.Size() }
+	dedupeSameSize = func(a, b fs.Object) bool { return a.Size() == b.Size() }
+)
+
 // DeduplicateMode is how the dedupe command chooses what to do
 type DeduplicateMode int
 
@@ -120,6 +146,7 @@ const (
 	DeduplicateOldest                             // choose the oldest object
 	DeduplicateRename                             // rename the objects
 	DeduplicateLargest                            // choose the largest object
+	DeduplicateSmallest                           // choose the smallest object
 )
 
 func (x DeduplicateMode) String() string {
@@ -138,6 +165,8 @@ func (x DeduplicateMode) String() string {
 		return "rename"
 	case DeduplicateLargest:
 		return "largest"
+	case DeduplicateSmallest:
+		return "smallest"
 	}
 	return "unknown"
 }
@@ -159,6 +188,8 @@ func (x *DeduplicateMode) Set(s string) error {
 		*x = DeduplicateRename
 	case "largest":
 		*x = DeduplicateLargest
+	case "smallest":
+		*x = DeduplicateSmallest
 	default:
 		return errors.Errorf("Unknown mode for dedupe %q.", s)
 	}
@@ -226,6 +257,9 @@ func dedupeMergeDuplicateDirs(f fs.Fs, duplicateDirs [][]fs.Directory) error {
 // delete all but one or rename them to be different. Only useful with
 // Google Drive which can have duplicate file names.
 func Deduplicate(f fs.Fs, mode DeduplicateMode) error {
+	if !f.Features().DuplicateFiles {
+		return errors.Errorf("%v: can't dedupe - remote doesn't support duplicate files", f)
+	}
 	fs.Infof(f, "Looking for duplicates using %v mode.", mode)
 
 	// Find duplicate directories first and fix them - repeat
@@ -280,24 +314,15 @@ func Deduplicate(f fs.Fs, mode DeduplicateMode) error {
 			case DeduplicateFirst:
 				dedupeDeleteAllButOne(0, remote, objs)
 			case DeduplicateNewest:
-				sort.Sort(objectsSortedByModTime(objs)) // sort oldest first
-				dedupeDeleteAllButOne(len(objs)-1, remote, objs)
+				dedupeDeleteAllButOne(dedupeChoose(objs, dedupeNewer, dedupeSameTime), remote, objs)
 			case DeduplicateOldest:
-				sort.Sort(objectsSortedByModTime(objs)) // sort oldest first
-				dedupeDeleteAllButOne(0, remote, objs)
+				dedupeDeleteAllButOne(dedupeChoose(objs, dedupeOlder, dedupeSameTime), remote, objs)
 			case DeduplicateRename:
 				dedupeRename(remote, objs)
 			case DeduplicateLargest:
-				largest, largestIndex := int64(-1), -1
-				for i, obj := range objs {
-					size := obj.Size()
-					if size > largest {
-						largest, largestIndex = size, i
-					}
-				}
-				if largestIndex > -1 {
-					dedupeDeleteAllButOne(largestIndex, remote, objs)
-				}
+				dedupeDeleteAllButOne(dedupeChoose(objs, dedupeLarger, dedupeSameSize), remote, objs)
+			case DeduplicateSmallest:
+				dedupeDeleteAllButOne(dedupeChoose(objs, dedupeSmaller, dedupeSameSize), remote, objs)
 			case DeduplicateSkip:
 				// skip
 			default:
@@ -0,0 +1,286 @@
+package operations
+
+import (
+	"crypto/md5"
+	"io"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/pkg/errors"
+)
+
+// deltaLiteralFlush is the most data not found in the destination
+// which is held before it is written
+const deltaLiteralFlush = 1024 * 1024
+
+// rollingChecksum is the weak checksum rsync uses which can be
+// rolled along the data a byte at a time
+type rollingChecksum struct {
+	a, b uint32
+	n    uint32 // size of the block
+}
+
+// newRollingChecksum returns the checksum of block
+func newRollingChecksum(block []byte) (r rollingChecksum) {
+	r.n = uint32(len(block))
+	for i, c := range block {
+		r.a += uint32(c)
+		r.b += (r.n - uint32(i)) * uint32(c)
+	}
+	return r
+}
+
+// roll moves the block along by a byte, removing out from its start
+// and adding in to its end
+func (r *rollingChecksum) roll(out, in byte) {
+	r.a += uint32(in) - uint32(out)
+	r.b += r.a - r.n*uint32(out)
+}
+
+// sum returns the checksum
+func (r rollingChecksum) sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
+
+// deltaSignature holds the checksums of the blocks of the old
+// contents of a file
+type deltaSignature struct {
+	blockSize int
+	weak      map[uint32][]int // weak checksum to the numbers of the blocks with it
+	strong    [][md5.Size]byte // strong checksum of each block
+}
+
+// newDeltaSignature reads in and works out the checksums of each
+// whole block of it
+func newDeltaSignature(in io.Reader, blockSize int) (*deltaSignature, error) {
+	sig := &deltaSignature{
+		blockSize: blockSize,
+		weak:      make(map[uint32][]int),
+	}
+	block := make([]byte, blockSize)
+	for {
+		_, err := io.ReadFull(in, block)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// The short block at the end isn't used
+			return sig, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		weak := newRollingChecksum(block).sum()
+		sig.weak[weak] = append(sig.weak[weak], len(sig.strong))
+		sig.strong = append(sig.strong, md5.Sum(block))
+	}
+}
+
+// find returns the offset of a block in the old contents which is the
+// same as block with weak checksum weak, or -1 if there isn't one.
+//
+// If the block at offset is the same then that is returned.
+func (sig *deltaSignature) find(weak uint32, block []byte, offset int64) int64 {
+	blocks := sig.weak[weak]
+	if len(blocks) == 0 {
+		return -1
+	}
+	strong := md5.Sum(block)
+	found := int64(-1)
+	for _, i := range blocks {
+		if sig.strong[i] != strong {
+			continue
+		}
+		blockOffset := int64(i) * int64(sig.blockSize)
+		if blockOffset == offset {
+			return offset
+		}
+		if found < 0 {
+			found = blockOffset
+		}
+	}
+	return found
+}
+
+// deltaStats counts the bytes of the new contents of a file by how
+// they were found in the old contents
+type deltaStats struct {
+	unchanged int64 // found in the same place
+	moved     int64 // found in a different place
+	changed   int64 // not found
+}
+
+// applyDelta reads the new contents of a file from in and writes the
+// parts of it which aren't the same as the old contents described by
+// sig to w.
+//
+// Data which is found at a different place in the old contents is
+// written again as there is no way of copying it within the
+// destination which is cheaper than sending it.
+func applyDelta(sig *deltaSignature, in io.Reader, w io.WriterAt) (stats deltaStats, err error) {
+	blockSize := sig.blockSize
+	var (
+		buf     = make([]byte, 0, deltaLiteralFlush+2*blockSize) // unwritten data then the block being looked at
+		pos     int64                                            // offset of buf[0] in the file
+		i       int                                              // start of the block in buf
+		sum     rollingChecksum                                  // checksum of the block if rolling is set
+		rolling bool
+		eof     bool
+	)
+	// fill reads into buf until it has at least n bytes or in is
+	// finished
+	fill := func(n int) error {
+		end := n + blockSize
+		if end > cap(buf) {
+			end = cap(buf)
+		}
+		for len(buf) < n && !eof {
+			m, err := in.Read(buf[len(buf):end])
+			buf = buf[:len(buf)+m]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// write writes the n bytes at the start of buf which weren't
+	// found in the old contents and discards them
+	write := func(n int) error {
+		if n == 0 {
+			return nil
+		}
+		_, err := w.WriteAt(buf[:n], pos)
+		if err != nil {
+			return err
+		}
+		stats.changed += int64(n)
+		pos += int64(n)
+		buf = buf[:copy(buf, buf[n:])]
+		i -= n
+		return nil
+	}
+	for {
+		if i >= deltaLiteralFlush {
+			err = write(i)
+			if err != nil {
+				return stats, err
+			}
+		}
+		// Read the block and the byte after it to roll in
+		err = fill(i + blockSize + 1)
+		if err != nil {
+			return stats, err
+		}
+		if len(buf)-i < blockSize {
+			break
+		}
+		block := buf[i : i+blockSize]
+		if !rolling {
+			sum = newRollingChecksum(block)
+			rolling = true
+		}
+		offset := pos + int64(i)
+		if found := sig.find(sum.sum(), block, offset); found >= 0 {
+			err = write(i)
+			if err != nil {
+				return stats, err
+			}
+			block = buf[:blockSize]
+			if found == offset {
+				stats.unchanged += int64(blockSize)
+			} else {
+				_, err = w.WriteAt(block, offset)
+				if err != nil {
+					return stats, err
+				}
+				stats.moved += int64(blockSize)
+			}
+			pos += int64(blockSize)
+			buf = buf[:copy(buf, buf[blockSize:])]
+			rolling = false
+			continue
+		}
+		if len(buf) == i+blockSize {
+			break
+		}
+		sum.roll(buf[i], buf[i+blockSize])
+		i++
+	}
+	// The rest wasn't found
+	err = write(len(buf))
+	return stats, err
+}
+
+// doDeltaCopy returns whether dst in f should be updated with a delta
+// copy from src
+func doDeltaCopy(f fs.Info, dst, src fs.ObjectInfo) bool {
+	if !fs.Config.Delta || dst == nil {
+		return false
+	}
+	// Files smaller than a block have nothing to compare
+	blockSize := int64(fs.Config.DeltaBlockSize)
+	if blockSize <= 0 || src.Size() < blockSize || dst.Size() < blockSize {
+		return false
+	}
+	return f.Features().OpenWriterAt != nil
+}
+
+// deltaCopy updates dst in f to have the contents of src by writing
+// only the parts of it which have changed.
+//
+// It reads all of dst to work out the checksums of its blocks then
+// reads src looking for them.  If the copy fails dst is left partly
+// updated.
+func deltaCopy(f fs.Fs, dst, src fs.Object, options ...fs.OpenOption) (newDst fs.Object, err error) {
+	in, err := dst.Open()
+	if err != nil {
+		return nil, errors.Wrap(err, "delta copy: failed to open destination")
+	}
+	sig, err := newDeltaSignature(in, int(fs.Config.DeltaBlockSize))
+	closeErr := in.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "delta copy: failed to read destination")
+	}
+
+	in0, err := src.Open(options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open source object")
+	}
+	acc := accounting.NewAccount(in0, src).WithBuffer() // account and buffer the transfer
+	// Opening with the size keeps the data before it
+	wc, err := f.Features().OpenWriterAt(dst.Remote(), src.Size())
+	if err != nil {
+		_ = acc.Close()
+		return nil, errors.Wrap(err, "delta copy: failed to open destination for writing")
+	}
+	stats, err := applyDelta(sig, acc, wc)
+	closeErr = acc.Close()
+	if err == nil {
+		err = closeErr
+	}
+	closeErr = wc.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if total := stats.unchanged + stats.moved + stats.changed; total != src.Size() {
+		return nil, errors.Errorf("delta copy: read %d bytes but expected %d", total, src.Size())
+	}
+	fs.Debugf(src, "Delta copy: %v unchanged, %v moved, %v changed", fs.SizeSuffix(stats.unchanged), fs.SizeSuffix(stats.moved), fs.SizeSuffix(stats.changed))
+
+	newDst, err = f.NewObject(dst.Remote())
+	if err != nil {
+		return nil, errors.Wrap(err, "delta copy: failed to find object after copy")
+	}
+	err = newDst.SetModTime(src.ModTime())
+	switch err {
+	case nil, fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
+	default:
+		return nil, errors.Wrap(err, "delta copy: failed to set modification time")
+	}
+	return newDst, nil
+}
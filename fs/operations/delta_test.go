@@ -0,0 +1,133 @@
+package operations
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollingChecksum(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	const n = 100
+	sum := newRollingChecksum(data[:n])
+	for i := 1; i+n <= len(data); i++ {
+		sum.roll(data[i-1], data[i+n-1])
+		assert.Equal(t, newRollingChecksum(data[i:i+n]).sum(), sum.sum(), i)
+	}
+}
+
+// recordWriterAt is a memWriterAt which counts the bytes written
+type recordWriterAt struct {
+	*memWriterAt
+	written int
+}
+
+func (w *recordWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.written += len(p)
+	return w.memWriterAt.WriteAt(p, off)
+}
+
+func TestApplyDelta(t *testing.T) {
+	const blockSize = 64
+	rnd := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rnd.Read(b)
+		return b
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	old := random(20*blockSize + 10)
+	for _, test := range []struct {
+		name    string
+		new     []byte
+		want    deltaStats
+		written int
+	}{
+		{
+			name:    "Unchanged",
+			new:     old,
+			want:    deltaStats{unchanged: 20 * blockSize, changed: 10},
+			written: 10,
+		},
+		{
+			name:    "ChangedBlock",
+			new:     join(old[:5*blockSize], random(blockSize), old[6*blockSize:]),
+			want:    deltaStats{unchanged: 19 * blockSize, changed: blockSize + 10},
+			written: blockSize + 10,
+		},
+		{
+			name:    "Inserted",
+			new:     join(old[:5*blockSize], random(3), old[5*blockSize:]),
+			want:    deltaStats{unchanged: 5 * blockSize, moved: 15 * blockSize, changed: 13},
+			written: 15*blockSize + 13,
+		},
+		{
+			name:    "LongInsert",
+			new:     join(old[:2*blockSize], random(deltaLiteralFlush+100), old[2*blockSize:]),
+			want:    deltaStats{unchanged: 2 * blockSize, moved: 18 * blockSize, changed: deltaLiteralFlush + 110},
+			written: 18*blockSize + deltaLiteralFlush + 110,
+		},
+		{
+			name:    "Truncated",
+			new:     old[:10*blockSize+7],
+			want:    deltaStats{unchanged: 10 * blockSize, changed: 7},
+			written: 7,
+		},
+		{
+			name:    "Extended",
+			new:     join(old[:20*blockSize], random(3*blockSize)),
+			want:    deltaStats{unchanged: 20 * blockSize, changed: 3 * blockSize},
+			written: 3 * blockSize,
+		},
+		{
+			name:    "AllChanged",
+			new:     random(5*blockSize + 1),
+			want:    deltaStats{changed: 5*blockSize + 1},
+			written: 5*blockSize + 1,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sig, err := newDeltaSignature(bytes.NewReader(old), blockSize)
+			require.NoError(t, err)
+			assert.Len(t, sig.strong, 20)
+
+			// Start with the old contents set to the new size
+			w := &recordWriterAt{memWriterAt: newMemWriterAt(int64(len(test.new)))}
+			copy(w.buf, old)
+			stats, err := applyDelta(sig, bytes.NewReader(test.new), w)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, stats)
+			assert.Equal(t, test.written, w.written)
+			assert.Equal(t, test.new, w.buf)
+		})
+	}
+}
+
+func TestDoDeltaCopy(t *testing.T) {
+	origDelta, origBlockSize := fs.Config.Delta, fs.Config.DeltaBlockSize
+	defer func() {
+		fs.Config.Delta, fs.Config.DeltaBlockSize = origDelta, origBlockSize
+	}()
+	fs.Config.Delta = true
+	fs.Config.DeltaBlockSize = 50
+
+	f := &writerAtFs{writerAt: true}
+	dst := sizedObject{"file", 100}
+	assert.True(t, doDeltaCopy(f, dst, sizedObject{"file", 100}))
+	assert.True(t, doDeltaCopy(f, dst, sizedObject{"file", 50}))
+	assert.False(t, doDeltaCopy(f, dst, sizedObject{"file", 49}))
+	assert.False(t, doDeltaCopy(f, dst, sizedObject{"file", -1}))
+	assert.False(t, doDeltaCopy(f, sizedObject{"file", 10}, sizedObject{"file", 100}))
+	assert.False(t, doDeltaCopy(f, nil, sizedObject{"file", 100}))
+	assert.False(t, doDeltaCopy(&writerAtFs{}, dst, sizedObject{"file", 100}))
+
+	fs.Config.Delta = false
+	assert.False(t, doDeltaCopy(f, dst, sizedObject{"file", 100}))
+}
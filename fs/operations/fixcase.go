@@ -0,0 +1,63 @@
+package operations
+
+import (
+	"path"
+	"strings"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// FixCase renames dst in fdst so its leaf name has the same case as
+// the leaf of srcRemote if the two only differ in case, as used by
+// --fix-case.  It returns the renamed object, or dst if it didn't
+// need renaming.
+//
+// If fdst is case insensitive the rename is done via a temporary name
+// as renaming a file straight to a name which only differs in case
+// may do nothing.  If the rename from the temporary name fails the
+// file is moved back to its original name and the error returned.
+func FixCase(fdst fs.Fs, dst fs.Object, srcRemote string) (fs.Object, error) {
+	dstLeaf, srcLeaf := path.Base(dst.Remote()), path.Base(srcRemote)
+	if dstLeaf == srcLeaf || !strings.EqualFold(dstLeaf, srcLeaf) {
+		return dst, nil
+	}
+	remote := path.Join(path.Dir(dst.Remote()), srcLeaf)
+	doMove := fdst.Features().Move
+	if doMove == nil {
+		return dst, errors.Errorf("can't fix case of %q as the destination can't rename files", dst.Remote())
+	}
+	if fs.Config.DryRun {
+		fs.Logf(dst, "Not fixing case to %q as --dry-run", srcLeaf)
+		return dst, nil
+	}
+	oldRemote := dst.Remote()
+	src, viaTemp := dst, fdst.Features().CaseInsensitive
+	if viaTemp {
+		tmpRemote, err := atomicTempName(fdst, remote)
+		if err != nil {
+			return dst, err
+		}
+		src, err = doMove(dst, tmpRemote)
+		if err != nil {
+			return dst, errors.Wrapf(err, "failed to fix case of %q", oldRemote)
+		}
+	}
+	newDst, err := doMove(src, remote)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to fix case of %q", oldRemote)
+		if !viaTemp {
+			return dst, err
+		}
+		// Put the file back where it was rather than leaving
+		// it with the temporary name
+		restored, undoErr := doMove(src, oldRemote)
+		if undoErr != nil {
+			fs.Errorf(src, "Failed to move back to %q: %v", oldRemote, undoErr)
+			return nil, err
+		}
+		return restored, err
+	}
+	fs.Infof(newDst, "Fixed case by renaming from %q", oldRemote)
+	return newDst, nil
+}
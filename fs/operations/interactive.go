@@ -0,0 +1,86 @@
+package operations
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// ErrorInteractiveQuit is returned when the user chooses to quit at
+// an --interactive prompt
+var ErrorInteractiveQuit = fserrors.FatalError(errors.New("quit from --interactive prompt"))
+
+var (
+	// interactiveMu stops the prompts for concurrent transfers
+	// getting mixed up and protects the variables below
+	interactiveMu sync.Mutex
+	// interactiveAll has the actions the user said yes to all of
+	interactiveAll = map[string]bool{}
+	// interactiveQuit is set if the user has quit
+	interactiveQuit bool
+)
+
+// errorInteractiveNoTerminal is returned if --interactive is set but
+// rclone isn't running in a terminal so can't ask
+var errorInteractiveNoTerminal = fserrors.FatalError(errors.New("can't use --interactive as not running in a terminal"))
+
+// CheckInteractive returns a fatal error if --interactive is set but
+// rclone can't ask the user anything.  Operations which call
+// SkipDestructive should call this before they start so they fail
+// straight away rather than part way through.
+func CheckInteractive() error {
+	if !fs.Config.Interactive || fs.Config.DryRun {
+		return nil
+	}
+	if !config.StdinIsTerminal() {
+		return errorInteractiveNoTerminal
+	}
+	return nil
+}
+
+// SkipDestructive asks the user whether to do action, eg "delete",
+// to subject if --interactive is set.  It returns true if the action
+// should be skipped.
+//
+// It doesn't ask with --dry-run as nothing will be done anyway.
+//
+// If the user answers "all" then it doesn't ask about that action
+// again.  If the user quits, or rclone isn't running in a terminal so
+// can't ask, it returns a fatal error.
+func SkipDestructive(subject interface{}, action string) (skip bool, err error) {
+	if !fs.Config.Interactive || fs.Config.DryRun {
+		return false, nil
+	}
+	interactiveMu.Lock()
+	defer interactiveMu.Unlock()
+	if interactiveQuit {
+		return true, ErrorInteractiveQuit
+	}
+	if interactiveAll[action] {
+		return false, nil
+	}
+	if !config.StdinIsTerminal() {
+		return true, errorInteractiveNoTerminal
+	}
+	fmt.Printf("rclone: %s %q?\n", action, fmt.Sprint(subject))
+	switch config.Command([]string{
+		"yYes, this is OK",
+		"nNo, skip this",
+		fmt.Sprintf("aAll, %s everything else without asking", action),
+		"qQuit rclone now",
+	}) {
+	case 'n':
+		fs.Logf(subject, "Skipped %s", action)
+		return true, nil
+	case 'a':
+		interactiveAll[action] = true
+	case 'q':
+		interactiveQuit = true
+		return true, ErrorInteractiveQuit
+	}
+	return false, nil
+}
@@ -0,0 +1,152 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// MapperMetadata is the metadata of an object which is passed to the
+// --metadata-mapper as JSON on its standard input.
+//
+// The mapper writes it back, changed as it likes, on its standard
+// output.  Only ModTime, MimeType and Metadata are used from this and
+// any which are left out aren't changed.
+type MapperMetadata struct {
+	Remote   string            // path of the object
+	Size     int64             // size in bytes, -1 if unknown
+	ModTime  time.Time         `json:",omitempty"` // modification time
+	MimeType string            `json:",omitempty"` // content type, if known
+	Metadata map[string]string `json:",omitempty"` // custom properties, if any
+}
+
+var (
+	// metadataMapperOnce makes metadataMapperTokens
+	metadataMapperOnce sync.Once
+	// metadataMapperTokens limits the number of mappers running
+	// at once to --transfers
+	metadataMapperTokens chan struct{}
+)
+
+// mappedObject is an fs.Object with the metadata the --metadata-mapper
+// returned for it
+type mappedObject struct {
+	fs.Object
+	modTime  time.Time
+	mimeType string
+	metadata map[string]string
+}
+
+// ModTime returns the mapped modification time
+func (o *mappedObject) ModTime() time.Time {
+	return o.modTime
+}
+
+// MimeType returns the mapped content type or "" if it isn't known
+func (o *mappedObject) MimeType() string {
+	return o.mimeType
+}
+
+// Metadata returns the mapped custom properties
+func (o *mappedObject) Metadata() map[string]string {
+	return o.metadata
+}
+
+// Check interfaces are satisfied
+var (
+	_ fs.MimeTyper  = (*mappedObject)(nil)
+	_ fs.Metadataer = (*mappedObject)(nil)
+)
+
+// MapMetadata runs the --metadata-mapper on the metadata of src and
+// returns src with the metadata it returned.
+//
+// It returns src unchanged if there isn't a --metadata-mapper or if
+// src has been mapped already, so src can be mapped before it is
+// compared with the destination and then passed to Copy.
+func MapMetadata(src fs.Object) (fs.Object, error) {
+	if fs.Config.MetadataMapper == "" {
+		return src, nil
+	}
+	if _, ok := src.(*mappedObject); ok {
+		return src, nil
+	}
+	in := MapperMetadata{
+		Remote:   src.Remote(),
+		Size:     src.Size(),
+		ModTime:  src.ModTime(),
+		MimeType: fs.MimeType(src),
+	}
+	if do, ok := src.(fs.Metadataer); ok {
+		in.Metadata = do.Metadata()
+	}
+	out, err := runMetadataMapper(&in)
+	if err != nil {
+		return nil, err
+	}
+	mapped := &mappedObject{
+		Object:   src,
+		modTime:  in.ModTime,
+		metadata: in.Metadata,
+	}
+	if do, ok := src.(fs.MimeTyper); ok {
+		mapped.mimeType = do.MimeType()
+	}
+	if !out.ModTime.IsZero() {
+		mapped.modTime = out.ModTime
+	}
+	if out.MimeType != "" {
+		mapped.mimeType = out.MimeType
+	}
+	if out.Metadata != nil {
+		mapped.metadata = out.Metadata
+	}
+	fs.Debugf(src, "Mapped metadata to modtime %v, type %q, metadata %v", mapped.modTime, mapped.mimeType, mapped.metadata)
+	return mapped, nil
+}
+
+// runMetadataMapper runs the --metadata-mapper with in and returns
+// what it wrote
+func runMetadataMapper(in *MapperMetadata) (out *MapperMetadata, err error) {
+	metadataMapperOnce.Do(func() {
+		metadataMapperTokens = make(chan struct{}, fs.Config.Transfers)
+	})
+	metadataMapperTokens <- struct{}{}
+	defer func() { <-metadataMapperTokens }()
+
+	input, err := json.Marshal(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "metadata mapper: failed to encode input")
+	}
+	ctx := context.Background()
+	if fs.Config.MetadataMapperTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fs.Config.MetadataMapperTimeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, fs.Config.MetadataMapper)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, errors.Errorf("metadata mapper: timed out after %v", fs.Config.MetadataMapperTimeout)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "metadata mapper failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	out = new(MapperMetadata)
+	err = json.Unmarshal(stdout.Bytes(), out)
+	if err != nil {
+		return nil, errors.Wrap(err, "metadata mapper: failed to decode output")
+	}
+	return out, nil
+}
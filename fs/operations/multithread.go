@@ -0,0 +1,220 @@
+package operations
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/pkg/errors"
+)
+
+const (
+	multithreadChunkSize     = 64 << 10 // streams start on multiples of this
+	multithreadChunkSizeMask = multithreadChunkSize - 1
+	multithreadBufferSize    = 32 * 1024 // buffer used by each stream
+)
+
+// errMultiThreadNoRange is returned if the source ignored the Range
+// request and sent more data than was asked for
+var errMultiThreadNoRange = errors.New("multi-thread copy: source doesn't support ranged reads")
+
+// doMultiThreadCopy returns whether the copy of src into f should be
+// done with multiple streams
+func doMultiThreadCopy(f fs.Info, src fs.Object) bool {
+	// Disable multi-thread copy if not configured
+	if fs.Config.MultiThreadStreams <= 1 {
+		return false
+	}
+	// ...or if the source is too small or of unknown size
+	if src.Size() < int64(fs.Config.MultiThreadCutoff) {
+		return false
+	}
+	// ...or if the destination can't do random writes
+	if f.Features().OpenWriterAt == nil {
+		return false
+	}
+	return true
+}
+
+// multiThreadCopyState is the state of a multi-thread copy
+type multiThreadCopyState struct {
+	ctx      context.Context
+	partSize int64
+	size     int64
+	wc       fs.WriterAtCloser
+	src      fs.Object
+	acc      *accounting.Account
+	streams  int
+}
+
+// copyStream copies the part of the source for stream
+func (mc *multiThreadCopyState) copyStream(stream int) (err error) {
+	start := int64(stream) * mc.partSize
+	if start >= mc.size {
+		return nil
+	}
+	end := start + mc.partSize
+	if end > mc.size {
+		end = mc.size
+	}
+
+	fs.Debugf(mc.src, "multi-thread copy: stream %d/%d (%d-%d) size %v starting", stream+1, mc.streams, start, end, fs.SizeSuffix(end-start))
+
+	rc, err := mc.src.Open(&fs.RangeOption{Start: start, End: end - 1})
+	if err != nil {
+		return errors.Wrap(err, "multi-thread copy: failed to open source")
+	}
+	defer fs.CheckClose(rc, &err)
+
+	// Copy the data in at most multithreadBufferSize pieces so
+	// the memory used is bounded by the number of streams
+	buf := make([]byte, multithreadBufferSize)
+	offset := start
+	for {
+		// Check if context cancelled and exit if so
+		if mc.ctx.Err() != nil {
+			return mc.ctx.Err()
+		}
+		nr, er := rc.Read(buf)
+		if nr > 0 {
+			if offset+int64(nr) > end {
+				return errMultiThreadNoRange
+			}
+			err = mc.acc.AccountRead(nr)
+			if err != nil {
+				return errors.Wrap(err, "multi-thread copy: accounting failed")
+			}
+			nw, ew := mc.wc.WriteAt(buf[0:nr], offset)
+			if nw > 0 {
+				offset += int64(nw)
+			}
+			if ew != nil {
+				return errors.Wrap(ew, "multi-thread copy: write failed")
+			}
+			if nr != nw {
+				return errors.Wrap(io.ErrShortWrite, "multi-thread copy: write failed")
+			}
+		}
+		if er != nil {
+			if er != io.EOF {
+				return errors.Wrap(er, "multi-thread copy: read failed")
+			}
+			break
+		}
+	}
+
+	if offset != end {
+		return errors.Errorf("multi-thread copy: stream %d/%d: read %d bytes but expected %d", stream+1, mc.streams, offset-start, end-start)
+	}
+
+	fs.Debugf(mc.src, "multi-thread copy: stream %d/%d (%d-%d) size %v finished", stream+1, mc.streams, start, end, fs.SizeSuffix(end-start))
+	return nil
+}
+
+// calculateChunks calculates the size of each chunk given the size of
+// the file and the number of streams.  The chunk size is rounded up
+// to a multiple of multithreadChunkSize.
+func (mc *multiThreadCopyState) calculateChunks() {
+	partSize := mc.size / int64(mc.streams)
+	// Round partition size up so partSize * streams >= size
+	if (mc.size % int64(mc.streams)) != 0 {
+		partSize++
+	}
+	// round partSize up to nearest multithreadChunkSize boundary
+	mc.partSize = (partSize + multithreadChunkSizeMask) &^ multithreadChunkSizeMask
+}
+
+// multiThreadCopy copies src to remote in f using streams parallel
+// ranged reads written with OpenWriterAt.
+//
+// If the source turns out not to support ranged reads then it
+// returns errMultiThreadNoRange and the caller should fall back to a
+// normal copy.
+func multiThreadCopy(f fs.Fs, remote string, src fs.Object, streams int) (newDst fs.Object, err error) {
+	openWriterAt := f.Features().OpenWriterAt
+	if openWriterAt == nil {
+		return nil, errors.New("multi-thread copy: OpenWriterAt not supported")
+	}
+	if src.Size() < 0 {
+		return nil, errors.New("multi-thread copy: can't copy unknown sized file")
+	}
+	if src.Size() == 0 {
+		return nil, errors.New("multi-thread copy: can't copy zero sized file")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mc := &multiThreadCopyState{
+		ctx:     ctx,
+		size:    src.Size(),
+		src:     src,
+		streams: streams,
+	}
+	mc.calculateChunks()
+
+	// Make accounting
+	mc.acc = accounting.NewAccount(ioutil.NopCloser(nil), src)
+	defer fs.CheckClose(mc.acc, &err)
+
+	// create write file handle
+	mc.wc, err = openWriterAt(remote, mc.size)
+	if err != nil {
+		return nil, errors.Wrap(err, "multi-thread copy: failed to open destination")
+	}
+
+	fs.Debugf(src, "Starting multi-thread copy with %d parts of size %v", mc.streams, fs.SizeSuffix(mc.partSize))
+
+	// Run the streams, cancelling the others on the first error
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	for stream := 0; stream < mc.streams; stream++ {
+		wg.Add(1)
+		go func(stream int) {
+			defer wg.Done()
+			err := mc.copyStream(stream)
+			if err != nil {
+				errMu.Lock()
+				if firstErr == nil || firstErr == context.Canceled {
+					firstErr = err
+				}
+				errMu.Unlock()
+				cancel()
+			}
+		}(stream)
+	}
+	wg.Wait()
+	err = firstErr
+	closeErr := mc.wc.Close()
+	if err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "multi-thread copy: failed to close object after copy")
+	}
+	if err != nil {
+		// Remove the partially written destination
+		if obj, findErr := f.NewObject(remote); findErr == nil {
+			removeFailedCopy(obj)
+		}
+		return nil, err
+	}
+
+	obj, err := f.NewObject(remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "multi-thread copy: failed to find object after copy")
+	}
+
+	err = obj.SetModTime(src.ModTime())
+	switch err {
+	case nil, fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
+	default:
+		return nil, errors.Wrap(err, "multi-thread copy: failed to set modification time")
+	}
+
+	fs.Debugf(src, "Finished multi-thread copy with %d parts of size %v", mc.streams, fs.SizeSuffix(mc.partSize))
+	return obj, nil
+}
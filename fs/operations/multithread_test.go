@@ -0,0 +1,149 @@
+package operations
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writerAtFs is a minimal fs.Info which may support OpenWriterAt
+type writerAtFs struct {
+	writerAt bool
+}
+
+func (f *writerAtFs) Name() string             { return "writerAt" }
+func (f *writerAtFs) Root() string             { return "" }
+func (f *writerAtFs) String() string           { return "writerAt" }
+func (f *writerAtFs) Precision() time.Duration { return time.Second }
+func (f *writerAtFs) Hashes() hash.Set         { return hash.Set(hash.None) }
+func (f *writerAtFs) Features() *fs.Features {
+	ft := &fs.Features{}
+	if f.writerAt {
+		ft.OpenWriterAt = func(remote string, size int64) (fs.WriterAtCloser, error) {
+			return newMemWriterAt(size), nil
+		}
+	}
+	return ft
+}
+
+// memWriterAt is an in memory fs.WriterAtCloser
+type memWriterAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func newMemWriterAt(size int64) *memWriterAt {
+	return &memWriterAt{buf: make([]byte, size)}
+}
+
+func (w *memWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return copy(w.buf[off:], p), nil
+}
+
+func (w *memWriterAt) Close() error { return nil }
+
+// sizedObject is a mock object with a size
+type sizedObject struct {
+	mockobject.Object
+	size int64
+}
+
+func (o sizedObject) Size() int64 { return o.size }
+
+func TestDoMultiThreadCopy(t *testing.T) {
+	origStreams, origCutoff := fs.Config.MultiThreadStreams, fs.Config.MultiThreadCutoff
+	defer func() {
+		fs.Config.MultiThreadStreams, fs.Config.MultiThreadCutoff = origStreams, origCutoff
+	}()
+	fs.Config.MultiThreadStreams = 4
+	fs.Config.MultiThreadCutoff = 50
+
+	f := &writerAtFs{writerAt: true}
+	assert.True(t, doMultiThreadCopy(f, sizedObject{"file", 100}))
+	assert.True(t, doMultiThreadCopy(f, sizedObject{"file", 50}))
+	assert.False(t, doMultiThreadCopy(f, sizedObject{"file", 49}))
+	assert.False(t, doMultiThreadCopy(f, sizedObject{"file", -1}))
+	assert.False(t, doMultiThreadCopy(&writerAtFs{}, sizedObject{"file", 100}))
+
+	fs.Config.MultiThreadStreams = 1
+	assert.False(t, doMultiThreadCopy(f, sizedObject{"file", 100}))
+}
+
+func TestMultithreadCalculateChunks(t *testing.T) {
+	for _, test := range []struct {
+		size         int64
+		streams      int
+		wantPartSize int64
+	}{
+		{size: 1, streams: 10, wantPartSize: multithreadChunkSize},
+		{size: 1 << 20, streams: 1, wantPartSize: 1 << 20},
+		{size: 1 << 20, streams: 2, wantPartSize: 1 << 19},
+		{size: (1 << 20) + 1, streams: 2, wantPartSize: (1 << 19) + multithreadChunkSize},
+		{size: (1 << 20) - 1, streams: 2, wantPartSize: (1 << 19)},
+	} {
+		mc := &multiThreadCopyState{
+			size:    test.size,
+			streams: test.streams,
+		}
+		mc.calculateChunks()
+		assert.Equal(t, test.wantPartSize, mc.partSize, "size=%d streams=%d", test.size, test.streams)
+		assert.True(t, mc.partSize*int64(mc.streams) >= mc.size)
+	}
+}
+
+// copyStreams runs all the streams of mc one after the other
+func copyStreams(mc *multiThreadCopyState) (err error) {
+	for stream := 0; stream < mc.streams; stream++ {
+		err = mc.copyStream(stream)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestMultithreadCopyStream(t *testing.T) {
+	content := make([]byte, 3*multithreadChunkSize+17)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	for _, test := range []struct {
+		seekMode mockobject.SeekMode
+		wantErr  error
+	}{
+		{mockobject.SeekModeNone, nil},
+		// SeekModeRegular ignores the end of the range
+		{mockobject.SeekModeRegular, errMultiThreadNoRange},
+	} {
+		src := mockobject.Object("file").WithContent(content, test.seekMode)
+		wc := newMemWriterAt(src.Size())
+		mc := &multiThreadCopyState{
+			ctx:     context.Background(),
+			size:    src.Size(),
+			src:     src,
+			wc:      wc,
+			streams: 3,
+			acc:     accounting.NewAccountSizeName(ioutil.NopCloser(nil), src.Size(), "file"),
+		}
+		mc.calculateChunks()
+		err := copyStreams(mc)
+		require.NoError(t, mc.acc.Close())
+		if test.wantErr != nil {
+			assert.Equal(t, test.wantErr, err, test.seekMode.String())
+			continue
+		}
+		require.NoError(t, err, test.seekMode.String())
+		assert.Equal(t, content, wc.buf, test.seekMode.String())
+	}
+}
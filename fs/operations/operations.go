@@ -4,7 +4,9 @@ package operations
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/csv"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -41,7 +43,11 @@ import (
 //
 // If an error is returned it will return equal as false
 func CheckHashes(src fs.ObjectInfo, dst fs.Object) (equal bool, ht hash.Type, err error) {
-	common := src.Fs().Hashes().Overlap(dst.Fs().Hashes())
+	return checkHashes(src, dst, src.Fs().Hashes().Overlap(dst.Fs().Hashes()))
+}
+
+// checkHashes is CheckHashes using one of the hash types in common
+func checkHashes(src fs.ObjectInfo, dst fs.Object, common hash.Set) (equal bool, ht hash.Type, err error) {
 	// fs.Debugf(nil, "Shared hashes: %v", common)
 	if common.Count() == 0 {
 		return true, hash.None, nil
@@ -80,6 +86,9 @@ func CheckHashes(src fs.ObjectInfo, dst fs.Object) (equal bool, ht hash.Type, er
 // that is done.  If --ignore-size is in effect then this check is
 // skipped and the files are considered the same size.
 //
+// If --size-and-free-hash is in effect then the hash is checked too,
+// but only if src and dst both have one which is free to read.
+//
 // If the size is the same and the mtime is the same then it is
 // considered to be equal.  This check is skipped if using --checksum.
 //
@@ -94,6 +103,65 @@ func Equal(src fs.ObjectInfo, dst fs.Object) bool {
 	return equal(src, dst, fs.Config.SizeOnly, fs.Config.CheckSum)
 }
 
+// equalServerModTime is the modification time check for
+// --modtime-source source.  The source's modification time wins, so
+// dst is the same if it was written on the server at or after the
+// source was last modified.  The hashes are checked otherwise, but the
+// modification time of dst isn't set as it can't change the server's
+// time.
+//
+// dstServerModTime is corrected for any --clock-skew of the server.
+func equalServerModTime(src fs.ObjectInfo, dst fs.Object, dstServerModTime time.Time, modifyWindow time.Duration) bool {
+	if modifyWindow == fs.ModTimeNotSupported {
+		modifyWindow = time.Second
+	}
+	dstServerModTime = dstServerModTime.Add(-ClockSkew(dst.Fs()))
+	srcModTime := src.ModTime()
+	dt := dstServerModTime.Sub(srcModTime)
+	if dt > -modifyWindow {
+		fs.Debugf(src, "Size the same and destination written after source modified (by %s)", dt)
+		return true
+	}
+	fs.Debugf(src, "Destination written %s before source modified: %v, %v", -dt, srcModTime, dstServerModTime)
+	same, ht, _ := CheckHashes(src, dst)
+	if !same {
+		fs.Debugf(src, "%v differ", ht)
+		return false
+	}
+	if ht == hash.None {
+		return false
+	}
+	fs.Debugf(src, "Size and %v of src and dst objects identical", ht)
+	return true
+}
+
+// freeHashes returns the hashes of f which can be read without
+// reading the data or asking the server to compute them
+func freeHashes(f fs.Info) hash.Set {
+	if f.Features().SlowHash {
+		return hash.Set(hash.None)
+	}
+	return f.Hashes()
+}
+
+// equalFreeHash is the check for --size-and-free-hash once the sizes
+// are found to be the same.  The hashes are compared if src and dst
+// have one in common which is free to read, otherwise they are
+// considered equal as with --size-only.
+func equalFreeHash(src fs.ObjectInfo, dst fs.Object) bool {
+	same, ht, _ := checkHashes(src, dst, freeHashes(src.Fs()).Overlap(freeHashes(dst.Fs())))
+	if !same {
+		fs.Debugf(src, "%v differ", ht)
+		return false
+	}
+	if ht == hash.None {
+		fs.Debugf(src, "Sizes identical")
+	} else {
+		fs.Debugf(src, "Size and %v of src and dst objects identical", ht)
+	}
+	return true
+}
+
 // sizeDiffers compare the size of src and dst taking into account the
 // various ways of ignoring sizes
 func sizeDiffers(src, dst fs.ObjectInfo) bool {
@@ -110,8 +178,14 @@ func equal(src fs.ObjectInfo, dst fs.Object, sizeOnly, checkSum bool) bool {
 	}
 	if sizeOnly {
 		fs.Debugf(src, "Sizes identical")
+		if fs.Config.RefreshTimes {
+			refreshModTime(src, dst)
+		}
 		return true
 	}
+	if fs.Config.SizeAndFreeHash {
+		return equalFreeHash(src, dst)
+	}
 
 	// Assert: Size is equal or being ignored
 
@@ -128,17 +202,25 @@ func equal(src fs.ObjectInfo, dst fs.Object, sizeOnly, checkSum bool) bool {
 		} else {
 			fs.Debugf(src, "Size and %v of src and dst objects identical", ht)
 		}
+		if fs.Config.RefreshTimes {
+			refreshModTime(src, dst)
+		}
 		return true
 	}
 
 	// Sizes the same so check the mtime
 	modifyWindow := fs.GetModifyWindow(src.Fs(), dst.Fs())
+	if fs.Config.ModTimeSource == fs.ModTimeSourceSource {
+		if do, ok := dst.(fs.ServerModTimer); ok {
+			return equalServerModTime(src, dst, do.ServerModTime(), modifyWindow)
+		}
+	}
 	if modifyWindow == fs.ModTimeNotSupported {
 		fs.Debugf(src, "Sizes identical")
 		return true
 	}
-	srcModTime := src.ModTime()
-	dstModTime := dst.ModTime()
+	srcModTime := localModTime(src)
+	dstModTime := localModTime(dst)
 	dt := dstModTime.Sub(srcModTime)
 	if dt < modifyWindow && dt > -modifyWindow {
 		fs.Debugf(src, "Size and modification time the same (differ by %s, within tolerance %s)", dt, modifyWindow)
@@ -196,6 +278,41 @@ func equal(src fs.ObjectInfo, dst fs.Object, sizeOnly, checkSum bool) bool {
 	return true
 }
 
+// refreshModTime sets the modification time of dst to that of src if
+// they differ, as requested by --refresh-times.
+//
+// src and dst have already been found equal so this never causes a
+// transfer, even if the remote can't set the modification time.
+func refreshModTime(src fs.ObjectInfo, dst fs.Object) {
+	modifyWindow := fs.GetModifyWindow(src.Fs(), dst.Fs())
+	if modifyWindow == fs.ModTimeNotSupported {
+		return
+	}
+	srcModTime := src.ModTime()
+	dt := dst.ModTime().Sub(srcModTime)
+	if dt < modifyWindow && dt > -modifyWindow {
+		return
+	}
+	if fs.Config.DryRun {
+		fs.Logf(dst, "Not refreshing modification time as --dry-run")
+		return
+	}
+	if fs.Config.Immutable {
+		fs.Errorf(dst, "Not refreshing modification time of immutable object")
+		return
+	}
+	err := dst.SetModTime(srcModTime)
+	switch err {
+	case nil:
+		fs.Infof(dst, "Refreshed modification time (differed by %s)", dt)
+	case fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
+		fs.Debugf(dst, "Can't refresh modification time without re-uploading")
+	default:
+		fs.CountError(err)
+		fs.Errorf(dst, "Failed to refresh modification time: %v", err)
+	}
+}
+
 // Used to remove a failed copy
 //
 // Returns whether the file was succesfully removed or not
@@ -212,6 +329,79 @@ func removeFailedCopy(dst fs.Object) bool {
 	return true
 }
 
+// atomicWarnOnce makes sure the warning that --atomic can't be used
+// is only given once
+var atomicWarnOnce sync.Once
+
+// atomicTempName returns an unused temporary name in f to upload
+// remote to with --atomic
+func atomicTempName(f fs.Fs, remote string) (string, error) {
+	var random [4]byte
+	for i := 0; i < 10; i++ {
+		_, err := rand.Read(random[:])
+		if err != nil {
+			return "", errors.Wrap(err, "failed to make temporary name")
+		}
+		tmpRemote := remote + ".rclone-" + hex.EncodeToString(random[:]) + ".partial"
+		_, err = f.NewObject(tmpRemote)
+		if err == fs.ErrorObjectNotFound {
+			return tmpRemote, nil
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "failed to check temporary name")
+		}
+		fs.Debugf(f, "Temporary name %q in use - trying another", tmpRemote)
+	}
+	return "", errors.Errorf("failed to find an unused temporary name for %q", remote)
+}
+
+// removeAtomicTemp removes the temporary upload tmpRemote if it exists
+func removeAtomicTemp(f fs.Fs, tmpRemote string) {
+	if tmp, err := f.NewObject(tmpRemote); err == nil {
+		removeFailedCopy(tmp)
+	}
+}
+
+// atomicRename renames tmp, uploaded with --atomic, to remote,
+// replacing old if set.
+//
+// The rename is tried over the top of old first.  Only if that fails
+// is old removed and the rename tried again, in which case tmp is kept
+// if the rename still fails so the new data isn't lost along with the
+// old.  Otherwise tmp is removed if this fails.
+func atomicRename(f fs.Fs, old, tmp fs.Object, remote string) (fs.Object, error) {
+	fs.Debugf(tmp, "Renaming temporary upload to %q", remote)
+	doMove := f.Features().Move
+	newDst, err := doMove(tmp, remote)
+	if err == nil {
+		// Remotes which allow duplicate names keep old alongside
+		// the renamed upload rather than replacing it
+		if old != nil && f.Features().DuplicateFiles {
+			err = old.Remove()
+			if err != nil {
+				return newDst, errors.Wrap(err, "failed to remove existing file after renaming temporary upload")
+			}
+		}
+		return newDst, nil
+	}
+	if old == nil {
+		removeFailedCopy(tmp)
+		return nil, errors.Wrap(err, "failed to rename temporary upload")
+	}
+	fs.Debugf(tmp, "Failed to rename temporary upload over existing file - removing it and retrying: %v", err)
+	err = old.Remove()
+	if err != nil {
+		removeFailedCopy(tmp)
+		return nil, errors.Wrap(err, "failed to remove existing file to rename temporary upload")
+	}
+	newDst, err = doMove(tmp, remote)
+	if err != nil {
+		// The existing file has gone so keep the upload
+		return nil, errors.Wrapf(err, "failed to rename temporary upload - new contents left in %q", tmp.Remote())
+	}
+	return newDst, nil
+}
+
 // Wrapper to override the remote for an object
 type overrideRemoteObject struct {
 	fs.Object
@@ -232,8 +422,20 @@ func (o *overrideRemoteObject) MimeType() string {
 	return ""
 }
 
-// Check interface is satisfied
-var _ fs.MimeTyper = (*overrideRemoteObject)(nil)
+// Metadata returns the custom properties of the underlying object or
+// nil if it doesn't have any
+func (o *overrideRemoteObject) Metadata() map[string]string {
+	if do, ok := o.Object.(fs.Metadataer); ok {
+		return do.Metadata()
+	}
+	return nil
+}
+
+// Check interfaces are satisfied
+var (
+	_ fs.MimeTyper  = (*overrideRemoteObject)(nil)
+	_ fs.Metadataer = (*overrideRemoteObject)(nil)
+)
 
 // Copy src object to dst or f if nil.  If dst is nil then it uses
 // remote as the name of the new object.
@@ -246,6 +448,16 @@ func Copy(f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Objec
 		fs.Logf(src, "Not copying as --dry-run")
 		return newDst, nil
 	}
+	// Wait for any per backend transfer limits
+	defer acquireTransfer(f, src.Fs())()
+	// Transform the metadata with any --metadata-mapper
+	mapped, err := MapMetadata(src)
+	if err != nil {
+		fs.CountError(err)
+		fs.Errorf(src, "Failed to copy: %v", err)
+		return newDst, err
+	}
+	src = mapped
 	maxTries := fs.Config.LowLevelRetries
 	tries := 0
 	doUpdate := dst != nil
@@ -260,12 +472,34 @@ func Copy(f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Objec
 		}
 	}
 	hashOption := &fs.HashesOption{Hashes: common}
+	// With --inplace an existing destination is always updated
+	// rather than being replaced with a new object
+	inplace := fs.Config.Inplace && doUpdate
+	// With --delta existing destinations are updated in place by
+	// writing only the blocks which have changed
+	useDelta := doDeltaCopy(f, dst, src)
+	multiThread := !inplace && !useDelta && doMultiThreadCopy(f, src)
+	// With --atomic uploads are made to a temporary name which is
+	// renamed to remote once the upload has been checked
+	useAtomic := fs.Config.Atomic && !inplace && !useDelta && f.Features().Move != nil
+	if fs.Config.Atomic && f.Features().Move == nil {
+		atomicWarnOnce.Do(func() {
+			fs.Logf(f, "Ignoring --atomic as the remote can't rename files")
+		})
+	}
+	// Downloads to the local disk are made to a partial file which
+	// can be resumed if the copy is interrupted
+	usePartial := !inplace && !useDelta && !multiThread && !useAtomic && doPartialCopy(f, src)
+	oldDst := dst
+	var atomicRemote string
 	var actionTaken string
 	for {
 		// Try server side copy first - if has optional interface and
 		// is same underlying remote
 		actionTaken = "Copied (server side copy)"
-		if doCopy := f.Features().Copy; doCopy != nil && SameConfig(src.Fs(), f) {
+		// Server side copies keep the original metadata so
+		// can't be used with a --metadata-mapper
+		if doCopy := f.Features().Copy; doCopy != nil && !inplace && fs.Config.MetadataMapper == "" && SameConfig(src.Fs(), f) {
 			newDst, err = doCopy(src, remote)
 			if err == nil {
 				dst = newDst
@@ -273,6 +507,73 @@ func Copy(f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Objec
 		} else {
 			err = fs.ErrorCantCopy
 		}
+		uploadRemote := remote
+		if err == fs.ErrorCantCopy && useAtomic {
+			var tmpErr error
+			atomicRemote, tmpErr = atomicTempName(f, remote)
+			if tmpErr != nil {
+				err = tmpErr
+			} else {
+				uploadRemote = atomicRemote
+			}
+		}
+		// If can't server side copy, try a delta copy
+		if err == fs.ErrorCantCopy && useDelta {
+			actionTaken = "Copied (delta)"
+			var deltaDst fs.Object
+			deltaDst, err = deltaCopy(f, dst, src, hashOption)
+			if err == nil {
+				dst = deltaDst
+				newDst = dst
+			}
+		}
+		// If can't server side copy, try a multi-thread copy
+		if err == fs.ErrorCantCopy && multiThread {
+			if doUpdate {
+				actionTaken = "Multi-thread Copied (replaced existing)"
+			} else {
+				actionTaken = "Multi-thread Copied (new)"
+			}
+			var mtDst fs.Object
+			mtDst, err = multiThreadCopy(f, uploadRemote, src, fs.Config.MultiThreadStreams)
+			if err == nil {
+				dst = mtDst
+				newDst = dst
+			} else if errors.Cause(err) == errMultiThreadNoRange {
+				fs.Debugf(src, "Falling back to single stream copy: %v", err)
+				multiThread = false
+				err = fs.ErrorCantCopy
+			}
+			// Any existing destination was truncated and then
+			// removed if the copy failed
+			doUpdate = false
+		}
+		// If can't server side copy, try a resumable copy
+		if err == fs.ErrorCantCopy && usePartial {
+			// Replace the existing object which may have a
+			// differently normalized name
+			partialTarget := remote
+			if doUpdate {
+				partialTarget = dst.Remote()
+			}
+			var (
+				partialDst fs.Object
+				offset     int64
+			)
+			partialDst, offset, err = partialCopy(f, partialTarget, src, hashOption)
+			switch {
+			case offset > 0:
+				actionTaken = "Copied (resumed)"
+			case doUpdate:
+				actionTaken = "Copied (replaced existing)"
+			default:
+				actionTaken = "Copied (new)"
+			}
+			if err == nil {
+				dst = partialDst
+				newDst = dst
+			}
+		}
 		// If can't server side copy, do it manually
 		if err == fs.ErrorCantCopy {
 			var in0 io.ReadCloser
@@ -283,10 +584,10 @@ func Copy(f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Objec
 				in := accounting.NewAccount(in0, src).WithBuffer() // account and buffer the transfer
 				var wrappedSrc fs.ObjectInfo = src
 				// We try to pass the original object if possible
-				if src.Remote() != remote {
-					wrappedSrc = &overrideRemoteObject{Object: src, remote: remote}
+				if src.Remote() != uploadRemote {
+					wrappedSrc = &overrideRemoteObject{Object: src, remote: uploadRemote}
 				}
-				if doUpdate {
+				if doUpdate && !useAtomic {
 					actionTaken = "Copied (replaced existing)"
 					err = dst.Update(in, wrappedSrc, hashOption)
 				} else {
@@ -300,6 +601,9 @@ func Copy(f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Objec
 				}
 			}
 		}
+		if err != nil && uploadRemote != remote {
+			removeAtomicTemp(f, uploadRemote)
+		}
 		tries++
 		if tries >= maxTries {
 			break
@@ -352,6 +656,15 @@ func Copy(f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Objec
 		}
 	}
 
+	if atomicRemote != "" && dst != nil && dst.Remote() == atomicRemote {
+		newDst, err = atomicRename(f, oldDst, dst, remote)
+		if err != nil {
+			fs.CountError(err)
+			fs.Errorf(src, "Failed to copy: %v", err)
+			return nil, err
+		}
+	}
+
 	fs.Infof(src, actionTaken)
 	return newDst, err
 }
@@ -367,8 +680,10 @@ func Move(fdst fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Ob
 		fs.Logf(src, "Not moving as --dry-run")
 		return newDst, nil
 	}
-	// See if we have Move available
-	if doMove := fdst.Features().Move; doMove != nil && SameConfig(src.Fs(), fdst) {
+	// See if we have Move available - with --inplace an existing
+	// destination is updated by the copy below instead, as are all
+	// files with a --metadata-mapper
+	if doMove := fdst.Features().Move; doMove != nil && !(fs.Config.Inplace && dst != nil) && fs.Config.MetadataMapper == "" && SameConfig(src.Fs(), fdst) {
 		// Delete destination if it exists
 		if dst != nil {
 			err = DeleteFile(dst)
@@ -411,6 +726,116 @@ func CanServerSideMove(fdst fs.Fs) bool {
 	return canMove || canCopy
 }
 
+// SuffixName adds the current --suffix to the remote, obeying
+// --suffix-keep-extension if set.
+//
+// With --suffix-keep-extension the suffix is inserted before the
+// final extension of the file name, so "file.txt" becomes
+// "file-suffix.txt".  If the name ends in one of the
+// --suffix-compound-extensions, eg ".tar.gz", then the suffix is
+// inserted before that instead.  Names without an extension, and
+// names like ".bashrc" which are only an extension, have the suffix
+// added to the end.
+func SuffixName(remote string) string {
+	suffix := fs.Config.Suffix
+	if suffix == "" || !fs.Config.SuffixKeepExtension {
+		return remote + suffix
+	}
+	dir, base := path.Split(remote)
+	ext := ""
+	lowerBase := strings.ToLower(base)
+	for _, compound := range fs.Config.CompoundExtensions {
+		if len(compound) > len(ext) && strings.HasSuffix(lowerBase, strings.ToLower(compound)) {
+			ext = base[len(base)-len(compound):]
+		}
+	}
+	if ext == "" {
+		ext = path.Ext(base)
+	}
+	if ext == "" || ext == base {
+		return remote + suffix
+	}
+	return dir + base[:len(base)-len(ext)] + suffix + ext
+}
+
+// ErrorMaxDeleteLimitReached is returned when the deletes planned by a
+// sync would exceed --max-delete or --max-delete-size
+var ErrorMaxDeleteLimitReached = fserrors.FatalError(errors.New("not deleting anything as the deletes would exceed --max-delete or --max-delete-size"))
+
+// deleteSampleSize is the number of files logged when the planned
+// deletes exceed the limits
+const deleteSampleSize = 10
+
+// countDelete adds dst to the delete stats, returning a fatal error if
+// that takes them over --max-delete or --max-delete-size
+func countDelete(dst fs.Object) error {
+	numDeletes := accounting.Stats.Deletes(1)
+	if fs.Config.MaxDelete != -1 && numDeletes > fs.Config.MaxDelete {
+		return fserrors.FatalError(errors.New("--max-delete threshold reached"))
+	}
+	size := dst.Size()
+	if size < 0 {
+		size = 0
+	}
+	deletedBytes := accounting.Stats.DeletedBytes(size)
+	if fs.Config.MaxDeleteSize != -1 && deletedBytes > int64(fs.Config.MaxDeleteSize) {
+		return fserrors.FatalError(errors.New("--max-delete-size threshold reached"))
+	}
+	return nil
+}
+
+// objectsByRemote sorts a slice of objects by their remote name
+type objectsByRemote []fs.Object
+
+// Len is part of sort.Interface.
+func (objs objectsByRemote) Len() int { return len(objs) }
+
+// Swap is part of sort.Interface.
+func (objs objectsByRemote) Swap(i, j int) { objs[i], objs[j] = objs[j], objs[i] }
+
+// Less is part of sort.Interface.
+func (objs objectsByRemote) Less(i, j int) bool { return objs[i].Remote() < objs[j].Remote() }
+
+// CheckDeleteLimits checks the complete set of objects about to be
+// deleted against --max-delete and --max-delete-size, counting any
+// deletes already done.
+//
+// If either limit would be exceeded it logs a sample of the objects
+// and returns ErrorMaxDeleteLimitReached so nothing gets deleted.
+func CheckDeleteLimits(toDelete []fs.Object) error {
+	if fs.Config.MaxDelete == -1 && fs.Config.MaxDeleteSize == -1 {
+		return nil
+	}
+	numDeletes := accounting.Stats.Deletes(0) + int64(len(toDelete))
+	deletedBytes := accounting.Stats.DeletedBytes(0)
+	for _, o := range toDelete {
+		if size := o.Size(); size > 0 {
+			deletedBytes += size
+		}
+	}
+	switch {
+	case fs.Config.MaxDelete != -1 && numDeletes > fs.Config.MaxDelete:
+		fs.Errorf(nil, "Would delete %d files which is more than --max-delete %d", numDeletes, fs.Config.MaxDelete)
+	case fs.Config.MaxDeleteSize != -1 && deletedBytes > int64(fs.Config.MaxDeleteSize):
+		fs.Errorf(nil, "Would delete %v which is more than --max-delete-size %v", fs.SizeSuffix(deletedBytes), fs.Config.MaxDeleteSize)
+	default:
+		return nil
+	}
+	sample := make([]fs.Object, len(toDelete))
+	copy(sample, toDelete)
+	sort.Sort(objectsByRemote(sample))
+	if len(sample) > deleteSampleSize {
+		sample = sample[:deleteSampleSize]
+	}
+	for _, o := range sample {
+		fs.Logf(o, "Would have deleted")
+	}
+	if more := len(toDelete) - len(sample); more > 0 {
+		fs.Logf(nil, "...and %d more files would have been deleted", more)
+	}
+	return ErrorMaxDeleteLimitReached
+}
+
 // DeleteFileWithBackupDir deletes a single file respecting --dry-run
 // and accumulating stats and errors.
 //
@@ -418,9 +843,9 @@ func CanServerSideMove(fdst fs.Fs) bool {
 // deleting
 func DeleteFileWithBackupDir(dst fs.Object, backupDir fs.Fs) (err error) {
 	accounting.Stats.Checking(dst.Remote())
-	numDeletes := accounting.Stats.Deletes(1)
-	if fs.Config.MaxDelete != -1 && numDeletes > fs.Config.MaxDelete {
-		return fserrors.FatalError(errors.New("--max-delete threshold reached"))
+	err = countDelete(dst)
+	if err != nil {
+		return err
 	}
 	action, actioned, actioning := "delete", "Deleted", "deleting"
 	if backupDir != nil {
@@ -432,7 +857,7 @@ func DeleteFileWithBackupDir(dst fs.Object, backupDir fs.Fs) (err error) {
 		if !SameConfig(dst.Fs(), backupDir) {
 			err = errors.New("parameter to --backup-dir has to be on the same remote as destination")
 		} else {
-			remoteWithSuffix := dst.Remote() + fs.Config.Suffix
+			remoteWithSuffix := SuffixName(dst.Remote())
 			overwritten, _ := backupDir.NewObject(remoteWithSuffix)
 			_, err = Move(backupDir, overwritten, remoteWithSuffix, dst)
 		}
@@ -457,22 +882,131 @@ func DeleteFile(dst fs.Object) (err error) {
 	return DeleteFileWithBackupDir(dst, nil)
 }
 
+// removeBatchSize is the number of objects collected before they are
+// passed to RemoveBatch
+const removeBatchSize = 1000
+
+// deleteBatcher collects the objects to be deleted from remotes which
+// support RemoveBatch so they can be deleted in batches
+type deleteBatcher struct {
+	mu      sync.Mutex
+	pending map[fs.Info][]fs.Object
+}
+
+// newDeleteBatcher makes a new deleteBatcher
+func newDeleteBatcher() *deleteBatcher {
+	return &deleteBatcher{
+		pending: make(map[fs.Info][]fs.Object),
+	}
+}
+
+// add dst to the batch for its remote.  If that makes a full batch
+// then it is returned for deletion.
+//
+// It returns an error if --max-delete or --max-delete-size has been
+// exceeded.
+func (b *deleteBatcher) add(dst fs.Object) (batch []fs.Object, err error) {
+	accounting.Stats.Checking(dst.Remote())
+	err = countDelete(dst)
+	if err != nil {
+		accounting.Stats.DoneChecking(dst.Remote())
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f := dst.Fs()
+	b.pending[f] = append(b.pending[f], dst)
+	if len(b.pending[f]) >= removeBatchSize {
+		batch = b.pending[f]
+		delete(b.pending, f)
+	}
+	return batch, nil
+}
+
+// flush returns all the batches which haven't been deleted yet
+func (b *deleteBatcher) flush() (batches [][]fs.Object) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for f, batch := range b.pending {
+		batches = append(batches, batch)
+		delete(b.pending, f)
+	}
+	return batches
+}
+
+// deleteBatch deletes the objects, which must all be from the same
+// remote, with RemoveBatch and returns the number which failed
+func deleteBatch(objs []fs.Object) (errorCount int32) {
+	fs.Debugf(objs[0].Fs(), "Deleting batch of %d files", len(objs))
+	errs := objs[0].Fs().Features().RemoveBatch(objs)
+	for i, dst := range objs {
+		if err := errs[i]; err != nil {
+			fs.CountError(err)
+			fs.Errorf(dst, "Couldn't delete: %v", err)
+			errorCount++
+		} else {
+			fs.Infof(dst, "Deleted")
+		}
+		accounting.Stats.DoneChecking(dst.Remote())
+	}
+	return errorCount
+}
+
 // DeleteFilesWithBackupDir removes all the files passed in the
 // channel
 //
 // If backupDir is set the files will be placed into that directory
 // instead of being deleted.
+//
+// With --interactive the user is asked before each file is deleted.
+// If they quit, the rest of the files are read from the channel but
+// not deleted.
+//
+// Files on remotes which support RemoveBatch are collected and
+// deleted in batches.
 func DeleteFilesWithBackupDir(toBeDeleted fs.ObjectsChan, backupDir fs.Fs) error {
+	if err := CheckInteractive(); err != nil {
+		// Drain the channel so the sender doesn't block
+		for range toBeDeleted {
+		}
+		return err
+	}
 	var wg sync.WaitGroup
 	wg.Add(fs.Config.Transfers)
 	var errorCount int32
 	var fatalErrorCount int32
+	var interactiveErrOnce sync.Once
+	var interactiveErr error
+	batcher := newDeleteBatcher()
+	action := "delete"
+	if backupDir != nil {
+		action = "move into backup dir"
+	}
 
 	for i := 0; i < fs.Config.Transfers; i++ {
 		go func() {
 			defer wg.Done()
 			for dst := range toBeDeleted {
-				err := DeleteFileWithBackupDir(dst, backupDir)
+				skip, err := SkipDestructive(dst, action)
+				if err != nil {
+					interactiveErrOnce.Do(func() {
+						interactiveErr = err
+					})
+					// Keep reading so the sender doesn't block
+					continue
+				}
+				if skip {
+					continue
+				}
+				if backupDir == nil && !fs.Config.DryRun && dst.Fs().Features().RemoveBatch != nil {
+					var batch []fs.Object
+					batch, err = batcher.add(dst)
+					if batch != nil {
+						atomic.AddInt32(&errorCount, deleteBatch(batch))
+					}
+				} else {
+					err = DeleteFileWithBackupDir(dst, backupDir)
+				}
 				if err != nil {
 					atomic.AddInt32(&errorCount, 1)
 					if fserrors.IsFatalError(err) {
@@ -486,6 +1020,12 @@ func DeleteFilesWithBackupDir(toBeDeleted fs.ObjectsChan, backupDir fs.Fs) error
 	}
 	fs.Infof(nil, "Waiting for deletions to finish")
 	wg.Wait()
+	for _, batch := range batcher.flush() {
+		errorCount += deleteBatch(batch)
+	}
+	if interactiveErr != nil {
+		return interactiveErr
+	}
 	if errorCount > 0 {
 		err := errors.Errorf("failed to delete %d files", errorCount)
 		if fatalErrorCount > 0 {
@@ -539,7 +1079,7 @@ func checkIdentical(dst, src fs.Object) (differ bool, noHash bool) {
 	same, ht, err := CheckHashes(src, dst)
 	if err != nil {
 		// CheckHashes will log and count errors
-		return true, false
+		return true, true
 	}
 	if ht == hash.None {
 		return false, true
@@ -554,20 +1094,48 @@ func checkIdentical(dst, src fs.Object) (differ bool, noHash bool) {
 }
 
 // checkFn is the the type of the checking function used in CheckFn()
+//
+// If it returns differ and noHash then the check failed with an error.
 type checkFn func(a, b fs.Object) (differ bool, noHash bool)
 
+// Status characters written to the combined report of a check
+const (
+	CheckMatch   = '=' // file is identical in source and destination
+	CheckDiffer  = '*' // file is in source and destination but differs
+	CheckDstOnly = '+' // file is in the destination only
+	CheckSrcOnly = '-' // file is in the source only
+	CheckError   = '!' // there was an error checking the file
+)
+
 // checkMarch is used to march over two Fses in the same way as
 // sync/copy
 type checkMarch struct {
 	fdst, fsrc      fs.Fs
 	check           checkFn
 	oneway          bool
+	combinedMu      sync.Mutex // protects combined
+	combined        io.Writer  // if set, write a line for each file checked here
 	differences     int32
 	noHashes        int32
 	srcFilesMissing int32
 	dstFilesMissing int32
 }
 
+// report writes a line with status and the path of entry to the
+// combined report if there is one
+func (c *checkMarch) report(status rune, entry fs.DirEntry) {
+	if c.combined == nil {
+		return
+	}
+	c.combinedMu.Lock()
+	defer c.combinedMu.Unlock()
+	_, err := fmt.Fprintf(c.combined, "%c %s\n", status, entry.Remote())
+	if err != nil {
+		fs.Errorf(nil, "Failed to write combined report: %v", err)
+		c.combined = nil
+	}
+}
+
 // DstOnly have an object which is in the destination only
 func (c *checkMarch) DstOnly(dst fs.DirEntry) (recurse bool) {
 	switch dst.(type) {
@@ -580,6 +1148,7 @@ func (c *checkMarch) DstOnly(dst fs.DirEntry) (recurse bool) {
 		fs.CountError(err)
 		atomic.AddInt32(&c.differences, 1)
 		atomic.AddInt32(&c.srcFilesMissing, 1)
+		c.report(CheckDstOnly, dst)
 	case fs.Directory:
 		// Do the same thing to the entire contents of the directory
 		return true
@@ -598,6 +1167,7 @@ func (c *checkMarch) SrcOnly(src fs.DirEntry) (recurse bool) {
 		fs.CountError(err)
 		atomic.AddInt32(&c.differences, 1)
 		atomic.AddInt32(&c.dstFilesMissing, 1)
+		c.report(CheckSrcOnly, src)
 	case fs.Directory:
 		// Do the same thing to the entire contents of the directory
 		return true
@@ -630,6 +1200,14 @@ func (c *checkMarch) Match(dst, src fs.DirEntry) (recurse bool) {
 		dstX, ok := dst.(fs.Object)
 		if ok {
 			differ, noHash := c.checkIdentical(dstX, srcX)
+			switch {
+			case differ && noHash:
+				c.report(CheckError, src)
+			case differ:
+				c.report(CheckDiffer, src)
+			default:
+				c.report(CheckMatch, src)
+			}
 			if differ {
 				atomic.AddInt32(&c.differences, 1)
 			} else {
@@ -644,6 +1222,7 @@ func (c *checkMarch) Match(dst, src fs.DirEntry) (recurse bool) {
 			fs.CountError(err)
 			atomic.AddInt32(&c.differences, 1)
 			atomic.AddInt32(&c.dstFilesMissing, 1)
+			c.report(CheckSrcOnly, src)
 		}
 	case fs.Directory:
 		// Do the same thing to the entire contents of the directory
@@ -656,6 +1235,7 @@ func (c *checkMarch) Match(dst, src fs.DirEntry) (recurse bool) {
 		fs.CountError(err)
 		atomic.AddInt32(&c.differences, 1)
 		atomic.AddInt32(&c.srcFilesMissing, 1)
+		c.report(CheckDstOnly, dst)
 
 	default:
 		panic("Bad object in DirEntries")
@@ -670,12 +1250,16 @@ func (c *checkMarch) Match(dst, src fs.DirEntry) (recurse bool) {
 //
 // it returns true if differences were found
 // it also returns whether it couldn't be hashed
-func CheckFn(fdst, fsrc fs.Fs, check checkFn, oneway bool) error {
+//
+// If combined is not nil a line is written to it for every file
+// checked, the path prefixed by one of the Check status characters.
+func CheckFn(fdst, fsrc fs.Fs, check checkFn, oneway bool, combined io.Writer) error {
 	c := &checkMarch{
-		fdst:   fdst,
-		fsrc:   fsrc,
-		check:  check,
-		oneway: oneway,
+		fdst:     fdst,
+		fsrc:     fsrc,
+		check:    check,
+		oneway:   oneway,
+		combined: combined,
 	}
 
 	// set up a march over fdst and fsrc
@@ -701,8 +1285,8 @@ func CheckFn(fdst, fsrc fs.Fs, check checkFn, oneway bool) error {
 }
 
 // Check the files in fsrc and fdst according to Size and hash
-func Check(fdst, fsrc fs.Fs, oneway bool) error {
-	return CheckFn(fdst, fsrc, checkIdentical, oneway)
+func Check(fdst, fsrc fs.Fs, oneway bool, combined io.Writer) error {
+	return CheckFn(fdst, fsrc, checkIdentical, oneway, combined)
 }
 
 // CheckEqualReaders checks to see if in1 and in2 have the same
@@ -759,7 +1343,7 @@ func CheckIdentical(dst, src fs.Object) (differ bool, err error) {
 
 // CheckDownload checks the files in fsrc and fdst according to Size
 // and the actual contents of the files.
-func CheckDownload(fdst, fsrc fs.Fs, oneway bool) error {
+func CheckDownload(fdst, fsrc fs.Fs, oneway bool, combined io.Writer) error {
 	check := func(a, b fs.Object) (differ bool, noHash bool) {
 		differ, err := CheckIdentical(a, b)
 		if err != nil {
@@ -769,7 +1353,7 @@ func CheckDownload(fdst, fsrc fs.Fs, oneway bool) error {
 		}
 		return differ, false
 	}
-	return CheckFn(fdst, fsrc, check, oneway)
+	return CheckFn(fdst, fsrc, check, oneway, combined)
 }
 
 // ListFn lists the Fs to the supplied function
@@ -954,6 +1538,12 @@ func Purge(f fs.Fs, dir string) error {
 		// FIXME change the Purge interface so it takes a dir - see #1891
 		if doPurge := f.Features().Purge; doPurge != nil {
 			doFallbackPurge = false
+			if err = CheckInteractive(); err != nil {
+				return err
+			}
+			if skip, err := SkipDestructive(f, "purge"); skip {
+				return err
+			}
 			if fs.Config.DryRun {
 				fs.Logf(f, "Not purging as --dry-run set")
 			} else {
@@ -1188,6 +1778,43 @@ func Rcat(fdst fs.Fs, dstFileName string, in io.ReadCloser, modTime time.Time) (
 	return dst, nil
 }
 
+// FsInfo provides information about a remote
+type FsInfo struct {
+	// Name of the remote (as passed into NewFs)
+	Name string
+
+	// Root of the remote (as passed into NewFs)
+	Root string
+
+	// String returns a description of the FS
+	String string
+
+	// Precision of the ModTimes in this Fs in Nanoseconds
+	Precision time.Duration
+
+	// Returns the supported hash types of the filesystem
+	Hashes []string
+
+	// Features returns the optional features of this Fs
+	Features map[string]bool
+}
+
+// GetFsInfo gets the information (FsInfo) about a given Fs
+func GetFsInfo(f fs.Fs) *FsInfo {
+	info := &FsInfo{
+		Name:      f.Name(),
+		Root:      f.Root(),
+		String:    f.String(),
+		Precision: f.Precision(),
+		Hashes:    make([]string, 0, 4),
+		Features:  f.Features().Enabled(),
+	}
+	for _, hashType := range f.Hashes().Array() {
+		info.Hashes = append(info.Hashes, hashType.String())
+	}
+	return info
+}
+
 // PublicLink adds a "readable by anyone with link" permission on the given file or folder.
 func PublicLink(f fs.Fs, remote string) (string, error) {
 	doPublicLink := f.Features().PublicLink
@@ -1259,6 +1886,43 @@ func Rmdirs(f fs.Fs, dir string, leaveRoot bool) error {
 	return nil
 }
 
+// verifyExistingNoHash is used to warn once that --verify-existing
+// can't compare hashes
+var verifyExistingNoHash sync.Once
+
+// existingMatches checks whether dst which exists is the same as src
+// for --verify-existing.
+//
+// It compares the sizes and hashes, or if there is no common hash the
+// sizes and modification times.
+func existingMatches(src, dst fs.Object) bool {
+	if sizeDiffers(src, dst) {
+		fs.Logf(src, "Destination exists but sizes differ (src %d vs dst %d), transferring", src.Size(), dst.Size())
+		return false
+	}
+	same, ht, err := CheckHashes(src, dst)
+	if err != nil {
+		// error already logged
+		return false
+	}
+	if ht != hash.None {
+		if !same {
+			fs.Logf(src, "Destination exists but %v differ, transferring", ht)
+			return false
+		}
+		fs.Debugf(src, "Destination exists and %v match, skipping", ht)
+		return true
+	}
+	verifyExistingNoHash.Do(func() {
+		fs.Logf(dst.Fs(), "--verify-existing can't compare hashes with %v so comparing size and modification time", src.Fs())
+	})
+	if !equal(src, dst, false, false) {
+		fs.Logf(src, "Destination exists but modification times differ, transferring")
+		return false
+	}
+	return true
+}
+
 // NeedTransfer checks to see if src needs to be copied to dst using
 // the current config.
 //
@@ -1269,6 +1933,10 @@ func NeedTransfer(dst, src fs.Object) bool {
 		fs.Debugf(src, "Couldn't find file - need to transfer")
 		return true
 	}
+	// If we should verify existing files, only skip them if they match
+	if fs.Config.VerifyExisting {
+		return !existingMatches(src, dst)
+	}
 	// If we should ignore existing files, don't transfer
 	if fs.Config.IgnoreExisting {
 		fs.Debugf(src, "Destination exists, skipping")
@@ -1281,8 +1949,8 @@ func NeedTransfer(dst, src fs.Object) bool {
 	}
 	// If UpdateOlder is in effect, skip if dst is newer than src
 	if fs.Config.UpdateOlder {
-		srcModTime := src.ModTime()
-		dstModTime := dst.ModTime()
+		srcModTime := localModTime(src)
+		dstModTime := localModTime(dst)
 		dt := dstModTime.Sub(srcModTime)
 		// If have a mutually agreed precision then use that
 		modifyWindow := fs.GetModifyWindow(dst.Fs(), src.Fs())
@@ -1344,7 +2012,21 @@ func moveOrCopyFile(fdst fs.Fs, fsrc fs.Fs, dstFileName string, srcFileName stri
 		return err
 	}
 
+	// Compare the destination with the metadata which will be
+	// uploaded if there is a --metadata-mapper
+	srcObj, err = MapMetadata(srcObj)
+	if err != nil {
+		fs.CountError(err)
+		fs.Errorf(srcFileName, "Failed to map metadata: %v", err)
+		return err
+	}
+
 	if NeedTransfer(dstObj, srcObj) {
+		// If files are treated as immutable, fail if destination exists and does not match
+		if fs.Config.Immutable && dstObj != nil {
+			fs.Errorf(dstObj, "Source and destination exist but do not match: immutable file modified")
+			return fs.ErrorImmutableModified
+		}
 		accounting.Stats.Transferring(srcFileName)
 		_, err = Op(fdst, dstObj, dstFileName, srcObj)
 		accounting.Stats.DoneTransferring(srcFileName, err == nil)
@@ -3,13 +3,28 @@
 package operations
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/fserrors"
+	"github.com/ncw/rclone/fs/hash"
 	"github.com/ncw/rclone/fs/object"
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/ncw/rclone/fstest/mockobject"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSizeDiffers(t *testing.T) {
@@ -38,3 +53,747 @@ func TestSizeDiffers(t *testing.T) {
 		assert.Equal(t, test.want, got, fmt.Sprintf("ignoreSize=%v, srcSize=%v, dstSize=%v", test.ignoreSize, test.srcSize, test.dstSize))
 	}
 }
+
+// limitedFs is a minimal fs.Info with a transfer limit
+type limitedFs struct {
+	name  string
+	limit int
+}
+
+func (f *limitedFs) Name() string                { return f.name }
+func (f *limitedFs) Root() string                { return "" }
+func (f *limitedFs) String() string              { return f.name }
+func (f *limitedFs) Precision() time.Duration    { return time.Second }
+func (f *limitedFs) Hashes() hash.Set            { return hash.Set(hash.None) }
+func (f *limitedFs) Features() *fs.Features      { return &fs.Features{} }
+func (f *limitedFs) MaxConcurrentTransfers() int { return f.limit }
+
+func TestAcquireTransfer(t *testing.T) {
+	limited := &limitedFs{name: "limited", limit: 2}
+	unlimited := &limitedFs{name: "unlimited", limit: 0}
+
+	// Unlimited remotes don't get a limiter
+	assert.Nil(t, getTransferLimiter(unlimited))
+	release := acquireTransfer(unlimited, unlimited)
+	release()
+
+	// The same Fs twice only takes one token
+	release1 := acquireTransfer(limited, limited)
+	release2 := acquireTransfer(limited, unlimited)
+
+	// The third transfer should block until one is released
+	started := make(chan struct{})
+	go func() {
+		release3 := acquireTransfer(unlimited, limited)
+		close(started)
+		release3()
+	}()
+	select {
+	case <-started:
+		t.Fatal("transfer started when it should have been limited")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release1()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transfer didn't start after release")
+	}
+	release2()
+}
+
+func TestGetTransferLimiterByName(t *testing.T) {
+	// Fs for the same remote share a limiter
+	f1 := &limitedFs{name: "shared", limit: 2}
+	f2 := &limitedFs{name: "shared", limit: 2}
+	limiter := getTransferLimiter(f1)
+	require.NotNil(t, limiter)
+	assert.True(t, limiter == getTransferLimiter(f2))
+
+	// and making more of them doesn't add limiters
+	transferLimitersMu.Lock()
+	n := len(transferLimiters)
+	transferLimitersMu.Unlock()
+	for i := 0; i < 10; i++ {
+		getTransferLimiter(&limitedFs{name: "shared", limit: 2})
+	}
+	transferLimitersMu.Lock()
+	assert.Equal(t, n, len(transferLimiters))
+	transferLimitersMu.Unlock()
+}
+
+func TestAcquireTransferOrdering(t *testing.T) {
+	a := &limitedFs{name: "a", limit: 1}
+	b := &limitedFs{name: "b", limit: 1}
+
+	// Transfers in opposite directions mustn't deadlock
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func(i int) {
+			for j := 0; j < 100; j++ {
+				var release func()
+				if i == 0 {
+					release = acquireTransfer(a, b)
+				} else {
+					release = acquireTransfer(b, a)
+				}
+				release()
+			}
+			done <- struct{}{}
+		}(i)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("deadlocked")
+		}
+	}
+}
+
+// idObject is a mock object with an ID, size and modification time
+type idObject struct {
+	mockobject.Object
+	id      string
+	size    int64
+	modTime time.Time
+}
+
+func (o idObject) ID() string         { return o.id }
+func (o idObject) Size() int64        { return o.size }
+func (o idObject) ModTime() time.Time { return o.modTime }
+
+func TestDedupeChoose(t *testing.T) {
+	t1 := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	objs := []fs.Object{
+		idObject{"one", "c", 10, t1},
+		idObject{"one", "b", 20, t2},
+		idObject{"one", "d", 10, t2},
+		idObject{"one", "a", 20, t1},
+	}
+	assert.Equal(t, 1, dedupeChoose(objs, dedupeNewer, dedupeSameTime))
+	assert.Equal(t, 3, dedupeChoose(objs, dedupeOlder, dedupeSameTime))
+	assert.Equal(t, 3, dedupeChoose(objs, dedupeLarger, dedupeSameSize))
+	assert.Equal(t, 0, dedupeChoose(objs, dedupeSmaller, dedupeSameSize))
+}
+
+// batchFs is a minimal fs.Info which supports RemoveBatch
+type batchFs struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (f *batchFs) Name() string             { return "batch" }
+func (f *batchFs) Root() string             { return "" }
+func (f *batchFs) String() string           { return "batch" }
+func (f *batchFs) Precision() time.Duration { return time.Second }
+func (f *batchFs) Hashes() hash.Set         { return hash.Set(hash.None) }
+func (f *batchFs) Features() *fs.Features   { return &fs.Features{RemoveBatch: f.RemoveBatch} }
+
+// RemoveBatch records the batch and fails objects called "fail"
+func (f *batchFs) RemoveBatch(objs []fs.Object) []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var batch []string
+	errs := make([]error, len(objs))
+	for i, o := range objs {
+		batch = append(batch, o.Remote())
+		if o.Remote() == "fail" {
+			errs[i] = errors.New("failed")
+		}
+	}
+	f.batches = append(f.batches, batch)
+	return errs
+}
+
+// batchObject is a mock object on a batchFs
+type batchObject struct {
+	mockobject.Object
+	f *batchFs
+}
+
+func (o batchObject) Fs() fs.Info { return o.f }
+
+func TestDeleteFilesBatch(t *testing.T) {
+	f := &batchFs{}
+	n := removeBatchSize + 10
+	toBeDeleted := make(fs.ObjectsChan, n+1)
+	for i := 0; i < n; i++ {
+		toBeDeleted <- batchObject{mockobject.New(fmt.Sprintf("file%d", i)), f}
+	}
+	toBeDeleted <- batchObject{mockobject.New("fail"), f}
+	close(toBeDeleted)
+
+	err := DeleteFiles(toBeDeleted)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to delete 1 files")
+
+	require.Len(t, f.batches, 2)
+	total := 0
+	for _, batch := range f.batches {
+		assert.True(t, len(batch) <= removeBatchSize)
+		total += len(batch)
+	}
+	assert.Equal(t, n+1, total)
+}
+
+func TestSuffixName(t *testing.T) {
+	origSuffix, origKeep := fs.Config.Suffix, fs.Config.SuffixKeepExtension
+	defer func() {
+		fs.Config.Suffix, fs.Config.SuffixKeepExtension = origSuffix, origKeep
+	}()
+	for _, test := range []struct {
+		remote string
+		suffix string
+		keep   bool
+		want   string
+	}{
+		{"test.txt", "", false, "test.txt"},
+		{"test.txt", "", true, "test.txt"},
+		{"test.txt", "-suffix", false, "test.txt-suffix"},
+		{"test.txt", "-suffix", true, "test-suffix.txt"},
+		{"dir/test.txt", "-suffix", true, "dir/test-suffix.txt"},
+		{"dir.d/test", "-suffix", true, "dir.d/test-suffix"},
+		{"test", "-suffix", true, "test-suffix"},
+		{".bashrc", "-suffix", true, ".bashrc-suffix"},
+		{"my.report.doc", "-suffix", true, "my.report-suffix.doc"},
+		{"archive.tar.gz", "-suffix", true, "archive-suffix.tar.gz"},
+		{"archive.TAR.GZ", "-suffix", true, "archive-suffix.TAR.GZ"},
+		{"archive.tar.gz", "-suffix", false, "archive.tar.gz-suffix"},
+		{"file.gz", "-suffix", true, "file-suffix.gz"},
+		{".tar.gz", "-suffix", true, ".tar.gz-suffix"},
+	} {
+		fs.Config.Suffix = test.suffix
+		fs.Config.SuffixKeepExtension = test.keep
+		got := SuffixName(test.remote)
+		assert.Equal(t, test.want, got, fmt.Sprintf("%+v", test))
+	}
+}
+
+// serverModTimeObject is a mock object with a server modification
+// time
+type serverModTimeObject struct {
+	mockobject.Object
+	f             fs.Info
+	serverModTime time.Time
+}
+
+func (o serverModTimeObject) Fs() fs.Info              { return o.f }
+func (o serverModTimeObject) ServerModTime() time.Time { return o.serverModTime }
+
+func TestEqualModTimeSourceSource(t *testing.T) {
+	f := &limitedFs{name: "f"}
+	srcModTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := object.NewStaticObjectInfo("a", srcModTime, 0, true, nil, f)
+	oldModTimeSource := fs.Config.ModTimeSource
+	fs.Config.ModTimeSource = fs.ModTimeSourceSource
+	defer func() { fs.Config.ModTimeSource = oldModTimeSource }()
+	for _, test := range []struct {
+		serverModTime time.Time
+		want          bool
+	}{
+		{srcModTime.Add(time.Hour), true},
+		{srcModTime, true},
+		{srcModTime.Add(-500 * time.Millisecond), true},
+		{srcModTime.Add(-time.Hour), false},
+	} {
+		dst := serverModTimeObject{Object: mockobject.New("a"), f: f, serverModTime: test.serverModTime}
+		assert.Equal(t, test.want, equal(src, dst, false, false), test.serverModTime.String())
+	}
+}
+
+// hashFs is a minimal fs.Info with MD5 hashes
+type hashFs struct {
+	slowHash bool
+}
+
+func (f *hashFs) Name() string             { return "hashFs" }
+func (f *hashFs) Root() string             { return "" }
+func (f *hashFs) String() string           { return "hashFs" }
+func (f *hashFs) Precision() time.Duration { return time.Second }
+func (f *hashFs) Hashes() hash.Set         { return hash.Set(hash.MD5) }
+func (f *hashFs) Features() *fs.Features   { return &fs.Features{SlowHash: f.slowHash} }
+
+// hashObject is a mock object with an MD5 hash which counts how many
+// times it is read
+type hashObject struct {
+	mockobject.Object
+	f      fs.Info
+	md5    string
+	hashes *int
+}
+
+func (o hashObject) Fs() fs.Info { return o.f }
+func (o hashObject) Hash(ht hash.Type) (string, error) {
+	*o.hashes++
+	return o.md5, nil
+}
+
+func TestEqualSizeAndFreeHash(t *testing.T) {
+	fs.Config.SizeAndFreeHash = true
+	defer func() { fs.Config.SizeAndFreeHash = false }()
+	fast, slow := &hashFs{}, &hashFs{slowHash: true}
+	for _, test := range []struct {
+		srcFs, dstFs   *hashFs
+		srcMD5, dstMD5 string
+		want           bool
+		wantHashRead   bool
+	}{
+		{fast, fast, "aaa", "aaa", true, true},
+		{fast, fast, "aaa", "bbb", false, true},
+		{fast, fast, "aaa", "", true, true},
+		{slow, fast, "aaa", "bbb", true, false},
+		{fast, slow, "aaa", "bbb", true, false},
+	} {
+		what := fmt.Sprintf("%+v", test)
+		hashes := 0
+		src := hashObject{Object: mockobject.New("a"), f: test.srcFs, md5: test.srcMD5, hashes: &hashes}
+		dst := hashObject{Object: mockobject.New("a"), f: test.dstFs, md5: test.dstMD5, hashes: &hashes}
+		assert.Equal(t, test.want, equal(src, dst, false, false), what)
+		assert.Equal(t, test.wantHashRead, hashes > 0, what)
+	}
+}
+
+// serverTimeFs is a minimal fs.Info whose server clock is skew ahead
+// of the local one
+type serverTimeFs struct {
+	name  string
+	skew  time.Duration
+	err   error
+	calls int
+}
+
+func (f *serverTimeFs) Name() string             { return f.name }
+func (f *serverTimeFs) Root() string             { return "" }
+func (f *serverTimeFs) String() string           { return f.name }
+func (f *serverTimeFs) Precision() time.Duration { return time.Second }
+func (f *serverTimeFs) Hashes() hash.Set         { return hash.Set(hash.None) }
+func (f *serverTimeFs) Features() *fs.Features {
+	return &fs.Features{ServerTime: func() (time.Time, error) {
+		f.calls++
+		return time.Now().Add(f.skew), f.err
+	}}
+}
+
+func TestClockSkew(t *testing.T) {
+	oldClockSkew, oldClockSkewAuto := fs.Config.ClockSkew, fs.Config.ClockSkewAuto
+	defer func() {
+		fs.Config.ClockSkew, fs.Config.ClockSkewAuto = oldClockSkew, oldClockSkewAuto
+		clockSkews = map[string]time.Duration{}
+	}()
+	ahead := &serverTimeFs{name: "ahead", skew: time.Hour}
+	behind := &serverTimeFs{name: "behind", skew: -time.Hour}
+	near := &serverTimeFs{name: "near", skew: 100 * time.Millisecond}
+	broken := &serverTimeFs{name: "broken", skew: time.Hour, err: errors.New("boom")}
+
+	fs.Config.ClockSkew = 5 * time.Minute
+	assert.Equal(t, 5*time.Minute, ClockSkew(ahead))
+	assert.Equal(t, 0, ahead.calls)
+
+	fs.Config.ClockSkew, fs.Config.ClockSkewAuto = 0, true
+	for _, test := range []struct {
+		f    *serverTimeFs
+		want time.Duration
+	}{
+		{ahead, time.Hour},
+		{behind, -time.Hour},
+		{near, 0},
+		{broken, 0},
+	} {
+		for i := 0; i < 2; i++ {
+			assert.InDelta(t, float64(test.want), float64(ClockSkew(test.f)), float64(time.Second), test.f.name)
+		}
+		assert.Equal(t, 1, test.f.calls, test.f.name)
+	}
+	assert.Equal(t, time.Duration(0), ClockSkew(&limitedFs{name: "noServerTime"}))
+
+	// The server modification time is corrected for the skew
+	oldModTimeSource := fs.Config.ModTimeSource
+	fs.Config.ModTimeSource = fs.ModTimeSourceSource
+	defer func() { fs.Config.ModTimeSource = oldModTimeSource }()
+	srcModTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := object.NewStaticObjectInfo("a", srcModTime, 0, true, nil, &limitedFs{name: "src"})
+	dst := serverModTimeObject{Object: mockobject.New("a"), f: ahead, serverModTime: srcModTime.Add(30 * time.Minute)}
+	assert.False(t, equal(src, dst, false, false))
+	dst = serverModTimeObject{Object: mockobject.New("a"), f: behind, serverModTime: srcModTime.Add(-30 * time.Minute)}
+	assert.True(t, equal(src, dst, false, false))
+}
+
+func TestMapMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script")
+	}
+	dir, err := ioutil.TempDir("", "rclone-metadata-mapper")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+	script := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0700))
+		return path
+	}
+	oldMapper, oldTimeout := fs.Config.MetadataMapper, fs.Config.MetadataMapperTimeout
+	defer func() { fs.Config.MetadataMapper, fs.Config.MetadataMapperTimeout = oldMapper, oldTimeout }()
+
+	modTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := object.NewMemoryObject("dir/file.txt", modTime.Add(-time.Hour), []byte("hello"))
+
+	fs.Config.MetadataMapper = ""
+	got, err := MapMetadata(src)
+	require.NoError(t, err)
+	assert.Equal(t, fs.Object(src), got)
+
+	// The input is passed on and only the fields returned change
+	input := filepath.Join(dir, "input.json")
+	fs.Config.MetadataMapper = script("mapper", `cat > `+input+`
+echo '{"MimeType":"text/x-mapped","Metadata":{"key":"value"}}'`)
+	got, err = MapMetadata(src)
+	require.NoError(t, err)
+	in, err := ioutil.ReadFile(input)
+	require.NoError(t, err)
+	var inMetadata MapperMetadata
+	require.NoError(t, json.Unmarshal(in, &inMetadata))
+	assert.Equal(t, "dir/file.txt", inMetadata.Remote)
+	assert.Equal(t, int64(5), inMetadata.Size)
+	assert.Equal(t, "text/plain; charset=utf-8", inMetadata.MimeType)
+	assert.Equal(t, "dir/file.txt", got.Remote())
+	assert.Equal(t, src.ModTime(), got.ModTime())
+	assert.Equal(t, "text/x-mapped", fs.MimeType(got))
+	assert.Equal(t, map[string]string{"key": "value"}, got.(fs.Metadataer).Metadata())
+
+	fs.Config.MetadataMapper = script("modtime", `echo '{"ModTime":"`+modTime.Format(time.RFC3339)+`"}'`)
+	got, err = MapMetadata(src)
+	require.NoError(t, err)
+	assert.True(t, modTime.Equal(got.ModTime()))
+
+	fs.Config.MetadataMapper = script("fail", "echo oops >&2; exit 3")
+	_, err = MapMetadata(src)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oops")
+
+	fs.Config.MetadataMapper = script("garbage", "echo not json")
+	_, err = MapMetadata(src)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to decode output")
+
+	fs.Config.MetadataMapper = script("slow", "exec sleep 10")
+	fs.Config.MetadataMapperTimeout = 100 * time.Millisecond
+	_, err = MapMetadata(src)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+}
+
+func TestSkipDestructive(t *testing.T) {
+	oldInteractive, oldDryRun, oldReadLine, oldStdinIsTerminal := fs.Config.Interactive, fs.Config.DryRun, config.ReadLine, config.StdinIsTerminal
+	defer func() {
+		fs.Config.Interactive, fs.Config.DryRun, config.ReadLine, config.StdinIsTerminal = oldInteractive, oldDryRun, oldReadLine, oldStdinIsTerminal
+		interactiveAll = map[string]bool{}
+		interactiveQuit = false
+	}()
+	var answers []string
+	config.ReadLine = func() string {
+		answer := answers[0]
+		answers = answers[1:]
+		return answer
+	}
+	config.StdinIsTerminal = func() bool { return true }
+	o := mockobject.New("file")
+
+	// Doesn't ask unless --interactive
+	fs.Config.Interactive = false
+	skip, err := SkipDestructive(o, "delete")
+	require.NoError(t, err)
+	assert.False(t, skip)
+
+	// Or with --dry-run
+	fs.Config.Interactive = true
+	fs.Config.DryRun = true
+	skip, err = SkipDestructive(o, "delete")
+	require.NoError(t, err)
+	assert.False(t, skip)
+	fs.Config.DryRun = false
+
+	answers = []string{"y", "n", "a", "q"}
+	skip, err = SkipDestructive(o, "delete")
+	require.NoError(t, err)
+	assert.False(t, skip)
+	skip, err = SkipDestructive(o, "delete")
+	require.NoError(t, err)
+	assert.True(t, skip)
+
+	// All remembers the answer for the action only
+	skip, err = SkipDestructive(o, "delete")
+	require.NoError(t, err)
+	assert.False(t, skip)
+	skip, err = SkipDestructive(o, "delete")
+	require.NoError(t, err)
+	assert.False(t, skip)
+	skip, err = SkipDestructive(o, "copy")
+	assert.Equal(t, ErrorInteractiveQuit, err)
+	assert.True(t, skip)
+	assert.Len(t, answers, 0)
+
+	// Doesn't ask again after quitting
+	skip, err = SkipDestructive(o, "move")
+	assert.Equal(t, ErrorInteractiveQuit, err)
+	assert.True(t, skip)
+	interactiveQuit = false
+
+	// Can't ask without a terminal
+	config.StdinIsTerminal = func() bool { return false }
+	skip, err = SkipDestructive(o, "copy")
+	require.Error(t, err)
+	assert.True(t, fserrors.IsFatalError(err))
+	assert.True(t, skip)
+}
+
+func TestDeleteFilesInteractive(t *testing.T) {
+	oldInteractive, oldReadLine, oldStdinIsTerminal := fs.Config.Interactive, config.ReadLine, config.StdinIsTerminal
+	defer func() {
+		fs.Config.Interactive, config.ReadLine, config.StdinIsTerminal = oldInteractive, oldReadLine, oldStdinIsTerminal
+		interactiveAll = map[string]bool{}
+		interactiveQuit = false
+	}()
+	fs.Config.Interactive = true
+	config.StdinIsTerminal = func() bool { return true }
+	answers := []string{"n", "y", "a"}
+	config.ReadLine = func() string {
+		answer := answers[0]
+		answers = answers[1:]
+		return answer
+	}
+
+	f := &batchFs{}
+	toBeDeleted := make(fs.ObjectsChan, 10)
+	for i := 0; i < 10; i++ {
+		toBeDeleted <- batchObject{mockobject.New(fmt.Sprintf("file%d", i)), f}
+	}
+	close(toBeDeleted)
+
+	err := DeleteFiles(toBeDeleted)
+	require.NoError(t, err)
+	assert.Len(t, answers, 0)
+	total := 0
+	for _, batch := range f.batches {
+		total += len(batch)
+	}
+	assert.Equal(t, 9, total)
+
+	// Quitting carries on reading the channel so the sender
+	// doesn't block
+	answers = []string{"q"}
+	interactiveAll = map[string]bool{}
+	unbuffered := make(fs.ObjectsChan)
+	go func() {
+		for i := 0; i < 10; i++ {
+			unbuffered <- batchObject{mockobject.New(fmt.Sprintf("file%d", i)), f}
+		}
+		close(unbuffered)
+	}()
+	err = DeleteFiles(unbuffered)
+	assert.Equal(t, ErrorInteractiveQuit, err)
+	assert.Len(t, answers, 0)
+	interactiveQuit = false
+
+	// Without a terminal it fails before asking anything
+	config.StdinIsTerminal = func() bool { return false }
+	unbuffered = make(fs.ObjectsChan)
+	go func() {
+		for i := 0; i < 10; i++ {
+			unbuffered <- batchObject{mockobject.New(fmt.Sprintf("file%d", i)), f}
+		}
+		close(unbuffered)
+	}()
+	err = DeleteFiles(unbuffered)
+	require.Error(t, err)
+	assert.True(t, fserrors.IsFatalError(err))
+}
+
+// renameFs is a minimal fs.Fs with a Move which can be made to fail
+type renameFs struct {
+	fs.Fs
+	objects    []*renameObject
+	overwrite  bool // Move replaces an existing file
+	duplicates bool // Move leaves an existing file alongside
+	failMoves  bool // Move always fails
+}
+
+func (f *renameFs) Features() *fs.Features {
+	return &fs.Features{Move: f.Move, DuplicateFiles: f.duplicates}
+}
+
+func (f *renameFs) add(remote string) *renameObject {
+	o := &renameObject{Object: mockobject.New(remote), f: f, remote: remote}
+	f.objects = append(f.objects, o)
+	return o
+}
+
+func (f *renameFs) remove(o *renameObject) {
+	for i := range f.objects {
+		if f.objects[i] == o {
+			f.objects = append(f.objects[:i], f.objects[i+1:]...)
+			return
+		}
+	}
+}
+
+// remotes returns the names of the objects in f
+func (f *renameFs) remotes() (remotes []string) {
+	for _, o := range f.objects {
+		remotes = append(remotes, o.remote)
+	}
+	return remotes
+}
+
+func (f *renameFs) Move(src fs.Object, remote string) (fs.Object, error) {
+	if f.failMoves {
+		return nil, errors.New("move failed")
+	}
+	for _, o := range f.objects {
+		if o.remote == remote {
+			if f.overwrite {
+				f.remove(o)
+			} else if !f.duplicates {
+				return nil, errors.New("destination exists")
+			}
+			break
+		}
+	}
+	o := src.(*renameObject)
+	o.remote = remote
+	return o, nil
+}
+
+// renameObject is a mock object on a renameFs
+type renameObject struct {
+	mockobject.Object
+	f      *renameFs
+	remote string
+}
+
+func (o *renameObject) Remote() string { return o.remote }
+func (o *renameObject) Remove() error  { o.f.remove(o); return nil }
+
+func TestAtomicRename(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		f          *renameFs
+		noOld      bool
+		wantErr    bool
+		wantRemote []string
+	}{
+		{name: "overwrite", f: &renameFs{overwrite: true}, wantRemote: []string{"file"}},
+		{name: "no overwrite", f: &renameFs{}, wantRemote: []string{"file"}},
+		{name: "duplicates", f: &renameFs{duplicates: true}, wantRemote: []string{"file"}},
+		{name: "new", f: &renameFs{}, noOld: true, wantRemote: []string{"file"}},
+		// The upload is kept if the existing file has gone
+		{name: "fail", f: &renameFs{failMoves: true}, wantErr: true, wantRemote: []string{"file.partial"}},
+		{name: "fail new", f: &renameFs{failMoves: true}, noOld: true, wantErr: true, wantRemote: nil},
+	} {
+		var old fs.Object
+		if !test.noOld {
+			old = test.f.add("file")
+		}
+		tmp := test.f.add("file.partial")
+		newDst, err := atomicRename(test.f, old, tmp, "file")
+		if test.wantErr {
+			assert.Error(t, err, test.name)
+		} else {
+			require.NoError(t, err, test.name)
+			assert.True(t, newDst == tmp, test.name)
+		}
+		assert.Equal(t, test.wantRemote, test.f.remotes(), test.name)
+	}
+}
+
+// unhashableObject is an fs.Object which doesn't support any hashes
+type unhashableObject struct {
+	fs.Object
+}
+
+func (o unhashableObject) Hash(hash.Type) (string, error) {
+	return "", hash.ErrUnsupported
+}
+
+func TestRcHashsum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-hashsum-test")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello world"), 0600))
+	const md5sum = "5eb63bbbe01eeed093cb22bb8f5acdc3"
+
+	// Bad parameters are reported straight away
+	_, err = rcHashsum(rc.Params{"fs": dir, "remote": "file.txt"})
+	assert.EqualError(t, err, "hashType is needed")
+	_, err = rcHashsum(rc.Params{"fs": dir, "remote": "file.txt", "hashType": "potato"})
+	assert.Error(t, err)
+
+	out, err := rcHashsum(rc.Params{"fs": dir, "remote": "file.txt", "hashType": "MD5"})
+	require.NoError(t, err)
+	assert.NotNil(t, out["jobid"])
+
+	f, err := fs.NewFs(dir)
+	require.NoError(t, err)
+	out, err = hashsumJob(f, "file.txt", hash.MD5)(&rc.Job{})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"hash": md5sum, "hashType": "MD5"}, out)
+	_, err = hashsumJob(f, "missing.txt", hash.MD5)(&rc.Job{})
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+
+	// Objects without the hash are downloaded to work it out
+	o, err := f.NewObject("file.txt")
+	require.NoError(t, err)
+	var downloads int
+	sum, err := objectHash(hash.MD5, unhashableObject{o}, func(acc *accounting.Account) {
+		downloads++
+	})
+	require.NoError(t, err)
+	assert.Equal(t, md5sum, sum)
+	assert.Equal(t, 1, downloads)
+}
+
+// caseFs is a case insensitive fs.Fs which records its moves and
+// fails ones to the remote failMove
+type caseFs struct {
+	fs.Fs
+	moves    []string
+	failMove string
+}
+
+func (f *caseFs) Features() *fs.Features {
+	return &fs.Features{CaseInsensitive: true, Move: f.Move}
+}
+
+func (f *caseFs) NewObject(remote string) (fs.Object, error) {
+	return nil, fs.ErrorObjectNotFound
+}
+
+func (f *caseFs) Move(src fs.Object, remote string) (fs.Object, error) {
+	if remote == f.failMove {
+		return nil, errors.New("move failed")
+	}
+	f.moves = append(f.moves, src.Remote()+" to "+remote)
+	return mockobject.New(remote), nil
+}
+
+func TestFixCase(t *testing.T) {
+	f := &caseFs{}
+	newDst, err := FixCase(f, mockobject.New("dir/file.txt"), "dir/File.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "dir/File.txt", newDst.Remote())
+	require.Len(t, f.moves, 2)
+	assert.True(t, strings.HasPrefix(f.moves[0], "dir/file.txt to dir/File.txt.rclone-"), f.moves[0])
+
+	// If the move to the final name fails the file is moved
+	// back to its original name
+	f = &caseFs{failMove: "dir/File.txt"}
+	newDst, err = FixCase(f, mockobject.New("dir/file.txt"), "dir/File.txt")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "move failed")
+	require.NotNil(t, newDst)
+	assert.Equal(t, "dir/file.txt", newDst.Remote())
+	require.Len(t, f.moves, 2)
+	assert.True(t, strings.HasSuffix(f.moves[1], " to dir/file.txt"), f.moves[1])
+}
@@ -25,7 +25,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -201,6 +206,31 @@ func TestHashSums(t *testing.T) {
 	}
 }
 
+func TestCheckFile(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteBoth("potato2", "------------------------------------------------------------", t1)
+	file2 := r.WriteBoth("empty space", "", t2)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	check := func(sums string) (string, error) {
+		var buf bytes.Buffer
+		err := operations.CheckFile(hash.MD5, r.Fremote, strings.NewReader(sums), &buf)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		sort.Strings(lines)
+		return strings.Join(lines, "\n"), err
+	}
+
+	got, err := check("d6548b156ea68a4e003e786df99eee76  potato2\nD41D8CD98F00B204E9800998ECF8427E *empty space\n")
+	require.NoError(t, err)
+	assert.Equal(t, "MATCH     empty space\nMATCH     potato2", got)
+
+	got, err = check("d6548b156ea68a4e003e786df99eee77  potato2\nd41d8cd98f00b204e9800998ecf8427e  missing\nrubbish\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3 differences found")
+	assert.Equal(t, "HASH_DIFF potato2\nMISS      missing", got)
+}
+
 func TestCount(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
@@ -238,14 +268,14 @@ func TestDelete(t *testing.T) {
 	fstest.CheckItems(t, r.Fremote, file3)
 }
 
-func testCheck(t *testing.T, checkFunction func(fdst, fsrc fs.Fs, oneway bool) error) {
+func testCheck(t *testing.T, checkFunction func(fdst, fsrc fs.Fs, oneway bool, combined io.Writer) error) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
 
 	check := func(i int, wantErrors int64, oneway bool) {
 		fs.Debugf(r.Fremote, "%d: Starting check test", i)
 		oldErrors := accounting.Stats.GetErrors()
-		err := checkFunction(r.Fremote, r.Flocal, oneway)
+		err := checkFunction(r.Fremote, r.Flocal, oneway, nil)
 		gotErrors := accounting.Stats.GetErrors() - oldErrors
 		if wantErrors == 0 && err != nil {
 			t.Errorf("%d: Got error when not expecting one: %v", i, err)
@@ -299,6 +329,38 @@ func TestCheckDownload(t *testing.T) {
 	testCheck(t, operations.CheckDownload)
 }
 
+func TestCheckCombined(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	file1 := r.WriteBoth("same", "identical", t1)
+	file2 := r.WriteFile("differ", "source contents", t1)
+	file3 := r.WriteObject("differ", "dest contents!!", t1)
+	file4 := r.WriteFile("srconly", "only in source", t1)
+	file5 := r.WriteObject("dstonly", "only in dest", t1)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file4)
+	fstest.CheckItems(t, r.Fremote, file1, file3, file5)
+
+	var buf bytes.Buffer
+	err := operations.CheckDownload(r.Fremote, r.Flocal, false, &buf)
+	require.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(lines)
+	assert.Equal(t, []string{
+		"* differ",
+		"+ dstonly",
+		"- srconly",
+		"= same",
+	}, lines)
+
+	// --one-way leaves out the files only on the destination
+	buf.Reset()
+	err = operations.CheckDownload(r.Fremote, r.Flocal, true, &buf)
+	require.Error(t, err)
+	assert.NotContains(t, buf.String(), "dstonly")
+}
+
 func TestCheckSizeOnly(t *testing.T) {
 	fs.Config.SizeOnly = true
 	defer func() { fs.Config.SizeOnly = false }()
@@ -374,6 +436,104 @@ func TestRcat(t *testing.T) {
 	check(false)
 }
 
+func TestCopyURL(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	contents := "file contents\n"
+	md5 := "081404b3d2ae5bf599add15b7445ac07"
+	contentMD5 := "CBQEs9KuW/WZrdFbdEWsBw=="
+	modTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	var (
+		ranges   []string
+		failOnce bool
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ranges = append(ranges, req.Header.Get("Range"))
+		w.Header().Set("Content-MD5", contentMD5)
+		if failOnce && req.Header.Get("Range") == "" {
+			// send half the data then drop the connection
+			failOnce = false
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+			w.Header().Set("Content-Length", fmt.Sprint(len(contents)))
+			_, _ = io.WriteString(w, contents[:5])
+			return
+		}
+		http.ServeContent(w, req, "file.txt", modTime, strings.NewReader(contents))
+	}))
+	defer ts.Close()
+
+	// Plain copy checking the header checksum
+	o, err := operations.CopyURL(r.Fremote, "file1", ts.URL, "Content-MD5", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), o.Size())
+	file1 := fstest.NewItem("file1", contents, modTime)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// Bad checksum removes the download
+	_, err = operations.CopyURL(r.Fremote, "file2", ts.URL, "", "00000000000000000000000000000000")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "corrupted on transfer")
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// Dropped connections are resumed
+	ranges, failOnce = nil, true
+	_, err = operations.CopyURL(r.Fremote, "file3", ts.URL, "", md5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "bytes=5-"}, ranges)
+	file3 := fstest.NewItem("file3", contents, modTime)
+	fstest.CheckItems(t, r.Fremote, file1, file3)
+
+	// An existing destination is replaced rather than resumed
+	r.WriteObject("file4", contents[:7], t1)
+	ranges = nil
+	_, err = operations.CopyURL(r.Fremote, "file4", ts.URL, "", md5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, ranges)
+	file4 := fstest.NewItem("file4", contents, modTime)
+	fstest.CheckItems(t, r.Fremote, file1, file3, file4)
+
+	// Partial downloads are resumed if the remote can
+	features := r.Fremote.Features()
+	if fs.Config.NoPartial || features.OpenWriterAt == nil || features.Move == nil || !features.AtomicMove {
+		return
+	}
+	r.WriteObject("file5"+fs.Config.PartialSuffix, contents[:7], t1)
+	ranges = nil
+	_, err = operations.CopyURL(r.Fremote, "file5", ts.URL, "Content-MD5", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, ranges)
+	file5 := fstest.NewItem("file5", contents, modTime)
+	fstest.CheckItems(t, r.Fremote, file1, file3, file4, file5)
+
+	// Unless they don't match the data
+	file6 := fstest.NewItem("file6", contents, modTime)
+	for _, stale := range []string{"FILE CONTENTS\n", "file contents\n and more"} {
+		r.WriteObject("file6"+fs.Config.PartialSuffix, stale, t1)
+		ranges = nil
+		_, err = operations.CopyURL(r.Fremote, "file6", ts.URL, "", md5)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"", ""}, ranges, stale)
+		fstest.CheckItems(t, r.Fremote, file1, file3, file4, file5, file6)
+	}
+
+	// Only the rest of a large file is downloaded
+	large := strings.Repeat("0123456789abcdef", 16*1024)
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ranges = append(ranges, req.Header.Get("Range"))
+		http.ServeContent(w, req, "large.txt", modTime, strings.NewReader(large))
+	}))
+	defer ts2.Close()
+	r.WriteObject("file7"+fs.Config.PartialSuffix, large[:100000], t1)
+	ranges = nil
+	_, err = operations.CopyURL(r.Fremote, "file7", ts2.URL, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bytes=34464-"}, ranges)
+	file7 := fstest.NewItem("file7", large, modTime)
+	fstest.CheckItems(t, r.Fremote, file1, file3, file4, file5, file6, file7)
+}
+
 func TestRmdirsNoLeaveRoot(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
@@ -523,6 +683,239 @@ func TestCopyFile(t *testing.T) {
 	fstest.CheckItems(t, r.Fremote, file2)
 }
 
+func TestNeedTransferVerifyExisting(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if r.Flocal.Hashes().Overlap(r.Fremote.Hashes()).Count() == 0 {
+		t.Skip("no common hash")
+	}
+
+	// Same size and modification time but different contents
+	file1 := r.WriteFile("file1", "potato", t1)
+	file2 := r.WriteObject("file1", "tomato", t1)
+	fstest.CheckItems(t, r.Flocal, file1)
+	fstest.CheckItems(t, r.Fremote, file2)
+
+	src, err := r.Flocal.NewObject(file1.Path)
+	require.NoError(t, err)
+	dst, err := r.Fremote.NewObject(file1.Path)
+	require.NoError(t, err)
+
+	assert.False(t, operations.NeedTransfer(dst, src))
+
+	fs.Config.IgnoreExisting = true
+	assert.False(t, operations.NeedTransfer(dst, src))
+	fs.Config.IgnoreExisting = false
+
+	fs.Config.VerifyExisting = true
+	defer func() { fs.Config.VerifyExisting = false }()
+	assert.True(t, operations.NeedTransfer(dst, src))
+	assert.True(t, operations.NeedTransfer(nil, src))
+
+	// Once the contents match it is skipped
+	file2 = r.WriteObject("file1", "potato", t2)
+	dst, err = r.Fremote.NewObject(file2.Path)
+	require.NoError(t, err)
+	assert.False(t, operations.NeedTransfer(dst, src))
+}
+
+func TestNeedTransferRefreshTimes(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if r.Fremote.Precision() == fs.ModTimeNotSupported {
+		t.Skip("remote doesn't support modification times")
+	}
+
+	// Same size but different modification time
+	file1 := r.WriteFile("file1", "potato", t1)
+	file2 := r.WriteObject("file1", "tomato", t2)
+
+	src, err := r.Flocal.NewObject(file1.Path)
+	require.NoError(t, err)
+	dst, err := r.Fremote.NewObject(file1.Path)
+	require.NoError(t, err)
+
+	fs.Config.SizeOnly = true
+	defer func() { fs.Config.SizeOnly = false }()
+
+	// Without --refresh-times the modification time is left alone
+	assert.False(t, operations.NeedTransfer(dst, src))
+	fstest.CheckItems(t, r.Fremote, file2)
+
+	fs.Config.RefreshTimes = true
+	defer func() { fs.Config.RefreshTimes = false }()
+
+	// Not in --dry-run
+	fs.Config.DryRun = true
+	assert.False(t, operations.NeedTransfer(dst, src))
+	fs.Config.DryRun = false
+	fstest.CheckItems(t, r.Fremote, file2)
+
+	assert.False(t, operations.NeedTransfer(dst, src))
+	file2.ModTime = t1
+	fstest.CheckItems(t, r.Fremote, file2)
+}
+
+func TestCopyFileAtomic(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	fs.Config.Atomic = true
+	defer func() { fs.Config.Atomic = false }()
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	fstest.CheckItems(t, r.Flocal, file1)
+
+	// New file
+	err := operations.CopyFile(r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// Replacing an existing file
+	file1b := r.WriteFile("file1", "file1 contents which are longer", t2)
+	err = operations.CopyFile(r.Fremote, r.Flocal, file1b.Path, file1b.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Flocal, file1b)
+	// no temporary files should be left
+	fstest.CheckItems(t, r.Fremote, file1b)
+}
+
+func TestCopyFileInplace(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if r.Fremote.Name() != "local" {
+		t.Skip("Can only check files are updated in place on the local backend")
+	}
+	fs.Config.Inplace = true
+	fs.Config.Atomic = true
+	defer func() {
+		fs.Config.Inplace = false
+		fs.Config.Atomic = false
+	}()
+	stat := func(remote string) os.FileInfo {
+		fi, err := os.Stat(filepath.Join(r.Fremote.Root(), remote))
+		require.NoError(t, err)
+		return fi
+	}
+
+	// New files are uploaded as normal
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	err := operations.CopyFile(r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+	before := stat("file1")
+
+	// Existing files are overwritten rather than replaced
+	file1b := r.WriteFile("file1", "file1 contents which are longer", t2)
+	err = operations.CopyFile(r.Fremote, r.Flocal, file1b.Path, file1b.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1b)
+	assert.True(t, os.SameFile(before, stat("file1")))
+
+	// Even when moving on the same remote
+	file2 := r.WriteObject("file2", "file2 contents", t3)
+	fstest.CheckItems(t, r.Fremote, file1b, file2)
+	err = operations.MoveFile(r.Fremote, r.Fremote, "file1", "file2")
+	require.NoError(t, err)
+	file2.Path = "file1"
+	fstest.CheckItems(t, r.Fremote, file2)
+	assert.True(t, os.SameFile(before, stat("file1")))
+}
+
+func TestCopyFileResumePartial(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	features := r.Fremote.Features()
+	if features.OpenWriterAt == nil || features.Move == nil || !features.AtomicMove {
+		t.Skip("Can't test partial downloads without OpenWriterAt and an atomic Move")
+	}
+
+	contents := "file1 contents which will be resumed"
+	file1 := r.WriteFile("file1", contents, t1)
+	fstest.CheckItems(t, r.Flocal, file1)
+
+	// A partial download which matches the source is resumed
+	partial := r.WriteObject("file1"+fs.Config.PartialSuffix, contents[:10], t2)
+	fstest.CheckItems(t, r.Fremote, partial)
+	accounting.Stats.ResetCounters()
+	err := operations.CopyFile(r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(contents)-10), accounting.Stats.GetBytes())
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// One which doesn't match is started again
+	file1b := r.WriteFile("file1", "file1 contents which have changed", t2)
+	r.WriteObject("file1"+fs.Config.PartialSuffix, "something else", t2)
+	accounting.Stats.ResetCounters()
+	err = operations.CopyFile(r.Fremote, r.Flocal, file1b.Path, file1b.Path)
+	require.NoError(t, err)
+	assert.Equal(t, file1b.Size, accounting.Stats.GetBytes())
+	fstest.CheckItems(t, r.Fremote, file1b)
+
+	// With --no-partial there are no partial files
+	fs.Config.NoPartial = true
+	defer func() { fs.Config.NoPartial = false }()
+	file1c := r.WriteFile("file1", "file1 contents without partial", t3)
+	err = operations.CopyFile(r.Fremote, r.Flocal, file1c.Path, file1c.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1c)
+}
+
+func TestCopyFileDelta(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if r.Fremote.Features().OpenWriterAt == nil {
+		t.Skip("Can't test delta copies without OpenWriterAt")
+	}
+	origBlockSize := fs.Config.DeltaBlockSize
+	fs.Config.Delta = true
+	fs.Config.DeltaBlockSize = 16
+	defer func() {
+		fs.Config.Delta = false
+		fs.Config.DeltaBlockSize = origBlockSize
+	}()
+
+	contents := "0123456789abcdef" + "the old block..." + "fedcba9876543210" + "end"
+	file1 := r.WriteFile("file1", contents, t1)
+	err := operations.CopyFile(r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// Only the changed block is written but the whole file is read
+	file1b := r.WriteFile("file1", "0123456789abcdef"+"the new block!!!"+"fedcba9876543210"+"the new end", t2)
+	accounting.Stats.ResetCounters()
+	err = operations.CopyFile(r.Fremote, r.Flocal, file1b.Path, file1b.Path)
+	require.NoError(t, err)
+	assert.Equal(t, file1b.Size, accounting.Stats.GetBytes())
+	fstest.CheckItems(t, r.Fremote, file1b)
+}
+
+func TestCopyFileImmutable(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	fs.Config.Immutable = true
+	defer func() { fs.Config.Immutable = false }()
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	fstest.CheckItems(t, r.Flocal, file1)
+
+	// New files copy normally
+	err := operations.CopyFile(r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// Modified files are refused and the destination is unchanged
+	file1b := r.WriteFile("file1", "file1 contents modified", t2)
+	err = operations.CopyFile(r.Fremote, r.Flocal, file1.Path, file1.Path)
+	assert.Equal(t, fs.ErrorImmutableModified, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// Moves are refused too and the source isn't deleted
+	err = operations.MoveFile(r.Fremote, r.Flocal, file1.Path, file1.Path)
+	assert.Equal(t, fs.ErrorImmutableModified, err)
+	fstest.CheckItems(t, r.Flocal, file1b)
+	fstest.CheckItems(t, r.Fremote, file1)
+}
+
 // testFsInfo is for unit testing fs.Info
 type testFsInfo struct {
 	name      string
@@ -771,3 +1164,52 @@ func TestListFormat(t *testing.T) {
 	assert.Equal(t, fmt.Sprintf("%d", items[1].Size())+"|subdir/|"+items[1].ModTime().Local().Format("2006-01-02 15:04:05"), list.Format(items[1]))
 
 }
+
+func TestCopyFileMultiThread(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	origStreams, origCutoff := fs.Config.MultiThreadStreams, fs.Config.MultiThreadCutoff
+	defer func() {
+		fs.Config.MultiThreadStreams, fs.Config.MultiThreadCutoff = origStreams, origCutoff
+	}()
+	fs.Config.MultiThreadStreams = 4
+	fs.Config.MultiThreadCutoff = 1
+
+	contents := fstest.RandomString(500 * 1024)
+	file1 := r.WriteObject("file1", contents, t1)
+	fstest.CheckItems(t, r.Fremote, file1)
+	fstest.CheckItems(t, r.Flocal)
+
+	// Copy to the local backend which supports OpenWriterAt
+	err := operations.CopyFile(r.Flocal, r.Fremote, file1.Path, file1.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Flocal, file1)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// Check it overwrites an existing file properly
+	file2 := r.WriteObject("file1", contents[:400*1024], t2)
+	fstest.CheckItems(t, r.Fremote, file2)
+	err = operations.CopyFile(r.Flocal, r.Fremote, file2.Path, file2.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Flocal, file2)
+}
+
+func TestGetFsInfo(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	f := r.Fremote
+	info := operations.GetFsInfo(f)
+	assert.Equal(t, f.Name(), info.Name)
+	assert.Equal(t, f.Root(), info.Root)
+	assert.Equal(t, f.String(), info.String)
+	assert.Equal(t, f.Precision(), info.Precision)
+	hashSet := hash.NewHashSet()
+	for _, hashName := range info.Hashes {
+		var ht hash.Type
+		require.NoError(t, ht.Set(hashName))
+		hashSet.Add(ht)
+	}
+	assert.Equal(t, f.Hashes(), hashSet)
+	assert.Equal(t, f.Features().Enabled(), info.Features)
+}
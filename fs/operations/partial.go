@@ -0,0 +1,154 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/pkg/errors"
+)
+
+// partialSampleSize is the amount of data at the end of a partial
+// download which is compared with the source before resuming it
+const partialSampleSize = 64 * 1024
+
+// doPartialCopy returns whether the copy of src into f should be made
+// to a partial file which can be resumed if it is interrupted.
+//
+// This needs the destination to be able to write at an offset into a
+// file and to rename it over the existing file atomically, which
+// means the local backend.
+func doPartialCopy(f fs.Info, src fs.Object) bool {
+	if fs.Config.NoPartial || fs.Config.PartialSuffix == "" {
+		return false
+	}
+	if src.Size() <= 0 {
+		return false
+	}
+	features := f.Features()
+	return features.OpenWriterAt != nil && features.Move != nil && features.AtomicMove
+}
+
+// IsPartialName returns whether remote is the name of a partial
+// download which may be resumed.
+func IsPartialName(remote string) bool {
+	return !fs.Config.NoPartial && fs.Config.PartialSuffix != "" && strings.HasSuffix(remote, fs.Config.PartialSuffix)
+}
+
+// readSample reads the bytes from start to end of o.  It returns
+// errMultiThreadNoRange if o ignores the range.
+func readSample(o fs.Object, start, end int64) (sample []byte, err error) {
+	in, err := o.Open(&fs.RangeOption{Start: start, End: end - 1})
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(in, &err)
+	sample, err = ioutil.ReadAll(io.LimitReader(in, end-start+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(sample)) > end-start {
+		return nil, errMultiThreadNoRange
+	}
+	return sample, nil
+}
+
+// partialOffset returns the offset to resume the download of src from
+// the partial file in f, or 0 if it should be started from scratch.
+//
+// The end of the data already downloaded is compared with the source
+// to make sure the partial file is from the same version of it.
+func partialOffset(f fs.Fs, partialRemote string, src fs.Object) int64 {
+	partial, err := f.NewObject(partialRemote)
+	if err != nil {
+		return 0
+	}
+	offset := partial.Size()
+	if offset <= 0 || offset > src.Size() {
+		return 0
+	}
+	start := offset - partialSampleSize
+	if start < 0 {
+		start = 0
+	}
+	srcSample, err := readSample(src, start, offset)
+	if err != nil {
+		fs.Debugf(src, "Not resuming partial download: failed to read source: %v", err)
+		return 0
+	}
+	partialSample, err := readSample(partial, start, offset)
+	if err != nil {
+		fs.Debugf(src, "Not resuming partial download: failed to read partial file: %v", err)
+		return 0
+	}
+	if !bytes.Equal(srcSample, partialSample) {
+		fs.Debugf(src, "Not resuming partial download: source has changed")
+		return 0
+	}
+	return offset
+}
+
+// partialCopy copies src to remote in f via a file with
+// --partial-suffix on the end of its name which is renamed to remote
+// when complete.
+//
+// If the copy fails the partial file is left so the next copy of src
+// can resume from where this one got to.  It returns the offset the
+// copy was resumed from.
+func partialCopy(f fs.Fs, remote string, src fs.Object, options ...fs.OpenOption) (newDst fs.Object, offset int64, err error) {
+	partialRemote := remote + fs.Config.PartialSuffix
+	offset = partialOffset(f, partialRemote, src)
+	if offset > 0 {
+		fs.Infof(src, "Resuming partial download from %v", fs.SizeSuffix(offset))
+		// The source and destination hashes are compared
+		// after the copy so there is no need to read them
+		options = []fs.OpenOption{&fs.RangeOption{Start: offset, End: -1}}
+	}
+
+	// Truncating to offset keeps the data we are resuming from
+	wc, err := f.Features().OpenWriterAt(partialRemote, offset)
+	if err != nil {
+		return nil, offset, errors.Wrap(err, "partial copy: failed to open destination")
+	}
+	in0, err := src.Open(options...)
+	if err != nil {
+		_ = wc.Close()
+		return nil, offset, errors.Wrap(err, "failed to open source object")
+	}
+	in := accounting.NewAccount(in0, src).WithBuffer() // account and buffer the transfer
+	w := &offsetWriter{w: wc, off: offset}
+	_, err = io.Copy(w, in)
+	closeErr := in.Close()
+	if err == nil {
+		err = closeErr
+	}
+	closeErr = wc.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, offset, err
+	}
+	if w.off != src.Size() {
+		return nil, offset, errors.Errorf("partial copy: read %d bytes but expected %d", w.off, src.Size())
+	}
+
+	partial, err := f.NewObject(partialRemote)
+	if err != nil {
+		return nil, offset, errors.Wrap(err, "partial copy: failed to find object after copy")
+	}
+	err = partial.SetModTime(src.ModTime())
+	switch err {
+	case nil, fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
+	default:
+		return nil, offset, errors.Wrap(err, "partial copy: failed to set modification time")
+	}
+	newDst, err = f.Features().Move(partial, remote)
+	if err != nil {
+		return nil, offset, errors.Wrap(err, "partial copy: failed to rename to final name")
+	}
+	return newDst, offset, nil
+}
@@ -0,0 +1,102 @@
+package operations
+
+import (
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "operations/hashsum",
+		Fn:    rcHashsum,
+		Title: "Works out the hash of a file in the background.",
+		Help: `
+This takes the following parameters
+
+- fs - a remote name string eg "drive:"
+- remote - a path within that remote eg "dir/file.txt"
+- hashType - the name of the hash eg "MD5" or "SHA-1"
+
+It starts a job to work out the hash and returns its ID in "jobid".
+Read the result with job/status - when the job has finished its
+"output" contains
+
+- hash - the hash of the file
+- hashType - the name of the hash
+
+If the remote stores hashes of the type asked for, eg MD5 on drive,
+then it is read straight away.  Otherwise the file is downloaded to
+work it out and job/status shows how far it has got in "progress"
+which has "bytes" read so far out of "size".
+
+Eg
+
+    rclone rc operations/hashsum fs=drive: remote=file.txt hashType=MD5
+    rclone rc job/status jobid=1
+`,
+	})
+}
+
+// getString reads the string parameter key from in
+func getString(in rc.Params, key string) (string, error) {
+	value, ok := in[key]
+	if !ok {
+		return "", errors.Errorf("%s is needed", key)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.Errorf("value must be string %s=%v", key, value)
+	}
+	return s, nil
+}
+
+// Start a job to work out the hash of a file
+func rcHashsum(in rc.Params) (out rc.Params, err error) {
+	fsName, err := getString(in, "fs")
+	if err != nil {
+		return nil, err
+	}
+	remote, err := getString(in, "remote")
+	if err != nil {
+		return nil, err
+	}
+	hashType, err := getString(in, "hashType")
+	if err != nil {
+		return nil, err
+	}
+	var ht hash.Type
+	err = ht.Set(hashType)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.NewFs(fsName)
+	if err != nil {
+		return nil, err
+	}
+	job := rc.StartJob(hashsumJob(f, remote, ht))
+	return rc.Params{"jobid": job.ID}, nil
+}
+
+// hashsumJob returns a job to work out the hash of type ht of remote
+// in f
+func hashsumJob(f fs.Fs, remote string, ht hash.Type) func(job *rc.Job) (rc.Params, error) {
+	return func(job *rc.Job) (rc.Params, error) {
+		o, err := f.NewObject(remote)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := objectHash(ht, o, func(acc *accounting.Account) {
+			job.SetProgress(func() rc.Params {
+				bytes, size := acc.Progress()
+				return rc.Params{"bytes": bytes, "size": size}
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		return rc.Params{"hash": sum, "hashType": ht.String()}, nil
+	}
+}
@@ -0,0 +1,109 @@
+package operations
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// transferLimiter limits the number of concurrent transfers to or
+// from a single remote
+type transferLimiter struct {
+	id     int           // creation order - limiters are acquired in this order
+	tokens chan struct{} // one token for each allowed concurrent transfer
+}
+
+// transferLimiterList is a slice of *transferLimiter sorted by id
+type transferLimiterList []*transferLimiter
+
+// Len is part of sort.Interface.
+func (ls transferLimiterList) Len() int { return len(ls) }
+
+// Swap is part of sort.Interface.
+func (ls transferLimiterList) Swap(i, j int) { ls[i], ls[j] = ls[j], ls[i] }
+
+// Less is part of sort.Interface.
+func (ls transferLimiterList) Less(i, j int) bool { return ls[i].id < ls[j].id }
+
+var (
+	transferLimitersMu sync.Mutex
+	// transferLimiters are keyed by remote name rather than Fs
+	// so the many Fs made for the same remote share one and the
+	// map doesn't grow without limit
+	transferLimiters = map[string]*transferLimiter{}
+)
+
+// findTransferLimit returns the Fs providing the transfer limit for f
+// and the limit, unwrapping wrapping remotes such as crypt.  It
+// returns nil, 0 if there is no limit.
+func findTransferLimit(f fs.Info) (fs.Info, int) {
+	for f != nil {
+		if do, ok := f.(fs.TransferLimiter); ok {
+			if n := do.MaxConcurrentTransfers(); n > 0 {
+				return f, n
+			}
+		}
+		features := f.Features()
+		if features == nil || features.UnWrap == nil {
+			break
+		}
+		f = features.UnWrap()
+	}
+	return nil, 0
+}
+
+// getTransferLimiter returns the transferLimiter for the remote f is
+// on, making it if necessary, or nil if f has no transfer limit
+func getTransferLimiter(f fs.Info) *transferLimiter {
+	f, n := findTransferLimit(f)
+	if f == nil {
+		return nil
+	}
+	transferLimitersMu.Lock()
+	defer transferLimitersMu.Unlock()
+	limiter, ok := transferLimiters[f.Name()]
+	if !ok {
+		limiter = &transferLimiter{
+			id:     len(transferLimiters),
+			tokens: make(chan struct{}, n),
+		}
+		transferLimiters[f.Name()] = limiter
+	}
+	return limiter
+}
+
+// acquireTransfer waits until a transfer may start to or from all of
+// the Fs passed in according to their transfer limits.  It returns a
+// function which must be called to release the transfer when done.
+//
+// The limiters are always acquired in the same order so concurrent
+// transfers in opposite directions can't deadlock.
+func acquireTransfer(fss ...fs.Info) (release func()) {
+	var limiters transferLimiterList
+	for _, f := range fss {
+		limiter := getTransferLimiter(f)
+		if limiter == nil {
+			continue
+		}
+		duplicate := false
+		for _, existing := range limiters {
+			if existing == limiter {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			limiters = append(limiters, limiter)
+		}
+	}
+	sort.Sort(limiters)
+	for _, limiter := range limiters {
+		limiter.tokens <- struct{}{}
+	}
+	return func() {
+		for i := len(limiters) - 1; i >= 0; i-- {
+			<-limiters[i].tokens
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// OrderBy is a parsed --order-by
+type OrderBy struct {
+	Less  func(a, b DirEntry) bool // true if a should go before b
+	Mixed bool                     // take the entries alternately from each end
+}
+
+// ParseOrderBy turns a --order-by string into an OrderBy, returning
+// nil if nothing should be ordered
+//
+// The string is a key of size, path (or name) or modtime optionally
+// followed by ,ascending ,descending or ,mixed
+func ParseOrderBy(config string) (*OrderBy, error) {
+	if config == "" {
+		return nil, nil
+	}
+	parts := strings.Split(strings.ToLower(config), ",")
+	if len(parts) > 2 {
+		return nil, errors.Errorf("bad --order-by string %q", config)
+	}
+	o := &OrderBy{}
+	switch key := strings.TrimSpace(parts[0]); key {
+	case "size":
+		o.Less = func(a, b DirEntry) bool {
+			return a.Size() < b.Size()
+		}
+	case "path", "name":
+		o.Less = func(a, b DirEntry) bool {
+			return a.Remote() < b.Remote()
+		}
+	case "modtime":
+		o.Less = func(a, b DirEntry) bool {
+			return a.ModTime().Before(b.ModTime())
+		}
+	default:
+		return nil, errors.Errorf("unknown --order-by key %q", key)
+	}
+	if len(parts) == 2 {
+		switch direction := strings.TrimSpace(parts[1]); direction {
+		case "ascending", "asc":
+		case "descending", "desc":
+			ascending := o.Less
+			o.Less = func(a, b DirEntry) bool {
+				return ascending(b, a)
+			}
+		case "mixed":
+			o.Mixed = true
+		default:
+			return nil, errors.Errorf("unknown --order-by direction %q", direction)
+		}
+	}
+	return o, nil
+}
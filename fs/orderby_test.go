@@ -0,0 +1,44 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := NewDir("a", t0).SetSize(2)
+	b := NewDir("b", t0.Add(time.Hour)).SetSize(1)
+	for _, test := range []struct {
+		in        string
+		wantNil   bool
+		wantMixed bool
+		wantLess  bool // whether a goes before b
+		wantErr   bool
+	}{
+		{"", true, false, false, false},
+		{"size", false, false, false, false},
+		{"Size,Descending", false, false, true, false},
+		{"size,mixed", false, true, false, false},
+		{"name", false, false, true, false},
+		{"path,desc", false, false, false, false},
+		{"modtime,asc", false, false, true, false},
+		{"potato", false, false, false, true},
+		{"size,potato", false, false, false, true},
+		{"size,ascending,mixed", false, false, false, true},
+	} {
+		got, err := ParseOrderBy(test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+		if test.wantErr {
+			continue
+		}
+		assert.Equal(t, test.wantNil, got == nil, test.in)
+		if got == nil {
+			continue
+		}
+		assert.Equal(t, test.wantMixed, got.Mixed, test.in)
+		assert.Equal(t, test.wantLess, got.Less(a, b), test.in)
+	}
+}
@@ -0,0 +1,241 @@
+// Manage background jobs started by remote control functions
+
+package rc
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// jobExpireDuration is how long finished jobs are kept so their
+// results can be read
+const jobExpireDuration = 60 * time.Second
+
+// Job describes a remote control function running in the background
+type Job struct {
+	mu        sync.Mutex
+	ID        int64
+	StartTime time.Time
+	EndTime   time.Time
+	Finished  bool
+	Success   bool
+	Error     string
+	Output    Params
+	progress  func() Params
+}
+
+// jobs holds all the jobs
+type jobs struct {
+	mu     sync.Mutex
+	jobs   map[int64]*Job
+	lastID int64
+}
+
+var running = newJobs()
+
+// newJobs makes a new jobs structure
+func newJobs() *jobs {
+	return &jobs{
+		jobs: make(map[int64]*Job),
+	}
+}
+
+// expire removes the jobs which finished more than jobExpireDuration
+// ago - call with the lock held
+func (js *jobs) expire() {
+	now := time.Now()
+	for ID, job := range js.jobs {
+		job.mu.Lock()
+		if job.Finished && now.Sub(job.EndTime) > jobExpireDuration {
+			delete(js.jobs, ID)
+		}
+		job.mu.Unlock()
+	}
+}
+
+// get a job by ID or nil if not found
+func (js *jobs) get(ID int64) *Job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.expire()
+	return js.jobs[ID]
+}
+
+// jobIDs sorts a slice of job IDs into ascending order
+type jobIDs []int64
+
+// Len is part of sort.Interface.
+func (ids jobIDs) Len() int { return len(ids) }
+
+// Swap is part of sort.Interface.
+func (ids jobIDs) Swap(i, j int) { ids[i], ids[j] = ids[j], ids[i] }
+
+// Less is part of sort.Interface.
+func (ids jobIDs) Less(i, j int) bool { return ids[i] < ids[j] }
+
+// IDs returns the IDs of the jobs in order
+func (js *jobs) IDs() (IDs []int64) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.expire()
+	IDs = []int64{}
+	for ID := range js.jobs {
+		IDs = append(IDs, ID)
+	}
+	sort.Sort(jobIDs(IDs))
+	return IDs
+}
+
+// start runs fn in the background as a new job
+func (js *jobs) start(fn func(job *Job) (Params, error)) *Job {
+	js.mu.Lock()
+	js.expire()
+	js.lastID++
+	job := &Job{
+		ID:        js.lastID,
+		StartTime: time.Now(),
+	}
+	js.jobs[job.ID] = job
+	js.mu.Unlock()
+	go job.run(fn)
+	return job
+}
+
+// StartJob runs fn in the background as a new job and returns it.
+//
+// The output and any error fn returns are kept in the job so can be
+// read with job/status.
+func StartJob(fn func(job *Job) (Params, error)) *Job {
+	return running.start(fn)
+}
+
+// run fn and record what it returns in the job
+func (job *Job) run(fn func(job *Job) (Params, error)) {
+	out, err := fn(job)
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.EndTime = time.Now()
+	job.Finished = true
+	job.progress = nil
+	if err != nil {
+		job.Error = err.Error()
+	} else {
+		job.Success = true
+		job.Output = out
+	}
+}
+
+// SetProgress sets fn to be called to read the progress of the job
+// while it is running
+func (job *Job) SetProgress(fn func() Params) {
+	job.mu.Lock()
+	job.progress = fn
+	job.mu.Unlock()
+}
+
+// status returns the state of the job
+func (job *Job) status() Params {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	out := Params{
+		"id":        job.ID,
+		"startTime": job.StartTime,
+		"finished":  job.Finished,
+		"success":   job.Success,
+		"error":     job.Error,
+		"output":    job.Output,
+	}
+	if job.Finished {
+		out["endTime"] = job.EndTime
+		out["duration"] = job.EndTime.Sub(job.StartTime).Seconds()
+	} else {
+		out["duration"] = time.Since(job.StartTime).Seconds()
+		if job.progress != nil {
+			out["progress"] = job.progress()
+		}
+	}
+	return out
+}
+
+func init() {
+	Add(Call{
+		Path:  "job/status",
+		Fn:    rcJobStatus,
+		Title: "Reads the status of the job ID",
+		Help: `
+This takes the following parameters
+
+- jobid - id of the job (integer)
+
+Results
+
+- finished - boolean whether the job has finished or not
+- success - boolean - true for success false otherwise
+- error - error from the job or empty string for no error
+- output - output of the job as would have been returned if called synchronously
+- progress - progress of the job while it is running, if known
+- startTime - time the job started (eg "2018-10-26T18:50:20.528746884+01:00")
+- endTime - time the job finished (eg "2018-10-26T18:50:20.528746884+01:00")
+- duration - time in seconds that the job ran for
+
+Jobs are kept for 60 seconds after they finish.
+`,
+	})
+	Add(Call{
+		Path:  "job/list",
+		Fn:    rcJobList,
+		Title: "Lists the IDs of the running jobs",
+		Help: `
+Parameters - None
+
+Results
+
+- jobids - array of integer job ids
+`,
+	})
+}
+
+// getJobID reads the jobid parameter which may be a number or a string
+func getJobID(in Params) (int64, error) {
+	value, ok := in["jobid"]
+	if !ok {
+		return 0, errors.New("jobid is needed")
+	}
+	switch x := value.(type) {
+	case int64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case float64:
+		return int64(x), nil
+	case string:
+		ID, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return 0, errors.Wrap(err, "bad jobid")
+		}
+		return ID, nil
+	}
+	return 0, errors.Errorf("bad jobid %v", value)
+}
+
+// Return the status of a job
+func rcJobStatus(in Params) (out Params, err error) {
+	ID, err := getJobID(in)
+	if err != nil {
+		return nil, err
+	}
+	job := running.get(ID)
+	if job == nil {
+		return nil, errors.Errorf("job %d not found", ID)
+	}
+	return job.status(), nil
+}
+
+// Return the IDs of the jobs
+func rcJobList(in Params) (out Params, err error) {
+	return Params{"jobids": running.IDs()}, nil
+}
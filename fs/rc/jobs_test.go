@@ -0,0 +1,106 @@
+package rc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wait for job to finish
+func waitJob(t *testing.T, job *Job) {
+	for i := 0; i < 100; i++ {
+		job.mu.Lock()
+		finished := job.Finished
+		job.mu.Unlock()
+		if finished {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for job")
+}
+
+func TestGetJobID(t *testing.T) {
+	for _, test := range []struct {
+		in      Params
+		want    int64
+		wantErr bool
+	}{
+		{Params{"jobid": int64(1)}, 1, false},
+		{Params{"jobid": 2}, 2, false},
+		{Params{"jobid": 3.0}, 3, false},
+		{Params{"jobid": "4"}, 4, false},
+		{Params{"jobid": "potato"}, 0, true},
+		{Params{"jobid": true}, 0, true},
+		{Params{}, 0, true},
+	} {
+		got, err := getJobID(test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	orig := running
+	defer func() { running = orig }()
+	running = newJobs()
+
+	release := make(chan struct{})
+	job := StartJob(func(job *Job) (Params, error) {
+		job.SetProgress(func() Params { return Params{"bytes": 1} })
+		<-release
+		return Params{"hello": "world"}, nil
+	})
+	assert.Equal(t, int64(1), job.ID)
+	failed := StartJob(func(job *Job) (Params, error) {
+		return nil, errors.New("boom")
+	})
+	assert.Equal(t, int64(2), failed.ID)
+
+	out, err := rcJobList(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Params{"jobids": []int64{1, 2}}, out)
+
+	// Wait for the progress to be set
+	for i := 0; i < 100; i++ {
+		out, err = rcJobStatus(Params{"jobid": "1"})
+		require.NoError(t, err)
+		if out["progress"] != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, false, out["finished"])
+	assert.Equal(t, Params{"bytes": 1}, out["progress"])
+
+	close(release)
+	waitJob(t, job)
+	out, err = rcJobStatus(Params{"jobid": 1})
+	require.NoError(t, err)
+	assert.Equal(t, true, out["finished"])
+	assert.Equal(t, true, out["success"])
+	assert.Equal(t, "", out["error"])
+	assert.Equal(t, Params{"hello": "world"}, out["output"])
+	assert.Nil(t, out["progress"])
+
+	waitJob(t, failed)
+	out, err = rcJobStatus(Params{"jobid": 2})
+	require.NoError(t, err)
+	assert.Equal(t, true, out["finished"])
+	assert.Equal(t, false, out["success"])
+	assert.Equal(t, "boom", out["error"])
+
+	_, err = rcJobStatus(Params{"jobid": 3})
+	assert.EqualError(t, err, "job 3 not found")
+
+	// Finished jobs expire
+	job.mu.Lock()
+	job.EndTime = time.Now().Add(-2 * jobExpireDuration)
+	job.mu.Unlock()
+	out, err = rcJobList(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Params{"jobids": []int64{2}}, out)
+}
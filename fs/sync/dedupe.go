@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/operations"
+)
+
+// uploadDedupe keeps track of the files uploaded to the destination
+// by hash for --dedupe-on-upload so files with the same contents can
+// be server side copied from the first one uploaded instead of being
+// uploaded again
+type uploadDedupe struct {
+	f        fs.Fs
+	doCopy   func(src fs.Object, remote string) (fs.Object, error)
+	hashType hash.Type
+	mu       sync.Mutex
+	uploaded map[string]fs.Object // objects uploaded to f by hash
+}
+
+// newUploadDedupe returns an uploadDedupe to copy files from fsrc to
+// fdst with.
+//
+// It returns nil if --dedupe-on-upload isn't set or it can't be used
+// between fsrc and fdst.
+func newUploadDedupe(fdst, fsrc fs.Fs) *uploadDedupe {
+	if !fs.Config.DedupeOnUpload {
+		return nil
+	}
+	doCopy := fdst.Features().Copy
+	if doCopy == nil {
+		fs.Errorf(fdst, "Ignoring --dedupe-on-upload as the destination does not support server side copy")
+		return nil
+	}
+	if operations.SameConfig(fsrc, fdst) {
+		// Transfers are server side already
+		return nil
+	}
+	hashType := fsrc.Hashes().Overlap(fdst.Hashes()).GetOne()
+	if hashType == hash.None {
+		fs.Errorf(fdst, "Ignoring --dedupe-on-upload as the source and destination do not have a common hash")
+		return nil
+	}
+	return &uploadDedupe{
+		f:        fdst,
+		doCopy:   doCopy,
+		hashType: hashType,
+		uploaded: make(map[string]fs.Object),
+	}
+}
+
+// hash returns the hash of src to dedupe it with or "" if it
+// shouldn't be
+func (d *uploadDedupe) hash(src fs.Object) string {
+	if src.Size() <= 0 || fs.Config.DryRun {
+		return ""
+	}
+	sum, err := src.Hash(d.hashType)
+	if err != nil {
+		fs.Debugf(src, "Not deduping as failed to read hash: %v", err)
+		return ""
+	}
+	return sum
+}
+
+// copy tries to make remote with the contents of src by a server
+// side copy of a file uploaded earlier with the same hash srcHash.
+//
+// It returns the new object or nil if there wasn't a file to copy or
+// the copy failed, in which case src should be uploaded as normal.
+func (d *uploadDedupe) copy(remote string, src fs.Object, srcHash string) fs.Object {
+	if srcHash == "" {
+		return nil
+	}
+	d.mu.Lock()
+	first := d.uploaded[srcHash]
+	d.mu.Unlock()
+	if first == nil || first.Size() != src.Size() {
+		return nil
+	}
+	newDst, err := d.doCopy(first, remote)
+	if err != nil {
+		fs.Debugf(src, "Uploading as server side copy from %q failed: %v", first.Remote(), err)
+		return nil
+	}
+	if !newDst.ModTime().Equal(src.ModTime()) {
+		err = newDst.SetModTime(src.ModTime())
+		if err != nil && err != fs.ErrorCantSetModTime && err != fs.ErrorCantSetModTimeWithoutDelete {
+			fs.Debugf(newDst, "Failed to set modification time after server side copy: %v", err)
+		}
+	}
+	fs.Infof(src, "Copied (server side copy of %q with the same contents)", first.Remote())
+	return newDst
+}
+
+// add records that newDst was uploaded with srcHash so later files
+// with the same hash can be copied from it
+func (d *uploadDedupe) add(srcHash string, newDst fs.Object) {
+	if srcHash == "" || newDst == nil {
+		return
+	}
+	d.mu.Lock()
+	if _, found := d.uploaded[srcHash]; !found {
+		d.uploaded[srcHash] = newDst
+	}
+	d.mu.Unlock()
+}
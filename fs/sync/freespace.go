@@ -0,0 +1,159 @@
+package sync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// errorFreeSpaceLow is returned when the destination doesn't have
+// --min-free-space left
+var errorFreeSpaceLow = fserrors.FatalError(errors.New("free space on destination is below --min-free-space"))
+
+// freeSpaceCheckInterval is how often the free space is read from
+// the destination while the sync is running
+var freeSpaceCheckInterval = time.Minute
+
+// freeSpace keeps track of the free space on the destination for
+// --min-free-space
+type freeSpace struct {
+	f       fs.Fs
+	about   func() (*fs.Usage, error)
+	mu      sync.Mutex
+	free    int64     // free space at the last check
+	bytes   int64     // bytes transferred at the last check
+	checked time.Time // time of the last check
+}
+
+// newFreeSpace checks the free space on f is above --min-free-space
+// and returns a freeSpace to track it during the sync.
+//
+// It returns nil if --min-free-space isn't set or f can't report its
+// free space.
+func newFreeSpace(f fs.Fs) (*freeSpace, error) {
+	if fs.Config.MinFreeSpace < 0 {
+		return nil, nil
+	}
+	about := f.Features().About
+	if about == nil {
+		fs.Logf(f, "Ignoring --min-free-space as the destination can't report its free space")
+		return nil, nil
+	}
+	fsp := &freeSpace{
+		f:     f,
+		about: about,
+	}
+	err := fsp.read()
+	if err != nil {
+		fs.Logf(f, "Ignoring --min-free-space: %v", err)
+		return nil, nil
+	}
+	fs.Debugf(f, "Free space is %v", fs.SizeSuffix(fsp.free))
+	if fsp.free < int64(fs.Config.MinFreeSpace) {
+		fs.Errorf(f, "Free space %v is below --min-free-space %v", fs.SizeSuffix(fsp.free), fs.Config.MinFreeSpace)
+		return nil, errorFreeSpaceLow
+	}
+	return fsp, nil
+}
+
+// read the free space from the destination - call with mu held
+func (fsp *freeSpace) read() error {
+	usage, err := fsp.about()
+	if err != nil {
+		return errors.Wrap(err, "failed to read free space")
+	}
+	if usage.Free == nil {
+		return errors.New("destination doesn't report its free space")
+	}
+	fsp.free = *usage.Free
+	fsp.bytes = accounting.Stats.GetBytes()
+	fsp.checked = time.Now()
+	return nil
+}
+
+// estimate returns the current free space, reading it again from the
+// destination if it hasn't been read for freeSpaceCheckInterval,
+// otherwise taking off the bytes transferred since it was read.
+func (fsp *freeSpace) estimate() int64 {
+	fsp.mu.Lock()
+	defer fsp.mu.Unlock()
+	if time.Since(fsp.checked) >= freeSpaceCheckInterval {
+		err := fsp.read()
+		if err != nil {
+			fs.Debugf(fsp.f, "Using estimated free space: %v", err)
+		}
+	}
+	return fsp.free - (accounting.Stats.GetBytes() - fsp.bytes)
+}
+
+// planTransfers reads all the transfers from in and adds up their
+// sizes before sending any of them to out, so the sync can stop
+// before it starts if they won't all fit.  In that case it returns
+// errorFreeSpaceLow without sending any.  It closes out when done.
+//
+// usesSpace should return whether a transfer takes up space on the
+// destination.
+func (fsp *freeSpace) planTransfers(ctx context.Context, in <-chan fs.ObjectPair, out chan<- fs.ObjectPair, usesSpace func(fs.ObjectPair) bool) error {
+	defer close(out)
+	var pairs []fs.ObjectPair
+	var total int64
+	for pair := range in {
+		pairs = append(pairs, pair)
+		if size := pair.Src.Size(); size > 0 && usesSpace(pair) {
+			total += size
+		}
+	}
+	free := fsp.estimate()
+	if free-total < int64(fs.Config.MinFreeSpace) {
+		fs.Errorf(fsp.f, "Not transferring %d files (%v) as free space %v would go below --min-free-space %v", len(pairs), fs.SizeSuffix(total), fs.SizeSuffix(free), fs.Config.MinFreeSpace)
+		return errorFreeSpaceLow
+	}
+	for _, pair := range pairs {
+		select {
+		case out <- pair:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// wait returns when there is enough free space to transfer size bytes
+// to remote without going below --min-free-space.
+//
+// With --cutoff-mode hard it returns errorFreeSpaceLow if there isn't,
+// otherwise it pauses, checking the free space every
+// freeSpaceCheckInterval until there is or ctx is cancelled.
+func (fsp *freeSpace) wait(ctx context.Context, remote string, size int64) error {
+	if size < 0 {
+		size = 0
+	}
+	paused := false
+	for {
+		free := fsp.estimate()
+		if free-size >= int64(fs.Config.MinFreeSpace) {
+			if paused {
+				fs.Logf(remote, "Resuming transfer as free space is now %v", fs.SizeSuffix(free))
+			}
+			return nil
+		}
+		if fs.Config.CutoffMode == fs.CutoffModeHard {
+			fs.Errorf(remote, "Not transferring as free space %v is below --min-free-space %v", fs.SizeSuffix(free), fs.Config.MinFreeSpace)
+			return errorFreeSpaceLow
+		}
+		if !paused {
+			fs.Logf(remote, "Pausing transfer until free space %v is above --min-free-space %v", fs.SizeSuffix(free), fs.Config.MinFreeSpace)
+			paused = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(freeSpaceCheckInterval):
+		}
+	}
+}
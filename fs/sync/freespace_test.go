@@ -0,0 +1,114 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeSpaceWait(t *testing.T) {
+	oldMinFreeSpace, oldCutoffMode, oldInterval := fs.Config.MinFreeSpace, fs.Config.CutoffMode, freeSpaceCheckInterval
+	defer func() {
+		fs.Config.MinFreeSpace, fs.Config.CutoffMode, freeSpaceCheckInterval = oldMinFreeSpace, oldCutoffMode, oldInterval
+	}()
+	fs.Config.MinFreeSpace = 100
+	freeSpaceCheckInterval = time.Hour
+
+	free := int64(1000)
+	reads := 0
+	fsp := &freeSpace{
+		about: func() (*fs.Usage, error) {
+			reads++
+			return &fs.Usage{Free: &free}, nil
+		},
+	}
+	accounting.Stats.ResetCounters()
+	require.NoError(t, fsp.read())
+	assert.Equal(t, 1, reads)
+	ctx := context.Background()
+
+	// Enough space, using the estimate
+	fs.Config.CutoffMode = fs.CutoffModeHard
+	assert.NoError(t, fsp.wait(ctx, "file", 900))
+	assert.NoError(t, fsp.wait(ctx, "file", -1))
+	assert.Equal(t, 1, reads)
+
+	// Bytes transferred come off the estimate
+	accounting.Stats.Bytes(500)
+	defer accounting.Stats.ResetCounters()
+	assert.Equal(t, errorFreeSpaceLow, fsp.wait(ctx, "file", 401))
+	assert.NoError(t, fsp.wait(ctx, "file", 400))
+	assert.Equal(t, 1, reads)
+
+	// Soft mode pauses until the space is freed
+	fs.Config.CutoffMode = fs.CutoffModeSoft
+	freeSpaceCheckInterval = time.Millisecond
+	free = 200
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		fsp.mu.Lock()
+		free = 1000
+		fsp.mu.Unlock()
+	}()
+	assert.NoError(t, fsp.wait(ctx, "file", 800))
+	assert.True(t, reads > 1)
+
+	// Until the context is cancelled
+	free = 200
+	fsp.checked = time.Time{}
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, fsp.wait(ctx, "file", 800))
+}
+
+func TestFreeSpacePlanTransfers(t *testing.T) {
+	oldMinFreeSpace := fs.Config.MinFreeSpace
+	defer func() { fs.Config.MinFreeSpace = oldMinFreeSpace }()
+	fs.Config.MinFreeSpace = 100
+
+	free := int64(130)
+	fsp := &freeSpace{
+		about: func() (*fs.Usage, error) {
+			return &fs.Usage{Free: &free}, nil
+		},
+	}
+	accounting.Stats.ResetCounters()
+	require.NoError(t, fsp.read())
+	ctx := context.Background()
+
+	plan := func(usesSpace func(fs.ObjectPair) bool, sizes ...int) (sent int, err error) {
+		in := make(chan fs.ObjectPair, len(sizes))
+		for _, size := range sizes {
+			in <- fs.ObjectPair{Src: object.NewMemoryObject("file", t1, make([]byte, size))}
+		}
+		close(in)
+		out := make(chan fs.ObjectPair, len(sizes))
+		err = fsp.planTransfers(ctx, in, out, usesSpace)
+		for range out {
+			sent++
+		}
+		return sent, err
+	}
+	all := func(fs.ObjectPair) bool { return true }
+
+	// The files fit
+	sent, err := plan(all, 10, 20)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+
+	// Each file fits but not all of them
+	sent, err = plan(all, 10, 20, 10)
+	assert.Equal(t, errorFreeSpaceLow, err)
+	assert.Equal(t, 0, sent)
+
+	// Unless they don't use any space
+	sent, err = plan(func(fs.ObjectPair) bool { return false }, 10, 20, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+}
@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// lessFn compares two transfers returning true if a should go first
+type lessFn func(a, b fs.ObjectPair) bool
+
+// orderBy is the parsed --order-by
+type orderBy struct {
+	less  lessFn // ascending order of the key
+	mixed bool   // take the transfers alternately from each end
+}
+
+// parseOrderBy turns a config string into an orderBy, returning nil
+// if the transfers shouldn't be ordered
+//
+// The transfers are ordered by their sources as parsed by
+// fs.ParseOrderBy
+func parseOrderBy(config string) (*orderBy, error) {
+	o, err := fs.ParseOrderBy(config)
+	if err != nil || o == nil {
+		return nil, err
+	}
+	return &orderBy{
+		less: func(a, b fs.ObjectPair) bool {
+			return o.Less(a.Src, b.Src)
+		},
+		mixed: o.Mixed,
+	}, nil
+}
+
+// orderedQueue holds the transfers waiting to be done in order
+type orderedQueue struct {
+	orderBy
+	pairs []fs.ObjectPair // sorted by less
+	last  bool            // set if the last transfer came from the end
+}
+
+// push adds pair to the queue
+func (q *orderedQueue) push(pair fs.ObjectPair) {
+	i := sort.Search(len(q.pairs), func(i int) bool {
+		return q.less(pair, q.pairs[i])
+	})
+	q.pairs = append(q.pairs, fs.ObjectPair{})
+	copy(q.pairs[i+1:], q.pairs[i:])
+	q.pairs[i] = pair
+}
+
+// next returns the transfer which should be done next without
+// removing it
+func (q *orderedQueue) next() fs.ObjectPair {
+	if q.mixed && !q.last {
+		return q.pairs[len(q.pairs)-1]
+	}
+	return q.pairs[0]
+}
+
+// pop removes the transfer returned by next
+func (q *orderedQueue) pop() {
+	if q.mixed && !q.last {
+		q.pairs = q.pairs[:len(q.pairs)-1]
+	} else {
+		q.pairs = q.pairs[1:]
+	}
+	if q.mixed {
+		q.last = !q.last
+	}
+}
+
+// orderTransfers reads the transfers from in and sends them to out in
+// the order given by o.  It holds up to lookahead transfers to sort
+// them so it can start sending before the end of in.  It closes out
+// when in is closed and all the transfers have been sent.
+func orderTransfers(ctx context.Context, o *orderBy, lookahead int, in <-chan fs.ObjectPair, out chan<- fs.ObjectPair) {
+	defer close(out)
+	if lookahead < 1 {
+		lookahead = 1
+	}
+	q := &orderedQueue{orderBy: *o}
+	for in != nil || len(q.pairs) > 0 {
+		// Only read more transfers if there is room, and only send
+		// if there is anything to send
+		var (
+			input  <-chan fs.ObjectPair
+			output chan<- fs.ObjectPair
+			next   fs.ObjectPair
+		)
+		if len(q.pairs) < lookahead {
+			input = in
+		}
+		if len(q.pairs) > 0 {
+			output = out
+			next = q.next()
+		}
+		select {
+		case pair, ok := <-input:
+			if !ok {
+				in = nil
+				continue
+			}
+			q.push(pair)
+		case output <- next:
+			q.pop()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -2,18 +2,23 @@
 package sync
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"path"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/config"
 	"github.com/ncw/rclone/fs/filter"
 	"github.com/ncw/rclone/fs/fserrors"
 	"github.com/ncw/rclone/fs/hash"
 	"github.com/ncw/rclone/fs/march"
+	"github.com/ncw/rclone/fs/object"
 	"github.com/ncw/rclone/fs/operations"
 	"github.com/pkg/errors"
 )
@@ -31,9 +36,14 @@ type syncCopyMove struct {
 	cancel         func()                 // cancel the context
 	deletersWg     sync.WaitGroup         // for delete before go routine
 	deleteFilesCh  chan fs.Object         // channel to receive deletes if delete before
+	planDeletes    bool                   // set if deletes before are collected and checked against the limits first
 	trackRenames   bool                   // set if we should do server side renames
+	renameStrategy trackRenamesStrategy   // strategies used for tracking renames
+	modifyWindow   time.Duration          // modify window between fsrc, fdst
 	dstFilesMu     sync.Mutex             // protect dstFiles
 	dstFiles       map[string]fs.Object   // dst files, always filled
+	excludedMu     sync.Mutex             // protect excluded
+	excluded       map[string]fs.Object   // dst files excluded by the filters for --delete-excluded
 	srcFiles       map[string]fs.Object   // src files, only used if deleteBefore
 	srcFilesChan   chan fs.Object         // passes src objects
 	srcFilesResult chan error             // error result of src listing
@@ -42,24 +52,35 @@ type syncCopyMove struct {
 	dstEmptyDirs   map[string]fs.DirEntry // potentially empty directories
 	srcEmptyDirsMu sync.Mutex             // protect srcEmptyDirs
 	srcEmptyDirs   map[string]fs.DirEntry // potentially empty directories
+	srcMovedDirs   map[string]fs.DirEntry // all the source directories for --delete-empty-src-dirs - protected by srcEmptyDirsMu
+	dirMarker      string                 // leaf name of the placeholders for empty directories, "" if not in use
+	srcDirMarkers  bool                   // set if objects called dirMarker in fsrc are placeholders
+	dstDirMarkers  bool                   // set if objects called dirMarker in fdst are placeholders
+	srcMarkers     map[string]fs.Object   // placeholders in fsrc - protected by srcEmptyDirsMu
+	dstMarkers     map[string]fs.Object   // placeholders in fdst only - protected by dstFilesMu
+	dstPartials    map[string]fs.Object   // partial downloads in fdst only - protected by dstFilesMu
 	checkerWg      sync.WaitGroup         // wait for checkers
 	toBeChecked    fs.ObjectPairChan      // checkers channel
 	transfersWg    sync.WaitGroup         // wait for transfers
 	toBeUploaded   fs.ObjectPairChan      // copiers channel
+	orderBy        *orderBy               // order of the transfers for --order-by, nil if not ordering
+	toBeCopied     fs.ObjectPairChan      // transfers in order - the same as toBeUploaded if not ordering
 	errorMu        sync.Mutex             // Mutex covering the errors variables
 	err            error                  // normal error from copy process
 	noRetryErr     error                  // error with NoRetry set
 	fatalErr       error                  // fatal error
 	commonHash     hash.Type              // common hash type between src and dst
 	renameMapMu    sync.Mutex             // mutex to protect the below
-	renameMap      map[string][]fs.Object // dst files by hash - only used by trackRenames
+	renameMap      map[string][]fs.Object // dst files by rename ID - only used by trackRenames
 	renamerWg      sync.WaitGroup         // wait for renamers
 	toBeRenamed    fs.ObjectPairChan      // renamers channel
 	trackRenamesWg sync.WaitGroup         // wg for background track renames
 	trackRenamesCh chan fs.Object         // objects are pumped in here
 	renameCheck    []fs.Object            // accumulate files to check for rename here
 	backupDir      fs.Fs                  // place to store overwrites/deletes
-	suffix         string                 // suffix to add to files placed in backupDir
+	freeSpace      *freeSpace             // free space on fdst for --min-free-space, nil if not checking
+	ramp           *accounting.Ramp       // limits the concurrent transfers for --transfers-ramp, nil if not in use
+	dedupe         *uploadDedupe          // server side copies files already uploaded for --dedupe-on-upload, nil if not in use
 }
 
 func newSyncCopyMove(fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, deleteEmptySrcDirs bool) (*syncCopyMove, error) {
@@ -74,7 +95,12 @@ func newSyncCopyMove(fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, de
 		srcFilesResult:     make(chan error, 1),
 		dstFilesResult:     make(chan error, 1),
 		dstEmptyDirs:       make(map[string]fs.DirEntry),
+		excluded:           make(map[string]fs.Object),
 		srcEmptyDirs:       make(map[string]fs.DirEntry),
+		srcMovedDirs:       make(map[string]fs.DirEntry),
+		srcMarkers:         make(map[string]fs.Object),
+		dstMarkers:         make(map[string]fs.Object),
+		dstPartials:        make(map[string]fs.Object),
 		toBeChecked:        make(fs.ObjectPairChan, fs.Config.Transfers),
 		toBeUploaded:       make(fs.ObjectPairChan, fs.Config.Transfers),
 		deleteFilesCh:      make(chan fs.Object, fs.Config.Checkers),
@@ -82,16 +108,41 @@ func newSyncCopyMove(fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, de
 		commonHash:         fsrc.Hashes().Overlap(fdst.Hashes()).GetOne(),
 		toBeRenamed:        make(fs.ObjectPairChan, fs.Config.Transfers),
 		trackRenamesCh:     make(chan fs.Object, fs.Config.Checkers),
+		modifyWindow:       fs.GetModifyWindow(fsrc, fdst),
+	}
+	if err := operations.CheckInteractive(); err != nil {
+		return nil, err
 	}
 	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if fs.Config.CreateEmptySrcDirs && fs.Config.EmptyDirMarker != "" {
+		// Placeholders are only used on remotes which can't have
+		// empty directories
+		s.dirMarker = fs.Config.EmptyDirMarker
+		s.srcDirMarkers = !fsrc.Features().CanHaveEmptyDirectories
+		s.dstDirMarkers = !fdst.Features().CanHaveEmptyDirectories
+	}
 	if s.trackRenames {
+		var err error
+		s.renameStrategy, err = parseTrackRenamesStrategy(fs.Config.TrackRenamesStrategy)
+		if err != nil {
+			return nil, fserrors.FatalError(err)
+		}
 		// Don't track renames for remotes without server-side move support.
 		if !operations.CanServerSideMove(fdst) {
 			fs.Errorf(fdst, "Ignoring --track-renames as the destination does not support server-side move or copy")
 			s.trackRenames = false
 		}
-		if s.commonHash == hash.None {
-			fs.Errorf(fdst, "Ignoring --track-renames as the source and destination do not have a common hash")
+		if s.renameStrategy.hash() && s.commonHash == hash.None {
+			if s.renameStrategy == trackRenamesStrategyHash {
+				fs.Errorf(fdst, "Ignoring --track-renames as the source and destination do not have a common hash")
+				s.trackRenames = false
+			} else {
+				fs.Logf(fdst, "Not matching renames by hash as the source and destination do not have a common hash")
+				s.renameStrategy &^= trackRenamesStrategyHash
+			}
+		}
+		if s.renameStrategy.modTime() && s.modifyWindow == fs.ModTimeNotSupported {
+			fs.Errorf(fdst, "Ignoring --track-renames as either the source or destination do not support modtime")
 			s.trackRenames = false
 		}
 		if s.deleteMode == fs.DeleteModeOff {
@@ -104,6 +155,10 @@ func newSyncCopyMove(fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, de
 		if s.deleteMode != fs.DeleteModeOff {
 			s.deleteMode = fs.DeleteModeAfter
 		}
+		fs.Infof(fdst, "Tracking renames from %v using strategy %q", fsrc, s.renameStrategy)
+		if s.renameStrategy.leaf() && !s.renameStrategy.hash() {
+			fs.Logf(fdst, "Matching renames by leaf name and size without a hash may match files with different content")
+		}
 	}
 	// Make Fs for --backup-dir if required
 	if fs.Config.BackupDir != "" {
@@ -124,7 +179,28 @@ func newSyncCopyMove(fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, de
 		if operations.Overlapping(fsrc, s.backupDir) {
 			return nil, fserrors.FatalError(errors.New("source and parameter to --backup-dir mustn't overlap"))
 		}
-		s.suffix = fs.Config.Suffix
+	}
+	// Collect the deletes before doing any if there is a limit on them
+	if s.deleteMode == fs.DeleteModeOnly && (fs.Config.MaxDelete != -1 || fs.Config.MaxDeleteSize != -1) {
+		s.planDeletes = true
+	}
+	// Check the free space on the destination if required
+	if s.deleteMode != fs.DeleteModeOnly {
+		var err error
+		s.freeSpace, err = newFreeSpace(fdst)
+		if err != nil {
+			return nil, err
+		}
+		s.dedupe = newUploadDedupe(fdst, fsrc)
+	}
+	var err error
+	s.orderBy, err = parseOrderBy(fs.Config.OrderBy)
+	if err != nil {
+		return nil, fserrors.FatalError(err)
+	}
+	s.toBeCopied = s.toBeUploaded
+	if s.orderBy != nil {
+		s.toBeCopied = make(fs.ObjectPairChan, fs.Config.Transfers)
 	}
 	return s, nil
 }
@@ -210,6 +286,28 @@ func (s *syncCopyMove) pairChecker(in fs.ObjectPairChan, out fs.ObjectPairChan,
 			}
 			src := pair.Src
 			accounting.Stats.Checking(src.Remote())
+			// Rename the destination if its name only differs
+			// from the source in case
+			if fs.Config.FixCase && pair.Dst != nil {
+				newDst, err := operations.FixCase(s.fdst, pair.Dst, src.Remote())
+				if err != nil {
+					fs.Errorf(pair.Dst, "%v", err)
+					s.processError(err)
+					accounting.Stats.DoneChecking(src.Remote())
+					continue
+				}
+				pair.Dst = newDst
+			}
+			// Compare the destination with the metadata which
+			// will be uploaded if there is a --metadata-mapper
+			mapped, err := operations.MapMetadata(pair.Src)
+			if err != nil {
+				fs.Errorf(src, "Failed to map metadata: %v", err)
+				s.processError(err)
+				accounting.Stats.DoneChecking(src.Remote())
+				continue
+			}
+			pair.Src = mapped
 			// Check to see if can store this
 			if src.Storable() {
 				if operations.NeedTransfer(pair.Dst, pair.Src) {
@@ -217,10 +315,12 @@ func (s *syncCopyMove) pairChecker(in fs.ObjectPairChan, out fs.ObjectPairChan,
 					if fs.Config.Immutable && pair.Dst != nil {
 						fs.Errorf(pair.Dst, "Source and destination exist but do not match: immutable file modified")
 						s.processError(fs.ErrorImmutableModified)
+					} else if !s.confirmTransfer(pair) {
+						// Not transferring as --interactive said not to
 					} else {
 						// If destination already exists, then we must move it into --backup-dir if required
 						if pair.Dst != nil && s.backupDir != nil {
-							remoteWithSuffix := pair.Dst.Remote() + s.suffix
+							remoteWithSuffix := operations.SuffixName(pair.Dst.Remote())
 							overwritten, _ := s.backupDir.NewObject(remoteWithSuffix)
 							_, err := operations.Move(s.backupDir, overwritten, remoteWithSuffix, pair.Dst)
 							if err != nil {
@@ -228,17 +328,13 @@ func (s *syncCopyMove) pairChecker(in fs.ObjectPairChan, out fs.ObjectPairChan,
 							} else {
 								// If successful zero out the dst as it is no longer there and copy the file
 								pair.Dst = nil
-								select {
-								case <-s.ctx.Done():
+								if !s.toBeTransferred(out, pair) {
 									return
-								case out <- pair:
 								}
 							}
 						} else {
-							select {
-							case <-s.ctx.Done():
+							if !s.toBeTransferred(out, pair) {
 								return
-							case out <- pair:
 							}
 						}
 					}
@@ -257,6 +353,40 @@ func (s *syncCopyMove) pairChecker(in fs.ObjectPairChan, out fs.ObjectPairChan,
 	}
 }
 
+// confirmTransfer asks the user whether pair should be transferred
+// if --interactive is set, returning false if it shouldn't be
+func (s *syncCopyMove) confirmTransfer(pair fs.ObjectPair) bool {
+	action := "copy"
+	switch {
+	case pair.Dst != nil:
+		action = "overwrite"
+	case s.DoMove:
+		action = "move"
+	}
+	skip, err := operations.SkipDestructive(pair.Src, action)
+	s.processError(err)
+	return !skip
+}
+
+// toBeTransferred sends pair on out to be transferred, adding its size
+// to the queued bytes in the stats.  It returns false if the sync was
+// cancelled.
+func (s *syncCopyMove) toBeTransferred(out fs.ObjectPairChan, pair fs.ObjectPair) bool {
+	size := pair.Src.Size()
+	if size > 0 {
+		accounting.Stats.Queued(size)
+	}
+	select {
+	case <-s.ctx.Done():
+		if size > 0 {
+			accounting.Stats.Queued(-size)
+		}
+		return false
+	case out <- pair:
+	}
+	return true
+}
+
 // pairRenamer reads Objects~s on in and attempts to rename them,
 // otherwise it sends them out if they need transferring.
 func (s *syncCopyMove) pairRenamer(in fs.ObjectPairChan, out fs.ObjectPairChan, wg *sync.WaitGroup) {
@@ -271,12 +401,10 @@ func (s *syncCopyMove) pairRenamer(in fs.ObjectPairChan, out fs.ObjectPairChan,
 				return
 			}
 			src := pair.Src
-			if !s.tryRename(src) {
+			if !s.tryRename(src) && s.confirmTransfer(pair) {
 				// pass on if not renamed
-				select {
-				case <-s.ctx.Done():
+				if !s.toBeTransferred(out, pair) {
 					return
-				case out <- pair:
 				}
 			}
 		case <-s.ctx.Done():
@@ -299,11 +427,52 @@ func (s *syncCopyMove) pairCopyOrMove(in fs.ObjectPairChan, fdst fs.Fs, wg *sync
 				return
 			}
 			src := pair.Src
+			if size := src.Size(); size > 0 {
+				accounting.Stats.Queued(-size)
+			}
+			if accounting.Stats.MaxTransferReached() {
+				// Don't start any new transfers once the limit is reached
+				s.processError(accounting.ErrorMaxTransferLimitReached)
+				return
+			}
+			if s.freeSpace != nil {
+				err = s.freeSpace.wait(s.ctx, src.Remote(), src.Size())
+				if err != nil {
+					s.processError(err)
+					return
+				}
+			}
+			if s.ramp != nil {
+				err = s.ramp.Wait(s.ctx)
+				if err != nil {
+					return
+				}
+			}
 			accounting.Stats.Transferring(src.Remote())
-			if s.DoMove {
-				_, err = operations.Move(fdst, pair.Dst, src.Remote(), src)
-			} else {
-				_, err = operations.Copy(fdst, pair.Dst, src.Remote(), src)
+			var newDst fs.Object
+			var srcHash string
+			if s.dedupe != nil && pair.Dst == nil {
+				// Only new files are deduped as a server side
+				// copy can't replace an existing file
+				srcHash = s.dedupe.hash(src)
+				newDst = s.dedupe.copy(src.Remote(), src, srcHash)
+			}
+			switch {
+			case newDst != nil:
+				err = nil
+				if s.DoMove {
+					err = operations.DeleteFile(src)
+				}
+			case s.DoMove:
+				newDst, err = operations.Move(fdst, pair.Dst, src.Remote(), src)
+			default:
+				newDst, err = operations.Copy(fdst, pair.Dst, src.Remote(), src)
+			}
+			if s.dedupe != nil && err == nil {
+				s.dedupe.add(srcHash, newDst)
+			}
+			if s.ramp != nil {
+				s.ramp.Done()
 			}
 			s.processError(err)
 			accounting.Stats.DoneTransferring(src.Remote(), err == nil)
@@ -328,11 +497,34 @@ func (s *syncCopyMove) stopCheckers() {
 	s.checkerWg.Wait()
 }
 
+// usesSpace returns whether transferring pair takes up space on
+// fdst, which a server side move doesn't
+func (s *syncCopyMove) usesSpace(pair fs.ObjectPair) bool {
+	return !s.DoMove || s.fdst.Features().Move == nil || !operations.SameConfig(pair.Src.Fs(), s.fdst)
+}
+
 // This starts the background transfers
 func (s *syncCopyMove) startTransfers() {
+	s.ramp = accounting.NewRamp(fs.Config.Transfers)
+	toBeOrdered := s.toBeUploaded
+	// With --cutoff-mode hard check all the files will fit before
+	// starting any of them
+	if s.freeSpace != nil && fs.Config.CutoffMode == fs.CutoffModeHard {
+		planned := make(fs.ObjectPairChan, fs.Config.Transfers)
+		go func() {
+			s.processError(s.freeSpace.planTransfers(s.ctx, s.toBeUploaded, planned, s.usesSpace))
+		}()
+		toBeOrdered = planned
+		if s.orderBy == nil {
+			s.toBeCopied = planned
+		}
+	}
+	if s.orderBy != nil {
+		go orderTransfers(s.ctx, s.orderBy, fs.Config.OrderByLookahead, toBeOrdered, s.toBeCopied)
+	}
 	s.transfersWg.Add(fs.Config.Transfers)
 	for i := 0; i < fs.Config.Transfers; i++ {
-		go s.pairCopyOrMove(s.toBeUploaded, s.fdst, &s.transfersWg)
+		go s.pairCopyOrMove(s.toBeCopied, s.fdst, &s.transfersWg)
 	}
 }
 
@@ -341,6 +533,9 @@ func (s *syncCopyMove) stopTransfers() {
 	close(s.toBeUploaded)
 	fs.Infof(s.fdst, "Waiting for transfers to finish")
 	s.transfersWg.Wait()
+	if s.ramp != nil {
+		s.ramp.Stop()
+	}
 }
 
 // This starts the background renamers.
@@ -389,7 +584,7 @@ func (s *syncCopyMove) stopTrackRenames() {
 
 // This starts the background deletion of files for --delete-during
 func (s *syncCopyMove) startDeleters() {
-	if s.deleteMode != fs.DeleteModeDuring && s.deleteMode != fs.DeleteModeOnly {
+	if s.deleteMode != fs.DeleteModeDuring && s.deleteMode != fs.DeleteModeOnly || s.planDeletes {
 		return
 	}
 	s.deletersWg.Add(1)
@@ -402,7 +597,7 @@ func (s *syncCopyMove) startDeleters() {
 
 // This stops the background deleters
 func (s *syncCopyMove) stopDeleters() {
-	if s.deleteMode != fs.DeleteModeDuring && s.deleteMode != fs.DeleteModeOnly {
+	if s.deleteMode != fs.DeleteModeDuring && s.deleteMode != fs.DeleteModeOnly || s.planDeletes {
 		return
 	}
 	close(s.deleteFilesCh)
@@ -414,23 +609,36 @@ func (s *syncCopyMove) stopDeleters() {
 // file map, otherwise it unconditionally deletes them.  If
 // checkSrcMap is clear then it assumes that the any source files that
 // have been found have been removed from dstFiles already.
+//
+// All the files are checked against --max-delete and
+// --max-delete-size before any are deleted.
 func (s *syncCopyMove) deleteFiles(checkSrcMap bool) error {
 	if accounting.Stats.Errored() && !fs.Config.IgnoreErrors {
 		fs.Errorf(s.fdst, "%v", fs.ErrorNotDeleting)
 		return fs.ErrorNotDeleting
 	}
 
+	// Find the spare files
+	var spare []fs.Object
+	for remote, o := range s.dstFiles {
+		if checkSrcMap {
+			_, exists := s.srcFiles[remote]
+			if exists {
+				continue
+			}
+		}
+		spare = append(spare, o)
+	}
+	err := operations.CheckDeleteLimits(spare)
+	if err != nil {
+		return err
+	}
+
 	// Delete the spare files
 	toDelete := make(fs.ObjectsChan, fs.Config.Transfers)
 	go func() {
 	outer:
-		for remote, o := range s.dstFiles {
-			if checkSrcMap {
-				_, exists := s.srcFiles[remote]
-				if exists {
-					continue
-				}
-			}
+		for _, o := range spare {
 			if s.aborting() {
 				break
 			}
@@ -445,6 +653,74 @@ func (s *syncCopyMove) deleteFiles(checkSrcMap bool) error {
 	return operations.DeleteFilesWithBackupDir(toDelete, s.backupDir)
 }
 
+// confirmDeleteExcluded returns whether the excluded files should be
+// deleted.
+//
+// If running interactively it asks the user unless --auto-confirm is
+// set.  Otherwise it deletes them unless
+// --delete-excluded-dry-run-first is set, in which case it needs
+// --auto-confirm to delete them.
+func confirmDeleteExcluded() bool {
+	if fs.Config.AutoConfirm {
+		return true
+	}
+	if !config.StdinIsTerminal() {
+		if fs.Config.DeleteExcludedDryRun {
+			fs.Logf(nil, "Not deleting excluded files without --auto-confirm as not running interactively")
+			return false
+		}
+		return true
+	}
+	fmt.Printf("Delete the files excluded from the sync?\n")
+	return config.ConfirmWithDefault(false)
+}
+
+// This deletes the files in the destination excluded by the filters
+// with --delete-excluded.  These are collected during the sync rather
+// than being deleted straight away so they can be counted, listed
+// with --delete-excluded-dry-run-first, and confirmed before any are
+// deleted.
+func (s *syncCopyMove) deleteExcluded() error {
+	if len(s.excluded) == 0 {
+		return nil
+	}
+	var remotes []string
+	var size int64
+	for remote, o := range s.excluded {
+		remotes = append(remotes, remote)
+		if o.Size() > 0 {
+			size += o.Size()
+		}
+	}
+	sort.Strings(remotes)
+	if fs.Config.DeleteExcludedDryRun {
+		for _, remote := range remotes {
+			fs.Logf(s.excluded[remote], "Would delete as excluded from the sync")
+		}
+	}
+	fs.Logf(s.fdst, "--delete-excluded: %d excluded files (%v) to delete", len(remotes), fs.SizeSuffix(size))
+	if !fs.Config.DryRun && !confirmDeleteExcluded() {
+		fs.Logf(s.fdst, "Not deleting %d excluded files", len(remotes))
+		return nil
+	}
+	toDelete := make(fs.ObjectsChan, fs.Config.Transfers)
+	go func() {
+	outer:
+		for _, remote := range remotes {
+			if s.aborting() {
+				break
+			}
+			select {
+			case <-s.ctx.Done():
+				break outer
+			case toDelete <- s.excluded[remote]:
+			}
+		}
+		close(toDelete)
+	}()
+	return operations.DeleteFilesWithBackupDir(toDelete, s.backupDir)
+}
+
 // This deletes the empty directories in the slice passed in.  It
 // ignores any errors deleting directories
 func deleteEmptyDirectories(f fs.Fs, entriesMap map[string]fs.DirEntry) error {
@@ -491,16 +767,25 @@ func deleteEmptyDirectories(f fs.Fs, entriesMap map[string]fs.DirEntry) error {
 
 // This copies the empty directories in the slice passed in and logs
 // any errors copying the directories
-func copyEmptyDirectories(f fs.Fs, entries map[string]fs.DirEntry) error {
+//
+// If marker is set and f can't have empty directories then a
+// placeholder called marker is made in each instead.
+func copyEmptyDirectories(f fs.Fs, entries map[string]fs.DirEntry, marker string) error {
 	if len(entries) == 0 {
 		return nil
 	}
+	useMarkers := marker != "" && !f.Features().CanHaveEmptyDirectories
 
 	var okCount int
 	for _, entry := range entries {
 		dir, ok := entry.(fs.Directory)
 		if ok {
-			err := f.Mkdir(dir.Remote())
+			var err error
+			if useMarkers {
+				err = makeDirMarker(f, dir, marker)
+			} else {
+				err = f.Mkdir(dir.Remote())
+			}
 			if err != nil {
 				fs.Errorf(fs.LogDirName(f, dir.Remote()), "Failed to Mkdir: %v", err)
 				accounting.Stats.Error(err)
@@ -522,30 +807,203 @@ func copyEmptyDirectories(f fs.Fs, entries map[string]fs.DirEntry) error {
 	return nil
 }
 
+// makeDirMarker makes an empty object called marker in dir to stand
+// in for the directory on a remote which can't have empty directories
+func makeDirMarker(f fs.Fs, dir fs.Directory, marker string) error {
+	remote := path.Join(dir.Remote(), marker)
+	if _, err := f.NewObject(remote); err == nil {
+		// already made
+		return nil
+	}
+	if fs.Config.DryRun {
+		fs.Logf(fs.LogDirName(f, dir.Remote()), "Not making directory placeholder as dry run is set")
+		return nil
+	}
+	fs.Debugf(fs.LogDirName(f, dir.Remote()), "Making directory placeholder %q", marker)
+	src := object.NewStaticObjectInfo(remote, dir.ModTime(), 0, true, nil, f)
+	_, err := f.Put(bytes.NewReader(nil), src)
+	return err
+}
+
+// isDirMarker returns true if o is a placeholder for an empty directory
+func (s *syncCopyMove) isDirMarker(o fs.Object, markers bool) bool {
+	return markers && path.Base(o.Remote()) == s.dirMarker
+}
+
+// This deletes the placeholders in fdst for directories which aren't
+// empty in fsrc any more
+func (s *syncCopyMove) deleteDstDirMarkers() error {
+	toDelete := make(fs.ObjectsChan, len(s.dstMarkers))
+	for remote, o := range s.dstMarkers {
+		if _, empty := s.srcEmptyDirs[parentDir(remote)]; !empty {
+			toDelete <- o
+		}
+	}
+	close(toDelete)
+	return operations.DeleteFilesWithBackupDir(toDelete, s.backupDir)
+}
+
+// partialIsStale returns whether the partial download partialRemote
+// in fdst can't be resumed by a later sync, either because the file
+// it is for isn't in fsrc any more or because that has been copied
+// already.
+func (s *syncCopyMove) partialIsStale(partialRemote string) bool {
+	remote := strings.TrimSuffix(partialRemote, fs.Config.PartialSuffix)
+	src, err := s.fsrc.NewObject(remote)
+	if err != nil {
+		return true
+	}
+	dst, err := s.fdst.NewObject(remote)
+	if err != nil {
+		return false
+	}
+	src, err = operations.MapMetadata(src)
+	if err != nil {
+		return false
+	}
+	return !operations.NeedTransfer(dst, src)
+}
+
+// This deletes the partial downloads in fdst which can't be resumed.
+// The ones for files which failed to copy are kept for next time.
+func (s *syncCopyMove) deleteStalePartials() error {
+	toDelete := make(fs.ObjectsChan, len(s.dstPartials))
+	for remote, o := range s.dstPartials {
+		if _, err := s.fdst.NewObject(remote); err != nil {
+			// renamed by the copy of its file during this sync
+			continue
+		}
+		if s.partialIsStale(remote) {
+			toDelete <- o
+		} else {
+			fs.Debugf(o, "Keeping partial download so it can be resumed")
+		}
+	}
+	close(toDelete)
+	return operations.DeleteFiles(toDelete)
+}
+
+// This deletes the placeholders in fsrc for --delete-empty-src-dirs so
+// the directories they were in can be removed
+func (s *syncCopyMove) deleteSrcDirMarkers() error {
+	if accounting.Stats.Errored() && !fs.Config.IgnoreErrors {
+		return nil
+	}
+	toDelete := make(fs.ObjectsChan, len(s.srcMarkers))
+	for _, o := range s.srcMarkers {
+		toDelete <- o
+	}
+	close(toDelete)
+	return operations.DeleteFiles(toDelete)
+}
+
+// parentDir returns the directory remote is in, "" for the root
+func parentDir(remote string) string {
+	dir := path.Dir(remote)
+	if dir == "." {
+		dir = ""
+	}
+	return dir
+}
+
 func parentDirCheck(entries map[string]fs.DirEntry, entry fs.DirEntry) {
-	parentDir := path.Dir(entry.Remote())
-	if parentDir == "." {
-		parentDir = ""
+	dir := parentDir(entry.Remote())
+	if _, ok := entries[dir]; ok {
+		delete(entries, dir)
+	}
+}
+
+// trackRenamesStrategy is a bitmask of the ways renames can be matched
+type trackRenamesStrategy byte
+
+// Strategies for --track-renames-strategy - size is always matched
+const (
+	trackRenamesStrategyHash trackRenamesStrategy = 1 << iota
+	trackRenamesStrategyModtime
+	trackRenamesStrategyLeaf
+)
+
+func (strategy trackRenamesStrategy) hash() bool {
+	return (strategy & trackRenamesStrategyHash) != 0
+}
+
+func (strategy trackRenamesStrategy) modTime() bool {
+	return (strategy & trackRenamesStrategyModtime) != 0
+}
+
+func (strategy trackRenamesStrategy) leaf() bool {
+	return (strategy & trackRenamesStrategyLeaf) != 0
+}
+
+// String turns the strategy back into the form used on the command line
+func (strategy trackRenamesStrategy) String() string {
+	var out []string
+	if strategy.hash() {
+		out = append(out, "hash")
+	}
+	if strategy.modTime() {
+		out = append(out, "modtime")
+	}
+	if strategy.leaf() {
+		out = append(out, "leaf")
+	}
+	out = append(out, "size")
+	return strings.Join(out, ",")
+}
+
+// parseTrackRenamesStrategy turns a config string into a trackRenamesStrategy
+func parseTrackRenamesStrategy(strategies string) (strategy trackRenamesStrategy, err error) {
+	if len(strategies) == 0 {
+		return strategy, nil
 	}
-	if _, ok := entries[parentDir]; ok {
-		delete(entries, parentDir)
+	for _, s := range strings.Split(strategies, ",") {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "hash":
+			strategy |= trackRenamesStrategyHash
+		case "modtime":
+			strategy |= trackRenamesStrategyModtime
+		case "leaf":
+			strategy |= trackRenamesStrategyLeaf
+		case "size":
+			// ignore
+		default:
+			return strategy, errors.Errorf("unknown track renames strategy %q", s)
+		}
 	}
+	return strategy, nil
 }
 
-// renameHash makes a string with the size and the hash for rename detection
+// renameID makes a string with the size and the other identifiers of
+// the requested rename strategies for rename detection
 //
-// it may return an empty string in which case no hash could be made
-func (s *syncCopyMove) renameHash(obj fs.Object) (hash string) {
-	var err error
-	hash, err = obj.Hash(s.commonHash)
-	if err != nil {
-		fs.Debugf(obj, "Hash failed: %v", err)
-		return ""
+// it may return an empty string in which case no ID could be made
+func (s *syncCopyMove) renameID(obj fs.Object, renamesStrategy trackRenamesStrategy) string {
+	var builder bytes.Buffer
+
+	fmt.Fprintf(&builder, "%d", obj.Size())
+
+	if renamesStrategy.modTime() {
+		modTime := obj.ModTime()
+		fmt.Fprintf(&builder, ",%d", modTime.Truncate(s.modifyWindow).UnixNano())
 	}
-	if hash == "" {
-		return ""
+
+	if renamesStrategy.leaf() {
+		fmt.Fprintf(&builder, ",%s", path.Base(obj.Remote()))
 	}
-	return fmt.Sprintf("%d,%s", obj.Size(), hash)
+
+	if renamesStrategy.hash() {
+		objHash, err := obj.Hash(s.commonHash)
+		if err != nil {
+			fs.Debugf(obj, "Hash failed: %v", err)
+			return ""
+		}
+		if objHash == "" {
+			return ""
+		}
+		fmt.Fprintf(&builder, ",%s", objHash)
+	}
+
+	return builder.String()
 }
 
 // pushRenameMap adds the object with hash to the rename map
@@ -572,8 +1030,8 @@ func (s *syncCopyMove) popRenameMap(hash string) (dst fs.Object) {
 	return dst
 }
 
-// makeRenameMap builds a map of the destination files by hash that
-// match sizes in the slice of objects in s.renameCheck
+// makeRenameMap builds a map of the destination files by rename ID
+// that match sizes in the slice of objects in s.renameCheck
 func (s *syncCopyMove) makeRenameMap() {
 	fs.Infof(s.fdst, "Making map for --track-renames")
 
@@ -587,7 +1045,7 @@ func (s *syncCopyMove) makeRenameMap() {
 	in := make(chan fs.Object, fs.Config.Checkers)
 	go s.pumpMapToChan(s.dstFiles, in)
 
-	// now make a map of size,hash for all dstFiles
+	// now make a map of rename IDs for all dstFiles
 	s.renameMap = make(map[string][]fs.Object)
 	var wg sync.WaitGroup
 	wg.Add(fs.Config.Transfers)
@@ -598,9 +1056,9 @@ func (s *syncCopyMove) makeRenameMap() {
 				// only create hash for dst fs.Object if its size could match
 				if _, found := possibleSizes[obj.Size()]; found {
 					accounting.Stats.Checking(obj.Remote())
-					hash := s.renameHash(obj)
-					if hash != "" {
-						s.pushRenameMap(hash, obj)
+					renameID := s.renameID(obj, s.renameStrategy)
+					if renameID != "" {
+						s.pushRenameMap(renameID, obj)
 					}
 					accounting.Stats.DoneChecking(obj.Remote())
 				}
@@ -617,18 +1075,24 @@ func (s *syncCopyMove) tryRename(src fs.Object) bool {
 	accounting.Stats.Checking(src.Remote())
 	defer accounting.Stats.DoneChecking(src.Remote())
 
-	// Calculate the hash of the src object
-	hash := s.renameHash(src)
-	if hash == "" {
+	// Calculate the rename ID of the src object
+	renameID := s.renameID(src, s.renameStrategy)
+	if renameID == "" {
 		return false
 	}
 
 	// Get a match on fdst
-	dst := s.popRenameMap(hash)
+	dst := s.popRenameMap(renameID)
 	if dst == nil {
 		return false
 	}
 
+	// Check the rename is OK with --interactive
+	if skip, err := operations.SkipDestructive(dst.Remote()+" to "+src.Remote(), "rename"); skip {
+		s.processError(err)
+		return false
+	}
+
 	// Find dst object we are about to overwrite if it exists
 	dstOverwritten, _ := s.fdst.NewObject(src.Remote())
 
@@ -694,10 +1158,33 @@ func (s *syncCopyMove) run() error {
 	s.stopTransfers()
 	s.stopDeleters()
 
-	s.processError(copyEmptyDirectories(s.fdst, s.srcEmptyDirs))
+	s.processError(copyEmptyDirectories(s.fdst, s.srcEmptyDirs, s.dirMarker))
 
-	// Delete files after
-	if s.deleteMode == fs.DeleteModeAfter {
+	// Delete the placeholders for directories which aren't empty any more
+	if len(s.dstMarkers) > 0 {
+		if s.currentError() != nil && !fs.Config.IgnoreErrors {
+			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeleting)
+		} else {
+			s.processError(s.deleteDstDirMarkers())
+		}
+	}
+
+	// Delete partial downloads which can't be resumed
+	if len(s.dstPartials) > 0 {
+		s.processError(s.deleteStalePartials())
+	}
+
+	// Delete files excluded from the sync
+	if len(s.excluded) > 0 {
+		if s.currentError() != nil && !fs.Config.IgnoreErrors {
+			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeleting)
+		} else {
+			s.processError(s.deleteExcluded())
+		}
+	}
+
+	// Delete files after, or before if they were collected
+	if s.deleteMode == fs.DeleteModeAfter || s.planDeletes {
 		if s.currentError() != nil && !fs.Config.IgnoreErrors {
 			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeleting)
 		} else {
@@ -717,8 +1204,10 @@ func (s *syncCopyMove) run() error {
 	// Delete empty fsrc subdirectories
 	// if DoMove and --delete-empty-src-dirs flag is set
 	if s.DoMove && s.deleteEmptySrcDirs {
-		//delete empty subdirectories that were part of the move
-		s.processError(deleteEmptyDirectories(s.fsrc, s.srcEmptyDirs))
+		// delete the subdirectories that were part of the move
+		// which are now empty - this doesn't include the root
+		s.processError(s.deleteSrcDirMarkers())
+		s.processError(deleteEmptyDirectories(s.fsrc, s.srcMovedDirs))
 	}
 
 	// cancel the context to free resources
@@ -733,13 +1222,36 @@ func (s *syncCopyMove) DstOnly(dst fs.DirEntry) (recurse bool) {
 	}
 	switch x := dst.(type) {
 	case fs.Object:
-		switch s.deleteMode {
-		case fs.DeleteModeAfter:
+		if s.isDirMarker(x, s.dstDirMarkers) {
+			// record the placeholder to delete if its directory
+			// isn't empty in the source
+			s.dstFilesMu.Lock()
+			s.dstMarkers[x.Remote()] = x
+			s.dstFilesMu.Unlock()
+			return false
+		}
+		if operations.IsPartialName(x.Remote()) {
+			// record partial downloads to delete once the
+			// transfers are done if they can't be resumed
+			s.dstFilesMu.Lock()
+			s.dstPartials[x.Remote()] = x
+			s.dstFilesMu.Unlock()
+			return false
+		}
+		if filter.Active.Opt.DeleteExcluded && !filter.Active.IncludeObject(x) {
+			// record excluded object to be confirmed before deleting
+			s.excludedMu.Lock()
+			s.excluded[x.Remote()] = x
+			s.excludedMu.Unlock()
+			return false
+		}
+		switch {
+		case s.deleteMode == fs.DeleteModeAfter || s.planDeletes:
 			// record object as needs deleting
 			s.dstFilesMu.Lock()
 			s.dstFiles[x.Remote()] = x
 			s.dstFilesMu.Unlock()
-		case fs.DeleteModeDuring, fs.DeleteModeOnly:
+		case s.deleteMode == fs.DeleteModeDuring || s.deleteMode == fs.DeleteModeOnly:
 			select {
 			case <-s.ctx.Done():
 				return
@@ -771,6 +1283,14 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 	}
 	switch x := src.(type) {
 	case fs.Object:
+		if s.isDirMarker(x, s.srcDirMarkers) {
+			// Don't copy the placeholder - the directory is
+			// created if it is empty
+			s.srcEmptyDirsMu.Lock()
+			s.srcMarkers[x.Remote()] = x
+			s.srcEmptyDirsMu.Unlock()
+			return false
+		}
 		// Remove parent directory from srcEmptyDirs
 		// since it's not really empty
 		s.srcEmptyDirsMu.Lock()
@@ -784,12 +1304,10 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 				return
 			case s.trackRenamesCh <- x:
 			}
-		} else {
+		} else if pair := (fs.ObjectPair{Src: x, Dst: nil}); s.confirmTransfer(pair) {
 			// No need to check since doesn't exist
-			select {
-			case <-s.ctx.Done():
+			if !s.toBeTransferred(s.toBeUploaded, pair) {
 				return
-			case s.toBeUploaded <- fs.ObjectPair{Src: x, Dst: nil}:
 			}
 		}
 	case fs.Directory:
@@ -798,6 +1316,7 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 		s.srcEmptyDirsMu.Lock()
 		parentDirCheck(s.srcEmptyDirs, src)
 		s.srcEmptyDirs[src.Remote()] = src
+		s.srcMovedDirs[src.Remote()] = src
 		s.srcEmptyDirsMu.Unlock()
 		return true
 	default:
@@ -810,6 +1329,12 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 func (s *syncCopyMove) Match(dst, src fs.DirEntry) (recurse bool) {
 	switch srcX := src.(type) {
 	case fs.Object:
+		if s.isDirMarker(srcX, s.srcDirMarkers) {
+			s.srcEmptyDirsMu.Lock()
+			s.srcMarkers[srcX.Remote()] = srcX
+			s.srcEmptyDirsMu.Unlock()
+			return false
+		}
 		s.srcEmptyDirsMu.Lock()
 		parentDirCheck(s.srcEmptyDirs, src)
 		s.srcEmptyDirsMu.Unlock()
@@ -838,6 +1363,7 @@ func (s *syncCopyMove) Match(dst, src fs.DirEntry) (recurse bool) {
 			s.srcEmptyDirsMu.Lock()
 			parentDirCheck(s.srcEmptyDirs, src)
 			s.srcEmptyDirs[src.Remote()] = src
+			s.srcMovedDirs[src.Remote()] = src
 			s.srcEmptyDirsMu.Unlock()
 			return true
 		}
@@ -3,17 +3,26 @@
 package sync
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
 	_ "github.com/ncw/rclone/backend/all" // import all backends
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/config"
 	"github.com/ncw/rclone/fs/filter"
+	"github.com/ncw/rclone/fs/fserrors"
 	"github.com/ncw/rclone/fs/hash"
 	"github.com/ncw/rclone/fs/operations"
 	"github.com/ncw/rclone/fstest"
+	"github.com/ncw/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/unicode/norm"
@@ -28,6 +37,8 @@ var (
 
 // TestMain drives the tests
 func TestMain(m *testing.M) {
+	// Never ask for confirmation in the tests
+	config.StdinIsTerminal = func() bool { return false }
 	fstest.TestMain(m)
 }
 
@@ -105,6 +116,80 @@ func TestCopyEmptyDirectories(t *testing.T) {
 	)
 }
 
+// setNoEmptyDirectories makes f look like a remote which can't have
+// empty directories and sets --create-empty-src-dirs-marker, returning
+// a function to put things back
+func setNoEmptyDirectories(f fs.Fs) func() {
+	features := f.Features()
+	old := features.CanHaveEmptyDirectories
+	features.CanHaveEmptyDirectories = false
+	fs.Config.CreateEmptySrcDirs = true
+	fs.Config.EmptyDirMarker = ".keep"
+	return func() {
+		features.CanHaveEmptyDirectories = old
+		fs.Config.CreateEmptySrcDirs = false
+		fs.Config.EmptyDirMarker = ""
+	}
+}
+
+// Test empty directories get a placeholder on a remote which can't
+// have them and that it is deleted once the directory has files in
+func TestSyncEmptyDirectoriesMarker(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteFile("sub dir/hello world", "hello world", t1)
+	err := operations.Mkdir(r.Flocal, "sub dir2")
+	require.NoError(t, err)
+	r.Mkdir(r.Fremote)
+	defer setNoEmptyDirectories(r.Fremote)()
+
+	err = Sync(r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	marker, err := r.Fremote.NewObject("sub dir2/.keep")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), marker.Size())
+
+	// Syncing again leaves the placeholder alone
+	err = Sync(r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	_, err = r.Fremote.NewObject("sub dir2/.keep")
+	require.NoError(t, err)
+
+	// The placeholder is deleted when the directory has a file in
+	file2 := r.WriteFile("sub dir2/potato", "potato", t2)
+	err = Sync(r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+}
+
+// Test --delete-empty-src-dirs deletes the placeholders in the source
+// and makes the directories they stood for on the destination
+func TestMoveDeleteEmptySrcDirsMarker(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteObject("sub dir/hello world", "hello world", t1)
+	r.WriteObject("empty/.keep", "", t1)
+	r.Mkdir(r.Flocal)
+	defer setNoEmptyDirectories(r.Fremote)()
+
+	err := MoveDir(r.Flocal, r.Fremote, true)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.Fremote)
+	fstest.CheckListingWithPrecision(
+		t,
+		r.Flocal,
+		[]fstest.Item{
+			file1,
+		},
+		[]string{
+			"empty",
+			"sub dir",
+		},
+		fs.GetModifyWindow(r.Flocal),
+	)
+}
+
 // Test a server side copy if possible, or the backup path if not
 func TestServerSideCopy(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -746,6 +831,135 @@ func TestCopyDeleteBefore(t *testing.T) {
 	fstest.CheckItems(t, r.Flocal, file2)
 }
 
+// Test that exceeding --max-delete or --max-delete-size with delete
+// before or after doesn't delete anything
+func TestSyncMaxDeleteLimits(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		deleteMode    fs.DeleteMode
+		maxDelete     int64
+		maxDeleteSize fs.SizeSuffix
+		wantErr       bool
+	}{
+		{"BeforeCount", fs.DeleteModeBefore, 1, -1, true},
+		{"AfterCount", fs.DeleteModeAfter, 1, -1, true},
+		{"BeforeSize", fs.DeleteModeBefore, -1, 10, true},
+		{"AfterSize", fs.DeleteModeAfter, -1, 10, true},
+		{"BeforeOK", fs.DeleteModeBefore, 2, 100, false},
+		{"AfterOK", fs.DeleteModeAfter, 2, 100, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := fstest.NewRun(t)
+			defer r.Finalise()
+
+			fs.Config.DeleteMode = test.deleteMode
+			fs.Config.MaxDelete = test.maxDelete
+			fs.Config.MaxDeleteSize = test.maxDeleteSize
+			defer func() {
+				fs.Config.DeleteMode = fs.DeleteModeDefault
+				fs.Config.MaxDelete = -1
+				fs.Config.MaxDeleteSize = -1
+			}()
+
+			file1 := r.WriteBoth("potato", "kept", t1)
+			file2 := r.WriteObject("spare1", "to be deleted", t1) // 13 bytes
+			file3 := r.WriteObject("spare2", "also to be deleted", t1)
+			fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+			fstest.CheckItems(t, r.Flocal, file1)
+
+			accounting.Stats.ResetCounters()
+			err := Sync(r.Fremote, r.Flocal)
+			if test.wantErr {
+				assert.Equal(t, operations.ErrorMaxDeleteLimitReached, err)
+				assert.Equal(t, int64(0), accounting.Stats.Deletes(0))
+				fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, int64(2), accounting.Stats.Deletes(0))
+				fstest.CheckItems(t, r.Fremote, file1)
+			}
+		})
+	}
+}
+
+// Test --interactive refuses to run without a terminal rather than
+// waiting for an answer
+func TestSyncInteractiveNoTerminal(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	fs.Config.Interactive = true
+	defer func() {
+		fs.Config.Interactive = false
+	}()
+	file1 := r.WriteFile("potato", "not copied", t1)
+	file2 := r.WriteObject("spare", "not deleted", t1)
+
+	accounting.Stats.ResetCounters()
+	err := Sync(r.Fremote, r.Flocal)
+	require.Error(t, err)
+	assert.True(t, fserrors.IsFatalError(err))
+	assert.Contains(t, err.Error(), "--interactive")
+
+	fstest.CheckItems(t, r.Flocal, file1)
+	fstest.CheckItems(t, r.Fremote, file2)
+}
+
+// Test a --metadata-mapper which changes the modification time
+// doesn't make the file be copied again on every sync
+func TestSyncMetadataMapperModTime(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script")
+	}
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	dir, err := ioutil.TempDir("", "rclone-metadata-mapper")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+	mapper := filepath.Join(dir, "mapper")
+	require.NoError(t, ioutil.WriteFile(mapper, []byte("#!/bin/sh\necho '{\"ModTime\":\""+t2.Format(time.RFC3339Nano)+"\"}'\n"), 0700))
+	fs.Config.MetadataMapper = mapper
+	defer func() { fs.Config.MetadataMapper = "" }()
+
+	file1 := r.WriteFile("file1", "mapped", t1)
+
+	accounting.Stats.ResetCounters()
+	require.NoError(t, Sync(r.Fremote, r.Flocal))
+	assert.Equal(t, int64(1), accounting.Stats.GetTransfers())
+	fstest.CheckItems(t, r.Flocal, file1)
+	file1.ModTime = t2
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// The second sync compares with the mapped modification time
+	accounting.Stats.ResetCounters()
+	require.NoError(t, Sync(r.Fremote, r.Flocal))
+	assert.Equal(t, int64(0), accounting.Stats.GetTransfers())
+	fstest.CheckItems(t, r.Fremote, file1)
+}
+
+// Test sync deletes the partial downloads which can't be resumed
+func TestSyncDeletesStalePartials(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteBoth("done", "already copied", t1)
+	file2 := r.WriteFile("big", "not copied as it is bigger than --max-size", t1)
+	r.WriteObject("done"+fs.Config.PartialSuffix, "already", t1)
+	r.WriteObject("gone"+fs.Config.PartialSuffix, "not in the source", t1)
+	partial := r.WriteObject("big"+fs.Config.PartialSuffix, "not", t1)
+
+	filter.Active.Opt.MaxSize = 20
+	defer func() {
+		filter.Active.Opt.MaxSize = -1
+	}()
+
+	accounting.Stats.ResetCounters()
+	err := Sync(r.Fremote, r.Flocal)
+	require.NoError(t, err)
+
+	// Only the partial for the file which still needs copying is kept
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+	fstest.CheckItems(t, r.Fremote, file1, partial)
+}
+
 // Test with exclude
 func TestSyncWithExclude(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -804,6 +1018,40 @@ func TestSyncWithExcludeAndDeleteExcluded(t *testing.T) {
 	fstest.CheckItems(t, r.Flocal, file2)
 }
 
+// Test with delete excluded needing confirmation
+func TestSyncWithDeleteExcludedDryRunFirst(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteBoth("potato2", "------------------------------------------------------------", t1) // 60 bytes
+	file2 := r.WriteBoth("empty space", "", t2)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	filter.Active.Opt.MaxSize = 40
+	filter.Active.Opt.DeleteExcluded = true
+	fs.Config.DeleteExcludedDryRun = true
+	defer func() {
+		filter.Active.Opt.MaxSize = -1
+		filter.Active.Opt.DeleteExcluded = false
+		fs.Config.DeleteExcludedDryRun = false
+		fs.Config.AutoConfirm = false
+	}()
+
+	// Not interactive so the excluded file isn't deleted
+	accounting.Stats.ResetCounters()
+	err := Sync(r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+	assert.Equal(t, int64(0), accounting.Stats.Deletes(0))
+
+	// Until it is confirmed
+	fs.Config.AutoConfirm = true
+	accounting.Stats.ResetCounters()
+	err = Sync(r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file2)
+	assert.Equal(t, int64(1), accounting.Stats.Deletes(0))
+}
+
 // Test with UpdateOlder set
 func TestSyncWithUpdateOlder(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -878,6 +1126,254 @@ func TestSyncWithTrackRenames(t *testing.T) {
 	}
 }
 
+// Test a sync with --fix-case renames destination files whose names
+// only differ from the source in case
+func TestSyncFixCase(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if r.Fremote.Features().Move == nil {
+		t.Skip("Can't fix case without Move")
+	}
+
+	fs.Config.FixCase = true
+	defer func() { fs.Config.FixCase = false }()
+
+	file1 := r.WriteFile("sub dir/File.TXT", "same contents", t1)
+	file2 := r.WriteFile("other.txt", "other contents", t1)
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+	r.WriteObject("sub dir/file.txt", "same contents", t1)
+	r.WriteObject("other.txt", "other contents", t1)
+
+	accounting.Stats.ResetCounters()
+	require.NoError(t, Sync(r.Fremote, r.Flocal))
+	assert.Equal(t, int64(0), accounting.Stats.GetTransfers())
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+}
+
+func TestUploadDedupe(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	// Not used unless asked for
+	assert.Nil(t, newUploadDedupe(r.Fremote, r.Flocal))
+
+	file1 := r.WriteFile("a.txt", "same contents", t1)
+	file2 := r.WriteFile("b.txt", "same contents", t2)
+	file3 := r.WriteFile("c.txt", "other contents", t1)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+
+	// Copy from the destination to itself for the server side copy
+	var copied []string
+	d := &uploadDedupe{
+		f: r.Fremote,
+		doCopy: func(src fs.Object, remote string) (fs.Object, error) {
+			copied = append(copied, src.Remote()+" to "+remote)
+			return operations.Copy(r.Fremote, nil, remote, src)
+		},
+		hashType: hash.MD5,
+		uploaded: make(map[string]fs.Object),
+	}
+	upload := func(remote string) {
+		src, err := r.Flocal.NewObject(remote)
+		require.NoError(t, err)
+		srcHash := d.hash(src)
+		assert.NotEqual(t, "", srcHash)
+		newDst := d.copy(remote, src, srcHash)
+		if newDst == nil {
+			newDst, err = operations.Copy(r.Fremote, nil, remote, src)
+			require.NoError(t, err)
+		}
+		d.add(srcHash, newDst)
+	}
+	upload("a.txt")
+	upload("b.txt")
+	upload("c.txt")
+	assert.Equal(t, []string{"a.txt to b.txt"}, copied)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+}
+
+// Test --dedupe-on-upload doesn't copy over files which exist on the
+// destination
+func TestSyncUploadDedupeExisting(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteFile("a.txt", "same contents", t1)
+	file2 := r.WriteFile("b.txt", "same contents", t1)
+	file3 := r.WriteFile("c.txt", "same contents", t1)
+	r.WriteObject("b.txt", "old contents", t2)
+
+	s, err := newSyncCopyMove(r.Fremote, r.Flocal, fs.DeleteModeDefault, false, false)
+	require.NoError(t, err)
+	var mu sync.Mutex
+	var copied []string
+	s.dedupe = &uploadDedupe{
+		f: r.Fremote,
+		doCopy: func(src fs.Object, remote string) (fs.Object, error) {
+			mu.Lock()
+			copied = append(copied, remote)
+			mu.Unlock()
+			return operations.Copy(r.Fremote, nil, remote, src)
+		},
+		hashType: hash.MD5,
+		uploaded: make(map[string]fs.Object),
+	}
+	require.NoError(t, s.run())
+	assert.NotContains(t, copied, "b.txt")
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+}
+
+func TestParseTrackRenamesStrategy(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    trackRenamesStrategy
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"hash", trackRenamesStrategyHash, false},
+		{"size", 0, false},
+		{"leaf", trackRenamesStrategyLeaf, false},
+		{"hash, modtime", trackRenamesStrategyHash | trackRenamesStrategyModtime, false},
+		{"HASH,leaf,size", trackRenamesStrategyHash | trackRenamesStrategyLeaf, false},
+		{"potato", 0, true},
+	} {
+		got, err := parseTrackRenamesStrategy(test.in)
+		assert.Equal(t, test.want, got, test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+	}
+	assert.Equal(t, "hash,leaf,size", (trackRenamesStrategyHash | trackRenamesStrategyLeaf).String())
+}
+
+func TestParseOrderBy(t *testing.T) {
+	a := fs.ObjectPair{Src: mockobject.New("a").WithContent([]byte("22"), mockobject.SeekModeNone)}
+	b := fs.ObjectPair{Src: mockobject.New("b").WithContent([]byte("1"), mockobject.SeekModeNone)}
+	for _, test := range []struct {
+		in        string
+		wantNil   bool
+		wantMixed bool
+		wantLess  bool // whether a sorts before b
+		wantErr   bool
+	}{
+		{"", true, false, false, false},
+		{"size", false, false, false, false},
+		{"size,ascending", false, false, false, false},
+		{"Size,Descending", false, false, true, false},
+		{"size,mixed", false, true, false, false},
+		{"path", false, false, true, false},
+		{"path,desc", false, false, false, false},
+		{"modtime", false, false, false, false},
+		{"potato", false, false, false, true},
+		{"size,potato", false, false, false, true},
+		{"size,ascending,mixed", false, false, false, true},
+	} {
+		got, err := parseOrderBy(test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+		if test.wantErr {
+			continue
+		}
+		assert.Equal(t, test.wantNil, got == nil, test.in)
+		if got == nil {
+			continue
+		}
+		assert.Equal(t, test.wantMixed, got.mixed, test.in)
+		assert.Equal(t, test.wantLess, got.less(a, b), test.in)
+	}
+}
+
+func TestOrderTransfers(t *testing.T) {
+	var pairs []fs.ObjectPair
+	for _, size := range []int{3, 1, 4, 1, 5, 9, 2, 6} {
+		o := mockobject.New(fmt.Sprintf("file%d", len(pairs))).WithContent(make([]byte, size), mockobject.SeekModeNone)
+		pairs = append(pairs, fs.ObjectPair{Src: o})
+	}
+	for _, test := range []struct {
+		orderBy   string
+		lookahead int
+		want      []int64
+	}{
+		{"size", 100, []int64{1, 1, 2, 3, 4, 5, 6, 9}},
+		{"size,descending", 100, []int64{9, 6, 5, 4, 3, 2, 1, 1}},
+		{"size,mixed", 100, []int64{9, 1, 6, 1, 5, 2, 4, 3}},
+		{"size", 1, []int64{3, 1, 4, 1, 5, 9, 2, 6}},
+	} {
+		o, err := parseOrderBy(test.orderBy)
+		require.NoError(t, err)
+		in := make(chan fs.ObjectPair, len(pairs))
+		for _, pair := range pairs {
+			in <- pair
+		}
+		close(in)
+		out := make(chan fs.ObjectPair)
+		// Fill the lookahead before reading any transfers
+		go orderTransfers(context.Background(), o, test.lookahead, in, out)
+		time.Sleep(10 * time.Millisecond)
+		var got []int64
+		for pair := range out {
+			got = append(got, pair.Src.Size())
+		}
+		assert.Equal(t, test.want, got, test.orderBy)
+	}
+}
+
+// Test a sync with --order-by set
+func TestSyncOrderBy(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	fs.Config.OrderBy = "size,mixed"
+	defer func() {
+		fs.Config.OrderBy = ""
+	}()
+	file1 := r.WriteFile("small", "a", t1)
+	file2 := r.WriteFile("medium", "potato", t1)
+	file3 := r.WriteFile("large", "potato sausage", t1)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+
+	accounting.Stats.ResetCounters()
+	err := Sync(r.Fremote, r.Flocal)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+	assert.Equal(t, int64(3), accounting.Stats.GetTransfers())
+}
+
+// Test with TrackRenames set and the leaf strategy
+func TestSyncWithTrackRenamesStrategyLeaf(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	fs.Config.TrackRenames = true
+	fs.Config.TrackRenamesStrategy = "leaf"
+	defer func() {
+		fs.Config.TrackRenames = false
+		fs.Config.TrackRenamesStrategy = "hash"
+	}()
+
+	canTrackRenames := operations.CanServerSideMove(r.Fremote)
+	t.Logf("Can track renames: %v", canTrackRenames)
+
+	f1 := r.WriteFile("potato", "Potato Content", t1)
+	f2 := r.WriteFile("sub/yam", "Yam Content", t2)
+
+	accounting.Stats.ResetCounters()
+	require.NoError(t, Sync(r.Fremote, r.Flocal))
+
+	fstest.CheckItems(t, r.Fremote, f1, f2)
+	fstest.CheckItems(t, r.Flocal, f1, f2)
+
+	// Now move the file to a new directory keeping the leaf
+	f2 = r.RenameFile(f2, "yam")
+
+	accounting.Stats.ResetCounters()
+	require.NoError(t, Sync(r.Fremote, r.Flocal))
+
+	fstest.CheckItems(t, r.Fremote, f1, f2)
+
+	if canTrackRenames {
+		assert.Equal(t, accounting.Stats.GetTransfers(), int64(0))
+	} else {
+		assert.Equal(t, accounting.Stats.GetTransfers(), int64(1))
+	}
+}
+
 // Test a server side move if possible, or the backup path if not
 func testServerSideMove(t *testing.T, r *fstest.Run, withFilter, testDeleteEmptyDirs bool) {
 	FremoteMove, _, finaliseMove, err := fstest.RandomRemote(*fstest.RemoteName, *fstest.SubDir)
@@ -974,6 +1470,42 @@ func TestServerSideMoveDeleteEmptySourceDirs(t *testing.T) {
 	testServerSideMove(t, r, false, true)
 }
 
+// Test --delete-empty-src-dirs removes the source directories which
+// had files in when the files are moved one by one
+func TestMoveDeleteEmptySrcDirsNested(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteFile("sub dir/sub sub dir/hello world", "hello world", t1)
+	file2 := r.WriteFile("sub dir/potato", "potato", t2)
+	file3 := r.WriteFile("keep/potato.bak", "potato", t2)
+	require.NoError(t, operations.Mkdir(r.Flocal, "empty"))
+	r.Mkdir(r.Fremote)
+
+	// The excluded file stops its directory being removed
+	require.NoError(t, filter.Active.AddRule("- *.bak"))
+	defer filter.Active.Clear()
+
+	err := MoveDir(r.Fremote, r.Flocal, true)
+	require.NoError(t, err)
+
+	fstest.CheckListingWithPrecision(t, r.Flocal, []fstest.Item{file3}, []string{"keep"}, fs.GetModifyWindow(r.Flocal))
+	fstest.CheckListingWithPrecision(
+		t,
+		r.Fremote,
+		[]fstest.Item{
+			file1,
+			file2,
+		},
+		[]string{
+			"empty",
+			"keep",
+			"sub dir",
+			"sub dir/sub sub dir",
+		},
+		fs.GetModifyWindow(r.Fremote),
+	)
+}
+
 // Test a server side move with overlap
 func TestServerSideMoveOverlap(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -1170,3 +1702,43 @@ func TestAbort(t *testing.T) {
 	err := Sync(r.Fremote, r.Flocal)
 	assert.Equal(t, accounting.ErrorMaxTransferLimitReached, err)
 }
+
+// Test that --cutoff-mode soft lets the current transfer finish but
+// doesn't start any new ones once the max upload is reached
+func TestAbortSoft(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	if r.Fremote.Name() != "local" {
+		t.Skip("This test only runs on local")
+	}
+
+	oldMaxTransfer := fs.Config.MaxTransfer
+	oldCutoffMode := fs.Config.CutoffMode
+	oldTransfers := fs.Config.Transfers
+	oldCheckers := fs.Config.Checkers
+	fs.Config.MaxTransfer = 3 * 1024
+	fs.Config.CutoffMode = fs.CutoffModeSoft
+	fs.Config.Transfers = 1
+	fs.Config.Checkers = 1
+	defer func() {
+		fs.Config.MaxTransfer = oldMaxTransfer
+		fs.Config.CutoffMode = oldCutoffMode
+		fs.Config.Transfers = oldTransfers
+		fs.Config.Checkers = oldCheckers
+	}()
+
+	// Create file on source
+	file1 := r.WriteFile("file1", string(make([]byte, 5*1024)), t1)
+	file2 := r.WriteFile("file2", string(make([]byte, 2*1024)), t1)
+	file3 := r.WriteFile("file3", string(make([]byte, 3*1024)), t1)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+	fstest.CheckItems(t, r.Fremote)
+
+	accounting.Stats.ResetCounters()
+
+	err := Sync(r.Fremote, r.Flocal)
+	assert.Equal(t, accounting.ErrorMaxTransferLimitReached, err)
+	assert.Equal(t, int64(1), accounting.Stats.GetTransfers())
+	fstest.CheckItems(t, r.Fremote, file1)
+}
@@ -51,22 +51,66 @@ type Func func(path string, entries fs.DirEntries, err error) error
 //
 // Parent directories are always listed before their children
 //
-// This is implemented by WalkR if Config.UseRecursiveListing is true
-// and f supports it and level > 1, or WalkN otherwise.
+// This is implemented by WalkR if UseListR returns true, or WalkN
+// otherwise.
 //
 // NB (f, path) to be replaced by fs.Dir at some point
 func Walk(f fs.Fs, path string, includeAll bool, maxLevel int, fn Func) error {
-	if (maxLevel < 0 || maxLevel > 1) && fs.Config.UseListR && f.Features().ListR != nil {
+	useListR, entries := UseListR(f, path, includeAll, maxLevel)
+	if useListR {
 		return walkListR(f, path, includeAll, maxLevel, fn)
 	}
-	return walkListDirSorted(f, path, includeAll, maxLevel, fn)
+	return walk(f, path, includeAll, maxLevel, fn, reuseListing(path, entries))
 }
 
-// walkListDirSorted lists the directory.
+// UseListR returns whether a listing of path in f to maxLevel should
+// use ListR.  This is only possible if f supports ListR and maxLevel
+// is > 1, and is disabled by --no-fast-list.
 //
-// It implements Walk using non recursive directory listing.
-func walkListDirSorted(f fs.Fs, path string, includeAll bool, maxLevel int, fn Func) error {
-	return walk(f, path, includeAll, maxLevel, fn, list.DirSorted)
+// If --fast-list is set then it returns true.  Otherwise if
+// --fast-list-threshold is set it lists path and returns true if it
+// has more entries than the threshold.  This is a heuristic - a big
+// first level likely means a big tree.  If the listing was made and
+// ListR isn't to be used then the entries of path are returned so
+// path needn't be listed again.
+func UseListR(f fs.Fs, path string, includeAll bool, maxLevel int) (useListR bool, entries fs.DirEntries) {
+	if (maxLevel >= 0 && maxLevel <= 1) || f.Features().ListR == nil || fs.Config.NoListR {
+		return false, nil
+	}
+	if fs.Config.UseListR {
+		return true, nil
+	}
+	if fs.Config.FastListThreshold <= 0 {
+		return false, nil
+	}
+	entries, err := list.DirSorted(f, includeAll, path)
+	if err != nil {
+		// leave the normal listing to report the error
+		return false, nil
+	}
+	if len(entries) > fs.Config.FastListThreshold {
+		fs.Debugf(f, "Using fast list as %q has %d entries which is more than --fast-list-threshold %d", path, len(entries), fs.Config.FastListThreshold)
+		return true, nil
+	}
+	return false, entries
+}
+
+// reuseListing returns a listDirFunc which returns entries the first
+// time dir is listed if not nil, and lists with list.DirSorted
+// otherwise.
+func reuseListing(dir string, entries fs.DirEntries) listDirFunc {
+	var mu sync.Mutex
+	return func(f fs.Fs, includeAll bool, remote string) (fs.DirEntries, error) {
+		mu.Lock()
+		if remote == dir && entries != nil {
+			reused := entries
+			entries = nil
+			mu.Unlock()
+			return reused, nil
+		}
+		mu.Unlock()
+		return list.DirSorted(f, includeAll, remote)
+	}
 }
 
 // walkListR lists the directory.
@@ -449,15 +493,30 @@ func walkNDirTree(f fs.Fs, path string, includeAll bool, maxLevel int, listDir l
 // If maxLevel is < 0 then it will recurse indefinitely, else it will
 // only do maxLevel levels.
 //
-// This is implemented by WalkR if Config.UseRecursiveListing is true
-// and f supports it and level > 1, or WalkN otherwise.
+// This is implemented by WalkR if UseListR returns true, or WalkN
+// otherwise.
 //
 // NB (f, path) to be replaced by fs.Dir at some point
 func NewDirTree(f fs.Fs, path string, includeAll bool, maxLevel int) (DirTree, error) {
-	if ListR := f.Features().ListR; (maxLevel < 0 || maxLevel > 1) && fs.Config.UseListR && ListR != nil {
-		return walkRDirTree(f, path, includeAll, maxLevel, ListR)
+	useListR, entries := UseListR(f, path, includeAll, maxLevel)
+	if useListR {
+		return NewDirTreeListR(f, path, includeAll, maxLevel)
+	}
+	return walkNDirTree(f, path, includeAll, maxLevel, reuseListing(path, entries))
+}
+
+// NewDirTreeListR returns a DirTree filled with the directory listing
+// made with ListR using the parameters supplied, or ErrorCantListR if
+// f doesn't support ListR.
+//
+// Use this rather than NewDirTree when UseListR has already been
+// called.
+func NewDirTreeListR(f fs.Fs, path string, includeAll bool, maxLevel int) (DirTree, error) {
+	listR := f.Features().ListR
+	if listR == nil {
+		return nil, ErrorCantListR
 	}
-	return walkNDirTree(f, path, includeAll, maxLevel, list.DirSorted)
+	return walkRDirTree(f, path, includeAll, maxLevel, listR)
 }
 
 func walkR(f fs.Fs, path string, includeAll bool, maxLevel int, fn Func, listR fs.ListRFn) error {
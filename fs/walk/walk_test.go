@@ -634,3 +634,69 @@ b/c/d/
 	// Set to default value, to avoid side effects
 	filter.Active.Opt.ExcludeFile = ""
 }
+
+// listRFs is a minimal fs.Fs which supports ListR
+type listRFs struct {
+	fs.Fs
+	entries fs.DirEntries
+	lists   int
+}
+
+func (f *listRFs) Features() *fs.Features {
+	return (&fs.Features{}).Fill(f)
+}
+
+func (f *listRFs) List(dir string) (entries fs.DirEntries, err error) {
+	f.lists++
+	return f.entries, nil
+}
+
+func (f *listRFs) ListR(dir string, callback fs.ListRCallback) error {
+	return callback(f.entries)
+}
+
+func TestUseListR(t *testing.T) {
+	oldUseListR, oldNoListR, oldThreshold := fs.Config.UseListR, fs.Config.NoListR, fs.Config.FastListThreshold
+	defer func() {
+		fs.Config.UseListR, fs.Config.NoListR, fs.Config.FastListThreshold = oldUseListR, oldNoListR, oldThreshold
+	}()
+	f := &listRFs{entries: fs.DirEntries{mockobject.Object("a"), mockobject.Object("b")}}
+
+	for _, test := range []struct {
+		useListR   bool
+		noListR    bool
+		threshold  int
+		maxLevel   int
+		want       bool
+		wantProbed bool
+	}{
+		{false, false, 0, -1, false, false},
+		{true, false, 0, -1, true, false},
+		{true, false, 0, 1, false, false},
+		{true, true, 1, -1, false, false},
+		{false, false, 1, -1, true, false},
+		{false, false, 2, -1, false, true},
+		{false, true, 1, -1, false, false},
+	} {
+		what := fmt.Sprintf("%+v", test)
+		fs.Config.UseListR, fs.Config.NoListR, fs.Config.FastListThreshold = test.useListR, test.noListR, test.threshold
+		got, entries := UseListR(f, "", true, test.maxLevel)
+		assert.Equal(t, test.want, got, what)
+		if test.wantProbed {
+			assert.Equal(t, f.entries, entries, what)
+		} else {
+			assert.Nil(t, entries, what)
+		}
+	}
+
+	// The probe listing is reused by Walk
+	fs.Config.UseListR, fs.Config.NoListR, fs.Config.FastListThreshold = false, false, 10
+	f.lists = 0
+	var got fs.DirEntries
+	require.NoError(t, Walk(f, "", true, -1, func(path string, entries fs.DirEntries, err error) error {
+		got = append(got, entries...)
+		return err
+	}))
+	assert.Equal(t, f.entries, got)
+	assert.Equal(t, 1, f.lists)
+}
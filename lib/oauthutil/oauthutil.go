@@ -117,6 +117,10 @@ func GetToken(name string) (*oauth2.Token, error) {
 	return token, nil
 }
 
+// putTokenMu serializes the reading and writing of tokens in the
+// config file so concurrent updates don't clobber each other
+var putTokenMu sync.Mutex
+
 // PutToken stores the token in the config file
 //
 // This saves the config file if it changes
@@ -125,6 +129,8 @@ func PutToken(name string, token *oauth2.Token, newSection bool) error {
 	if err != nil {
 		return err
 	}
+	putTokenMu.Lock()
+	defer putTokenMu.Unlock()
 	tokenString := string(tokenBytes)
 	old := config.FileGet(name, config.ConfigToken)
 	if tokenString != old {
@@ -140,12 +146,57 @@ func PutToken(name string, token *oauth2.Token, newSection bool) error {
 	return nil
 }
 
+// sharedTokenKey identifies an account whose token may be shared
+type sharedTokenKey struct {
+	clientID string
+	name     string
+}
+
+// sharedToken is the token for an account shared by all the
+// TokenSources for it, so that only one of them refreshes it when it
+// expires and the others use the refreshed token.
+type sharedToken struct {
+	mu          sync.Mutex // held while the token is being refreshed
+	tokenSource oauth2.TokenSource
+	token       *oauth2.Token
+}
+
+var (
+	sharedTokensMu sync.Mutex
+	sharedTokens   = map[sharedTokenKey]*sharedToken{}
+)
+
+// getSharedToken returns the sharedToken for the account, making it
+// with token if it doesn't exist.  If token is newer than the shared
+// token, eg because the remote was reconnected, then it replaces it.
+func getSharedToken(name string, config *oauth2.Config, token *oauth2.Token) *sharedToken {
+	key := sharedTokenKey{clientID: config.ClientID, name: name}
+	sharedTokensMu.Lock()
+	defer sharedTokensMu.Unlock()
+	shared, ok := sharedTokens[key]
+	if !ok {
+		shared = &sharedToken{token: token}
+		sharedTokens[key] = shared
+		return shared
+	}
+	shared.mu.Lock()
+	if token.Expiry.After(shared.token.Expiry) {
+		shared.token = token
+		shared.tokenSource = nil
+	}
+	shared.mu.Unlock()
+	return shared
+}
+
 // TokenSource stores updated tokens in the config file
+//
+// All the TokenSources for the same account share their token so it
+// is only refreshed once however many of them are in use.
 type TokenSource struct {
 	mu          sync.Mutex
 	name        string
-	tokenSource oauth2.TokenSource
-	token       *oauth2.Token
+	shared      *sharedToken
+	token       *oauth2.Token // the last token this returned
 	config      *oauth2.Config
 	ctx         context.Context
 	expiryTimer *time.Timer // signals whenever the token expires
@@ -160,22 +211,31 @@ func (ts *TokenSource) Token() (*oauth2.Token, error) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
+	// Holding the shared lock means only one TokenSource refreshes
+	// the token and the others wait and then use the new token
+	shared := ts.shared
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
 	// Make a new token source if required
-	if ts.tokenSource == nil {
-		ts.tokenSource = ts.config.TokenSource(ts.ctx, ts.token)
+	if shared.tokenSource == nil {
+		shared.tokenSource = ts.config.TokenSource(ts.ctx, shared.token)
 	}
 
-	token, err := ts.tokenSource.Token()
+	token, err := shared.tokenSource.Token()
 	if err != nil {
 		return nil, err
 	}
-	changed := *token != *ts.token
-	ts.token = token
-	if changed {
+	changed := !tokensEqual(token, shared.token)
+	shared.token = token
+	if !tokensEqual(token, ts.token) {
 		// Bump on the expiry timer if it is set
+		ts.token = token
 		if ts.expiryTimer != nil {
 			ts.expiryTimer.Reset(ts.timeToExpiry())
 		}
+	}
+	if changed {
 		err = PutToken(ts.name, token, false)
 		if err != nil {
 			return nil, err
@@ -184,11 +244,24 @@ func (ts *TokenSource) Token() (*oauth2.Token, error) {
 	return token, nil
 }
 
+// tokensEqual returns whether a and b are the same token.
+//
+// The tokens can't be compared with == as refreshed tokens contain a
+// map of the raw response.
+func tokensEqual(a, b *oauth2.Token) bool {
+	return a.AccessToken == b.AccessToken &&
+		a.TokenType == b.TokenType &&
+		a.RefreshToken == b.RefreshToken &&
+		a.Expiry.Equal(b.Expiry)
+}
+
 // Invalidate invalidates the token
+//
+// This invalidates it for all the TokenSources sharing it
 func (ts *TokenSource) Invalidate() {
-	ts.mu.Lock()
-	ts.token.AccessToken = ""
-	ts.mu.Unlock()
+	ts.shared.mu.Lock()
+	ts.shared.token.AccessToken = ""
+	ts.shared.mu.Unlock()
 }
 
 // timeToExpiry returns how long until the token expires
@@ -271,9 +344,11 @@ func NewClientWithBaseClient(name string, config *oauth2.Config, baseClient *htt
 	ctx := Context(baseClient)
 
 	// Wrap the TokenSource in our TokenSource which saves changed
-	// tokens in the config file
+	// tokens in the config file and shares them with the other
+	// TokenSources for this account
 	ts := &TokenSource{
 		name:   name,
+		shared: getSharedToken(name, config, token),
 		token:  token,
 		config: config,
 		ctx:    ctx,
@@ -310,13 +385,27 @@ func ConfigErrorCheck(id, name string, errorHandler func(*http.Request) AuthErro
 func doConfig(id, name string, errorHandler func(*http.Request) AuthError, oauthConfig *oauth2.Config, offline bool, opts []oauth2.AuthCodeOption) error {
 	oauthConfig, changed := overrideCredentials(name, oauthConfig)
 	automatic := config.FileGet(name, config.ConfigAutomatic) != ""
+	save := config.FileGet(name, config.ConfigAutomaticSave) != ""
+
+	// Use our own http client for the token exchange so the proxy
+	// settings and timeouts are used
+	ctx := Context(fshttp.NewClient(fs.Config))
 
 	// See if already have a token
 	tokenString := config.FileGet(name, "token")
 	if tokenString != "" {
-		fmt.Printf("Already have a token - refresh?\n")
-		if !config.Confirm() {
-			return nil
+		if automatic {
+			// Refresh it without any interaction if it can be
+			err := refreshToken(ctx, name, oauthConfig)
+			if err == nil {
+				return nil
+			}
+			fmt.Printf("Failed to refresh existing token: %v\n", err)
+		} else {
+			fmt.Printf("Already have a token - refresh?\n")
+			if !config.Confirm() {
+				return nil
+			}
 		}
 	}
 
@@ -423,13 +512,13 @@ func doConfig(id, name string, errorHandler func(*http.Request) AuthError, oauth
 		fmt.Printf("Enter verification code> ")
 		authCode = config.ReadLine()
 	}
-	token, err := oauthConfig.Exchange(oauth2.NoContext, authCode)
+	token, err := oauthConfig.Exchange(ctx, authCode)
 	if err != nil {
 		return errors.Wrap(err, "failed to get token")
 	}
 
 	// Print code if we do automatic retrieval
-	if automatic {
+	if automatic && !save {
 		result, err := json.Marshal(token)
 		if err != nil {
 			return errors.Wrap(err, "failed to marshal token")
@@ -439,6 +528,25 @@ func doConfig(id, name string, errorHandler func(*http.Request) AuthError, oauth
 	return PutToken(name, token, true)
 }
 
+// refreshToken gets a new token for the config name using the
+// refresh token of the one it has and saves it
+func refreshToken(ctx context.Context, name string, oauthConfig *oauth2.Config) error {
+	token, err := GetToken(name)
+	if err != nil {
+		return err
+	}
+	if token.RefreshToken == "" {
+		return errors.New("token has no refresh token")
+	}
+	// Leave out the access token so a new one is always fetched
+	newToken, err := oauthConfig.TokenSource(ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+	if err != nil {
+		return errors.Wrap(err, "failed to refresh token")
+	}
+	fmt.Printf("Refreshed existing token\n")
+	return PutToken(name, newToken, true)
+}
+
 // Local web server for collecting auth
 type authServer struct {
 	state        string
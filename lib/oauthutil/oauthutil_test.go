@@ -0,0 +1,160 @@
+package oauthutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// Check that TokenSources for the same account refresh the token
+// only once however many of them use it at once
+func TestSharedTokenRefresh(t *testing.T) {
+	var refreshes int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&refreshes, 1)
+		// make the refresh slow enough for the callers to pile up
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","refresh_token":"refresh","expires_in":3600}`, n)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "rclone-oauthutil-test")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	oldConfigPath := config.ConfigPath
+	config.ConfigPath = filepath.Join(dir, "rclone.conf")
+	defer func() {
+		config.ConfigPath = oldConfigPath
+	}()
+
+	const name = "TestSharedTokenRefresh"
+	sharedTokensMu.Lock()
+	sharedTokens = map[sharedTokenKey]*sharedToken{}
+	sharedTokensMu.Unlock()
+
+	expired, err := json.Marshal(&oauth2.Token{
+		AccessToken:  "expired",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	config.FileSet(name, config.ConfigToken, string(expired))
+
+	oauthConfig := &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint: oauth2.Endpoint{
+			TokenURL: ts.URL,
+		},
+	}
+	var tokenSources []*TokenSource
+	for i := 0; i < 3; i++ {
+		_, tokenSource, err := NewClientWithBaseClient(name, oauthConfig, http.DefaultClient)
+		require.NoError(t, err)
+		tokenSources = append(tokenSources, tokenSource)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		access = map[string]int{}
+	)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(tokenSource *TokenSource) {
+			defer wg.Done()
+			token, err := tokenSource.Token()
+			require.NoError(t, err)
+			mu.Lock()
+			access[token.AccessToken]++
+			mu.Unlock()
+		}(tokenSources[i%len(tokenSources)])
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+	assert.Equal(t, map[string]int{"access-1": 30}, access)
+
+	// Check the refreshed token was saved
+	saved, err := GetToken(name)
+	require.NoError(t, err)
+	assert.Equal(t, "access-1", saved.AccessToken)
+
+	// Invalidating one invalidates them all
+	tokenSources[0].Invalidate()
+	for _, tokenSource := range tokenSources {
+		token, err := tokenSource.Token()
+		require.NoError(t, err)
+		assert.Equal(t, "access-2", token.AccessToken)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&refreshes))
+}
+
+// Check that automatic config refreshes an existing token without
+// any interaction
+func TestConfigRefreshExistingToken(t *testing.T) {
+	var gotRefresh string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRefresh = r.FormValue("refresh_token")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"access_token":"new-access","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "rclone-oauthutil-test")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	oldConfigPath := config.ConfigPath
+	config.ConfigPath = filepath.Join(dir, "rclone.conf")
+	defer func() {
+		config.ConfigPath = oldConfigPath
+	}()
+
+	const name = "TestConfigRefreshExistingToken"
+	old, err := json.Marshal(&oauth2.Token{
+		AccessToken:  "old-access",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	config.FileSet(name, config.ConfigToken, string(old))
+	config.FileSet(name, config.ConfigAutomatic, "yes")
+	config.FileSet(name, config.ConfigAutomaticSave, "yes")
+
+	oauthConfig := &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint: oauth2.Endpoint{
+			TokenURL: ts.URL,
+		},
+		RedirectURL: RedirectURL,
+	}
+	require.NoError(t, Config("test", name, oauthConfig))
+	assert.Equal(t, "refresh", gotRefresh)
+
+	token, err := GetToken(name)
+	require.NoError(t, err)
+	assert.Equal(t, "new-access", token.AccessToken)
+	// The refresh token is kept if a new one isn't returned
+	assert.Equal(t, "refresh", token.RefreshToken)
+}
@@ -0,0 +1,110 @@
+package pacer
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/fserrors"
+)
+
+// breaker is a circuit breaker which stops calls being made to a
+// backend which is failing every call.
+//
+// After threshold consecutive failures of the same class of error it
+// opens and calls fail immediately for the cooldown period.  After
+// that a single probe call is let through - if that succeeds the
+// breaker closes again, otherwise it stays open for another cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int           // consecutive failures needed to open
+	cooldown  time.Duration // how long to stay open for
+	failures  int           // number of consecutive failures of class
+	class     string        // class of the last failure
+	lastErr   error         // the last failure
+	openUntil time.Time     // if non zero the breaker is open until this time
+	probing   bool          // set if a probe call is in progress
+	now       func() time.Time
+}
+
+// newBreaker makes a new circuit breaker
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// CircuitBreakerError is returned from the pacer calls when the
+// circuit breaker is open
+type CircuitBreakerError struct {
+	Failures int   // number of consecutive failures which opened the breaker
+	Err      error // the last failure
+}
+
+// Error satisfies the error interface
+func (e *CircuitBreakerError) Error() string {
+	return fmt.Sprintf("circuit breaker open after %d consecutive failures: %v", e.Failures, e.Err)
+}
+
+// errorClass returns a string classifying err so that failures of
+// the same kind can be counted together
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	_, cause := fserrors.Cause(err)
+	return reflect.TypeOf(cause).String()
+}
+
+// allow returns nil if a call may be made or an error if the breaker
+// is open
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return nil
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return &CircuitBreakerError{Failures: b.failures, Err: b.lastErr}
+	}
+	// Cooldown expired so let one call through to probe
+	b.probing = true
+	return nil
+}
+
+// record notes the result of a call.  retry is true if the call
+// failed in a way which should be retried.
+func (b *breaker) record(retry bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasProbing := b.probing
+	b.probing = false
+	if !retry {
+		if !b.openUntil.IsZero() {
+			fs.Logf("pacer", "Circuit breaker closed - backend is working again")
+		}
+		b.failures = 0
+		b.class = ""
+		b.lastErr = nil
+		b.openUntil = time.Time{}
+		return
+	}
+	class := errorClass(err)
+	if class == b.class {
+		b.failures++
+	} else {
+		b.class = class
+		b.failures = 1
+	}
+	b.lastErr = err
+	if wasProbing || b.failures >= b.threshold {
+		if b.openUntil.IsZero() {
+			fs.Errorf("pacer", "Circuit breaker open after %d consecutive failures - failing calls for %v: %v", b.failures, b.cooldown, err)
+		}
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
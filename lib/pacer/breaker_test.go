@@ -0,0 +1,84 @@
+package pacer
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	b := newBreaker(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	// Failures of different classes don't open it
+	assert.NoError(t, b.allow())
+	b.record(true, errFoo)
+	b.record(true, errFoo)
+	b.record(true, io.EOF)
+	assert.NoError(t, b.allow())
+
+	// A success resets the count
+	b.record(false, nil)
+	b.record(true, errFoo)
+	b.record(true, errFoo)
+	assert.NoError(t, b.allow())
+
+	// Non retriable errors count as success
+	b.record(false, errFoo)
+	assert.NoError(t, b.allow())
+
+	// Threshold failures of the same class open it
+	b.record(true, errFoo)
+	b.record(true, errors.Wrap(errFoo, "wrapped"))
+	b.record(true, errFoo)
+	err := b.allow()
+	require.Error(t, err)
+	breakerErr, ok := err.(*CircuitBreakerError)
+	require.True(t, ok)
+	assert.Equal(t, 3, breakerErr.Failures)
+	assert.Equal(t, errFoo, breakerErr.Err)
+
+	// After the cooldown one probe is let through
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.allow())
+	assert.Error(t, b.allow())
+
+	// A failed probe opens it again
+	b.record(true, errFoo)
+	assert.Error(t, b.allow())
+	now = now.Add(time.Minute)
+
+	// A successful probe closes it
+	assert.NoError(t, b.allow())
+	b.record(false, nil)
+	assert.NoError(t, b.allow())
+	assert.NoError(t, b.allow())
+}
+
+func TestCallCircuitBreaker(t *testing.T) {
+	p := New().SetMinSleep(time.Millisecond).SetMaxSleep(2*time.Millisecond).SetRetries(20).SetCircuitBreaker(5, time.Hour)
+
+	dp := &dummyPaced{retry: true}
+	err := p.Call(dp.fn)
+	assert.Equal(t, 5, dp.called)
+	_, ok := err.(*CircuitBreakerError)
+	assert.True(t, ok)
+
+	// Subsequent calls fail fast without calling the function
+	err = p.Call(dp.fn)
+	assert.Equal(t, 5, dp.called)
+	_, ok = err.(*CircuitBreakerError)
+	assert.True(t, ok)
+
+	// Disabling it lets calls through again
+	p.SetCircuitBreaker(0, 0)
+	dp.retry = false
+	err = p.Call(dp.fn)
+	assert.Equal(t, 6, dp.called)
+	assert.Equal(t, errFoo, err)
+}
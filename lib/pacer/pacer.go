@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
 	"github.com/ncw/rclone/fs/fserrors"
 )
 
@@ -24,8 +25,13 @@ type Pacer struct {
 	connTokens         chan struct{} // Connection tokens
 	calculatePace      func(bool)    // switchable pacing algorithm - call with mu held
 	consecutiveRetries int           // number of consecutive retries
+	breaker            *breaker      // circuit breaker if set
 }
 
+// rampBackoffRetries is the number of consecutive retries after which
+// the concurrent transfers are reduced for --transfers-ramp
+const rampBackoffRetries = 3
+
 // Type is for selecting different pacing algorithms
 type Type int
 
@@ -80,6 +86,7 @@ func New() *Pacer {
 	p.sleepTime = p.minSleep
 	p.SetPacer(DefaultPacer)
 	p.SetMaxConnections(fs.Config.Checkers + fs.Config.Transfers)
+	p.SetCircuitBreaker(fs.Config.BreakerThreshold, fs.Config.BreakerCooldown)
 
 	// Put the first pacing token in
 	p.pacer <- struct{}{}
@@ -120,6 +127,28 @@ func (p *Pacer) SetMaxSleep(t time.Duration) *Pacer {
 	return p
 }
 
+// SetBurst sets the number of calls which may be made in quick
+// succession without sleeping once the pacer has been idle for long
+// enough.  Values less than 1 are treated as 1 which is the default.
+//
+// This can be changed while the pacer is in use.
+func (p *Pacer) SetBurst(n int) *Pacer {
+	if n < 1 {
+		n = 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n == cap(p.pacer) {
+		return p
+	}
+	// Calls in progress return their tokens to the old channel
+	p.pacer = make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		p.pacer <- struct{}{}
+	}
+	return p
+}
+
 // SetMaxConnections sets the maximum number of concurrent connections.
 // Setting the value to 0 will allow unlimited number of connections.
 // Should not be changed once you have started calling the pacer.
@@ -139,6 +168,22 @@ func (p *Pacer) SetMaxConnections(n int) *Pacer {
 	return p
 }
 
+// SetCircuitBreaker sets up the circuit breaker so that after
+// threshold consecutive failures of the same kind calls fail
+// immediately for the cooldown period.
+//
+// threshold <= 0 disables the circuit breaker.
+func (p *Pacer) SetCircuitBreaker(threshold int, cooldown time.Duration) *Pacer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if threshold > 0 {
+		p.breaker = newBreaker(threshold, cooldown)
+	} else {
+		p.breaker = nil
+	}
+	return p
+}
+
 // SetDecayConstant sets the decay constant for the pacer
 //
 // This is the speed the time falls back to the minimum after errors
@@ -201,7 +246,10 @@ func (p *Pacer) beginCall() {
 	// XXX ms later we put another in.  We could do this with a
 	// Ticker more accurately, but then we'd have to work out how
 	// not to run it when it wasn't needed
-	<-p.pacer
+	p.mu.Lock()
+	pacer := p.pacer
+	p.mu.Unlock()
+	<-pacer
 	if p.maxConnections > 0 {
 		<-p.connTokens
 	}
@@ -211,7 +259,7 @@ func (p *Pacer) beginCall() {
 	go func(t time.Duration) {
 		// fs.Debugf(f, "New sleep for %v at %v", t, time.Now())
 		time.Sleep(t)
-		p.pacer <- struct{}{}
+		pacer <- struct{}{}
 	}(p.sleepTime)
 	p.mu.Unlock()
 }
@@ -320,6 +368,9 @@ func (p *Pacer) endCall(retry bool) {
 	p.mu.Lock()
 	if retry {
 		p.consecutiveRetries++
+		if p.consecutiveRetries == rampBackoffRetries {
+			accounting.RampBackoff()
+		}
 	} else {
 		p.consecutiveRetries = 0
 	}
@@ -330,10 +381,21 @@ func (p *Pacer) endCall(retry bool) {
 // call implements Call but with settable retries
 func (p *Pacer) call(fn Paced, retries int) (err error) {
 	var retry bool
+	p.mu.Lock()
+	b := p.breaker
+	p.mu.Unlock()
 	for i := 1; i <= retries; i++ {
+		if b != nil {
+			if breakerErr := b.allow(); breakerErr != nil {
+				return breakerErr
+			}
+		}
 		p.beginCall()
 		retry, err = fn()
 		p.endCall(retry)
+		if b != nil {
+			b.record(retry, err)
+		}
 		if !retry {
 			break
 		}
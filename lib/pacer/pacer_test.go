@@ -80,6 +80,31 @@ func TestSetMaxSleep(t *testing.T) {
 	}
 }
 
+func TestSetBurst(t *testing.T) {
+	p := New().SetBurst(3)
+	if cap(p.pacer) != 3 {
+		t.Errorf("cap want 3 got %d", cap(p.pacer))
+	}
+	if len(p.pacer) != 3 {
+		t.Errorf("len want 3 got %d", len(p.pacer))
+	}
+	p.SetBurst(0)
+	if cap(p.pacer) != 1 || len(p.pacer) != 1 {
+		t.Errorf("want burst 1 got cap %d len %d", cap(p.pacer), len(p.pacer))
+	}
+}
+
+func TestBurst(t *testing.T) {
+	p := New().SetMinSleep(time.Hour).SetBurst(3).SetMaxConnections(0)
+	// The first 3 calls shouldn't wait
+	for i := 0; i < 3; i++ {
+		p.beginCall()
+	}
+	if len(p.pacer) != 0 {
+		t.Errorf("expecting pacer to be empty")
+	}
+}
+
 func TestMaxConnections(t *testing.T) {
 	p := New().SetMaxConnections(20)
 	if p.maxConnections != 20 {
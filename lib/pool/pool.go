@@ -0,0 +1,106 @@
+// Package pool provides a pool of buffers which limits the total
+// memory they use.
+package pool
+
+import (
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// Pool hands out buffers while keeping the memory used by them under
+// a limit.  Buffers which are given back are kept to be reused by
+// later calls to Get for the same size.
+type Pool struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	max       int64            // max bytes the buffers may use, 0 for no limit
+	inUse     int64            // bytes in the buffers handed out
+	freeBytes int64            // bytes in the buffers kept for reuse
+	free      map[int][][]byte // buffers kept for reuse by size
+}
+
+// New makes a pool which keeps the buffers handed out and kept for
+// reuse to max bytes in total.  If max is 0 there is no limit and
+// buffers aren't kept.
+func New(max int64) *Pool {
+	p := &Pool{
+		max:  max,
+		free: make(map[int][][]byte),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Get a buffer of size bytes, waiting until there is enough memory
+// for it if necessary.
+//
+// If size is bigger than the limit it is only handed out when no
+// other buffers are, so it doesn't wait forever.
+func (p *Pool) Get(size int) []byte {
+	if p.max <= 0 {
+		return make([]byte, size)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waited := false
+	for {
+		if bufs := p.free[size]; len(bufs) > 0 {
+			buf := bufs[len(bufs)-1]
+			p.free[size] = bufs[:len(bufs)-1]
+			p.freeBytes -= int64(size)
+			p.inUse += int64(size)
+			return buf
+		}
+		// Throw away buffers kept for other sizes to make room
+		if p.inUse+p.freeBytes+int64(size) > p.max {
+			p._freeAll()
+		}
+		if p.inUse+int64(size) <= p.max || p.inUse == 0 {
+			p.inUse += int64(size)
+			return make([]byte, size)
+		}
+		if !waited {
+			fs.Debugf(nil, "Waiting for %v of upload buffers in use to be freed for %v more", fs.SizeSuffix(p.inUse), fs.SizeSuffix(size))
+			waited = true
+		}
+		p.cond.Wait()
+	}
+}
+
+// Put gives back a buffer from Get when it is no longer needed
+func (p *Pool) Put(buf []byte) {
+	if p.max <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	size := len(buf)
+	p.inUse -= int64(size)
+	if p.inUse+p.freeBytes+int64(size) <= p.max {
+		p.free[size] = append(p.free[size], buf)
+		p.freeBytes += int64(size)
+	}
+	p.cond.Broadcast()
+}
+
+// _freeAll drops all the buffers kept for reuse - call with the lock
+// held
+func (p *Pool) _freeAll() {
+	p.free = make(map[int][][]byte)
+	p.freeBytes = 0
+}
+
+var (
+	uploadPool     *Pool
+	uploadPoolOnce sync.Once
+)
+
+// Upload returns the pool for upload buffers which is shared by all
+// the backends and limited by --upload-buffer-pool-max.
+func Upload() *Pool {
+	uploadPoolOnce.Do(func() {
+		uploadPool = New(int64(fs.Config.UploadBufferPoolMax))
+	})
+	return uploadPool
+}
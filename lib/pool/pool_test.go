@@ -0,0 +1,75 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolUnlimited(t *testing.T) {
+	p := New(0)
+	buf := p.Get(10)
+	assert.Len(t, buf, 10)
+	p.Put(buf)
+	assert.Equal(t, int64(0), p.inUse)
+	assert.Equal(t, int64(0), p.freeBytes)
+}
+
+func TestPoolReuse(t *testing.T) {
+	p := New(100)
+	buf := p.Get(10)
+	assert.Len(t, buf, 10)
+	assert.Equal(t, int64(10), p.inUse)
+	p.Put(buf)
+	assert.Equal(t, int64(0), p.inUse)
+	assert.Equal(t, int64(10), p.freeBytes)
+
+	// The same buffer comes back
+	buf2 := p.Get(10)
+	assert.True(t, &buf[0] == &buf2[0])
+	assert.Equal(t, int64(0), p.freeBytes)
+	p.Put(buf2)
+
+	// Buffers of other sizes are thrown away to make room
+	buf3 := p.Get(95)
+	assert.Len(t, buf3, 95)
+	assert.Equal(t, int64(95), p.inUse)
+	assert.Equal(t, int64(0), p.freeBytes)
+	p.Put(buf3)
+}
+
+func TestPoolWait(t *testing.T) {
+	p := New(100)
+	buf1 := p.Get(60)
+	got := make(chan []byte)
+	go func() {
+		got <- p.Get(60)
+	}()
+	select {
+	case <-got:
+		t.Fatal("Get didn't wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.Put(buf1)
+	select {
+	case buf2 := <-got:
+		assert.Len(t, buf2, 60)
+		assert.True(t, &buf1[0] == &buf2[0])
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get didn't finish waiting")
+	}
+}
+
+func TestPoolTooBig(t *testing.T) {
+	p := New(100)
+
+	// Handed out if nothing else is in use
+	buf := p.Get(200)
+	assert.Len(t, buf, 200)
+	p.Put(buf)
+
+	// But not kept
+	assert.Equal(t, int64(0), p.inUse)
+	assert.Equal(t, int64(0), p.freeBytes)
+}
@@ -73,9 +73,10 @@ type cache struct {
 
 // cacheItem is stored in the item map
 type cacheItem struct {
-	opens  int       // number of times file is open
-	atime  time.Time // last time file was accessed
-	isFile bool      // if this is a file or a directory
+	opens  int         // number of times file is open
+	atime  time.Time   // last time file was accessed
+	isFile bool        // if this is a file or a directory
+	sparse *sparseFile // chunks of the file downloaded so far if set
 }
 
 // newCacheItem returns an item for the cache
@@ -286,6 +287,17 @@ func (c *cache) close(name string) {
 
 // remove should be called if name is deleted
 func (c *cache) remove(name string) {
+	c.itemMu.Lock()
+	defer c.itemMu.Unlock()
+	c._remove(name)
+}
+
+// _remove removes name from the cache, forgetting the chunks of it
+// downloaded so far - call with the lock held
+func (c *cache) _remove(name string) {
+	if item := c.item[clean(name)]; item != nil {
+		item.sparse = nil
+	}
 	osPath := c.toOSPath(name)
 	err := os.Remove(osPath)
 	if err != nil && !os.IsNotExist(err) {
@@ -293,6 +305,10 @@ func (c *cache) remove(name string) {
 	} else {
 		fs.Debugf(name, "Removed from cache")
 	}
+	err = os.Remove(osPath + partialSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		fs.Errorf(name, "Failed to remove partial file from cache: %v", err)
+	}
 }
 
 // removeDir should be called if dir is deleted and returns true if
@@ -341,7 +357,9 @@ func (c *cache) walk(fn func(osPath string, fi os.FileInfo, name string) error)
 // updateAtimes walks the cache updating any atimes it finds
 func (c *cache) updateAtimes() error {
 	return c.walk(func(osPath string, fi os.FileInfo, name string) error {
-		if !fi.IsDir() {
+		if !fi.IsDir() && strings.HasSuffix(name, partialSuffix) {
+			c.updatePartial(osPath, strings.TrimSuffix(name, partialSuffix))
+		} else if !fi.IsDir() {
 			// Update the atime with that of the file
 			atime := times.Get(fi).AccessTime()
 			c.updateTime(name, atime)
@@ -352,9 +370,28 @@ func (c *cache) updateAtimes() error {
 	})
 }
 
+// updatePartial is called for the partial file at osPath of a file
+// being downloaded in chunks.  These are expired with the file they
+// belong to, so if it isn't being downloaded any more, eg as it was
+// left from a previous run, the partial file is removed.
+func (c *cache) updatePartial(osPath string, name string) {
+	c.itemMu.Lock()
+	defer c.itemMu.Unlock()
+	item := c.item[name]
+	if item != nil && item.sparse != nil {
+		return
+	}
+	err := os.Remove(osPath)
+	if err != nil && !os.IsNotExist(err) {
+		fs.Errorf(name, "Failed to remove stale partial file from cache: %v", err)
+	} else {
+		fs.Debugf(name, "Removed stale partial file from cache")
+	}
+}
+
 // purgeOld gets rid of any files that are over age
 func (c *cache) purgeOld(maxAge time.Duration) {
-	c._purgeOld(maxAge, c.remove, c.removeDir)
+	c._purgeOld(maxAge, c._remove, c.removeDir)
 }
 
 func (c *cache) _purgeOld(maxAge time.Duration, remove func(name string), removeDir func(name string) bool) {
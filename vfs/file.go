@@ -3,11 +3,13 @@ package vfs
 import (
 	"os"
 	"path"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
 	"github.com/ncw/rclone/fs/log"
 	"github.com/pkg/errors"
 )
@@ -482,6 +484,62 @@ func (f *File) VFS() *VFS {
 	return f.d.vfs
 }
 
+// xattrHashPrefix is prepended to the lower case hash name to make
+// the extended attribute name, eg user.rclone.md5
+const xattrHashPrefix = "user.rclone."
+
+// xattrHashName returns the extended attribute name for the hash type
+func xattrHashName(ht hash.Type) string {
+	return xattrHashPrefix + strings.ToLower(strings.Replace(ht.String(), "-", "", -1))
+}
+
+// Listxattr returns the names of the extended attributes of the file
+//
+// These are only present if XattrHashes is set in which case there is
+// one for each hash the remote supports.
+func (f *File) Listxattr() (names []string) {
+	if !f.d.vfs.Opt.XattrHashes {
+		return nil
+	}
+	o := f.getObject()
+	if o == nil {
+		return nil
+	}
+	for _, ht := range o.Fs().Hashes().Array() {
+		names = append(names, xattrHashName(ht))
+	}
+	return names
+}
+
+// Getxattr returns the value of the extended attribute name
+//
+// It returns ENOATTR if the attribute doesn't exist or if the remote
+// didn't return a hash for this object.
+func (f *File) Getxattr(name string) (value []byte, err error) {
+	if !f.d.vfs.Opt.XattrHashes || !strings.HasPrefix(name, xattrHashPrefix) {
+		return nil, ENOATTR
+	}
+	o := f.getObject()
+	if o == nil {
+		return nil, ENOATTR
+	}
+	for _, ht := range o.Fs().Hashes().Array() {
+		if xattrHashName(ht) != name {
+			continue
+		}
+		sum, err := o.Hash(ht)
+		if err != nil {
+			fs.Errorf(f, "File.Getxattr failed to read %v hash: %v", ht, err)
+			return nil, err
+		}
+		if sum == "" {
+			return nil, ENOATTR
+		}
+		return []byte(sum), nil
+	}
+	return nil, ENOATTR
+}
+
 // Open a file according to the flags provided
 //
 //   O_RDONLY open the file read-only.
@@ -534,6 +592,18 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 		write = true
 	}
 
+	// Refuse new writes if shutting down
+	if write && f.d.vfs.isDraining() {
+		fs.Errorf(f, "Can't open for write while shutting down")
+		return nil, EROFS
+	}
+
+	// Refuse writes straight away if read only rather than
+	// failing when the file is closed
+	if write && f.d.vfs.Opt.ReadOnly {
+		return nil, EROFS
+	}
+
 	// FIXME discover if file is in cache or not?
 
 	// Open the correct sort of handle
@@ -570,6 +640,9 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 
 // Truncate changes the size of the named file.
 func (f *File) Truncate(size int64) (err error) {
+	if f.d.vfs.Opt.ReadOnly {
+		return EROFS
+	}
 	// make a copy of fh.writers with the lock held then unlock so
 	// we can call other file methods.
 	f.mu.Lock()
@@ -5,6 +5,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
 	"github.com/ncw/rclone/fstest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -91,6 +93,38 @@ func TestFileSetModTime(t *testing.T) {
 	assert.Equal(t, EROFS, err)
 }
 
+func TestFileXattr(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	vfs, file, _ := fileCreate(t, r)
+
+	// Off by default
+	assert.Nil(t, file.Listxattr())
+	_, err := file.Getxattr("user.rclone.md5")
+	assert.Equal(t, ENOATTR, err)
+
+	vfs.Opt.XattrHashes = true
+	hashes := r.Fremote.Hashes()
+	names := file.Listxattr()
+	assert.Equal(t, hashes.Count(), len(names))
+	if hashes.Contains(hash.MD5) {
+		assert.Contains(t, names, "user.rclone.md5")
+		value, err := file.Getxattr("user.rclone.md5")
+		require.NoError(t, err)
+		sum, err := file.DirEntry().(fs.Object).Hash(hash.MD5)
+		require.NoError(t, err)
+		assert.Equal(t, sum, string(value))
+	}
+	if hashes.Contains(hash.SHA1) {
+		assert.Contains(t, names, "user.rclone.sha1")
+	}
+
+	_, err = file.Getxattr("user.rclone.potato")
+	assert.Equal(t, ENOATTR, err)
+	_, err = file.Getxattr("security.selinux")
+	assert.Equal(t, ENOATTR, err)
+}
+
 func TestFileOpenRead(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
@@ -182,3 +216,36 @@ func TestFileOpen(t *testing.T) {
 	fd, err = file.Open(3)
 	assert.Equal(t, EPERM, err)
 }
+
+func TestFileOpenReadOnly(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteObject("dir/file1", "file1 contents", t1)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	for _, cacheMode := range []CacheMode{CacheModeOff, CacheModeMinimal, CacheModeWrites, CacheModeFull} {
+		opt := DefaultOpt
+		opt.CacheMode = cacheMode
+		opt.ReadOnly = true
+		vfs := New(r.Fremote, &opt)
+		node, err := vfs.Stat("dir/file1")
+		require.NoError(t, err)
+		file := node.(*File)
+
+		// Writes are refused when opened not when closed
+		for _, flags := range []int{os.O_WRONLY, os.O_RDWR, os.O_RDWR | os.O_APPEND, os.O_WRONLY | os.O_TRUNC} {
+			_, err = file.Open(flags)
+			assert.Equal(t, EROFS, err, "cacheMode=%v flags=%s", cacheMode, decodeOpenFlags(flags))
+		}
+		assert.Equal(t, EROFS, file.Truncate(0), "cacheMode=%v", cacheMode)
+
+		// Reads still work
+		fd, err := file.Open(os.O_RDONLY)
+		require.NoError(t, err, "cacheMode=%v", cacheMode)
+		require.NoError(t, fd.Close())
+
+		require.NoError(t, vfs.CleanUp())
+		vfs.Shutdown()
+	}
+	fstest.CheckItems(t, r.Fremote, file1)
+}
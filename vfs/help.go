@@ -27,6 +27,14 @@ Or individual files or directories:
 
     rclone rc vfs/forget file=path/to/file dir=path/to/dir
 
+### Read Only
+
+If ` + "`--read-only`" + ` is set then anything which would change
+the remote - creating, writing, truncating, renaming or deleting files
+and directories, or setting modification times - fails straight away
+with "Read only file system" (EROFS) rather than being accepted and
+failing later when the file is closed.
+
 ### File Caching
 
 **NB** File caching is **EXPERIMENTAL** - use with care!
@@ -43,8 +51,10 @@ may find that you need one or the other or both.
 
     --cache-dir string                   Directory rclone will use for caching.
     --vfs-cache-max-age duration         Max age of objects in the cache. (default 1h0m0s)
+    --vfs-cache-chunked                  In full cache mode download files in chunks as they are read.
     --vfs-cache-mode string              Cache mode off|minimal|writes|full (default "off")
     --vfs-cache-poll-interval duration   Interval to poll the cache for stale objects. (default 1m0s)
+    --vfs-shutdown-timeout duration      Time to wait for writes and uploads to finish on shutdown. (default 1m0s)
 
 If run with ` + "`-vv`" + ` rclone will print the location of the file cache.  The
 files are stored in the user cache file area which is OS dependent but
@@ -60,6 +70,12 @@ closed so if rclone is quit or dies with open files then these won't
 get written back to the remote.  However they will still be in the on
 disk cache.
 
+When the mount is stopped with SIGINT or SIGTERM (eg by systemd),
+rclone refuses any new writes and waits for up to
+` + "`--vfs-shutdown-timeout`" + ` for open files to be closed and written
+back before it unmounts.  If the timeout elapses with files still not
+written back then rclone exits with an error.
+
 #### --vfs-cache-mode off
 
 In this mode the cache will read directly from the remote and write
@@ -113,6 +129,22 @@ will be purged on a schedule according to ` + "`--vfs-cache-max-age`" + `.
 
 This mode should support all normal file system operations.
 
+If ` + "`--vfs-cache-chunked`" + ` is set then files opened for read only
+aren't downloaded before they are read.  Instead the parts of the file
+which are read are downloaded in chunks into a sparse file in the
+cache, so playing or seeking in a large file only needs the parts
+used.  The first chunk is ` + "`--vfs-read-chunk-size`" + ` (or 128M if that is
+0) and the chunk size doubles while the file is read sequentially up
+to ` + "`--vfs-read-chunk-size-limit`" + `.  Handles reading the same file share
+the chunks downloaded.
+
+Until all of it has been downloaded the file is stored in the cache
+with ` + "`.partial`" + ` added to its name.  Which chunks are present is only
+known while rclone is running, so these files are removed if rclone is
+restarted, and they are purged with ` + "`--vfs-cache-max-age`" + ` like other
+files in the cache.  Once all of the file has been read it is cached
+as normal.
+
 If an upload or download fails it will be retried up to
 --low-level-retries times.
 `
@@ -27,10 +27,11 @@ type RWFileHandle struct {
 	file        *File
 	d           *Dir
 	opened      bool
-	flags       int    // open flags
-	osPath      string // path to the file in the cache
-	writeCalled bool   // if any Write() methods have been called
-	changed     bool   // file contents was changed in any other way
+	flags       int         // open flags
+	osPath      string      // path to the file in the cache
+	writeCalled bool        // if any Write() methods have been called
+	changed     bool        // file contents was changed in any other way
+	sparse      *sparseFile // if set the file is downloaded in chunks into this
 }
 
 // Check interfaces
@@ -125,6 +126,18 @@ func (fh *RWFileHandle) openPending(truncate bool) (err error) {
 
 		// try to open a exising cache file
 		fd, err = os.OpenFile(fh.osPath, cacheFileOpenFlags&^os.O_CREATE, 0600)
+		if os.IsNotExist(err) {
+			// if possible download the file in chunks as it is
+			// read rather than all of it now
+			var sparseErr error
+			fd, sparseErr = fh.openSparse(o)
+			if sparseErr != nil {
+				return sparseErr
+			}
+			if fd != nil {
+				err = nil
+			}
+		}
 		if os.IsNotExist(err) {
 			// cache file does not exist, so need to fetch it if we have an object to fetch
 			// it from
@@ -197,6 +210,45 @@ func (fh *RWFileHandle) openPending(truncate bool) (err error) {
 	return nil
 }
 
+// openSparse opens the partial cache file of o to download it in
+// chunks as it is read for --vfs-cache-chunked.
+//
+// It returns a nil fd if the file can't be downloaded in chunks, eg
+// because it isn't opened read only or another handle is writing it.
+//
+// call with the lock held
+func (fh *RWFileHandle) openSparse(o fs.Object) (fd *os.File, err error) {
+	if !fh.d.vfs.Opt.CacheChunked || o == nil || fh.flags&accessModeMask != os.O_RDONLY || fh.file.activeWriters() != 0 {
+		return nil, nil
+	}
+	sparse, err := fh.d.vfs.cache.openSparse(fh.remote, o, fh.osPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "open RW handle failed to make partial cache file")
+	}
+	if sparse == nil {
+		return nil, nil
+	}
+	fd, err = os.Open(fh.osPath + partialSuffix)
+	if err != nil {
+		fh.d.vfs.cache.closeSparse(fh.remote, sparse, fh.osPath)
+		return nil, errors.Wrap(err, "open RW handle failed to open partial cache file")
+	}
+	fs.Debugf(fh.logPrefix(), "Opened partial cached copy to download in chunks")
+	fh.sparse = sparse
+	return fd, nil
+}
+
+// ensure makes sure size bytes from off are in the cache file if it
+// is being downloaded in chunks
+//
+// call with the lock held
+func (fh *RWFileHandle) ensure(off, size int64) error {
+	if fh.sparse == nil {
+		return nil
+	}
+	return fh.sparse.ensure(off, size)
+}
+
 // String converts it to printable
 func (fh *RWFileHandle) String() string {
 	if fh == nil {
@@ -251,6 +303,10 @@ func (fh *RWFileHandle) close() (err error) {
 		if fh.opened {
 			fh.file.delRWOpen()
 		}
+		if fh.sparse != nil {
+			fh.d.vfs.cache.closeSparse(fh.remote, fh.sparse, fh.osPath)
+			fh.sparse = nil
+		}
 		fh.d.vfs.cache.close(fh.remote)
 	}()
 	rdwrMode := fh.flags & accessModeMask
@@ -263,6 +319,10 @@ func (fh *RWFileHandle) close() (err error) {
 
 	copy := false
 	if writer {
+		// Count as an upload until the close is finished so
+		// Drain waits for the transfer
+		fh.d.vfs.addUpload(1)
+		defer fh.d.vfs.addUpload(-1)
 		copy = fh.file.delWriter(fh, fh.modified())
 		defer fh.file.finishWriterClose()
 	}
@@ -309,6 +369,7 @@ func (fh *RWFileHandle) close() (err error) {
 			return err
 		}
 		fh.file.setObject(o)
+		fh.d.vfs.uploadDone()
 		fs.Debugf(o, "transferred to remote")
 	}
 
@@ -418,6 +479,16 @@ func (fh *RWFileHandle) readFn(read func() (int, error)) (n int, err error) {
 // Read bytes from the file
 func (fh *RWFileHandle) Read(b []byte) (n int, err error) {
 	return fh.readFn(func() (int, error) {
+		if fh.sparse != nil {
+			off, err := fh.File.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return 0, err
+			}
+			err = fh.ensure(off, int64(len(b)))
+			if err != nil {
+				return 0, err
+			}
+		}
 		return fh.File.Read(b)
 	})
 }
@@ -425,6 +496,10 @@ func (fh *RWFileHandle) Read(b []byte) (n int, err error) {
 // ReadAt bytes from the file at off
 func (fh *RWFileHandle) ReadAt(b []byte, off int64) (n int, err error) {
 	return fh.readFn(func() (int, error) {
+		err := fh.ensure(off, int64(len(b)))
+		if err != nil {
+			return 0, err
+		}
 		return fh.File.ReadAt(b, off)
 	})
 }
@@ -593,3 +593,35 @@ func TestRWFileModTimeWithOpenWriters(t *testing.T) {
 	// avoid errors because of timezone differences
 	assert.Equal(t, info.ModTime().Unix(), mtime.Unix())
 }
+
+func TestRWFileHandleDrain(t *testing.T) {
+	r := fstest.NewRun(t)
+	vfs, fh := rwHandleCreateWriteOnly(t, r)
+	defer cleanup(t, r, vfs)
+
+	_, err := fh.WriteString("hello")
+	require.NoError(t, err)
+
+	// Drain with a writer open should time out
+	flushed, err := vfs.Drain(10 * time.Millisecond)
+	assert.Error(t, err)
+	assert.Equal(t, 0, flushed)
+
+	// New writes should be refused while draining
+	_, err = vfs.OpenFile("file2", os.O_WRONLY|os.O_CREATE, 0777)
+	assert.Equal(t, EROFS, err)
+
+	// Close the writer while draining and check it was flushed
+	done := make(chan struct{})
+	go func() {
+		flushed, err = vfs.Drain(10 * time.Second)
+		close(done)
+	}()
+	require.NoError(t, fh.Close())
+	<-done
+	assert.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+
+	file1 := fstest.NewItem("file1", "hello", t1)
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{file1}, []string{}, fs.ModTimeNotSupported)
+}
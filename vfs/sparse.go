@@ -0,0 +1,357 @@
+// This deals with downloading files into the cache in chunks
+
+package vfs
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+// partialSuffix is added to the cache path of files which are being
+// downloaded in chunks until they are complete
+const partialSuffix = ".partial"
+
+// defaultSparseChunkSize is the size of the chunks downloaded if
+// --vfs-read-chunk-size isn't set
+const defaultSparseChunkSize = 128 * 1024 * 1024
+
+// byteRange is the range of bytes from start up to but not including
+// end
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+// byteRanges is a slice of byteRange sorted by start
+type byteRanges []byteRange
+
+// Len is part of sort.Interface.
+func (rs byteRanges) Len() int { return len(rs) }
+
+// Swap is part of sort.Interface.
+func (rs byteRanges) Swap(i, j int) { rs[i], rs[j] = rs[j], rs[i] }
+
+// Less is part of sort.Interface.
+func (rs byteRanges) Less(i, j int) bool { return rs[i].start < rs[j].start }
+
+// sparseFile is a file in the cache for --vfs-cache-chunked which is
+// downloaded in chunks as it is read, so only the parts read are
+// downloaded.
+//
+// It is made at the size of the object with OpenWriterAt on the cache
+// remote then the chunks are written into it.  The ranges present are only known in memory, so
+// the file is kept at its path with partialSuffix added until all of
+// it is present.
+//
+// It is shared by all the handles reading the file and the chunks
+// are downloaded one at a time.
+type sparseFile struct {
+	mu             sync.Mutex
+	o              fs.Object         // object being downloaded
+	f              fs.Fs             // cache remote to write the partial file to
+	remote         string            // name of the partial file in f
+	osPath         string            // path of the partial file
+	out            fs.WriterAtCloser // open for writing the chunks, nil if not open
+	refs           int               // number of handles using this
+	ranges         []byteRange       // sorted and merged ranges present
+	chunkSize      int64             // size of the first chunk
+	chunkSizeLimit int64             // max size of the chunks, -1 for unlimited
+	nextChunk      int64             // size of the next chunk if it carries on from lastEnd
+	lastEnd        int64             // end of the last chunk downloaded
+}
+
+// newSparseFile makes a new sparse file for o called remote in the
+// cache remote f which is at osPath
+func newSparseFile(o fs.Object, f fs.Fs, remote, osPath string, opt *Options) (*sparseFile, error) {
+	sf := &sparseFile{
+		o:      o,
+		f:      f,
+		remote: remote,
+		osPath: osPath,
+	}
+	err := sf._open()
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := int64(opt.ChunkSize)
+	if chunkSize <= 0 {
+		chunkSize = defaultSparseChunkSize
+	}
+	sf.chunkSize = chunkSize
+	sf.chunkSizeLimit = int64(opt.ChunkSizeLimit)
+	sf.nextChunk = chunkSize
+	sf.lastEnd = -1
+	return sf, nil
+}
+
+// _open the partial file for writing at the size of the object if it
+// isn't open already - call with the lock held
+func (sf *sparseFile) _open() (err error) {
+	if sf.out != nil {
+		return nil
+	}
+	openWriterAt := sf.f.Features().OpenWriterAt
+	if openWriterAt == nil {
+		return errors.New("cache remote can't OpenWriterAt")
+	}
+	sf.out, err = openWriterAt(sf.remote, sf.o.Size())
+	if err != nil {
+		return errors.Wrap(err, "failed to open partial cache file")
+	}
+	return nil
+}
+
+// sameObject returns true if o is the object being downloaded
+//
+// The contents can change without the size and modification time
+// changing so the hashes are compared too, unless reading them is
+// slow which would defeat downloading in chunks.
+func (sf *sparseFile) sameObject(o fs.Object) bool {
+	if o.Size() != sf.o.Size() || !o.ModTime().Equal(sf.o.ModTime()) {
+		return false
+	}
+	f := o.Fs()
+	if f.Features().SlowHash {
+		return true
+	}
+	ht := f.Hashes().GetOne()
+	if ht == hash.None {
+		return true
+	}
+	oldHash, err := sf.o.Hash(ht)
+	if err != nil || oldHash == "" {
+		return true
+	}
+	newHash, err := o.Hash(ht)
+	if err != nil || newHash == "" {
+		return true
+	}
+	return oldHash == newHash
+}
+
+// _present returns the end of the range present from off or off if
+// off isn't present - call with the lock held
+func (sf *sparseFile) _present(off int64) int64 {
+	i := sort.Search(len(sf.ranges), func(i int) bool {
+		return sf.ranges[i].end > off
+	})
+	if i < len(sf.ranges) && sf.ranges[i].start <= off {
+		return sf.ranges[i].end
+	}
+	return off
+}
+
+// _nextPresent returns the start of the first range present after off
+// or the size if there isn't one - call with the lock held
+func (sf *sparseFile) _nextPresent(off int64) int64 {
+	i := sort.Search(len(sf.ranges), func(i int) bool {
+		return sf.ranges[i].start > off
+	})
+	if i < len(sf.ranges) {
+		return sf.ranges[i].start
+	}
+	return sf.o.Size()
+}
+
+// _add adds r to the ranges present - call with the lock held
+func (sf *sparseFile) _add(r byteRange) {
+	ranges := append(sf.ranges, r)
+	sort.Sort(byteRanges(ranges))
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.start <= last.end {
+			if r.end > last.end {
+				last.end = r.end
+			}
+		} else {
+			merged = append(merged, r)
+		}
+	}
+	sf.ranges = merged
+}
+
+// complete returns true if all of the file is present
+func (sf *sparseFile) complete() bool {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf._present(0) >= sf.o.Size()
+}
+
+// _chunkSize returns the size of the chunk to download from start -
+// this doubles each time the chunks carry on from the previous one up
+// to chunkSizeLimit.  Call with the lock held.
+func (sf *sparseFile) _chunkSize(start int64) int64 {
+	if start != sf.lastEnd {
+		sf.nextChunk = sf.chunkSize
+	}
+	size := sf.nextChunk
+	if sf.chunkSizeLimit < 0 || sf.nextChunk*2 <= sf.chunkSizeLimit {
+		sf.nextChunk *= 2
+	} else if sf.chunkSizeLimit > sf.nextChunk {
+		sf.nextChunk = sf.chunkSizeLimit
+	}
+	return size
+}
+
+// ensure makes sure the bytes from off for size are present,
+// downloading the parts which aren't
+func (sf *sparseFile) ensure(off, size int64) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	end := off + size
+	if end > sf.o.Size() {
+		end = sf.o.Size()
+	}
+	for off < end {
+		off = sf._present(off)
+		if off >= end {
+			break
+		}
+		chunkEnd := off + sf._chunkSize(off)
+		if next := sf._nextPresent(off); chunkEnd > next {
+			chunkEnd = next
+		}
+		err := sf._download(off, chunkEnd)
+		if err != nil {
+			return err
+		}
+		sf._add(byteRange{start: off, end: chunkEnd})
+		sf.lastEnd = chunkEnd
+		off = chunkEnd
+	}
+	return nil
+}
+
+// _download the bytes from start to end into the file - call with the
+// lock held
+func (sf *sparseFile) _download(start, end int64) (err error) {
+	fs.Debugf(sf.o, "vfs cache: downloading chunk %d-%d", start, end-1)
+	err = sf._open()
+	if err != nil {
+		return err
+	}
+	accounting.Stats.Transferring(sf.o.Remote())
+	defer func() {
+		accounting.Stats.DoneTransferring(sf.o.Remote(), err == nil)
+	}()
+	in0, err := sf.o.Open(&fs.RangeOption{Start: start, End: end - 1})
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to open chunk")
+	}
+	in := accounting.NewAccount(in0, sf.o)
+	_, err = io.Copy(&offsetWriter{w: sf.out, off: start}, io.LimitReader(in, end-start))
+	closeErr := in.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to download chunk")
+	}
+	return nil
+}
+
+// close the file used for writing chunks, finishing the file if it is
+// complete by renaming it to finalPath.
+//
+// It returns true if the file was finished.
+func (sf *sparseFile) close(finalPath string) (finished bool) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.out != nil {
+		err := sf.out.Close()
+		if err != nil {
+			fs.Errorf(sf.o, "vfs cache: failed to close partial cache file: %v", err)
+		}
+		sf.out = nil
+	}
+	if sf._present(0) < sf.o.Size() {
+		return false
+	}
+	if _, err := os.Stat(finalPath); err == nil {
+		// Already cached another way, eg by a writer
+		return false
+	}
+	err := os.Chtimes(sf.osPath, time.Now(), sf.o.ModTime())
+	if err != nil {
+		fs.Debugf(sf.o, "vfs cache: failed to set modification time: %v", err)
+	}
+	err = os.Rename(sf.osPath, finalPath)
+	if err != nil {
+		fs.Errorf(sf.o, "vfs cache: failed to finish partial cache file: %v", err)
+		return false
+	}
+	fs.Debugf(sf.o, "vfs cache: all chunks downloaded")
+	return true
+}
+
+// offsetWriter writes to w from off onwards
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+// Write p at the current offset
+func (ow *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = ow.w.WriteAt(p, ow.off)
+	ow.off += int64(n)
+	return n, err
+}
+
+// openSparse returns the sparseFile to read name from which is stored
+// at osPath when complete, making a new one if necessary.
+//
+// It returns nil if a sparse file can't be used, eg because it is
+// being read already with a different version of o.
+func (c *cache) openSparse(name string, o fs.Object, osPath string) (*sparseFile, error) {
+	if o.Size() < 0 {
+		return nil, nil
+	}
+	name = clean(name)
+	c.itemMu.Lock()
+	defer c.itemMu.Unlock()
+	item, _ := c._get(true, name)
+	if item.sparse != nil {
+		if item.sparse.sameObject(o) {
+			item.sparse.refs++
+			return item.sparse, nil
+		}
+		if item.sparse.refs > 0 {
+			return nil, nil
+		}
+		fs.Debugf(name, "vfs cache: discarding chunks of old version")
+		item.sparse = nil
+	}
+	sf, err := newSparseFile(o, c.f, name+partialSuffix, osPath+partialSuffix, c.opt)
+	if err != nil {
+		return nil, err
+	}
+	sf.refs++
+	item.sparse = sf
+	return sf, nil
+}
+
+// closeSparse should be called when a handle has finished with sf
+// which was read from name stored at osPath
+func (c *cache) closeSparse(name string, sf *sparseFile, osPath string) {
+	name = clean(name)
+	c.itemMu.Lock()
+	defer c.itemMu.Unlock()
+	sf.refs--
+	if sf.refs > 0 {
+		return
+	}
+	if sf.close(osPath) {
+		if item := c.item[name]; item != nil && item.sparse == sf {
+			item.sparse = nil
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fstest"
+	"github.com/ncw/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparseChunkSize(t *testing.T) {
+	sf := &sparseFile{chunkSize: 4, chunkSizeLimit: 16, nextChunk: 4, lastEnd: -1}
+	var got []int64
+	start := int64(0)
+	for i := 0; i < 5; i++ {
+		size := sf._chunkSize(start)
+		got = append(got, size)
+		start += size
+		sf.lastEnd = start
+	}
+	assert.Equal(t, []int64{4, 8, 16, 16, 16}, got)
+
+	// Starts again from the chunk size if not sequential
+	assert.Equal(t, int64(4), sf._chunkSize(1000))
+
+	// Unlimited
+	sf = &sparseFile{chunkSize: 4, chunkSizeLimit: -1, nextChunk: 4, lastEnd: -1}
+	assert.Equal(t, int64(4), sf._chunkSize(0))
+	sf.lastEnd = 4
+	assert.Equal(t, int64(8), sf._chunkSize(4))
+	sf.lastEnd = 12
+	assert.Equal(t, int64(16), sf._chunkSize(12))
+}
+
+func TestSparseAdd(t *testing.T) {
+	sf := &sparseFile{}
+	sf._add(byteRange{10, 20})
+	sf._add(byteRange{30, 40})
+	sf._add(byteRange{0, 5})
+	assert.Equal(t, []byteRange{{0, 5}, {10, 20}, {30, 40}}, sf.ranges)
+	assert.Equal(t, int64(20), sf._present(10))
+	assert.Equal(t, int64(20), sf._present(15))
+	assert.Equal(t, int64(25), sf._present(25))
+	sf._add(byteRange{5, 10})
+	assert.Equal(t, []byteRange{{0, 20}, {30, 40}}, sf.ranges)
+	sf._add(byteRange{15, 35})
+	assert.Equal(t, []byteRange{{0, 40}}, sf.ranges)
+}
+
+// Open dir/file1 read only with --vfs-cache-chunked
+func sparseOpen(t *testing.T, vfs *VFS) *RWFileHandle {
+	h, err := vfs.OpenFile("dir/file1", os.O_RDONLY, 0777)
+	require.NoError(t, err)
+	fh, ok := h.(*RWFileHandle)
+	require.True(t, ok)
+	return fh
+}
+
+func TestSparseRWFileHandle(t *testing.T) {
+	r := fstest.NewRun(t)
+	opt := DefaultOpt
+	opt.CacheMode = CacheModeFull
+	opt.CacheChunked = true
+	opt.ChunkSize = 4
+	opt.ChunkSizeLimit = 8
+	vfs := New(r.Fremote, &opt)
+	defer cleanup(t, r, vfs)
+
+	file1 := r.WriteObject("dir/file1", "0123456789abcdef", t1)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	fh1 := sparseOpen(t, vfs)
+	fh2 := sparseOpen(t, vfs)
+
+	// Read from the middle of the file
+	buf := make([]byte, 2)
+	n, err := fh1.ReadAt(buf, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "ab", string(buf[:n]))
+	require.NotNil(t, fh1.sparse)
+	assert.Equal(t, []byteRange{{10, 14}}, fh1.sparse.ranges)
+
+	// Only the partial file should be in the cache
+	_, err = os.Stat(fh1.osPath)
+	assert.True(t, os.IsNotExist(err))
+	fi, err := os.Stat(fh1.osPath + partialSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, int64(16), fi.Size())
+
+	// Read from the start with the other handle
+	assert.Equal(t, "012", rwReadString(t, fh2, 3))
+	assert.True(t, fh1.sparse == fh2.sparse)
+	assert.Equal(t, []byteRange{{0, 4}, {10, 14}}, fh1.sparse.ranges)
+	assert.False(t, fh1.sparse.complete())
+
+	// Read the rest
+	buf = make([]byte, 16)
+	n, err = fh1.ReadAt(buf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(buf[:n]))
+	assert.Equal(t, []byteRange{{0, 16}}, fh1.sparse.ranges)
+	assert.True(t, fh1.sparse.complete())
+
+	// Finished when the last handle is closed
+	require.NoError(t, fh1.Close())
+	_, err = os.Stat(fh2.osPath)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, fh2.Close())
+
+	contents, err := ioutil.ReadFile(fh2.osPath)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(contents))
+	_, err = os.Stat(fh2.osPath + partialSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	// Now opens the complete file
+	fh3 := sparseOpen(t, vfs)
+	assert.Equal(t, "0123", rwReadString(t, fh3, 4))
+	assert.Nil(t, fh3.sparse)
+	require.NoError(t, fh3.Close())
+}
+
+func TestSparseEviction(t *testing.T) {
+	r := fstest.NewRun(t)
+	opt := DefaultOpt
+	opt.CacheMode = CacheModeFull
+	opt.CacheChunked = true
+	opt.ChunkSize = 4
+	vfs := New(r.Fremote, &opt)
+	defer cleanup(t, r, vfs)
+
+	file1 := r.WriteObject("dir/file1", "0123456789abcdef", t1)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	fh := sparseOpen(t, vfs)
+	assert.Equal(t, "01", rwReadString(t, fh, 2))
+	partial := fh.osPath + partialSuffix
+	require.NoError(t, fh.Close())
+
+	// Partial file kept while the chunks are known
+	c := vfs.cache
+	require.NoError(t, c.updateAtimes())
+	_, err := os.Stat(partial)
+	require.NoError(t, err)
+
+	// Reopening carries on with the chunks downloaded already
+	fh = sparseOpen(t, vfs)
+	assert.Equal(t, "01", rwReadString(t, fh, 2))
+	assert.Equal(t, []byteRange{{0, 4}}, fh.sparse.ranges)
+	require.NoError(t, fh.Close())
+
+	// Removing the file forgets the chunks downloaded
+	c.remove("dir/file1")
+	_, err = os.Stat(partial)
+	assert.True(t, os.IsNotExist(err))
+	fh = sparseOpen(t, vfs)
+	assert.Equal(t, "01", rwReadString(t, fh, 2))
+	assert.Equal(t, []byteRange{{0, 4}}, fh.sparse.ranges)
+	require.NoError(t, fh.Close())
+
+	// Purging the file removes the partial file
+	c.purgeOld(0)
+	_, err = os.Stat(partial)
+	assert.True(t, os.IsNotExist(err))
+
+	// Stale partial files are removed
+	require.NoError(t, os.MkdirAll(filepath.Dir(partial), 0700))
+	require.NoError(t, ioutil.WriteFile(partial, []byte("stale"), 0600))
+	require.NoError(t, c.updateAtimes())
+	_, err = os.Stat(partial)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// hashInfo is an fs.Info with the hashes and features given
+type hashInfo struct {
+	hashes   hash.Set
+	features fs.Features
+}
+
+func (f *hashInfo) Name() string             { return "hashInfo" }
+func (f *hashInfo) Root() string             { return "" }
+func (f *hashInfo) String() string           { return "hashInfo" }
+func (f *hashInfo) Precision() time.Duration { return time.Second }
+func (f *hashInfo) Hashes() hash.Set         { return f.hashes }
+func (f *hashInfo) Features() *fs.Features   { return &f.features }
+
+// hashObject is an fs.Object with the size, modification time and MD5
+// given
+type hashObject struct {
+	mockobject.Object
+	f       *hashInfo
+	size    int64
+	modTime time.Time
+	md5     string
+}
+
+func (o *hashObject) Fs() fs.Info                    { return o.f }
+func (o *hashObject) Size() int64                    { return o.size }
+func (o *hashObject) ModTime() time.Time             { return o.modTime }
+func (o *hashObject) Hash(hash.Type) (string, error) { return o.md5, nil }
+
+func TestSparseSameObject(t *testing.T) {
+	f := &hashInfo{hashes: hash.Set(hash.MD5)}
+	newObject := func(size int64, modTime time.Time, md5 string) *hashObject {
+		return &hashObject{Object: mockobject.New("file"), f: f, size: size, modTime: modTime, md5: md5}
+	}
+	sf := &sparseFile{o: newObject(16, t1, "aaaa")}
+
+	assert.True(t, sf.sameObject(newObject(16, t1, "aaaa")))
+	assert.False(t, sf.sameObject(newObject(15, t1, "aaaa")))
+	assert.False(t, sf.sameObject(newObject(16, t2, "aaaa")))
+	assert.False(t, sf.sameObject(newObject(16, t1, "bbbb")))
+	assert.True(t, sf.sameObject(newObject(16, t1, "")))
+
+	// Slow hashes aren't read
+	f.features.SlowHash = true
+	assert.True(t, sf.sameObject(newObject(16, t1, "bbbb")))
+	f.features.SlowHash = false
+
+	// Nor are they if there aren't any
+	f.hashes = hash.Set(hash.None)
+	assert.True(t, sf.sameObject(newObject(16, t1, "bbbb")))
+}
@@ -30,6 +30,7 @@ import (
 
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/log"
+	"github.com/pkg/errors"
 )
 
 // DefaultOpt is the default values uses for Opt
@@ -48,6 +49,7 @@ var DefaultOpt = Options{
 	CacheMode:         CacheModeOff,
 	CacheMaxAge:       3600 * time.Second,
 	CachePollInterval: 60 * time.Second,
+	ShutdownTimeout:   60 * time.Second,
 }
 
 // Node represents either a directory (*Dir) or a file (*File)
@@ -174,6 +176,11 @@ type VFS struct {
 	usageMu   sync.Mutex
 	usageTime time.Time
 	usage     *fs.Usage
+	draining  int32 // set to stop accepting new writes
+	uploads   int32 // number of uploads from the cache in progress
+	uploaded  int32 // number of uploads from the cache finished
+	drainMu   sync.Mutex
+	drained   int32 // value of uploaded when draining started
 }
 
 // Options is options for creating the vfs
@@ -192,8 +199,11 @@ type Options struct {
 	ChunkSize         fs.SizeSuffix // if > 0 read files in chunks
 	ChunkSizeLimit    fs.SizeSuffix // if > ChunkSize double the chunk size after each chunk until reached
 	CacheMode         CacheMode
+	CacheChunked      bool // in full cache mode download files in chunks as they are read
 	CacheMaxAge       time.Duration
 	CachePollInterval time.Duration
+	ShutdownTimeout   time.Duration // how long to wait for writes and uploads on shutdown
+	XattrHashes       bool          // expose the object hashes as extended attributes
 }
 
 // New creates a new VFS and root directory.  If opt is nil, then
@@ -276,10 +286,28 @@ func (vfs *VFS) FlushDirCache() {
 	vfs.root.ForgetAll()
 }
 
-// WaitForWriters sleeps until all writers have finished or
-// time.Duration has elapsed
-func (vfs *VFS) WaitForWriters(timeout time.Duration) {
-	defer log.Trace(nil, "timeout=%v", timeout)("")
+// activeWriters returns the number of writers open on all the files
+func (vfs *VFS) activeWriters() (writers int) {
+	vfs.root.walk("", func(d *Dir) {
+		fs.Debugf(d.path, "Looking for writers")
+		// NB d.mu is held by walk() here
+		for leaf, item := range d.items {
+			fs.Debugf(leaf, "reading active writers")
+			if file, ok := item.(*File); ok {
+				n := file.activeWriters()
+				if n != 0 {
+					fs.Debugf(file, "active writers %d", n)
+				}
+				writers += n
+			}
+		}
+	})
+	return writers
+}
+
+// waitFor sleeps until busy returns 0 or timeout has elapsed.  It
+// returns the last value of busy.
+func waitFor(timeout time.Duration, what string, busy func() int) int {
 	const tickTime = 1 * time.Second
 	deadline := time.NewTimer(timeout)
 	defer deadline.Stop()
@@ -287,36 +315,75 @@ func (vfs *VFS) WaitForWriters(timeout time.Duration) {
 	defer tick.Stop()
 	tick.Stop()
 	for {
-		writers := 0
-		vfs.root.walk("", func(d *Dir) {
-			fs.Debugf(d.path, "Looking for writers")
-			// NB d.mu is held by walk() here
-			for leaf, item := range d.items {
-				fs.Debugf(leaf, "reading active writers")
-				if file, ok := item.(*File); ok {
-					n := file.activeWriters()
-					if n != 0 {
-						fs.Debugf(file, "active writers %d", n)
-					}
-					writers += n
-				}
-			}
-		})
-		if writers == 0 {
-			return
+		n := busy()
+		if n == 0 {
+			return 0
 		}
-		fs.Debugf(nil, "Still %d writers active, waiting %v", writers, tickTime)
+		fs.Debugf(nil, "Still %d %s active, waiting %v", n, what, tickTime)
 		tick.Reset(tickTime)
 		select {
 		case <-tick.C:
 			break
 		case <-deadline.C:
-			fs.Errorf(nil, "Exiting even though %d writers are active after %v", writers, timeout)
-			return
+			return n
 		}
 	}
 }
 
+// WaitForWriters sleeps until all writers have finished or
+// time.Duration has elapsed
+func (vfs *VFS) WaitForWriters(timeout time.Duration) {
+	defer log.Trace(nil, "timeout=%v", timeout)("")
+	writers := waitFor(timeout, "writers", vfs.activeWriters)
+	if writers != 0 {
+		fs.Errorf(nil, "Exiting even though %d writers are active after %v", writers, timeout)
+	}
+}
+
+// addUpload should be called with +1 and -1 around uploads from the
+// cache so Drain can wait for them
+func (vfs *VFS) addUpload(delta int32) {
+	atomic.AddInt32(&vfs.uploads, delta)
+}
+
+// uploadDone should be called when an upload from the cache has
+// finished successfully
+func (vfs *VFS) uploadDone() {
+	atomic.AddInt32(&vfs.uploaded, 1)
+}
+
+// isDraining returns true if Drain has been called and new writes
+// should be refused
+func (vfs *VFS) isDraining() bool {
+	return atomic.LoadInt32(&vfs.draining) != 0
+}
+
+// Drain stops the VFS accepting new writes then waits until all the
+// open writers have been closed and the uploads from the cache have
+// finished or timeout has elapsed.
+//
+// It returns the number of files which were flushed since draining
+// started and an error if the timeout elapsed with writes still in
+// progress.
+func (vfs *VFS) Drain(timeout time.Duration) (flushed int, err error) {
+	defer log.Trace(nil, "timeout=%v", timeout)("flushed=%d, err=%v", &flushed, &err)
+	vfs.drainMu.Lock()
+	if !vfs.isDraining() {
+		vfs.drained = atomic.LoadInt32(&vfs.uploaded)
+		atomic.StoreInt32(&vfs.draining, 1)
+	}
+	start := vfs.drained
+	vfs.drainMu.Unlock()
+	busy := waitFor(timeout, "writers and uploads", func() int {
+		return vfs.activeWriters() + int(atomic.LoadInt32(&vfs.uploads))
+	})
+	flushed = int(atomic.LoadInt32(&vfs.uploaded) - start)
+	if busy != 0 {
+		return flushed, errors.Errorf("timed out after %v with %d writers and uploads still active", timeout, busy)
+	}
+	return flushed, nil
+}
+
 // Root returns the root node
 func (vfs *VFS) Root() (*Dir, error) {
 	// fs.Debugf(vfs.f, "Root()")
@@ -21,9 +21,12 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.DurationVarP(flagSet, &Opt.PollInterval, "poll-interval", "", Opt.PollInterval, "Time to wait between polling for changes. Must be smaller than dir-cache-time. Only on supported remotes. Set to 0 to disable.")
 	flags.BoolVarP(flagSet, &Opt.ReadOnly, "read-only", "", Opt.ReadOnly, "Mount read-only.")
 	flags.FVarP(flagSet, &Opt.CacheMode, "vfs-cache-mode", "", "Cache mode off|minimal|writes|full")
+	flags.BoolVarP(flagSet, &Opt.CacheChunked, "vfs-cache-chunked", "", Opt.CacheChunked, "In full cache mode download files in chunks as they are read.")
 	flags.DurationVarP(flagSet, &Opt.CachePollInterval, "vfs-cache-poll-interval", "", Opt.CachePollInterval, "Interval to poll the cache for stale objects.")
 	flags.DurationVarP(flagSet, &Opt.CacheMaxAge, "vfs-cache-max-age", "", Opt.CacheMaxAge, "Max age of objects in the cache.")
+	flags.DurationVarP(flagSet, &Opt.ShutdownTimeout, "vfs-shutdown-timeout", "", Opt.ShutdownTimeout, "Time to wait for writes and uploads to finish on shutdown.")
 	flags.FVarP(flagSet, &Opt.ChunkSize, "vfs-read-chunk-size", "", "Read the source objects in chunks.")
 	flags.FVarP(flagSet, &Opt.ChunkSizeLimit, "vfs-read-chunk-size-limit", "", "If greater than --vfs-read-chunk-size, double the chunk size after each chunk read, until the limit is reached. -1 is unlimited.")
+	flags.BoolVarP(flagSet, &Opt.XattrHashes, "vfs-xattr-hashes", "", Opt.XattrHashes, "Expose the hashes the remote supports as extended attributes, eg user.rclone.md5.")
 	platformFlags(flagSet)
 }